@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// config is where the API lives and how to authenticate
+type config struct {
+	URL   string
+	Token string
+}
+
+// loadConfig merges the flags, the environment and the config file, in that
+// order of precedence. The config file holds "url = ..." and "token = ..."
+// lines; it defaults to $HANNACTL_CONFIG or ~/.config/hannactl/config and
+// may be missing unless named explicitly.
+func loadConfig(path, urlFlag, tokenFlag string) (config, error) {
+	explicit := path != ""
+	if path == "" {
+		path = os.Getenv("HANNACTL_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "hannactl", "config")
+		}
+	}
+
+	var file config
+	if path != "" {
+		var err error
+		file, err = readConfigFile(path)
+		if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+			return config{}, err
+		}
+	}
+
+	cfg := config{
+		URL:   first(urlFlag, os.Getenv("HANNA_API_URL"), file.URL, defaultURL),
+		Token: first(tokenFlag, os.Getenv("HANNA_API_TOKEN"), os.Getenv("API_TOKEN"), file.Token),
+	}
+	if cfg.Token == "" {
+		return config{}, errors.New("no API token (set -token, HANNA_API_TOKEN or token in the config file)")
+	}
+	return cfg, nil
+}
+
+func readConfigFile(path string) (config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return config{}, err
+	}
+	defer f.Close()
+
+	var cfg config
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return config{}, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "url":
+			cfg.URL = value
+		case "token":
+			cfg.Token = value
+		default:
+			return config{}, fmt.Errorf("%s:%d: unknown key %q", path, n, strings.TrimSpace(key))
+		}
+	}
+	return cfg, scanner.Err()
+}
+
+// first returns the first non-empty value
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const consoleHelp = `commands:
+  <text>                  send to the current channel
+  /chan #channel          switch the current channel
+  /join #channel          join a channel and switch to it
+  /part [#channel] [reason]
+  /msg <target> <text>    send a message
+  /notice <target> <text> send a notice
+  /me <text>              send an action to the current channel
+  /channels               list joined channels
+  /users [#channel]       list the users of a channel
+  /state                  show the connection state
+  /whois <nick>
+  /raw <line>             send a raw IRC line
+  /quit
+`
+
+// syncWriter serializes output from the event stream and the command loop
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// console is an interactive session: live traffic from the event stream is
+// printed while typed lines are sent or run as commands
+type console struct {
+	api     *apiClient
+	out     io.Writer
+	mu      sync.Mutex
+	nick    string
+	current string // channel plain text is sent to
+}
+
+func cmdConsole(ctx context.Context, api *apiClient, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("console", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	channel := fs.String("channel", "", "initial current channel")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+	var s state
+	if err := api.getJSON(ctx, "GET", "/api/state", nil, &s); err != nil {
+		return err
+	}
+	c := &console{api: api, out: &syncWriter{w: out}, nick: s.Nick, current: *channel}
+	fmt.Fprintf(c.out, "*** %s is %s on %d channels; /help lists commands\n", s.Nick, s.Connection.State, len(s.Channels))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.follow(ctx)
+
+	// Read stdin on its own goroutine so an interrupt ends the console even
+	// while waiting for input
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			quit, err := c.handle(ctx, strings.TrimSpace(line))
+			if err != nil {
+				fmt.Fprintf(c.out, "!!! %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+		}
+	}
+}
+
+// follow prints the event stream, reconnecting when it drops
+func (c *console) follow(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := streamEvents(ctx, c.api, "", "", func(data []byte) {
+			var e event
+			if json.Unmarshal(data, &e) != nil || e.Type == "mention" {
+				return // mentions repeat a privmsg
+			}
+			if e.Type == "nick" && e.Self {
+				c.mu.Lock()
+				c.nick = e.Nick
+				c.mu.Unlock()
+			}
+			fmt.Fprintln(c.out, formatEvent(e))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Fprintf(c.out, "!!! event stream lost: %v (retrying in 5s)\n", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// handle runs one input line and reports whether the console should exit
+func (c *console) handle(ctx context.Context, line string) (quit bool, err error) {
+	if line == "" {
+		return false, nil
+	}
+	c.mu.Lock()
+	current, nick := c.current, c.nick
+	c.mu.Unlock()
+
+	if !strings.HasPrefix(line, "/") || strings.HasPrefix(line, "//") {
+		if current == "" {
+			return false, fmt.Errorf("no current channel, use /chan #channel")
+		}
+		return false, c.send(ctx, "/api/send", current, strings.TrimPrefix(line, "/"), nick)
+	}
+
+	cmd, rest, _ := strings.Cut(line[1:], " ")
+	rest = strings.TrimSpace(rest)
+	arg, text, _ := strings.Cut(rest, " ")
+	text = strings.TrimSpace(text)
+	switch strings.ToLower(cmd) {
+	case "help":
+		fmt.Fprint(c.out, consoleHelp)
+	case "quit", "exit":
+		return true, nil
+	case "chan":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /chan #channel")
+		}
+		c.setCurrent(arg)
+	case "join":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /join #channel")
+		}
+		if err := c.post(ctx, "/api/join", map[string]string{"channel": arg}); err != nil {
+			return false, err
+		}
+		c.setCurrent(arg)
+	case "part":
+		channel, reason := arg, text
+		if channel == "" || !strings.ContainsAny(channel[:1], "#&+!") {
+			channel, reason = current, rest
+		}
+		if channel == "" {
+			return false, fmt.Errorf("usage: /part [#channel] [reason]")
+		}
+		return false, c.post(ctx, "/api/part", map[string]string{"channel": channel, "reason": reason})
+	case "msg", "notice":
+		if arg == "" || text == "" {
+			return false, fmt.Errorf("usage: /%s <target> <text>", cmd)
+		}
+		path := "/api/send"
+		if cmd == "notice" {
+			path = "/api/notice"
+		}
+		return false, c.send(ctx, path, arg, text, nick)
+	case "me":
+		if current == "" || rest == "" {
+			return false, fmt.Errorf("usage: /me <text> (with a current channel)")
+		}
+		return false, c.send(ctx, "/api/action", current, rest, nick)
+	case "channels", "users":
+		return false, c.listChannels(ctx, cmd == "users", first(arg, current))
+	case "state":
+		return false, cmdState(ctx, c.api, nil, c.out)
+	case "whois":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /whois <nick>")
+		}
+		return false, cmdWhois(ctx, c.api, []string{arg}, c.out)
+	case "raw":
+		if rest == "" {
+			return false, fmt.Errorf("usage: /raw <line>")
+		}
+		return false, c.post(ctx, "/api/raw", map[string]string{"line": rest})
+	default:
+		return false, fmt.Errorf("unknown command /%s, see /help", cmd)
+	}
+	return false, nil
+}
+
+func (c *console) setCurrent(channel string) {
+	c.mu.Lock()
+	c.current = channel
+	c.mu.Unlock()
+	fmt.Fprintf(c.out, "*** now talking in %s\n", channel)
+}
+
+func (c *console) post(ctx context.Context, path string, body any) error {
+	var resp map[string]any
+	return c.api.getJSON(ctx, "POST", path, body, &resp)
+}
+
+// send delivers a message and echoes it, since the bot's own messages do not
+// come back on the event stream
+func (c *console) send(ctx context.Context, path, target, text, nick string) error {
+	if err := c.post(ctx, path, map[string]string{"target": target, "message": text}); err != nil {
+		return err
+	}
+	e := event{Type: "privmsg", Time: time.Now(), Sender: nick, Target: target, Text: text}
+	switch path {
+	case "/api/notice":
+		e.Type = "notice"
+	case "/api/action":
+		e.Type = "action"
+	}
+	fmt.Fprintln(c.out, formatEvent(e))
+	return nil
+}
+
+// listChannels prints the joined channels, or the users of one channel
+func (c *console) listChannels(ctx context.Context, users bool, channel string) error {
+	var s state
+	if err := c.api.getJSON(ctx, "GET", "/api/state", nil, &s); err != nil {
+		return err
+	}
+	if !users {
+		names := make([]string, 0, len(s.Channels))
+		for name, members := range s.Channels {
+			names = append(names, fmt.Sprintf("%s (%d)", name, len(members)))
+		}
+		sort.Strings(names)
+		fmt.Fprintf(c.out, "*** channels: %s\n", strings.Join(names, ", "))
+		return nil
+	}
+	if channel == "" {
+		return fmt.Errorf("usage: /users #channel")
+	}
+	var members map[string]any
+	for name, m := range s.Channels {
+		if strings.EqualFold(name, channel) {
+			members = m
+		}
+	}
+	if members == nil {
+		return fmt.Errorf("not in %s", channel)
+	}
+	nicks := make([]string, 0, len(members))
+	for nick, modes := range members {
+		if m, ok := modes.(string); ok && m != "" {
+			nick += "(+" + m + ")"
+		}
+		nicks = append(nicks, nick)
+	}
+	sort.Strings(nicks)
+	fmt.Fprintf(c.out, "*** %s (%d): %s\n", channel, len(nicks), strings.Join(nicks, " "))
+	return nil
+}
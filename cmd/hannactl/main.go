@@ -0,0 +1,387 @@
+// hannactl is a command line client for the Hanna REST API.
+//
+// Usage:
+//
+//	hannactl [-url URL] [-token TOKEN] <command> [arguments]
+//
+// Commands:
+//
+//	send [-notice] [-format raw|markdown|plain] <target> <message>
+//	state [-json]
+//	whois [-json] <nick>
+//	tail [-json] [-types privmsg,join] [-channel #chan]
+//	console [-channel #chan]
+//
+// The API URL and token come from the flags, then HANNA_API_URL and
+// HANNA_API_TOKEN (or API_TOKEN), then the config file.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+)
+
+const usage = `usage: hannactl [-url URL] [-token TOKEN] [-config FILE] <command> [arguments]
+
+commands:
+  send [-notice] [-format raw|markdown|plain] <target> <message>
+  state [-json]
+  whois [-json] <nick>
+  tail [-json] [-types privmsg,join] [-channel #chan]
+  console [-channel #chan]
+`
+
+// errUsage makes main print the usage text and exit with status 2
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "hannactl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("hannactl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	urlFlag := fs.String("url", "", "API URL")
+	tokenFlag := fs.String("token", "", "API token")
+	configFlag := fs.String("config", "", "config file")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	cfg, err := loadConfig(*configFlag, *urlFlag, *tokenFlag)
+	if err != nil {
+		return err
+	}
+	api := &apiClient{base: strings.TrimRight(cfg.URL, "/"), token: cfg.Token, http: &http.Client{}}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "send":
+		return cmdSend(ctx, api, cmdArgs)
+	case "state":
+		return cmdState(ctx, api, cmdArgs, out)
+	case "whois":
+		return cmdWhois(ctx, api, cmdArgs, out)
+	case "tail":
+		return cmdTail(ctx, api, cmdArgs, out)
+	case "console":
+		return cmdConsole(ctx, api, cmdArgs, in, out)
+	}
+	return fmt.Errorf("unknown command %q (see hannactl -h)", cmd)
+}
+
+// apiClient makes authenticated requests to the REST API
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// do sends a request with an optional JSON body and returns the response,
+// turning error statuses into errors with the API's message
+func (a *apiClient) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct{ Error string }
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+// getJSON decodes the response of a request into v
+func (a *apiClient) getJSON(ctx context.Context, method, path string, body, v any) error {
+	resp, err := a.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func cmdSend(ctx context.Context, api *apiClient, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	notice := fs.Bool("notice", false, "send a NOTICE")
+	format := fs.String("format", "", "raw, markdown or plain")
+	if err := fs.Parse(args); err != nil || fs.NArg() < 2 {
+		return errUsage
+	}
+	path := "/api/send"
+	if *notice {
+		path = "/api/notice"
+	}
+	body := map[string]string{
+		"target":  fs.Arg(0),
+		"message": strings.Join(fs.Args()[1:], " "),
+		"format":  *format,
+	}
+	var resp map[string]any
+	return api.getJSON(ctx, "POST", path, body, &resp)
+}
+
+type state struct {
+	Connected  bool `json:"connected"`
+	Connection struct {
+		State  string    `json:"state"`
+		Since  time.Time `json:"since"`
+		Reason string    `json:"reason"`
+	} `json:"connection"`
+	Nick      string `json:"nick"`
+	UserModes string `json:"user_modes"`
+	Away      struct {
+		Away    bool   `json:"away"`
+		Message string `json:"message"`
+	} `json:"away"`
+	Channels map[string]map[string]any `json:"channels"`
+}
+
+func cmdState(ctx context.Context, api *apiClient, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("state", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "print the raw JSON")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+	var raw json.RawMessage
+	if err := api.getJSON(ctx, "GET", "/api/state", nil, &raw); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(out, raw)
+	}
+	var s state
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "connection: %s", s.Connection.State)
+	if !s.Connection.Since.IsZero() {
+		fmt.Fprintf(out, " since %s", s.Connection.Since.Local().Format(time.DateTime))
+	}
+	if s.Connection.Reason != "" {
+		fmt.Fprintf(out, " (%s)", s.Connection.Reason)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "nick:       %s\n", s.Nick)
+	if s.UserModes != "" {
+		fmt.Fprintf(out, "modes:      +%s\n", s.UserModes)
+	}
+	if s.Away.Away {
+		fmt.Fprintf(out, "away:       %s\n", s.Away.Message)
+	}
+	names := make([]string, 0, len(s.Channels))
+	for name := range s.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(out, "channels:   %d\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-20s %d users\n", name, len(s.Channels[name]))
+	}
+	return nil
+}
+
+func cmdWhois(ctx context.Context, api *apiClient, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("whois", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "print the raw JSON")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	var raw json.RawMessage
+	if err := api.getJSON(ctx, "POST", "/api/whois", map[string]string{"nick": fs.Arg(0)}, &raw); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(out, raw)
+	}
+	var info map[string]any
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return err
+	}
+	delete(info, "raw_data")
+	keys := make([]string, 0, len(info))
+	for k := range info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(out, "%-13s %v\n", k+":", info[k])
+	}
+	return nil
+}
+
+// event holds the fields of a streamed event that tail prints
+type event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Sender   string    `json:"sender"`
+	Target   string    `json:"target"`
+	Nick     string    `json:"nick"`
+	Text     string    `json:"text"`
+	Message  string    `json:"message"`
+	Channels []string  `json:"channels"`
+	Self     bool      `json:"self"`
+}
+
+func cmdTail(ctx context.Context, api *apiClient, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "print one JSON event per line")
+	types := fs.String("types", "", "comma-separated event types")
+	channel := fs.String("channel", "", "only events in this channel")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+	return streamEvents(ctx, api, *types, *channel, func(data []byte) {
+		if *asJSON {
+			fmt.Fprintf(out, "%s\n", data)
+			return
+		}
+		var e event
+		if err := json.Unmarshal(data, &e); err == nil {
+			fmt.Fprintln(out, formatEvent(e))
+		}
+	})
+}
+
+// streamEvents follows /api/events, calling handle with the JSON of each
+// event until ctx is cancelled or the stream ends
+func streamEvents(ctx context.Context, api *apiClient, types, channel string, handle func(data []byte)) error {
+	query := url.Values{}
+	if types != "" {
+		query.Set("types", types)
+	}
+	if channel != "" {
+		query.Set("channel", channel)
+	}
+	path := "/api/events"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := api.do(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Server-sent events: data lines are collected until a blank line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if d, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimPrefix(d, []byte(" "))...)
+			continue
+		}
+		if len(line) > 0 || len(data) == 0 {
+			continue
+		}
+		handle(data)
+		data = data[:0]
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("event stream closed by the server")
+}
+
+// formatEvent renders an event as a chat log line
+func formatEvent(e event) string {
+	where := e.Target
+	if where == "" {
+		where = strings.Join(e.Channels, ",")
+	}
+	var line string
+	switch e.Type {
+	case "action":
+		line = fmt.Sprintf("* %s %s", e.Sender, e.Text)
+	case "privmsg", "pm", "mention":
+		if action, ok := strings.CutPrefix(e.Text, "\x01ACTION "); ok {
+			line = fmt.Sprintf("* %s %s", e.Sender, strings.TrimSuffix(action, "\x01"))
+		} else {
+			line = fmt.Sprintf("<%s> %s", e.Sender, e.Text)
+		}
+	case "notice":
+		line = fmt.Sprintf("-%s- %s", e.Sender, e.Text)
+	case "join":
+		line = fmt.Sprintf("*** %s joined", e.Sender)
+	case "part":
+		line = fmt.Sprintf("*** %s left", e.Sender)
+		if e.Text != "" {
+			line += " (" + e.Text + ")"
+		}
+	case "quit":
+		line = fmt.Sprintf("*** %s quit", e.Sender)
+		if e.Text != "" {
+			line += " (" + e.Text + ")"
+		}
+	case "kick":
+		line = fmt.Sprintf("*** %s was kicked by %s (%s)", e.Nick, e.Sender, e.Text)
+	case "nick":
+		line = fmt.Sprintf("*** %s is now known as %s", e.Sender, e.Nick)
+	case "topic":
+		line = fmt.Sprintf("*** %s changed the topic to: %s", e.Sender, e.Text)
+	default:
+		line = fmt.Sprintf("*** %s: %s", e.Type, e.Message)
+	}
+	return fmt.Sprintf("%s %s %s", e.Time.Local().Format(time.TimeOnly), where, line)
+}
+
+func printJSON(out io.Writer, raw json.RawMessage) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(out)
+	return err
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeAPI(t *testing.T, requests chan<- string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(401)
+			fmt.Fprint(w, `{"error":"invalid or missing bearer token"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/send":
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			requests <- in["target"] + " " + in["message"]
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/api/join":
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			requests <- "JOIN " + in["channel"]
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/api/state":
+			fmt.Fprint(w, `{"connected":true,"connection":{"state":"connected"},"nick":"hanna","channels":{"#test":{"hanna":null,"alice":"o"}}}`)
+		case "/api/events":
+			if r.URL.RawQuery != "" {
+				requests <- r.URL.RawQuery
+			}
+			fmt.Fprint(w, "event: privmsg\ndata: {\"type\":\"privmsg\",\"sender\":\"alice\",\"target\":\"#test\",\"text\":\"hi\"}\n\n")
+			fmt.Fprint(w, "event: join\ndata: {\"type\":\"join\",\"sender\":\"bob\",\"target\":\"#test\"}\n\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestCommands(t *testing.T) {
+	requests := make(chan string, 10)
+	url := fakeAPI(t, requests)
+	empty := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(empty, nil, 0o600)
+	t.Setenv("HANNACTL_CONFIG", empty)
+
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-url", url, "-token", "secret", "send", "#test", "hello", "world"}, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-requests; got != "#test hello world" {
+		t.Errorf("Unexpected send %q", got)
+	}
+
+	err = run(context.Background(), []string{"-url", url, "-token", "wrong", "state"}, nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "invalid or missing bearer token") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+
+	out.Reset()
+	if err := run(context.Background(), []string{"-url", url, "-token", "secret", "state"}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "nick:       hanna") || !strings.Contains(out.String(), "#test") || !strings.Contains(out.String(), "2 users") {
+		t.Errorf("Unexpected state output:\n%s", out.String())
+	}
+
+	out.Reset()
+	err = run(context.Background(), []string{"-url", url, "-token", "secret", "tail", "-types", "privmsg,join"}, nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "closed by the server") {
+		t.Errorf("Expected the stream to end with an error, got %v", err)
+	}
+	if got := <-requests; got != "types=privmsg%2Cjoin" {
+		t.Errorf("Unexpected query %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "#test <alice> hi") || !strings.HasSuffix(lines[1], "#test *** bob joined") {
+		t.Errorf("Unexpected tail output:\n%s", out.String())
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(path, []byte("# hannactl\nurl = https://bot.example.org\ntoken = \"from-file\"\n"), 0o600)
+	t.Setenv("HANNACTL_CONFIG", path)
+	t.Setenv("HANNA_API_URL", "")
+	t.Setenv("HANNA_API_TOKEN", "")
+	t.Setenv("API_TOKEN", "")
+
+	cfg, err := loadConfig("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.URL != "https://bot.example.org" || cfg.Token != "from-file" {
+		t.Errorf("Unexpected config from file: %+v", cfg)
+	}
+
+	t.Setenv("HANNA_API_TOKEN", "from-env")
+	if cfg, _ := loadConfig("", "", ""); cfg.Token != "from-env" {
+		t.Errorf("Environment should override the file, got %q", cfg.Token)
+	}
+	if cfg, _ := loadConfig("", "", "from-flag"); cfg.Token != "from-flag" {
+		t.Errorf("Flags should override the environment, got %q", cfg.Token)
+	}
+
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing"), "", ""); err == nil {
+		t.Error("A missing config file named explicitly should be an error")
+	}
+}
+
+func TestConsole(t *testing.T) {
+	requests := make(chan string, 10)
+	url := fakeAPI(t, requests)
+	empty := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(empty, nil, 0o600)
+	t.Setenv("HANNACTL_CONFIG", empty)
+
+	in := strings.NewReader("hello\n/join #ops\nhi ops\n/users #test\n/bogus\n/quit\n")
+	var out syncWriter
+	var buf bytes.Buffer
+	out.w = &buf
+	if err := run(context.Background(), []string{"-url", url, "-token", "secret", "console"}, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-requests; got != "JOIN #ops" {
+		t.Errorf("Expected the join first (no current channel for hello), got %q", got)
+	}
+	if got := <-requests; got != "#ops hi ops" {
+		t.Errorf("Unexpected send %q", got)
+	}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	for _, want := range []string{
+		"!!! no current channel",
+		"*** now talking in #ops",
+		"#ops <hanna> hi ops",
+		"*** #test (2): alice(+o) hanna",
+		"!!! unknown command /bogus",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Output lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Role is a privilege level granted to IRC users through ACCESS_CONFIG.
+// Roles are ordered: a user holding a role also satisfies every lower one.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleTrusted
+	RoleAdmin
+	RoleOwner
+)
+
+var roleNames = map[Role]string{
+	RoleNone:    "none",
+	RoleTrusted: "trusted",
+	RoleAdmin:   "admin",
+	RoleOwner:   "owner",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole converts a role name such as "admin" into a Role
+func ParseRole(s string) (Role, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return RoleNone, nil
+	}
+	for role, name := range roleNames {
+		if name == s {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", s)
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Role) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	role, err := ParseRole(s)
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// AccessEntry grants a role to users matching a services account and/or a
+// nick!user@host mask. When both are set, both must match.
+type AccessEntry struct {
+	Account string `json:"account,omitempty"`
+	Mask    string `json:"mask,omitempty"`
+	Role    Role   `json:"role"`
+}
+
+// AccessConfig is the ACCESS_CONFIG JSON document
+type AccessConfig struct {
+	Users     []AccessEntry   `json:"users"`
+	Commands  map[string]Role `json:"commands,omitempty"`   // command name -> required role
+	APIScopes map[string]Role `json:"api_scopes,omitempty"` // API scope -> required role
+}
+
+func (c *Client) loadAccessConfig() {
+	configStr := os.Getenv("ACCESS_CONFIG")
+	if configStr == "" {
+		return
+	}
+	log.Printf("Got access config")
+	if err := json.Unmarshal([]byte(configStr), &c.access); err != nil {
+		log.Fatalf("FATAL: Invalid ACCESS_CONFIG JSON: %v", err)
+	}
+}
+
+// RoleOf returns the highest role granted to a user identified by their
+// hostmask (nick!user@host) and services account. Either may be empty.
+func (c *Client) RoleOf(hostmask, account string) Role {
+	best := RoleNone
+	for _, entry := range c.access.Users {
+		if entry.Account == "" && entry.Mask == "" {
+			continue
+		}
+		if entry.Account != "" && (account == "" || !strings.EqualFold(entry.Account, account)) {
+			continue
+		}
+		if entry.Mask != "" && (hostmask == "" || !matchMask(entry.Mask, hostmask)) {
+			continue
+		}
+		if entry.Role > best {
+			best = entry.Role
+		}
+	}
+	return best
+}
+
+// commandRole returns the role required to run a command, preferring the
+// ACCESS_CONFIG override over the command's own default
+func (c *Client) commandRole(name string, def Role) Role {
+	if role, ok := c.access.Commands[strings.ToLower(name)]; ok {
+		return role
+	}
+	return def
+}
+
+// defaultScopeRoles are required for API scopes that ACCESS_CONFIG does not
+// mention
+var defaultScopeRoles = map[string]Role{"tokens": RoleAdmin, "debug": RoleAdmin}
+
+// apiScopeRole returns the role required for an API scope (RoleNone if unrestricted)
+func (c *Client) apiScopeRole(scope string) Role {
+	if role, ok := c.access.APIScopes[scope]; ok {
+		return role
+	}
+	return defaultScopeRoles[scope]
+}
+
+// senderAccount resolves the services account for a message sender, using the
+// account message tag when present and falling back to tracked WHOIS data
+func (c *Client) senderAccount(nick string, tags map[string]string) string {
+	if account := tags["account"]; account != "" && account != "*" {
+		return account
+	}
+	if info := c.getUserInfo(nick); info != nil {
+		return info.Account
+	}
+	return ""
+}
+
+// moveAccount carries a tracked account over to a new nick, or drops it when
+// newNick is empty, so whoever takes the old nick next does not inherit it
+func (c *Client) moveAccount(oldNick, newNick string) {
+	c.userInfoMu.Lock()
+	defer c.userInfoMu.Unlock()
+	info := c.userInfo[strings.ToLower(oldNick)]
+	if info == nil || info.Account == "" {
+		return
+	}
+	account := info.Account
+	info.Account = ""
+	if newNick == "" {
+		return
+	}
+	next := c.userInfo[strings.ToLower(newNick)]
+	if next == nil {
+		next = &UserInfo{Nick: strings.ToLower(newNick), SpecialInfo: make(map[string]string)}
+		c.userInfo[strings.ToLower(newNick)] = next
+	}
+	next.Account = account
+	next.updated = time.Now()
+}
+
+// matchMask reports whether s matches an IRC-style wildcard mask, where '*'
+// matches any run of characters and '?' matches exactly one. Matching is
+// case-insensitive.
+func matchMask(mask, s string) bool {
+	mask = strings.ToLower(mask)
+	s = strings.ToLower(s)
+
+	mi, si := 0, 0
+	star, match := -1, 0
+	for si < len(s) {
+		switch {
+		case mi < len(mask) && (mask[mi] == '?' || mask[mi] == s[si]):
+			mi++
+			si++
+		case mi < len(mask) && mask[mi] == '*':
+			star = mi
+			match = si
+			mi++
+		case star != -1:
+			mi = star + 1
+			match++
+			si = match
+		default:
+			return false
+		}
+	}
+	for mi < len(mask) && mask[mi] == '*' {
+		mi++
+	}
+	return mi == len(mask)
+}
+
+// scope restricts an API handler to callers acting on behalf of an IRC user
+// holding the role configured for the scope in ACCESS_CONFIG. Webhook-driven
+// workflows identify the user with the X-Hanna-Hostmask and X-Hanna-Account
+// headers; requests without either header are treated as coming from the
+// token holder and are not restricted.
+func (a *API) scope(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := a.bot.checkScope(name, r.Header); err != nil {
+			writeError(w, http.StatusForbidden, codeForbidden, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// checkScope reports whether the IRC user named in the request headers holds
+// the role required for scope
+func (c *Client) checkScope(name string, h http.Header) error {
+	required := c.apiScopeRole(name)
+	hostmask := h.Get("X-Hanna-Hostmask")
+	account := h.Get("X-Hanna-Account")
+	if required > RoleNone && (hostmask != "" || account != "") {
+		if role := c.RoleOf(hostmask, account); role < required {
+			return fmt.Errorf("scope %s requires role %s", name, required)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package irc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchMask(t *testing.T) {
+	tests := []struct {
+		mask, s string
+		want    bool
+	}{
+		{"*!*@example.com", "alice!alice@example.com", true},
+		{"*!*@*.example.com", "bob!b@host.example.com", true},
+		{"*!*@*.example.com", "bob!b@example.com", false},
+		{"Alice!*@*", "alice!x@y", true},
+		{"al?ce!*@*", "alice!x@y", true},
+		{"al?ce!*@*", "alce!x@y", false},
+		{"*", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := matchMask(tt.mask, tt.s); got != tt.want {
+			t.Errorf("matchMask(%q, %q) = %v, want %v", tt.mask, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRoleOf(t *testing.T) {
+	oldConfig := os.Getenv("ACCESS_CONFIG")
+	defer os.Setenv("ACCESS_CONFIG", oldConfig)
+
+	os.Setenv("ACCESS_CONFIG", `{
+		"users": [
+			{"account": "alice", "role": "owner"},
+			{"mask": "*!*@trusted.example", "role": "trusted"},
+			{"account": "bob", "mask": "bob!*@*", "role": "admin"}
+		]
+	}`)
+	client := NewClient()
+
+	if role := client.RoleOf("whatever!x@y", "alice"); role != RoleOwner {
+		t.Errorf("Expected owner for account alice, got %s", role)
+	}
+	if role := client.RoleOf("carol!c@trusted.example", ""); role != RoleTrusted {
+		t.Errorf("Expected trusted for matching mask, got %s", role)
+	}
+	if role := client.RoleOf("bob!b@host", ""); role != RoleNone {
+		t.Errorf("Expected none when account is missing for account+mask entry, got %s", role)
+	}
+	if role := client.RoleOf("bob!b@trusted.example", "bob"); role != RoleAdmin {
+		t.Errorf("Expected highest matching role admin, got %s", role)
+	}
+}
+
+func TestCommandPermissions(t *testing.T) {
+	oldConfig := os.Getenv("ACCESS_CONFIG")
+	defer os.Setenv("ACCESS_CONFIG", oldConfig)
+
+	os.Setenv("ACCESS_CONFIG", `{
+		"users": [{"mask": "admin!*@*", "role": "admin"}],
+		"commands": {"secret": "admin"}
+	}`)
+	client := NewClient()
+	client.setNick("TestBot")
+
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	ran := 0
+	client.RegisterCommand(Command{
+		Name:    "secret",
+		Usage:   "secret",
+		Handler: func(ctx *CommandContext) { ran++ },
+	})
+
+	client.handleLine(":guest!g@host PRIVMSG #test :!secret")
+	if ran != 0 {
+		t.Error("Command should not run for a user without the required role")
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "requires role admin") {
+		t.Errorf("Expected a denial reply, got %v", sent)
+	}
+
+	client.handleLine(":admin!a@host PRIVMSG #test :!secret")
+	if ran != 1 {
+		t.Error("Command should run for a user with the required role")
+	}
+
+	sent = nil
+	client.handleLine(":guest!g@host PRIVMSG TestBot :!whoami")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "PRIVMSG guest :") {
+		t.Errorf("Expected private reply to guest, got %v", sent)
+	}
+}
+
+func TestAPIScopePermissions(t *testing.T) {
+	oldConfig := os.Getenv("ACCESS_CONFIG")
+	defer os.Setenv("ACCESS_CONFIG", oldConfig)
+
+	os.Setenv("ACCESS_CONFIG", `{
+		"users": [{"account": "alice", "role": "admin"}],
+		"api_scopes": {"raw": "admin"}
+	}`)
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	handler := client.CreateAPI("token")
+
+	do := func(account string) int {
+		req := httptest.NewRequest("POST", "/api/raw", strings.NewReader(`{"line":"PING :x"}`))
+		req.Header.Set("Authorization", "Bearer token")
+		if account != "" {
+			req.Header.Set("X-Hanna-Account", account)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do("mallory"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for acting user without role, got %d", code)
+	}
+	if code := do("alice"); code != http.StatusOK {
+		t.Errorf("Expected 200 for acting user with role, got %d", code)
+	}
+	if code := do(""); code != http.StatusOK {
+		t.Errorf("Expected 200 for token holder without acting user, got %d", code)
+	}
+}
+
+func TestAccountTracking(t *testing.T) {
+	received := newTriggerRecorder(t, "join", "privmsg", "quit")
+	t.Setenv("CHANLOG_DIR", t.TempDir())
+	t.Setenv("CHANLOG_FORMATS", "jsonl")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	// extended-join carries the account and real name after the channel
+	client.handleLine(":alice!a@host JOIN #test alice_acct :Alice Liddell")
+	if p := expectTrigger(t, received); p.Target != "#test" || p.SenderAccount != "alice_acct" {
+		t.Errorf("Unexpected join payload %+v", p)
+	}
+	if info := client.getUserInfo("alice"); info == nil || info.RealName != "Alice Liddell" {
+		t.Errorf("Expected the real name from extended-join, got %+v", info)
+	}
+
+	client.handleLine(":alice!a@host ACCOUNT alice_new")
+	client.handleLine(":alice!a@host NICK :alice2")
+	client.handleLine(":alice2!a@host PRIVMSG #test :hi")
+	if p := expectTrigger(t, received); p.SenderAccount != "alice_new" {
+		t.Errorf("Expected the account to follow the nick change, got %q", p.SenderAccount)
+	}
+	if acct := client.senderAccount("alice", nil); acct != "" {
+		t.Errorf("The old nick should not keep the account, got %q", acct)
+	}
+
+	client.handleLine(":alice2!a@host QUIT :bye")
+	if p := expectTrigger(t, received); p.SenderAccount != "alice_new" {
+		t.Errorf("The quit event should still carry the account, got %q", p.SenderAccount)
+	}
+	client.handleLine(":alice2!b@elsewhere JOIN #test * :Someone Else")
+	if p := expectTrigger(t, received); p.SenderAccount != "" {
+		t.Errorf("A new user of the nick should not inherit the account, got %q", p.SenderAccount)
+	}
+
+	var accounts []string
+	client.chanlog.historyEntries("#test", time.Now().Add(-time.Minute), time.Now(), func(e LogEntry) error {
+		accounts = append(accounts, e.Account)
+		return nil
+	})
+	if strings.Join(accounts, ",") != "alice_acct,alice_new,alice_new,alice_new," {
+		t.Errorf("Unexpected accounts in history: %q", accounts)
+	}
+}
+
+func TestCapNegotiation(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.capPending.Store(2)
+	client.handleLine(":server CAP * ACK :message-tags account-tag server-time batch")
+	if len(sent) != 0 {
+		t.Errorf("CAP END should wait for every request, sent %q", sent)
+	}
+	client.handleLine(":server CAP * NAK :extended-join account-notify")
+	if len(sent) != 1 || sent[0] != "CAP END" {
+		t.Errorf("Expected CAP END once all requests were answered, sent %q", sent)
+	}
+	if !client.messageTags.Load() {
+		t.Error("message-tags should be enabled")
+	}
+}
@@ -0,0 +1,147 @@
+package irc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// activityTypes are the log entry types counted as activity unless a request
+// names its own
+var activityTypes = map[string]bool{"privmsg": true, "action": true, "notice": true}
+
+// maxActivityBuckets bounds the series of one channel, e.g. a year of days
+// or about 13 months of hours
+const maxActivityBuckets = 10000
+
+// ActivityBucket is the message count of one hour or day
+type ActivityBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// ChannelActivity is the logged activity of a channel over a time range
+type ChannelActivity struct {
+	Channel string           `json:"channel"`
+	Total   int              `json:"total"`
+	Users   int              `json:"users"`   // distinct nicks
+	Buckets []ActivityBucket `json:"buckets"` // every hour or day of the range, empty ones included
+	Weekly  [7][24]int       `json:"weekly"`  // counts by local weekday (0 is Sunday) and hour
+}
+
+// bucketStart truncates t to the start of its local hour or day
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.Local()
+	if bucket == "day" {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+}
+
+// activityBuckets returns the empty buckets covering from to to, or an error
+// when there would be more than maxActivityBuckets
+func activityBuckets(from, to time.Time, bucket string) ([]ActivityBucket, error) {
+	var buckets []ActivityBucket
+	for start := bucketStart(from, bucket); !start.After(to); {
+		if len(buckets) == maxActivityBuckets {
+			return nil, fmt.Errorf("more than %d buckets; use a shorter range or bucket=day", maxActivityBuckets)
+		}
+		buckets = append(buckets, ActivityBucket{Start: start})
+		if bucket == "day" {
+			start = start.AddDate(0, 0, 1)
+		} else {
+			start = start.Add(time.Hour)
+		}
+	}
+	return buckets, nil
+}
+
+// channelActivity counts the stored entries of a channel that match
+func (l *channelLogger) channelActivity(channel string, from, to time.Time, bucket string, buckets []ActivityBucket, match func(LogEntry) bool) (ChannelActivity, error) {
+	activity := ChannelActivity{Channel: channel, Buckets: append([]ActivityBucket(nil), buckets...)}
+	index := make(map[int64]int, len(buckets))
+	for i, b := range buckets {
+		index[b.Start.Unix()] = i
+	}
+	nicks := make(map[string]bool)
+	err := l.historyEntries(channel, from, to, func(e LogEntry) error {
+		if !match(e) {
+			return nil
+		}
+		if i, ok := index[bucketStart(e.Time, bucket).Unix()]; ok {
+			activity.Buckets[i].Count++
+		}
+		local := e.Time.Local()
+		activity.Weekly[local.Weekday()][local.Hour()]++
+		activity.Total++
+		nicks[strings.ToLower(e.Nick)] = true
+		return nil
+	})
+	activity.Users = len(nicks)
+	return activity, err
+}
+
+// handleActivity counts the logged messages of channels per hour or day, for
+// activity heatmaps:
+// GET /api/analytics/activity?channel=#a,#b&bucket=hour|day&from=...&to=...
+func (a *API) handleActivity(w http.ResponseWriter, r *http.Request) {
+	from, to, err := historyRange(r)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	bucket := strings.ToLower(q.Get("bucket"))
+	if bucket == "" {
+		bucket = "hour"
+	}
+	if bucket != "hour" && bucket != "day" {
+		writeError(w, 400, codeInvalidRequest, fmt.Sprintf("unknown bucket %q (use hour or day)", bucket))
+		return
+	}
+	var channels []string
+	for _, ch := range strings.Split(q.Get("channel"), ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			if !isChannelName(ch) {
+				writeError(w, 400, codeInvalidChannel, "invalid channel name: "+ch)
+				return
+			}
+			channels = append(channels, ch)
+		}
+	}
+	if len(channels) == 0 {
+		channels = a.bot.Channels()
+		sort.Strings(channels)
+	}
+	// Optional filters, as for /api/history/export: ?nick=alice&account=alice&type=privmsg
+	nick, account := q.Get("nick"), q.Get("account")
+	types := parseTypeFilter(q.Get("type"))
+	if len(types) == 0 {
+		types = activityTypes
+	}
+	if a.bot.chanlog == nil || !a.bot.chanlog.jsonl {
+		writeError(w, 503, codeUnavailable, errNoHistory.Error())
+		return
+	}
+	buckets, err := activityBuckets(from, to, bucket)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+
+	match := func(e LogEntry) bool {
+		return types[e.Type] && (nick == "" || strings.EqualFold(e.Nick, nick)) && (account == "" || strings.EqualFold(e.Account, account))
+	}
+	out := make([]ChannelActivity, 0, len(channels))
+	for _, ch := range channels {
+		activity, err := a.bot.chanlog.channelActivity(ch, from, to, bucket, buckets, match)
+		if err != nil {
+			writeError(w, 500, codeInternal, fmt.Sprintf("reading the history of %s: %v", ch, err))
+			return
+		}
+		out = append(out, activity)
+	}
+	writeJSON(w, 200, map[string]any{"from": from, "to": to, "bucket": bucket, "channels": out})
+}
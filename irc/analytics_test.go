@@ -0,0 +1,74 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestActivityAnalytics(t *testing.T) {
+	t.Setenv("CHANLOG_DIR", t.TempDir())
+	t.Setenv("CHANLOG_FORMATS", "jsonl")
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("token")
+
+	hour := bucketStart(time.Now(), "hour")
+	stamp := func(d time.Duration) string { return hour.Add(d).UTC().Format(time.RFC3339Nano) }
+	client.handleLine("@time=" + stamp(-2*time.Hour+time.Minute) + " :alice!a@host PRIVMSG #test :morning")
+	client.handleLine("@time=" + stamp(-2*time.Hour+2*time.Minute) + " :bob!b@host PRIVMSG #test :\x01ACTION yawns\x01")
+	client.handleLine("@time=" + stamp(time.Minute) + " :alice!a@host PRIVMSG #test :still here")
+	client.handleLine("@time=" + stamp(time.Minute) + " :alice!a@host PRIVMSG #other :hi")
+	client.handleLine("@time=" + stamp(2*time.Minute) + " :carol!c@host JOIN #test")
+
+	type response struct {
+		Bucket   string            `json:"bucket"`
+		Channels []ChannelActivity `json:"channels"`
+	}
+	rng := fmt.Sprintf("&from=%d&to=%d", hour.Add(-2*time.Hour).Unix(), hour.Add(59*time.Minute).Unix())
+	get := func(query string) (int, response) {
+		req := httptest.NewRequest("GET", "/api/analytics/activity?"+query+rng, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		var out response
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out
+	}
+
+	code, out := get("channel=%23test,%23other")
+	if code != 200 || out.Bucket != "hour" || len(out.Channels) != 2 {
+		t.Fatalf("Expected hourly activity of two channels, got %d %+v", code, out)
+	}
+	test := out.Channels[0]
+	counts := []int{}
+	for _, b := range test.Buckets {
+		counts = append(counts, b.Count)
+	}
+	// The join is not a message; the empty hour in between is kept
+	if fmt.Sprint(counts) != "[2 0 1]" || test.Total != 3 || test.Users != 2 || !test.Buckets[2].Start.Equal(hour) {
+		t.Errorf("Unexpected #test activity %v: %+v", counts, test)
+	}
+	if test.Weekly[hour.Weekday()][hour.Hour()] != 1 {
+		t.Errorf("Expected one message in the weekly cell of this hour, got %v", test.Weekly[hour.Weekday()])
+	}
+	if other := out.Channels[1]; other.Channel != "#other" || other.Total != 1 {
+		t.Errorf("Unexpected #other activity %+v", other)
+	}
+
+	_, out = get("channel=%23test&bucket=day&nick=alice&type=privmsg")
+	if total := out.Channels[0].Total; out.Bucket != "day" || total != 2 || len(out.Channels[0].Buckets) > 2 {
+		t.Errorf("Expected alice's 2 messages in daily buckets, got %+v", out)
+	}
+
+	if code, _ := get("channel=%23test&bucket=week"); code != 400 {
+		t.Errorf("Expected 400 for an unknown bucket, got %d", code)
+	}
+	if code, _ := get("channel=nochannel"); code != 400 {
+		t.Errorf("Expected 400 for an invalid channel, got %d", code)
+	}
+}
@@ -0,0 +1,891 @@
+package irc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateAPI creates a new API instance with the comprehensive endpoints.
+// token (API_TOKEN) is valid alongside API_TOKENS and API_TOKENS_FILE.
+func (c *Client) CreateAPI(token string) http.Handler {
+	c.tokens.setPrimary(token)
+	api := &API{bot: c}
+	return api.routes()
+}
+
+type API struct {
+	bot *Client
+	mux *http.ServeMux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	if ew, ok := w.(*encodedWriter); ok && ew.msgpack {
+		if data, err := marshalMsgpack(v); err == nil {
+			w.Header().Set("Content-Type", "application/msgpack")
+			w.WriteHeader(code)
+			w.Write(data)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (a *API) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.allowAddr(w, r) {
+			return
+		}
+		if !a.bot.tokens.configured() {
+			writeError(w, http.StatusForbidden, codeForbidden, "API_TOKEN not set on server")
+			return
+		}
+		if !a.bot.tokens.valid(bearerToken(r)) {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		// Read-only requests (dashboards polling state) do not end auto-away
+		if r.Method != http.MethodGet {
+			a.bot.touchActivity()
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (a *API) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if a.bot.Connected() {
+			writeJSON(w, 200, map[string]any{"ok": true, "nick": a.bot.Nick()})
+		} else {
+			writeJSON(w, 503, map[string]any{"ok": false})
+		}
+	})
+
+	// The dashboard page is public; its data comes from the API with the token
+	mux.Handle("/ui/", dashboardHandler())
+	mux.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+
+	// Kubernetes-style probes
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/readyz", a.handleReadyz)
+	mux.HandleFunc("/status", a.handleStatus)
+	mux.HandleFunc("/metrics", a.auth(a.handleMetrics))
+
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, map[string]any{"version": Version, "name": "Hanna IRC Bot"})
+	})
+
+	mux.HandleFunc("/api/state", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		// Read before the channels: changes in between are replayed by
+		// /api/state/changes, which consumers apply idempotently
+		revision := a.bot.StateRevision()
+		writeJSON(w, 200, map[string]any{
+			"revision":   revision,
+			"connected":  a.bot.Connected(),
+			"connection": a.bot.StateInfo(),
+			"nick":       a.bot.Nick(),
+			"user_modes": a.bot.UserModes(),
+			"away":       a.bot.Away(),
+			"channels":   a.bot.GetChannelStates(),
+			"lag":        a.bot.LagStats(),
+			"autojoin":   a.bot.AutojoinProgress(),
+		})
+	}))
+
+	mux.HandleFunc("/api/state/changes", a.auth(a.handleStateChanges))
+
+	mux.HandleFunc("/api/state/gc", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, 200, a.bot.CollectState(time.Now()))
+	}))
+
+	mux.HandleFunc("/api/server", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		serverInfo := a.bot.getServerInfo()
+		writeJSON(w, 200, serverInfo)
+	}))
+
+	mux.HandleFunc("/api/users", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		// Optional filters: ?nick=alice*&account=alice&channel=#dev
+		p, err := parsePage(r)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		q := r.URL.Query()
+		matched := a.bot.FindUsers(UserFilter{Nick: q.Get("nick"), Account: q.Get("account"), Channel: q.Get("channel")})
+		window := paginate(matched, p)
+		users := make(map[string]*UserInfo, len(window))
+		for _, info := range window {
+			users[strings.ToLower(info.Nick)] = info
+		}
+		out := p.fields(len(window), len(matched))
+		out["users"] = users
+		writeJSON(w, 200, out)
+	}))
+
+	mux.HandleFunc("/api/user", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Nick string `json:"nick"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Nick == "" {
+			writeError(w, 400, codeInvalidRequest, "nick required")
+			return
+		}
+
+		userInfo := a.bot.getUserInfo(in.Nick)
+		if userInfo == nil {
+			writeError(w, 404, codeNotFound, "user not found")
+			return
+		}
+
+		writeJSON(w, 200, userInfo)
+	}))
+
+	mux.HandleFunc("/api/stats", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		p, err := parsePage(r)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		stats := a.bot.getStats()
+		if kind := r.URL.Query().Get("type"); kind != "" {
+			matched := stats[:0]
+			for _, s := range stats {
+				if strings.EqualFold(s.Type, kind) {
+					matched = append(matched, s)
+				}
+			}
+			stats = matched
+		}
+		writePage(w, "stats", stats, p, nil)
+	}))
+
+	mux.HandleFunc("/api/triggers", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, map[string]any{"endpoints": a.bot.TriggerHealth()})
+	}))
+
+	mux.HandleFunc("/api/errors", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		p, err := parsePage(r)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		q := r.URL.Query()
+		errors := a.bot.getRecentErrors()
+		if code, target := q.Get("code"), q.Get("target"); code != "" || target != "" {
+			matched := errors[:0]
+			for _, e := range errors {
+				if (code == "" || e.Code == code) && (target == "" || strings.EqualFold(e.Target, target)) {
+					matched = append(matched, e)
+				}
+			}
+			errors = matched
+		}
+		writePage(w, "errors", errors, p, nil)
+	}))
+
+	mux.HandleFunc("/api/channel", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Channel string `json:"channel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Channel == "" {
+			writeError(w, 400, codeInvalidChannel, "channel required")
+			return
+		}
+
+		channelState := a.bot.GetChannelState(in.Channel)
+		if channelState == nil {
+			writeError(w, 404, codeNotFound, "channel not found")
+			return
+		}
+
+		writeJSON(w, 200, channelState)
+	}))
+
+	mux.HandleFunc("/api/resync", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+		channels := a.bot.ResyncChannels()
+		writeJSON(w, 200, map[string]any{
+			"status":   "ok",
+			"channels": channels,
+		})
+	}))
+
+	mux.HandleFunc("/api/server-notices", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		p, err := parsePage(r)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		notices := a.bot.ServerNotices()
+		if kind := r.URL.Query().Get("kind"); kind != "" {
+			matched := notices[:0]
+			for _, n := range notices {
+				if strings.EqualFold(n.Kind, kind) {
+					matched = append(matched, n)
+				}
+			}
+			notices = matched
+		}
+		writePage(w, "notices", notices, p, nil)
+	}))
+
+	mux.HandleFunc("/api/netsplits", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		p, err := parsePage(r)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		writePage(w, "split_users", a.bot.SplitUsers(), p, nil)
+	}))
+
+	mux.HandleFunc("/api/history/export", a.auth(a.handleHistoryExport))
+	mux.HandleFunc("/api/analytics/activity", a.auth(a.handleActivity))
+	mux.HandleFunc("/api/search", a.auth(a.handleSearch))
+	mux.HandleFunc("/api/seen", a.auth(a.handleSeen))
+	mux.HandleFunc("/api/events", a.auth(a.handleEvents))
+	mux.HandleFunc("/api/pm", a.auth(a.handleDMSessions))
+	mux.HandleFunc("/api/usage", a.auth(a.handleUsage))
+	mux.HandleFunc("/api/debug/loglevel", a.auth(a.scope("debug", a.handleLogLevel)))
+	mux.HandleFunc("/api/debug/raw", a.auth(a.scope("debug", a.handleRawTap)))
+	mux.HandleFunc("/api/debug/pprof/", a.auth(a.scope("debug", a.handlePprof)))
+	mux.HandleFunc("/api/debug/goroutines", a.auth(a.scope("debug", a.handleGoroutines)))
+	mux.HandleFunc("/api/debug/memory", a.auth(a.scope("debug", a.handleMemory)))
+	mux.HandleFunc("/api/pm/{nick}", a.auth(a.scope("pm", a.handleCloseDMSession)))
+
+	// Inbound webhooks authenticate with their own token
+	mux.HandleFunc("/api/webhook/{name}", a.handleWebhook)
+
+	// Token rotation: list, create and revoke API tokens, see tokens.go
+	mux.HandleFunc("/api/tokens", a.auth(a.scope("tokens", a.tokenAdmin(a.handleTokens))))
+	mux.HandleFunc("/api/tokens/{id}", a.auth(a.scope("tokens", a.tokenAdmin(a.handleRevokeToken))))
+
+	mux.HandleFunc("/api/comprehensive-state", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		// Return comprehensive IRC state information
+		writeJSON(w, 200, map[string]any{
+			"connected":     a.bot.Connected(),
+			"nick":          a.bot.Nick(),
+			"server":        a.bot.getServerInfo(),
+			"channels":      a.bot.GetChannelStates(),
+			"users":         a.bot.ListUsers(),
+			"stats":         a.bot.getStats(),
+			"recent_errors": a.bot.getRecentErrors(),
+			"timestamp":     time.Now().Unix(),
+		})
+	}))
+
+	mux.HandleFunc("/api/join", a.auth(a.scope("join", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Channel string `json:"channel"`
+			Force   bool   `json:"force"` // join despite JOIN_MAX_USERS and JOIN_DENY
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Channel == "" {
+			writeError(w, 400, codeInvalidChannel, "channel required")
+			return
+		}
+		if !validChannelList(in.Channel) {
+			writeError(w, 400, codeInvalidChannel, "invalid channel name: "+in.Channel)
+			return
+		}
+		if err := a.bot.checkChannelLength(in.Channel); err != nil {
+			writeError(w, 400, codeInvalidChannel, err.Error())
+			return
+		}
+		if err := a.bot.guardJoin(r.Context(), in.Channel, in.Force); err != nil {
+			var refused *joinRefusedError
+			if errors.As(err, &refused) {
+				writeError(w, 403, codeJoinRefused, err.Error())
+			} else {
+				writeRequestError(w, "LIST", err)
+			}
+			return
+		}
+		a.bot.Join(in.Channel)
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	})))
+
+	mux.HandleFunc("/api/part", a.auth(a.scope("part", func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Channel, Reason string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Channel == "" {
+			writeError(w, 400, codeInvalidChannel, "channel required")
+			return
+		}
+		if !validChannelList(in.Channel) {
+			writeError(w, 400, codeInvalidChannel, "invalid channel name: "+in.Channel)
+			return
+		}
+		if err := a.bot.checkChannelLength(in.Channel); err != nil {
+			writeError(w, 400, codeInvalidChannel, err.Error())
+			return
+		}
+		a.bot.Part(in.Channel, in.Reason)
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	})))
+
+	mux.HandleFunc("/api/send", a.auth(a.scope("send", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Target, Message, Format string
+			Tags                    map[string]string // client-only tags, +key
+			Notice                  *bool             // instead of REPLY_NOTICE and the channel's profile
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Target == "" || in.Message == "" {
+			writeError(w, 400, codeInvalidRequest, "target and message required")
+			return
+		}
+		message, err := applyMessageFormat(in.Format, in.Message)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		if err := checkClientTags(in.Tags); err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		a.sendToTarget(w, r, func(target string) string { return a.bot.replyKind(target, in.Notice) }, in.Target, func(target string) error {
+			return a.bot.SendReply(target, message, in.Tags, in.Notice)
+		})
+	})))
+
+	mux.HandleFunc("/api/notice", a.auth(a.scope("notice", func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Target, Message, Format string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Target == "" || in.Message == "" {
+			writeError(w, 400, codeInvalidRequest, "target and message required")
+			return
+		}
+		message, err := applyMessageFormat(in.Format, in.Message)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		a.sendToTarget(w, r, func(string) string { return "notice" }, in.Target, func(target string) error { return a.bot.Notice(target, message) })
+	})))
+
+	mux.HandleFunc("/api/action", a.auth(a.scope("send", func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Target, Message, Format string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Target == "" || strings.TrimSpace(in.Message) == "" {
+			writeError(w, 400, codeInvalidRequest, "target and message required")
+			return
+		}
+		message, err := applyMessageFormat(in.Format, in.Message)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		a.sendToTarget(w, r, func(string) string { return "action" }, in.Target, func(target string) error { return a.bot.Action(target, message) })
+	})))
+
+	mux.HandleFunc("/api/reply", a.auth(a.scope("send", a.handleReply)))
+	mux.HandleFunc("/api/broadcast", a.auth(a.scope("broadcast", a.handleBroadcast)))
+
+	mux.HandleFunc("/api/tagmsg", a.auth(a.scope("send", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Target  string `json:"target"`
+			Typing  string `json:"typing"`   // active, paused or done
+			React   string `json:"react"`    // emoji
+			ReplyTo string `json:"reply_to"` // msgid of the message reacted to
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Target == "" || (in.Typing == "" && in.React == "") {
+			writeError(w, 400, codeInvalidRequest, "target and typing or react required")
+			return
+		}
+		if in.Typing != "" && !validTypingState(in.Typing) {
+			writeError(w, 400, codeInvalidRequest, "typing must be active, paused or done")
+			return
+		}
+		if in.React != "" && in.ReplyTo == "" {
+			writeError(w, 400, codeInvalidRequest, "reply_to required with react")
+			return
+		}
+		tags := make(map[string]string)
+		if in.Typing != "" {
+			tags[typingTag] = in.Typing
+			if in.Typing != "active" {
+				a.bot.stopTyping(in.Target)
+			}
+		}
+		if in.React != "" {
+			tags[reactTag], tags[replyTag] = in.React, in.ReplyTo
+		}
+		sent, err := a.bot.trackSend("tagmsg", in.Target, requestID(r.Context()), func() error { return a.bot.Tagmsg(in.Target, tags) })
+		if err != nil {
+			writeSendError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]string{"status": sent.Status, "id": sent.ID})
+	})))
+
+	mux.HandleFunc("/api/raw", a.auth(a.scope("raw", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Line string `json:"line"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Line) == "" {
+			writeError(w, 400, codeInvalidRequest, "line required")
+			return
+		}
+		if err := a.bot.raw(in.Line); err != nil {
+			writeSendError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	})))
+
+	mux.HandleFunc("/api/message/{id}", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		m, ok := a.bot.SentMessage(r.PathValue("id"))
+		if !ok {
+			writeError(w, 404, codeNotFound, "message not found")
+			return
+		}
+		writeJSON(w, 200, m)
+	}))
+
+	mux.HandleFunc("/api/nick", a.auth(a.scope("nick", func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Nick string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Nick) == "" {
+			writeError(w, 400, codeInvalidRequest, "nick required")
+			return
+		}
+		if err := a.bot.SetNick(in.Nick); err != nil {
+			var limit *limitError
+			if errors.As(err, &limit) {
+				writeError(w, 400, codeInvalidRequest, err.Error())
+			} else {
+				writeSendError(w, err)
+			}
+			return
+		}
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	})))
+
+	mux.HandleFunc("/api/setname", a.auth(a.scope("setname", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Realname string `json:"realname"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Realname) == "" {
+			writeError(w, 400, codeInvalidRequest, "realname required")
+			return
+		}
+		err := a.bot.SetRealname(in.Realname)
+		switch {
+		case errors.Is(err, errNoSetname):
+			writeError(w, 409, codeConflict, err.Error())
+		case errors.Is(err, errNotConnected):
+			writeSendError(w, err)
+		case err != nil:
+			writeError(w, 400, codeInvalidRequest, err.Error())
+		default:
+			writeJSON(w, 200, map[string]string{"status": "ok"})
+		}
+	})))
+
+	mux.HandleFunc("/api/umode", a.auth(a.scope("umode", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, 200, map[string]string{"user_modes": a.bot.UserModes()})
+			return
+		}
+		var in struct {
+			Modes string `json:"modes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Modes) == "" {
+			writeError(w, 400, codeInvalidRequest, "modes required")
+			return
+		}
+		if err := a.bot.SetUserModes(in.Modes); err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	})))
+
+	mux.HandleFunc("/api/topic", a.auth(a.scope("topic", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			channel := r.URL.Query().Get("channel")
+			if channel == "" {
+				writeError(w, 400, codeInvalidChannel, "channel required")
+				return
+			}
+			topic, ok := a.bot.Topic(channel)
+			if !ok {
+				writeError(w, 404, codeNotFound, "channel not found")
+				return
+			}
+			writeJSON(w, 200, topic)
+		case http.MethodPost:
+			var in struct {
+				Channel string `json:"channel"`
+				Topic   string `json:"topic"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Channel == "" {
+				writeError(w, 400, codeInvalidChannel, "channel required")
+				return
+			}
+			if err := a.bot.SetTopic(in.Channel, in.Topic); err != nil {
+				writeError(w, 400, codeInvalidRequest, err.Error())
+				return
+			}
+			writeJSON(w, 200, map[string]string{"status": "ok"})
+		default:
+			writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		}
+	})))
+
+	mux.HandleFunc("/api/away", a.auth(a.scope("away", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, 200, a.bot.Away())
+		case http.MethodPost:
+			var in struct {
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Message) == "" {
+				writeError(w, 400, codeInvalidRequest, "message required")
+				return
+			}
+			if strings.ContainsAny(in.Message, "\r\n") {
+				writeError(w, 400, codeInvalidRequest, "message must not contain newlines")
+				return
+			}
+			if err := a.bot.SetAway(in.Message); err != nil {
+				writeError(w, 400, codeInvalidRequest, err.Error())
+				return
+			}
+			writeJSON(w, 200, map[string]string{"status": "ok"})
+		case http.MethodDelete:
+			a.bot.ClearAway()
+			writeJSON(w, 200, map[string]string{"status": "ok"})
+		default:
+			writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		}
+	})))
+
+	mux.HandleFunc("/api/schedule", a.auth(a.scope("schedule", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			schedules := a.bot.Schedules()
+			writeJSON(w, 200, map[string]any{"schedules": schedules, "count": len(schedules)})
+		case http.MethodPost:
+			var in struct {
+				Target  string `json:"target"`
+				Message string `json:"message"`
+				Delay   string `json:"delay"` // Go duration, e.g. "90s" or "2h30m"
+				Cron    string `json:"cron"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Target == "" || in.Message == "" {
+				writeError(w, 400, codeInvalidRequest, "target and message required")
+				return
+			}
+			var delay time.Duration
+			if in.Delay != "" {
+				d, err := time.ParseDuration(in.Delay)
+				if err != nil {
+					writeError(w, 400, codeInvalidRequest, fmt.Sprintf("invalid delay: %v", err))
+					return
+				}
+				delay = d
+			}
+			s, err := a.bot.AddSchedule(in.Target, in.Message, delay, in.Cron, r.Header.Get("X-Hanna-Account"))
+			if err != nil {
+				writeError(w, 400, codeInvalidRequest, err.Error())
+				return
+			}
+			writeJSON(w, 200, s)
+		case http.MethodDelete:
+			id := r.URL.Query().Get("id")
+			if id == "" {
+				writeError(w, 400, codeInvalidRequest, "id required")
+				return
+			}
+			if !a.bot.RemoveSchedule(id) {
+				writeError(w, 404, codeNotFound, "schedule not found")
+				return
+			}
+			writeJSON(w, 200, map[string]string{"status": "ok"})
+		default:
+			writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		}
+	})))
+
+	mux.HandleFunc("/api/list", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		// Optional filters: ?min_users=N&max_users=N&mask=*linux*&created_after=MINUTES
+		p, err := parsePage(r)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		var filter ListFilter
+		q := r.URL.Query()
+		for name, dst := range map[string]*int{"min_users": &filter.MinUsers, "max_users": &filter.MaxUsers, "created_after": &filter.CreatedAfter} {
+			if v := q.Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					writeError(w, 400, codeInvalidRequest, name+" must be a non-negative integer")
+					return
+				}
+				*dst = n
+			}
+		}
+		filter.Mask = q.Get("mask")
+
+		channels, cached, err := a.bot.ListChannels(r.Context(), filter, q.Get("refresh") == "true")
+		if err != nil {
+			writeRequestError(w, "list", err)
+			return
+		}
+
+		writePage(w, "channels", channels, p, map[string]any{"cached": cached})
+	}))
+
+	mux.HandleFunc("/api/names", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		var in struct {
+			Channel string `json:"channel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Channel) == "" {
+			writeError(w, 400, codeInvalidChannel, "channel required")
+			return
+		}
+
+		requestID := a.bot.Names(in.Channel)
+
+		// Wait for the result with a 10 second timeout
+		result, err := a.bot.GetRequestResultContext(r.Context(), requestID, 10*time.Second)
+		if err != nil {
+			writeRequestError(w, "names", err)
+			return
+		}
+
+		writeJSON(w, 200, map[string]interface{}{
+			"channel": in.Channel,
+			"users":   result.Data,
+			"count":   len(result.Data),
+		})
+	}))
+
+	mux.HandleFunc("/api/modelist", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Channel string `json:"channel"`
+			List    string `json:"list"`
+		}
+		switch r.Method {
+		case http.MethodGet:
+			in.Channel, in.List = r.URL.Query().Get("channel"), r.URL.Query().Get("list")
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				writeError(w, 400, codeInvalidRequest, "invalid JSON")
+				return
+			}
+		default:
+			writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if in.Channel == "" || in.List == "" {
+			writeError(w, 400, codeInvalidChannel, "channel and list required")
+			return
+		}
+
+		// POST asks the server for the current list, replacing the cached one
+		if r.Method == http.MethodPost {
+			if !a.bot.Connected() {
+				writeError(w, 503, codeNotConnected, "bot not connected")
+				return
+			}
+			requestID, err := a.bot.RefreshModeList(in.Channel, in.List)
+			if err != nil {
+				writeError(w, 400, codeInvalidRequest, err.Error())
+				return
+			}
+			if _, err := a.bot.GetRequestResultContext(r.Context(), requestID, 10*time.Second); err != nil {
+				writeRequestError(w, in.List+" list", err)
+				return
+			}
+		}
+		if _, ok := modeLists[in.List]; !ok {
+			writeError(w, 400, codeInvalidRequest, "list must be ban, invite or except")
+			return
+		}
+		entries, ok := a.bot.ModeList(in.Channel, in.List)
+		if !ok {
+			writeError(w, 404, codeNotFound, "channel not found")
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"channel": in.Channel,
+			"list":    in.List,
+			"entries": entries,
+		})
+	}))
+
+	mux.HandleFunc("/api/whowas", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		var in struct {
+			Nick  string `json:"nick"`
+			Count int    `json:"count"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Nick) == "" || strings.ContainsAny(in.Nick, " \r\n") {
+			writeError(w, 400, codeInvalidRequest, "nick required")
+			return
+		}
+
+		result, ok := a.awaitRequest(w, r, a.bot.Whowas(in.Nick, in.Count), "whowas")
+		if !ok {
+			return
+		}
+		records := whowasRecords(result.Data)
+		writeJSON(w, 200, map[string]any{
+			"nick":    in.Nick,
+			"records": records,
+			"count":   len(records),
+		})
+	}))
+
+	mux.HandleFunc("/api/stats/query", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		var in struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Query) == "" || strings.ContainsAny(in.Query, " \r\n") {
+			writeError(w, 400, codeInvalidRequest, "query required")
+			return
+		}
+
+		result, ok := a.awaitRequest(w, r, a.bot.Stats(in.Query), "stats")
+		if !ok {
+			return
+		}
+		writeJSON(w, 200, map[string]any{
+			"query":   in.Query,
+			"entries": result.Data,
+			"count":   len(result.Data),
+		})
+	}))
+
+	mux.HandleFunc("/api/admin", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		result, ok := a.awaitRequest(w, r, a.bot.Admin(r.URL.Query().Get("server")), "admin")
+		if !ok {
+			return
+		}
+		admin := map[string]any{"raw_data": result.Data}
+		for _, entry := range result.Data {
+			switch entry["type"] {
+			case "server":
+				admin["server"] = entry["server"]
+			default:
+				admin[entry["type"]] = entry["info"]
+			}
+		}
+		writeJSON(w, 200, admin)
+	}))
+
+	mux.HandleFunc("/api/motd", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		result, ok := a.awaitRequest(w, r, a.bot.Motd(r.URL.Query().Get("server")), "motd")
+		if !ok {
+			return
+		}
+		lines := make([]string, 0, len(result.Data))
+		for _, entry := range result.Data {
+			lines = append(lines, entry["line"])
+		}
+		writeJSON(w, 200, map[string]any{
+			"motd":  lines,
+			"count": len(lines),
+		})
+	}))
+
+	mux.HandleFunc("/api/whois", a.auth(func(w http.ResponseWriter, r *http.Request) {
+		if !a.bot.Connected() {
+			writeError(w, 503, codeNotConnected, "bot not connected")
+			return
+		}
+
+		var in struct {
+			Nick string `json:"nick"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Nick) == "" {
+			writeError(w, 400, codeInvalidRequest, "nick required")
+			return
+		}
+
+		requestID := a.bot.Whois(in.Nick)
+
+		// Wait for the result with a 10 second timeout
+		result, err := a.bot.GetRequestResultContext(r.Context(), requestID, 10*time.Second)
+		if err != nil {
+			writeRequestError(w, "whois", err)
+			return
+		}
+
+		// Parse the whois data into a structured format
+		whoisInfo := make(map[string]interface{})
+		whoisInfo["nick"] = in.Nick
+		whoisInfo["raw_data"] = result.Data
+
+		// Parse structured data
+		for _, entry := range result.Data {
+			switch entry["type"] {
+			case "user":
+				whoisInfo["user"] = entry["user"]
+				whoisInfo["host"] = entry["host"]
+				whoisInfo["real_name"] = entry["real_name"]
+			case "server":
+				whoisInfo["server"] = entry["server"]
+				whoisInfo["server_info"] = entry["server_info"]
+			case "operator":
+				whoisInfo["operator"] = true
+				whoisInfo["privileges"] = entry["privileges"]
+			case "idle":
+				whoisInfo["idle_seconds"] = entry["seconds"]
+				whoisInfo["idle_info"] = entry["info"]
+			case "channels":
+				whoisInfo["channels"] = entry["channels"]
+			}
+		}
+
+		writeJSON(w, 200, whoisInfo)
+	}))
+
+	a.operRoutes(mux)
+
+	a.mux = mux
+	return withRequestID(a.withEncoding(a.withRecover(mux)))
+}
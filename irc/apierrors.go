@@ -0,0 +1,68 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// API error codes. Every error response is {"error": message, "code": code}:
+// clients branch on the code, the message is meant for people and may change.
+const (
+	codeInvalidRequest   = "invalid_request"    // missing or malformed parameters
+	codeInvalidChannel   = "invalid_channel"    // missing or malformed channel name
+	codeUnauthorized     = "unauthorized"       // missing or wrong token or signature
+	codeForbidden        = "forbidden"          // the caller lacks the role for the scope
+	codeJoinRefused      = "join_refused"       // the join guard refused the channel
+	codeNotFound         = "not_found"          // unknown channel, user, ID or resource
+	codeMethodNotAllowed = "method_not_allowed" // unsupported HTTP method
+	codeConflict         = "conflict"           // the bot is not in a state to do this
+	codeRateLimited      = "rate_limited"       // too many requests, retry later
+	codeNotConnected     = "not_connected"      // the bot is not connected to IRC
+	codeUnavailable      = "unavailable"        // the feature is not configured
+	codeTimeout          = "timeout"            // the IRC server did not answer in time
+	codeRequestFailed    = "request_failed"     // the IRC server answered with an error
+	codeWriteFailed      = "write_failed"       // a line could not be written to the server
+	codeInternal         = "internal"           // anything else
+)
+
+// errorResponse is the envelope of every API error
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// writeError sends an error response with a code from the catalog above
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Error: message, Code: code})
+}
+
+// writeSendError reports a line that could not be sent: 503 without a
+// connection, 500 when the write failed
+func writeSendError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotConnected) {
+		writeError(w, 503, codeNotConnected, err.Error())
+		return
+	}
+	if errors.Is(err, errNoMessageTags) {
+		writeError(w, 409, codeConflict, err.Error())
+		return
+	}
+	writeError(w, 500, codeWriteFailed, "write failed: "+err.Error())
+}
+
+// writeRequestError reports a failed IRC request (NAMES, WHOIS, LIST...):
+// 504 when the server did not answer in time, 503 when the connection
+// ended, and 500 when the server answered with an error
+func writeRequestError(w http.ResponseWriter, what string, err error) {
+	message := fmt.Sprintf("%s request failed: %v", what, err)
+	switch {
+	case errors.Is(err, errRequestTimeout), errors.Is(err, context.DeadlineExceeded):
+		writeError(w, 504, codeTimeout, message)
+	case errors.Is(err, errConnectionClosed), errors.Is(err, errNotConnected):
+		writeError(w, 503, codeNotConnected, message)
+	default:
+		writeError(w, 500, codeRequestFailed, message)
+	}
+}
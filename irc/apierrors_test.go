@@ -0,0 +1,62 @@
+package irc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorCodes(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("token")
+
+	tests := []struct {
+		method, path, body, token string
+		status                    int
+		code                      string
+	}{
+		{"POST", "/api/join", `{"channel":"#ok,general"}`, "token", 400, codeInvalidChannel},
+		{"POST", "/api/join", `{}`, "token", 400, codeInvalidChannel},
+		{"POST", "/api/send", `{"target":"#test"}`, "token", 400, codeInvalidRequest},
+		{"GET", "/api/state", "", "wrong", 401, codeUnauthorized},
+		{"GET", "/api/message/msg_0", "", "token", 404, codeNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+			continue
+		}
+		var out errorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&out); err != nil || out.Code != tt.code || out.Error == "" {
+			t.Errorf("%s %s: expected code %s with a message, got %+v", tt.method, tt.path, tt.code, out)
+		}
+	}
+}
+
+func TestWriteRequestError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errRequestTimeout, 504, codeTimeout},
+		{errConnectionClosed, 503, codeNotConnected},
+		{errors.New("No such nick/channel"), 500, codeRequestFailed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeRequestError(rec, "whois", tt.err)
+		var out errorResponse
+		json.NewDecoder(rec.Body).Decode(&out)
+		if rec.Code != tt.status || out.Code != tt.code || !strings.HasPrefix(out.Error, "whois request failed: ") {
+			t.Errorf("%v: expected %d %s, got %d %+v", tt.err, tt.status, tt.code, rec.Code, out)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package irc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// loadAPIAllowlist reads API_ALLOWED_IPS, comma-separated addresses and
+// CIDR ranges allowed to use the authenticated API and gRPC, e.g.
+// 10.0.0.0/8,192.0.2.7. Empty allows any address with a valid token.
+func (c *Client) loadAPIAllowlist() {
+	for _, entry := range strings.Split(os.Getenv("API_ALLOWED_IPS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := parseAllowEntry(entry)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid API_ALLOWED_IPS entry %q: %v", entry, err)
+		}
+		c.apiAllowlist = append(c.apiAllowlist, prefix)
+	}
+	if len(c.apiAllowlist) > 0 {
+		log.Printf("API restricted to %d address ranges", len(c.apiAllowlist))
+	}
+}
+
+// parseAllowEntry parses a CIDR range, or a single address as a /32 or /128
+func parseAllowEntry(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// addrAllowed reports whether a request's remote address (host:port) is in
+// API_ALLOWED_IPS. Addresses are those of the direct peer; forwarded headers
+// are not trusted.
+func (c *Client) addrAllowed(remoteAddr string) bool {
+	if len(c.apiAllowlist) == 0 {
+		return true
+	}
+	ap, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := ap.Addr().Unmap()
+	for _, prefix := range c.apiAllowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIServerTLS returns the TLS settings of the API and gRPC servers, serving
+// certs (nil without API_TLS). With API_CLIENT_CA set, clients must present
+// a certificate signed by one of its CAs (mutual TLS). It returns nil when
+// there is nothing to configure.
+func APIServerTLS(certs *CertReloader) (*tls.Config, error) {
+	caFile := strings.TrimSpace(os.Getenv("API_CLIENT_CA"))
+	if caFile == "" && certs == nil {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certs != nil {
+		cfg.GetCertificate = certs.GetCertificate
+	}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read API_CLIENT_CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("API_CLIENT_CA %s has no PEM certificates", caFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// allowAddr rejects requests from addresses outside API_ALLOWED_IPS
+func (a *API) allowAddr(w http.ResponseWriter, r *http.Request) bool {
+	if a.bot.addrAllowed(r.RemoteAddr) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, codeForbidden, "client address not allowed")
+	return false
+}
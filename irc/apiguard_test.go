@@ -0,0 +1,120 @@
+package irc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPIAllowlist(t *testing.T) {
+	t.Setenv("API_ALLOWED_IPS", "10.0.0.0/8, 192.0.2.7, 2001:db8::/32")
+	client := NewClient()
+	api := client.CreateAPI("token")
+
+	for addr, want := range map[string]int{
+		"10.1.2.3:5000":          200,
+		"192.0.2.7:5000":         200,
+		"[::ffff:10.0.0.1]:5000": 200,
+		"[2001:db8::1]:5000":     200,
+		"192.0.2.8:5000":         403,
+		"[2001:db9::1]:5000":     403,
+	} {
+		req := httptest.NewRequest("GET", "/api/state", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", addr, want, rec.Code)
+		}
+	}
+
+	// Public probes are not restricted
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.RemoteAddr = "198.51.100.1:5000"
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code == 403 {
+		t.Errorf("Expected /healthz to ignore the allowlist")
+	}
+}
+
+// testCert issues a certificate, self-signed when parent is nil
+func testCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestAPIServerTLSClientCerts(t *testing.T) {
+	if cfg, err := APIServerTLS(nil); cfg != nil || err != nil {
+		t.Fatalf("Expected no TLS settings without API_CLIENT_CA, got %v %v", cfg, err)
+	}
+
+	ca, caKey, _ := testCert(t, "test CA", nil, nil)
+	_, _, clientCert := testCert(t, "client", ca, caKey)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600)
+	t.Setenv("API_CLIENT_CA", caFile)
+
+	cfg, err := APIServerTLS(nil)
+	if err != nil || cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("Expected client certificates to be required, got %v %v", cfg, err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport := srv.Client().Transport.(*http.Transport)
+	if _, err := srv.Client().Get(srv.URL); err == nil {
+		t.Errorf("Expected a request without a client certificate to fail")
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+	transport.CloseIdleConnections()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected a request with a client certificate to succeed: %v", err)
+	}
+	resp.Body.Close()
+
+	// A certificate from another CA is refused
+	other, otherKey, _ := testCert(t, "other CA", nil, nil)
+	_, _, stranger := testCert(t, "stranger", other, otherKey)
+	transport.TLSClientConfig.Certificates = []tls.Certificate{stranger}
+	transport.CloseIdleConnections()
+	if _, err := srv.Client().Get(srv.URL); err == nil {
+		t.Errorf("Expected a certificate from another CA to be refused")
+	}
+}
@@ -0,0 +1,247 @@
+package irc
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJoinBatchSize = 4                // channels per JOIN unless set or limited by TARGMAX
+	defaultJoinDelay     = time.Second      // pause between JOIN batches
+	autojoinMOTDWait     = 10 * time.Second // longest wait for ISUPPORT and the MOTD
+	maxJoinLineLength    = 400              // bytes of channel names in one JOIN
+)
+
+// autojoinTracker paces the AUTOJOIN channels after registration: batches
+// of channels per JOIN, within the server's TARGMAX and CHANLIMIT, with a
+// pause between batches so a long list does not trip flood protection
+type autojoinTracker struct {
+	channels  []string // AUTOJOIN entries; "#channel key" for keyed channels
+	batchSize int      // AUTOJOIN_BATCH_SIZE, 0 to follow TARGMAX
+	delay     time.Duration
+
+	mu       sync.Mutex
+	motd     chan struct{} // closed at the end of the MOTD of the current connection
+	progress AutojoinProgress
+}
+
+// AutojoinProgress reports the autojoin of the current connection in
+// /api/state
+type AutojoinProgress struct {
+	Total     int               `json:"total"`
+	Sent      int               `json:"sent"`              // channels a JOIN was sent for
+	Joined    int               `json:"joined"`            // channels the bot is in
+	Failed    map[string]string `json:"failed,omitempty"`  // channel -> server error
+	Skipped   []string          `json:"skipped,omitempty"` // over the server's CHANLIMIT
+	Done      bool              `json:"done"`              // every JOIN was sent
+	StartedAt time.Time         `json:"started_at"`
+}
+
+func (c *Client) loadAutojoin() {
+	for _, ch := range strings.Split(os.Getenv("AUTOJOIN"), ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			c.autojoin.channels = append(c.autojoin.channels, ch)
+		}
+	}
+	c.autojoin.batchSize = max(intenv("AUTOJOIN_BATCH_SIZE", 0), 0)
+	c.autojoin.delay = time.Duration(max(intenv("AUTOJOIN_DELAY_MS", int(defaultJoinDelay/time.Millisecond)), 0)) * time.Millisecond
+}
+
+// startAutojoin begins joining the AUTOJOIN channels once the server has
+// sent ISUPPORT, at the end of the MOTD
+func (c *Client) startAutojoin(done <-chan struct{}) {
+	if len(c.autojoin.channels) == 0 {
+		return
+	}
+	motd := make(chan struct{})
+	c.autojoin.mu.Lock()
+	c.autojoin.motd = motd
+	c.autojoin.progress = AutojoinProgress{Total: len(c.autojoin.channels), StartedAt: time.Now()}
+	c.autojoin.mu.Unlock()
+	log.Printf("Auto-joining %d channels", len(c.autojoin.channels))
+	c.supervise("autojoin", func() { c.autojoinLoop(done, motd) })
+}
+
+// endOfMOTD lets the autojoin of the current connection begin
+func (c *Client) endOfMOTD() {
+	c.autojoin.mu.Lock()
+	defer c.autojoin.mu.Unlock()
+	if c.autojoin.motd != nil {
+		close(c.autojoin.motd)
+		c.autojoin.motd = nil
+	}
+}
+
+func (c *Client) autojoinLoop(done <-chan struct{}, motd <-chan struct{}) {
+	select {
+	case <-motd:
+	case <-time.After(autojoinMOTDWait):
+	case <-done:
+		return
+	}
+	batches, skipped := c.joinBatches(c.autojoin.channels)
+	if len(skipped) > 0 {
+		log.Printf("Not auto-joining %d channels over the server's channel limit: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	c.autojoin.mu.Lock()
+	c.autojoin.progress.Skipped = skipped
+	c.autojoin.mu.Unlock()
+
+	for i, batch := range batches {
+		if i > 0 && c.autojoin.delay > 0 {
+			select {
+			case <-time.After(c.autojoin.delay):
+			case <-done:
+				return
+			}
+		}
+		c.Join(strings.Join(batch.channels, ",") + batch.keys)
+		c.autojoin.mu.Lock()
+		c.autojoin.progress.Sent += len(batch.channels)
+		c.autojoin.mu.Unlock()
+	}
+	c.autojoin.mu.Lock()
+	c.autojoin.progress.Done = true
+	c.autojoin.mu.Unlock()
+}
+
+type joinBatch struct {
+	channels []string
+	keys     string // " key" for a keyed channel, which is joined on its own
+}
+
+// joinBatches groups channels into JOIN commands of at most the batch size
+// and maxJoinLineLength, leaving out channels over the server's CHANLIMIT
+func (c *Client) joinBatches(entries []string) (batches []joinBatch, skipped []string) {
+	size := c.autojoin.batchSize
+	if limit, ok := c.targMax("JOIN"); ok && (size == 0 || size > limit) {
+		size = limit
+	}
+	if size == 0 {
+		size = defaultJoinBatchSize
+	}
+	limits := c.chanLimits()
+	used := make(map[string]int)
+
+	var current joinBatch
+	length := 0
+	flush := func() {
+		if len(current.channels) > 0 {
+			batches = append(batches, current)
+		}
+		current, length = joinBatch{}, 0
+	}
+	for _, entry := range entries {
+		channel, key, _ := strings.Cut(entry, " ")
+		if prefixes, limit := chanLimitFor(limits, channel); limit > 0 {
+			if used[prefixes] >= limit {
+				skipped = append(skipped, channel)
+				continue
+			}
+			used[prefixes]++
+		}
+		if key = strings.TrimSpace(key); key != "" {
+			flush()
+			batches = append(batches, joinBatch{channels: []string{channel}, keys: " " + key})
+			continue
+		}
+		if len(current.channels) >= size || length+len(channel)+1 > maxJoinLineLength {
+			flush()
+		}
+		current.channels = append(current.channels, channel)
+		length += len(channel) + 1
+	}
+	flush()
+	return batches, skipped
+}
+
+// targMax returns the ISUPPORT TARGMAX limit for a command, or false when
+// the server sets none
+func (c *Client) targMax(command string) (int, bool) {
+	for _, entry := range strings.Split(c.isupport("TARGMAX"), ",") {
+		name, value, _ := strings.Cut(entry, ":")
+		if strings.EqualFold(name, command) {
+			n, err := strconv.Atoi(value)
+			return n, err == nil && n > 0
+		}
+	}
+	return 0, false
+}
+
+// chanLimits returns the ISUPPORT CHANLIMIT limits by channel prefixes, e.g.
+// {"#&": 20}, falling back to the older MAXCHANNELS
+func (c *Client) chanLimits() map[string]int {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(c.isupport("CHANLIMIT"), ",") {
+		prefixes, value, ok := strings.Cut(entry, ":")
+		if n, err := strconv.Atoi(value); ok && err == nil && n > 0 {
+			limits[prefixes] = n
+		}
+	}
+	if n := c.isupportInt("MAXCHANNELS"); len(limits) == 0 && n > 0 {
+		limits["#&"] = n
+	}
+	return limits
+}
+
+func chanLimitFor(limits map[string]int, channel string) (string, int) {
+	if channel == "" {
+		return "", 0
+	}
+	for prefixes, n := range limits {
+		if strings.IndexByte(prefixes, channel[0]) >= 0 {
+			return prefixes, n
+		}
+	}
+	return "", 0
+}
+
+// autojoinError records a server refusal to join an AUTOJOIN channel
+func (c *Client) autojoinError(channel, reason string) {
+	c.autojoin.mu.Lock()
+	defer c.autojoin.mu.Unlock()
+	if c.autojoin.progress.Total == 0 {
+		return
+	}
+	for _, entry := range c.autojoin.channels {
+		name, _, _ := strings.Cut(entry, " ")
+		if strings.EqualFold(name, channel) {
+			if c.autojoin.progress.Failed == nil {
+				c.autojoin.progress.Failed = make(map[string]string)
+			}
+			c.autojoin.progress.Failed[name] = reason
+			return
+		}
+	}
+}
+
+// AutojoinProgress returns the autojoin progress of the current connection,
+// or nil when AUTOJOIN is not set
+func (c *Client) AutojoinProgress() *AutojoinProgress {
+	c.autojoin.mu.Lock()
+	p := c.autojoin.progress
+	p.Skipped = append([]string(nil), p.Skipped...)
+	if p.Failed != nil {
+		failed := make(map[string]string, len(p.Failed))
+		for k, v := range p.Failed {
+			failed[k] = v
+		}
+		p.Failed = failed
+	}
+	c.autojoin.mu.Unlock()
+	if p.Total == 0 {
+		return nil
+	}
+	joined := c.Channels()
+	for _, entry := range c.autojoin.channels {
+		name, _, _ := strings.Cut(entry, " ")
+		if containsFold(joined, name) {
+			p.Joined++
+		}
+	}
+	return &p
+}
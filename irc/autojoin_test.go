@@ -0,0 +1,74 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAutojoinPacing(t *testing.T) {
+	t.Setenv("AUTOJOIN", "#a,#b,#c,#d,#keyed secret,#e,#f")
+	t.Setenv("AUTOJOIN_BATCH_SIZE", "5")
+	t.Setenv("AUTOJOIN_DELAY_MS", "50")
+	client := NewClient()
+	client.setNick("TestBot")
+	var mu sync.Mutex
+	var joins []string
+	var times []time.Time
+	client.testRawCapture = func(line string) {
+		if strings.HasPrefix(line, "JOIN ") {
+			mu.Lock()
+			joins = append(joins, line)
+			times = append(times, time.Now())
+			mu.Unlock()
+		}
+	}
+
+	client.handleLine(":server 001 TestBot :Welcome")
+	client.handleLine(":server 005 TestBot TARGMAX=PRIVMSG:4,JOIN:3 CHANLIMIT=#:6 :are supported by this server")
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	if len(joins) != 0 {
+		t.Fatalf("Expected no JOIN before the end of the MOTD, got %q", joins)
+	}
+	mu.Unlock()
+	client.handleLine(":server 376 TestBot :End of /MOTD command.")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if p := client.AutojoinProgress(); p != nil && p.Done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// JOIN:3 caps the batch size and CHANLIMIT=#:6 leaves out #f
+	want := []string{"JOIN #a,#b,#c", "JOIN #d", "JOIN #keyed secret", "JOIN #e"}
+	if strings.Join(joins, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Expected joins %q, got %q", want, joins)
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Expected a pause between batches, got %v", gap)
+		}
+	}
+
+	client.handleLine(":TestBot!b@host JOIN #a")
+	client.handleLine(":server 474 TestBot #b :Cannot join channel (+b)")
+	p := client.AutojoinProgress()
+	if p.Total != 7 || p.Sent != 6 || p.Joined != 1 || !p.Done {
+		t.Errorf("Unexpected progress: %+v", p)
+	}
+	if p.Failed["#b"] != "Cannot join channel (+b)" || len(p.Skipped) != 1 || p.Skipped[0] != "#f" {
+		t.Errorf("Expected #b failed and #f skipped, got %+v", p)
+	}
+}
+
+func TestAutojoinProgressUnset(t *testing.T) {
+	client := NewClient()
+	if p := client.AutojoinProgress(); p != nil {
+		t.Errorf("Expected no progress without AUTOJOIN, got %+v", p)
+	}
+}
@@ -0,0 +1,122 @@
+package irc
+
+import (
+	"log"
+	"time"
+)
+
+// AwayState is the bot's own away status
+type AwayState struct {
+	Away    bool   `json:"away"`              // confirmed by RPL_NOWAWAY / RPL_UNAWAY
+	Message string `json:"message,omitempty"` // last requested away message
+	Auto    bool   `json:"auto"`              // set by auto-away rather than the API
+	Since   int64  `json:"since,omitempty"`
+}
+
+// Away returns the bot's current away state
+func (c *Client) Away() AwayState {
+	c.awayMu.RLock()
+	defer c.awayMu.RUnlock()
+	return c.away
+}
+
+// SetAway marks the bot as away with a message, which must fit the
+// server's AWAYLEN
+func (c *Client) SetAway(message string) error {
+	if err := c.checkLength("away message", "AWAYLEN", message); err != nil {
+		return err
+	}
+	c.setAway(message, false)
+	return nil
+}
+
+func (c *Client) setAway(message string, auto bool) {
+	c.awayMu.Lock()
+	c.away.Message = message
+	c.away.Auto = auto
+	c.awayMu.Unlock()
+	c.rawf("AWAY :%s", message)
+}
+
+// ClearAway marks the bot as back
+func (c *Client) ClearAway() {
+	c.awayMu.Lock()
+	c.away.Message = ""
+	c.away.Auto = false
+	c.awayMu.Unlock()
+	c.raw("AWAY")
+}
+
+// setAwayConfirmed records the server's RPL_NOWAWAY / RPL_UNAWAY
+func (c *Client) setAwayConfirmed(away bool) {
+	c.awayMu.Lock()
+	defer c.awayMu.Unlock()
+	if away && !c.away.Away {
+		c.away.Since = time.Now().Unix()
+	}
+	c.away.Away = away
+	if !away {
+		c.away.Since = 0
+	}
+}
+
+// restoreAway re-sends a manually set away message after reconnecting; the
+// server forgets it with the old connection
+func (c *Client) restoreAway() {
+	c.awayMu.Lock()
+	c.away.Away = false
+	c.away.Since = 0
+	if c.away.Auto {
+		c.away.Message = ""
+		c.away.Auto = false
+	}
+	message := c.away.Message
+	c.awayMu.Unlock()
+
+	if message != "" {
+		c.rawf("AWAY :%s", message)
+	}
+}
+
+// touchActivity records API or trigger activity and ends an auto-away
+func (c *Client) touchActivity() {
+	c.lastActivity.Store(time.Now().UnixNano())
+
+	c.awayMu.RLock()
+	auto := c.away.Auto
+	c.awayMu.RUnlock()
+	if auto {
+		log.Printf("Activity resumed, clearing auto-away")
+		c.ClearAway()
+	}
+}
+
+// checkAutoAway sets the bot away once no activity happened for autoAwayAfter
+func (c *Client) checkAutoAway(now time.Time) {
+	last := time.Unix(0, c.lastActivity.Load())
+	if now.Sub(last) < c.autoAwayAfter {
+		return
+	}
+	c.awayMu.RLock()
+	busy := c.away.Message != ""
+	c.awayMu.RUnlock()
+	if busy {
+		return
+	}
+	log.Printf("No activity for %s, setting auto-away", c.autoAwayAfter)
+	c.setAway(c.truncateTo("AWAYLEN", c.autoAwayMessage), true)
+}
+
+// autoAwayLoop checks for inactivity every minute until done is closed
+func (c *Client) autoAwayLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			c.checkAutoAway(now)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAwayAPI(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	api := client.CreateAPI("token")
+
+	req := httptest.NewRequest("POST", "/api/away", strings.NewReader(`{"message":"Back later"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 200 || len(sent) != 1 || sent[0] != "AWAY :Back later" {
+		t.Fatalf("Expected AWAY command, got %d %v", rec.Code, sent)
+	}
+	client.handleLine(":irc.server.com 306 TestBot :You have been marked as being away")
+
+	req = httptest.NewRequest("GET", "/api/state", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	var state struct {
+		Away AwayState `json:"away"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if !state.Away.Away || state.Away.Message != "Back later" || state.Away.Auto {
+		t.Errorf("Unexpected away state: %+v", state.Away)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/away", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	api.ServeHTTP(httptest.NewRecorder(), req)
+	client.handleLine(":irc.server.com 305 TestBot :You are no longer marked as being away")
+	if sent[len(sent)-1] != "AWAY" || client.Away().Away {
+		t.Errorf("Expected away to be cleared, sent %v state %+v", sent, client.Away())
+	}
+}
+
+func TestAutoAway(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	client.autoAwayAfter = 10 * time.Minute
+	client.autoAwayMessage = "Idle"
+
+	client.checkAutoAway(time.Now())
+	if len(sent) != 0 {
+		t.Fatalf("Should not go away while active, sent %v", sent)
+	}
+
+	client.checkAutoAway(time.Now().Add(11 * time.Minute))
+	if len(sent) != 1 || sent[0] != "AWAY :Idle" || !client.Away().Auto {
+		t.Fatalf("Expected auto-away, sent %v state %+v", sent, client.Away())
+	}
+
+	client.touchActivity()
+	if sent[len(sent)-1] != "AWAY" || client.Away().Auto {
+		t.Errorf("Activity should clear auto-away, sent %v", sent)
+	}
+
+	// A manual away message is never replaced by auto-away
+	client.SetAway("Maintenance")
+	sent = nil
+	client.checkAutoAway(time.Now().Add(time.Hour))
+	if len(sent) != 0 {
+		t.Errorf("Auto-away should not override a manual away, sent %v", sent)
+	}
+}
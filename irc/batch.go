@@ -0,0 +1,112 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ircBatch is an open IRCv3 BATCH. Trigger events for messages tagged with
+// its reference are counted instead of being sent individually.
+type ircBatch struct {
+	ref     string
+	kind    string
+	params  []string
+	started time.Time
+	counts  map[string]int // event type -> suppressed events
+}
+
+// handleBatch processes "BATCH +ref type params..." and "BATCH -ref"
+func (c *Client) handleBatch(args []string, tags map[string]string) {
+	if len(args) == 0 || len(args[0]) < 2 {
+		return
+	}
+	ref := args[0][1:]
+
+	switch args[0][0] {
+	case '+':
+		if len(args) < 2 {
+			return
+		}
+		b := &ircBatch{
+			ref:     ref,
+			kind:    strings.ToLower(args[1]),
+			params:  args[2:],
+			started: time.Now(),
+			counts:  make(map[string]int),
+		}
+		c.batchesMu.Lock()
+		c.batches[ref] = b
+		c.batchesMu.Unlock()
+
+		log.Printf("Batch %s (%s) started", ref, b.kind)
+		c.sendTriggerEvent("batch_start", "", b.target(), fmt.Sprintf("%s batch %s started", b.kind, ref), strings.Join(b.params, " "), tags)
+	case '-':
+		c.batchesMu.Lock()
+		b := c.batches[ref]
+		delete(c.batches, ref)
+		c.batchesMu.Unlock()
+		if b == nil {
+			return
+		}
+
+		total := 0
+		var summary []string
+		for eventType, n := range b.counts {
+			total += n
+			summary = append(summary, fmt.Sprintf("%s: %d", eventType, n))
+		}
+		sort.Strings(summary)
+		message := fmt.Sprintf("%s batch %s ended: %d event(s)", b.kind, ref, total)
+		log.Printf("%s in %s", message, time.Since(b.started).Round(time.Millisecond))
+		c.sendTriggerEvent("batch_end", "", b.target(), message, strings.Join(summary, ", "), tags)
+	}
+}
+
+// target returns the channel or nick a batch refers to, if any
+func (b *ircBatch) target() string {
+	if b.kind == "chathistory" && len(b.params) > 0 {
+		return b.params[0]
+	}
+	return ""
+}
+
+// batchKind returns the type of the open batch a message belongs to, or ""
+func (c *Client) batchKind(tags map[string]string) string {
+	ref, ok := tags["batch"]
+	if !ok {
+		return ""
+	}
+	c.batchesMu.Lock()
+	defer c.batchesMu.Unlock()
+	if b := c.batches[ref]; b != nil {
+		return b.kind
+	}
+	return ""
+}
+
+// absorbBatched counts an event belonging to an open batch. It reports true
+// when the event should not be sent on its own.
+func (c *Client) absorbBatched(eventType string, tags map[string]string) bool {
+	ref, ok := tags["batch"]
+	if !ok {
+		return false
+	}
+	c.batchesMu.Lock()
+	defer c.batchesMu.Unlock()
+	b := c.batches[ref]
+	if b == nil {
+		return false
+	}
+	b.counts[eventType]++
+	return true
+}
+
+// resetBatches forgets batches left open by a previous connection
+func (c *Client) resetBatches() {
+	c.batchesMu.Lock()
+	c.batches = make(map[string]*ircBatch)
+	c.batchesMu.Unlock()
+}
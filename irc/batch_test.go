@@ -0,0 +1,60 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChathistoryBatch(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg", "batch_start", "batch_end")
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":irc.server.com BATCH +h1 chathistory #test")
+	start := expectTrigger(t, received)
+	if start.EventType != "batch_start" || start.Target != "#test" {
+		t.Errorf("Unexpected batch_start payload: %+v", start)
+	}
+
+	client.handleLine("@batch=h1 :alice!a@host PRIVMSG #test :!help")
+	client.handleLine("@batch=h1 :bob!b@host PRIVMSG #test :hello")
+	expectNoTrigger(t, received)
+	if len(sent) != 0 {
+		t.Errorf("Replayed history should not run commands, sent %v", sent)
+	}
+
+	client.handleLine(":irc.server.com BATCH -h1")
+	end := expectTrigger(t, received)
+	if end.EventType != "batch_end" || !strings.Contains(end.Message, "2 event(s)") || end.ChatInput != "privmsg: 2" {
+		t.Errorf("Unexpected batch_end payload: %+v", end)
+	}
+
+	// Messages referencing a closed batch are delivered normally
+	client.handleLine("@batch=h1 :alice!a@host PRIVMSG #test :live")
+	if payload := expectTrigger(t, received); payload.EventType != "privmsg" {
+		t.Errorf("Expected privmsg, got %+v", payload)
+	}
+}
+
+func TestNetsplitBatch(t *testing.T) {
+	received := newTriggerRecorder(t, "join", "batch_end")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":irc.server.com BATCH +n1 netjoin hub.example.net leaf.example.net")
+	for _, nick := range []string{"alice", "bob", "carol"} {
+		client.handleLine("@batch=n1 :" + nick + "!u@host JOIN #test")
+	}
+	client.handleLine(":irc.server.com BATCH -n1")
+
+	payload := expectTrigger(t, received)
+	if payload.EventType != "batch_end" || payload.ChatInput != "join: 3" {
+		t.Errorf("Expected one summarized batch_end, got %+v", payload)
+	}
+	if !client.HasChannelUser("#test", "carol") {
+		t.Error("Batched JOINs should still update channel state")
+	}
+}
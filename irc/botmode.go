@@ -0,0 +1,93 @@
+package irc
+
+import (
+	"log"
+	"strings"
+)
+
+// botMessageTags are the tags servers add to messages from users in bot
+// mode: the IRCv3 bot tag, its draft name and soju's vendor tag
+var botMessageTags = []string{"bot", "draft/bot", "soju.im/bot"}
+
+// hasBotTag reports whether a message carries one of botMessageTags
+func hasBotTag(tags map[string]string) bool {
+	for _, tag := range botMessageTags {
+		if _, ok := tags[tag]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBotTag reads BOT_TAG, a client-only tag added to the bot's own
+// messages on servers with message-tags, e.g. +draft/bot
+func (c *Client) loadBotTag() {
+	c.botTag = strings.TrimSpace(getenv("BOT_TAG", ""))
+	if c.botTag != "" && !validClientTag(c.botTag) {
+		log.Fatalf("FATAL: BOT_TAG must be a client-only tag starting with +, got %q", c.botTag)
+	}
+}
+
+// withBotTag adds BOT_TAG to a "@key=value " tag prefix, or returns it as is
+func (c *Client) withBotTag(tags string) string {
+	if c.botTag == "" || !c.messageTags.Load() {
+		return tags
+	}
+	if tags == "" {
+		return "@" + c.botTag + " "
+	}
+	return "@" + c.botTag + ";" + strings.TrimPrefix(tags, "@")
+}
+
+// setBotMode sets the user mode the server advertises as BOT in ISUPPORT,
+// once per connection; servers without it have no bot mode
+func (c *Client) setBotMode() {
+	mode := c.getServerInfo().ISupportTags["BOT"]
+	if len(mode) != 1 || !c.botModeSent.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("Setting bot mode (+%s)", mode)
+	c.rawf("MODE %s +%s", c.Nick(), mode)
+}
+
+// markBot flags the sender of a message carrying a bot tag in UserInfo
+func (c *Client) markBot(prefix string, tags map[string]string) {
+	nick := strings.Split(prefix, "!")[0]
+	if nick == "" || !strings.Contains(prefix, "!") || !hasBotTag(tags) {
+		return
+	}
+	if info := c.getUserInfo(nick); info != nil && info.IsBot {
+		return
+	}
+	c.updateUserInfo(nick, func(info *UserInfo) { info.IsBot = true })
+}
+
+// senderIsBot reports whether the sender of an event is known to be a bot,
+// from the message's bot tag, WHOIS or the bots list of TRIGGER_CONFIG
+func (c *Client) senderIsBot(nick string, tags map[string]string) bool {
+	if nick == "" {
+		return false
+	}
+	if hasBotTag(tags) {
+		return true
+	}
+	if info := c.getUserInfo(nick); info != nil && info.IsBot {
+		return true
+	}
+	return c.isListedBot(nick, c.knownHostmask(nick))
+}
+
+// isListedBot reports whether nick or hostmask (nick!user@host, may be
+// empty) matches the bots list of TRIGGER_CONFIG
+func (c *Client) isListedBot(nick, hostmask string) bool {
+	for _, bot := range c.triggerConfig.Bots {
+		if strings.Contains(bot, "!") {
+			if hostmask != "" && matchMask(bot, hostmask) {
+				return true
+			}
+		} else if strings.EqualFold(bot, nick) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBotModeFromISupport(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+
+	client.handleLine(":server 005 TestBot NETWORK=Test :are supported by this server")
+	if len(lines) != 0 {
+		t.Errorf("Expected no bot mode without BOT in ISUPPORT, got %q", lines)
+	}
+	client.handleLine(":server 005 TestBot BOT=b CHANTYPES=# :are supported by this server")
+	client.handleLine(":server 005 TestBot BOT=b :are supported by this server")
+	if len(lines) != 1 || lines[0] != "MODE TestBot +b" {
+		t.Errorf("Expected MODE TestBot +b once, got %q", lines)
+	}
+}
+
+func TestBotTagMarksSender(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine("@bot :otherbot!b@host PRIVMSG #test :beep")
+	if p := expectTrigger(t, received); !p.SenderIsBot {
+		t.Error("Expected senderIsBot for a message with the bot tag")
+	}
+	if info := client.getUserInfo("otherbot"); info == nil || !info.IsBot {
+		t.Errorf("Expected otherbot to be marked as a bot, got %+v", info)
+	}
+	// Later messages without the tag still come from a known bot
+	client.handleLine(":otherbot!b@host PRIVMSG #test :boop")
+	if p := expectTrigger(t, received); !p.SenderIsBot {
+		t.Error("Expected senderIsBot for a known bot")
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :hi")
+	if p := expectTrigger(t, received); p.SenderIsBot {
+		t.Error("Expected alice not to be a bot")
+	}
+}
+
+func TestBotTagOnOwnMessages(t *testing.T) {
+	t.Setenv("BOT_TAG", "+draft/bot")
+	client := NewClient()
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+
+	client.Privmsg("#test", "no tags yet")
+	client.messageTags.Store(true)
+	client.Privmsg("#test", "hello")
+	client.PrivmsgTags("#test", "reply", map[string]string{"+draft/reply": "abc"})
+	client.Notice("#test", "notice")
+	want := []string{
+		"PRIVMSG #test :no tags yet",
+		"@+draft/bot PRIVMSG #test :hello",
+		"@+draft/bot;+draft/reply=abc PRIVMSG #test :reply",
+		"@+draft/bot NOTICE #test :notice",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %q, got %q", want, lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("Expected %q, got %q", want[i], lines[i])
+		}
+	}
+}
+
+func TestIgnoreBots(t *testing.T) {
+	ignoring := make(chan TriggerPayload, 10)
+	all := make(chan TriggerPayload, 10)
+	srv := func(received chan TriggerPayload) string {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload TriggerPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+				received <- payload
+			}
+		}))
+		t.Cleanup(s.Close)
+		return s.URL
+	}
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{
+		"ignore_bots": true,
+		"bots": ["relaybot", "*!*@bots.example.org"],
+		"endpoints": {
+			"llm": {"url": %q, "events": ["privmsg"]},
+			"log": {"url": %q, "events": ["privmsg"], "ignore_bots": false}
+		}
+	}`, srv(ignoring), srv(all)))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	for _, line := range []string{
+		"@bot :tagged!b@host PRIVMSG #test :beep",      // bot message tag
+		":relaybot!r@host PRIVMSG #test :relayed",      // listed nick
+		":helper!h@bots.example.org PRIVMSG #test :hi", // listed mask
+	} {
+		client.handleLine(line)
+		if p := expectTrigger(t, all); !p.SenderIsBot {
+			t.Errorf("Expected %q to come from a bot", line)
+		}
+	}
+	client.handleLine(":whoisbot!w@host PRIVMSG #test :x")
+	expectTrigger(t, all)
+	client.handleLine(":server 335 TestBot whoisbot :is a Bot")
+	client.handleLine(":whoisbot!w@host PRIVMSG #test :y")
+	expectTrigger(t, all)
+	expectTrigger(t, ignoring) // the first message, before WHOIS told
+	expectNoTrigger(t, ignoring)
+
+	client.handleLine(":alice!a@host PRIVMSG #test :hello")
+	if p := expectTrigger(t, ignoring); p.Sender != "alice" {
+		t.Errorf("Expected alice's message, got %+v", p)
+	}
+}
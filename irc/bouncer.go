@@ -0,0 +1,389 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bouncerServerName is the prefix of replies generated by the bouncer itself
+const bouncerServerName = "hanna"
+
+// upstreamRelayed lists the upstream commands passed on to attached clients.
+// Numerics are relayed too except for the registration burst, which every
+// client gets from the bouncer instead.
+var upstreamRelayed = map[string]bool{
+	"PRIVMSG": true, "NOTICE": true, "JOIN": true, "PART": true, "QUIT": true,
+	"KICK": true, "NICK": true, "MODE": true, "TOPIC": true, "INVITE": true,
+}
+
+var registrationNumerics = map[string]bool{
+	"001": true, "002": true, "003": true, "004": true, "005": true,
+	"375": true, "372": true, "376": true, "422": true,
+	"900": true, "901": true, "903": true, "904": true, "905": true, "906": true, "907": true, "908": true,
+}
+
+// downstreamQueue bounds the lines waiting for an attached client; a client
+// that falls this far behind is dropped rather than stall the bot
+const downstreamQueue = 256
+
+// bouncer lets regular IRC clients attach to the bot's connection. They
+// share the bot's nick and channels; what they send goes out as the bot.
+type bouncer struct {
+	addr     string
+	password string
+
+	mu      sync.Mutex
+	ln      net.Listener
+	clients map[*downstream]struct{}
+}
+
+// downstream is one attached IRC client
+type downstream struct {
+	conn net.Conn
+	addr string
+	wmu  sync.Mutex
+	w    *bufio.Writer
+
+	queue     chan string   // broadcast lines, written by writeLoop
+	closed    chan struct{} // closed on detach
+	closeOnce sync.Once
+}
+
+func (c *Client) loadBouncer() {
+	addr := strings.TrimSpace(os.Getenv("BOUNCER_ADDR"))
+	if addr == "" {
+		return
+	}
+	password := os.Getenv("BOUNCER_PASSWORD")
+	if password == "" {
+		log.Fatalf("FATAL: BOUNCER_PASSWORD is required when BOUNCER_ADDR is set")
+	}
+	c.bouncer = &bouncer{addr: addr, password: password, clients: make(map[*downstream]struct{})}
+}
+
+// ServeBouncer accepts downstream IRC clients until Close is called. It
+// returns immediately when BOUNCER_ADDR is not set.
+func (c *Client) ServeBouncer() error {
+	b := c.bouncer
+	if b == nil {
+		return nil
+	}
+	ln, err := net.Listen("tcp", b.addr)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.ln = ln
+	b.mu.Unlock()
+	// Close ends the lifetime context, which stops the listener and drops clients
+	stop := context.AfterFunc(c.context(), b.close)
+	defer stop()
+
+	log.Printf("Bouncer listening on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if c.context().Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go c.safely("bouncer client", func() { c.serveDownstream(conn) })
+	}
+}
+
+func (b *bouncer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ln != nil {
+		b.ln.Close()
+	}
+	for d := range b.clients {
+		d.conn.Close()
+	}
+}
+
+func (b *bouncer) attach(d *downstream) {
+	b.mu.Lock()
+	b.clients[d] = struct{}{}
+	b.mu.Unlock()
+}
+
+func (b *bouncer) detach(d *downstream) {
+	b.mu.Lock()
+	delete(b.clients, d)
+	b.mu.Unlock()
+	d.closeOnce.Do(func() { close(d.closed) })
+	d.conn.Close()
+}
+
+// broadcast queues a line for every attached client except skip. It never
+// blocks the IRC connection: a client whose queue is full is dropped.
+func (b *bouncer) broadcast(line string, skip *downstream) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	clients := make([]*downstream, 0, len(b.clients))
+	for d := range b.clients {
+		if d != skip {
+			clients = append(clients, d)
+		}
+	}
+	b.mu.Unlock()
+	for _, d := range clients {
+		select {
+		case d.queue <- line:
+		default:
+			log.Printf("Bouncer: dropping client %s: %d lines behind", d.addr, downstreamQueue)
+			b.detach(d)
+		}
+	}
+}
+
+// writeLoop writes the queued lines of a client until it is detached
+func (b *bouncer) writeLoop(d *downstream) {
+	for {
+		select {
+		case line := <-d.queue:
+			if err := d.send(line); err != nil {
+				log.Printf("Bouncer: dropping client %s: %v", d.addr, err)
+				b.detach(d)
+				return
+			}
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+// attached returns the number of attached downstream clients
+func (b *bouncer) attached() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+func (d *downstream) send(line string) error {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	d.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := d.w.WriteString(line + "\r\n"); err != nil {
+		return err
+	}
+	return d.w.Flush()
+}
+
+func (d *downstream) sendf(format string, args ...any) error {
+	return d.send(fmt.Sprintf(format, args...))
+}
+
+// relayUpstream passes a line received from the server on to attached
+// clients, without message tags since none are negotiated downstream
+func (c *Client) relayUpstream(line, cmd string) {
+	if c.bouncer.attached() == 0 {
+		return
+	}
+	numeric := len(cmd) == 3 && strings.Trim(cmd, "0123456789") == ""
+	if !upstreamRelayed[cmd] && (!numeric || registrationNumerics[cmd]) {
+		return
+	}
+	if strings.HasPrefix(line, "@") {
+		if _, rest, ok := strings.Cut(line, " "); ok {
+			line = rest
+		}
+	}
+	c.bouncer.broadcast(line, nil)
+}
+
+// relayOutgoing shows the bot's own messages to attached clients other than
+// the one that sent them, since the server does not echo them back. It is
+// called once the line was written.
+func (c *Client) relayOutgoing(line string, from *downstream) {
+	if c.bouncer.attached() == 0 {
+		return
+	}
+	cmd, _, _ := strings.Cut(line, " ")
+	if cmd = strings.ToUpper(cmd); cmd != "PRIVMSG" && cmd != "NOTICE" {
+		return
+	}
+	c.bouncer.broadcast(":"+c.Nick()+" "+line, from)
+}
+
+// serveDownstream registers a client and then forwards its commands to the
+// server until it disconnects
+func (c *Client) serveDownstream(conn net.Conn) {
+	d := &downstream{
+		conn: conn, addr: conn.RemoteAddr().String(), w: bufio.NewWriter(conn),
+		queue: make(chan string, downstreamQueue), closed: make(chan struct{}),
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), 16*1024)
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+	var pass, nick string
+	var user, capNegotiating bool
+	for !user || nick == "" || capNegotiating {
+		if !scanner.Scan() {
+			return
+		}
+		cmd, args, _ := parseDownstream(scanner.Text())
+		switch cmd {
+		case "CAP":
+			if len(args) > 0 {
+				switch strings.ToUpper(args[0]) {
+				case "LS", "LIST":
+					capNegotiating = true
+					d.sendf(":%s CAP * %s :", bouncerServerName, strings.ToUpper(args[0]))
+				case "REQ":
+					capNegotiating = true
+					d.sendf(":%s CAP * NAK :%s", bouncerServerName, strings.Join(args[1:], " "))
+				case "END":
+					capNegotiating = false
+				}
+			}
+		case "PASS":
+			if len(args) > 0 {
+				pass = args[0]
+			}
+		case "NICK":
+			if len(args) > 0 {
+				nick = args[0]
+			}
+		case "USER":
+			user = true
+		case "PING":
+			d.sendf(":%s PONG %s :%s", bouncerServerName, bouncerServerName, strings.Join(args, " "))
+		case "QUIT":
+			return
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(pass), []byte(c.bouncer.password)) != 1 {
+		log.Printf("Bouncer: rejected client %s: bad password", d.addr)
+		d.sendf(":%s 464 %s :Password incorrect", bouncerServerName, nick)
+		d.send("ERROR :Closing link: password incorrect")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	c.bouncer.attach(d)
+	defer c.bouncer.detach(d)
+	log.Printf("Bouncer: client %s attached as %s", d.addr, nick)
+	c.sendBurst(d, nick)
+	// Lines broadcast during the burst wait in the queue until it is sent
+	go c.safely("bouncer writer", func() { c.bouncer.writeLoop(d) })
+
+	for scanner.Scan() {
+		cmd, args, line := parseDownstream(scanner.Text())
+		switch cmd {
+		case "":
+		case "PING":
+			d.sendf(":%s PONG %s :%s", bouncerServerName, bouncerServerName, strings.Join(args, " "))
+		case "PONG", "CAP", "PASS", "USER":
+			// Connection-level commands stay between the client and the bouncer
+		case "QUIT":
+			log.Printf("Bouncer: client %s detached", d.addr)
+			return
+		default:
+			if c.State() != StateConnected {
+				d.sendf(":%s NOTICE %s :Not connected to the server, command dropped", bouncerServerName, c.Nick())
+				continue
+			}
+			c.rawFrom(line, d)
+		}
+	}
+}
+
+// sendBurst greets a newly attached client as the bot and replays the
+// channels it is in
+func (c *Client) sendBurst(d *downstream, clientNick string) {
+	nick := c.Nick()
+	info := c.getServerInfo()
+	d.sendf(":%s 001 %s :Welcome, attached to Hanna as %s", bouncerServerName, nick, nick)
+	d.sendf(":%s 002 %s :Your host is %s, running Hanna %s", bouncerServerName, nick, bouncerServerName, Version)
+	d.sendf(":%s 003 %s :Upstream server %s", bouncerServerName, nick, info.Name)
+	d.sendf(":%s 004 %s %s Hanna-%s %s %s", bouncerServerName, nick, bouncerServerName, Version, info.UserModes, info.ChannelModes)
+	if len(info.ISupportTags) > 0 {
+		keys := make([]string, 0, len(info.ISupportTags))
+		for k, v := range info.ISupportTags {
+			if v != "" {
+				k += "=" + v
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for len(keys) > 0 {
+			n := min(len(keys), 12)
+			d.sendf(":%s 005 %s %s :are supported by this server", bouncerServerName, nick, strings.Join(keys[:n], " "))
+			keys = keys[n:]
+		}
+	}
+	d.sendf(":%s 422 %s :MOTD File is missing", bouncerServerName, nick)
+	if !strings.EqualFold(clientNick, nick) {
+		d.sendf(":%s NICK %s", clientNick, nick)
+	}
+
+	c.channelStatesMu.RLock()
+	states := make([]ChannelState, 0, len(c.channelStates))
+	for _, state := range c.channelStates {
+		s := *state
+		s.Users = make(map[string]string, len(state.Users))
+		for n, m := range state.Users {
+			s.Users[n] = m
+		}
+		states = append(states, s)
+	}
+	c.channelStatesMu.RUnlock()
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+
+	prefixes := c.prefixMap()
+	for _, state := range states {
+		d.sendf(":%s JOIN %s", nick, state.Name)
+		if state.Topic != "" {
+			d.sendf(":%s 332 %s %s :%s", bouncerServerName, nick, state.Name, state.Topic)
+		}
+		names := make([]string, 0, len(state.Users))
+		for n, modes := range state.Users {
+			names = append(names, prefixes.highest(modes)+n)
+		}
+		sort.Strings(names)
+		for len(names) > 0 {
+			n := min(len(names), 20)
+			d.sendf(":%s 353 %s = %s :%s", bouncerServerName, nick, state.Name, strings.Join(names[:n], " "))
+			names = names[n:]
+		}
+		d.sendf(":%s 366 %s %s :End of /NAMES list", bouncerServerName, nick, state.Name)
+	}
+}
+
+// parseDownstream returns the upper-cased command of a client line, its
+// parameters (the trailing one included) and the line without tags or prefix
+func parseDownstream(line string) (string, []string, string) {
+	line = strings.TrimSpace(line)
+	for strings.HasPrefix(line, "@") || strings.HasPrefix(line, ":") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	params, trailing, hasTrailing := strings.Cut(line, " :")
+	args := strings.Fields(params)
+	if len(args) == 0 {
+		return "", nil, ""
+	}
+	if hasTrailing {
+		args = append(args, trailing)
+	}
+	return strings.ToUpper(args[0]), args[1:], line
+}
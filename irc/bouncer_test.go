@@ -0,0 +1,160 @@
+package irc
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startBouncer serves the bouncer of a new client and returns the client and
+// the listening address
+func startBouncer(t *testing.T) (*Client, string) {
+	t.Helper()
+	t.Setenv("BOUNCER_ADDR", "127.0.0.1:0")
+	t.Setenv("BOUNCER_PASSWORD", "hunter2")
+	client := NewClient()
+	client.setNick("TestBot")
+	markConnected(client)
+	go client.ServeBouncer()
+	t.Cleanup(func() { client.Close() })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		client.bouncer.mu.Lock()
+		ln := client.bouncer.ln
+		client.bouncer.mu.Unlock()
+		if ln != nil {
+			return client, ln.Addr().String()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Bouncer did not start listening")
+	return nil, ""
+}
+
+// attachClient registers a downstream client and returns a function reading
+// lines until one contains want
+func attachClient(t *testing.T, addr, pass string) (net.Conn, func(want string) string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.Write([]byte("CAP LS 302\r\nPASS " + pass + "\r\nNICK me\r\nUSER me 0 * :Me\r\nCAP END\r\n"))
+	r := bufio.NewReader(conn)
+	return conn, func(want string) string {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Did not receive %q: %v", want, err)
+			}
+			if strings.Contains(line, want) {
+				return strings.TrimRight(line, "\r\n")
+			}
+		}
+	}
+}
+
+func TestBouncerAttach(t *testing.T) {
+	client, addr := startBouncer(t)
+	client.testRawCapture = func(string) {}
+	client.handleLine(":server 005 TestBot PREFIX=(ov)@+ CHANTYPES=# :are supported by this server")
+	client.handleLine(":TestBot!bot@host JOIN #test")
+	client.handleLine(":server 353 TestBot = #test :TestBot @alice")
+	client.handleLine(":server 366 TestBot #test :End of /NAMES list")
+
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+	conn, expect := attachClient(t, addr, "hunter2")
+	expect(" 001 TestBot ")
+	expect("PREFIX=(ov)@+")
+	expect(":me NICK TestBot")
+	expect(":TestBot JOIN #test")
+	if names := expect(" 353 "); !strings.HasSuffix(names, ":@alice TestBot") {
+		t.Errorf("Unexpected NAMES reply %q", names)
+	}
+	expect(" 366 ")
+
+	// Server traffic reaches the client without tags
+	client.handleLine("@time=2024-01-01T00:00:00Z :alice!a@host PRIVMSG #test :hi there")
+	if line := expect("PRIVMSG #test"); line != ":alice!a@host PRIVMSG #test :hi there" {
+		t.Errorf("Unexpected relayed line %q", line)
+	}
+
+	// Client commands go out as the bot; PING and QUIT stay local
+	conn.Write([]byte("PING :abc\r\nPRIVMSG #test :hello from downstream\r\n"))
+	expect("PONG hanna :abc")
+	select {
+	case line := <-sent:
+		if line != "PRIVMSG #test :hello from downstream" {
+			t.Errorf("Unexpected upstream line %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Message was not sent upstream")
+	}
+}
+
+func TestBouncerEchoesBotMessages(t *testing.T) {
+	client, addr := startBouncer(t)
+	client.testRawCapture = func(string) {}
+	_, expect := attachClient(t, addr, "hunter2")
+	expect(" 001 ")
+
+	client.Privmsg("#test", "sent through the API")
+	if line := expect("PRIVMSG"); line != ":TestBot PRIVMSG #test :sent through the API" {
+		t.Errorf("Unexpected echo %q", line)
+	}
+}
+
+func TestBouncerSkipsUnsentLines(t *testing.T) {
+	client, addr := startBouncer(t)
+	_, expect := attachClient(t, addr, "hunter2")
+	expect(" 001 ")
+
+	// Not connected upstream: the line is never written, so never echoed
+	if err := client.Privmsg("#test", "never sent"); err == nil {
+		t.Fatal("Expected the message to fail without a connection")
+	}
+	client.handleLine(":alice!a@host PRIVMSG #test :after")
+	if line := expect("PRIVMSG"); !strings.HasSuffix(line, ":after") {
+		t.Errorf("Expected only the server's line, got %q", line)
+	}
+}
+
+func TestBouncerDropsSlowClient(t *testing.T) {
+	client, addr := startBouncer(t)
+	client.testRawCapture = func(string) {}
+	_, expect := attachClient(t, addr, "hunter2")
+	expect(" 001 ")
+
+	// The client stops reading; relaying must not wait for it
+	line := ":alice!a@host PRIVMSG #test :" + strings.Repeat("x", 400)
+	start := time.Now()
+	for range 50000 {
+		client.bouncer.broadcast(line, nil)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Broadcasting to a stalled client took %v", elapsed)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for client.bouncer.attached() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := client.bouncer.attached(); n != 0 {
+		t.Errorf("Expected the stalled client to be dropped, %d still attached", n)
+	}
+}
+
+func TestBouncerRejectsBadPassword(t *testing.T) {
+	client, addr := startBouncer(t)
+	_, expect := attachClient(t, addr, "wrong")
+	expect(" 464 ")
+	if n := client.bouncer.attached(); n != 0 {
+		t.Errorf("Expected no attached clients, got %d", n)
+	}
+}
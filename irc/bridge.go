@@ -0,0 +1,194 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ircRelay is the part of the client a chat bridge uses: a context that ends
+// on Close, and relayToIRC to post what it receives
+type ircRelay interface {
+	context() context.Context
+	relayToIRC(bridge, channel, author, text string, loop LoopProtection)
+}
+
+// bridgeEvents are the IRC events a bridge can relay to another network.
+// Only privmsg is relayed unless a bridge lists its events; actions (/me)
+// are relayed with privmsg.
+var bridgeEvents = map[string]bool{
+	"privmsg": true, "notice": true, "join": true, "part": true,
+	"quit": true, "kick": true, "nick": true, "topic": true,
+}
+
+// bridgeMessage is an IRC event rendered for another chat network. Nick is
+// the author of a message; status lines such as joins have no author.
+type bridgeMessage struct {
+	Channel string
+	Nick    string
+	Text    string // message text with IRC formatting, or the status line
+	Action  bool   // /me message
+	Dest    string // destination on the other network, for bridges with several per channel
+}
+
+// validateBridgeEvents checks a bridge's event list and fills in the default
+func validateBridgeEvents(bridge string, events []string) []string {
+	if len(events) == 0 {
+		return []string{"privmsg"}
+	}
+	for i, e := range events {
+		events[i] = strings.ToLower(e)
+		if !bridgeEvents[events[i]] {
+			log.Fatalf("FATAL: Invalid event %q for %s bridge", e, bridge)
+		}
+	}
+	return events
+}
+
+// bridgeMessages renders an event for each bridged channel it concerns.
+// Events of the bot itself, replayed history, spam and messages that came
+// from a relay are never relayed.
+func bridgeMessages(e Event, events []string, loop LoopProtection, bridged func(channel string) bool) []bridgeMessage {
+	typ := e.Type
+	if typ == "action" {
+		typ = "privmsg"
+	}
+	if e.Self || e.Replayed || e.Dropped || !slices.Contains(events, typ) || loop.looped(e) {
+		return nil
+	}
+	var channels []string
+	switch e.Type {
+	case "quit", "nick":
+		channels = e.Channels
+	default:
+		if isChannelName(e.Target) {
+			channels = []string{e.Target}
+		}
+	}
+
+	var out []bridgeMessage
+	for _, ch := range channels {
+		if !bridged(ch) {
+			continue
+		}
+		m := bridgeMessage{Channel: ch}
+		switch e.Type {
+		case "action":
+			m.Nick, m.Text, m.Action = e.Sender, e.Text, true
+		case "privmsg", "notice":
+			m.Nick, m.Text = e.Sender, e.Text
+			if action, ok := ctcpAction(e.Text); ok {
+				m.Text, m.Action = action, true
+			} else if strings.HasPrefix(e.Text, "\x01") {
+				continue // other CTCP requests
+			}
+		case "join":
+			m.Text = fmt.Sprintf("%s joined %s", e.Sender, ch)
+		case "part":
+			m.Text = fmt.Sprintf("%s left %s", e.Sender, ch)
+			if e.Text != "" {
+				m.Text += " (" + e.Text + ")"
+			}
+		case "quit":
+			m.Text = fmt.Sprintf("%s quit", e.Sender)
+			if e.Text != "" {
+				m.Text += " (" + e.Text + ")"
+			}
+		case "kick":
+			m.Text = fmt.Sprintf("%s was kicked by %s", e.Nick, e.Sender)
+			if e.Text != "" {
+				m.Text += " (" + e.Text + ")"
+			}
+		case "nick":
+			m.Text = fmt.Sprintf("%s is now known as %s", e.Sender, e.Nick)
+		case "topic":
+			m.Text = fmt.Sprintf("%s changed the topic to: %s", e.Sender, e.Text)
+		}
+		m.Text = loop.mark(m.Text)
+		out = append(out, m)
+	}
+	return out
+}
+
+// bridgeQueue posts messages to another network in order, on its own
+// goroutine so a slow API never stalls the read loop
+type bridgeQueue struct {
+	name string
+	ch   chan bridgeMessage
+	once sync.Once
+	post func(ctx context.Context, m bridgeMessage) error
+}
+
+func newBridgeQueue(name string, post func(ctx context.Context, m bridgeMessage) error) *bridgeQueue {
+	return &bridgeQueue{name: name, ch: make(chan bridgeMessage, 100), post: post}
+}
+
+// push queues a message, starting the sender on first use; a full queue
+// drops the message
+func (q *bridgeQueue) push(ctx context.Context, m bridgeMessage) {
+	q.once.Do(func() { go q.loop(ctx) })
+	select {
+	case q.ch <- m:
+	default:
+		log.Printf("%s bridge: queue full, dropping message for %s", q.name, m.Channel)
+	}
+}
+
+func (q *bridgeQueue) loop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-q.ch:
+			if err := q.post(ctx, m); err != nil {
+				log.Printf("%s bridge: posting from %s failed: %v", q.name, m.Channel, err)
+			}
+		}
+	}
+}
+
+// relayToIRC posts a message from another network to an IRC channel,
+// attributed to its author and marked as relayed. Lines are dropped while
+// disconnected, and so is text that an IRC relay carried back to the network.
+// A bare CR ends a line for some servers, so it splits lines like LF.
+func (c *Client) relayToIRC(bridge, channel, author, text string, loop LoopProtection) {
+	if !c.Connected() {
+		log.Printf("%s bridge: not connected, dropping message for %s", bridge, channel)
+		return
+	}
+	if hasLoopMarker(text) {
+		log.Printf("%s bridge: dropping looped message for %s", bridge, channel)
+		return
+	}
+	tags, marker := loop.ircPrefix(c, bridge)
+	var lines []string
+	split := func(r rune) bool { return r == '\r' || r == '\n' }
+	for _, line := range strings.FieldsFunc(text, split) {
+		if line = strings.TrimRight(line, " "); line != "" {
+			lines = append(lines, fmt.Sprintf("%s<%s> %s", marker, author, line))
+		}
+	}
+	if len(lines) > 0 {
+		c.privmsg(tags, channel, strings.Join(lines, "\n"))
+	}
+}
+
+// StartBridges connects the configured chat bridges and event outputs. They
+// run until Close.
+func (c *Client) StartBridges() {
+	if c.discord != nil {
+		c.supervise("discord", func() { c.discord.run(c.context()) })
+	}
+	if c.telegram != nil {
+		c.supervise("telegram", func() { c.telegram.run(c.context()) })
+	}
+	if c.xmpp != nil {
+		c.supervise("xmpp", func() { c.xmpp.run(c.context()) })
+	}
+	if c.nats != nil {
+		c.supervise("nats", func() { c.nats.run(c.context()) })
+	}
+}
@@ -0,0 +1,126 @@
+package irc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// BroadcastData is what a broadcast template sees for each channel
+type BroadcastData struct {
+	Channel string
+	Users   int // users in the channel
+	Topic   string
+	Nick    string            // the bot's nick
+	Vars    map[string]string // vars from the request, the same for every channel
+}
+
+// BroadcastMessage is the outcome of a broadcast in one channel
+type BroadcastMessage struct {
+	Target string `json:"target"`
+	Text   string `json:"text"`
+	ID     string `json:"id,omitempty"` // message status ID, when sent
+	Status string `json:"status"`       // preview, skipped (empty text), sent or failed
+	Error  string `json:"error,omitempty"`
+}
+
+// renderBroadcast executes a message template for each channel. Channels
+// whose message renders empty are skipped, so a template can leave some out.
+func (c *Client) renderBroadcast(text string, channels []string, vars map[string]string) ([]BroadcastMessage, error) {
+	tmpl, err := template.New("broadcast").Funcs(triggerTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BroadcastMessage, 0, len(channels))
+	for _, channel := range channels {
+		data := BroadcastData{Channel: channel, Nick: c.Nick(), Vars: vars}
+		if ctx := c.channelContext(channel, "", nil); ctx != nil {
+			data.Users, data.Topic = ctx.UserCount, ctx.Topic
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", channel, err)
+		}
+		m := BroadcastMessage{Target: channel, Text: strings.TrimSpace(buf.String()), Status: "preview"}
+		if m.Text == "" {
+			m.Status = "skipped"
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// handleBroadcast sends a templated message to every joined channel or to
+// a channel group: POST /api/broadcast
+func (a *API) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Message string            `json:"message"`
+		Group   string            `json:"group"` // group name, default all
+		Format  string            `json:"format"`
+		Vars    map[string]string `json:"vars"`
+		DryRun  bool              `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Message) == "" {
+		writeError(w, 400, codeInvalidRequest, "message required")
+		return
+	}
+	if in.Group == "" {
+		in.Group = "all"
+	}
+	channels, _, err := a.bot.expandTarget(groupPrefix + strings.TrimPrefix(in.Group, groupPrefix))
+	if err != nil {
+		writeError(w, 404, codeNotFound, err.Error())
+		return
+	}
+	messages, err := a.bot.renderBroadcast(in.Message, channels, in.Vars)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, "invalid template: "+err.Error())
+		return
+	}
+	for i := range messages {
+		if messages[i].Status == "skipped" {
+			continue
+		}
+		text, err := applyMessageFormat(in.Format, messages[i].Text)
+		if err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		messages[i].Text = text
+	}
+	if in.DryRun {
+		writeJSON(w, 200, map[string]any{"status": "preview", "messages": messages})
+		return
+	}
+
+	sent, failed := 0, 0
+	var firstErr error
+	for i := range messages {
+		m := &messages[i]
+		if m.Status == "skipped" {
+			continue
+		}
+		status, err := a.bot.trackSend("privmsg", m.Target, requestID(r.Context()), func() error { return a.bot.Privmsg(m.Target, m.Text) })
+		m.ID, m.Status, m.Error = status.ID, status.Status, status.Error
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sent++
+	}
+	if sent == 0 && firstErr != nil {
+		writeSendError(w, firstErr)
+		return
+	}
+	status := "sent"
+	if failed > 0 {
+		status = "partial"
+	}
+	writeJSON(w, 200, map[string]any{"status": status, "messages": messages})
+}
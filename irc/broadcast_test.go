@@ -0,0 +1,73 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBroadcast(t *testing.T) {
+	t.Setenv("CHANNEL_GROUPS", `{"ops": ["#ops"]}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	client.handleLine(":TestBot!b@host JOIN #ops")
+	client.handleLine(":server 353 TestBot = #ops :TestBot @alice bob")
+	client.handleLine(":TestBot!b@host JOIN #general")
+	client.handleLine(":TestBot!b@host JOIN #quiet")
+	api := client.CreateAPI("token")
+	post := func(body string) (*httptest.ResponseRecorder, []BroadcastMessage) {
+		req := httptest.NewRequest("POST", "/api/broadcast", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		var out struct{ Messages []BroadcastMessage }
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec, out.Messages
+	}
+	message := `{{if ne .Channel "#quiet"}}Hello {{.Channel}} ({{.Users}} users), {{.Vars.what}}{{end}}`
+
+	lines = nil
+	rec, messages := post(`{"message":` + jsonString(message) + `,"vars":{"what":"restart at 10"},"dry_run":true}`)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"status":"preview"`) {
+		t.Fatalf("Expected a preview, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(lines) != 0 {
+		t.Errorf("Expected nothing sent in a dry run, got %q", lines)
+	}
+	want := []BroadcastMessage{
+		{Target: "#general", Text: "Hello #general (1 users), restart at 10", Status: "preview"},
+		{Target: "#ops", Text: "Hello #ops (3 users), restart at 10", Status: "preview"},
+		{Target: "#quiet", Status: "skipped"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, messages)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], messages[i])
+		}
+	}
+
+	rec, messages = post(`{"message":"Ops: {{.Channel}}","group":"ops"}`)
+	if rec.Code != 200 || len(messages) != 1 || messages[0].Status != "sent" || messages[0].ID == "" {
+		t.Errorf("Unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(lines) != 1 || lines[0] != "PRIVMSG #ops :Ops: #ops" {
+		t.Errorf("Expected one message to #ops, got %q", lines)
+	}
+
+	if rec, _ := post(`{"message":"{{.Nope"}`); rec.Code != 400 {
+		t.Errorf("Expected 400 for a broken template, got %d", rec.Code)
+	}
+	if rec, _ := post(`{"message":"x","group":"missing"}`); rec.Code != 404 {
+		t.Errorf("Expected 404 for an unknown group, got %d", rec.Code)
+	}
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,117 @@
+package irc
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a parsed IRC event published on the client's bus by handleLine
+type Event struct {
+	ID       string            `json:"id"`   // correlation ID, forwarded to triggers as correlationId
+	Type     string            `json:"type"` // privmsg, action, pm, notice, join, part, quit, kick, mode, topic, nick, mention, tagmsg, typing or reaction
+	Time     time.Time         `json:"time"`
+	Prefix   string            `json:"prefix,omitempty"`
+	Sender   string            `json:"sender"`
+	Target   string            `json:"target,omitempty"`   // channel or nick the event happened in
+	Nick     string            `json:"nick,omitempty"`     // kicked nick or new nick
+	Args     []string          `json:"args,omitempty"`     // IRC parameters before the trailing one
+	Text     string            `json:"text,omitempty"`     // trailing text: message, reason or topic
+	Message  string            `json:"message,omitempty"`  // human-readable summary sent to triggers
+	Channels []string          `json:"channels,omitempty"` // channels shared with the sender before a quit or nick change
+	Tags     map[string]string `json:"tags,omitempty"`
+
+	Self     bool   `json:"self,omitempty"`     // the bot itself is the subject
+	Netsplit string `json:"netsplit,omitempty"` // split servers for quits caused by a netsplit
+	Rejoin   bool   `json:"rejoin,omitempty"`   // join of a user returning from a netsplit
+	Replayed bool   `json:"replayed,omitempty"` // chathistory playback
+	Dropped  bool   `json:"dropped,omitempty"`  // caught by spam protection
+	Session  string `json:"session,omitempty"`  // private conversation of a message to the bot, see dm.go
+}
+
+// EventBus delivers events to subscribers. Handlers run synchronously on the
+// publishing goroutine, in subscription order, so a subscriber sees the state
+// left by the ones registered before it.
+type EventBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []subscription
+}
+
+type subscription struct {
+	id      int
+	name    string
+	handler func(Event)
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers a handler and returns a function that removes it
+func (b *EventBus) Subscribe(name string, handler func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, subscription{id: id, name: name, handler: handler})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subs {
+			if s.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SubscribeChan delivers events to a buffered channel for consumers on other
+// goroutines. Events are dropped rather than blocking the parser when the
+// buffer is full.
+func (b *EventBus) SubscribeChan(name string, buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	var once sync.Once
+	var mu sync.Mutex
+	closed := false
+	unsubscribe := b.Subscribe(name, func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+	return ch, func() {
+		once.Do(func() {
+			unsubscribe()
+			mu.Lock()
+			closed = true
+			close(ch)
+			mu.Unlock()
+		})
+	}
+}
+
+// Publish delivers an event to every subscriber. Publishing on a nil bus is a
+// no-op, so bare test clients need no setup.
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = messageTime(e.Tags)
+	}
+	if e.ID == "" {
+		e.ID = newRequestID()
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.handler(e)
+	}
+}
@@ -0,0 +1,116 @@
+package irc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+	var order []string
+	bus.Subscribe("first", func(e Event) { order = append(order, "first:"+e.Type) })
+	unsubscribe := bus.Subscribe("second", func(e Event) { order = append(order, "second:"+e.Type) })
+
+	bus.Publish(Event{Type: "join"})
+	unsubscribe()
+	bus.Publish(Event{Type: "part"})
+
+	want := []string{"first:join", "second:join", "first:part"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected %v, got %v", want, order)
+	}
+
+	var nilBus *EventBus
+	nilBus.Publish(Event{Type: "join"}) // must not panic
+}
+
+func TestEventBusChanDropsWhenFull(t *testing.T) {
+	bus := newEventBus()
+	events, cancel := bus.SubscribeChan("test", 1)
+	bus.Publish(Event{Type: "join"})
+	bus.Publish(Event{Type: "part"}) // buffer full, dropped
+	if e := <-events; e.Type != "join" {
+		t.Errorf("Expected join, got %s", e.Type)
+	}
+	cancel()
+	bus.Publish(Event{Type: "quit"}) // after cancel, must not panic
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+}
+
+func TestCustomSubscriber(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	var got []Event
+	client.bus.Subscribe("test", func(e Event) { got = append(got, e) })
+	client.handleLine(":alice!a@host JOIN #test")
+	client.handleLine(":alice!a@host PRIVMSG #test :hello TestBot")
+	client.handleLine(":alice!a@host QUIT :bye")
+
+	var types []string
+	for _, e := range got {
+		types = append(types, e.Type)
+	}
+	if want := []string{"join", "privmsg", "mention", "quit"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("Expected %v, got %v", want, types)
+	}
+	if quit := got[3]; !reflect.DeepEqual(quit.Channels, []string{"#test"}) || quit.Text != "bye" {
+		t.Errorf("Unexpected quit event: %+v", quit)
+	}
+	if client.HasChannelUser("#test", "alice") {
+		t.Error("State tracker should have removed alice")
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	srv := httptest.NewServer(client.CreateAPI("token"))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/events?types=privmsg&channel=%23test", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+
+	lines := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				lines <- line
+			}
+		}
+	}()
+
+	client.handleLine(":alice!a@host JOIN #test")
+	client.handleLine(":alice!a@host PRIVMSG #other :elsewhere")
+	client.handleLine(":alice!a@host PRIVMSG #test :hello")
+	select {
+	case line := <-lines:
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != "privmsg" || e.Target != "#test" || e.Text != "hello" {
+			t.Errorf("Unexpected streamed event: %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for streamed event")
+	}
+}
@@ -0,0 +1,100 @@
+package irc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Minimal CBOR (RFC 8949) encoding for trigger payloads, from their JSON
+// encoding like MessagePack in msgpack.go
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+)
+
+// jsonToCBOR re-encodes a JSON document as CBOR
+func jsonToCBOR(data []byte) ([]byte, error) {
+	tree, err := decodeJSONTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return appendCBOR(nil, tree)
+}
+
+// appendCBOR appends a decoded JSON value
+func appendCBOR(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if v {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i < 0 {
+				return cborHeader(buf, cborNegInt, uint64(-1-i)), nil
+			}
+			return cborHeader(buf, cborUint, uint64(i)), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, 0xfb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case string:
+		buf = cborHeader(buf, cborText, uint64(len(v)))
+		return append(buf, v...), nil
+	case []any:
+		buf = cborHeader(buf, cborArray, uint64(len(v)))
+		var err error
+		for _, item := range v {
+			if buf, err = appendCBOR(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = cborHeader(buf, cborMap, uint64(len(v)))
+		var err error
+		for _, k := range keys {
+			buf, _ = appendCBOR(buf, k)
+			if buf, err = appendCBOR(buf, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cbor: unexpected %T", v)
+}
+
+// cborHeader appends a major type with its argument in the shortest form
+func cborHeader(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+}
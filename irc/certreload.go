@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the API certificate files are checked for
+// changes, so renewals are picked up without a SIGHUP
+const certCheckInterval = time.Minute
+
+// CertReloader serves the API certificate from API_CERT and API_KEY and
+// loads them again when they change on disk or Reload is called, e.g. on
+// SIGHUP, so renewed certificates do not need a restart. A pair that fails
+// to load keeps the previous one in use.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	checked time.Time
+}
+
+// NewCertReloader loads a certificate and key pair
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the certificate and key files again
+func (r *CertReloader) Reload() error {
+	certMod, keyMod := modTime(r.certFile), modTime(r.keyFile)
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checked = time.Now()
+	if err != nil {
+		return err
+	}
+	r.cert, r.certMod, r.keyMod = &cert, certMod, keyMod
+	if cert.Leaf != nil {
+		log.Printf("Loaded API certificate %s (expires %s)", r.certFile, cert.Leaf.NotAfter.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// GetCertificate is the tls.Config hook; it reloads the files first when
+// they changed since the last check
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	due := time.Since(r.checked) >= certCheckInterval
+	r.mu.RUnlock()
+	if due {
+		r.reloadIfChanged()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) reloadIfChanged() {
+	certMod, keyMod := modTime(r.certFile), modTime(r.keyFile)
+	r.mu.Lock()
+	changed := !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+	r.checked = time.Now()
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+	if err := r.Reload(); err != nil {
+		log.Printf("Cannot reload API certificate, keeping the current one: %v", err)
+	}
+}
+
+// modTime returns a file's modification time, following symlinks such as
+// Let's Encrypt's live directory, or the zero time if it cannot be read
+func modTime(path string) time.Time {
+	st, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return st.ModTime()
+}
@@ -0,0 +1,72 @@
+package irc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertPair writes a new self-signed certificate and its key as PEM
+func writeCertPair(t *testing.T, certFile, keyFile string, mod time.Time) []byte {
+	t.Helper()
+	cert, key, _ := testCert(t, "hanna.example", nil, nil)
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600)
+	os.Chtimes(certFile, mod, mod)
+	os.Chtimes(keyFile, mod, mod)
+	return cert.Raw
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeCertPair(t, certFile, keyFile, time.Now().Add(-time.Hour))
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := func() []byte {
+		cert, err := r.GetCertificate(nil)
+		if err != nil || cert == nil {
+			t.Fatalf("GetCertificate: %v", err)
+		}
+		return cert.Certificate[0]
+	}
+	if !bytes.Equal(served(), first) {
+		t.Fatal("Expected the loaded certificate to be served")
+	}
+
+	// A renewal is picked up at the next check
+	second := writeCertPair(t, certFile, keyFile, time.Now())
+	if !bytes.Equal(served(), first) {
+		t.Error("Expected no reload before the check interval")
+	}
+	r.mu.Lock()
+	r.checked = time.Time{}
+	r.mu.Unlock()
+	if !bytes.Equal(served(), second) {
+		t.Error("Expected the renewed certificate after the files changed")
+	}
+
+	// A broken pair keeps the current certificate
+	os.WriteFile(keyFile, []byte("not a key"), 0o600)
+	if err := r.Reload(); err == nil {
+		t.Error("Expected reloading a broken key to fail")
+	}
+	if !bytes.Equal(served(), second) {
+		t.Error("Expected the current certificate to stay after a failed reload")
+	}
+
+	if _, err := NewCertReloader(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Error("Expected an error for missing files")
+	}
+}
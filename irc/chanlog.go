@@ -0,0 +1,321 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogEntry is one line of a channel log
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Channel string    `json:"channel"`
+	Type    string    `json:"type"` // privmsg, action, notice, join, part, quit, kick, mode, topic or nick
+	Nick    string    `json:"nick"`
+	Account string    `json:"account,omitempty"` // services account of nick, when known
+	Target  string    `json:"target,omitempty"`  // kicked nick or new nick
+	Message string    `json:"message,omitempty"`
+	Raw     string    `json:"raw,omitempty"` // message before CHANLOG_FORMATTING, when it changed
+}
+
+// channelLogger writes per-channel daily log files below dir:
+// <dir>/<channel>/<YYYY-MM-DD>.log (plain text) and .jsonl (one LogEntry per line)
+type channelLogger struct {
+	dir        string
+	text       bool
+	jsonl      bool
+	retention  int                 // days of files to keep, 0 keeps everything
+	channels   map[string]struct{} // channels to log (lowercase); empty means all
+	formatting string              // raw, strip or markdown
+
+	mu    sync.Mutex
+	day   string              // date of the currently open files
+	files map[string]*os.File // path -> open file
+}
+
+// loadChannelLogger enables channel logging when CHANLOG_DIR is set
+func (c *Client) loadChannelLogger() {
+	dir := strings.TrimSpace(os.Getenv("CHANLOG_DIR"))
+	if dir == "" {
+		return
+	}
+	l := &channelLogger{
+		dir:       dir,
+		retention: intenv("CHANLOG_RETENTION_DAYS", 0),
+		channels:  make(map[string]struct{}),
+		files:     make(map[string]*os.File),
+	}
+	for _, f := range strings.Split(getenv("CHANLOG_FORMATS", "text"), ",") {
+		switch strings.ToLower(strings.TrimSpace(f)) {
+		case "text":
+			l.text = true
+		case "jsonl":
+			l.jsonl = true
+		case "":
+		default:
+			log.Fatalf("FATAL: Invalid CHANLOG_FORMATS entry %q (use text and/or jsonl)", f)
+		}
+	}
+	l.formatting = strings.ToLower(getenv("CHANLOG_FORMATTING", "raw"))
+	if !formattingModes[l.formatting] {
+		log.Fatalf("FATAL: Invalid CHANLOG_FORMATTING %q (use raw, strip or markdown)", l.formatting)
+	}
+	for _, ch := range strings.Split(os.Getenv("CHANLOG_CHANNELS"), ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			l.channels[strings.ToLower(ch)] = struct{}{}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("FATAL: Cannot create CHANLOG_DIR %s: %v", dir, err)
+	}
+	l.prune(time.Now())
+	log.Printf("Logging channels to %s (text: %v, jsonl: %v, retention: %d days)", dir, l.text, l.jsonl, l.retention)
+	c.chanlog = l
+}
+
+// logChannelEvent records an event in a channel's log. The server-time tag is
+// used as the timestamp when present.
+func (c *Client) logChannelEvent(typ, channel, nick, target, message string, tags map[string]string) {
+	if c.chanlog == nil || !isChannelName(channel) {
+		return
+	}
+	if p := c.channelProfile(channel); p.Log != nil && !*p.Log || p.Log == nil && !c.chanlog.logs(channel) {
+		return
+	}
+	e := LogEntry{
+		Time:    messageTime(tags),
+		Channel: channel,
+		Type:    typ,
+		Nick:    nick,
+		Account: c.senderAccount(nick, tags),
+		Target:  target,
+		Message: message,
+	}
+	if converted := convertFormatting(c.chanlog.formatting, e.Message); converted != e.Message {
+		e.Raw, e.Message = e.Message, converted
+	}
+	c.chanlog.write(e)
+	if c.search != nil {
+		c.search.add(e)
+	}
+}
+
+// logOutgoing records the bot's own channel messages sent with raw
+func (c *Client) logOutgoing(line string) {
+	if c.chanlog == nil && c.recent.size == 0 {
+		return
+	}
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 3 {
+		return
+	}
+	cmd := strings.ToUpper(fields[0])
+	if cmd != "PRIVMSG" && cmd != "NOTICE" {
+		return
+	}
+	message := strings.TrimPrefix(fields[2], ":")
+	typ := strings.ToLower(cmd)
+	// Incoming actions arrive parsed; the bot's own are still CTCP here
+	if action, ok := ctcpAction(message); ok && cmd == "PRIVMSG" {
+		typ, message = "action", action
+	}
+	c.rememberOutgoing(typ, fields[1], message)
+	c.logChannelEvent(typ, fields[1], c.Nick(), "", message, nil)
+}
+
+// userChannels returns the channels a nick is currently listed in
+func (c *Client) userChannels(nick string) []string {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+
+	var out []string
+	for name, state := range c.channelStates {
+		if _, ok := state.Users[nick]; ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// isChannelName reports whether target is a channel rather than a nick
+func isChannelName(target string) bool {
+	return strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&")
+}
+
+// validChannelList reports whether list is one or more comma-separated
+// channel names without spaces or control characters
+func validChannelList(list string) bool {
+	for _, name := range strings.Split(list, ",") {
+		if len(name) < 2 || !isChannelName(name) || strings.ContainsAny(name, " \x00\x07\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// messageTime returns the IRCv3 server-time of a message, or now
+func messageTime(tags map[string]string) time.Time {
+	if ts, ok := tags["time"]; ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// ctcpAction extracts the text of a CTCP ACTION (/me) message
+func ctcpAction(message string) (string, bool) {
+	if !strings.HasPrefix(message, "\x01ACTION ") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(message, "\x01ACTION "), "\x01"), true
+}
+
+// formatLogText renders an entry in irssi style, prefixing the time in layout
+func formatLogText(e LogEntry, layout string) string {
+	ts := e.Time.Local().Format(layout)
+	switch e.Type {
+	case "privmsg":
+		return fmt.Sprintf("%s <%s> %s", ts, e.Nick, e.Message)
+	case "action":
+		return fmt.Sprintf("%s  * %s %s", ts, e.Nick, e.Message)
+	case "notice":
+		return fmt.Sprintf("%s -%s- %s", ts, e.Nick, e.Message)
+	case "join":
+		return fmt.Sprintf("%s -!- %s has joined %s", ts, e.Nick, e.Channel)
+	case "part":
+		return fmt.Sprintf("%s -!- %s has left %s [%s]", ts, e.Nick, e.Channel, e.Message)
+	case "quit":
+		return fmt.Sprintf("%s -!- %s has quit [%s]", ts, e.Nick, e.Message)
+	case "kick":
+		return fmt.Sprintf("%s -!- %s was kicked from %s by %s [%s]", ts, e.Target, e.Channel, e.Nick, e.Message)
+	case "mode":
+		return fmt.Sprintf("%s -!- mode/%s [%s] by %s", ts, e.Channel, e.Message, e.Nick)
+	case "topic":
+		return fmt.Sprintf("%s -!- %s changed the topic of %s to: %s", ts, e.Nick, e.Channel, e.Message)
+	case "nick":
+		return fmt.Sprintf("%s -!- %s is now known as %s", ts, e.Nick, e.Target)
+	default:
+		return fmt.Sprintf("%s -!- %s %s %s", ts, e.Type, e.Nick, e.Message)
+	}
+}
+
+// channelDir returns the directory holding a channel's log files
+func (l *channelLogger) channelDir(channel string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(strings.ToLower(channel))
+	if name == "." || name == ".." {
+		name = "_" + name
+	}
+	return filepath.Join(l.dir, name)
+}
+
+// logs reports whether a channel is selected by CHANLOG_CHANNELS
+func (l *channelLogger) logs(channel string) bool {
+	if len(l.channels) == 0 {
+		return true
+	}
+	_, ok := l.channels[strings.ToLower(channel)]
+	return ok
+}
+
+func (l *channelLogger) write(e LogEntry) {
+	day := e.Time.Local().Format("2006-01-02")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if day > l.day {
+		// Rotate: later writes go to the new day's files
+		if l.day != "" {
+			l.closeFiles()
+			l.prune(e.Time)
+		}
+		l.day = day
+	}
+
+	base := filepath.Join(l.channelDir(e.Channel), day)
+	if l.text {
+		l.appendLine(base+".log", formatLogText(e, "15:04:05"))
+	}
+	if l.jsonl {
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Error encoding log entry: %v", err)
+			return
+		}
+		l.appendLine(base+".jsonl", string(data))
+	}
+}
+
+func (l *channelLogger) appendLine(path, line string) {
+	f := l.files[path]
+	if f == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Printf("Error creating log directory for %s: %v", path, err)
+			return
+		}
+		var err error
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Printf("Error opening channel log %s: %v", path, err)
+			return
+		}
+		l.files[path] = f
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		log.Printf("Error writing channel log %s: %v", path, err)
+	}
+}
+
+func (l *channelLogger) closeFiles() {
+	for path, f := range l.files {
+		if err := f.Close(); err != nil {
+			log.Printf("Error closing channel log %s: %v", path, err)
+		}
+		delete(l.files, path)
+	}
+}
+
+// prune removes log files older than the retention period
+func (l *channelLogger) prune(now time.Time) {
+	if l.retention <= 0 {
+		return
+	}
+	cutoff := now.Local().AddDate(0, 0, -l.retention).Format("2006-01-02")
+	dirs, err := os.ReadDir(l.dir)
+	if err != nil {
+		log.Printf("Error reading CHANLOG_DIR %s: %v", l.dir, err)
+		return
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(l.dir, d.Name()))
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			day := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+			if _, err := time.Parse("2006-01-02", day); err != nil || day >= cutoff {
+				continue
+			}
+			path := filepath.Join(l.dir, d.Name(), f.Name())
+			if err := os.Remove(path); err != nil {
+				log.Printf("Error removing expired channel log %s: %v", path, err)
+			} else {
+				log.Printf("Removed expired channel log %s", path)
+			}
+		}
+	}
+}
+
+// Close flushes and closes open log files
+func (l *channelLogger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeFiles()
+}
@@ -0,0 +1,119 @@
+package irc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannelLogging(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHANLOG_DIR", dir)
+	t.Setenv("CHANLOG_FORMATS", "text,jsonl")
+	t.Setenv("CHANLOG_CHANNELS", "#test")
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	day := time.Now().Format("2006-01-02")
+	ts := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	client.handleLine("@time=" + ts + " :alice!a@host JOIN #test")
+	client.handleLine(":alice!a@host PRIVMSG #test :hello there")
+	client.handleLine(":alice!a@host PRIVMSG #test :\x01ACTION waves\x01")
+	client.handleLine(":alice!a@host PRIVMSG #other :not logged")
+	client.Privmsg("#test", "hi alice")
+	client.handleLine(":alice!a@host NICK :alice2")
+	client.handleLine(":alice2!a@host QUIT :bye")
+	client.chanlog.Close()
+
+	text, err := os.ReadFile(filepath.Join(dir, "#test", day+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(text)), "\n") {
+		got = append(got, line[len("15:04:05 "):])
+	}
+	want := []string{
+		"-!- alice has joined #test",
+		"<alice> hello there",
+		" * alice waves",
+		"<TestBot> hi alice",
+		"-!- alice is now known as alice2",
+		"-!- alice2 has quit [bye]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected text log:\n%s", text)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "#test", day+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first LogEntry
+	if err := json.Unmarshal([]byte(strings.Split(string(data), "\n")[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Type != "join" || first.Nick != "alice" || first.Time.UTC().Format("2006-01-02T15:04:05.000Z") != ts {
+		t.Errorf("Unexpected JSONL entry: %+v", first)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "#other")); !os.IsNotExist(err) {
+		t.Errorf("Channels outside CHANLOG_CHANNELS should not be logged")
+	}
+}
+
+func TestChannelLogFormatting(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHANLOG_DIR", dir)
+	t.Setenv("CHANLOG_FORMATS", "jsonl")
+	t.Setenv("CHANLOG_FORMATTING", "strip")
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.handleLine(":alice!a@host PRIVMSG #test :\x0304red\x03 \x02alert\x02")
+	client.chanlog.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "#test", time.Now().Format("2006-01-02")+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e LogEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Message != "red alert" || e.Raw != "\x0304red\x03 \x02alert\x02" {
+		t.Errorf("Expected stripped message with raw original, got %+v", e)
+	}
+}
+
+func TestChannelLogRetention(t *testing.T) {
+	dir := t.TempDir()
+	chanDir := filepath.Join(dir, "#test")
+	if err := os.MkdirAll(chanDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
+	recent := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	for _, name := range []string{old + ".log", old + ".jsonl", recent + ".log", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(chanDir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := &channelLogger{dir: dir, retention: 7}
+	l.prune(time.Now())
+
+	entries, _ := os.ReadDir(chanDir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if strings.Join(left, ",") != recent+".log,notes.txt" {
+		t.Errorf("Expected only recent and unrelated files to remain, got %v", left)
+	}
+}
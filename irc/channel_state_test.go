@@ -449,6 +449,38 @@ func TestAPIStateEndpoint(t *testing.T) {
 	}
 }
 
+func TestStateAccessorsReturnCopies(t *testing.T) {
+	client := NewClient()
+	client.AddUserToChannel("#Test", "alice", "o")
+	client.updateUserInfo("alice", func(u *UserInfo) {
+		u.Channels = []string{"#test"}
+		u.SpecialInfo["note"] = "x"
+	})
+
+	state := client.GetChannelState("#TEST")
+	if state == nil || state.Users["alice"] != "o" {
+		t.Fatalf("Expected the channel state, got %+v", state)
+	}
+	state.Users["mallory"] = "o"
+	if client.HasChannelUser("#test", "mallory") {
+		t.Error("Changing the returned state should not change the client's")
+	}
+	if client.GetChannelState("#nowhere") != nil {
+		t.Error("Expected nil for an unknown channel")
+	}
+
+	users := client.ListUsers()
+	alice := users["alice"]
+	if alice == nil || alice.SpecialInfo["note"] != "x" {
+		t.Fatalf("Expected alice in the user list, got %+v", users)
+	}
+	alice.Channels[0] = "#other"
+	alice.SpecialInfo["note"] = "y"
+	if info := client.getUserInfo("alice"); info.Channels[0] != "#test" || info.SpecialInfo["note"] != "x" {
+		t.Errorf("Changing the returned user should not change the client's, got %+v", info)
+	}
+}
+
 // Helper function to check if a mode string contains a specific mode
 func containsMode(modes string, mode rune) bool {
 	for _, m := range modes {
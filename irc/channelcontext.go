@@ -0,0 +1,47 @@
+package irc
+
+import "strings"
+
+// ChannelContext is channel metadata added to trigger payloads for endpoints
+// with "channel_context": true, so workflows need no /api/channel call
+type ChannelContext struct {
+	Name          string `json:"name"`
+	Topic         string `json:"topic"`
+	Modes         string `json:"modes"`
+	UserCount     int    `json:"userCount"`
+	SenderModes   string `json:"senderModes"` // e.g. "o" or "ov", empty when the sender has none or left
+	SenderAccount string `json:"senderAccount,omitempty"`
+}
+
+// channelContext describes a channel the bot is in as seen by an event from
+// sender, or returns nil when the channel is not tracked
+func (c *Client) channelContext(channel, sender string, tags map[string]string) *ChannelContext {
+	c.channelStatesMu.RLock()
+	state := c.channelStates[strings.ToLower(channel)]
+	if state == nil {
+		c.channelStatesMu.RUnlock()
+		return nil
+	}
+	ctx := &ChannelContext{
+		Name:      channel,
+		Topic:     state.Topic,
+		Modes:     state.Modes,
+		UserCount: len(state.Users),
+	}
+	if modes, ok := state.Users[sender]; ok {
+		ctx.SenderModes = modes
+	} else {
+		for nick, modes := range state.Users {
+			if strings.EqualFold(nick, sender) {
+				ctx.SenderModes = modes
+				break
+			}
+		}
+	}
+	c.channelStatesMu.RUnlock()
+
+	if sender != "" {
+		ctx.SenderAccount = c.senderAccount(sender, tags)
+	}
+	return ctx
+}
@@ -0,0 +1,124 @@
+package irc
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// ChannelProfile overrides global settings for one channel. CHANNEL_CONFIG
+// maps channel names to profiles; the "*" profile applies to every channel
+// and a channel's own profile wins field by field. Unset fields keep the
+// global behaviour.
+type ChannelProfile struct {
+	Mentions     string   `json:"mentions,omitempty"`      // nick (default), all (every message is a mention) or off
+	FloodProtect *bool    `json:"flood_protect,omitempty"` // paste long replies, instead of FLOOD_PROTECTED_CHANNELS
+	MaxLines     int      `json:"max_lines,omitempty"`     // lines sent before pasting, instead of MAX_LINES_BEFORE_PASTING
+	Endpoints    []string `json:"endpoints,omitempty"`     // trigger endpoints that get this channel's events; empty for all
+	Language     string   `json:"language,omitempty"`      // sent to triggers as language
+	SystemPrompt string   `json:"system_prompt,omitempty"` // sent to triggers as systemPrompt
+	Log          *bool    `json:"log,omitempty"`           // channel log files, instead of CHANLOG_CHANNELS
+	DailyCap     int      `json:"daily_cap,omitempty"`     // trigger events forwarded per day before forwarding pauses until midnight
+	Notice       *bool    `json:"notice,omitempty"`        // reply with NOTICE, instead of REPLY_NOTICE
+}
+
+var mentionModes = map[string]bool{"nick": true, "all": true, "off": true}
+
+func (c *Client) loadChannelProfiles() {
+	configStr := os.Getenv("CHANNEL_CONFIG")
+	if configStr == "" {
+		return
+	}
+	var profiles map[string]ChannelProfile
+	if err := json.Unmarshal([]byte(configStr), &profiles); err != nil {
+		log.Fatalf("FATAL: Invalid CHANNEL_CONFIG JSON: %v", err)
+	}
+	c.channelProfiles = make(map[string]ChannelProfile, len(profiles))
+	for name, p := range profiles {
+		if name != "*" && !isChannelName(name) {
+			log.Fatalf("FATAL: CHANNEL_CONFIG key %q is not a channel (use #channel or *)", name)
+		}
+		p.Mentions = strings.ToLower(p.Mentions)
+		if p.Mentions != "" && !mentionModes[p.Mentions] {
+			log.Fatalf("FATAL: Invalid mentions %q for %s in CHANNEL_CONFIG (use nick, all or off)", p.Mentions, name)
+		}
+		if p.MaxLines < 0 {
+			log.Fatalf("FATAL: max_lines for %s in CHANNEL_CONFIG must not be negative", name)
+		}
+		if p.DailyCap < 0 {
+			log.Fatalf("FATAL: daily_cap for %s in CHANNEL_CONFIG must not be negative", name)
+		}
+		for _, endpoint := range p.Endpoints {
+			if _, ok := c.triggerConfig.Endpoints[endpoint]; !ok {
+				log.Fatalf("FATAL: CHANNEL_CONFIG for %s routes to unknown trigger endpoint %q", name, endpoint)
+			}
+		}
+		c.channelProfiles[strings.ToLower(name)] = p
+	}
+	log.Printf("Loaded %d channel profiles", len(c.channelProfiles))
+}
+
+// channelProfile resolves the profile of a channel at event time: the "*"
+// profile overridden by the channel's own. Targets that are not channels
+// get an empty profile.
+func (c *Client) channelProfile(channel string) ChannelProfile {
+	if len(c.channelProfiles) == 0 || !isChannelName(channel) {
+		return ChannelProfile{}
+	}
+	p := c.channelProfiles["*"]
+	own, ok := c.channelProfiles[strings.ToLower(channel)]
+	if !ok {
+		return p
+	}
+	if own.Mentions != "" {
+		p.Mentions = own.Mentions
+	}
+	if own.FloodProtect != nil {
+		p.FloodProtect = own.FloodProtect
+	}
+	if own.MaxLines > 0 {
+		p.MaxLines = own.MaxLines
+	}
+	if own.Endpoints != nil {
+		p.Endpoints = own.Endpoints
+	}
+	if own.Language != "" {
+		p.Language = own.Language
+	}
+	if own.SystemPrompt != "" {
+		p.SystemPrompt = own.SystemPrompt
+	}
+	if own.Log != nil {
+		p.Log = own.Log
+	}
+	if own.DailyCap > 0 {
+		p.DailyCap = own.DailyCap
+	}
+	if own.Notice != nil {
+		p.Notice = own.Notice
+	}
+	return p
+}
+
+// routes reports whether a channel's events go to a trigger endpoint
+func (p ChannelProfile) routes(endpoint string) bool {
+	if len(p.Endpoints) == 0 {
+		return true
+	}
+	for _, name := range p.Endpoints {
+		if name == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLinesFor returns how many lines of a reply are sent to target before
+// the rest is pasted
+func (c *Client) maxLinesFor(target string) int {
+	if n := c.channelProfile(target).MaxLines; n > 0 {
+		return n
+	}
+	return c.maxLinesBeforePasting
+}
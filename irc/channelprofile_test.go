@@ -0,0 +1,110 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannelProfileResolution(t *testing.T) {
+	t.Setenv("CHANNEL_CONFIG", `{
+		"*": {"language": "en", "max_lines": 5, "flood_protect": true},
+		"#de": {"language": "de", "system_prompt": "Antworte kurz."},
+		"#raw": {"flood_protect": false}
+	}`)
+	client := NewClient()
+
+	p := client.channelProfile("#DE")
+	if p.Language != "de" || p.SystemPrompt != "Antworte kurz." || p.MaxLines != 5 {
+		t.Errorf("Expected #de to override the language and inherit max_lines, got %+v", p)
+	}
+	if p := client.channelProfile("#other"); p.Language != "en" {
+		t.Errorf("Expected the * profile for other channels, got %+v", p)
+	}
+	if p := client.channelProfile("alice"); p.Language != "" {
+		t.Errorf("Expected no profile for private messages, got %+v", p)
+	}
+	if !client.isFloodProtectedChannel("#de") || client.isFloodProtectedChannel("#raw") {
+		t.Error("Expected flood_protect to override FLOOD_PROTECTED_CHANNELS")
+	}
+	if n := client.maxLinesFor("#de"); n != 5 {
+		t.Errorf("Expected 5 lines before pasting, got %d", n)
+	}
+	if n := client.maxLinesFor("alice"); n != 3 {
+		t.Errorf("Expected the global 3 lines for private messages, got %d", n)
+	}
+}
+
+func TestChannelProfileMentionsAndRouting(t *testing.T) {
+	received := map[string]chan TriggerPayload{"llm": make(chan TriggerPayload, 10), "log": make(chan TriggerPayload, 10)}
+	urls := map[string]string{}
+	for name, ch := range received {
+		ch := ch
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload TriggerPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+				ch <- payload
+			}
+		}))
+		t.Cleanup(srv.Close)
+		urls[name] = srv.URL
+	}
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints": {
+		"llm": {"url": %q, "events": ["mention"]},
+		"log": {"url": %q, "events": ["mention"]}
+	}}`, urls["llm"], urls["log"]))
+	t.Setenv("CHANNEL_CONFIG", `{
+		"#ask": {"mentions": "all", "endpoints": ["llm"], "system_prompt": "Be helpful."},
+		"#quiet": {"mentions": "off"}
+	}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	// Every message in #ask is a mention, routed to llm only
+	client.handleLine(":alice!a@host PRIVMSG #ask :what is IRC?")
+	p := expectTrigger(t, received["llm"])
+	if p.EventType != "mention" || p.SystemPrompt != "Be helpful." {
+		t.Errorf("Unexpected payload: %+v", p)
+	}
+	expectNoTrigger(t, received["log"])
+
+	// Mentions are off in #quiet
+	client.handleLine(":alice!a@host PRIVMSG #quiet :hey TestBot")
+	expectNoTrigger(t, received["llm"])
+	expectNoTrigger(t, received["log"])
+
+	// Other channels keep the global behaviour
+	client.handleLine(":alice!a@host PRIVMSG #other :hey TestBot")
+	expectTrigger(t, received["llm"])
+	if p := expectTrigger(t, received["log"]); p.SystemPrompt != "" {
+		t.Errorf("Expected no system prompt outside #ask, got %q", p.SystemPrompt)
+	}
+}
+
+func TestChannelProfileLogging(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHANLOG_DIR", dir)
+	t.Setenv("CHANNEL_CONFIG", `{"#private": {"log": false}}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host PRIVMSG #private :secret")
+	client.handleLine(":alice!a@host PRIVMSG #public :hello")
+	client.chanlog.Close()
+
+	day := time.Now().Format("2006-01-02")
+	if _, err := os.Stat(filepath.Join(dir, "#private", day+".log")); !os.IsNotExist(err) {
+		t.Errorf("Expected #private not to be logged, got %v", err)
+	}
+	if text, err := os.ReadFile(filepath.Join(dir, "#public", day+".log")); err != nil || !strings.Contains(string(text), "hello") {
+		t.Errorf("Expected #public to be logged, got %q %v", text, err)
+	}
+}
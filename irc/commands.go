@@ -0,0 +1,126 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// CommandContext carries the details of an in-channel command invocation
+type CommandContext struct {
+	Client   *Client
+	Name     string
+	Args     []string
+	Sender   string // nick of the caller
+	Hostmask string // nick!user@host of the caller
+	Account  string // services account of the caller, if known
+	Target   string // channel or bot nick the command was sent to
+	ReplyTo  string // channel for channel commands, sender for private ones
+	Role     Role
+	Tags     map[string]string
+}
+
+// Reply sends a message back to where the command came from
+func (ctx *CommandContext) Reply(msg string) {
+	ctx.Client.Privmsg(ctx.ReplyTo, msg)
+}
+
+// Replyf is the formatted variant of Reply
+func (ctx *CommandContext) Replyf(format string, a ...any) {
+	ctx.Reply(fmt.Sprintf(format, a...))
+}
+
+// Command is an in-channel command such as !whoami
+type Command struct {
+	Name    string
+	Usage   string
+	Role    Role // default required role, overridable via ACCESS_CONFIG
+	Handler func(*CommandContext)
+}
+
+// RegisterCommand adds or replaces an in-channel command
+func (c *Client) RegisterCommand(cmd Command) {
+	c.commandsMu.Lock()
+	defer c.commandsMu.Unlock()
+	c.commands[strings.ToLower(cmd.Name)] = &cmd
+}
+
+func (c *Client) getCommand(name string) *Command {
+	c.commandsMu.RLock()
+	defer c.commandsMu.RUnlock()
+	return c.commands[strings.ToLower(name)]
+}
+
+// dispatchCommand runs a registered command if message starts with the
+// command prefix. It returns true when the message was handled as a command.
+func (c *Client) dispatchCommand(prefix, target, message string, tags map[string]string) bool {
+	if c.commandPrefix == "" || !strings.HasPrefix(message, c.commandPrefix) {
+		return false
+	}
+	fields := strings.Fields(strings.TrimPrefix(message, c.commandPrefix))
+	if len(fields) == 0 {
+		return false
+	}
+	cmd := c.getCommand(fields[0])
+	if cmd == nil {
+		return false
+	}
+
+	sender := strings.Split(prefix, "!")[0]
+	ctx := &CommandContext{
+		Client:   c,
+		Name:     strings.ToLower(fields[0]),
+		Args:     fields[1:],
+		Sender:   sender,
+		Hostmask: prefix,
+		Account:  c.senderAccount(sender, tags),
+		Target:   target,
+		ReplyTo:  target,
+		Tags:     tags,
+	}
+	if strings.EqualFold(target, c.Nick()) {
+		ctx.ReplyTo = sender
+	}
+	ctx.Role = c.RoleOf(ctx.Hostmask, ctx.Account)
+
+	if required := c.commandRole(cmd.Name, cmd.Role); ctx.Role < required {
+		log.Printf("Denied command %s for %s (role %s, requires %s)", ctx.Name, prefix, ctx.Role, required)
+		ctx.Replyf("%s: %s%s requires role %s", sender, c.commandPrefix, ctx.Name, required)
+		return true
+	}
+
+	log.Printf("Running command %s for %s in %s", ctx.Name, sender, target)
+	cmd.Handler(ctx)
+	return true
+}
+
+func (c *Client) registerBuiltinCommands() {
+	c.RegisterCommand(Command{
+		Name:  "whoami",
+		Usage: "whoami",
+		Handler: func(ctx *CommandContext) {
+			account := ctx.Account
+			if account == "" {
+				account = "not logged in"
+			}
+			ctx.Replyf("%s: you are %s (account: %s), role %s", ctx.Sender, ctx.Hostmask, account, ctx.Role)
+		},
+	})
+	c.RegisterCommand(Command{
+		Name:  "help",
+		Usage: "help",
+		Handler: func(ctx *CommandContext) {
+			c.commandsMu.RLock()
+			var names []string
+			for name, cmd := range c.commands {
+				if ctx.Role >= c.commandRole(name, cmd.Role) {
+					names = append(names, c.commandPrefix+cmd.Usage)
+				}
+			}
+			c.commandsMu.RUnlock()
+			sort.Strings(names)
+			ctx.Replyf("%s: available commands: %s", ctx.Sender, strings.Join(names, ", "))
+		},
+	})
+}
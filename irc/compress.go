@@ -0,0 +1,235 @@
+package irc
+
+import (
+	"compress/gzip"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// apiEncoding is the response encoding of the API: gzip for clients sending
+// Accept-Encoding: gzip, and MessagePack instead of JSON for clients asking
+// for application/msgpack
+type apiEncoding struct {
+	gzip    bool // API_GZIP
+	minSize int  // API_GZIP_MIN_BYTES: smaller responses are sent as they are
+}
+
+func (c *Client) loadAPIEncoding() {
+	c.apiEncoding.gzip = boolenv("API_GZIP", true)
+	c.apiEncoding.minSize = intenv("API_GZIP_MIN_BYTES", 1024)
+	if c.apiEncoding.minSize < 0 {
+		log.Fatalf("FATAL: API_GZIP_MIN_BYTES must not be negative")
+	}
+}
+
+// msgpackTypes are the media types MessagePack is requested with
+var msgpackTypes = []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+
+// acceptQuality returns the quality an Accept or Accept-Encoding header gives
+// value: that of its own entry, else of the best matching wildcard (*, */*
+// or type/*), else 0. Only its own entry counts with exact set.
+func acceptQuality(header, value string, exact bool) float64 {
+	own, wildcard := -1.0, 0.0
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch {
+		case name == value:
+			own = max(own, q)
+		case exact:
+		case name == "*" || name == "*/*",
+			strings.HasSuffix(name, "/*") && strings.HasPrefix(value, strings.TrimSuffix(name, "*")):
+			wildcard = max(wildcard, q)
+		}
+	}
+	if own >= 0 {
+		return own
+	}
+	return wildcard
+}
+
+// wantsMsgpack reports whether Accept names MessagePack with a higher
+// quality than JSON; wildcards keep JSON
+func wantsMsgpack(accept string) bool {
+	if accept == "" {
+		return false
+	}
+	var q float64
+	for _, t := range msgpackTypes {
+		q = max(q, acceptQuality(accept, t, true))
+	}
+	return q > 0 && q > acceptQuality(accept, "application/json", true)
+}
+
+// compressible reports whether a response of this media type is worth
+// gzipping: text and structured data, but not event streams, which are read
+// line by line as they come, or formats that are compressed already
+func compressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "msgpack", "protobuf"} {
+		if strings.Contains(mediaType, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// withEncoding negotiates the encoding of API responses. Whether a response
+// is gzipped is decided once its first API_GZIP_MIN_BYTES are written, or
+// when the handler flushes, so small answers go out as they are and streams
+// are compressed as they flush.
+func (a *API) withEncoding(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept, Accept-Encoding")
+		gz := a.bot.apiEncoding.gzip && acceptQuality(r.Header.Get("Accept-Encoding"), "gzip", false) > 0
+		msgpack := wantsMsgpack(r.Header.Get("Accept"))
+		if !gz && !msgpack {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &encodedWriter{ResponseWriter: w, gzip: gz, minSize: a.bot.apiEncoding.minSize, msgpack: msgpack}
+		next.ServeHTTP(ew, r)
+		ew.close()
+	})
+}
+
+// encodedWriter holds back the start of a response until it knows whether
+// to gzip it. writeJSON checks msgpack to answer in MessagePack.
+type encodedWriter struct {
+	http.ResponseWriter
+	gzip    bool // the client accepts gzip
+	minSize int
+	msgpack bool // the client asked for MessagePack
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *encodedWriter) WriteHeader(status int) {
+	if w.status != 0 || w.decided {
+		return
+	}
+	if status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *encodedWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.minSize {
+			return len(p), nil
+		}
+		if err := w.decide(w.compress()); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return w.write(p)
+}
+
+func (w *encodedWriter) write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// compress reports whether the response is gzipped, once it is known to be
+// large enough
+func (w *encodedWriter) compress() bool {
+	h := w.Header()
+	if !w.gzip || h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || w.status == http.StatusPartialContent {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+	}
+	return compressible(contentType)
+}
+
+// decide sends the header, with or without gzip, then what was held back
+func (w *encodedWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) > 0 {
+		_, err := w.write(buf)
+		return err
+	}
+	return nil
+}
+
+// Flush sends what was written so far; a handler that flushes streams its
+// response, which is compressed whatever its size
+func (w *encodedWriter) Flush() {
+	if !w.decided {
+		w.decide(w.compress())
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *encodedWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// close ends the response once the handler returned
+func (w *encodedWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing written: the server sends its own 200
+			return
+		}
+		// Smaller than API_GZIP_MIN_BYTES, or Write would have decided
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(nil)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
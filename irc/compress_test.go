@@ -0,0 +1,115 @@
+package irc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIResponseEncoding(t *testing.T) {
+	client := NewClient()
+	for i := range 200 {
+		client.AddUserToChannel("#big", fmt.Sprintf("user%03d", i), "")
+	}
+	api := client.CreateAPI("token")
+	get := func(path string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/state", "Accept-Encoding", "br, gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzipped state, got headers %v", rec.Header())
+	}
+	if v := rec.Header().Values("Vary"); len(v) != 1 || v[0] != "Accept, Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept, Accept-Encoding, got %v", v)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil || !json.Valid(body) || !bytes.Contains(body, []byte(`"user199"`)) {
+		t.Errorf("Expected the state with every user, got %v", err)
+	}
+
+	// Small responses, clients without gzip and API_GZIP=false stay plain
+	for _, rec := range []*httptest.ResponseRecorder{
+		get("/version", "Accept-Encoding", "gzip"),
+		get("/api/state"),
+		get("/api/state", "Accept-Encoding", "gzip;q=0, identity"),
+	} {
+		if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("Expected no encoding, got %q", enc)
+		}
+		if !json.Valid(rec.Body.Bytes()) {
+			t.Errorf("Expected plain JSON, got %q", rec.Body.String())
+		}
+	}
+	client.apiEncoding.gzip = false
+	if enc := get("/api/state", "Accept-Encoding", "gzip").Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Expected no encoding with API_GZIP=false, got %q", enc)
+	}
+	client.apiEncoding.gzip = true
+
+	// MessagePack, gzipped too when large
+	rec = get("/version", "Accept", "application/msgpack")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/msgpack" {
+		t.Fatalf("Expected MessagePack, got %q", ct)
+	}
+	want := append([]byte{0x82, 0xa4}, "name"...)
+	if !bytes.HasPrefix(rec.Body.Bytes(), want) {
+		t.Errorf("Expected a map of two starting with name, got % x", rec.Body.Bytes())
+	}
+	rec = get("/api/state", "Accept", "application/msgpack, application/json;q=0.5", "Accept-Encoding", "gzip")
+	if rec.Header().Get("Content-Type") != "application/msgpack" || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected gzipped MessagePack, got %v", rec.Header())
+	}
+	for _, accept := range []string{"*/*", "application/json, application/msgpack;q=0.9", "application/*"} {
+		if ct := get("/version", "Accept", accept).Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Accept %q: expected JSON, got %q", accept, ct)
+		}
+	}
+}
+
+func TestMarshalMsgpack(t *testing.T) {
+	for _, tc := range []struct {
+		v    any
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{5, []byte{0x05}},
+		{-3, []byte{0xfd}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{70000, []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"hi", []byte{0xa2, 'h', 'i'}},
+		{[]string{"a"}, []byte{0x91, 0xa1, 'a'}},
+		{map[string]int{"b": 1, "a": 2}, []byte{0x82, 0xa1, 'a', 0x02, 0xa1, 'b', 0x01}},
+		{struct {
+			Nick string `json:"nick"`
+			Away bool   `json:"away,omitempty"`
+		}{Nick: "x"}, []byte{0x81, 0xa4, 'n', 'i', 'c', 'k', 0xa1, 'x'}},
+	} {
+		got, err := marshalMsgpack(tc.v)
+		if err != nil || !bytes.Equal(got, tc.want) {
+			t.Errorf("%#v: expected % x, got % x (%v)", tc.v, tc.want, got, err)
+		}
+	}
+	long, _ := marshalMsgpack(string(bytes.Repeat([]byte("x"), 300)))
+	if !bytes.HasPrefix(long, []byte{0xda, 0x01, 0x2c}) || len(long) != 303 {
+		t.Errorf("Expected a str16 of 300 bytes, got % x...", long[:3])
+	}
+}
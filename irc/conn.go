@@ -0,0 +1,319 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// retireTimeout bounds how long Dial waits for the previous connection's
+// read loop to exit; the attempt fails rather than start a second reader
+const retireTimeout = 10 * time.Second
+
+var errConnReplaced = errors.New("replaced by a new connection")
+
+// ircConn is one connection to the server. Every Dial makes a new one with
+// its own reader, writer and context, and the read loop and writes only use
+// the connection they were started with, so a connection that ends late can
+// neither read from nor disconnect its successor.
+type ircConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer // guarded by Client.wmu
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	done    chan struct{} // closed when the read loop exits
+	retired atomic.Bool   // a new Dial took over; the read loop leaves the state alone
+}
+
+// attach makes d the current connection. It ends with ctx, Close or end,
+// which close the socket and so unblock its read loop.
+func (c *Client) attach(ctx context.Context, d net.Conn) *ircConn {
+	connCtx, cancel := context.WithCancelCause(ctx)
+	stopLink := context.AfterFunc(c.context(), func() { cancel(nil) })
+	context.AfterFunc(connCtx, func() {
+		stopLink()
+		d.Close()
+	})
+	ic := &ircConn{
+		conn:   d,
+		r:      bufio.NewReader(d),
+		w:      bufio.NewWriter(d),
+		ctx:    connCtx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	c.connMu.Lock()
+	c.conn = ic
+	c.connMu.Unlock()
+	return ic
+}
+
+// end cancels the connection and closes its socket; cause, when set,
+// becomes the disconnect reason
+func (ic *ircConn) end(cause error) {
+	ic.cancel(cause)
+	ic.conn.Close()
+}
+
+// currentConn returns the latest connection, nil before the first Dial
+func (c *Client) currentConn() *ircConn {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.conn
+}
+
+// retireConn ends the previous connection and waits for its read loop to
+// exit, so that a connection never has company
+func (c *Client) retireConn(ctx context.Context) error {
+	old := c.currentConn()
+	if old == nil {
+		return nil
+	}
+	old.retired.Store(true)
+	old.end(errConnReplaced)
+	t := time.NewTimer(retireTimeout)
+	defer t.Stop()
+	select {
+	case <-old.done:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-t.C:
+		return errors.New("the previous connection's read loop did not stop")
+	}
+}
+
+// connDone returns a channel closed when the current connection's read loop
+// exits, for loops that live as long as the connection; nil before the
+// first Dial
+func (c *Client) connDone() <-chan struct{} {
+	if ic := c.currentConn(); ic != nil {
+		return ic.done
+	}
+	return nil
+}
+
+// Dial connects and registers with the server. The connection stays open
+// until ctx is cancelled, Close is called or the server drops it; cancelling
+// ctx also aborts a dial or SASL exchange in progress.
+func (c *Client) Dial(ctx context.Context) error {
+	if c.transport == nil {
+		return errors.New("IRC_ADDR is required")
+	}
+	if !c.transition(StateConnecting, "") {
+		return fmt.Errorf("cannot connect while %s", c.State())
+	}
+	// The previous connection's read loop must be gone before the next
+	// connection starts, or both would feed the same state
+	if err := c.retireConn(ctx); err != nil {
+		log.Printf("Connection failed: %v", err)
+		c.transition(StateDisconnected, err.Error())
+		return err
+	}
+	log.Printf("Connecting to IRC server %s", c.transport)
+	d, err := c.transport.Dial(ctx)
+	if err != nil {
+		log.Printf("Connection failed: %v", err)
+		c.transition(StateDisconnected, err.Error())
+		return err
+	}
+	log.Printf("Connection established")
+	ic := c.attach(ctx, d)
+	c.transition(StateRegistering, "")
+
+	// Registration sequence
+	log.Printf("Starting IRC registration as nick: %s", c.Nick())
+	if c.pass != "" {
+		log.Printf("Sending server password")
+		c.rawf("PASS %s", c.pass)
+	}
+
+	// Check if SASL is configured
+	sasl := c.saslUser != "" && c.saslPass != ""
+
+	// Always request CAP negotiation for caps (and SASL if configured)
+	log.Printf("Starting capability negotiation")
+	c.messageTags.Store(false)
+	c.setname.Store(false)
+	c.labeledResponse.Store(false)
+	c.raw("CAP LS 302")
+
+	c.capPending.Store(6)
+	if sasl {
+		log.Printf("Requesting SASL and other caps")
+		c.transition(StateAuthenticating, "")
+		c.saslStatus.Store("pending")
+		c.saslInProgress.Store(true)
+		c.raw("CAP REQ :sasl message-tags account-tag server-time")
+	} else {
+		log.Printf("Requesting caps")
+		c.raw("CAP REQ :message-tags account-tag server-time")
+	}
+	// Account tracking is requested on its own so a server refusing it
+	// still grants the caps above
+	c.raw("CAP REQ :extended-join account-notify")
+	// batch groups netsplits and history playback; a server without it must
+	// not take SASL down with it
+	c.raw("CAP REQ :batch")
+	// multi-prefix lists every status of a user in NAMES, not just the highest
+	c.raw("CAP REQ :multi-prefix")
+	// setname announces realname changes and lets the bot change its own
+	c.raw("CAP REQ :setname")
+	// labeled-response tells which answers belong to a reply sent by the API
+	c.raw("CAP REQ :labeled-response")
+
+	go c.readLoop(ic)
+
+	if sasl {
+		// Wait for SASL to complete before sending NICK/USER
+		log.Printf("Waiting for SASL authentication to complete...")
+		select {
+		case success := <-c.saslComplete:
+			if success {
+				log.Printf("SASL authentication completed successfully")
+				c.saslStatus.Store("succeeded")
+				c.transition(StateRegistering, "SASL authentication succeeded")
+			} else {
+				log.Printf("SASL authentication failed, continuing without SASL")
+				c.saslStatus.Store("failed")
+				c.transition(StateRegistering, "SASL authentication failed")
+			}
+		case <-time.After(30 * time.Second):
+			log.Printf("SASL authentication timed out, continuing without SASL")
+			c.saslStatus.Store("timed out")
+			c.saslInProgress.Store(false)
+			c.transition(StateRegistering, "SASL authentication timed out")
+		case <-ic.ctx.Done():
+			c.saslInProgress.Store(false)
+			return fmt.Errorf("connection closed during SASL: %w", context.Cause(ic.ctx))
+		}
+	}
+
+	// Send NICK and USER after SASL is complete (or if SASL is not used)
+	log.Printf("Sending NICK and USER commands")
+	c.rawf("NICK %s", c.registrationNick())
+	c.rawf("USER %s 0 * :%s", c.user, c.name)
+
+	return nil
+}
+
+// readLoop reads and handles the lines of one connection until it ends
+func (c *Client) readLoop(ic *ircConn) {
+	log.Printf("Starting IRC read loop")
+	c.readLoops.Add(1)
+	defer func() {
+		c.readLoops.Add(-1)
+		close(ic.done)
+	}()
+	for {
+		if c.readTimeout > 0 {
+			// The lag check's PINGs keep a healthy connection from going quiet
+			ic.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+		}
+		line, err := ic.r.ReadString('\n')
+		if err != nil {
+			reason := err.Error()
+			if ic.ctx.Err() != nil {
+				reason = context.Cause(ic.ctx).Error()
+				log.Printf("IRC connection closed: %s", reason)
+			} else {
+				log.Printf("IRC read error: %v", err)
+			}
+			// The connection is over before the state says so, a reconnect
+			// never overlaps it
+			ic.end(nil)
+			if ic.retired.Load() {
+				// The next connection owns the state now
+				return
+			}
+			c.transition(StateDisconnected, reason)
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+		debugf("<< %s", line)
+		c.recorder.record("in", line)
+		c.rawTap.publish("in", line)
+		// A line that trips a bug is dropped, the connection stays up
+		c.safely("handleLine", func() { c.handleLine(line) })
+	}
+}
+
+// errNotConnected is returned for lines sent while there is no connection
+var errNotConnected = errors.New("not connected to the IRC server")
+
+func (c *Client) rawf(format string, a ...any) error { return c.raw(fmt.Sprintf(format, a...)) }
+
+func (c *Client) raw(s string) error { return c.rawFrom(s, nil) }
+
+// rawFrom sends a line on behalf of an attached bouncer client (nil for the
+// bot itself); the other attached clients see the bot's own messages. A
+// write that fails or exceeds the write timeout ends the connection, so the
+// supervisor reconnects.
+func (c *Client) rawFrom(s string, from *downstream) error {
+	s = validOutgoing(s)
+	// Logs and bouncer clients see the line without its client tags
+	untagged := s
+	if strings.HasPrefix(s, "@") {
+		_, untagged, _ = strings.Cut(s, " ")
+	}
+	c.logOutgoing(untagged)
+	if c.testRawCapture != nil {
+		c.testRawCapture(s)
+		c.relayOutgoing(untagged, from)
+		return nil
+	}
+	c.pendingWrites.Add(1)
+	defer c.pendingWrites.Add(-1)
+	ic := c.currentConn()
+	if ic == nil {
+		return errNotConnected
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	debugf(">> %s", s)
+	c.recorder.record("out", s)
+	c.rawTap.publish("out", s)
+	if c.writeTimeout > 0 {
+		ic.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err := fmt.Fprint(ic.w, s, "\r\n")
+	if err == nil {
+		err = ic.w.Flush()
+	}
+	if err != nil {
+		log.Printf("IRC write error: %v", err)
+		// Only the connection written to ends, not one that replaced it
+		ic.end(fmt.Errorf("write error: %w", err))
+		return err
+	}
+	// Attached clients only see lines the server got
+	c.relayOutgoing(untagged, from)
+	return nil
+}
+
+// connContext returns the current connection's context, cancelled when the
+// connection ends. Before the first connection it is the lifetime context.
+func (c *Client) connContext() context.Context {
+	if ic := c.currentConn(); ic != nil {
+		return ic.ctx
+	}
+	return c.context()
+}
+
+// dropConnection ends the current connection; cause, when set, becomes the
+// disconnect reason
+func (c *Client) dropConnection(cause error) {
+	if ic := c.currentConn(); ic != nil {
+		ic.end(cause)
+	}
+}
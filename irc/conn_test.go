@@ -0,0 +1,125 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRapidReconnect(t *testing.T) {
+	t.Setenv("LAG_CHECK_SECONDS", "0")
+	srv := newFakeIRCd(t)
+	client := srv.client()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const cycles = 20
+	for i := range cycles {
+		if err := client.Dial(ctx); err != nil {
+			t.Fatalf("Cycle %d: %v", i, err)
+		}
+		fc := srv.accept()
+		waitState(t, client, StateConnected)
+		if n := client.readLoops.Load(); n != 1 {
+			t.Fatalf("Cycle %d: expected one read loop, got %d", i, n)
+		}
+		// Alternate between the server and the client ending the connection
+		if i%2 == 0 {
+			fc.close()
+		} else {
+			client.dropConnection(errors.New("cycle"))
+		}
+		waitState(t, client, StateDisconnected)
+	}
+
+	select {
+	case <-client.connDone():
+	case <-time.After(2 * time.Second):
+		t.Fatal("The last read loop did not stop")
+	}
+	if n := client.readLoops.Load(); n != 0 {
+		t.Errorf("Expected no read loop left, got %d", n)
+	}
+	if n := client.connections.Load(); n != cycles {
+		t.Errorf("Expected %d connections, got %d", cycles, n)
+	}
+}
+
+func TestDialWaitsForPreviousReadLoop(t *testing.T) {
+	t.Setenv("LAG_CHECK_SECONDS", "0")
+	srv := newFakeIRCd(t)
+	client := srv.client()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := client.Dial(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first := srv.accept()
+	waitState(t, client, StateConnected)
+
+	// The read loop is stuck handling a line when the state already says
+	// disconnected
+	stalled, release := make(chan struct{}), make(chan struct{})
+	client.bus.Subscribe("test", func(e Event) {
+		if e.Text == "stall" {
+			close(stalled)
+			<-release
+		}
+	})
+	first.send(":alice!a@host PRIVMSG #test :stall")
+	<-stalled
+	client.transition(StateDisconnected, "test")
+
+	dialed := make(chan error, 1)
+	go func() { dialed <- client.Dial(ctx) }()
+	select {
+	case <-srv.conns:
+		t.Fatal("Dialed while the previous read loop was running")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	srv.accept()
+	if err := <-dialed; err != nil {
+		t.Fatal(err)
+	}
+	// The old read loop exits without disconnecting its successor
+	waitState(t, client, StateConnected)
+	if n := client.readLoops.Load(); n != 1 {
+		t.Errorf("Expected one read loop, got %d", n)
+	}
+	if err := client.raw("PRIVMSG #test :hello"); err != nil {
+		t.Errorf("Expected the new connection to take writes, got %v", err)
+	}
+}
+
+func TestLateWriteErrorKeepsNewConnection(t *testing.T) {
+	srv := newFakeIRCd(t)
+	client := srv.client()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := client.Dial(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.accept()
+	old := client.currentConn()
+	client.dropConnection(errors.New("first"))
+	waitState(t, client, StateDisconnected)
+	if err := client.Dial(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.accept()
+	waitState(t, client, StateConnected)
+
+	// A write on the replaced connection fails on that connection alone
+	old.end(errors.New("write error: late"))
+	if err := client.connContext().Err(); err != nil {
+		t.Errorf("The new connection should stay up, got %v", err)
+	}
+	if client.State() != StateConnected {
+		t.Errorf("Expected to stay connected, got %s", client.State())
+	}
+}
@@ -0,0 +1,122 @@
+package irc
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+)
+
+// ConnState is the state of the IRC connection
+type ConnState int
+
+const (
+	StateDisconnected   ConnState = iota
+	StateConnecting               // dialing the server
+	StateRegistering              // connected, waiting for 001
+	StateAuthenticating           // SASL exchange in progress
+	StateConnected                // registered and usable
+	StateClosing                  // Close was called
+)
+
+var connStateNames = [...]string{"disconnected", "connecting", "registering", "authenticating", "connected", "closing"}
+
+func (s ConnState) String() string {
+	if int(s) < len(connStateNames) {
+		return connStateNames[s]
+	}
+	return "unknown"
+}
+
+func (s ConnState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// connTransitions lists the states each state may move to
+var connTransitions = map[ConnState][]ConnState{
+	StateDisconnected:   {StateConnecting, StateClosing},
+	StateConnecting:     {StateRegistering, StateAuthenticating, StateDisconnected, StateClosing},
+	StateRegistering:    {StateAuthenticating, StateConnected, StateDisconnected, StateClosing},
+	StateAuthenticating: {StateRegistering, StateDisconnected, StateClosing},
+	StateConnected:      {StateDisconnected, StateClosing},
+	StateClosing:        {StateDisconnected},
+}
+
+// StateInfo describes the current connection state and how it was reached
+type StateInfo struct {
+	State    ConnState `json:"state"`
+	Previous ConnState `json:"previous"`
+	Since    time.Time `json:"since"`
+	Reason   string    `json:"reason,omitempty"` // why the last transition happened, e.g. a read error
+}
+
+// RegistrationFailed reports whether the connection was lost before the
+// server accepted the registration (as opposed to a drop after connecting)
+func (i StateInfo) RegistrationFailed() bool {
+	return i.State == StateDisconnected && (i.Previous == StateRegistering || i.Previous == StateAuthenticating)
+}
+
+// StateChangeFunc is called after every connection state transition
+type StateChangeFunc func(from, to ConnState, reason string)
+
+// State returns the current connection state
+func (c *Client) State() ConnState {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.state.State
+}
+
+// StateInfo returns the current connection state with its history
+func (c *Client) StateInfo() StateInfo {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.state
+}
+
+// OnStateChange registers a hook run after each state transition. Hooks run
+// synchronously on the goroutine making the transition.
+func (c *Client) OnStateChange(fn StateChangeFunc) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.stateHooks = append(c.stateHooks, fn)
+}
+
+// transition moves to a new state. Transitions not listed in connTransitions
+// are refused and reported as false; moving to the current state is a no-op.
+func (c *Client) transition(to ConnState, reason string) bool {
+	c.stateMu.Lock()
+	from := c.state.State
+	if from == to {
+		c.stateMu.Unlock()
+		return true
+	}
+	if !slices.Contains(connTransitions[from], to) {
+		c.stateMu.Unlock()
+		log.Printf("Ignoring invalid connection state transition %s -> %s", from, to)
+		return false
+	}
+	if reason == "" && to == StateDisconnected {
+		reason, c.serverError = c.serverError, ""
+	}
+	c.state = StateInfo{State: to, Previous: from, Since: time.Now(), Reason: reason}
+	hooks := slices.Clone(c.stateHooks)
+	c.stateMu.Unlock()
+
+	if reason != "" {
+		log.Printf("Connection state: %s -> %s (%s)", from, to, reason)
+	} else {
+		log.Printf("Connection state: %s -> %s", from, to)
+	}
+	for _, fn := range hooks {
+		fn(from, to, reason)
+	}
+	return true
+}
+
+// noteServerError remembers an ERROR message so it becomes the reason of the
+// disconnect that follows
+func (c *Client) noteServerError(message string) {
+	c.stateMu.Lock()
+	c.serverError = message
+	c.stateMu.Unlock()
+}
@@ -0,0 +1,92 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// markConnected walks a test client through registration without a server
+func markConnected(c *Client) {
+	c.transition(StateConnecting, "")
+	c.transition(StateRegistering, "")
+	c.transition(StateConnected, "")
+}
+
+func TestConnStateTransitions(t *testing.T) {
+	client := NewClient()
+	var seen []string
+	client.OnStateChange(func(from, to ConnState, reason string) {
+		seen = append(seen, from.String()+">"+to.String())
+	})
+
+	if client.transition(StateConnected, "") {
+		t.Error("disconnected -> connected should be refused")
+	}
+	markConnected(client)
+	if !client.Connected() {
+		t.Fatal("Expected client to be connected")
+	}
+	client.noteServerError("Closing Link: banned")
+	client.transition(StateDisconnected, "")
+
+	want := []string{"disconnected>connecting", "connecting>registering", "registering>connected", "connected>disconnected"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected transitions %v, got %v", want, seen)
+	}
+	info := client.StateInfo()
+	if info.Previous != StateConnected || info.Reason != "Closing Link: banned" || info.RegistrationFailed() {
+		t.Errorf("Unexpected state info: %+v", info)
+	}
+}
+
+func TestRegistrationFailure(t *testing.T) {
+	client := NewClient()
+	client.transition(StateConnecting, "")
+	client.transition(StateRegistering, "")
+	client.handleLine("ERROR :Closing Link: nick in use")
+	client.transition(StateDisconnected, "")
+	if info := client.StateInfo(); !info.RegistrationFailed() || info.Reason != "Closing Link: nick in use" {
+		t.Errorf("Expected a registration failure, got %+v", info)
+	}
+}
+
+func TestWelcomeConnects(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	client.transition(StateConnecting, "")
+	client.transition(StateRegistering, "")
+	client.handleLine(":server 001 TestBot :Welcome")
+	if client.State() != StateConnected {
+		t.Errorf("Expected connected after 001, got %s", client.State())
+	}
+
+	client.Close()
+	if client.State() != StateDisconnected || client.StateInfo().Previous != StateClosing {
+		t.Errorf("Expected closing -> disconnected, got %+v", client.StateInfo())
+	}
+}
+
+func TestStateEndpoint(t *testing.T) {
+	client := NewClient()
+	markConnected(client)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/state", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	client.CreateAPI("token").ServeHTTP(rec, req)
+
+	var body struct {
+		Connection struct {
+			State    string `json:"state"`
+			Previous string `json:"previous"`
+		} `json:"connection"`
+	}
+	if err := json.NewDecoder(strings.NewReader(rec.Body.String())).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Connection.State != "connected" || body.Connection.Previous != "registering" {
+		t.Errorf("Unexpected connection state: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenIRC starts a TCP server that accepts one connection and reads from it
+// until it is closed
+func listenIRC(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialCancelClosesConnection(t *testing.T) {
+	t.Setenv("IRC_ADDR", listenIRC(t))
+	t.Setenv("IRC_TLS", "0")
+	client := NewClient()
+	client.setNick("TestBot")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := client.Dial(ctx); err != nil {
+		t.Fatal(err)
+	}
+	done := client.connDone()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Read loop did not stop after the context was cancelled")
+	}
+	if client.connContext().Err() == nil {
+		t.Error("Connection context should be cancelled")
+	}
+}
+
+func TestDialCancelledContext(t *testing.T) {
+	t.Setenv("IRC_ADDR", listenIRC(t))
+	t.Setenv("IRC_TLS", "0")
+	client := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.Dial(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRequestResultCancelled(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	id := client.Names("#test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	if _, err := client.GetRequestResultContext(ctx, id, 5*time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Waiting should stop as soon as the context is cancelled")
+	}
+
+	// Close ends the lifetime context, which also ends waits on the connection
+	id = client.Names("#test")
+	client.Close()
+	if _, err := client.GetRequestResult(id, 5*time.Second); err == nil || err.Error() != "connection closed" {
+		t.Errorf("Expected connection closed error, got %v", err)
+	}
+}
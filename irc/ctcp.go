@@ -0,0 +1,137 @@
+package irc
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCTCPSource is the SOURCE reply unless CTCP_SOURCE is set
+const defaultCTCPSource = "https://github.com/h4ks-com/hanna"
+
+// ctcpResponder answers CTCP requests (VERSION, SOURCE, USERINFO, PING,
+// CLIENTINFO) sent to the bot, within a rate limit so a CTCP flood cannot
+// get the bot disconnected for excess flood
+type ctcpResponder struct {
+	replies   map[string]string // request -> reply; disabled requests are missing
+	perMinute int               // replies allowed per minute, 0 for no limit
+
+	mu       sync.Mutex
+	recent   []time.Time // replies sent in the last minute
+	flooding bool        // replies are being dropped, logged once per flood
+	dropped  int64
+}
+
+// loadCTCP reads CTCP_VERSION, CTCP_SOURCE and CTCP_USERINFO; unset uses the
+// default reply and set to an empty value disables the reply
+func (c *Client) loadCTCP() {
+	c.ctcp.replies = map[string]string{"PING": ""}
+	for _, r := range []struct{ request, env, def string }{
+		{"VERSION", "CTCP_VERSION", "Hanna IRC Bot " + Version},
+		{"SOURCE", "CTCP_SOURCE", defaultCTCPSource},
+		{"USERINFO", "CTCP_USERINFO", c.name},
+	} {
+		reply, set := os.LookupEnv(r.env)
+		if !set {
+			reply = r.def
+		}
+		if reply = strings.TrimSpace(reply); reply != "" {
+			c.ctcp.replies[r.request] = reply
+		}
+	}
+	c.ctcp.perMinute = intenv("CTCP_MAX_PER_MINUTE", 10)
+	if c.ctcp.perMinute < 0 {
+		log.Fatalf("FATAL: CTCP_MAX_PER_MINUTE must not be negative")
+	}
+}
+
+// parseCTCP splits a CTCP message into its upper-cased command and argument
+func parseCTCP(message string) (command, arg string, ok bool) {
+	if len(message) < 2 || message[0] != '\x01' {
+		return "", "", false
+	}
+	body := strings.TrimSuffix(message[1:], "\x01")
+	command, arg, _ = strings.Cut(body, " ")
+	return strings.ToUpper(command), arg, command != ""
+}
+
+// handleCTCP answers a CTCP request and reports whether message was one;
+// ACTION is a regular message and is left to the caller
+func (c *Client) handleCTCP(sender, target, message string) bool {
+	command, arg, ok := parseCTCP(message)
+	if !ok || command == "ACTION" {
+		return false
+	}
+	log.Printf("CTCP %s from %s in %s", command, sender, target)
+
+	var reply string
+	switch command {
+	case "CLIENTINFO":
+		reply = c.ctcpClientInfo()
+	case "PING":
+		reply = arg
+	default:
+		var enabled bool
+		if reply, enabled = c.ctcp.replies[command]; !enabled {
+			return true
+		}
+	}
+	if !c.ctcp.allow(time.Now()) {
+		return true
+	}
+	if reply == "" {
+		c.rawf("NOTICE %s :\x01%s\x01", sender, command)
+	} else {
+		c.rawf("NOTICE %s :\x01%s %s\x01", sender, command, reply)
+	}
+	return true
+}
+
+// ctcpClientInfo lists the requests the bot answers
+func (c *Client) ctcpClientInfo() string {
+	commands := []string{"CLIENTINFO"}
+	for command := range c.ctcp.replies {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	return strings.Join(commands, " ")
+}
+
+// allow reports whether another reply fits the per-minute limit
+func (r *ctcpResponder) allow(now time.Time) bool {
+	if r.perMinute == 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.recent[:0]
+	for _, t := range r.recent {
+		if now.Sub(t) < time.Minute {
+			kept = append(kept, t)
+		}
+	}
+	r.recent = kept
+	if len(r.recent) >= r.perMinute {
+		r.dropped++
+		if !r.flooding {
+			r.flooding = true
+			log.Printf("CTCP flood: more than %d requests per minute, not replying", r.perMinute)
+		}
+		return false
+	}
+	if r.flooding {
+		r.flooding = false
+		log.Printf("CTCP flood over, %d requests went unanswered so far", r.dropped)
+	}
+	r.recent = append(r.recent, now)
+	return true
+}
+
+func (r *ctcpResponder) droppedCount() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
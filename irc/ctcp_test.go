@@ -0,0 +1,95 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCTCPReplies(t *testing.T) {
+	t.Setenv("CTCP_VERSION", "CustomBot 1.0")
+	t.Setenv("CTCP_SOURCE", "")
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	for _, msg := range []string{"\x01VERSION\x01", "\x01SOURCE\x01", "\x01PING 12345\x01", "\x01CLIENTINFO\x01"} {
+		client.handleLine(":alice!a@host PRIVMSG TestBot :" + msg)
+	}
+	want := []string{
+		"NOTICE alice :\x01VERSION CustomBot 1.0\x01",
+		"NOTICE alice :\x01PING 12345\x01",
+		"NOTICE alice :\x01CLIENTINFO CLIENTINFO PING USERINFO VERSION\x01",
+	}
+	if strings.Join(sent, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected replies %q, got %q", want, sent)
+	}
+}
+
+func TestCTCPFlood(t *testing.T) {
+	t.Setenv("CTCP_MAX_PER_MINUTE", "3")
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	for i := 0; i < 10; i++ {
+		client.handleLine(":flooder!f@host PRIVMSG #test :\x01VERSION\x01")
+	}
+	if len(sent) != 3 {
+		t.Errorf("Expected 3 replies within the limit, got %d", len(sent))
+	}
+	if n := client.ctcp.droppedCount(); n != 7 {
+		t.Errorf("Expected 7 dropped requests, got %d", n)
+	}
+}
+
+func TestCTCPNotAMention(t *testing.T) {
+	received := newTriggerRecorder(t, "mention")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :\x01PING TestBot\x01")
+	expectNoTrigger(t, received)
+}
+
+func TestActions(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg", "action")
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":alice!a@host PRIVMSG #test :\x01ACTION waves\x01")
+	if p := expectTrigger(t, received); p.EventType != "action" || p.Message != "waves" || p.Sender != "alice" {
+		t.Errorf("Expected an action event with its text, got %+v", p)
+	}
+	expectNoTrigger(t, received)
+
+	api := client.CreateAPI("token")
+	req := httptest.NewRequest("POST", "/api/action", strings.NewReader(`{"target":"#test","message":"**waves** back\nand smiles","format":"markdown"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	want := []string{"PRIVMSG #test :\x01ACTION \x02waves\x02 back\x01", "PRIVMSG #test :\x01ACTION and smiles\x01"}
+	if strings.Join(sent, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, sent)
+	}
+
+	// Long actions are split into several that each keep the CTCP framing
+	sent = nil
+	client.Action("#test", strings.Repeat("x", 1000))
+	if len(sent) < 2 {
+		t.Fatalf("Expected the action to be split, got %d lines", len(sent))
+	}
+	for _, line := range sent {
+		if !strings.HasPrefix(line, "PRIVMSG #test :\x01ACTION x") || !strings.HasSuffix(line, "\x01") {
+			t.Errorf("Expected a complete action, got %q", line)
+		}
+	}
+}
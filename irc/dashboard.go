@@ -0,0 +1,18 @@
+package irc
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The dashboard is a static page; it asks for the API token in the browser
+// and reads everything through the authenticated JSON endpoints.
+//
+//go:embed ui
+var dashboardFiles embed.FS
+
+func dashboardHandler() http.Handler {
+	files, _ := fs.Sub(dashboardFiles, "ui")
+	return http.StripPrefix("/ui/", http.FileServerFS(files))
+}
@@ -0,0 +1,66 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	api := NewClient().CreateAPI("token")
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/ui", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/ui/" {
+		t.Errorf("Expected a redirect to /ui/, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "<title>Hanna Dashboard</title>") {
+		t.Errorf("Expected the dashboard page, got %d", rec.Code)
+	}
+}
+
+func TestTriggerHealth(t *testing.T) {
+	status := 500
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"n8n":{"url":%q,"events":["mention"]}}}`, srv.URL+"/hook?secret=x"))
+	client := NewClient()
+	api := client.CreateAPI("token")
+
+	health := func() TriggerHealth {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/triggers", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		var resp struct{ Endpoints []TriggerHealth }
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Endpoints) != 1 {
+			t.Fatalf("Unexpected response %d: %v", rec.Code, err)
+		}
+		return resp.Endpoints[0]
+	}
+
+	if h := health(); !h.Healthy || h.Successes+h.Failures != 0 || h.URL != srv.URL+"/hook" {
+		t.Errorf("Unexpected health before any delivery: %+v", h)
+	}
+
+	endpoint := client.triggerConfig.Endpoints["n8n"]
+	client.callTriggerEndpoint("n8n", endpoint, TriggerPayload{EventType: "mention"})
+	if h := health(); h.Healthy || h.Failures != 1 || h.LastStatus != 500 {
+		t.Errorf("Expected a failed delivery, got %+v", h)
+	}
+
+	status = 200
+	client.callTriggerEndpoint("n8n", endpoint, TriggerPayload{EventType: "mention"})
+	if h := health(); !h.Healthy || h.Successes != 1 || h.Failures != 1 || h.LastSuccess == 0 {
+		t.Errorf("Expected a recovered endpoint, got %+v", h)
+	}
+}
@@ -0,0 +1,245 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Log levels, most verbose first. debug logs every IRC line and message,
+// info the rest, and error only lines reporting errors and failures.
+const (
+	LogDebug int32 = iota
+	LogInfo
+	LogError
+)
+
+var logLevelNames = []string{"debug", "info", "error"}
+
+var logLevel atomic.Int32 // LogDebug by default, which logs everything as before levels existed
+
+// errorLine picks out the log lines kept at the error level
+var errorLine = regexp.MustCompile(`(?i)error|fail|fatal|panic|refus|invalid|timed out`)
+
+// SetLogLevel sets the log verbosity by name: debug, info or error
+func SetLogLevel(name string) error {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			logLevel.Store(int32(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q (use debug, info or error)", name)
+}
+
+// LogLevel returns the name of the current log level
+func LogLevel() string {
+	return logLevelNames[logLevel.Load()]
+}
+
+// debugf logs at the debug level, for lines logged for every IRC line or
+// message
+func debugf(format string, args ...any) {
+	if logLevel.Load() <= LogDebug {
+		log.Printf(format, args...)
+	}
+}
+
+// levelWriter drops log output below the error level when it is set
+type levelWriter struct{ w io.Writer }
+
+// LevelWriter wraps the log output so the error level keeps only errors;
+// debugf already leaves out debug lines above the debug level
+func LevelWriter(w io.Writer) io.Writer {
+	return levelWriter{w}
+}
+
+func (l levelWriter) Write(p []byte) (int, error) {
+	if logLevel.Load() >= LogError && !errorLine.Match(p) {
+		return len(p), nil
+	}
+	return l.w.Write(p)
+}
+
+// handleLogLevel reads or changes the log level at runtime:
+// GET /api/debug/loglevel, PUT {"level": "debug"}
+func (a *API) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var in struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, 400, codeInvalidRequest, "level required")
+			return
+		}
+		old := LogLevel()
+		if err := SetLogLevel(in.Level); err != nil {
+			writeError(w, 400, codeInvalidRequest, err.Error())
+			return
+		}
+		log.Printf("Log level changed from %s to %s through the API", old, LogLevel())
+	default:
+		writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, 200, map[string]string{"level": LogLevel()})
+}
+
+// RawLine is an IRC line seen by the raw traffic tap
+type RawLine struct {
+	Time time.Time `json:"time"`
+	Dir  string    `json:"dir"` // in or out
+	Line string    `json:"line"`
+}
+
+// rawTap copies the IRC lines read and written to /api/debug/raw streams.
+// Slow readers miss lines rather than hold up the connection.
+type rawTap struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]chan RawLine
+	active atomic.Int32 // number of subscribers, checked without the lock
+}
+
+func (t *rawTap) subscribe(size int) (<-chan RawLine, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subs == nil {
+		t.subs = make(map[int]chan RawLine)
+	}
+	t.nextID++
+	id := t.nextID
+	ch := make(chan RawLine, size)
+	t.subs[id] = ch
+	t.active.Add(1)
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subs[id]; ok {
+			delete(t.subs, id)
+			t.active.Add(-1)
+		}
+	}
+}
+
+// publish sends a line to every tap; outgoing secrets are blanked out as in
+// recordings
+func (t *rawTap) publish(dir, line string) {
+	if t.active.Load() == 0 {
+		return
+	}
+	if dir == "out" {
+		line = redactOutgoing(line)
+	}
+	raw := RawLine{Time: time.Now(), Dir: dir, Line: line}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ch := range t.subs {
+		select {
+		case ch <- raw:
+		default:
+		}
+	}
+}
+
+// rawFilter selects tapped lines by direction, command and text
+type rawFilter struct {
+	dir      string
+	commands map[string]bool
+	match    string // lowercased substring
+}
+
+func (f rawFilter) matches(l RawLine) bool {
+	if f.dir != "" && f.dir != l.Dir {
+		return false
+	}
+	if len(f.commands) > 0 && !f.commands[rawCommand(l.Line)] {
+		return false
+	}
+	return f.match == "" || strings.Contains(strings.ToLower(l.Line), f.match)
+}
+
+// rawCommand returns the command or numeric of a line, after its tags and
+// prefix
+func rawCommand(line string) string {
+	fields := strings.Fields(line)
+	for len(fields) > 0 && (strings.HasPrefix(fields[0], "@") || strings.HasPrefix(fields[0], ":")) {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// handleRawTap streams raw IRC lines as server-sent events:
+// GET /api/debug/raw?dir=in&commands=PRIVMSG,JOIN&match=alice
+func (a *API) handleRawTap(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, 500, codeInternal, "streaming not supported")
+		return
+	}
+	q := r.URL.Query()
+	filter := rawFilter{dir: strings.ToLower(q.Get("dir")), match: strings.ToLower(q.Get("match"))}
+	if filter.dir != "" && filter.dir != "in" && filter.dir != "out" {
+		writeError(w, 400, codeInvalidRequest, "dir must be in or out")
+		return
+	}
+	for _, cmd := range strings.Split(q.Get("commands"), ",") {
+		if cmd = strings.ToUpper(strings.TrimSpace(cmd)); cmd != "" {
+			if filter.commands == nil {
+				filter.commands = make(map[string]bool)
+			}
+			filter.commands[cmd] = true
+		}
+	}
+	closeStream, ok := a.bot.drain.openStream()
+	if !ok {
+		writeError(w, 503, codeUnavailable, "shutting down")
+		return
+	}
+	defer closeStream()
+
+	lines, unsubscribe := a.bot.rawTap.subscribe(1024)
+	defer unsubscribe()
+	log.Printf("Raw traffic tap opened from %s", r.RemoteAddr)
+	defer log.Printf("Raw traffic tap from %s closed", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.bot.drain.done():
+			data, _ := json.Marshal(shutdownEvent())
+			fmt.Fprintf(w, "event: shutting_down\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		case l := <-lines:
+			if !filter.matches(l) {
+				continue
+			}
+			data, err := json.Marshal(l)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", l.Dir, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLevelAPI(t *testing.T) {
+	t.Cleanup(func() { SetLogLevel("debug") })
+	api := NewClient().CreateAPI("token")
+	call := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/debug/loglevel", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := call("PUT", `{"level":"error"}`); rec.Code != 200 || LogLevel() != "error" {
+		t.Fatalf("Expected the level changed to error, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := call("PUT", `{"level":"loud"}`); rec.Code != 400 || LogLevel() != "error" {
+		t.Errorf("Expected 400 for an unknown level, got %d", rec.Code)
+	}
+	if rec := call("GET", ""); !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("Expected the current level, got %s", rec.Body.String())
+	}
+
+	var out strings.Builder
+	w := LevelWriter(&out)
+	w.Write([]byte("Joined channel: #test\n"))
+	w.Write([]byte("Error writing to server: broken pipe\n"))
+	if out.String() != "Error writing to server: broken pipe\n" {
+		t.Errorf("Expected only the error line at the error level, got %q", out.String())
+	}
+}
+
+func TestRawTap(t *testing.T) {
+	client := NewClient()
+	srv := httptest.NewServer(client.CreateAPI("token"))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/debug/raw?commands=PRIVMSG,PASS", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	client.rawTap.publish("in", ":server PING :x")
+	client.rawTap.publish("out", "PASS hunter2")
+	client.rawTap.publish("in", "@time=x :alice!a@host PRIVMSG #dev :hi")
+
+	scanner := bufio.NewScanner(resp.Body)
+	var data []string
+	for len(data) < 2 && scanner.Scan() {
+		if line, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			data = append(data, line)
+		}
+	}
+	if len(data) != 2 || !strings.Contains(data[0], `"line":"PASS ***"`) || !strings.Contains(data[1], "PRIVMSG #dev :hi") {
+		t.Errorf("Expected the filtered lines with the password blanked out, got %q", data)
+	}
+}
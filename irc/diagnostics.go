@@ -0,0 +1,140 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handlePprof serves net/http/pprof under /api/debug/pprof/, behind the
+// debug scope. go tool pprof cannot send the bearer token, so profiles are
+// fetched with curl and opened from the file.
+func (a *API) handlePprof(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/api/debug/pprof/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		// Index serves the named profiles below /debug/pprof/
+		http.StripPrefix("/api", http.HandlerFunc(pprof.Index)).ServeHTTP(w, r)
+	}
+}
+
+// GoroutineGroup is a set of goroutines with the same stack
+type GoroutineGroup struct {
+	Count int      `json:"count"`
+	Stack []string `json:"stack"` // functions, innermost first
+}
+
+// goroutineGroups returns the running goroutines grouped by stack, largest
+// group first
+func goroutineGroups() ([]GoroutineGroup, error) {
+	var buf bytes.Buffer
+	if err := runtimepprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil, err
+	}
+	// Groups look like:
+	// 3 @ 0x43e4ce 0x44f2b8 ...
+	// #	0x4a1b2c	hanna/irc.(*Client).readLoop+0x4c	/src/irc/conn.go:211
+	var groups []GoroutineGroup
+	scanner := bufio.NewScanner(&buf)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if count, _, ok := strings.Cut(line, " @ "); ok {
+			if n, err := strconv.Atoi(count); err == nil {
+				groups = append(groups, GoroutineGroup{Count: n})
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "#\t"); ok && len(groups) > 0 {
+			fields := strings.Split(rest, "\t")
+			if len(fields) >= 3 {
+				fn, _, _ := strings.Cut(strings.TrimSpace(fields[1]), "+0x")
+				g := &groups[len(groups)-1]
+				g.Stack = append(g.Stack, fn+" "+strings.TrimSpace(fields[2]))
+			}
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups, scanner.Err()
+}
+
+// handleGoroutines lists the goroutines grouped by stack, or the full dump
+// of every goroutine with ?format=text:
+// GET /api/debug/goroutines
+func (a *API) handleGoroutines(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+		return
+	}
+	groups, err := goroutineGroups()
+	if err != nil {
+		writeError(w, 500, codeInternal, err.Error())
+		return
+	}
+	writeJSON(w, 200, map[string]any{
+		"count":  runtime.NumGoroutine(),
+		"groups": groups,
+	})
+}
+
+// MemoryStats is the part of runtime.MemStats worth watching for leaks
+type MemoryStats struct {
+	Goroutines   int       `json:"goroutines"`
+	HeapAlloc    uint64    `json:"heap_alloc_bytes"`  // bytes of allocated heap objects
+	HeapInuse    uint64    `json:"heap_inuse_bytes"`  // heap spans in use
+	HeapObjects  uint64    `json:"heap_objects"`      // live heap objects
+	Sys          uint64    `json:"sys_bytes"`         // memory obtained from the OS
+	TotalAlloc   uint64    `json:"total_alloc_bytes"` // allocated since startup, freed or not
+	StackInuse   uint64    `json:"stack_inuse_bytes"` // goroutine stacks
+	NumGC        uint32    `json:"num_gc"`
+	PauseTotalMs float64   `json:"gc_pause_total_ms"`
+	LastGC       time.Time `json:"last_gc,omitzero"`
+	Uptime       string    `json:"uptime"`
+	Connections  int64     `json:"connections"` // to the IRC server since startup
+	ReadLoops    int32     `json:"read_loops"`  // IRC read loops running, at most one
+}
+
+// MemoryStats reads the runtime memory statistics
+func (c *Client) MemoryStats() MemoryStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := MemoryStats{
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		TotalAlloc:   m.TotalAlloc,
+		StackInuse:   m.StackInuse,
+		NumGC:        m.NumGC,
+		PauseTotalMs: float64(m.PauseTotalNs) / 1e6,
+		Uptime:       time.Since(c.startTime).Round(time.Second).String(),
+		Connections:  c.connections.Load(),
+		ReadLoops:    c.readLoops.Load(),
+	}
+	if m.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC))
+	}
+	return stats
+}
+
+// handleMemory reports memory statistics:
+// GET /api/debug/memory
+func (a *API) handleMemory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, a.bot.MemoryStats())
+}
@@ -0,0 +1,49 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsEndpoints(t *testing.T) {
+	api := NewClient().CreateAPI("token")
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var goroutines struct {
+		Count  int              `json:"count"`
+		Groups []GoroutineGroup `json:"groups"`
+	}
+	rec := get("/api/debug/goroutines")
+	if err := json.NewDecoder(rec.Body).Decode(&goroutines); err != nil || goroutines.Count == 0 || len(goroutines.Groups) == 0 || len(goroutines.Groups[0].Stack) == 0 {
+		t.Errorf("Expected goroutines grouped by stack, got %d %+v %v", rec.Code, goroutines, err)
+	}
+	if rec := get("/api/debug/goroutines?format=text"); !strings.Contains(rec.Body.String(), "goroutine ") {
+		t.Errorf("Expected a full goroutine dump, got %q", rec.Body.String())
+	}
+
+	var mem MemoryStats
+	if err := json.NewDecoder(get("/api/debug/memory").Body).Decode(&mem); err != nil || mem.HeapAlloc == 0 || mem.Goroutines == 0 {
+		t.Errorf("Expected memory stats, got %+v %v", mem, err)
+	}
+
+	if rec := get("/api/debug/pprof/"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("Expected the pprof index, got %d", rec.Code)
+	}
+	if rec := get("/api/debug/pprof/heap?debug=1"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "heap profile") {
+		t.Errorf("Expected the heap profile, got %d %.100s", rec.Code, rec.Body.String())
+	}
+	req := httptest.NewRequest("GET", "/api/debug/pprof/heap", nil)
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 401 {
+		t.Errorf("Expected profiles to require the token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,384 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	discordAPI     = "https://discord.com/api/v10"
+	discordGateway = "wss://gateway.discord.gg/?v=10&encoding=json"
+
+	// GUILD_MESSAGES and MESSAGE_CONTENT (privileged, must be enabled for the
+	// bot in the developer portal)
+	discordIntents = 1<<9 | 1<<15
+)
+
+// DiscordConfig bridges IRC channels with Discord channels
+type DiscordConfig struct {
+	Token    string            `json:"token"`
+	Channels map[string]string `json:"channels"`           // IRC channel -> Discord channel ID
+	Webhooks map[string]string `json:"webhooks,omitempty"` // IRC channel -> Discord webhook URL, to post under the IRC nick
+	Events   []string          `json:"events,omitempty"`   // IRC events relayed to Discord (default privmsg)
+	LoopProtection
+}
+
+// discordBridge relays messages both ways: IRC events are posted through the
+// REST API (or a channel webhook) and Discord messages arrive on the gateway
+type discordBridge struct {
+	client     ircRelay
+	cfg        DiscordConfig
+	apiBase    string
+	gatewayURL string
+	http       *http.Client
+
+	toDiscord map[string]string // lowercased IRC channel -> Discord channel ID
+	toIRC     map[string]string // Discord channel ID -> IRC channel
+	webhooks  map[string]string // lowercased IRC channel -> webhook URL
+
+	queue  *bridgeQueue
+	selfID atomic.Value // string, the bot's Discord user ID
+}
+
+func (c *Client) loadDiscordBridge() {
+	cfg := c.triggerConfig.Discord
+	if cfg == nil {
+		return
+	}
+	if cfg.Token == "" || len(cfg.Channels) == 0 {
+		log.Fatalf("FATAL: Discord bridge requires a token and at least one channel")
+	}
+	d := &discordBridge{
+		client:     c,
+		cfg:        *cfg,
+		apiBase:    discordAPI,
+		gatewayURL: discordGateway,
+		http:       &http.Client{Timeout: 15 * time.Second},
+		toDiscord:  make(map[string]string),
+		toIRC:      make(map[string]string),
+		webhooks:   make(map[string]string),
+	}
+	d.cfg.Events = validateBridgeEvents("Discord", cfg.Events)
+	d.cfg.validate("Discord")
+	for ircChan, id := range cfg.Channels {
+		d.toDiscord[strings.ToLower(ircChan)] = id
+		d.toIRC[id] = ircChan
+	}
+	for ircChan, url := range cfg.Webhooks {
+		d.webhooks[strings.ToLower(ircChan)] = url
+	}
+	d.queue = newBridgeQueue("Discord", d.post)
+	d.selfID.Store("")
+	c.discord = d
+	c.bus.Subscribe("discord", d.relayEvent)
+}
+
+// relayEvent queues bridged IRC events for posting
+func (d *discordBridge) relayEvent(e Event) {
+	bridged := func(ch string) bool { return d.toDiscord[strings.ToLower(ch)] != "" }
+	for _, m := range bridgeMessages(e, d.cfg.Events, d.cfg.LoopProtection, bridged) {
+		d.queue.push(d.client.context(), m)
+	}
+}
+
+// post sends one message, through the channel webhook when there is one so
+// the IRC nick shows as the author
+func (d *discordBridge) post(ctx context.Context, m bridgeMessage) error {
+	text := IRCToMarkdown(m.Text)
+	// Never let relayed text ping @everyone, roles or users
+	body := map[string]any{"allowed_mentions": map[string]any{"parse": []string{}}}
+	url := d.webhooks[strings.ToLower(m.Channel)]
+	webhook := url != ""
+	switch {
+	case m.Nick == "":
+		body["content"] = "_" + text + "_"
+	case webhook:
+		body["username"] = truncateUTF8(m.Nick, 80)
+		body["content"] = text
+		if m.Action {
+			body["content"] = "_" + text + "_"
+		}
+	case m.Action:
+		body["content"] = fmt.Sprintf("\\* **%s** %s", m.Nick, text)
+	default:
+		body["content"] = fmt.Sprintf("**<%s>** %s", m.Nick, text)
+	}
+	if !webhook {
+		url = d.apiBase + "/channels/" + d.toDiscord[strings.ToLower(m.Channel)] + "/messages"
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if !webhook {
+			req.Header.Set("Authorization", "Bot "+d.cfg.Token)
+		}
+		resp, err := d.http.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			json.Unmarshal(respBody, &limit)
+			if !sleepContext(ctx, time.Duration(limit.RetryAfter*float64(time.Second))+50*time.Millisecond) {
+				return ctx.Err()
+			}
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		}
+		return nil
+	}
+	return fmt.Errorf("still rate limited after retries")
+}
+
+// run keeps a gateway session open until ctx is cancelled
+func (d *discordBridge) run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := d.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("Discord bridge: gateway session ended: %v (reconnecting in %s)", err, backoff)
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+		backoff = min(backoff*2, 2*time.Minute)
+	}
+}
+
+// discordFrame is a gateway payload
+type discordFrame struct {
+	Op   int             `json:"op"`
+	Data json.RawMessage `json:"d"`
+	Seq  *int64          `json:"s,omitempty"`
+	Type string          `json:"t,omitempty"`
+}
+
+type discordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Bot        bool   `json:"bot"`
+	Member     *struct {
+		Nick string `json:"nick"`
+	} `json:"member,omitempty"`
+}
+
+// displayName prefers the server nickname, then the global display name
+func (u discordUser) displayName(member string) string {
+	switch {
+	case member != "":
+		return member
+	case u.Member != nil && u.Member.Nick != "":
+		return u.Member.Nick
+	case u.GlobalName != "":
+		return u.GlobalName
+	}
+	return u.Username
+}
+
+type discordMessage struct {
+	ChannelID string        `json:"channel_id"`
+	Content   string        `json:"content"`
+	WebhookID string        `json:"webhook_id"`
+	Author    discordUser   `json:"author"`
+	Mentions  []discordUser `json:"mentions"`
+	Member    *struct {
+		Nick string `json:"nick"`
+	} `json:"member"`
+	Attachments []struct {
+		URL      string `json:"url"`
+		Filename string `json:"filename"`
+	} `json:"attachments"`
+}
+
+var discordMentionRe = regexp.MustCompile(`<@!?(\d+)>`)
+
+// session runs one gateway connection: identify, heartbeat and dispatch
+func (d *discordBridge) session(ctx context.Context) error {
+	t, err := parseTransport(d.gatewayURL, true, false, &net.Dialer{Timeout: 30 * time.Second})
+	if err != nil {
+		return err
+	}
+	conn, err := t.Dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	context.AfterFunc(sessionCtx, func() { conn.Close() })
+
+	// Gateway payloads never contain raw newlines, so each is one line
+	r := bufio.NewReader(conn)
+	send := func(op int, data any) error {
+		b, err := json.Marshal(map[string]any{"op": op, "d": data})
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(append(b, '\n'))
+		return err
+	}
+	read := func() (discordFrame, error) {
+		var f discordFrame
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return f, err
+		}
+		return f, json.Unmarshal(line, &f)
+	}
+
+	hello, err := read()
+	if err != nil {
+		return err
+	}
+	var hb struct {
+		Interval int64 `json:"heartbeat_interval"`
+	}
+	if hello.Op != 10 || json.Unmarshal(hello.Data, &hb) != nil || hb.Interval <= 0 {
+		return fmt.Errorf("expected hello, got op %d", hello.Op)
+	}
+
+	var seq atomic.Int64
+	seq.Store(-1)
+	heartbeat := func() error {
+		var s any // null until the first dispatch
+		if n := seq.Load(); n >= 0 {
+			s = n
+		}
+		return send(1, s)
+	}
+	var acked atomic.Bool
+	acked.Store(true)
+	go func() {
+		ticker := time.NewTicker(time.Duration(hb.Interval) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sessionCtx.Done():
+				return
+			case <-ticker.C:
+				if !acked.Swap(false) {
+					log.Printf("Discord bridge: heartbeat not acknowledged, reconnecting")
+					cancel()
+					return
+				}
+				if err := heartbeat(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	identify := map[string]any{
+		"token":      d.cfg.Token,
+		"intents":    discordIntents,
+		"properties": map[string]string{"os": "linux", "browser": "hanna", "device": "hanna"},
+	}
+	if err := send(2, identify); err != nil {
+		return err
+	}
+
+	for {
+		f, err := read()
+		if err != nil {
+			return err
+		}
+		if f.Seq != nil {
+			seq.Store(*f.Seq)
+		}
+		switch f.Op {
+		case 0:
+			d.dispatch(f.Type, f.Data)
+		case 1:
+			heartbeat()
+		case 7:
+			return fmt.Errorf("reconnect requested")
+		case 9:
+			return fmt.Errorf("invalid session")
+		case 11:
+			acked.Store(true)
+		}
+	}
+}
+
+func (d *discordBridge) dispatch(event string, data json.RawMessage) {
+	switch event {
+	case "READY":
+		var ready struct {
+			User discordUser `json:"user"`
+		}
+		if json.Unmarshal(data, &ready) == nil {
+			d.selfID.Store(ready.User.ID)
+			log.Printf("Discord bridge: connected as %s", ready.User.Username)
+		}
+	case "MESSAGE_CREATE":
+		var m discordMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Printf("Discord bridge: invalid message: %v", err)
+			return
+		}
+		d.relayMessage(m)
+	}
+}
+
+// relayMessage posts a Discord message to its IRC channel. Messages from bots
+// and webhooks (including the bridge's own posts) are ignored.
+func (d *discordBridge) relayMessage(m discordMessage) {
+	ircChan, ok := d.toIRC[m.ChannelID]
+	if !ok || m.Author.Bot || m.WebhookID != "" || m.Author.ID == d.selfID.Load().(string) {
+		return
+	}
+	member := ""
+	if m.Member != nil {
+		member = m.Member.Nick
+	}
+	names := make(map[string]string, len(m.Mentions))
+	for _, u := range m.Mentions {
+		names[u.ID] = u.displayName("")
+	}
+	text := discordMentionRe.ReplaceAllStringFunc(m.Content, func(s string) string {
+		if name, ok := names[discordMentionRe.FindStringSubmatch(s)[1]]; ok {
+			return "@" + name
+		}
+		return s
+	})
+	text = MarkdownToIRC(text)
+	for _, a := range m.Attachments {
+		if text != "" {
+			text += "\n"
+		}
+		text += a.URL
+	}
+	d.client.relayToIRC("Discord", ircChan, m.Author.displayName(member), text, d.cfg.LoopProtection)
+}
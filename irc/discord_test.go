@@ -0,0 +1,121 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDiscordClient(t *testing.T, config string) *Client {
+	t.Helper()
+	t.Setenv("TRIGGER_CONFIG", config)
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestDiscordRelayFromIRC(t *testing.T) {
+	type post struct {
+		path, auth string
+		body       map[string]any
+	}
+	posts := make(chan post, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		posts <- post{r.URL.Path, r.Header.Get("Authorization"), body}
+	}))
+	defer srv.Close()
+
+	client := newDiscordClient(t, `{"discord":{"token":"tok","channels":{"#Test":"123","#hooked":"456"},
+		"webhooks":{"#hooked":"`+srv.URL+`/webhooks/1/abc"}}}`)
+	client.discord.apiBase = srv.URL
+
+	client.handleLine(":alice!a@host JOIN #test") // not relayed by default
+	client.handleLine(":alice!a@host PRIVMSG #test :hello \x02world\x02")
+	client.handleLine(":alice!a@host PRIVMSG #other :not bridged")
+	client.handleLine(":bob!b@host PRIVMSG #hooked :\x01ACTION waves\x01")
+
+	expect := func() post {
+		t.Helper()
+		select {
+		case p := <-posts:
+			return p
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for a Discord post")
+			return post{}
+		}
+	}
+	p := expect()
+	if p.path != "/channels/123/messages" || p.auth != "Bot tok" || p.body["content"] != "**<alice>** hello **world**" {
+		t.Errorf("Unexpected post %+v", p)
+	}
+	if mentions, _ := p.body["allowed_mentions"].(map[string]any); mentions == nil {
+		t.Error("Posts should disable mentions")
+	}
+	p = expect()
+	if p.path != "/webhooks/1/abc" || p.auth != "" || p.body["username"] != "bob" || p.body["content"] != "_waves_" {
+		t.Errorf("Unexpected webhook post %+v", p)
+	}
+	select {
+	case p := <-posts:
+		t.Errorf("Unexpected extra post %+v", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDiscordRelayToIRC(t *testing.T) {
+	identified := make(chan string, 1)
+	srv := serveWebSocket(t, func(conn net.Conn, r *bufio.Reader) {
+		writeServerFrame(conn, wsText, `{"op":10,"d":{"heartbeat_interval":45000}}`)
+		_, identify, err := readClientFrame(r)
+		if err != nil {
+			return
+		}
+		identified <- identify
+		writeServerFrame(conn, wsText, `{"op":0,"s":1,"t":"READY","d":{"user":{"id":"1","username":"hanna"}}}`)
+		writeServerFrame(conn, wsText, `{"op":0,"s":2,"t":"MESSAGE_CREATE","d":{"channel_id":"123","content":"ignored","author":{"id":"9","username":"otherbot","bot":true}}}`)
+		writeServerFrame(conn, wsText, `{"op":0,"s":3,"t":"MESSAGE_CREATE","d":{"channel_id":"123","content":"hi <@2> **there**",`+
+			`"author":{"id":"3","username":"bob","global_name":"Bobby"},"member":{"nick":"Bob"},`+
+			`"mentions":[{"id":"2","username":"alice"}],"attachments":[{"url":"https://cdn.example/cat.png","filename":"cat.png"}]}}`)
+		io.Copy(io.Discard, r)
+	})
+
+	client := newDiscordClient(t, `{"discord":{"token":"tok","channels":{"#test":"123"}}}`)
+	markConnected(client)
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+	client.discord.gatewayURL = "ws://" + strings.TrimPrefix(srv.URL, "http://") + "/?v=10&encoding=json"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.discord.run(ctx)
+
+	select {
+	case identify := <-identified:
+		if !strings.Contains(identify, `"op":2`) || !strings.Contains(identify, `"token":"tok"`) {
+			t.Errorf("Unexpected identify payload %s", identify)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Bridge did not identify")
+	}
+	for _, want := range []string{"PRIVMSG #test :<Bob> hi @alice \x02there\x02", "PRIVMSG #test :<Bob> https://cdn.example/cat.png"} {
+		select {
+		case line := <-sent:
+			if line != want {
+				t.Errorf("Expected %q, got %q", want, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package irc
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDMIdle = time.Hour
+
+// DMSession is an open private conversation (query window) with one user
+type DMSession struct {
+	Nick         string    `json:"nick"`
+	Account      string    `json:"account,omitempty"`
+	SessionID    string    `json:"session_id"` // sessionId of the conversation's trigger payloads
+	OpenedAt     time.Time `json:"opened_at"`
+	LastActivity time.Time `json:"last_activity"`
+	Received     int       `json:"received"` // messages from the user
+	Sent         int       `json:"sent"`     // messages from the bot
+}
+
+// dmTracker keeps the open private conversations. A conversation ends after
+// DM_SESSION_IDLE_MINUTES without messages either way; the next message
+// starts a new one with a new session ID.
+type dmTracker struct {
+	idle     time.Duration
+	mentions string // DM_MENTIONS: all, nick or off
+
+	mu       sync.Mutex
+	sessions map[string]*DMSession // lowercased nick -> session
+}
+
+func (c *Client) loadDMSessions() {
+	c.dm.sessions = make(map[string]*DMSession)
+	c.dm.idle = time.Duration(max(intenv("DM_SESSION_IDLE_MINUTES", int(defaultDMIdle/time.Minute)), 1)) * time.Minute
+	switch mode := strings.ToLower(getenv("DM_MENTIONS", "all")); mode {
+	case "all", "nick", "off":
+		c.dm.mentions = mode
+	default:
+		log.Fatalf("FATAL: Invalid DM_MENTIONS %q (use all, nick or off)", mode)
+	}
+}
+
+// isDM reports whether a message to target is a private message to the bot
+func (c *Client) isDM(target string) bool {
+	return strings.EqualFold(target, c.Nick())
+}
+
+// dmSession returns the open conversation with nick, starting one if create
+// is set. Expired conversations are dropped first.
+func (c *Client) dmSession(nick string, create bool) *DMSession {
+	now := time.Now()
+	key := strings.ToLower(nick)
+	s := c.dm.sessions[key]
+	if s != nil && now.Sub(s.LastActivity) > c.dm.idle {
+		delete(c.dm.sessions, key)
+		s = nil
+	}
+	if s == nil && create {
+		s = &DMSession{
+			Nick:      nick,
+			SessionID: "pm:" + key + ":" + strconv.FormatInt(now.Unix(), 10),
+			OpenedAt:  now,
+		}
+		c.dm.sessions[key] = s
+		log.Printf("Private conversation with %s started", nick)
+	}
+	return s
+}
+
+// dmReceived counts a private message from nick and returns the session ID
+// of the conversation
+func (c *Client) dmReceived(nick string, tags map[string]string) string {
+	account := c.senderAccount(nick, tags)
+	c.dm.mu.Lock()
+	defer c.dm.mu.Unlock()
+	s := c.dmSession(nick, true)
+	s.Received++
+	s.LastActivity = time.Now()
+	if account != "" {
+		s.Account = account
+	}
+	return s.SessionID
+}
+
+// dmSent counts a message the bot sent to a user, starting a conversation
+// when the bot writes first
+func (c *Client) dmSent(target string) {
+	if isChannelName(target) || target == "" {
+		return
+	}
+	c.dm.mu.Lock()
+	defer c.dm.mu.Unlock()
+	s := c.dmSession(target, true)
+	s.Sent++
+	s.LastActivity = time.Now()
+}
+
+// dmSessionID returns the session ID of the open conversation with nick, or
+// "" if there is none
+func (c *Client) dmSessionID(nick string) string {
+	c.dm.mu.Lock()
+	defer c.dm.mu.Unlock()
+	if s := c.dmSession(nick, false); s != nil {
+		return s.SessionID
+	}
+	return ""
+}
+
+// renameDM follows a user's nick change into their conversation
+func (c *Client) renameDM(e Event) {
+	if e.Type != "nick" || e.Self || e.Replayed {
+		return
+	}
+	c.dm.mu.Lock()
+	defer c.dm.mu.Unlock()
+	old := strings.ToLower(e.Sender)
+	if s, ok := c.dm.sessions[old]; ok {
+		delete(c.dm.sessions, old)
+		s.Nick = e.Nick
+		c.dm.sessions[strings.ToLower(e.Nick)] = s
+	}
+}
+
+// DMSessions returns the open private conversations, most recent first
+func (c *Client) DMSessions() []DMSession {
+	c.dm.mu.Lock()
+	defer c.dm.mu.Unlock()
+	sessions := make([]DMSession, 0, len(c.dm.sessions))
+	for key := range c.dm.sessions {
+		if s := c.dmSession(key, false); s != nil {
+			sessions = append(sessions, *s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastActivity.After(sessions[j].LastActivity) })
+	return sessions
+}
+
+// CloseDMSession ends the conversation with nick; the next message starts a
+// new one
+func (c *Client) CloseDMSession(nick string) bool {
+	c.dm.mu.Lock()
+	defer c.dm.mu.Unlock()
+	key := strings.ToLower(nick)
+	if _, ok := c.dm.sessions[key]; !ok {
+		return false
+	}
+	delete(c.dm.sessions, key)
+	return true
+}
+
+// handleDMSessions lists the open private conversations:
+// GET /api/pm?limit=20&offset=0
+func (a *API) handleDMSessions(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePage(r)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+	writePage(w, "sessions", a.bot.DMSessions(), p, nil)
+}
+
+// handleCloseDMSession ends a private conversation:
+// DELETE /api/pm/{nick}
+func (a *API) handleCloseDMSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	nick := r.PathValue("nick")
+	if !a.bot.CloseDMSession(nick) {
+		writeError(w, 404, codeNotFound, "no open conversation with "+nick)
+		return
+	}
+	writeJSON(w, 200, map[string]string{"status": "closed", "nick": nick})
+}
@@ -0,0 +1,69 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDMSessions(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	var events []Event
+	client.bus.Subscribe("test", func(e Event) { events = append(events, e) })
+
+	client.handleLine(":alice!a@host PRIVMSG TestBot :hello there")
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+		if !strings.HasPrefix(e.Session, "pm:alice:") {
+			t.Errorf("Expected %s to carry alice's session, got %q", e.Type, e.Session)
+		}
+	}
+	if strings.Join(types, ",") != "privmsg,pm,mention" {
+		t.Errorf("Expected privmsg, pm and mention for a private message, got %v", types)
+	}
+	session := events[0].Session
+
+	// Channel messages and CTCP requests are not part of a conversation
+	events = nil
+	client.handleLine(":alice!a@host PRIVMSG #test :hello")
+	client.handleLine(":bob!b@host PRIVMSG TestBot :\x01VERSION\x01")
+	for _, e := range events {
+		if e.Session != "" || e.Type == "pm" {
+			t.Errorf("Unexpected conversation for %+v", e)
+		}
+	}
+
+	// The conversation follows a nick change and counts the bot's replies
+	client.handleLine(":alice!a@host NICK :alice2")
+	client.Privmsg("alice2", "hi!")
+	sessions := client.DMSessions()
+	if len(sessions) != 1 || sessions[0].Nick != "alice2" || sessions[0].SessionID != session || sessions[0].Received != 1 || sessions[0].Sent != 1 {
+		t.Fatalf("Unexpected sessions: %+v", sessions)
+	}
+
+	api := client.CreateAPI("token")
+	req := httptest.NewRequest("DELETE", "/api/pm/alice2", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 200 || len(client.DMSessions()) != 0 {
+		t.Errorf("Expected the conversation closed, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDMMentionsNick(t *testing.T) {
+	t.Setenv("DM_MENTIONS", "nick")
+	client := NewClient()
+	client.setNick("TestBot")
+	var types []string
+	client.bus.Subscribe("test", func(e Event) { types = append(types, e.Type) })
+
+	client.handleLine(":alice!a@host PRIVMSG TestBot :hello")
+	client.handleLine(":alice!a@host PRIVMSG TestBot :hello TestBot")
+	if strings.Join(types, ",") != "privmsg,pm,privmsg,pm,mention" {
+		t.Errorf("Expected a mention only when the bot is named, got %v", types)
+	}
+}
@@ -0,0 +1,109 @@
+package irc
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// cp1252High maps Windows-1252 bytes 0x80-0x9F, where it differs from
+// latin-1; zero entries are undefined and decoded as in latin-1
+var cp1252High = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// iso885915 lists the latin-9 bytes that differ from latin-1
+var iso885915 = map[byte]rune{
+	0xA4: '€', 0xA6: 'Š', 0xA8: 'š', 0xB4: 'Ž', 0xB8: 'ž', 0xBC: 'Œ', 0xBD: 'œ', 0xBE: 'Ÿ',
+}
+
+// charsetDecoders convert a single non-UTF-8 byte to a rune
+var charsetDecoders = map[string]func(byte) rune{
+	"latin1": func(b byte) rune { return rune(b) },
+	"cp1252": func(b byte) rune {
+		if b >= 0x80 && b <= 0x9F && cp1252High[b-0x80] != 0 {
+			return cp1252High[b-0x80]
+		}
+		return rune(b)
+	},
+	"latin9": func(b byte) rune {
+		if r, ok := iso885915[b]; ok {
+			return r
+		}
+		return rune(b)
+	},
+	"none": func(byte) rune { return utf8.RuneError },
+}
+
+var charsetAliases = map[string]string{
+	"latin1": "latin1", "latin-1": "latin1", "iso-8859-1": "latin1", "iso8859-1": "latin1",
+	"cp1252": "cp1252", "windows-1252": "cp1252",
+	"latin9": "latin9", "latin-9": "latin9", "iso-8859-15": "latin9", "iso8859-15": "latin9",
+	"none": "none", "replace": "none",
+}
+
+// loadCharset reads IRC_FALLBACK_CHARSET, the charset assumed for incoming
+// bytes that are not valid UTF-8
+func (c *Client) loadCharset() {
+	name := strings.ToLower(strings.TrimSpace(getenv("IRC_FALLBACK_CHARSET", "cp1252")))
+	charset, ok := charsetAliases[name]
+	if !ok {
+		log.Fatalf("FATAL: Invalid IRC_FALLBACK_CHARSET %q (use latin1, cp1252, latin9 or none)", name)
+	}
+	c.fallbackCharset = charset
+}
+
+// decodeIncoming returns line as valid UTF-8. Valid UTF-8 sequences are kept
+// and every other byte is decoded with the fallback charset, so lines mixing
+// UTF-8 and legacy text (e.g. a UTF-8 nick and a latin-1 message) survive.
+func (c *Client) decodeIncoming(line string) string {
+	if utf8.ValidString(line) {
+		return line
+	}
+	decode := charsetDecoders[c.fallbackCharset]
+	if decode == nil {
+		decode = charsetDecoders["cp1252"]
+	}
+	var b strings.Builder
+	b.Grow(len(line) + len(line)/4)
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteRune(decode(line[i]))
+		} else {
+			b.WriteString(line[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// lineBreaks replaces the characters that end or cut an IRC line
+var lineBreaks = strings.NewReplacer("\r", " ", "\n", " ", "\x00", " ")
+
+// validOutgoing replaces invalid UTF-8 in an outgoing line so the server never
+// receives broken sequences, and CR, LF and NUL so text from users or other
+// networks can never smuggle in a second command
+func validOutgoing(s string) string {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		log.Printf("Replacing line breaks in outgoing line: %q", s)
+		s = lineBreaks.Replace(s)
+	}
+	if utf8.ValidString(s) {
+		return s
+	}
+	log.Printf("Replacing invalid UTF-8 in outgoing line: %q", s)
+	return strings.ToValidUTF8(s, "�")
+}
+
+// truncateUTF8 cuts s to at most max bytes without splitting a character
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
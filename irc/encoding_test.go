@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDecodeIncoming(t *testing.T) {
+	tests := []struct {
+		charset, in, want string
+	}{
+		{"latin1", "caf\xe9", "café"},
+		{"cp1252", "\x93quoted\x94 \x80", "“quoted” €"},
+		{"latin9", "\xa4 \xbd", "€ œ"},
+		{"none", "caf\xe9", "caf�"},
+		// UTF-8 and latin-1 mixed in one line
+		{"latin1", ":jos\xc3\xa9!u@h PRIVMSG #c :d\xe9j\xe0 vu", ":josé!u@h PRIVMSG #c :déjà vu"},
+		{"cp1252", "already ünïcode", "already ünïcode"},
+	}
+	for _, tt := range tests {
+		c := &Client{fallbackCharset: tt.charset}
+		if got := c.decodeIncoming(tt.in); got != tt.want {
+			t.Errorf("decodeIncoming(%s, %q) = %q, want %q", tt.charset, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIncomingLatin1Trigger(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	t.Setenv("IRC_FALLBACK_CHARSET", "iso-8859-1")
+	client := NewClient()
+	client.setNick("TestBot")
+
+	client.handleLine(":alice!a@host PRIVMSG #test :na\xefve r\xe9sum\xe9")
+	if payload := expectTrigger(t, received); payload.Message != "naïve résumé" {
+		t.Errorf("Expected latin-1 to be decoded, got %q", payload.Message)
+	}
+}
+
+func TestOutgoingUTF8(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.Privmsg("#test", "bad \xff byte")
+	client.Privmsg("#test", strings.Repeat("é", 300))
+	if sent[0] != "PRIVMSG #test :bad � byte" {
+		t.Errorf("Expected invalid UTF-8 to be replaced, got %q", sent[0])
+	}
+	if len(sent) != 3 {
+		t.Fatalf("Expected the long message to be split in two, got %d lines", len(sent)-1)
+	}
+	for _, line := range sent[1:] {
+		if !utf8.ValidString(line) {
+			t.Errorf("Message split inside a character: %q", line)
+		}
+	}
+}
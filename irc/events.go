@@ -0,0 +1,285 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// registerSubscribers wires the built-in consumers to the event bus. History
+// runs before the state tracker so it still sees who was in which channel.
+func (c *Client) registerSubscribers() {
+	c.bus.Subscribe("history", c.recordHistory)
+	c.bus.Subscribe("state", c.trackState)
+	c.bus.Subscribe("state_changes", c.trackChanges)
+	c.bus.Subscribe("recent_messages", c.rememberMessage)
+	c.bus.Subscribe("reply_refs", c.rememberReplyRef)
+	c.bus.Subscribe("triggers", c.triggerFromEvent)
+	c.bus.Subscribe("nick_reclaim", c.reclaimOnRelease)
+	c.bus.Subscribe("dm", c.renameDM)
+	c.bus.Subscribe("link_preview", func(e Event) {
+		if (e.Type == "privmsg" || e.Type == "action") && !e.Replayed && !e.Dropped {
+			c.previewLinks(e.Sender, e.Target, e.Text, e.Tags)
+		}
+	})
+}
+
+// recordHistory writes events to the channel logs and the seen records
+func (c *Client) recordHistory(e Event) {
+	if e.Replayed {
+		return
+	}
+	switch e.Type {
+	case "kick":
+		c.logChannelEvent("kick", e.Target, e.Sender, e.Nick, e.Text, e.Tags)
+		c.recordSeen(e.Nick, "kick", e.Target, e.Sender, e.Text, nil)
+	case "mode":
+		if len(e.Args) >= 2 {
+			c.logChannelEvent("mode", e.Target, e.Sender, "", strings.TrimSpace(strings.Join(e.Args[1:], " ")), e.Tags)
+		}
+	case "topic", "notice":
+		c.logChannelEvent(e.Type, e.Target, e.Sender, "", e.Text, e.Tags)
+	case "nick":
+		for _, ch := range e.Channels {
+			c.logChannelEvent("nick", ch, e.Sender, e.Nick, "", e.Tags)
+		}
+		c.recordSeen(e.Sender, "nick", "", e.Nick, "", e.Tags)
+	case "privmsg", "action":
+		c.logChannelEvent(e.Type, e.Target, e.Sender, "", e.Text, e.Tags)
+		if isChannelName(e.Target) {
+			c.recordSeen(e.Sender, e.Type, e.Target, "", e.Text, e.Tags)
+		}
+	case "join", "part":
+		c.logChannelEvent(e.Type, e.Target, e.Sender, "", e.Text, e.Tags)
+		c.recordSeen(e.Sender, e.Type, e.Target, "", e.Text, e.Tags)
+	case "quit":
+		for _, ch := range e.Channels {
+			c.logChannelEvent("quit", ch, e.Sender, "", e.Text, e.Tags)
+		}
+		c.recordSeen(e.Sender, "quit", "", "", e.Text, e.Tags)
+	}
+}
+
+// trackState keeps channel membership, modes and the bot's own nick current
+func (c *Client) trackState(e Event) {
+	switch e.Type {
+	case "kick":
+		if e.Self {
+			log.Printf("Kicked from channel: %s", e.Target)
+			c.channelsMu.Lock()
+			delete(c.channels, strings.ToLower(e.Target))
+			c.channelsMu.Unlock()
+
+			// Clear channel state when we're kicked
+			c.ClearChannelState(e.Target)
+		} else {
+			log.Printf("User %s kicked %s from %s: %s", e.Sender, e.Nick, e.Target, e.Text)
+			c.RemoveUserFromChannel(e.Target, e.Nick)
+		}
+	case "mode":
+		// User modes for the bot itself, e.g. :Hanna MODE Hanna :+iw
+		if e.Self {
+			modeString := e.Text
+			if len(e.Args) >= 2 {
+				modeString = e.Args[1]
+			}
+			c.applyUserModes(modeString)
+			log.Printf("User modes changed by %s: %s (now %s)", e.Sender, modeString, c.UserModes())
+		}
+		if len(e.Args) >= 2 && (strings.HasPrefix(e.Target, "#") || strings.HasPrefix(e.Target, "&")) {
+			changes := c.ParseModeChange(e.Target, e.Args[1], e.Args[2:])
+			c.ApplyModeChanges(e.Target, changes)
+			for _, change := range changes {
+				op := "+"
+				if !change.Adding {
+					op = "-"
+				}
+				log.Printf("Mode change by %s: %s%c %s in %s", e.Sender, op, change.Mode, change.Nick, e.Target)
+			}
+		}
+	case "topic":
+		c.updateTopic(e)
+	case "nick":
+		if e.Self {
+			log.Printf("Nick changed from %s to %s", c.Nick(), e.Nick)
+			c.setNick(e.Nick)
+		}
+		c.channelStatesMu.Lock()
+		for _, state := range c.channelStates {
+			if modes, exists := state.Users[e.Sender]; exists {
+				delete(state.Users, e.Sender)
+				state.Users[e.Nick] = modes
+			}
+		}
+		c.channelStatesMu.Unlock()
+	case "join":
+		switch {
+		case e.Self:
+			log.Printf("Joined channel: %s", e.Target)
+			c.channelsMu.Lock()
+			c.channels[strings.ToLower(e.Target)] = struct{}{}
+			c.channelsMu.Unlock()
+
+			// Add ourselves to the channel state and request the user list
+			c.AddUserToChannel(e.Target, c.Nick(), "")
+			c.rawf("NAMES %s", e.Target)
+		case e.Rejoin:
+			// Returning from a netsplit; keep existing modes
+			log.Printf("User %s rejoined %s after netsplit", e.Sender, e.Target)
+			c.ensureUserInChannel(e.Target, e.Sender)
+		case c.HasChannelUser(e.Target, e.Sender):
+			// Already listed, e.g. after a missed PART or a restored state
+			log.Printf("User %s joined %s", e.Sender, e.Target)
+			c.ensureUserInChannel(e.Target, e.Sender)
+		default:
+			log.Printf("User %s joined %s", e.Sender, e.Target)
+			c.AddUserToChannel(e.Target, e.Sender, "")
+		}
+	case "part":
+		if e.Self {
+			log.Printf("Left channel: %s", e.Target)
+			c.channelsMu.Lock()
+			delete(c.channels, strings.ToLower(e.Target))
+			c.channelsMu.Unlock()
+
+			// Clear channel state when we leave
+			c.ClearChannelState(e.Target)
+		} else {
+			log.Printf("User %s left %s: %s", e.Sender, e.Target, e.Text)
+			c.RemoveUserFromChannel(e.Target, e.Sender)
+		}
+	case "quit":
+		if e.Netsplit != "" {
+			// Keep the user around until they rejoin; reported as one netsplit event
+			log.Printf("User %s lost in netsplit %s", e.Sender, e.Netsplit)
+			c.markSplit(e.Sender, e.Netsplit)
+			return
+		}
+		log.Printf("User %s quit: %s", e.Sender, e.Text)
+		c.RemoveUserFromAllChannels(e.Sender)
+	}
+}
+
+// triggerFromEvent forwards events to the trigger endpoints. The bot's own
+// joins, parts and kicks, netsplit quits and rejoins, and spam are not sent.
+func (c *Client) triggerFromEvent(e Event) {
+	if e.Dropped || (e.Self && e.Type != "mode") {
+		return
+	}
+	switch e.Type {
+	case "mode":
+		if len(e.Args) < 2 {
+			return
+		}
+	case "join":
+		if e.Rejoin {
+			return
+		}
+	case "quit":
+		if e.Netsplit != "" {
+			return
+		}
+	case "nick":
+		return
+	case "mention":
+		if c.throttleMention(e) {
+			return
+		}
+	}
+	payload := c.newTriggerPayload(e.Type, e.Sender, e.Target, e.Message, e.Text, e.Tags)
+	payload.CorrelationID = e.ID
+	if e.Session != "" {
+		payload.SessionId = e.Session
+	}
+	if strings.Contains(e.Prefix, "!") {
+		payload.Hostmask = e.Prefix
+		payload.SenderIsBot = payload.SenderIsBot || c.isListedBot(e.Sender, e.Prefix)
+	}
+	c.sendTrigger(payload)
+}
+
+// handleEvents streams bus events as server-sent events:
+// GET /api/events?types=privmsg,join&channel=%23general
+func (a *API) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, 500, codeInternal, "streaming not supported")
+		return
+	}
+	filter := newEventFilter(strings.Split(r.URL.Query().Get("types"), ","), r.URL.Query().Get("channel"))
+	closeStream, ok := a.bot.drain.openStream()
+	if !ok {
+		writeError(w, 503, codeUnavailable, "shutting down")
+		return
+	}
+	defer closeStream()
+
+	events, unsubscribe := a.bot.bus.SubscribeChan("api_stream", 256)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.bot.drain.done():
+			data, _ := json.Marshal(shutdownEvent())
+			fmt.Fprintf(w, "event: shutting_down\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter.match(e) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// eventFilter selects streamed events by type and channel; empty fields
+// match everything
+type eventFilter struct {
+	types   map[string]bool
+	channel string
+}
+
+func newEventFilter(types []string, channel string) eventFilter {
+	f := eventFilter{types: make(map[string]bool), channel: channel}
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			f.types[strings.ToLower(t)] = true
+		}
+	}
+	return f
+}
+
+func (f eventFilter) match(e Event) bool {
+	if len(f.types) > 0 && !f.types[e.Type] {
+		return false
+	}
+	return f.channel == "" || strings.EqualFold(e.Target, f.channel) || containsFold(e.Channels, f.channel)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIRCd is an in-process IRC server for integration tests. It accepts
+// any number of connections and answers registration, CAP, SASL PLAIN and
+// PING on its own; tests script the rest through handlers and fakeConn.
+type fakeIRCd struct {
+	t  *testing.T
+	ln net.Listener
+
+	caps     []string // capabilities offered in CAP LS and acknowledged in CAP REQ
+	saslUser string   // SASL PLAIN credentials that succeed, any others fail
+	saslPass string
+	isupport []string // 005 tokens
+
+	mu       sync.Mutex
+	handlers map[string]func(fc *fakeConn, m fakeMsg) // by command, replacing the defaults
+	conns    chan *fakeConn
+}
+
+// fakeMsg is a line received from the client
+type fakeMsg struct {
+	Line    string
+	Command string
+	Params  []string // including the trailing parameter
+}
+
+// fakeConn is the server end of one client connection
+type fakeConn struct {
+	srv   *fakeIRCd
+	conn  net.Conn
+	lines chan fakeMsg // everything the client sent, in order
+
+	// Registration, only touched by the reading goroutine
+	nick     string
+	capEnded bool
+	welcomed bool
+}
+
+func newFakeIRCd(t *testing.T) *fakeIRCd {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeIRCd{
+		t:        t,
+		ln:       ln,
+		caps:     []string{"message-tags", "account-tag", "server-time", "batch", "extended-join", "account-notify", "multi-prefix"},
+		isupport: []string{"NETWORK=FakeNet", "CHANTYPES=#&", "PREFIX=(ov)@+"},
+		handlers: make(map[string]func(*fakeConn, fakeMsg)),
+		conns:    make(chan *fakeConn, 10),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+// handle replaces the default handling of a command
+func (s *fakeIRCd) handle(command string, fn func(fc *fakeConn, m fakeMsg)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[command] = fn
+}
+
+// client returns a client that dials this server through an injected
+// transport; env settings must be in place before it is called
+func (s *fakeIRCd) client() *Client {
+	c := NewClient()
+	c.SetTransport(TransportFunc(func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", s.ln.Addr().String())
+	}))
+	s.t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// accept waits for the next client connection
+func (s *fakeIRCd) accept() *fakeConn {
+	s.t.Helper()
+	select {
+	case fc := <-s.conns:
+		return fc
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("Timed out waiting for a connection")
+	}
+	return nil
+}
+
+func (s *fakeIRCd) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		fc := &fakeConn{srv: s, conn: conn, lines: make(chan fakeMsg, 100)}
+		s.t.Cleanup(func() { conn.Close() })
+		s.conns <- fc
+		go fc.read()
+	}
+}
+
+func (fc *fakeConn) read() {
+	defer close(fc.lines)
+	scanner := bufio.NewScanner(fc.conn)
+	for scanner.Scan() {
+		m := parseFakeMsg(scanner.Text())
+		select {
+		case fc.lines <- m:
+		default: // nobody is reading the log
+		}
+		fc.srv.mu.Lock()
+		h := fc.srv.handlers[m.Command]
+		fc.srv.mu.Unlock()
+		if h != nil {
+			h(fc, m)
+		} else {
+			fc.reply(m)
+		}
+	}
+}
+
+func parseFakeMsg(line string) fakeMsg {
+	m := fakeMsg{Line: line}
+	rest := line
+	if strings.HasPrefix(rest, "@") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	rest, trailing, hasTrailing := strings.Cut(rest, " :")
+	fields := strings.Fields(rest)
+	if len(fields) > 0 {
+		m.Command = strings.ToUpper(fields[0])
+		m.Params = fields[1:]
+	}
+	if hasTrailing {
+		m.Params = append(m.Params, trailing)
+	}
+	return m
+}
+
+// reply is the default behaviour for registration, CAP, SASL and PING
+func (fc *fakeConn) reply(m fakeMsg) {
+	s := fc.srv
+	switch m.Command {
+	case "CAP":
+		if len(m.Params) == 0 {
+			return
+		}
+		switch strings.ToUpper(m.Params[0]) {
+		case "LS":
+			offered := s.caps
+			if s.saslUser != "" {
+				offered = append(append([]string{}, offered...), "sasl=PLAIN")
+			}
+			fc.send(":fake.server CAP * LS :%s", strings.Join(offered, " "))
+		case "REQ":
+			requested := strings.Fields(m.Params[len(m.Params)-1])
+			for _, cap := range requested {
+				if !fc.offers(cap) {
+					fc.send(":fake.server CAP * NAK :%s", strings.Join(requested, " "))
+					return
+				}
+			}
+			fc.send(":fake.server CAP * ACK :%s", strings.Join(requested, " "))
+		case "END":
+			fc.capEnded = true
+			fc.welcome()
+		}
+	case "AUTHENTICATE":
+		if len(m.Params) == 0 {
+			return
+		}
+		if m.Params[0] == "PLAIN" {
+			fc.send("AUTHENTICATE +")
+			return
+		}
+		want := base64.StdEncoding.EncodeToString([]byte("\x00" + s.saslUser + "\x00" + s.saslPass))
+		if m.Params[0] == want {
+			fc.send(":fake.server 900 * * %s :You are now logged in as %s", s.saslUser, s.saslUser)
+			fc.send(":fake.server 903 * :SASL authentication successful")
+		} else {
+			fc.send(":fake.server 904 * :SASL authentication failed")
+		}
+	case "NICK":
+		if len(m.Params) > 0 {
+			fc.nick = m.Params[0]
+			fc.welcome()
+		}
+	case "PING":
+		fc.send(":fake.server PONG fake.server :%s", m.Params[len(m.Params)-1])
+	}
+}
+
+func (fc *fakeConn) offers(cap string) bool {
+	if cap == "sasl" {
+		return fc.srv.saslUser != ""
+	}
+	for _, c := range fc.srv.caps {
+		if c == cap {
+			return true
+		}
+	}
+	return false
+}
+
+// welcome completes registration once CAP negotiation has ended and NICK
+// has been seen, in either order
+func (fc *fakeConn) welcome() {
+	if fc.welcomed || !fc.capEnded || fc.nick == "" {
+		return
+	}
+	fc.welcomed = true
+	fc.send(":fake.server 001 %s :Welcome to FakeNet %s", fc.nick, fc.nick)
+	fc.send(":fake.server 005 %s %s :are supported by this server", fc.nick, strings.Join(fc.srv.isupport, " "))
+	fc.send(":fake.server 376 %s :End of /MOTD command.", fc.nick)
+}
+
+// send writes a line to the client
+func (fc *fakeConn) send(format string, args ...any) {
+	fmt.Fprintf(fc.conn, format+"\r\n", args...)
+}
+
+// expect waits for the next line from the client with the given command,
+// skipping others
+func (fc *fakeConn) expect(command string) fakeMsg {
+	fc.srv.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m, ok := <-fc.lines:
+			if !ok {
+				fc.srv.t.Fatalf("Connection closed while waiting for %s", command)
+			}
+			if m.Command == command {
+				return m
+			}
+		case <-timeout:
+			fc.srv.t.Fatalf("Timed out waiting for %s", command)
+		}
+	}
+}
+
+func (fc *fakeConn) close() { fc.conn.Close() }
+
+// waitState waits until the client reaches a connection state
+func waitState(t *testing.T, c *Client, want ConnState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected state %s, still %s", want, c.State())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
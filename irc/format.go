@@ -0,0 +1,207 @@
+package irc
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// mIRC formatting control codes
+const (
+	CodeBold          = "\x02"
+	CodeColor         = "\x03"
+	CodeHexColor      = "\x04"
+	CodeMonospace     = "\x11"
+	CodeReverse       = "\x16"
+	CodeItalic        = "\x1d"
+	CodeStrikethrough = "\x1e"
+	CodeUnderline     = "\x1f"
+	CodeReset         = "\x0f"
+)
+
+// Color is one of the 16 standard mIRC colors
+type Color int
+
+const (
+	ColorWhite Color = iota
+	ColorBlack
+	ColorBlue
+	ColorGreen
+	ColorRed
+	ColorBrown
+	ColorPurple
+	ColorOrange
+	ColorYellow
+	ColorLightGreen
+	ColorCyan
+	ColorLightCyan
+	ColorLightBlue
+	ColorPink
+	ColorGrey
+	ColorLightGrey
+)
+
+var colorNames = map[string]Color{
+	"white": ColorWhite, "black": ColorBlack, "blue": ColorBlue, "green": ColorGreen, "red": ColorRed,
+	"brown": ColorBrown, "purple": ColorPurple, "orange": ColorOrange, "yellow": ColorYellow,
+	"lightgreen": ColorLightGreen, "cyan": ColorCyan, "lightcyan": ColorLightCyan,
+	"lightblue": ColorLightBlue, "pink": ColorPink, "grey": ColorGrey, "gray": ColorGrey,
+	"lightgrey": ColorLightGrey, "lightgray": ColorLightGrey,
+}
+
+// ParseColor returns the color with the given name (e.g. "lightblue")
+func ParseColor(name string) (Color, bool) {
+	c, ok := colorNames[strings.ToLower(name)]
+	return c, ok
+}
+
+func Bold(s string) string          { return CodeBold + s + CodeBold }
+func Italic(s string) string        { return CodeItalic + s + CodeItalic }
+func Underline(s string) string     { return CodeUnderline + s + CodeUnderline }
+func Strikethrough(s string) string { return CodeStrikethrough + s + CodeStrikethrough }
+func Monospace(s string) string     { return CodeMonospace + s + CodeMonospace }
+
+// Colorize wraps s in a foreground color. Two-digit codes are always used so
+// text starting with a digit is not mistaken for part of the color.
+func Colorize(fg Color, s string) string {
+	return fmt.Sprintf("%s%02d%s%s", CodeColor, fg, s, CodeColor)
+}
+
+// ColorizeBg wraps s in a foreground and background color
+func ColorizeBg(fg, bg Color, s string) string {
+	return fmt.Sprintf("%s%02d,%02d%s%s", CodeColor, fg, bg, s, CodeColor)
+}
+
+var (
+	colorCodeRe    = regexp.MustCompile(`\x03(?:\d{1,2}(?:,\d{1,2})?)?`)
+	hexColorCodeRe = regexp.MustCompile(`\x04(?:[0-9a-fA-F]{6}(?:,[0-9a-fA-F]{6})?)?`)
+	formatCodes    = strings.NewReplacer(CodeBold, "", CodeMonospace, "", CodeReverse, "", CodeItalic, "", CodeStrikethrough, "", CodeUnderline, "", CodeReset, "")
+)
+
+// StripFormatting removes all mIRC bold, italic, underline, color and other
+// formatting codes from s
+func StripFormatting(s string) string {
+	if !strings.ContainsAny(s, "\x02\x03\x04\x0f\x11\x16\x1d\x1e\x1f") {
+		return s
+	}
+	s = colorCodeRe.ReplaceAllString(s, "")
+	s = hexColorCodeRe.ReplaceAllString(s, "")
+	return formatCodes.Replace(s)
+}
+
+var (
+	mdCodeRe      = regexp.MustCompile("`([^`]+)`")
+	mdBoldRe      = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	mdUnderlineRe = regexp.MustCompile(`__(\S(?:.*?\S)?)__`)
+	mdStrikeRe    = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdItalicRe    = regexp.MustCompile(`(^|[^\w*])\*(\S(?:[^*]*?\S)?)\*([^\w*]|$)`)
+	mdItalicAltRe = regexp.MustCompile(`(^|[^\w_])_(\S(?:[^_]*?\S)?)_([^\w_]|$)`)
+	mdColorRe     = regexp.MustCompile(`\{([a-zA-Z]+)\}(.*?)\{/\}`)
+)
+
+// MarkdownToIRC converts markdown-lite to mIRC codes: **bold**, *italic* or
+// _italic_, __underline__, ~~strikethrough~~, `monospace` and {red}color{/}.
+// Text inside backticks is left untouched.
+func MarkdownToIRC(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdCodeRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(markdownSpans(s[last:m[0]]))
+		b.WriteString(Monospace(s[m[2]:m[3]]))
+		last = m[1]
+	}
+	b.WriteString(markdownSpans(s[last:]))
+	return b.String()
+}
+
+func markdownSpans(s string) string {
+	s = mdBoldRe.ReplaceAllString(s, CodeBold+"$1"+CodeBold)
+	s = mdUnderlineRe.ReplaceAllString(s, CodeUnderline+"$1"+CodeUnderline)
+	s = mdStrikeRe.ReplaceAllString(s, CodeStrikethrough+"$1"+CodeStrikethrough)
+	s = mdItalicRe.ReplaceAllString(s, "${1}"+CodeItalic+"${2}"+CodeItalic+"${3}")
+	s = mdItalicAltRe.ReplaceAllString(s, "${1}"+CodeItalic+"${2}"+CodeItalic+"${3}")
+	return mdColorRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdColorRe.FindStringSubmatch(m)
+		if c, ok := ParseColor(sub[1]); ok {
+			return Colorize(c, sub[2])
+		}
+		return m
+	})
+}
+
+// applyMessageFormat prepares an outgoing API message: "" or "raw" sends it
+// as-is, "markdown" converts markdown-lite and "plain" strips formatting
+func applyMessageFormat(format, message string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "raw":
+		return message, nil
+	case "markdown":
+		return MarkdownToIRC(message), nil
+	case "plain":
+		return StripFormatting(message), nil
+	default:
+		return "", fmt.Errorf("unknown format %q (expected raw, markdown or plain)", format)
+	}
+}
+
+// IRCToMarkdown converts mIRC bold, italic, underline, strikethrough and
+// monospace codes to their markdown-lite equivalents and drops colors
+func IRCToMarkdown(s string) string {
+	s = colorCodeRe.ReplaceAllString(s, "")
+	s = hexColorCodeRe.ReplaceAllString(s, "")
+	marks := map[rune]string{
+		'\x02': "**", '\x1d': "*", '\x1f': "__", '\x1e': "~~", '\x11': "`",
+	}
+	var b strings.Builder
+	var open []rune // styles in the order they were opened
+	closeAll := func() {
+		for i := len(open) - 1; i >= 0; i-- {
+			b.WriteString(marks[open[i]])
+		}
+		open = open[:0]
+	}
+	for _, r := range s {
+		switch r {
+		case '\x02', '\x1d', '\x1f', '\x1e', '\x11':
+			if i := slices.Index(open, r); i >= 0 {
+				// Close inner styles, then reopen them so spans stay nested
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString(marks[open[j]])
+				}
+				inner := slices.Clone(open[i+1:])
+				open = open[:i]
+				for _, o := range inner {
+					b.WriteString(marks[o])
+					open = append(open, o)
+				}
+			} else {
+				b.WriteString(marks[r])
+				open = append(open, r)
+			}
+		case '\x0f':
+			closeAll()
+		case '\x16':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	closeAll()
+	return b.String()
+}
+
+// formattingModes are the ways incoming formatting can be handed on:
+// "raw" keeps the codes, "strip" removes them and "markdown" converts them
+var formattingModes = map[string]bool{"raw": true, "strip": true, "markdown": true}
+
+// convertFormatting applies a formatting mode to incoming text
+func convertFormatting(mode, s string) string {
+	switch mode {
+	case "strip":
+		return StripFormatting(s)
+	case "markdown":
+		return IRCToMarkdown(s)
+	default:
+		return s
+	}
+}
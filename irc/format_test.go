@@ -0,0 +1,113 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStripFormatting(t *testing.T) {
+	tests := map[string]string{
+		"\x02bold\x02 \x1ditalic\x1d \x1funder\x1f": "bold italic under",
+		"\x0304red\x03 \x034,12bg\x03 \x03plain":    "red bg plain",
+		"\x0399 balloons":                           " balloons",
+		"\x04ff0000hex\x04 \x16rev\x0f":             "hex rev",
+		"no codes":                                  "no codes",
+	}
+	for in, want := range tests {
+		if got := StripFormatting(in); got != want {
+			t.Errorf("StripFormatting(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMarkdownToIRC(t *testing.T) {
+	tests := map[string]string{
+		"**bold** and *italic*":         "\x02bold\x02 and \x1ditalic\x1d",
+		"__under__ ~~gone~~ _it_":       "\x1funder\x1f \x1egone\x1e \x1dit\x1d",
+		"run `**not bold**` now":        "run \x11**not bold**\x11 now",
+		"{red}alert{/} {nope}x{/}":      "\x0304alert\x03 {nope}x{/}",
+		"snake_case_name and 2 * 3 * 4": "snake_case_name and 2 * 3 * 4",
+	}
+	for in, want := range tests {
+		if got := MarkdownToIRC(in); got != want {
+			t.Errorf("MarkdownToIRC(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if Colorize(ColorGreen, "1st") != "\x03031st\x03" {
+		t.Error("Colorize should always use two-digit color codes")
+	}
+}
+
+func TestSendFormat(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	api := client.CreateAPI("token")
+
+	send := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/send", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := send(`{"target":"#test","message":"**deploy** done","format":"markdown"}`); code != 200 {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if code := send(`{"target":"#test","message":"\u00034red","format":"plain"}`); code != 200 {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if code := send(`{"target":"#test","message":"x","format":"html"}`); code != 400 {
+		t.Errorf("Expected 400 for an unknown format, got %d", code)
+	}
+	if len(sent) != 2 || sent[0] != "PRIVMSG #test :\x02deploy\x02 done" || sent[1] != "PRIVMSG #test :red" {
+		t.Errorf("Unexpected messages sent: %q", sent)
+	}
+}
+
+func TestIRCToMarkdown(t *testing.T) {
+	tests := map[string]string{
+		"\x02bold\x02 \x1dit\x1d":       "**bold** *it*",
+		"\x0304\x02red bold":            "**red bold**",
+		"\x02b \x1fbu\x02 u\x1f":        "**b __bu__**__ u__",
+		"\x11code\x0f done \x1eold\x1e": "`code` done ~~old~~",
+	}
+	for in, want := range tests {
+		if got := IRCToMarkdown(in); got != want {
+			t.Errorf("IRCToMarkdown(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTriggerStripColors(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	os.Setenv("TRIGGER_CONFIG", strings.Replace(os.Getenv("TRIGGER_CONFIG"), `"events"`, `"strip_colors":true,"events"`, 1))
+	client := NewClient()
+	client.setNick("TestBot")
+
+	client.handleLine(":alice!a@host PRIVMSG #test :\x02hello\x02 \x0304world")
+	payload := expectTrigger(t, received)
+	if payload.Message != "hello world" || payload.ChatInput != "hello world" {
+		t.Errorf("Expected formatting to be stripped, got %q / %q", payload.Message, payload.ChatInput)
+	}
+}
+
+func TestTriggerFormattingMarkdown(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	os.Setenv("TRIGGER_CONFIG", strings.Replace(os.Getenv("TRIGGER_CONFIG"), `"events"`, `"formatting":"markdown","events"`, 1))
+	client := NewClient()
+	client.setNick("TestBot")
+
+	client.handleLine(":alice!a@host PRIVMSG #test :\x02hello\x02 world")
+	payload := expectTrigger(t, received)
+	if payload.Message != "**hello** world" || payload.RawMessage != "\x02hello\x02 world" {
+		t.Errorf("Expected markdown message and raw original, got %q / %q", payload.Message, payload.RawMessage)
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :plain")
+	if payload := expectTrigger(t, received); payload.RawMessage != "" {
+		t.Errorf("rawMessage should be omitted when nothing changed, got %q", payload.RawMessage)
+	}
+}
@@ -0,0 +1,254 @@
+package irc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Colors used in GitHub announcements
+const (
+	ghColorRepo   = ColorPink
+	ghColorNick   = ColorLightGrey
+	ghColorBranch = ColorPurple
+	ghColorHash   = ColorGrey
+)
+
+// ghMaxCommits is how many commits of a push are listed individually
+const ghMaxCommits = 3
+
+// verifyGitHubSignature checks an X-Hub-Signature-256 header ("sha256=<hex>")
+// against the HMAC of the body
+func verifyGitHubSignature(secret, header string, body []byte) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+type ghUser struct {
+	Login string `json:"login"`
+}
+
+type ghRepo struct {
+	FullName string `json:"full_name"`
+}
+
+type ghCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Author  struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+type ghPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	Merged  bool   `json:"merged"`
+	Draft   bool   `json:"draft"`
+	Base    struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type ghIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+}
+
+type ghRelease struct {
+	TagName    string `json:"tag_name"`
+	Name       string `json:"name"`
+	HTMLURL    string `json:"html_url"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// ghEvent holds the fields of push, pull_request, issues and release payloads
+// that are announced
+type ghEvent struct {
+	Action     string `json:"action"`
+	Ref        string `json:"ref"`
+	Compare    string `json:"compare"`
+	Forced     bool   `json:"forced"`
+	Created    bool   `json:"created"`
+	Deleted    bool   `json:"deleted"`
+	Repository ghRepo `json:"repository"`
+	Sender     ghUser `json:"sender"`
+	Pusher     struct {
+		Name string `json:"name"`
+	} `json:"pusher"`
+	Commits     []ghCommit     `json:"commits"`
+	PullRequest *ghPullRequest `json:"pull_request"`
+	Issue       *ghIssue       `json:"issue"`
+	Release     *ghRelease     `json:"release"`
+}
+
+// formatGitHubEvent renders a GitHub event as IRC lines. Events and actions
+// that are not announced return no lines.
+func formatGitHubEvent(event string, e *ghEvent) []string {
+	repo := Colorize(ghColorRepo, "["+e.Repository.FullName+"]")
+	sender := Colorize(ghColorNick, e.Sender.Login)
+
+	switch event {
+	case "push":
+		branch := strings.TrimPrefix(strings.TrimPrefix(e.Ref, "refs/heads/"), "refs/tags/")
+		who := e.Pusher.Name
+		if who == "" {
+			who = e.Sender.Login
+		}
+		who = Colorize(ghColorNick, who)
+		switch {
+		case e.Deleted:
+			return []string{fmt.Sprintf("%s %s %s %s", repo, who, Colorize(ColorRed, "deleted"), Colorize(ghColorBranch, branch))}
+		case len(e.Commits) == 0:
+			if e.Created {
+				return []string{fmt.Sprintf("%s %s created %s: %s", repo, who, Colorize(ghColorBranch, branch), e.Compare)}
+			}
+			return nil
+		}
+		verb := "pushed"
+		if e.Forced {
+			verb = Colorize(ColorRed, "force-pushed")
+		}
+		noun := "commits"
+		if len(e.Commits) == 1 {
+			noun = "commit"
+		}
+		lines := []string{fmt.Sprintf("%s %s %s %d %s to %s: %s", repo, who, verb, len(e.Commits), noun, Colorize(ghColorBranch, branch), e.Compare)}
+		for i, commit := range e.Commits {
+			if i == ghMaxCommits {
+				lines = append(lines, fmt.Sprintf("  ... and %d more", len(e.Commits)-ghMaxCommits))
+				break
+			}
+			author := commit.Author.Username
+			if author == "" {
+				author = commit.Author.Name
+			}
+			id := commit.ID
+			if len(id) > 7 {
+				id = id[:7]
+			}
+			msg, _, _ := strings.Cut(commit.Message, "\n")
+			lines = append(lines, fmt.Sprintf("  %s %s (%s)", Colorize(ghColorHash, id), msg, Colorize(ghColorNick, author)))
+		}
+		return lines
+
+	case "pull_request":
+		pr := e.PullRequest
+		if pr == nil {
+			return nil
+		}
+		var action string
+		switch e.Action {
+		case "opened":
+			action = Colorize(ColorGreen, "opened")
+			if pr.Draft {
+				action = Colorize(ColorGreen, "opened draft")
+			}
+		case "reopened":
+			action = Colorize(ColorGreen, "reopened")
+		case "ready_for_review":
+			action = Colorize(ColorGreen, "marked ready for review")
+		case "closed":
+			action = Colorize(ColorRed, "closed")
+			if pr.Merged {
+				action = Colorize(ColorPurple, "merged")
+			}
+		default:
+			return nil
+		}
+		return []string{fmt.Sprintf("%s %s %s PR #%d into %s: %s %s", repo, sender, action, pr.Number, Colorize(ghColorBranch, pr.Base.Ref), pr.Title, pr.HTMLURL)}
+
+	case "issues":
+		issue := e.Issue
+		if issue == nil {
+			return nil
+		}
+		var action string
+		switch e.Action {
+		case "opened", "reopened":
+			action = Colorize(ColorGreen, e.Action)
+		case "closed":
+			action = Colorize(ColorRed, "closed")
+		default:
+			return nil
+		}
+		return []string{fmt.Sprintf("%s %s %s issue #%d: %s %s", repo, sender, action, issue.Number, issue.Title, issue.HTMLURL)}
+
+	case "release":
+		rel := e.Release
+		if rel == nil || e.Action != "published" {
+			return nil
+		}
+		name := rel.TagName
+		if rel.Name != "" && rel.Name != rel.TagName {
+			name = fmt.Sprintf("%s (%s)", rel.TagName, rel.Name)
+		}
+		kind := "release"
+		if rel.Prerelease {
+			kind = "pre-release"
+		}
+		return []string{fmt.Sprintf("%s %s published %s %s: %s", repo, sender, kind, Colorize(ColorGreen, name), rel.HTMLURL)}
+	}
+	return nil
+}
+
+// channelsFor returns the channels a repository's events are routed to
+func (h *Webhook) channelsFor(repo string) []string {
+	if channels, ok := h.Repos[strings.ToLower(repo)]; ok {
+		return channels
+	}
+	return h.Channels
+}
+
+func (h *Webhook) wantsEvent(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGitHubWebhook announces a GitHub event whose signature (if a secret
+// is configured) has already been verified
+func (a *API) handleGitHubWebhook(w http.ResponseWriter, r *http.Request, name string, hook *Webhook, body []byte) {
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		writeJSON(w, 200, map[string]string{"status": "pong"})
+		return
+	}
+	if event == "" {
+		writeError(w, 400, codeInvalidRequest, "missing X-GitHub-Event header")
+		return
+	}
+	var e ghEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		writeError(w, 400, codeInvalidRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	channels := hook.channelsFor(e.Repository.FullName)
+	if !hook.wantsEvent(event) || len(channels) == 0 {
+		writeJSON(w, 200, map[string]any{"status": "skipped", "lines": 0})
+		return
+	}
+	a.relayWebhook(w, name, hook, channels, formatGitHubEvent(event, &e))
+}
@@ -0,0 +1,92 @@
+package irc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func signGitHub(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestFormatGitHubEvent(t *testing.T) {
+	push := &ghEvent{Ref: "refs/heads/main", Compare: "https://github.com/o/r/compare/a...b"}
+	push.Repository.FullName = "o/r"
+	push.Pusher.Name = "alice"
+	for _, msg := range []string{"Fix bug\n\nDetails", "Two", "Three", "Four"} {
+		push.Commits = append(push.Commits, ghCommit{ID: "0123456789abcdef", Message: msg})
+	}
+	got := formatGitHubEvent("push", push)
+	if len(got) != 5 {
+		t.Fatalf("Expected summary, 3 commits and a remainder line, got %q", got)
+	}
+	if want := "\x0313[o/r]\x03 \x0315alice\x03 pushed 4 commits to \x0306main\x03: https://github.com/o/r/compare/a...b"; got[0] != want {
+		t.Errorf("Unexpected push summary %q", got[0])
+	}
+	if !strings.Contains(got[1], "\x03140123456\x03 Fix bug (") || got[4] != "  ... and 1 more" {
+		t.Errorf("Unexpected commit lines %q", got[1:])
+	}
+
+	var pr ghEvent
+	pr.Action = "closed"
+	pr.Repository.FullName = "o/r"
+	pr.Sender.Login = "bob"
+	pr.PullRequest = &ghPullRequest{Number: 7, Title: "Add feature", HTMLURL: "https://github.com/o/r/pull/7", Merged: true}
+	pr.PullRequest.Base.Ref = "main"
+	if got := formatGitHubEvent("pull_request", &pr); len(got) != 1 || !strings.Contains(got[0], "\x0306merged\x03 PR #7") {
+		t.Errorf("Unexpected pull_request lines %q", got)
+	}
+	pr.Action = "labeled"
+	if got := formatGitHubEvent("pull_request", &pr); got != nil {
+		t.Errorf("Expected unannounced action to be ignored, got %q", got)
+	}
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	t.Setenv("WEBHOOK_CONFIG", `{"webhooks": {"gh": {
+		"type": "github",
+		"secret": "shh",
+		"channels": ["#dev"],
+		"repos": {"Owner/Special": ["#special"]}
+	}}}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	markConnected(client)
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	api := client.CreateAPI("api-token")
+
+	post := func(event, body, sig string) int {
+		req := httptest.NewRequest("POST", "/api/webhook/gh", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", sig)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	body := `{"action":"opened","repository":{"full_name":"owner/special"},"sender":{"login":"carol"},"issue":{"number":3,"title":"Crash","html_url":"https://github.com/owner/special/issues/3"}}`
+	if code := post("issues", body, signGitHub("wrong", body)); code != 401 {
+		t.Errorf("Expected 401 for a bad signature, got %d", code)
+	}
+	if code := post("issues", body, signGitHub("shh", body)); code != 200 {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	want := []string{"PRIVMSG #special :\x0313[owner/special]\x03 \x0315carol\x03 \x0303opened\x03 issue #3: Crash https://github.com/owner/special/issues/3"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("Expected %q, sent %q", want, sent)
+	}
+
+	sent = nil
+	ping := `{"zen":"hi"}`
+	if code := post("ping", ping, signGitHub("shh", ping)); code != 200 || len(sent) != 0 {
+		t.Errorf("Expected ping to be acknowledged silently, got %d %q", code, sent)
+	}
+}
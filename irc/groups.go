@@ -0,0 +1,153 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// groupPrefix marks a channel group in API targets and trigger channel
+// filters, e.g. group:ops. The built-in group:all is every joined channel.
+const groupPrefix = "group:"
+
+// loadChannelGroups reads CHANNEL_GROUPS, a JSON object of group names and
+// their channels: {"ops": ["#ops", "#staff"]}
+func (c *Client) loadChannelGroups() {
+	configStr := os.Getenv("CHANNEL_GROUPS")
+	if configStr != "" {
+		var groups map[string][]string
+		if err := json.Unmarshal([]byte(configStr), &groups); err != nil {
+			log.Fatalf("FATAL: Invalid CHANNEL_GROUPS JSON: %v", err)
+		}
+		c.channelGroups = make(map[string][]string, len(groups))
+		for name, channels := range groups {
+			key := strings.ToLower(strings.TrimSpace(name))
+			if key == "" || key == "all" {
+				log.Fatalf("FATAL: Invalid CHANNEL_GROUPS name %q (all is built in)", name)
+			}
+			if len(channels) == 0 {
+				log.Fatalf("FATAL: CHANNEL_GROUPS group %s has no channels", name)
+			}
+			for _, ch := range channels {
+				if !isChannelName(ch) || strings.ContainsAny(ch, " ,") {
+					log.Fatalf("FATAL: CHANNEL_GROUPS group %s has an invalid channel %q", name, ch)
+				}
+			}
+			c.channelGroups[key] = channels
+		}
+		log.Printf("Loaded %d channel groups", len(c.channelGroups))
+	}
+	for name, endpoint := range c.triggerConfig.Endpoints {
+		for _, ch := range endpoint.Channels {
+			if group, ok := groupName(ch); ok && group != "all" && c.channelGroups[group] == nil {
+				log.Fatalf("FATAL: Trigger endpoint %s filters on unknown channel group %q", name, group)
+			}
+		}
+	}
+}
+
+// groupName returns the lower-cased group of a group:name target
+func groupName(target string) (string, bool) {
+	if len(target) <= len(groupPrefix) || !strings.EqualFold(target[:len(groupPrefix)], groupPrefix) {
+		return "", false
+	}
+	return strings.ToLower(target[len(groupPrefix):]), true
+}
+
+// expandTarget returns the channels of a group:name target, sorted, or the
+// target itself when it is not a group
+func (c *Client) expandTarget(target string) (targets []string, group bool, err error) {
+	name, ok := groupName(target)
+	if !ok {
+		return []string{target}, false, nil
+	}
+	if name == "all" {
+		targets = c.Channels()
+	} else {
+		targets = append(targets, c.channelGroups[name]...)
+		if len(targets) == 0 {
+			return nil, true, fmt.Errorf("unknown channel group %q", name)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, true, fmt.Errorf("channel group %s is empty: the bot is in no channels", name)
+	}
+	sort.Strings(targets)
+	return targets, true, nil
+}
+
+// inChannels reports whether channel is in a trigger filter list of
+// channels and group:name entries
+func (c *Client) inChannels(list []string, channel string) bool {
+	for _, entry := range list {
+		name, ok := groupName(entry)
+		switch {
+		case !ok:
+			if strings.EqualFold(entry, channel) {
+				return true
+			}
+		case name == "all":
+			if containsFold(c.Channels(), channel) {
+				return true
+			}
+		default:
+			if containsFold(c.channelGroups[name], channel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sendToTarget sends a message to target, or to every channel of a
+// group:name target, and writes the response. A group answers with the
+// status of each message; it fails only when no message could be sent. kind
+// names the SentMessage kind of each target.
+func (a *API) sendToTarget(w http.ResponseWriter, r *http.Request, kind func(target string) string, target string, send func(target string) error) {
+	targets, group, err := a.bot.expandTarget(target)
+	if err != nil {
+		writeError(w, 404, codeNotFound, err.Error())
+		return
+	}
+	if !group {
+		sent, err := a.bot.trackSend(kind(target), target, requestID(r.Context()), func() error { return send(target) })
+		if err != nil {
+			writeSendError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]string{"status": sent.Status, "id": sent.ID})
+		return
+	}
+
+	var messages []SentMessage
+	var firstErr error
+	for _, t := range targets {
+		sent, err := a.bot.trackSend(kind(t), t, requestID(r.Context()), func() error { return send(t) })
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		messages = append(messages, sent)
+	}
+	status := "sent"
+	if firstErr != nil {
+		if allFailed(messages) {
+			writeSendError(w, firstErr)
+			return
+		}
+		status = "partial"
+	}
+	writeJSON(w, 200, map[string]any{"status": status, "group": target, "messages": messages})
+}
+
+func allFailed(messages []SentMessage) bool {
+	for _, m := range messages {
+		if m.Status != "failed" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChannelGroupsSend(t *testing.T) {
+	t.Setenv("CHANNEL_GROUPS", `{"ops": ["#staff", "#ops"]}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	api := client.CreateAPI("token")
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/send", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"target":"group:OPS","message":"maintenance at 10"}`)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Status   string
+		Messages []SentMessage
+	}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if out.Status != "sent" || len(out.Messages) != 2 || out.Messages[0].Target != "#ops" || out.Messages[0].ID == "" {
+		t.Errorf("Unexpected response: %s", rec.Body.String())
+	}
+	want := "PRIVMSG #ops :maintenance at 10\nPRIVMSG #staff :maintenance at 10"
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if rec := post(`{"target":"group:nope","message":"x"}`); rec.Code != 404 {
+		t.Errorf("Expected 404 for an unknown group, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"target":"group:all","message":"x"}`); rec.Code != 404 {
+		t.Errorf("Expected 404 for group:all in no channels, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	client.handleLine(":TestBot!b@host JOIN #general")
+	lines = nil
+	if rec := post(`{"target":"group:all","message":"hello all"}`); rec.Code != 200 {
+		t.Errorf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(lines) != 1 || lines[0] != "PRIVMSG #general :hello all" {
+		t.Errorf("Expected group:all to reach the joined channel, got %q", lines)
+	}
+}
+
+func TestChannelGroupsTriggerFilter(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	t.Setenv("TRIGGER_CONFIG", strings.Replace(getenv("TRIGGER_CONFIG", ""), `"events"`, `"channels":["group:ops"],"events"`, 1))
+	t.Setenv("CHANNEL_GROUPS", `{"ops": ["#ops", "#staff"]}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host PRIVMSG #general :not for ops")
+	client.handleLine(":alice!a@host PRIVMSG #Staff :for ops")
+	if p := expectTrigger(t, received); p.Target != "#Staff" {
+		t.Errorf("Expected the #Staff message, got %+v", p)
+	}
+	expectNoTrigger(t, received)
+}
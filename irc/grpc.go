@@ -0,0 +1,495 @@
+package irc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gRPC status codes returned by the server
+const (
+	grpcOK               = 0
+	grpcInvalidArgument  = 3
+	grpcPermissionDenied = 7
+	grpcResourceExhaust  = 8
+	grpcFailedPrecond    = 9
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+// grpcMaxMessage is the largest request message accepted, the usual gRPC default
+const grpcMaxMessage = 4 << 20
+
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+func grpcErrorf(code int, format string, args ...any) error {
+	return &grpcError{code, fmt.Sprintf(format, args...)}
+}
+
+// grpcServer implements the hanna.v1.Hanna service described in
+// proto/hanna/v1/hanna.proto. It speaks the gRPC wire protocol directly on
+// net/http, so it has to be served over HTTP/2 (TLS or h2c).
+type grpcServer struct {
+	bot *Client
+}
+
+// GRPCHandler returns the gRPC interface, authenticated with the same bearer
+// tokens as the REST API
+func (c *Client) GRPCHandler(token string) http.Handler {
+	c.tokens.setPrimary(token)
+	return &grpcServer{bot: c}
+}
+
+func (s *grpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	code, msg := grpcOK, ""
+	if err := s.call(w, r); err != nil {
+		var gerr *grpcError
+		if !errors.As(err, &gerr) {
+			gerr = &grpcError{grpcInternal, err.Error()}
+		}
+		code, msg = gerr.code, gerr.msg
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", url.PathEscape(msg))
+	}
+}
+
+// call authenticates the request and dispatches it to its method
+func (s *grpcServer) call(w http.ResponseWriter, r *http.Request) error {
+	if !s.bot.addrAllowed(r.RemoteAddr) {
+		return grpcErrorf(grpcPermissionDenied, "client address not allowed")
+	}
+	if !s.bot.tokens.configured() {
+		return grpcErrorf(grpcUnauthenticated, "API_TOKEN not set on server")
+	}
+	if !s.bot.tokens.valid(bearerToken(r)) {
+		return grpcErrorf(grpcUnauthenticated, "invalid or missing bearer token")
+	}
+
+	method := strings.TrimPrefix(r.URL.Path, "/hanna.v1.Hanna/")
+	switch method {
+	case "Events":
+		return s.events(w, r)
+	case "Session":
+		s.bot.touchActivity()
+		return s.session(w, r)
+	}
+
+	var handle func(*http.Request, []pbField) (pbWriter, error)
+	switch method {
+	case "Send":
+		handle = s.send
+	case "Join":
+		handle = s.join
+	case "Part":
+		handle = s.part
+	case "State":
+		handle = s.state
+	default:
+		return grpcErrorf(grpcUnimplemented, "unknown method %s", r.URL.Path)
+	}
+	req, err := readGRPCMessage(r.Body)
+	if err == io.EOF {
+		return grpcErrorf(grpcInvalidArgument, "missing request message")
+	} else if err != nil {
+		return err
+	}
+	fields, err := pbFields(req)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid request: %v", err)
+	}
+	// Polling state does not end auto-away, like GET requests of the REST API
+	if method != "State" {
+		s.bot.touchActivity()
+	}
+	resp, err := handle(r, fields)
+	if err != nil {
+		return err
+	}
+	return writeGRPCMessage(w, resp.buf)
+}
+
+// readGRPCMessage reads one length-prefixed message, returning io.EOF at the
+// clean end of the request stream
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, grpcErrorf(grpcInvalidArgument, "truncated message")
+		}
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > grpcMaxMessage {
+		return nil, grpcErrorf(grpcResourceExhaust, "message larger than %d bytes", grpcMaxMessage)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "truncated message")
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	if _, err := w.Write(append(frame, msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+func (s *grpcServer) send(r *http.Request, fields []pbField) (pbWriter, error) {
+	var target, message, format string
+	var notice bool
+	for _, f := range fields {
+		switch f.Num {
+		case 1:
+			target = f.String()
+		case 2:
+			message = f.String()
+		case 3:
+			format = f.String()
+		case 4:
+			notice = f.Bool()
+		}
+	}
+	if target == "" || message == "" {
+		return pbWriter{}, grpcErrorf(grpcInvalidArgument, "target and message required")
+	}
+	scope := "send"
+	if notice {
+		scope = "notice"
+	}
+	if err := s.bot.checkScope(scope, r.Header); err != nil {
+		return pbWriter{}, grpcErrorf(grpcPermissionDenied, "%v", err)
+	}
+	message, err := applyMessageFormat(format, message)
+	if err != nil {
+		return pbWriter{}, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	send := s.bot.Privmsg
+	if notice {
+		send = s.bot.Notice
+	}
+	if err := send(target, message); err != nil {
+		if errors.Is(err, errNotConnected) {
+			return pbWriter{}, grpcErrorf(grpcUnavailable, "%v", err)
+		}
+		return pbWriter{}, grpcErrorf(grpcInternal, "write failed: %v", err)
+	}
+	return pbWriter{}, nil
+}
+
+func (s *grpcServer) join(r *http.Request, fields []pbField) (pbWriter, error) {
+	var channel string
+	var force bool
+	for _, f := range fields {
+		switch f.Num {
+		case 1:
+			channel = f.String()
+		case 2:
+			force = f.Bool()
+		}
+	}
+	if channel == "" {
+		return pbWriter{}, grpcErrorf(grpcInvalidArgument, "channel required")
+	}
+	if err := s.bot.checkScope("join", r.Header); err != nil {
+		return pbWriter{}, grpcErrorf(grpcPermissionDenied, "%v", err)
+	}
+	if err := s.bot.checkChannelLength(channel); err != nil {
+		return pbWriter{}, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	if err := s.bot.guardJoin(r.Context(), channel, force); err != nil {
+		var refused *joinRefusedError
+		if errors.As(err, &refused) {
+			return pbWriter{}, grpcErrorf(grpcFailedPrecond, "%v", err)
+		}
+		return pbWriter{}, grpcErrorf(grpcUnavailable, "LIST request failed: %v", err)
+	}
+	s.bot.Join(channel)
+	return pbWriter{}, nil
+}
+
+func (s *grpcServer) part(r *http.Request, fields []pbField) (pbWriter, error) {
+	var channel, reason string
+	for _, f := range fields {
+		switch f.Num {
+		case 1:
+			channel = f.String()
+		case 2:
+			reason = f.String()
+		}
+	}
+	if channel == "" {
+		return pbWriter{}, grpcErrorf(grpcInvalidArgument, "channel required")
+	}
+	if err := s.bot.checkScope("part", r.Header); err != nil {
+		return pbWriter{}, grpcErrorf(grpcPermissionDenied, "%v", err)
+	}
+	s.bot.Part(channel, reason)
+	return pbWriter{}, nil
+}
+
+func (s *grpcServer) state(*http.Request, []pbField) (pbWriter, error) {
+	var out pbWriter
+	out.bool(1, s.bot.Connected())
+	out.string(2, s.bot.State().String())
+	out.string(3, s.bot.Nick())
+	out.string(4, s.bot.UserModes())
+	away := s.bot.Away()
+	out.bool(5, away.Away)
+	out.string(6, away.Message)
+
+	states := s.bot.GetChannelStates()
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var ch pbWriter
+		ch.string(1, name)
+		nicks := make([]string, 0, len(states[name]))
+		for nick := range states[name] {
+			nicks = append(nicks, nick)
+		}
+		sort.Strings(nicks)
+		for _, nick := range nicks {
+			var m pbWriter
+			m.string(1, nick)
+			modes, _ := states[name][nick].(string)
+			m.string(2, modes)
+			ch.message(2, m)
+		}
+		out.message(7, ch)
+	}
+	return out, nil
+}
+
+// parseEventsRequest builds the filter of an EventsRequest
+func parseEventsRequest(b []byte) (eventFilter, error) {
+	fields, err := pbFields(b)
+	if err != nil {
+		return eventFilter{}, grpcErrorf(grpcInvalidArgument, "invalid request: %v", err)
+	}
+	var types []string
+	var channel string
+	for _, f := range fields {
+		switch f.Num {
+		case 1:
+			types = append(types, f.String())
+		case 2:
+			channel = f.String()
+		}
+	}
+	return newEventFilter(types, channel), nil
+}
+
+func encodeEvent(e Event) pbWriter {
+	var out pbWriter
+	out.string(1, e.Type)
+	out.int64(2, e.Time.UnixMilli())
+	out.string(3, e.Prefix)
+	out.string(4, e.Sender)
+	out.string(5, e.Target)
+	out.string(6, e.Nick)
+	out.strings(7, e.Args)
+	out.string(8, e.Text)
+	out.string(9, e.Message)
+	out.strings(10, e.Channels)
+	keys := make([]string, 0, len(e.Tags))
+	for k := range e.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry pbWriter
+		entry.string(1, k)
+		entry.string(2, e.Tags[k])
+		out.message(11, entry)
+	}
+	out.bool(12, e.Self)
+	out.string(13, e.Netsplit)
+	out.bool(14, e.Rejoin)
+	out.bool(15, e.Replayed)
+	out.bool(16, e.Dropped)
+	return out
+}
+
+// events streams bus events matching the request until the client cancels
+func (s *grpcServer) events(w http.ResponseWriter, r *http.Request) error {
+	req, err := readGRPCMessage(r.Body)
+	if err == io.EOF {
+		return grpcErrorf(grpcInvalidArgument, "missing request message")
+	} else if err != nil {
+		return err
+	}
+	filter, err := parseEventsRequest(req)
+	if err != nil {
+		return err
+	}
+	closeStream, ok := s.bot.drain.openStream()
+	if !ok {
+		return grpcErrorf(grpcUnavailable, "shutting down")
+	}
+	defer closeStream()
+
+	events, unsubscribe := s.bot.bus.SubscribeChan("grpc_stream", 256)
+	defer unsubscribe()
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-s.bot.drain.done():
+			return s.endStream(w)
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if !filter.match(e) {
+				continue
+			}
+			if err := writeGRPCMessage(w, encodeEvent(e).buf); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// endStream sends the shutting_down event and ends a stream as unavailable,
+// so clients reconnect once the bot is back
+func (s *grpcServer) endStream(w http.ResponseWriter) error {
+	_ = writeGRPCMessage(w, encodeEvent(shutdownEvent()).buf)
+	return grpcErrorf(grpcUnavailable, "shutting down")
+}
+
+// session runs a bidirectional stream: requests from the client are executed
+// in order while matching events are streamed back. An invalid request ends
+// the stream with its error.
+func (s *grpcServer) session(w http.ResponseWriter, r *http.Request) error {
+	type request struct {
+		fields []pbField
+		err    error
+	}
+	requests := make(chan request)
+	go func() {
+		for {
+			msg, err := readGRPCMessage(r.Body)
+			var fields []pbField
+			if err == nil {
+				if fields, err = pbFields(msg); err != nil {
+					err = grpcErrorf(grpcInvalidArgument, "invalid request: %v", err)
+				}
+			}
+			select {
+			case requests <- request{fields, err}:
+			case <-r.Context().Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	closeStream, ok := s.bot.drain.openStream()
+	if !ok {
+		return grpcErrorf(grpcUnavailable, "shutting down")
+	}
+	defer closeStream()
+
+	events, unsubscribe := s.bot.bus.SubscribeChan("grpc_session", 256)
+	defer unsubscribe()
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+
+	var filter *eventFilter // nil until the first subscribe
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-s.bot.drain.done():
+			return s.endStream(w)
+		case req := <-requests:
+			if req.err == io.EOF {
+				// The client finished sending; keep streaming events
+				requests = nil
+				continue
+			} else if req.err != nil {
+				return req.err
+			}
+			for _, f := range req.fields {
+				var err error
+				switch f.Num {
+				case 1:
+					var next eventFilter
+					if next, err = parseEventsRequest(f.Bytes); err == nil {
+						filter = &next
+					}
+				case 2:
+					err = sessionCall(r, f, s.send)
+				case 3:
+					err = sessionCall(r, f, s.join)
+				case 4:
+					err = sessionCall(r, f, s.part)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if filter == nil || !filter.match(e) {
+				continue
+			}
+			if err := writeGRPCMessage(w, encodeEvent(e).buf); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// sessionCall runs a unary method on a request embedded in a SessionRequest
+func sessionCall(r *http.Request, f pbField, handle func(*http.Request, []pbField) (pbWriter, error)) error {
+	fields, err := pbFields(f.Bytes)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "invalid request: %v", err)
+	}
+	_, err = handle(r, fields)
+	return err
+}
@@ -0,0 +1,176 @@
+package irc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// startGRPC serves the gRPC interface over h2c and returns a client for it
+func startGRPC(t *testing.T, client *Client) (string, *http.Client) {
+	t.Helper()
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := httptest.NewUnstartedServer(client.GRPCHandler("secret"))
+	srv.Config.Protocols = &protocols
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv.URL, &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+func grpcRequest(t *testing.T, url, method, token string, body io.Reader) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("POST", url+"/hanna.v1.Hanna/"+method, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// grpcCall makes a unary call, returning the response message and status
+func grpcCall(t *testing.T, url string, hc *http.Client, method, token string, msg pbWriter) ([]byte, string) {
+	t.Helper()
+	resp, err := hc.Do(grpcRequest(t, url, method, token, bytes.NewReader(grpcFrame(msg.buf))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, err := readGRPCMessage(resp.Body)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return out, resp.Trailer.Get("Grpc-Status")
+}
+
+func TestGRPCUnary(t *testing.T) {
+	client := NewClient()
+	url, hc := startGRPC(t, client)
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+
+	var req pbWriter
+	req.string(1, "#test")
+	req.string(2, "**hello**")
+	req.string(3, "markdown")
+	if _, status := grpcCall(t, url, hc, "Send", "wrong", req); status != "16" {
+		t.Errorf("Expected UNAUTHENTICATED with a bad token, got %q", status)
+	}
+	if _, status := grpcCall(t, url, hc, "Send", "secret", req); status != "0" {
+		t.Fatalf("Send failed with status %q", status)
+	}
+	if line := <-sent; line != "PRIVMSG #test :\x02hello\x02" {
+		t.Errorf("Unexpected line %q", line)
+	}
+
+	if _, status := grpcCall(t, url, hc, "Join", "secret", pbWriter{}); status != "3" {
+		t.Errorf("Expected INVALID_ARGUMENT without a channel, got %q", status)
+	}
+	if _, status := grpcCall(t, url, hc, "Kick", "secret", pbWriter{}); status != "12" {
+		t.Errorf("Expected UNIMPLEMENTED for an unknown method, got %q", status)
+	}
+
+	client.handleLine(":TestBot!bot@host JOIN #test")
+	client.handleLine(":server 353 TestBot = #test :TestBot @alice")
+	out, status := grpcCall(t, url, hc, "State", "secret", pbWriter{})
+	if status != "0" {
+		t.Fatalf("State failed with status %q", status)
+	}
+	fields, err := pbFields(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []string
+	for _, f := range fields {
+		if f.Num != 7 {
+			continue
+		}
+		channel, _ := pbFields(f.Bytes)
+		for _, cf := range channel {
+			if cf.Num == 2 {
+				member, _ := pbFields(cf.Bytes)
+				m := member[0].String()
+				if len(member) > 1 {
+					m += "+" + member[1].String()
+				}
+				members = append(members, m)
+			}
+		}
+	}
+	if len(members) != 2 || members[0] != "TestBot" || members[1] != "alice+o" {
+		t.Errorf("Unexpected members %v", members)
+	}
+}
+
+func TestGRPCSession(t *testing.T) {
+	client := NewClient()
+	url, hc := startGRPC(t, client)
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	respc := make(chan *http.Response, 1)
+	go func() {
+		resp, err := hc.Do(grpcRequest(t, url, "Session", "secret", pr))
+		if err != nil {
+			t.Error(err)
+			close(respc)
+			return
+		}
+		respc <- resp
+	}()
+
+	var filter, subscribe pbWriter
+	filter.strings(1, []string{"privmsg"})
+	subscribe.message(1, filter)
+	pw.Write(grpcFrame(subscribe.buf))
+	resp := <-respc
+	if resp == nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var join, request pbWriter
+	join.string(1, "#test")
+	request.message(3, join)
+	pw.Write(grpcFrame(request.buf))
+	select {
+	case line := <-sent:
+		if line != "JOIN #test" {
+			t.Errorf("Unexpected line %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Join was not executed")
+	}
+
+	client.handleLine(":alice!a@host JOIN #test")
+	client.handleLine(":alice!a@host PRIVMSG #test :hi there")
+	msg, err := readGRPCMessage(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := pbFields(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]string{}
+	for _, f := range fields {
+		got[f.Num] = f.String()
+	}
+	if got[1] != "privmsg" || got[4] != "alice" || got[8] != "hi there" {
+		t.Errorf("Unexpected event %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package irc
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// readinessLimits are the thresholds /readyz checks against
+type readinessLimits struct {
+	maxLag                time.Duration
+	maxSendQueue          int
+	maxWebhookFailureRate float64 // 0 reports the rate without failing on it
+}
+
+func (c *Client) loadReadiness() {
+	c.readiness = readinessLimits{
+		maxLag:                time.Duration(intenv("READY_MAX_LAG_SECONDS", 30)) * time.Second,
+		maxSendQueue:          intenv("READY_MAX_SEND_QUEUE", 50),
+		maxWebhookFailureRate: float64(intenv("READY_MAX_WEBHOOK_FAILURE_PERCENT", 0)) / 100,
+	}
+	c.startTime = time.Now()
+	c.saslStatus.Store("disabled")
+}
+
+// probeCheck is one line of the /readyz report
+type probeCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Value  any    `json:"value,omitempty"`
+}
+
+// readinessChecks evaluates every readiness probe
+func (c *Client) readinessChecks() map[string]probeCheck {
+	checks := make(map[string]probeCheck)
+
+	info := c.StateInfo()
+	checks["registered"] = probeCheck{OK: info.State == StateConnected, Detail: info.State.String(), Value: info.Reason}
+
+	// SASL only fails readiness when it is configured and did not succeed
+	sasl := c.saslStatus.Load().(string)
+	checks["sasl"] = probeCheck{OK: sasl == "disabled" || sasl == "succeeded", Detail: sasl}
+
+	if lag, ok := c.Lag(); ok {
+		checks["lag"] = probeCheck{OK: lag <= c.readiness.maxLag, Detail: lag.Round(time.Millisecond).String(), Value: lag.Milliseconds()}
+	} else {
+		// A PING is sent right after registration; until then there is no figure
+		checks["lag"] = probeCheck{OK: true, Detail: "not measured yet"}
+	}
+
+	depth := int(c.pendingWrites.Load())
+	checks["send_queue"] = probeCheck{OK: depth <= c.readiness.maxSendQueue, Value: depth}
+
+	webhooks := probeCheck{OK: true}
+	var worst float64
+	for _, h := range c.TriggerHealth() {
+		if h.FailureRate > worst {
+			worst = h.FailureRate
+			webhooks.Detail = h.Name
+		}
+	}
+	webhooks.Value = worst
+	if c.readiness.maxWebhookFailureRate > 0 && worst > c.readiness.maxWebhookFailureRate {
+		webhooks.OK = false
+	}
+	checks["webhooks"] = webhooks
+	return checks
+}
+
+// handleHealthz is the liveness probe: the process is up and serving
+func (a *API) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]any{
+		"ok":     true,
+		"uptime": time.Since(a.bot.startTime).Round(time.Second).String(),
+		"state":  a.bot.State(),
+	})
+}
+
+// handleReadyz is the readiness probe: 200 when the bot is registered and
+// healthy, 503 with the failing checks otherwise
+func (a *API) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := a.bot.readinessChecks()
+	failed := []string{}
+	for name, check := range checks {
+		if !check.OK {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	code := 200
+	if len(failed) > 0 {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"ready": len(failed) == 0, "failed": failed, "checks": checks})
+}
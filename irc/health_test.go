@@ -0,0 +1,78 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readyz(t *testing.T, client *Client) (int, map[string]probeCheck) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	client.CreateAPI("token").ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	var resp struct {
+		Ready  bool
+		Failed []string
+		Checks map[string]probeCheck
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if resp.Ready != (rec.Code == 200) {
+		t.Errorf("ready=%v does not match status %d", resp.Ready, rec.Code)
+	}
+	return rec.Code, resp.Checks
+}
+
+func TestReadinessProbe(t *testing.T) {
+	t.Setenv("READY_MAX_LAG_SECONDS", "5")
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+
+	rec := httptest.NewRecorder()
+	client.CreateAPI("token").ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"state":"disconnected"`) {
+		t.Errorf("Liveness should pass while disconnected, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	code, checks := readyz(t, client)
+	if code != 503 || checks["registered"].OK {
+		t.Errorf("Expected not ready while disconnected, got %d %+v", code, checks["registered"])
+	}
+
+	markConnected(client)
+	code, checks = readyz(t, client)
+	if code != 200 || checks["lag"].Detail != "not measured yet" || checks["sasl"].Detail != "disabled" {
+		t.Errorf("Expected ready once registered, got %d %+v", code, checks)
+	}
+
+	client.handleLine(fmt.Sprintf(":server PONG server :%s%d", lagTokenPrefix, time.Now().Add(-200*time.Millisecond).UnixNano()))
+	if lag, ok := client.Lag(); !ok || lag < 200*time.Millisecond || lag > 5*time.Second {
+		t.Errorf("Unexpected lag %s (measured %v)", lag, ok)
+	}
+
+	client.handleLine(fmt.Sprintf(":server PONG server :%s%d", lagTokenPrefix, time.Now().Add(-10*time.Second).UnixNano()))
+	if code, checks = readyz(t, client); code != 503 || checks["lag"].OK {
+		t.Errorf("Expected lag above the limit to fail readiness, got %d %+v", code, checks["lag"])
+	}
+}
+
+func TestReadinessWebhookFailures(t *testing.T) {
+	t.Setenv("READY_MAX_WEBHOOK_FAILURE_PERCENT", "50")
+	t.Setenv("TRIGGER_CONFIG", `{"endpoints":{"n8n":{"url":"http://127.0.0.1:1/hook","events":["mention"]}}}`)
+	client := NewClient()
+	markConnected(client)
+
+	client.recordTriggerResult("n8n", 200, nil)
+	client.recordTriggerResult("n8n", 500, nil)
+	if code, checks := readyz(t, client); code != 200 || checks["webhooks"].Value != 0.5 {
+		t.Errorf("A 50%% failure rate should still be ready, got %d %+v", code, checks["webhooks"])
+	}
+	client.recordTriggerResult("n8n", 0, fmt.Errorf("connection refused"))
+	if code, checks := readyz(t, client); code != 503 || checks["webhooks"].Detail != "n8n" {
+		t.Errorf("Expected the failing endpoint to fail readiness, got %d %+v", code, checks["webhooks"])
+	}
+}
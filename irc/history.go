@@ -0,0 +1,275 @@
+package irc
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNoHistory is returned when channel logging does not keep JSONL files,
+// which are the source of stored history
+var errNoHistory = errors.New("history requires CHANLOG_DIR with jsonl in CHANLOG_FORMATS")
+
+// errPageFull stops reading history once a page has its limit of entries
+var errPageFull = errors.New("page full")
+
+// historyEntries calls fn for every logged entry of a channel between from
+// and to (inclusive), in file order. Returning an error from fn stops the walk.
+// Only the day files that exist are read, however wide the range.
+func (l *channelLogger) historyEntries(channel string, from, to time.Time, fn func(LogEntry) error) error {
+	if l == nil || !l.jsonl {
+		return errNoHistory
+	}
+	dir := l.channelDir(channel)
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	first, last := from.Local().Format("2006-01-02"), to.Local().Format("2006-01-02")
+	for _, f := range files {
+		day, ok := strings.CutSuffix(f.Name(), ".jsonl")
+		if !ok || day < first || day > last {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		if err := readLogFile(path, func(e LogEntry) error {
+			if e.Time.Before(from) || e.Time.After(to) {
+				return nil
+			}
+			return fn(e)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readLogFile(path string, fn func(LogEntry) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("Skipping malformed log line in %s: %v", path, err)
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseTimeParam accepts RFC 3339 timestamps, YYYY-MM-DD dates (local time)
+// and unix seconds
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", v)
+}
+
+// historyRange reads the from/to query parameters. "to" defaults to now and
+// "from" to 24 hours before "to"; a bare date for "to" covers that whole day.
+func historyRange(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+		if _, err := time.Parse("2006-01-02", v); err == nil {
+			to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if v := q.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// parseTypeFilter reads a comma-separated list of entry types; empty when
+// the list is
+func parseTypeFilter(v string) map[string]bool {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(v, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types[t] = true
+		}
+	}
+	return types
+}
+
+// historyExporter writes log entries to w in one export format
+type historyExporter interface {
+	Write(LogEntry) error
+	Flush() error
+}
+
+type jsonlExporter struct{ enc *json.Encoder }
+
+func (x *jsonlExporter) Write(e LogEntry) error { return x.enc.Encode(e) }
+func (x *jsonlExporter) Flush() error           { return nil }
+
+type csvExporter struct{ w *csv.Writer }
+
+func (x *csvExporter) Write(e LogEntry) error {
+	return x.w.Write([]string{e.Time.Format(time.RFC3339), e.Channel, e.Type, e.Nick, e.Target, e.Message})
+}
+
+func (x *csvExporter) Flush() error {
+	x.w.Flush()
+	return x.w.Error()
+}
+
+// textExporter writes irssi-style logs with "Day changed" separators
+type textExporter struct {
+	w   *bufio.Writer
+	day string
+}
+
+func (x *textExporter) Write(e LogEntry) error {
+	if day := e.Time.Local().Format("2006-01-02"); day != x.day {
+		x.day = day
+		if _, err := fmt.Fprintf(x.w, "--- Day changed %s\n", e.Time.Local().Format("Mon Jan 02 2006")); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(x.w, formatLogText(e, "15:04:05"))
+	return err
+}
+
+func (x *textExporter) Flush() error { return x.w.Flush() }
+
+// newHistoryExporter sets the response headers for format and returns its exporter
+func newHistoryExporter(w http.ResponseWriter, format, channel string) (historyExporter, error) {
+	name := strings.TrimLeft(strings.NewReplacer("/", "_", "\\", "_").Replace(channel), "#&")
+	var x historyExporter
+	var ext string
+	switch format {
+	case "", "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		x, ext = &jsonlExporter{enc: json.NewEncoder(w)}, "jsonl"
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"time", "channel", "type", "nick", "target", "message"}); err != nil {
+			return nil, err
+		}
+		x, ext = &csvExporter{w: cw}, "csv"
+	case "text", "irssi":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		x, ext = &textExporter{w: bufio.NewWriter(w)}, "log"
+	default:
+		return nil, fmt.Errorf("unknown format %q (use jsonl, csv or text)", format)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+ext))
+	return x, nil
+}
+
+// handleHistoryExport streams a channel's stored history:
+// GET /api/history/export?channel=#chan&from=...&to=...&format=jsonl|csv|text
+func (a *API) handleHistoryExport(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		writeError(w, 400, codeInvalidChannel, "channel required")
+		return
+	}
+	from, to, err := historyRange(r)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+	p, err := parsePage(r)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+	// Optional filters: ?nick=alice&account=alice&type=privmsg,action
+	q := r.URL.Query()
+	nick, account := q.Get("nick"), q.Get("account")
+	types := parseTypeFilter(q.Get("type"))
+	if a.bot.chanlog == nil || !a.bot.chanlog.jsonl {
+		writeError(w, 503, codeUnavailable, errNoHistory.Error())
+		return
+	}
+	exporter, err := newHistoryExporter(w, strings.ToLower(r.URL.Query().Get("format")), channel)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	n, skipped := 0, 0
+	err = a.bot.chanlog.historyEntries(channel, from, to, func(e LogEntry) error {
+		if (nick != "" && !strings.EqualFold(e.Nick, nick)) || (account != "" && !strings.EqualFold(e.Account, account)) || (len(types) > 0 && !types[e.Type]) {
+			return nil
+		}
+		if skipped < p.Offset {
+			skipped++
+			return nil
+		}
+		if p.Limit > 0 && n >= p.Limit {
+			return errPageFull
+		}
+		if err := exporter.Write(e); err != nil {
+			return err
+		}
+		if n++; flusher != nil && n%500 == 0 {
+			if err := exporter.Flush(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil || errors.Is(err, errPageFull) {
+		err = exporter.Flush()
+	}
+	if err != nil {
+		// Headers are already sent; the client sees a truncated body
+		log.Printf("History export for %s failed after %d entries: %v", channel, n, err)
+		return
+	}
+	log.Printf("Exported %d history entries for %s", n, channel)
+}
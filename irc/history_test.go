@@ -0,0 +1,82 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryExport(t *testing.T) {
+	t.Setenv("CHANLOG_DIR", t.TempDir())
+	t.Setenv("CHANLOG_FORMATS", "jsonl")
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("token")
+
+	now := time.Now().UTC()
+	stamp := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339Nano) }
+	client.handleLine("@time=" + stamp(-3*time.Hour) + " :alice!a@host PRIVMSG #test :too old")
+	client.handleLine("@time=" + stamp(-time.Hour) + " :alice!a@host PRIVMSG #test :hello, world")
+	client.handleLine("@time=" + stamp(-time.Minute) + " :bob!b@host PRIVMSG #test :\x01ACTION waves\x01")
+
+	export := func(query string) (int, string, string) {
+		req := httptest.NewRequest("GET", "/api/history/export?"+query, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec.Code, rec.Header().Get("Content-Type"), rec.Body.String()
+	}
+	from := "from=" + now.Add(-2*time.Hour).Format(time.RFC3339)
+
+	code, ctype, body := export("channel=%23test&format=csv&" + from)
+	want := "time,channel,type,nick,target,message\n" +
+		now.Add(-time.Hour).Format(time.RFC3339) + ",#test,privmsg,alice,,\"hello, world\"\n" +
+		now.Add(-time.Minute).Format(time.RFC3339) + ",#test,action,bob,,waves\n"
+	if code != 200 || ctype != "text/csv; charset=utf-8" || body != want {
+		t.Errorf("Unexpected CSV export %d %q:\n%s", code, ctype, body)
+	}
+
+	_, _, body = export("channel=%23test&format=text&" + from)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "--- Day changed ") || !strings.HasSuffix(lines[1], " <alice> hello, world") || !strings.HasSuffix(lines[2], "  * bob waves") {
+		t.Errorf("Unexpected text export:\n%s", body)
+	}
+
+	_, ctype, body = export("channel=%23test&" + from)
+	if ctype != "application/x-ndjson" || strings.Count(body, "\n") != 2 || strings.Contains(body, "too old") {
+		t.Errorf("Unexpected JSONL export %q:\n%s", ctype, body)
+	}
+
+	// Paging and filters count only the matching entries
+	for query, want := range map[string]string{
+		"limit=1&offset=1": "bob",
+		"limit=1":          "alice",
+		"nick=ALICE":       "alice",
+		"type=action":      "bob",
+	} {
+		_, _, body = export("channel=%23test&format=csv&" + from + "&" + query)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		if len(lines) != 2 || strings.Split(lines[1], ",")[3] != want {
+			t.Errorf("%s: expected only %s's entry, got:\n%s", query, want, body)
+		}
+	}
+	// Ranges reaching far back read only the files that exist
+	start := time.Now()
+	_, _, body = export("channel=%23test&from=0001-01-01T00:00:00Z")
+	if strings.Count(body, "\n") != 3 || time.Since(start) > time.Second {
+		t.Errorf("Expected every entry from a range starting in year 1, quickly, got in %v:\n%s", time.Since(start), body)
+	}
+	if code, _, _ := export("channel=%23test&limit=-1"); code != 400 {
+		t.Errorf("Expected 400 for a negative limit, got %d", code)
+	}
+
+	if code, _, _ := export("channel=%23test&format=xml"); code != 400 {
+		t.Errorf("Expected 400 for an unknown format, got %d", code)
+	}
+	if code, _, _ := export("format=csv"); code != 400 {
+		t.Errorf("Expected 400 without a channel, got %d", code)
+	}
+}
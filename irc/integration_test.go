@@ -0,0 +1,134 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIntegrationRegistration(t *testing.T) {
+	t.Setenv("IRC_NICK", "TestBot")
+	srv := newFakeIRCd(t)
+	client := srv.client()
+
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fc := srv.accept()
+	if m := fc.expect("NICK"); m.Params[0] != "TestBot" {
+		t.Errorf("Expected NICK TestBot, got %q", m.Line)
+	}
+	fc.expect("USER")
+	fc.expect("CAP")
+	waitState(t, client, StateConnected)
+
+	if !client.messageTags.Load() {
+		t.Error("Expected message-tags to be acknowledged")
+	}
+	if network := client.getServerInfo().ISupportTags["NETWORK"]; network != "FakeNet" {
+		t.Errorf("Expected ISUPPORT NETWORK=FakeNet, got %q", network)
+	}
+
+	// Lines from the server reach the bus once registered
+	events := make(chan Event, 1)
+	client.bus.Subscribe("test", func(e Event) {
+		if e.Type == "privmsg" {
+			events <- e
+		}
+	})
+	fc.send(":alice!a@host PRIVMSG #test :hello")
+	select {
+	case e := <-events:
+		if e.Sender != "alice" || e.Text != "hello" {
+			t.Errorf("Unexpected event: %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the message")
+	}
+}
+
+func TestIntegrationCAPRefused(t *testing.T) {
+	srv := newFakeIRCd(t)
+	srv.caps = []string{"message-tags", "account-tag", "server-time", "batch"}
+	client := srv.client()
+
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Two of the three CAP REQs are refused; registration still completes
+	waitState(t, client, StateConnected)
+}
+
+func TestIntegrationSASL(t *testing.T) {
+	for _, tt := range []struct {
+		pass, status string
+	}{
+		{"secret", "succeeded"},
+		{"wrong", "failed"},
+	} {
+		t.Run(tt.status, func(t *testing.T) {
+			t.Setenv("SASL_USER", "bot")
+			t.Setenv("SASL_PASS", tt.pass)
+			srv := newFakeIRCd(t)
+			srv.saslUser, srv.saslPass = "bot", "secret"
+			client := srv.client()
+
+			if err := client.Dial(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			waitState(t, client, StateConnected)
+			if status := client.saslStatus.Load().(string); status != tt.status {
+				t.Errorf("Expected SASL %s, got %s", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestIntegrationSASLWithoutBatch(t *testing.T) {
+	t.Setenv("SASL_USER", "bot")
+	t.Setenv("SASL_PASS", "secret")
+	srv := newFakeIRCd(t)
+	srv.saslUser, srv.saslPass = "bot", "secret"
+	srv.caps = []string{"message-tags", "account-tag", "server-time"}
+	client := srv.client()
+
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The refused batch request must not take SASL down with it
+	waitState(t, client, StateConnected)
+	if status := client.saslStatus.Load().(string); status != "succeeded" {
+		t.Errorf("Expected SASL to succeed without batch, got %s", status)
+	}
+}
+
+func TestIntegrationReconnect(t *testing.T) {
+	srv := newFakeIRCd(t)
+	client := srv.client()
+	s := NewSupervisor(client)
+	go s.Run()
+	defer s.Stop()
+
+	first := srv.accept()
+	waitState(t, client, StateConnected)
+	first.send("ERROR :Closing Link: restarting")
+	first.close()
+
+	second := srv.accept()
+	second.expect("USER")
+	waitState(t, client, StateConnected)
+}
+
+func TestIntegrationReadTimeout(t *testing.T) {
+	t.Setenv("LAG_CHECK_SECONDS", "0")
+	srv := newFakeIRCd(t)
+	client := srv.client()
+	client.readTimeout = 200 * time.Millisecond
+
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, client, StateConnected)
+	// The server goes quiet; the client gives up on the connection
+	waitState(t, client, StateDisconnected)
+}
@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// joinGuard keeps API joins away from channels the bot should not be pointed
+// at by mistake: channels matching a deny pattern and channels with more
+// users than the bot is meant to talk to
+type joinGuard struct {
+	maxUsers int      // JOIN_MAX_USERS, 0 for no size limit
+	deny     []string // JOIN_DENY masks, e.g. #*-offtopic
+	strict   bool     // JOIN_GUARD=strict: force does not override the guard
+}
+
+// joinRefusedError is returned for a channel the join guard refuses
+type joinRefusedError struct {
+	channel string
+	reason  string
+	force   bool // force would override the refusal
+}
+
+func (e *joinRefusedError) Error() string {
+	msg := fmt.Sprintf("refusing to join %s: %s", e.channel, e.reason)
+	if e.force {
+		msg += " (set force to join anyway)"
+	}
+	return msg
+}
+
+func (c *Client) loadJoinGuard() {
+	c.joinGuard.maxUsers = max(intenv("JOIN_MAX_USERS", 0), 0)
+	for _, mask := range strings.Split(os.Getenv("JOIN_DENY"), ",") {
+		if mask = strings.TrimSpace(mask); mask != "" {
+			c.joinGuard.deny = append(c.joinGuard.deny, mask)
+		}
+	}
+	switch mode := strings.ToLower(getenv("JOIN_GUARD", "force")); mode {
+	case "force":
+	case "strict":
+		c.joinGuard.strict = true
+	default:
+		log.Fatalf("FATAL: Invalid JOIN_GUARD %q (use force or strict)", mode)
+	}
+}
+
+// guardJoin checks the channels of a JOIN parameter ("#a,#b keys") against
+// the join guard. Channel sizes are looked up with LIST; channels the bot is
+// already in are not checked again.
+func (c *Client) guardJoin(ctx context.Context, channels string, force bool) error {
+	g := &c.joinGuard
+	if (g.maxUsers == 0 && len(g.deny) == 0) || (force && !g.strict) {
+		return nil
+	}
+	list, _, _ := strings.Cut(channels, " ")
+	joined := c.Channels()
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if containsFold(joined, name) {
+			continue
+		}
+		for _, mask := range g.deny {
+			if matchMask(mask, name) {
+				return &joinRefusedError{channel: name, reason: "matches JOIN_DENY pattern " + mask, force: !g.strict}
+			}
+		}
+		names = append(names, name)
+	}
+	if g.maxUsers == 0 || len(names) == 0 {
+		return nil
+	}
+
+	result, err := c.GetRequestResultContext(ctx, c.StartRequest("list", "", "LIST "+strings.Join(names, ",")), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	for _, entry := range result.Data {
+		users, _ := strconv.Atoi(entry["users"])
+		if users > g.maxUsers && containsFold(names, entry["channel"]) {
+			reason := fmt.Sprintf("%d users, over JOIN_MAX_USERS=%d", users, g.maxUsers)
+			return &joinRefusedError{channel: entry["channel"], reason: reason, force: !g.strict}
+		}
+	}
+	return nil
+}
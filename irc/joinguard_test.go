@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJoinGuard(t *testing.T) {
+	t.Setenv("JOIN_MAX_USERS", "30")
+	t.Setenv("JOIN_DENY", "#golang-*")
+	client := NewClient()
+	var sent []string
+	answerList(client, &sent)
+	api := client.CreateAPI("token")
+
+	join := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/join", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for body, reason := range map[string]string{
+		`{"channel":"#go,#rust"}`:        "40 users",
+		`{"channel":"#golang-offtopic"}`: "JOIN_DENY",
+	} {
+		rec := join(body)
+		if rec.Code != 403 || !strings.Contains(rec.Body.String(), codeJoinRefused) || !strings.Contains(rec.Body.String(), reason) {
+			t.Errorf("%s: expected 403 join_refused for %s, got %d %s", body, reason, rec.Code, rec.Body.String())
+		}
+	}
+	if sent[0] != "LIST #go,#rust" || len(sent) != 1 {
+		t.Errorf("Expected one LIST for the sizes and no JOIN, got %q", sent)
+	}
+
+	sent = nil
+	if rec := join(`{"channel":"#go"}`); rec.Code != 200 || sent[len(sent)-1] != "JOIN #go" {
+		t.Errorf("Expected #go within the size limit to be joined, got %d %q", rec.Code, sent)
+	}
+	sent = nil
+	if rec := join(`{"channel":"#rust","force":true}`); rec.Code != 200 || len(sent) != 1 || sent[0] != "JOIN #rust" {
+		t.Errorf("Expected force to join without a LIST, got %d %q", rec.Code, sent)
+	}
+}
+
+func TestJoinGuardStrict(t *testing.T) {
+	t.Setenv("JOIN_DENY", "#secret*")
+	t.Setenv("JOIN_GUARD", "strict")
+	client := NewClient()
+	err := client.guardJoin(t.Context(), "#secrets", true)
+	if err == nil || strings.Contains(err.Error(), "force") {
+		t.Errorf("Expected a refusal force cannot override, got %v", err)
+	}
+	if err := client.guardJoin(t.Context(), "#public", false); err != nil {
+		t.Errorf("Expected no LIST without JOIN_MAX_USERS, got %v", err)
+	}
+}
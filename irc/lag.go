@@ -0,0 +1,159 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lagTokenPrefix = "hanna-lag-"
+	lagSamples     = 10 // round trips averaged
+)
+
+// lagTracker measures the round trip of PINGs the bot sends to the server
+type lagTracker struct {
+	interval time.Duration // between PINGs, 0 disables them
+	warnAt   time.Duration // lag that triggers a "lag" event, 0 disables it
+
+	mu      sync.Mutex
+	sent    time.Time       // when the unanswered PING was sent, zero if none
+	samples []time.Duration // last round trips, oldest first
+	warned  bool            // a warning was sent and lag has not recovered yet
+}
+
+// LagStats is the lag reported by /api/state and /metrics
+type LagStats struct {
+	CurrentMs int64 `json:"current_ms"`
+	AverageMs int64 `json:"average_ms"`
+	Samples   int   `json:"samples"`
+	Pending   bool  `json:"pending"` // a PING is waiting for its PONG
+}
+
+func (c *Client) loadLag() {
+	c.lag.interval = time.Duration(intenv("LAG_CHECK_SECONDS", 30)) * time.Second
+	c.lag.warnAt = time.Duration(intenv("LAG_WARN_SECONDS", 10)) * time.Second
+}
+
+func (l *lagTracker) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sent, l.samples, l.warned = time.Time{}, nil, false
+}
+
+// lagLoop pings the server for the lifetime of a connection. While a PING
+// is unanswered no new one is sent, so its wait keeps growing as lag.
+func (c *Client) lagLoop(done <-chan struct{}) {
+	if c.lag.interval <= 0 {
+		return
+	}
+	c.sendLagPing()
+	ticker := time.NewTicker(c.lag.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.lag.mu.Lock()
+			pending := !c.lag.sent.IsZero()
+			c.lag.mu.Unlock()
+			if pending {
+				lag, _ := c.Lag()
+				c.checkLag(lag)
+			} else {
+				c.sendLagPing()
+			}
+		}
+	}
+}
+
+func (c *Client) sendLagPing() {
+	now := time.Now()
+	c.lag.mu.Lock()
+	c.lag.sent = now
+	c.lag.mu.Unlock()
+	c.rawf("PING :%s%d", lagTokenPrefix, now.UnixNano())
+}
+
+// handleLagPong records the round trip of a PONG answering a lag PING
+func (c *Client) handleLagPong(token string) {
+	ns, ok := strings.CutPrefix(token, lagTokenPrefix)
+	if !ok {
+		return
+	}
+	sentNs, err := strconv.ParseInt(ns, 10, 64)
+	if err != nil {
+		return
+	}
+	rtt := time.Since(time.Unix(0, sentNs))
+	c.lag.mu.Lock()
+	if c.lag.sent.UnixNano() == sentNs {
+		c.lag.sent = time.Time{}
+	}
+	c.lag.samples = append(c.lag.samples, rtt)
+	if len(c.lag.samples) > lagSamples {
+		c.lag.samples = c.lag.samples[1:]
+	}
+	c.lag.mu.Unlock()
+	c.checkLag(rtt)
+}
+
+// Lag returns the current lag to the server: the round trip of the last
+// PING, or how long the unanswered one has waited when that is longer. ok is
+// false until there is a measurement.
+func (c *Client) Lag() (lag time.Duration, ok bool) {
+	c.lag.mu.Lock()
+	defer c.lag.mu.Unlock()
+	if n := len(c.lag.samples); n > 0 {
+		lag, ok = c.lag.samples[n-1], true
+	}
+	if !c.lag.sent.IsZero() {
+		if waiting := time.Since(c.lag.sent); waiting > lag {
+			lag, ok = waiting, true
+		}
+	}
+	return lag, ok
+}
+
+// LagStats returns the current and average lag
+func (c *Client) LagStats() LagStats {
+	current, _ := c.Lag()
+	c.lag.mu.Lock()
+	defer c.lag.mu.Unlock()
+	stats := LagStats{CurrentMs: current.Milliseconds(), Samples: len(c.lag.samples), Pending: !c.lag.sent.IsZero()}
+	if len(c.lag.samples) > 0 {
+		var sum time.Duration
+		for _, s := range c.lag.samples {
+			sum += s
+		}
+		stats.AverageMs = (sum / time.Duration(len(c.lag.samples))).Milliseconds()
+	}
+	return stats
+}
+
+// checkLag sends a "lag" trigger event when lag crosses LAG_WARN_SECONDS,
+// and another when it falls back below
+func (c *Client) checkLag(lag time.Duration) {
+	if c.lag.warnAt <= 0 {
+		return
+	}
+	c.lag.mu.Lock()
+	over := lag > c.lag.warnAt
+	changed := over != c.lag.warned
+	c.lag.warned = over
+	c.lag.mu.Unlock()
+	if !changed {
+		return
+	}
+	lag = lag.Round(time.Millisecond)
+	message := fmt.Sprintf("Lag to the server recovered: %s", lag)
+	if over {
+		message = fmt.Sprintf("Lag to the server is %s (threshold %s)", lag, c.lag.warnAt)
+	}
+	log.Print(message)
+	c.sendTriggerEvent("lag", "", "", message, strconv.FormatInt(lag.Milliseconds(), 10), nil)
+}
@@ -0,0 +1,68 @@
+package irc
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lagPong(client *Client, age time.Duration) {
+	client.handleLine(fmt.Sprintf(":server PONG server :%s%d", lagTokenPrefix, time.Now().Add(-age).UnixNano()))
+}
+
+func TestLagWarning(t *testing.T) {
+	t.Setenv("LAG_WARN_SECONDS", "2")
+	received := newTriggerRecorder(t, "lag")
+	client := NewClient()
+
+	lagPong(client, 100*time.Millisecond)
+	lagPong(client, 300*time.Millisecond)
+	expectNoTrigger(t, received)
+	if stats := client.LagStats(); stats.Samples != 2 || stats.AverageMs < 200 || stats.CurrentMs < 300 {
+		t.Errorf("Unexpected lag stats %+v", stats)
+	}
+
+	lagPong(client, 3*time.Second)
+	payload := expectTrigger(t, received)
+	if payload.EventType != "lag" || !strings.Contains(payload.Message, "threshold 2s") {
+		t.Errorf("Unexpected warning %+v", payload)
+	}
+	// Still lagging: no repeated warning
+	lagPong(client, 4*time.Second)
+	expectNoTrigger(t, received)
+
+	lagPong(client, 50*time.Millisecond)
+	if payload := expectTrigger(t, received); !strings.Contains(payload.Message, "recovered") {
+		t.Errorf("Expected a recovery event, got %+v", payload)
+	}
+}
+
+func TestLagPending(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(line string) { sent = append(sent, line) }
+
+	if _, ok := client.Lag(); ok {
+		t.Error("Lag should not be measured before any PING")
+	}
+	client.sendLagPing()
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "PING :"+lagTokenPrefix) {
+		t.Fatalf("Unexpected PING %q", sent)
+	}
+	client.lag.mu.Lock()
+	client.lag.sent = client.lag.sent.Add(-5 * time.Second)
+	client.lag.mu.Unlock()
+	if stats := client.LagStats(); !stats.Pending || stats.CurrentMs < 5000 {
+		t.Errorf("An unanswered PING should count as lag, got %+v", stats)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	client.CreateAPI("token").ServeHTTP(rec, req)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "\nhanna_lag_seconds 5") {
+		t.Errorf("Unexpected metrics %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
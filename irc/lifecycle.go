@@ -0,0 +1,64 @@
+package irc
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Lifecycle trigger events report the bot's own health, so workflows need
+// not poll /api/state. They have no sender and are selected per endpoint
+// like any other event:
+//
+//	bot_connect     the connection is up and registration begins
+//	bot_registered  the server accepted the registration; chatInput is the nick
+//	bot_disconnect  a connection ended; chatInput is the reason
+//	bot_reconnect   a connection attempt after an earlier one; chatInput counts attempts since the last registration
+//	bot_nick        the bot's nick changed; chatInput is the new nick
+//	bot_kick        the bot was kicked; target is the channel, chatInput the reason
+func (c *Client) registerLifecycleEvents() {
+	c.OnStateChange(c.lifecycleFromState)
+	c.bus.Subscribe("lifecycle", c.lifecycleFromEvent)
+}
+
+func (c *Client) lifecycleFromState(from, to ConnState, reason string) {
+	switch to {
+	case StateConnecting:
+		if !c.dialed.Swap(true) {
+			return
+		}
+		n := c.reconnectAttempts.Add(1)
+		c.sendTriggerEvent("bot_reconnect", "", "", fmt.Sprintf("Reconnecting to the IRC server (attempt %d)", n), strconv.FormatInt(n, 10), nil)
+	case StateRegistering:
+		if from == StateConnecting {
+			c.connections.Add(1)
+			c.sendTriggerEvent("bot_connect", "", "", fmt.Sprintf("Connected to %s", c.transport), "", nil)
+		}
+	case StateConnected:
+		c.reconnectAttempts.Store(0)
+		c.sendTriggerEvent("bot_registered", "", "", fmt.Sprintf("Registered as %s", c.Nick()), c.Nick(), nil)
+	case StateDisconnected:
+		// A failed dial never connected, bot_reconnect reports the next try
+		if from == StateConnecting {
+			return
+		}
+		message := "Disconnected from the IRC server"
+		if reason != "" {
+			message += ": " + reason
+		}
+		c.sendTriggerEvent("bot_disconnect", "", "", message, reason, nil)
+	}
+}
+
+// lifecycleFromEvent reports the bot's own nick changes and kicks, which
+// triggerFromEvent leaves out
+func (c *Client) lifecycleFromEvent(e Event) {
+	if !e.Self || e.Replayed {
+		return
+	}
+	switch e.Type {
+	case "nick":
+		c.sendTriggerEvent("bot_nick", "", "", fmt.Sprintf("Nick changed from %s to %s", e.Sender, e.Nick), e.Nick, e.Tags)
+	case "kick":
+		c.sendTriggerEvent("bot_kick", "", e.Target, fmt.Sprintf("Kicked from %s by %s: %s", e.Target, e.Sender, e.Text), e.Text, e.Tags)
+	}
+}
@@ -0,0 +1,51 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLifecycleEvents(t *testing.T) {
+	t.Setenv("IRC_ADDR", listenIRC(t))
+	t.Setenv("IRC_TLS", "0")
+	received := newTriggerRecorder(t, "bot_connect", "bot_registered", "bot_disconnect", "bot_reconnect", "bot_nick", "bot_kick")
+	client := NewClient()
+	client.setNick("TestBot")
+
+	expect := func(eventType, target, chatInput string) {
+		t.Helper()
+		p := expectTrigger(t, received)
+		if p.EventType != eventType || p.Target != target || p.ChatInput != chatInput || p.Sender != "" {
+			t.Errorf("Expected %s (%q, %q), got %+v", eventType, target, chatInput, p)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.Dial(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expect("bot_connect", "", "")
+	client.handleLine(":server 001 TestBot :Welcome")
+	expect("bot_registered", "", "TestBot")
+	client.handleLine(":TestBot!b@host NICK :TestBot2")
+	expect("bot_nick", "", "TestBot2")
+	client.handleLine(":op!o@host KICK #test TestBot2 :bye")
+	expect("bot_kick", "#test", "bye")
+
+	done := client.connDone()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Connection did not close")
+	}
+	p := expectTrigger(t, received)
+	if p.EventType != "bot_disconnect" {
+		t.Errorf("Expected bot_disconnect, got %+v", p)
+	}
+
+	client.transition(StateConnecting, "")
+	expect("bot_reconnect", "", "1")
+}
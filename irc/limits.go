@@ -0,0 +1,97 @@
+package irc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits assumed when the server's ISUPPORT does not state them
+const (
+	defaultLineLen = 512 // bytes of a line, CRLF included, message tags excluded
+	defaultNickLen = 63
+	defaultUserLen = 10
+	defaultHostLen = 63
+	minMessageLen  = 64 // message bytes per line, however long the prefix
+)
+
+// limitError reports text longer than one of the server's ISUPPORT limits
+type limitError struct {
+	what  string // e.g. "topic"
+	token string // e.g. TOPICLEN
+	limit int
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%s is longer than the server's limit of %d bytes (%s)", e.what, e.limit, e.token)
+}
+
+// isupport returns the value of an ISUPPORT token, or "" if it was not sent
+func (c *Client) isupport(token string) string {
+	c.serverInfoMu.RLock()
+	defer c.serverInfoMu.RUnlock()
+	return c.serverInfo.ISupportTags[token]
+}
+
+// isupportInt returns a numeric ISUPPORT token, or 0 if it is missing or
+// not a positive number
+func (c *Client) isupportInt(token string) int {
+	n, err := strconv.Atoi(c.isupport(token))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// checkLength returns a limitError when text is longer than the ISUPPORT
+// token allows; servers that do not send the token impose no limit here
+func (c *Client) checkLength(what, token, text string) error {
+	if limit := c.isupportInt(token); limit > 0 && len(text) > limit {
+		return &limitError{what: what, token: token, limit: limit}
+	}
+	return nil
+}
+
+// truncateTo cuts text to the ISUPPORT token's limit, for texts the bot
+// writes itself such as kick reasons and the auto-away message
+func (c *Client) truncateTo(token, text string) string {
+	if limit := c.isupportInt(token); limit > 0 {
+		return truncateUTF8(text, limit)
+	}
+	return text
+}
+
+// checkChannelLength validates each channel of a comma-separated list
+// against CHANNELLEN
+func (c *Client) checkChannelLength(channels string) error {
+	for _, ch := range strings.Split(channels, ",") {
+		if err := c.checkLength("channel name "+ch, "CHANNELLEN", ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageLen returns how many bytes of text fit in one command line sent to
+// target, after the prefix the server adds when relaying it
+// (:nick!user@host) and within LINELEN
+func (c *Client) messageLen(command, target string) int {
+	lineLen := c.isupportInt("LINELEN")
+	if lineLen == 0 {
+		lineLen = defaultLineLen
+	}
+	prefix := c.knownHostmask(c.Nick())
+	if prefix == "" {
+		userLen, hostLen := c.isupportInt("USERLEN"), c.isupportInt("HOSTLEN")
+		if userLen == 0 {
+			userLen = defaultUserLen
+		}
+		if hostLen == 0 {
+			hostLen = defaultHostLen
+		}
+		prefix = c.Nick() + "!" + strings.Repeat("u", userLen+1) + "@" + strings.Repeat("h", hostLen)
+	}
+	// ":" prefix " " command " " target " :" text CRLF
+	overhead := 1 + len(prefix) + 1 + len(command) + 1 + len(target) + 2 + 2
+	return max(lineLen-overhead, minMessageLen)
+}
@@ -0,0 +1,80 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMessageSplitFollowsLineLen(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+
+	// Without ISUPPORT the prefix is assumed as long as USERLEN and HOSTLEN allow
+	client.Privmsg("#test", strings.Repeat("a", 1000))
+	for _, line := range lines {
+		if n := len(":TestBot!~uuuuuuuuuu@"+strings.Repeat("h", 63)+" ") + len(line) + 2; n > 512 {
+			t.Errorf("Relayed line would be %d bytes, over 512", n)
+		}
+	}
+
+	// A known hostmask and a larger LINELEN fit more per line
+	client.handleLine(":server 005 TestBot LINELEN=2048 NICKLEN=9 AWAYLEN=10 KICKLEN=5 CHANNELLEN=8 :are supported")
+	client.updateUserInfo("TestBot", func(info *UserInfo) { info.User, info.Host = "bot", "example.org" })
+	lines = nil
+	client.Privmsg("#test", strings.Repeat("a", 1000))
+	if len(lines) != 1 {
+		t.Errorf("Expected one line with LINELEN=2048, got %d", len(lines))
+	}
+	lines = nil
+	client.Notice("#test", strings.Repeat("b", 3000))
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "NOTICE #test :b") {
+		t.Errorf("Expected a long notice in two lines, got %d", len(lines))
+	}
+}
+
+func TestISupportLimitErrors(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	client.handleLine(":server 005 TestBot NICKLEN=9 AWAYLEN=10 KICKLEN=5 CHANNELLEN=8 TOPICLEN=6 :are supported")
+
+	if err := client.SetNick("muchtoolong"); err == nil || !strings.Contains(err.Error(), "NICKLEN") {
+		t.Errorf("Expected a NICKLEN error, got %v", err)
+	}
+	if err := client.SetAway("gone fishing today"); err == nil {
+		t.Error("Expected an AWAYLEN error")
+	}
+	if err := client.SetTopic("#test", "a long topic"); err == nil {
+		t.Error("Expected a TOPICLEN error")
+	}
+	if len(lines) != 0 {
+		t.Errorf("Expected nothing sent for refused commands, got %q", lines)
+	}
+	if err := client.SetNick("shortnick"); err != nil || lines[0] != "NICK shortnick" {
+		t.Errorf("Expected a nick within NICKLEN to be sent, got %v %q", err, lines)
+	}
+
+	api := client.CreateAPI("token")
+	for path, body := range map[string]string{
+		"/api/join": `{"channel":"#waytoolong"}`,
+		"/api/nick": `{"nick":"muchtoolong"}`,
+		"/api/away": `{"message":"gone fishing today"}`,
+	} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != 400 || !strings.Contains(rec.Body.String(), "limit") {
+			t.Errorf("%s: expected 400 naming the limit, got %d %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Texts the bot writes itself are cut instead
+	if got := client.truncateTo("KICKLEN", "flooding"); got != "flood" {
+		t.Errorf("Expected the kick reason cut to KICKLEN, got %q", got)
+	}
+}
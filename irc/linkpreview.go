@@ -0,0 +1,342 @@
+package irc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LinkPreviewConfig is the LINK_PREVIEW_CONFIG JSON document
+type LinkPreviewConfig struct {
+	Mode           string   `json:"mode"`                    // announce, event or both
+	Channels       []string `json:"channels,omitempty"`      // channels to preview links in (empty = all)
+	AllowDomains   []string `json:"allow_domains,omitempty"` // only these domains (and subdomains) when set
+	DenyDomains    []string `json:"deny_domains,omitempty"`  // never these domains (and subdomains)
+	MaxBytes       int64    `json:"max_bytes"`               // bytes of the page read when looking for a title
+	TimeoutSeconds int      `json:"timeout_seconds"`         // total time allowed per URL
+	MaxURLs        int      `json:"max_urls"`                // URLs previewed per message
+	AllowPrivate   bool     `json:"allow_private"`           // allow loopback/private addresses (disables SSRF protection)
+}
+
+// LinkPreview is the metadata fetched for a URL posted in a channel
+type LinkPreview struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"finalUrl,omitempty"` // after redirects, when different
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+var linkPreviewModes = map[string]bool{"announce": true, "event": true, "both": true}
+
+var (
+	urlRe         = regexp.MustCompile(`https?://[^\s<>"\x00-\x1f]+`)
+	titleRe       = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaRe        = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrRe    = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+	whitespaceRun = regexp.MustCompile(`\s+`)
+)
+
+func (c *Client) loadLinkPreviewConfig() {
+	configStr := os.Getenv("LINK_PREVIEW_CONFIG")
+	if configStr == "" {
+		return
+	}
+	cfg := LinkPreviewConfig{
+		Mode:           "event",
+		MaxBytes:       512 * 1024,
+		TimeoutSeconds: 5,
+		MaxURLs:        3,
+	}
+	if err := json.Unmarshal([]byte(configStr), &cfg); err != nil {
+		log.Fatalf("FATAL: Invalid LINK_PREVIEW_CONFIG JSON: %v", err)
+	}
+	cfg.Mode = strings.ToLower(cfg.Mode)
+	if !linkPreviewModes[cfg.Mode] {
+		log.Fatalf("FATAL: Invalid LINK_PREVIEW_CONFIG mode %q (expected announce, event or both)", cfg.Mode)
+	}
+	log.Printf("Link previews enabled (mode: %s)", cfg.Mode)
+	c.linkPreview = &cfg
+	c.linkClient = newLinkPreviewClient(&cfg)
+}
+
+// newLinkPreviewClient returns an HTTP client that refuses to connect to
+// non-public addresses unless AllowPrivate is set. The check runs on the
+// resolved address, so it also covers DNS rebinding and redirects.
+func newLinkPreviewClient(cfg *LinkPreviewConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if cfg.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   time.Duration(cfg.TimeoutSeconds) * time.Second,
+			ResponseHeaderTimeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			return cfg.checkURL(req.URL)
+		},
+	}
+}
+
+// specialPrefixes are special-use ranges the net.IP predicates do not cover,
+// some of which (CGNAT, NAT64, 6to4) reach internal hosts
+var specialPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, incl. Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range specialPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkURL applies the scheme and domain allow/deny rules
+func (cfg *LinkPreviewConfig) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	for _, d := range cfg.DenyDomains {
+		if domainMatches(host, d) {
+			return fmt.Errorf("domain %s is denied", host)
+		}
+	}
+	if len(cfg.AllowDomains) == 0 {
+		return nil
+	}
+	for _, d := range cfg.AllowDomains {
+		if domainMatches(host, d) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %s is not allowed", host)
+}
+
+// domainMatches reports whether host is domain or one of its subdomains
+func domainMatches(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "*."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func (c *Client) isLinkPreviewChannel(channel string) bool {
+	if !isChannelName(channel) {
+		return false
+	}
+	if len(c.linkPreview.Channels) == 0 {
+		return true
+	}
+	for _, ch := range c.linkPreview.Channels {
+		if strings.EqualFold(ch, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractURLs returns up to max distinct http(s) URLs found in a message
+func extractURLs(message string, max int) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, u := range urlRe.FindAllString(message, -1) {
+		u = strings.TrimRight(u, ".,;:!?'\")]}>")
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		out = append(out, u)
+		if len(out) == max {
+			break
+		}
+	}
+	return out
+}
+
+// previewLinks fetches titles for URLs in a channel message in the background,
+// then announces them and/or sends a "link_preview" trigger event
+func (c *Client) previewLinks(sender, target, message string, tags map[string]string) {
+	if c.linkPreview == nil || !c.isLinkPreviewChannel(target) {
+		return
+	}
+	urls := extractURLs(message, c.linkPreview.MaxURLs)
+	if len(urls) == 0 {
+		return
+	}
+	go c.safely("link preview", func() {
+		var previews []LinkPreview
+		for _, u := range urls {
+			p, err := c.fetchLinkPreview(u)
+			if err != nil {
+				log.Printf("Link preview for %s failed: %v", u, err)
+				continue
+			}
+			previews = append(previews, *p)
+		}
+		if len(previews) == 0 {
+			return
+		}
+
+		mode := c.linkPreview.Mode
+		if mode == "announce" || mode == "both" {
+			for _, p := range previews {
+				if p.Title == "" {
+					continue
+				}
+				host := p.URL
+				if parsed, err := url.Parse(p.URL); err == nil {
+					host = parsed.Hostname()
+				}
+				c.sendLines(c.replyCommand(target, nil), "", "", target, fmt.Sprintf("[ %s ] - %s", p.Title, host))
+			}
+		}
+		if mode == "event" || mode == "both" {
+			payload := c.newTriggerPayload("link_preview", sender, target, message, message, tags)
+			payload.LinkPreview = previews
+			c.deliverTrigger(payload)
+		}
+	})
+}
+
+// fetchLinkPreview GETs a URL and extracts its title and description
+func (c *Client) fetchLinkPreview(rawURL string) (*LinkPreview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.linkPreview.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Duration(c.linkPreview.TimeoutSeconds)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Hanna-IRC-Bot/"+Version+" (link preview)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := c.linkClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	p := &LinkPreview{URL: rawURL, ContentType: resp.Header.Get("Content-Type")}
+	if final := resp.Request.URL.String(); final != rawURL {
+		p.FinalURL = final
+	}
+	mediaType, _, _ := mime.ParseMediaType(p.ContentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return p, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.linkPreview.MaxBytes))
+	if err != nil && len(body) == 0 {
+		return nil, err
+	}
+	p.Title, p.Description = parseHTMLMeta(string(body))
+	return p, nil
+}
+
+// parseHTMLMeta returns the page title (preferring <title> over og:title) and
+// its description
+func parseHTMLMeta(page string) (string, string) {
+	var title, description, ogTitle string
+	if m := titleRe.FindStringSubmatch(page); m != nil {
+		title = m[1]
+	}
+	for _, tag := range metaRe.FindAllString(page, -1) {
+		var key, content string
+		for _, attr := range metaAttrRe.FindAllStringSubmatch(tag, -1) {
+			value := attr[2][1 : len(attr[2])-1]
+			if strings.EqualFold(attr[1], "content") {
+				content = value
+			} else {
+				key = strings.ToLower(value)
+			}
+		}
+		switch key {
+		case "og:title":
+			ogTitle = content
+		case "description", "og:description":
+			if description == "" {
+				description = content
+			}
+		}
+	}
+	if strings.TrimSpace(title) == "" {
+		title = ogTitle
+	}
+	return cleanPreviewText(title, 200), cleanPreviewText(description, 300)
+}
+
+func cleanPreviewText(s string, max int) string {
+	s = strings.TrimSpace(whitespaceRun.ReplaceAllString(html.UnescapeString(s), " "))
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,111 @@
+package irc
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	got := extractURLs(`see (https://example.com/a), "http://example.org/b?x=1". and https://example.com/a again`, 3)
+	want := []string{"https://example.com/a", "http://example.org/b?x=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractURLs = %v, want %v", got, want)
+	}
+	if got := extractURLs("https://a.com https://b.com https://c.com", 2); len(got) != 2 {
+		t.Errorf("Expected at most 2 URLs, got %v", got)
+	}
+}
+
+func TestParseHTMLMeta(t *testing.T) {
+	title, desc := parseHTMLMeta(`<html><head><TITLE>
+		Hello &amp; welcome
+	</TITLE><meta name="description" content='A   test page'></head></html>`)
+	if title != "Hello & welcome" || desc != "A test page" {
+		t.Errorf("Unexpected title %q and description %q", title, desc)
+	}
+	if title, _ := parseHTMLMeta(`<meta property="og:title" content="OG title">`); title != "OG title" {
+		t.Errorf("Expected og:title fallback, got %q", title)
+	}
+}
+
+func TestLinkPreviewURLRules(t *testing.T) {
+	cfg := &LinkPreviewConfig{AllowDomains: []string{"example.com"}, DenyDomains: []string{"bad.example.com"}}
+	for raw, ok := range map[string]bool{
+		"https://example.com/x":        true,
+		"https://www.example.com/x":    true,
+		"https://bad.example.com/x":    false,
+		"https://notexample.com/x":     false,
+		"ftp://example.com/x":          false,
+		"https://sub.bad.example.com/": false,
+	} {
+		u, _ := url.Parse(raw)
+		if err := cfg.checkURL(u); (err == nil) != ok {
+			t.Errorf("checkURL(%s) = %v, want allowed=%v", raw, err, ok)
+		}
+	}
+}
+
+func TestLinkPreviewEvent(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<title>Test Page</title>"))
+	}))
+	defer page.Close()
+
+	received := newTriggerRecorder(t, "link_preview")
+	t.Setenv("LINK_PREVIEW_CONFIG", `{"mode":"both","allow_private":true}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+
+	client.handleLine(":alice!a@host PRIVMSG #test :look at " + page.URL + "/page")
+	payload := expectTrigger(t, received)
+	if payload.EventType != "link_preview" || payload.Sender != "alice" || len(payload.LinkPreview) != 1 || payload.LinkPreview[0].Title != "Test Page" {
+		t.Errorf("Unexpected link_preview payload: %+v", payload)
+	}
+	if got := <-sent; got != "PRIVMSG #test :[ Test Page ] - 127.0.0.1" {
+		t.Errorf("Unexpected announcement %q", got)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":      true,
+		"2606:2800:220:1::1": true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"100.127.255.254":    false,
+		"192.0.0.8":          false,
+		"198.18.0.1":         false,
+		"255.255.255.255":    false,
+		"::ffff:100.64.0.1":  false,
+		"64:ff9b::a00:1":     false,
+		"2002:a00:1::1":      false,
+		"fd00::1":            false,
+		"::1":                false,
+	} {
+		if got := isPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestLinkPreviewBlocksPrivateAddresses(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Private address should not have been fetched")
+	}))
+	defer page.Close()
+
+	cfg := &LinkPreviewConfig{Mode: "event", MaxBytes: 1024, TimeoutSeconds: 2, MaxURLs: 1}
+	client := &Client{linkPreview: cfg, linkClient: newLinkPreviewClient(cfg)}
+	if _, err := client.fetchLinkPreview(page.URL); err == nil {
+		t.Error("Expected fetching a loopback address to fail")
+	}
+}
@@ -0,0 +1,141 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListFilter narrows a LIST query. Filters the server advertises through the
+// ISUPPORT ELIST token are sent with the LIST command; the user count and
+// mask filters are otherwise applied to the cached full list.
+type ListFilter struct {
+	MinUsers     int    `json:"min_users"`     // ELIST U: more than N users
+	MaxUsers     int    `json:"max_users"`     // ELIST U: fewer than N users
+	Mask         string `json:"mask"`          // ELIST M: channel name mask
+	CreatedAfter int    `json:"created_after"` // ELIST C: created less than N minutes ago
+}
+
+func (f ListFilter) empty() bool {
+	return f == ListFilter{}
+}
+
+// listCache holds the last unfiltered LIST result
+type listCache struct {
+	channels []map[string]string
+	fetched  time.Time
+}
+
+// elist returns the ELIST extensions advertised by the server (e.g. "CMNTU")
+func (c *Client) elist() string {
+	c.serverInfoMu.RLock()
+	defer c.serverInfoMu.RUnlock()
+	return strings.ToUpper(c.serverInfo.ISupportTags["ELIST"])
+}
+
+// serverParams builds the LIST parameters for the filters supported by elist.
+// ok is false when a filter is requested that the server cannot apply.
+func (f ListFilter) serverParams(elist string) (params string, ok bool) {
+	var parts []string
+	ok = true
+	if f.MinUsers > 0 || f.MaxUsers > 0 {
+		if strings.Contains(elist, "U") {
+			if f.MinUsers > 0 {
+				parts = append(parts, ">"+strconv.Itoa(f.MinUsers))
+			}
+			if f.MaxUsers > 0 {
+				parts = append(parts, "<"+strconv.Itoa(f.MaxUsers))
+			}
+		} else {
+			ok = false
+		}
+	}
+	if f.CreatedAfter > 0 {
+		if strings.Contains(elist, "C") {
+			parts = append(parts, "C<"+strconv.Itoa(f.CreatedAfter))
+		} else {
+			ok = false
+		}
+	}
+	if f.Mask != "" {
+		if strings.Contains(elist, "M") {
+			parts = append(parts, f.Mask)
+		} else {
+			ok = false
+		}
+	}
+	return strings.Join(parts, ","), ok
+}
+
+// match applies the user count and mask filters to a LIST entry
+func (f ListFilter) match(entry map[string]string) bool {
+	users, _ := strconv.Atoi(entry["users"])
+	if f.MinUsers > 0 && users <= f.MinUsers {
+		return false
+	}
+	if f.MaxUsers > 0 && users >= f.MaxUsers {
+		return false
+	}
+	if f.Mask != "" && !matchMask(f.Mask, entry["channel"]) {
+		return false
+	}
+	return true
+}
+
+// List initiates a LIST command and returns a request ID to track the
+// response. Filters the server does not advertise in ELIST are left out.
+func (c *Client) List(filter ListFilter) string {
+	command := "LIST"
+	if params, _ := filter.serverParams(c.elist()); params != "" {
+		command += " " + params
+	}
+	return c.StartRequest("list", "", command)
+}
+
+// ListChannels returns the channels matching filter. When the server supports
+// every requested filter the query is sent to the server; otherwise the full
+// list is fetched (or served from the cache while it is younger than the
+// cache TTL) and filtered locally. cached reports whether the cache was used.
+func (c *Client) ListChannels(ctx context.Context, filter ListFilter, refresh bool) (channels []map[string]string, cached bool, err error) {
+	if !filter.empty() {
+		if _, ok := filter.serverParams(c.elist()); ok {
+			result, err := c.GetRequestResultContext(ctx, c.List(filter), 10*time.Second)
+			if err != nil {
+				return nil, false, err
+			}
+			return result.Data, false, nil
+		}
+		if filter.CreatedAfter > 0 {
+			return nil, false, fmt.Errorf("server does not support filtering by creation time (ELIST C)")
+		}
+	}
+
+	c.listCacheMu.Lock()
+	entry := c.listCache
+	c.listCacheMu.Unlock()
+
+	all := entry.channels
+	cached = !refresh && all != nil && time.Since(entry.fetched) < c.listCacheTTL
+	if !cached {
+		result, err := c.GetRequestResultContext(ctx, c.List(ListFilter{}), 10*time.Second)
+		if err != nil {
+			return nil, false, err
+		}
+		all = result.Data
+		if c.listCacheTTL > 0 {
+			c.listCacheMu.Lock()
+			c.listCache = listCache{channels: all, fetched: time.Now()}
+			c.listCacheMu.Unlock()
+		}
+	}
+
+	channels = make([]map[string]string, 0, len(all))
+	for _, ch := range all {
+		if filter.match(ch) {
+			channels = append(channels, ch)
+		}
+	}
+	return channels, cached, nil
+}
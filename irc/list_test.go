@@ -0,0 +1,73 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestListFilterServerParams(t *testing.T) {
+	filter := ListFilter{MinUsers: 10, Mask: "#go*", CreatedAfter: 60}
+	if params, ok := filter.serverParams("CMNTU"); !ok || params != ">10,C<60,#go*" {
+		t.Errorf("Expected server-side params, got %q (%v)", params, ok)
+	}
+	if _, ok := filter.serverParams("MN"); ok {
+		t.Error("Filters outside the advertised ELIST should not be server-side")
+	}
+}
+
+// answerList replies to LIST commands with a fixed channel list
+func answerList(client *Client, sent *[]string) {
+	client.testRawCapture = func(s string) {
+		*sent = append(*sent, s)
+		go func() {
+			client.handleLine(":irc.server.com 322 TestBot #go 25 :Go")
+			client.handleLine(":irc.server.com 322 TestBot #golang-offtopic 4 :Chat")
+			client.handleLine(":irc.server.com 322 TestBot #rust 40 :Rust")
+			client.handleLine(":irc.server.com 323 TestBot :End of /LIST")
+		}()
+	}
+}
+
+func TestListChannelsCache(t *testing.T) {
+	client := NewClient()
+	client.listCacheTTL = time.Minute
+	var sent []string
+	answerList(client, &sent)
+
+	channels, cached, err := client.ListChannels(context.Background(), ListFilter{}, false)
+	if err != nil || cached || len(channels) != 3 {
+		t.Fatalf("Expected a fresh list of 3 channels, got %v cached=%v err=%v", channels, cached, err)
+	}
+
+	// Without ELIST support the filter is applied to the cached list
+	channels, cached, err = client.ListChannels(context.Background(), ListFilter{MinUsers: 10, Mask: "#go*"}, false)
+	if err != nil || !cached || len(channels) != 1 || channels[0]["channel"] != "#go" {
+		t.Errorf("Expected #go from the cache, got %v cached=%v err=%v", channels, cached, err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected a single LIST command, got %v", sent)
+	}
+
+	if _, cached, _ := client.ListChannels(context.Background(), ListFilter{}, true); cached {
+		t.Error("refresh should bypass the cache")
+	}
+
+	if _, _, err := client.ListChannels(context.Background(), ListFilter{CreatedAfter: 5}, false); err == nil {
+		t.Error("Creation time filter should fail without ELIST C")
+	}
+}
+
+func TestListChannelsServerFilter(t *testing.T) {
+	client := NewClient()
+	client.handleLine(":irc.server.com 005 TestBot ELIST=CMNTU :are supported by this server")
+	var sent []string
+	answerList(client, &sent)
+
+	if _, cached, err := client.ListChannels(context.Background(), ListFilter{MinUsers: 10}, false); err != nil || cached {
+		t.Fatalf("Expected a server-side query, got cached=%v err=%v", cached, err)
+	}
+	if len(sent) != 1 || sent[0] != "LIST >10" {
+		t.Errorf("Expected LIST with ELIST parameters, got %v", sent)
+	}
+}
@@ -0,0 +1,70 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+const (
+	// relayTag is the client-only tag on lines a bridge posts to IRC
+	relayTag = "+hanna/relay"
+	// loopMarker is prepended to relayed text where tags cannot go: zero
+	// width space followed by zero width non-joiner, which no one types
+	loopMarker = "\u200b\u200c"
+)
+
+// LoopProtection configures how a bridge marks what it relays and which IRC
+// messages it refuses to relay, so that bridges (ours or another relay bot's)
+// never echo each other's messages back and forth
+type LoopProtection struct {
+	LoopMarker  string   `json:"loop_marker,omitempty"`  // tag (default), zero_width or none
+	IgnoreNicks []string `json:"ignore_nicks,omitempty"` // relay bots on IRC whose messages are never relayed
+}
+
+// validate checks the marker and fills in the default
+func (l *LoopProtection) validate(bridge string) {
+	switch l.LoopMarker {
+	case "":
+		l.LoopMarker = "tag"
+	case "tag", "zero_width", "none":
+	default:
+		log.Fatalf("FATAL: Invalid loop_marker %q for %s bridge (tag, zero_width or none)", l.LoopMarker, bridge)
+	}
+}
+
+// looped reports whether an IRC event came from a relay: it carries the tag
+// or marker of a bridge, or was sent by one of the ignored relay bots
+func (l LoopProtection) looped(e Event) bool {
+	if _, ok := e.Tags[relayTag]; ok {
+		return true
+	}
+	if hasLoopMarker(e.Text) {
+		return true
+	}
+	return slices.ContainsFunc(l.IgnoreNicks, func(n string) bool { return strings.EqualFold(n, e.Sender) })
+}
+
+// mark prepends the zero width marker to text posted to another network.
+// Tags only exist on IRC, so the tag setting leaves the text alone there.
+func (l LoopProtection) mark(text string) string {
+	if l.LoopMarker == "zero_width" {
+		return loopMarker + text
+	}
+	return text
+}
+
+// ircPrefix returns what goes in front of each relayed IRC line: the relay
+// tag when the server supports client tags, or the zero width marker
+func (l LoopProtection) ircPrefix(c *Client, bridge string) (tags, marker string) {
+	switch {
+	case l.LoopMarker == "tag" && c.messageTags.Load():
+		return fmt.Sprintf("@%s=%s ", relayTag, strings.ToLower(bridge)), ""
+	case l.LoopMarker == "zero_width":
+		return "", loopMarker
+	}
+	return "", ""
+}
+
+func hasLoopMarker(s string) bool { return strings.Contains(s, loopMarker) }
@@ -0,0 +1,86 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoopProtectionFromIRC(t *testing.T) {
+	posts := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		content, _ := body["content"].(string)
+		posts <- content
+	}))
+	defer srv.Close()
+
+	client := newDiscordClient(t, `{"discord":{"token":"tok","channels":{"#test":"123"},
+		"loop_marker":"zero_width","ignore_nicks":["RelayBot"]}}`)
+	client.discord.apiBase = srv.URL
+
+	client.handleLine("@+hanna/relay=telegram :other!o@host PRIVMSG #test :<carol> tagged")
+	client.handleLine(":other!o@host PRIVMSG #test :" + loopMarker + "<carol> marked")
+	client.handleLine(":relaybot!r@host PRIVMSG #test :<dave> ignored")
+	client.handleLine(":alice!a@host PRIVMSG #test :hello")
+
+	select {
+	case content := <-posts:
+		if content != "**<alice>** "+loopMarker+"hello" {
+			t.Errorf("Unexpected post %q", content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a Discord post")
+	}
+	select {
+	case content := <-posts:
+		t.Errorf("Unexpected extra post %q", content)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLoopProtectionToIRC(t *testing.T) {
+	client := NewClient()
+	markConnected(client)
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	tag := LoopProtection{}
+	tag.validate("Discord")
+	client.relayToIRC("Discord", "#test", "bob", "no tags yet", tag)
+	client.messageTags.Store(true)
+	client.relayToIRC("Discord", "#test", "bob", "hi", tag)
+	client.relayToIRC("Discord", "#test", "bob", "looped "+loopMarker+"text", tag)
+	client.relayToIRC("XMPP", "#test", "carol", "hey", LoopProtection{LoopMarker: "zero_width"})
+
+	want := []string{
+		"PRIVMSG #test :<bob> no tags yet",
+		"@+hanna/relay=discord PRIVMSG #test :<bob> hi",
+		"PRIVMSG #test :" + loopMarker + "<carol> hey",
+	}
+	if strings.Join(sent, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, sent)
+	}
+}
+
+func TestBridgeLineBreaks(t *testing.T) {
+	client := NewClient()
+	markConnected(client)
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	// A bare CR ends a line on some servers; it must never start a command
+	client.relayToIRC("Discord", "#test", "eve\rQUIT", "hi\rJOIN #x\r\nbye\x00PART #test", LoopProtection{})
+	want := []string{
+		"PRIVMSG #test :<eve QUIT> hi",
+		"PRIVMSG #test :<eve QUIT> JOIN #x",
+		"PRIVMSG #test :<eve QUIT> bye PART #test",
+	}
+	if strings.Join(sent, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, sent)
+	}
+}
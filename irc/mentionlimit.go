@@ -0,0 +1,121 @@
+package irc
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMentionNotice = "{nick}: you are asking too quickly, please wait {seconds}s before the next request."
+
+// mentionLimiter keeps one user from sending the trigger endpoints mention
+// after mention: each user has a bucket of MENTION_RATE_BURST mentions that
+// refills by one every MENTION_RATE_SECONDS
+type mentionLimiter struct {
+	every  time.Duration // MENTION_RATE_SECONDS, 0 for no limit
+	burst  int           // MENTION_RATE_BURST
+	notice string        // MENTION_RATE_NOTICE, "" to throttle silently
+	exempt Role          // MENTION_RATE_EXEMPT_ROLE and above are never throttled
+
+	mu        sync.Mutex
+	buckets   map[string]*mentionBucket // lowercased account or nick -> bucket
+	throttled atomic.Int64
+}
+
+type mentionBucket struct {
+	tokens  float64
+	updated time.Time
+	noticed bool // the user was told to slow down since their last allowed mention
+}
+
+func (c *Client) loadMentionLimit() {
+	m := &c.mentionLimit
+	m.every = time.Duration(max(intenv("MENTION_RATE_SECONDS", 0), 0)) * time.Second
+	m.burst = max(intenv("MENTION_RATE_BURST", 3), 1)
+	m.notice = defaultMentionNotice
+	if v, ok := os.LookupEnv("MENTION_RATE_NOTICE"); ok {
+		m.notice = v
+	}
+	role, err := ParseRole(getenv("MENTION_RATE_EXEMPT_ROLE", "trusted"))
+	if err != nil {
+		log.Fatalf("FATAL: Invalid MENTION_RATE_EXEMPT_ROLE: %v", err)
+	}
+	m.exempt = role
+	m.buckets = make(map[string]*mentionBucket)
+	if m.every > 0 {
+		log.Printf("Mention rate limit: %d per user, one more every %s", m.burst, m.every)
+	}
+}
+
+// allow takes a mention from the sender's bucket. When the bucket is
+// empty it reports how long until the next mention is allowed and whether
+// the user should be told so, which happens once per throttled streak.
+func (m *mentionLimiter) allow(key string, now time.Time) (ok bool, wait time.Duration, notify bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.buckets[key]
+	if b == nil {
+		if len(m.buckets) >= 10000 {
+			m.prune(now)
+		}
+		b = &mentionBucket{tokens: float64(m.burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = min(b.tokens+float64(now.Sub(b.updated))/float64(m.every), float64(m.burst))
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.noticed = false
+		return true, 0, false
+	}
+	m.throttled.Add(1)
+	wait = time.Duration((1 - b.tokens) * float64(m.every))
+	notify = !b.noticed
+	b.noticed = true
+	return false, wait, notify
+}
+
+// prune drops the buckets that have refilled completely
+func (m *mentionLimiter) prune(now time.Time) {
+	for key, b := range m.buckets {
+		if b.tokens+float64(now.Sub(b.updated))/float64(m.every) >= float64(m.burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// throttleMention reports whether a mention should not reach the triggers
+// because its sender is over the mention rate limit, and tells the sender
+// to slow down where they asked
+func (c *Client) throttleMention(e Event) bool {
+	m := &c.mentionLimit
+	if m.every == 0 || e.Type != "mention" {
+		return false
+	}
+	account := c.senderAccount(e.Sender, e.Tags)
+	if m.exempt > RoleNone && c.RoleOf(e.Prefix, account) >= m.exempt {
+		return false
+	}
+	key := strings.ToLower(e.Sender)
+	if account != "" {
+		key = "account:" + strings.ToLower(account)
+	}
+	ok, wait, notify := m.allow(key, time.Now())
+	if ok {
+		return false
+	}
+	log.Printf("Mention by %s in %s [%s] throttled, next allowed in %s", e.Sender, e.Target, e.ID, wait.Round(time.Second))
+	if notify && m.notice != "" {
+		target := e.Target
+		if !isChannelName(target) {
+			target = e.Sender
+		}
+		seconds := strconv.Itoa(int(wait.Seconds()) + 1)
+		c.Notice(target, strings.NewReplacer("{nick}", e.Sender, "{seconds}", seconds).Replace(m.notice))
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMentionRateLimit(t *testing.T) {
+	t.Setenv("MENTION_RATE_SECONDS", "10")
+	t.Setenv("MENTION_RATE_BURST", "2")
+	client := NewClient()
+	client.setNick("TestBot")
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+
+	mention := Event{Type: "mention", Prefix: "alice!a@host", Sender: "alice", Target: "#test", Text: "TestBot: hi"}
+	var throttled []bool
+	for i := 0; i < 4; i++ {
+		throttled = append(throttled, client.throttleMention(mention))
+	}
+	if fmt.Sprint(throttled) != "[false false true true]" {
+		t.Errorf("Expected a burst of 2 then throttling, got %v", throttled)
+	}
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "NOTICE #test :alice: you are asking too quickly") {
+		t.Errorf("Expected one notice in the channel per throttled streak, got %q", lines)
+	}
+
+	// Other users have their own bucket, DMs are answered to the sender
+	if client.throttleMention(Event{Type: "mention", Prefix: "bob!b@host", Sender: "bob", Target: "#test"}) {
+		t.Error("Expected bob not to be throttled by alice's mentions")
+	}
+	lines = nil
+	client.mentionLimit.buckets["carol"] = &mentionBucket{updated: time.Now()}
+	client.throttleMention(Event{Type: "mention", Prefix: "carol!c@host", Sender: "carol", Target: "TestBot"})
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "NOTICE carol :") {
+		t.Errorf("Expected the notice for a private mention to go to the sender, got %q", lines)
+	}
+}
+
+func TestMentionBucketRefill(t *testing.T) {
+	m := &mentionLimiter{every: 10 * time.Second, burst: 1, buckets: make(map[string]*mentionBucket)}
+	now := time.Now()
+	if ok, _, _ := m.allow("alice", now); !ok {
+		t.Fatal("Expected the first mention to pass")
+	}
+	if ok, wait, notify := m.allow("alice", now.Add(4*time.Second)); ok || wait != 6*time.Second || !notify {
+		t.Errorf("Expected a 6s wait with a notice, got %v %v %v", ok, wait, notify)
+	}
+	if ok, _, _ := m.allow("alice", now.Add(10*time.Second)); !ok {
+		t.Error("Expected the bucket to refill after 10s")
+	}
+}
@@ -0,0 +1,94 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// sentMessagesMax bounds how many sent messages can be looked up by ID
+const sentMessagesMax = 1000
+
+// SentMessage is the delivery status of a message sent through the API
+type SentMessage struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"` // privmsg, notice, action or tagmsg
+	Target    string    `json:"target"`
+	Status    string    `json:"status"` // pending, sent, failed, or for labeled replies acknowledged or rejected
+	Error     string    `json:"error,omitempty"`
+	RequestID string    `json:"request_id,omitempty"` // correlation ID of the API request that sent it
+	CreatedAt time.Time `json:"created_at"`
+	SentAt    time.Time `json:"sent_at,omitzero"` // when the last line was flushed to the server
+}
+
+// trackSend runs send under a new message ID and records its outcome, so
+// callers can look it up later with SentMessage; requestID is the API
+// request's correlation ID, if any
+func (c *Client) trackSend(kind, target, requestID string, send func() error) (SentMessage, error) {
+	now := time.Now()
+	c.sentMu.Lock()
+	if c.sent == nil {
+		c.sent = make(map[string]*SentMessage)
+	}
+	m := &SentMessage{Kind: kind, Target: target, Status: "pending", RequestID: requestID, CreatedAt: now}
+	for n := now.UnixNano(); ; n++ {
+		m.ID = fmt.Sprintf("msg_%d", n)
+		if c.sent[m.ID] == nil {
+			break
+		}
+	}
+	c.sent[m.ID] = m
+	c.sentOrder = append(c.sentOrder, m.ID)
+	if len(c.sentOrder) > sentMessagesMax {
+		delete(c.sent, c.sentOrder[0])
+		c.sentOrder = c.sentOrder[1:]
+	}
+	c.sentMu.Unlock()
+
+	err := send()
+	if err != nil {
+		log.Printf("Failed to send %s to %s [%s]: %v", m.ID, target, requestID, err)
+	} else {
+		log.Printf("Sent %s to %s [%s]", m.ID, target, requestID)
+	}
+
+	c.sentMu.Lock()
+	defer c.sentMu.Unlock()
+	if err != nil {
+		m.Status = "failed"
+		m.Error = err.Error()
+	} else {
+		m.Status = "sent"
+		m.SentAt = time.Now()
+	}
+	return *m, err
+}
+
+// confirmSent records the server's answer to a labeled message:
+// acknowledged, or rejected with the error it answered with
+func (c *Client) confirmSent(id string, err error) SentMessage {
+	c.sentMu.Lock()
+	defer c.sentMu.Unlock()
+	m := c.sent[id]
+	if m == nil {
+		return SentMessage{ID: id}
+	}
+	if err != nil {
+		m.Status = "rejected"
+		m.Error = err.Error()
+	} else {
+		m.Status = "acknowledged"
+	}
+	return *m
+}
+
+// SentMessage returns the status of a recently sent message
+func (c *Client) SentMessage(id string) (SentMessage, bool) {
+	c.sentMu.Lock()
+	defer c.sentMu.Unlock()
+	m := c.sent[id]
+	if m == nil {
+		return SentMessage{}, false
+	}
+	return *m, true
+}
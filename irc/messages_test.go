@@ -0,0 +1,58 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSentMessageStatus(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("token")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/api/send", `{"target":"#test","message":"hello"}`)
+	var out struct{ Status, ID string }
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil || out.Status != "sent" || out.ID == "" {
+		t.Fatalf("Expected a sent message with an ID, got %d %+v", rec.Code, out)
+	}
+
+	rec = do("GET", "/api/message/"+out.ID, "")
+	var m SentMessage
+	if err := json.NewDecoder(rec.Body).Decode(&m); err != nil || rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if m.ID != out.ID || m.Kind != "privmsg" || m.Target != "#test" || m.Status != "sent" || m.SentAt.IsZero() {
+		t.Errorf("Unexpected message status: %+v", m)
+	}
+
+	if rec := do("GET", "/api/message/msg_0", ""); rec.Code != 404 {
+		t.Errorf("Expected 404 for an unknown ID, got %d", rec.Code)
+	}
+}
+
+func TestSentMessageFailed(t *testing.T) {
+	client := NewClient()
+	m, err := client.trackSend("notice", "#test", "", func() error { return client.Notice("#test", "hi") })
+	if err == nil || m.Status != "failed" || m.Error == "" || !m.SentAt.IsZero() {
+		t.Errorf("Expected a failed message without a connection, got %+v (%v)", m, err)
+	}
+	if got, ok := client.SentMessage(m.ID); !ok || got.Status != "failed" {
+		t.Errorf("Expected the failure to be recorded, got %+v", got)
+	}
+
+	for i := 0; i < sentMessagesMax+1; i++ {
+		client.trackSend("privmsg", "#test", "", func() error { return nil })
+	}
+	if _, ok := client.SentMessage(m.ID); ok {
+		t.Error("The oldest message should be forgotten past the limit")
+	}
+}
@@ -0,0 +1,54 @@
+package irc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// handleMetrics serves gauges and counters in the Prometheus text format
+func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	connected := 0.0
+	if a.bot.Connected() {
+		connected = 1
+	}
+	lag := a.bot.LagStats()
+	writeGauge(w, "hanna_connected", "Whether the bot is registered with the IRC server", connected)
+	writeGauge(w, "hanna_channels", "Channels the bot is in", float64(len(a.bot.Channels())))
+	writeGauge(w, "hanna_lag_seconds", "Current lag to the IRC server", float64(lag.CurrentMs)/1000)
+	writeGauge(w, "hanna_lag_average_seconds", "Average PING round trip over the last samples", float64(lag.AverageMs)/1000)
+	writeGauge(w, "hanna_send_queue", "Lines waiting to be written to the server", float64(a.bot.pendingWrites.Load()))
+	mem := a.bot.MemoryStats()
+	writeGauge(w, "hanna_goroutines", "Goroutines running", float64(mem.Goroutines))
+	writeGauge(w, "hanna_heap_alloc_bytes", "Bytes of live heap objects", float64(mem.HeapAlloc))
+	users, channels := a.bot.stateSizes()
+	writeGauge(w, "hanna_user_info_entries", "Users the bot keeps information about", float64(users))
+	writeGauge(w, "hanna_channel_state_entries", "Channels the bot keeps state for", float64(channels))
+	fmt.Fprintf(w, "# HELP hanna_state_evicted_total Stale entries removed by state GC\n# TYPE hanna_state_evicted_total counter\n")
+	fmt.Fprintf(w, "hanna_state_evicted_total{kind=\"user\"} %d\n", a.bot.gc.usersEvicted.Load())
+	fmt.Fprintf(w, "hanna_state_evicted_total{kind=\"channel\"} %d\n", a.bot.gc.channelsEvicted.Load())
+
+	fmt.Fprintf(w, "# HELP hanna_ctcp_dropped_total CTCP requests left unanswered by the rate limit\n# TYPE hanna_ctcp_dropped_total counter\n")
+	fmt.Fprintf(w, "hanna_ctcp_dropped_total %d\n", a.bot.ctcp.droppedCount())
+	fmt.Fprintf(w, "# HELP hanna_mentions_throttled_total Mentions kept from the triggers by the per-user rate limit\n# TYPE hanna_mentions_throttled_total counter\n")
+	fmt.Fprintf(w, "hanna_mentions_throttled_total %d\n", a.bot.mentionLimit.throttled.Load())
+	fmt.Fprintf(w, "# HELP hanna_panics_total Panics recovered in handlers and goroutines\n# TYPE hanna_panics_total counter\n")
+	for _, p := range a.bot.Panics() {
+		fmt.Fprintf(w, "hanna_panics_total{where=%q} %d\n", p.Where, p.Count)
+	}
+
+	health := a.bot.TriggerHealth()
+	fmt.Fprintf(w, "# HELP hanna_trigger_queue_depth Events waiting for delivery to a trigger endpoint\n# TYPE hanna_trigger_queue_depth gauge\n")
+	for _, h := range health {
+		fmt.Fprintf(w, "hanna_trigger_queue_depth{endpoint=%q} %d\n", h.Name, h.QueueDepth)
+	}
+	fmt.Fprintf(w, "# HELP hanna_trigger_dropped_total Events dropped because a trigger endpoint's queue was full\n# TYPE hanna_trigger_dropped_total counter\n")
+	for _, h := range health {
+		fmt.Fprintf(w, "hanna_trigger_dropped_total{endpoint=%q} %d\n", h.Name, h.Dropped)
+	}
+}
+
+func writeGauge(w io.Writer, name, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+}
@@ -0,0 +1,92 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modeList describes a channel list filled by MODE #channel +<mode> replies
+type modeList struct {
+	mode    byte   // b, I or e
+	reqType string // requestSpecs key correlating the replies
+}
+
+// modeLists maps the API names of the channel lists to their modes
+var modeLists = map[string]modeList{
+	"ban":    {'b', "banlist"},
+	"invite": {'I', "invitelist"},
+	"except": {'e', "exceptlist"},
+}
+
+// openModeList empties a channel's list when the first entry of a reply
+// arrives, so each reply replaces the list instead of adding to it. The
+// caller holds channelStatesMu.
+func (c *Client) openModeList(state *ChannelState, mode byte) {
+	key := string(mode) + state.Name
+	if c.modeListsOpen[key] {
+		return
+	}
+	c.modeListsOpen[key] = true
+	clearModeList(state, mode)
+}
+
+// closeModeList ends a reply; a reply without entries empties the list
+func (c *Client) closeModeList(channel string, mode byte) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+	channel = strings.ToLower(channel)
+	key := string(mode) + channel
+	if !c.modeListsOpen[key] {
+		if state := c.channelStates[channel]; state != nil {
+			clearModeList(state, mode)
+		}
+	}
+	delete(c.modeListsOpen, key)
+}
+
+func clearModeList(state *ChannelState, mode byte) {
+	switch mode {
+	case 'b':
+		state.BanList = make([]BanListEntry, 0)
+	case 'I':
+		state.InviteList = make([]InviteListEntry, 0)
+	case 'e':
+		state.ExceptList = make([]ExceptListEntry, 0)
+	}
+}
+
+// RefreshModeList requests a channel's ban, invite or except list from the
+// server and returns a request ID; the channel state holds the new list once
+// the request completes
+func (c *Client) RefreshModeList(channel, list string) (string, error) {
+	l, ok := modeLists[list]
+	if !ok {
+		return "", fmt.Errorf("unknown list %q (use ban, invite or except)", list)
+	}
+	if !isChannelName(channel) || strings.ContainsAny(channel, " ,\r\n") {
+		return "", fmt.Errorf("invalid channel %q", channel)
+	}
+	if modes := c.getServerInfo().ISupportTags["CHANMODES"]; modes != "" && !strings.ContainsRune(modes, rune(l.mode)) {
+		return "", fmt.Errorf("the server does not support %s lists", list)
+	}
+	return c.StartRequest(l.reqType, channel, fmt.Sprintf("MODE %s +%c", channel, l.mode)), nil
+}
+
+// ModeList returns a copy of a channel's ban, invite or except list
+func (c *Client) ModeList(channel, list string) (any, bool) {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+	state := c.channelStates[strings.ToLower(channel)]
+	if state == nil {
+		return nil, false
+	}
+	switch list {
+	case "ban":
+		return append([]BanListEntry{}, state.BanList...), true
+	case "invite":
+		return append([]InviteListEntry{}, state.InviteList...), true
+	case "except":
+		return append([]ExceptListEntry{}, state.ExceptList...), true
+	}
+	return nil, false
+}
@@ -0,0 +1,68 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModeListRefresh(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+	markConnected(client)
+	api := client.CreateAPI("token")
+
+	client.handleLine(":TestBot!b@host JOIN #test")
+	<-sent // NAMES
+	client.handleLine(":server 367 TestBot #test *!*@old.example op 1700000000")
+	client.handleLine(":server 367 TestBot #test *!*@gone.example op 1700000000")
+	client.handleLine(":server 368 TestBot #test :End of Channel Ban List")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest("POST", "/api/modelist", strings.NewReader(`{"channel":"#test","list":"ban"}`))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		done <- rec
+	}()
+	select {
+	case line := <-sent:
+		if line != "MODE #test +b" {
+			t.Fatalf("Expected MODE #test +b, got %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No MODE query sent")
+	}
+	client.handleLine(":server 367 TestBot #test *!*@old.example op 1700000000")
+	client.handleLine(":server 368 TestBot #test :End of Channel Ban List")
+
+	rec := <-done
+	var out struct {
+		Entries []BanListEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil || rec.Code != 200 {
+		t.Fatalf("Unexpected response %d: %v", rec.Code, err)
+	}
+	if len(out.Entries) != 1 || out.Entries[0].Mask != "*!*@old.example" {
+		t.Errorf("Expected the list to be replaced, got %+v", out.Entries)
+	}
+
+	// An empty reply empties the list
+	client.handleLine(":server 368 TestBot #test :End of Channel Ban List")
+	if entries, _ := client.ModeList("#test", "ban"); len(entries.([]BanListEntry)) != 0 {
+		t.Errorf("Expected an empty ban list, got %+v", entries)
+	}
+
+	client.handleLine(":server 005 TestBot CHANMODES=b,k,l,imnpst :are supported by this server")
+	if _, err := client.RefreshModeList("#test", "except"); err == nil {
+		t.Error("Expected an error for a list the server does not support")
+	}
+	if _, err := client.RefreshModeList("#test", "quiet"); err == nil {
+		t.Error("Expected an error for an unknown list")
+	}
+}
@@ -0,0 +1,137 @@
+package irc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Minimal MessagePack encoding for API responses and trigger payloads.
+// Values go through their JSON encoding first, so field names, omitempty and
+// times are the same as in JSON; integers stay integers and everything else
+// is a float64.
+
+// marshalMsgpack encodes v as MessagePack
+func marshalMsgpack(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToMsgpack(data)
+}
+
+// jsonToMsgpack re-encodes a JSON document as MessagePack
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	tree, err := decodeJSONTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgpack(nil, tree)
+}
+
+// decodeJSONTree decodes a JSON document keeping numbers as json.Number, so
+// integers can be told from floats
+func decodeJSONTree(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// appendMsgpack appends a decoded JSON value
+func appendMsgpack(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return msgpackInt(buf, i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case string:
+		buf = msgpackHeader(buf, len(v), 0xa0, 31, [3]byte{0xd9, 0xda, 0xdb})
+		return append(buf, v...), nil
+	case []any:
+		buf = msgpackHeader(buf, len(v), 0x90, 15, [3]byte{0, 0xdc, 0xdd})
+		var err error
+		for _, item := range v {
+			if buf, err = appendMsgpack(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		// Sorted like JSON objects, so the same value always encodes the same
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = msgpackHeader(buf, len(v), 0x80, 15, [3]byte{0, 0xde, 0xdf})
+		var err error
+		for _, k := range keys {
+			buf, _ = appendMsgpack(buf, k)
+			if buf, err = appendMsgpack(buf, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("msgpack: unexpected %T", v)
+}
+
+// msgpackInt appends an integer in its shortest form
+func msgpackInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 127:
+		return append(buf, byte(i))
+	case i < 0 && i >= -32:
+		return append(buf, byte(0xe0|(i+32)))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(buf, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(i))
+	case i >= 0:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), uint64(i))
+	case i >= math.MinInt8:
+		return append(buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(i))
+}
+
+// msgpackHeader appends the type and length of a string, array or map: the
+// fix form up to fixMax, else the shortest of the 8 (strings only), 16 and
+// 32-bit length forms
+func msgpackHeader(buf []byte, n int, fix byte, fixMax int, codes [3]byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(buf, fix|byte(n))
+	case codes[0] != 0 && n <= math.MaxUint8:
+		return append(buf, codes[0], byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, codes[1]), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, codes[2]), uint32(n))
+}
@@ -0,0 +1,322 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsOutput publishes every bus event to NATS on the subject
+// <prefix>.<type>.<channel>, e.g. hanna.privmsg.#general. Events without a
+// channel use _global (quit, nick) or _private (messages to the bot). With a
+// JetStream stream configured the subjects are persisted so consumers can
+// replay them.
+type natsOutput struct {
+	url    *url.URL
+	prefix string
+	stream string        // JetStream stream name, empty for core NATS only
+	maxAge time.Duration // stream retention, 0 keeps messages forever
+	events <-chan Event
+}
+
+// natsInfo is the part of the server's INFO message the client needs
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+func (c *Client) loadNATS() {
+	raw := strings.TrimSpace(os.Getenv("NATS_URL"))
+	if raw == "" {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		log.Fatalf("FATAL: Invalid NATS_URL %q (expected nats://[user:pass@]host:port or tls://...)", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	n := &natsOutput{
+		url:    u,
+		prefix: strings.Trim(getenv("NATS_SUBJECT_PREFIX", "hanna"), "."),
+		stream: strings.TrimSpace(os.Getenv("NATS_STREAM")),
+		maxAge: time.Duration(intenv("NATS_STREAM_MAX_AGE_HOURS", 0)) * time.Hour,
+	}
+	if n.prefix == "" || strings.ContainsAny(n.prefix, " *>") {
+		log.Fatalf("FATAL: Invalid NATS_SUBJECT_PREFIX %q", n.prefix)
+	}
+	n.events, _ = c.bus.SubscribeChan("nats", 1000)
+	c.nats = n
+}
+
+// natsSubject returns the subject an event is published on
+func natsSubject(prefix string, e Event) string {
+	scope := "_global"
+	if isChannelName(e.Target) {
+		scope = strings.ToLower(e.Target)
+	} else if e.Target != "" && e.Type != "mode" {
+		scope = "_private"
+	}
+	scope = strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, scope)
+	return prefix + "." + e.Type + "." + scope
+}
+
+// run keeps a NATS connection open until ctx is cancelled. Events arriving
+// while disconnected are dropped.
+func (n *natsOutput) run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := n.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("NATS output: connection ended: %v (reconnecting in %s)", err, backoff)
+		timer := time.NewTimer(backoff)
+	drain:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-n.events:
+			case <-timer.C:
+				break drain
+			}
+		}
+		backoff = min(backoff*2, 2*time.Minute)
+	}
+}
+
+// natsConn is one client connection; writes are shared by the publisher and
+// the reader answering server PINGs
+type natsConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	wmu   sync.Mutex
+	w     *bufio.Writer
+	inbox chan []byte // replies to requests
+}
+
+func (nc *natsConn) write(parts ...string) error {
+	nc.wmu.Lock()
+	defer nc.wmu.Unlock()
+	nc.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	for _, p := range parts {
+		if _, err := nc.w.WriteString(p); err != nil {
+			return err
+		}
+	}
+	return nc.w.Flush()
+}
+
+func (nc *natsConn) readLine() (string, error) {
+	line, err := nc.r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (n *natsOutput) session(ctx context.Context) error {
+	raw, err := (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", n.url.Host)
+	if err != nil {
+		return err
+	}
+	nc := &natsConn{conn: raw, r: bufio.NewReader(raw), inbox: make(chan []byte, 1)}
+	defer func() { nc.conn.Close() }()
+	sessionCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	context.AfterFunc(sessionCtx, func() { nc.conn.Close() })
+
+	raw.SetReadDeadline(time.Now().Add(30 * time.Second))
+	line, err := nc.readLine()
+	if err != nil {
+		return err
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("expected INFO, got %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("invalid INFO: %w", err)
+	}
+	if n.url.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: n.url.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("TLS handshake: %w", err)
+		}
+		nc.conn, nc.r = tlsConn, bufio.NewReader(tlsConn)
+	}
+	nc.w = bufio.NewWriter(nc.conn)
+
+	connect := map[string]any{"verbose": false, "pedantic": false, "name": "hanna", "lang": "go", "version": Version, "protocol": 1}
+	if user := n.url.User; user != nil {
+		if pass, ok := user.Password(); ok {
+			connect["user"], connect["pass"] = user.Username(), pass
+		} else {
+			connect["auth_token"] = user.Username()
+		}
+	}
+	data, _ := json.Marshal(connect)
+	if err := nc.write("CONNECT ", string(data), "\r\nPING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := nc.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return fmt.Errorf("server refused connection: %s", strings.TrimPrefix(line, "-ERR "))
+		}
+	}
+	nc.conn.SetReadDeadline(time.Time{})
+	log.Printf("NATS output: connected to %s", n.url.Host)
+
+	go func() { cancel(nc.readLoop()) }()
+
+	if n.stream != "" {
+		if err := n.ensureStream(sessionCtx, nc); err != nil {
+			log.Printf("NATS output: JetStream stream %s unavailable, publishing without persistence: %v", n.stream, err)
+		}
+	}
+
+	for {
+		select {
+		case <-sessionCtx.Done():
+			if err := context.Cause(sessionCtx); !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return ctx.Err()
+		case e := <-n.events:
+			payload, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			if info.MaxPayload > 0 && len(payload) > info.MaxPayload {
+				log.Printf("NATS output: dropping %s event larger than max_payload", e.Type)
+				continue
+			}
+			subject := natsSubject(n.prefix, e)
+			if err := nc.write("PUB ", subject, " ", strconv.Itoa(len(payload)), "\r\n", string(payload), "\r\n"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readLoop answers PINGs and routes request replies until the connection fails
+func (nc *natsConn) readLoop() error {
+	for {
+		line, err := nc.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PING":
+			if err := nc.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return fmt.Errorf("invalid MSG line %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(nc.r, payload); err != nil {
+				return err
+			}
+			select {
+			case nc.inbox <- payload[:size]:
+			default:
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimPrefix(line, "-ERR "))
+		}
+	}
+}
+
+// request publishes to subject and waits for one reply
+func (nc *natsConn) request(ctx context.Context, subject string, body any) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	inbox := "_INBOX." + hex.EncodeToString(b)
+	if err := nc.write("SUB ", inbox, " 1\r\nUNSUB 1 1\r\nPUB ", subject, " ", inbox, " ", strconv.Itoa(len(data)), "\r\n", string(data), "\r\n"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	select {
+	case reply := <-nc.inbox:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, errors.New("no reply (is JetStream enabled?)")
+	}
+}
+
+// ensureStream creates the JetStream stream capturing <prefix>.>, or updates
+// it when it already exists
+func (n *natsOutput) ensureStream(ctx context.Context, nc *natsConn) error {
+	config := map[string]any{
+		"name":     n.stream,
+		"subjects": []string{n.prefix + ".>"},
+		"storage":  "file",
+		"max_age":  int64(n.maxAge),
+	}
+	var resp struct {
+		Error *struct {
+			ErrCode     int    `json:"err_code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	for _, op := range []string{"CREATE", "UPDATE"} {
+		reply, err := nc.request(ctx, "$JS.API.STREAM."+op+"."+n.stream, config)
+		if err != nil {
+			return err
+		}
+		resp.Error = nil
+		if err := json.Unmarshal(reply, &resp); err != nil {
+			return err
+		}
+		if resp.Error == nil {
+			log.Printf("NATS output: JetStream stream %s ready for %s.>", n.stream, n.prefix)
+			return nil
+		}
+		if resp.Error.ErrCode != 10058 { // anything but "stream name already in use"
+			break
+		}
+	}
+	return errors.New(resp.Error.Description)
+}
@@ -0,0 +1,123 @@
+package irc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNATSSubject(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Type: "privmsg", Target: "#Go.Nuts"}, "hanna.privmsg.#go_nuts"},
+		{Event{Type: "privmsg", Target: "TestBot"}, "hanna.privmsg._private"},
+		{Event{Type: "quit", Channels: []string{"#test"}}, "hanna.quit._global"},
+		{Event{Type: "mode", Target: "TestBot"}, "hanna.mode._global"},
+	}
+	for _, tt := range tests {
+		if got := natsSubject("hanna", tt.event); got != tt.want {
+			t.Errorf("%+v: expected %s, got %s", tt.event, tt.want, got)
+		}
+	}
+}
+
+// fakeNATS accepts one client, answers the JetStream stream request and
+// reports every PUB as "subject payload"
+func fakeNATS(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	pubs := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				pubs <- "CONNECT " + fields[1]
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				payload := make([]byte, size+2)
+				io.ReadFull(r, payload)
+				if len(fields) == 4 { // request with a reply subject
+					reply := `{"type":"io.nats.jetstream.api.v1.stream_create_response","config":{}}`
+					if strings.Contains(fields[1], ".CREATE.") {
+						reply = `{"error":{"code":400,"err_code":10058,"description":"stream name already in use"}}`
+					}
+					fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(reply), reply)
+				}
+				pubs <- fields[1] + " " + string(payload[:size])
+			}
+		}
+	}()
+	return ln.Addr().String(), pubs
+}
+
+func TestNATSOutput(t *testing.T) {
+	addr, pubs := fakeNATS(t)
+	t.Setenv("NATS_URL", "nats://secret-token@"+addr)
+	t.Setenv("NATS_STREAM", "IRC")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	defer client.Close()
+	go client.nats.run(client.context())
+
+	next := func() string {
+		t.Helper()
+		select {
+		case p := <-pubs:
+			return p
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for the NATS server")
+			return ""
+		}
+	}
+	if connect := next(); !strings.Contains(connect, `"auth_token":"secret-token"`) {
+		t.Errorf("Expected token auth in %s", connect)
+	}
+	subject, body, _ := strings.Cut(next(), " ")
+	var config struct {
+		Subjects []string `json:"subjects"`
+	}
+	json.Unmarshal([]byte(body), &config)
+	if subject != "$JS.API.STREAM.CREATE.IRC" || len(config.Subjects) != 1 || config.Subjects[0] != "hanna.>" {
+		t.Errorf("Unexpected stream request %s %s", subject, body)
+	}
+	if update := next(); !strings.HasPrefix(update, "$JS.API.STREAM.UPDATE.IRC ") {
+		t.Errorf("Expected stream update after name in use, got %s", update)
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :hello")
+	subject, payload, _ := strings.Cut(next(), " ")
+	if subject != "hanna.privmsg.#test" {
+		t.Errorf("Unexpected subject %s", subject)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(payload), &e); err != nil || e.Sender != "alice" || e.Text != "hello" {
+		t.Errorf("Unexpected payload %s (%v)", payload, err)
+	}
+}
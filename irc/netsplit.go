@@ -0,0 +1,157 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A netsplit QUIT reason is exactly two server names, e.g. "hub.net leaf.net"
+var netsplitReasonRe = regexp.MustCompile(`^((?:[A-Za-z0-9-]+\.)+[A-Za-z0-9-]+) ((?:[A-Za-z0-9-]+\.)+[A-Za-z0-9-]+)$`)
+
+// SplitUser is a user who quit because of a netsplit and has not rejoined yet
+type SplitUser struct {
+	Nick    string    `json:"nick"`
+	Servers string    `json:"servers"` // the two servers from the QUIT reason
+	Since   time.Time `json:"since"`
+}
+
+type netsplitTracker struct {
+	mu         sync.Mutex
+	users      map[string]*SplitUser // nick (lowercase) -> split user
+	splitBatch map[string][]string   // servers -> nicks awaiting a "netsplit" event
+	joinBatch  map[string][]string   // servers -> nicks awaiting a "netjoin" event
+	rejoining  map[string]bool       // nick (lowercase) -> back, until its netjoin is reported
+	batchDelay time.Duration         // quiet period before a batch is reported
+	timeout    time.Duration         // how long split users are kept before being dropped
+}
+
+func newNetsplitTracker(timeout time.Duration) *netsplitTracker {
+	return &netsplitTracker{
+		users:      make(map[string]*SplitUser),
+		splitBatch: make(map[string][]string),
+		joinBatch:  make(map[string][]string),
+		rejoining:  make(map[string]bool),
+		batchDelay: 3 * time.Second,
+		timeout:    timeout,
+	}
+}
+
+// parseNetsplitReason returns the servers of a netsplit QUIT reason
+func parseNetsplitReason(reason string) (string, bool) {
+	if !netsplitReasonRe.MatchString(reason) {
+		return "", false
+	}
+	return reason, true
+}
+
+// markSplit records a user lost to a netsplit. They stay in channel state
+// until they rejoin or the split times out.
+func (c *Client) markSplit(nick, servers string) {
+	n := c.netsplit
+	key := strings.ToLower(nick)
+	user := &SplitUser{Nick: nick, Servers: servers, Since: time.Now()}
+
+	n.mu.Lock()
+	n.users[key] = user
+	first := len(n.splitBatch[servers]) == 0
+	n.splitBatch[servers] = append(n.splitBatch[servers], nick)
+	n.mu.Unlock()
+
+	if first {
+		time.AfterFunc(n.batchDelay, func() { c.flushNetsplitBatch("netsplit", servers) })
+	}
+	time.AfterFunc(n.timeout, func() {
+		n.mu.Lock()
+		expired := n.users[key] == user
+		if expired {
+			delete(n.users, key)
+		}
+		n.mu.Unlock()
+		if expired {
+			log.Printf("Split user %s did not return, removing", nick)
+			channels := c.userChannels(nick)
+			c.RemoveUserFromAllChannels(nick)
+			for _, ch := range channels {
+				c.recordChange(StateChange{Type: "user_left", Channel: ch, Nick: nick, Reason: "netsplit"})
+			}
+		}
+	})
+}
+
+// rejoinFromSplit reports whether a joining user is returning from a
+// netsplit, in which case the join is folded into a "netjoin" event
+func (c *Client) rejoinFromSplit(nick string) bool {
+	n := c.netsplit
+	key := strings.ToLower(nick)
+
+	n.mu.Lock()
+	user := n.users[key]
+	if user == nil {
+		// Joins to the user's other channels belong to the same netjoin
+		back := n.rejoining[key]
+		n.mu.Unlock()
+		return back
+	}
+	// A user rejoining several channels is only reported once
+	delete(n.users, key)
+	n.rejoining[key] = true
+	first := len(n.joinBatch[user.Servers]) == 0
+	n.joinBatch[user.Servers] = append(n.joinBatch[user.Servers], user.Nick)
+	n.mu.Unlock()
+
+	if first {
+		time.AfterFunc(n.batchDelay, func() { c.flushNetsplitBatch("netjoin", user.Servers) })
+	}
+	return true
+}
+
+// isSplit reports whether the nick is currently split away
+func (c *Client) isSplit(nick string) bool {
+	c.netsplit.mu.Lock()
+	defer c.netsplit.mu.Unlock()
+	return c.netsplit.users[strings.ToLower(nick)] != nil
+}
+
+func (c *Client) flushNetsplitBatch(eventType, servers string) {
+	n := c.netsplit
+	batch := n.splitBatch
+	if eventType == "netjoin" {
+		batch = n.joinBatch
+	}
+
+	n.mu.Lock()
+	nicks := batch[servers]
+	delete(batch, servers)
+	if eventType == "netjoin" {
+		for _, nick := range nicks {
+			delete(n.rejoining, strings.ToLower(nick))
+		}
+	}
+	n.mu.Unlock()
+	if len(nicks) == 0 {
+		return
+	}
+
+	sort.Strings(nicks)
+	message := fmt.Sprintf("%s %s: %d user(s): %s", strings.ToUpper(eventType[:1])+eventType[1:], servers, len(nicks), strings.Join(nicks, ", "))
+	log.Printf("%s", message)
+	c.sendTriggerEvent(eventType, "", "", message, strings.Join(nicks, " "), nil)
+}
+
+// SplitUsers returns the users currently split away
+func (c *Client) SplitUsers() []SplitUser {
+	c.netsplit.mu.Lock()
+	defer c.netsplit.mu.Unlock()
+
+	users := make([]SplitUser, 0, len(c.netsplit.users))
+	for _, u := range c.netsplit.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Nick < users[j].Nick })
+	return users
+}
@@ -0,0 +1,111 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNetsplitReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"hub.example.net leaf.example.net", true},
+		{"irc.a.org irc.b.org", true},
+		{"Quit: leaving", false},
+		{"hub.example.net", false},
+		{"Ping timeout: 240 seconds", false},
+		{"hub.example.net leaf.example.net extra", false},
+	}
+	for _, tt := range tests {
+		if _, ok := parseNetsplitReason(tt.reason); ok != tt.want {
+			t.Errorf("parseNetsplitReason(%q) = %v, want %v", tt.reason, ok, tt.want)
+		}
+	}
+}
+
+func TestNetsplitAndNetjoin(t *testing.T) {
+	received := newTriggerRecorder(t, "quit", "join", "netsplit", "netjoin")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	client.netsplit.batchDelay = 50 * time.Millisecond
+
+	client.AddUserToChannel("#test", "alice", "o")
+	client.AddUserToChannel("#test", "bob", "")
+	client.AddUserToChannel("#other", "alice", "")
+
+	client.handleLine(":alice!a@host QUIT :hub.example.net leaf.example.net")
+	client.handleLine(":bob!b@host QUIT :hub.example.net leaf.example.net")
+
+	if !client.HasChannelUser("#test", "alice") {
+		t.Error("Split users should stay in channel state")
+	}
+	if len(client.SplitUsers()) != 2 {
+		t.Errorf("Expected 2 split users, got %v", client.SplitUsers())
+	}
+
+	payload := expectTrigger(t, received)
+	if payload.EventType != "netsplit" || !strings.Contains(payload.Message, "2 user(s): alice, bob") {
+		t.Errorf("Expected one netsplit summary, got %+v", payload)
+	}
+	expectNoTrigger(t, received)
+
+	client.handleLine(":alice!a@host JOIN #test")
+	client.handleLine(":alice!a@host JOIN #other")
+	payload = expectTrigger(t, received)
+	if payload.EventType != "netjoin" || !strings.Contains(payload.Message, "1 user(s): alice") {
+		t.Errorf("Expected a netjoin summary, got %+v", payload)
+	}
+	expectNoTrigger(t, received)
+
+	if client.GetChannelStates()["#test"]["alice"] != "o" {
+		t.Error("Rejoining user should keep their modes")
+	}
+	if client.isSplit("alice") || !client.isSplit("bob") {
+		t.Error("Only bob should remain split")
+	}
+
+	// A regular quit still removes the user and is reported
+	client.handleLine(":carol!c@host JOIN #test")
+	expectTrigger(t, received)
+	client.handleLine(":carol!c@host QUIT :Quit: bye")
+	if payload := expectTrigger(t, received); payload.EventType != "quit" {
+		t.Errorf("Expected quit event, got %+v", payload)
+	}
+	if client.HasChannelUser("#test", "carol") {
+		t.Error("Regular quit should remove the user")
+	}
+}
+
+func TestNetsplitTimeout(t *testing.T) {
+	client := NewClient()
+	client.netsplit = newNetsplitTracker(50 * time.Millisecond)
+	client.netsplit.batchDelay = time.Hour
+
+	client.AddUserToChannel("#test", "alice", "")
+	client.handleLine(":alice!a@host QUIT :hub.example.net leaf.example.net")
+
+	time.Sleep(150 * time.Millisecond)
+	if client.HasChannelUser("#test", "alice") || client.isSplit("alice") {
+		t.Error("Split user should be dropped after the timeout")
+	}
+}
+
+func TestJoinOfListedUserIsReported(t *testing.T) {
+	received := newTriggerRecorder(t, "join", "netjoin")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	// A user still listed, e.g. after a missed PART, is not a netsplit rejoin
+	client.AddUserToChannel("#test", "alice", "v")
+	client.handleLine(":alice!a@host JOIN #test")
+	if payload := expectTrigger(t, received); payload.EventType != "join" {
+		t.Errorf("Expected a join event, got %+v", payload)
+	}
+	if client.GetChannelStates()["#test"]["alice"] != "v" {
+		t.Error("Expected alice's modes to be kept")
+	}
+}
@@ -0,0 +1,165 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNickAttempts is how many fallback nicks are tried before only random
+// suffixes are used
+const maxNickAttempts = 20
+
+var nickStrategies = map[string]bool{"underscore": true, "numeric": true, "truncate": true, "random": true}
+
+// nickCollision picks another nick when the wanted one is taken during
+// registration, and later takes the wanted nick back
+type nickCollision struct {
+	strategy string        // NICK_COLLISION: underscore, numeric, truncate or random
+	altNicks []string      // IRC_ALTNICKS, tried first
+	reclaim  time.Duration // NICK_RECLAIM_SECONDS, 0 to never reclaim
+
+	mu       sync.Mutex
+	primary  string // the wanted nick: IRC_NICK or the last one set through the API
+	attempts int    // fallbacks tried on the current connection
+	fallback bool   // the current nick is a fallback for primary
+}
+
+func (c *Client) loadNickCollision() {
+	c.nickCollision.primary = c.Nick()
+	c.nickCollision.strategy = strings.ToLower(getenv("NICK_COLLISION", "underscore"))
+	if !nickStrategies[c.nickCollision.strategy] {
+		log.Fatalf("FATAL: Invalid NICK_COLLISION %q (use underscore, numeric, truncate or random)", c.nickCollision.strategy)
+	}
+	for _, nick := range strings.Split(os.Getenv("IRC_ALTNICKS"), ",") {
+		if nick = strings.TrimSpace(nick); nick != "" {
+			c.nickCollision.altNicks = append(c.nickCollision.altNicks, sanitizeNick(nick))
+		}
+	}
+	c.nickCollision.reclaim = time.Duration(max(intenv("NICK_RECLAIM_SECONDS", 60), 0)) * time.Second
+}
+
+// registrationNick returns the nick to register with: the wanted nick,
+// whatever fallback the previous connection ended up with
+func (c *Client) registrationNick() string {
+	nc := &c.nickCollision
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if nc.fallback && nc.primary != "" {
+		c.setNick(nc.primary)
+	} else {
+		nc.primary = c.Nick()
+	}
+	nc.attempts, nc.fallback = 0, false
+	return c.Nick()
+}
+
+// wantNick records the nick the bot should hold and reclaim
+func (c *Client) wantNick(nick string) {
+	c.nickCollision.mu.Lock()
+	defer c.nickCollision.mu.Unlock()
+	c.nickCollision.primary = nick
+	c.nickCollision.fallback = false
+}
+
+func (c *Client) primaryNick() string {
+	c.nickCollision.mu.Lock()
+	defer c.nickCollision.mu.Unlock()
+	return c.nickCollision.primary
+}
+
+// nickInUse handles a refused nick (433, 432 or 437). Before registration
+// the next fallback is sent; afterwards the bot keeps its current nick.
+func (c *Client) nickInUse(nick, reason string) {
+	if c.State() == StateConnected {
+		log.Printf("Cannot change nick to %s: %s", nick, reason)
+		return
+	}
+	c.nickCollision.mu.Lock()
+	c.nickCollision.attempts++
+	next := c.fallbackNick(c.nickCollision.attempts)
+	c.nickCollision.fallback = true
+	c.nickCollision.mu.Unlock()
+	log.Printf("Nick %s is unavailable (%s), switching to %s", nick, reason, next)
+	c.setNick(next)
+	c.rawf("NICK %s", next)
+}
+
+// fallbackNick returns the nick for the nth attempt: the IRC_ALTNICKS in
+// order, then the primary nick with a suffix by strategy. With NICKLEN known
+// the nick is cut to fit, so the server does not truncate it back into a
+// nick already taken; truncate also keeps within the primary nick's length
+// when NICKLEN is not known yet.
+func (c *Client) fallbackNick(n int) string {
+	nc := &c.nickCollision
+	if n <= len(nc.altNicks) {
+		return nc.altNicks[n-1]
+	}
+	n -= len(nc.altNicks)
+	base := nc.primary
+	if base == "" {
+		base = c.Nick()
+	}
+	strategy := nc.strategy
+	if n > maxNickAttempts {
+		strategy = "random"
+	}
+	var suffix string
+	switch strategy {
+	case "numeric", "truncate":
+		suffix = strconv.Itoa(n)
+	case "random":
+		suffix = fmt.Sprintf("%04d", rand.IntN(10000))
+	default:
+		suffix = strings.Repeat("_", n)
+	}
+	limit := c.isupportInt("NICKLEN")
+	if limit == 0 && strategy == "truncate" {
+		limit = len(base)
+	}
+	if limit > len(suffix) && len(base)+len(suffix) > limit {
+		base = base[:limit-len(suffix)]
+	}
+	return base + suffix
+}
+
+// nickReclaimLoop asks for the primary nick again every
+// NICK_RECLAIM_SECONDS while the bot holds a fallback
+func (c *Client) nickReclaimLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(c.nickCollision.reclaim)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.reclaimNick()
+		}
+	}
+}
+
+// reclaimNick sends NICK for the primary nick if the bot does not hold it
+func (c *Client) reclaimNick() {
+	primary := c.primaryNick()
+	if primary == "" || strings.EqualFold(primary, c.Nick()) || c.State() != StateConnected {
+		return
+	}
+	log.Printf("Trying to reclaim nick %s", primary)
+	c.rawf("NICK %s", primary)
+}
+
+// reclaimOnRelease reclaims the primary nick as soon as its holder quits or
+// changes nick
+func (c *Client) reclaimOnRelease(e Event) {
+	if (e.Type != "quit" && e.Type != "nick") || e.Self || e.Replayed {
+		return
+	}
+	if primary := c.primaryNick(); primary != "" && strings.EqualFold(e.Sender, primary) {
+		c.reclaimNick()
+	}
+}
@@ -0,0 +1,83 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNickCollisionStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		strategy string
+		want     []string
+	}{
+		{"underscore", []string{"NICK Alt1", "NICK Hanna_", "NICK Hanna__"}},
+		{"numeric", []string{"NICK Alt1", "NICK Hanna1", "NICK Hanna2"}},
+		{"truncate", []string{"NICK Alt1", "NICK Hann1", "NICK Hann2"}},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			t.Setenv("IRC_NICK", "Hanna")
+			t.Setenv("IRC_ALTNICKS", "Alt1")
+			t.Setenv("NICK_COLLISION", tc.strategy)
+			client := NewClient()
+			var lines []string
+			client.testRawCapture = func(line string) { lines = append(lines, line) }
+
+			client.registrationNick()
+			client.handleLine(":server 433 * Hanna :Nickname is already in use")
+			client.handleLine(":server 433 * Alt1 :Nickname is already in use")
+			client.handleLine(":server 433 * " + strings.TrimPrefix(lines[1], "NICK ") + " :Nickname is already in use")
+			if strings.Join(lines, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Expected %q, got %q", tc.want, lines)
+			}
+		})
+	}
+}
+
+func TestNickCollisionFitsNickLen(t *testing.T) {
+	t.Setenv("IRC_NICK", "LongBotName")
+	t.Setenv("NICK_COLLISION", "random")
+	client := NewClient()
+	client.handleLine(":server 005 * NICKLEN=9 :are supported by this server")
+	nick := client.fallbackNick(1)
+	if len(nick) != 9 || !strings.HasPrefix(nick, "LongB") {
+		t.Errorf("Expected a random fallback cut to NICKLEN=9, got %q", nick)
+	}
+}
+
+func TestNickReclaim(t *testing.T) {
+	t.Setenv("IRC_NICK", "Hanna")
+	client := NewClient()
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	client.registrationNick()
+	client.handleLine(":server 433 * Hanna :Nickname is already in use")
+
+	// A new connection registers with the wanted nick again
+	if nick := client.registrationNick(); nick != "Hanna" {
+		t.Errorf("Expected to register as Hanna again, got %s", nick)
+	}
+	client.handleLine(":server 433 * Hanna :Nickname is already in use")
+	markConnected(client)
+	if client.Nick() != "Hanna_" {
+		t.Fatalf("Expected to register as Hanna_, got %s", client.Nick())
+	}
+
+	// Once connected a refused nick is kept as it is
+	lines = nil
+	client.handleLine(":server 433 Hanna_ Hanna :Nickname is already in use")
+	if len(lines) != 0 || client.Nick() != "Hanna_" {
+		t.Errorf("Expected no fallback after registration, got %q", lines)
+	}
+
+	client.handleLine(":Hanna!u@host QUIT :bye")
+	if len(lines) != 1 || lines[0] != "NICK Hanna" {
+		t.Errorf("Expected to reclaim Hanna when its holder quits, got %q", lines)
+	}
+	client.handleLine(":Hanna_!b@host NICK :Hanna")
+	lines = nil
+	client.reclaimNick()
+	if len(lines) != 0 {
+		t.Errorf("Expected no reclaim while holding the nick, got %q", lines)
+	}
+
+}
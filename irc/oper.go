@@ -0,0 +1,191 @@
+package irc
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// operEnabled reports whether an oper block (OPER_USER/OPER_PASS) is configured
+func (c *Client) operEnabled() bool {
+	return c.operUser != "" && c.operPass != ""
+}
+
+// IsOper reports whether the server has confirmed our operator status
+func (c *Client) IsOper() bool { return c.isOper.Load() }
+
+// operLogin sends OPER after registration when an oper block is configured
+func (c *Client) operLogin() {
+	if !c.operEnabled() {
+		return
+	}
+	log.Printf("Sending OPER as %s", c.operUser)
+	c.rawf("OPER %s %s", c.operUser, c.operPass)
+}
+
+// Kill disconnects a user from the network
+func (c *Client) Kill(nick, reason string) {
+	c.rawf("KILL %s :%s", nick, reason)
+}
+
+// Kline adds a server-local ban (ratbox/charybdis/solanum syntax). A
+// duration of zero makes the ban permanent.
+func (c *Client) Kline(mask string, minutes int, reason string) {
+	if minutes > 0 {
+		c.rawf("KLINE %d %s :%s", minutes, mask, reason)
+	} else {
+		c.rawf("KLINE %s :%s", mask, reason)
+	}
+}
+
+// Gline adds a network-wide ban (InspIRCd/UnrealIRCd syntax). A duration of
+// zero makes the ban permanent.
+func (c *Client) Gline(mask string, minutes int, reason string) {
+	c.rawf("GLINE %s %dm :%s", mask, minutes, reason)
+}
+
+// Squit disconnects a server link
+func (c *Client) Squit(server, reason string) {
+	c.rawf("SQUIT %s :%s", server, reason)
+}
+
+// Rehash asks the server to reload its configuration
+func (c *Client) Rehash() {
+	c.raw("REHASH")
+}
+
+// Links initiates a LINKS command and returns a request ID to track the response
+func (c *Client) Links() string {
+	return c.StartRequest("links", "", "LINKS")
+}
+
+// Map initiates a MAP command and returns a request ID to track the response
+func (c *Client) Map() string {
+	return c.StartRequest("map", "", "MAP")
+}
+
+// parseMapLine turns an RPL_MAP line such as "  `- leaf.example.net (12) [50.0%]"
+// into the server name, its tree depth and its user count when present
+func parseMapLine(line string) map[string]string {
+	trimmed := strings.TrimLeft(line, " |`-")
+	depth := (len(line) - len(trimmed)) / 2
+	fields := strings.Fields(trimmed)
+	if len(fields) == 0 {
+		return nil
+	}
+	entry := map[string]string{
+		"server": fields[0],
+		"depth":  strconv.Itoa(depth),
+		"raw":    line,
+	}
+	for _, f := range fields[1:] {
+		if strings.HasPrefix(f, "(") && strings.HasSuffix(f, ")") {
+			if users, err := strconv.Atoi(strings.Trim(f, "()")); err == nil {
+				entry["users"] = strconv.Itoa(users)
+			}
+		}
+		if strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+			entry["info"] = strings.Trim(f, "[]")
+		}
+	}
+	return entry
+}
+
+// operRoutes registers the oper endpoints. They are only available when an
+// oper block is configured and require the bot to hold operator status.
+func (a *API) operRoutes(mux *http.ServeMux) {
+	oper := func(next http.HandlerFunc) http.HandlerFunc {
+		return a.auth(a.scope("oper", func(w http.ResponseWriter, r *http.Request) {
+			if !a.bot.operEnabled() {
+				writeError(w, 404, codeUnavailable, "oper not configured (set OPER_USER and OPER_PASS)")
+				return
+			}
+			if !a.bot.Connected() {
+				writeError(w, 503, codeNotConnected, "bot not connected")
+				return
+			}
+			if !a.bot.IsOper() {
+				writeError(w, 409, codeConflict, "bot is not an IRC operator")
+				return
+			}
+			next(w, r)
+		}))
+	}
+
+	mux.HandleFunc("/api/oper/kill", oper(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Nick, Reason string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Nick) == "" {
+			writeError(w, 400, codeInvalidRequest, "nick required")
+			return
+		}
+		if in.Reason == "" {
+			in.Reason = "Killed"
+		}
+		a.bot.Kill(in.Nick, in.Reason)
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	}))
+
+	banHandler := func(apply func(mask string, minutes int, reason string)) http.HandlerFunc {
+		return oper(func(w http.ResponseWriter, r *http.Request) {
+			var in struct {
+				Mask     string `json:"mask"`
+				Duration int    `json:"duration"` // minutes, 0 = permanent
+				Reason   string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Mask) == "" {
+				writeError(w, 400, codeInvalidRequest, "mask required")
+				return
+			}
+			if in.Duration < 0 {
+				writeError(w, 400, codeInvalidRequest, "duration must not be negative")
+				return
+			}
+			if in.Reason == "" {
+				in.Reason = "Banned"
+			}
+			apply(in.Mask, in.Duration, in.Reason)
+			writeJSON(w, 200, map[string]string{"status": "ok"})
+		})
+	}
+	mux.HandleFunc("/api/oper/kline", banHandler(a.bot.Kline))
+	mux.HandleFunc("/api/oper/gline", banHandler(a.bot.Gline))
+
+	mux.HandleFunc("/api/oper/squit", oper(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Server, Reason string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Server) == "" {
+			writeError(w, 400, codeInvalidRequest, "server required")
+			return
+		}
+		a.bot.Squit(in.Server, in.Reason)
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	}))
+
+	mux.HandleFunc("/api/oper/rehash", oper(func(w http.ResponseWriter, r *http.Request) {
+		a.bot.Rehash()
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	}))
+
+	mux.HandleFunc("/api/oper/links", oper(func(w http.ResponseWriter, r *http.Request) {
+		result, ok := a.awaitRequest(w, r, a.bot.Links(), "links")
+		if !ok {
+			return
+		}
+		writeJSON(w, 200, map[string]any{
+			"links": result.Data,
+			"count": len(result.Data),
+		})
+	}))
+
+	mux.HandleFunc("/api/oper/map", oper(func(w http.ResponseWriter, r *http.Request) {
+		result, ok := a.awaitRequest(w, r, a.bot.Map(), "map")
+		if !ok {
+			return
+		}
+		writeJSON(w, 200, map[string]any{
+			"servers": result.Data,
+			"count":   len(result.Data),
+		})
+	}))
+}
@@ -0,0 +1,119 @@
+package irc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOperLoginOnWelcome(t *testing.T) {
+	oldUser, oldPass := os.Getenv("OPER_USER"), os.Getenv("OPER_PASS")
+	defer func() {
+		os.Setenv("OPER_USER", oldUser)
+		os.Setenv("OPER_PASS", oldPass)
+	}()
+	os.Setenv("OPER_USER", "hanna")
+	os.Setenv("OPER_PASS", "secret")
+
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":irc.server.com 001 TestBot :Welcome")
+	found := false
+	for _, line := range sent {
+		if line == "OPER hanna secret" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected OPER on welcome, got %v", sent)
+	}
+	if client.IsOper() {
+		t.Error("Should not be oper before RPL_YOUREOPER")
+	}
+	client.handleLine(":irc.server.com 381 TestBot :You are now an IRC operator")
+	if !client.IsOper() {
+		t.Error("Expected oper status after RPL_YOUREOPER")
+	}
+}
+
+func TestLinksAndMapParsing(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+
+	links := client.createPendingRequest("links", "")
+	client.handleLine(":hub.net 364 TestBot leaf.net hub.net :1 Leaf server")
+	client.handleLine(":hub.net 364 TestBot hub.net hub.net :0 Hub server")
+	client.handleLine(":hub.net 365 TestBot * :End of /LINKS list.")
+	if !links.Complete || len(links.Data) != 2 {
+		t.Fatalf("Expected 2 completed links, got %+v", links)
+	}
+	if links.Data[0]["server"] != "hub.net" || links.Data[0]["mask"] != "leaf.net" || links.Data[0]["hopcount"] != "1" || links.Data[0]["info"] != "Leaf server" {
+		t.Errorf("Unexpected link entry: %v", links.Data[0])
+	}
+
+	m := client.createPendingRequest("map", "")
+	client.handleLine(":hub.net 015 TestBot :hub.net (40) [66.7%]")
+	client.handleLine(":hub.net 015 TestBot :  `- leaf.net (20) [33.3%]")
+	client.handleLine(":hub.net 017 TestBot :End of /MAP")
+	if !m.Complete || len(m.Data) != 2 {
+		t.Fatalf("Expected 2 completed map entries, got %+v", m)
+	}
+	if m.Data[1]["server"] != "leaf.net" || m.Data[1]["users"] != "20" || m.Data[1]["depth"] != "2" {
+		t.Errorf("Unexpected map entry: %v", m.Data[1])
+	}
+}
+
+func TestOperEndpointsGating(t *testing.T) {
+	oldUser, oldPass := os.Getenv("OPER_USER"), os.Getenv("OPER_PASS")
+	defer func() {
+		os.Setenv("OPER_USER", oldUser)
+		os.Setenv("OPER_PASS", oldPass)
+	}()
+
+	post := func(client *Client, path, body string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		client.CreateAPI("token").ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	os.Unsetenv("OPER_USER")
+	os.Unsetenv("OPER_PASS")
+	client := NewClient()
+	if code := post(client, "/api/oper/kill", `{"nick":"bad"}`); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without oper block, got %d", code)
+	}
+
+	os.Setenv("OPER_USER", "hanna")
+	os.Setenv("OPER_PASS", "secret")
+	client = NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	markConnected(client)
+
+	if code := post(client, "/api/oper/kill", `{"nick":"bad"}`); code != http.StatusConflict {
+		t.Errorf("Expected 409 before opering up, got %d", code)
+	}
+
+	client.isOper.Store(true)
+	if code := post(client, "/api/oper/kill", `{"nick":"bad","reason":"spam"}`); code != http.StatusOK {
+		t.Errorf("Expected 200 for kill, got %d", code)
+	}
+	if code := post(client, "/api/oper/kline", `{"mask":"*@bad.host","duration":60,"reason":"spam"}`); code != http.StatusOK {
+		t.Errorf("Expected 200 for kline, got %d", code)
+	}
+	if code := post(client, "/api/oper/gline", `{"mask":"*@bad.host"}`); code != http.StatusOK {
+		t.Errorf("Expected 200 for gline, got %d", code)
+	}
+	want := []string{"KILL bad :spam", "KLINE 60 *@bad.host :spam", "GLINE *@bad.host 0m :Banned"}
+	if strings.Join(sent, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %v, got %v", want, sent)
+	}
+}
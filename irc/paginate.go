@@ -0,0 +1,75 @@
+package irc
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// maxPageLimit bounds the limit of one page
+const maxPageLimit = 1000
+
+// page is the limit/offset window of a collection endpoint:
+// ?limit=50&offset=100. Without a limit the rest of the collection is
+// returned, as before collections were paged.
+type page struct {
+	Offset int
+	Limit  int // 0 for no limit
+}
+
+// parsePage reads the limit and offset query parameters
+func parsePage(r *http.Request) (page, error) {
+	var p page
+	q := r.URL.Query()
+	for name, dst := range map[string]*int{"limit": &p.Limit, "offset": &p.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return p, errors.New(name + " must be a non-negative integer")
+			}
+			*dst = n
+		}
+	}
+	if p.Limit > maxPageLimit {
+		p.Limit = maxPageLimit
+	}
+	return p, nil
+}
+
+// paginate returns the items of the page
+func paginate[T any](items []T, p page) []T {
+	if p.Offset >= len(items) {
+		return items[:0]
+	}
+	items = items[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items
+}
+
+// fields returns the page's part of a collection response: count is the
+// number of items on the page, total the number matching the filters, and
+// next_offset, when there are more, where the next page starts
+func (p page) fields(count, total int) map[string]any {
+	out := map[string]any{"count": count, "total": total, "offset": p.Offset}
+	if p.Limit > 0 {
+		out["limit"] = p.Limit
+	}
+	if next := p.Offset + count; count > 0 && next < total {
+		out["next_offset"] = next
+	}
+	return out
+}
+
+// writePage writes a page of a collection under key, along with the page
+// fields and any extra fields
+func writePage[T any](w http.ResponseWriter, key string, items []T, p page, extra map[string]any) {
+	window := paginate(items, p)
+	out := p.fields(len(window), len(items))
+	out[key] = window
+	for k, v := range extra {
+		out[k] = v
+	}
+	writeJSON(w, 200, out)
+}
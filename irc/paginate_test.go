@@ -0,0 +1,79 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCollectionPaging(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	api := client.CreateAPI("token")
+	get := func(path string, out any) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if out != nil {
+			json.NewDecoder(rec.Body).Decode(out)
+		}
+		return rec.Code
+	}
+
+	for _, nick := range []string{"alice", "bob", "carol", "dave"} {
+		client.updateUserInfo(nick, func(u *UserInfo) { u.Account = nick + "_acct" })
+	}
+	client.AddUserToChannel("#dev", "Bob", "")
+	client.AddUserToChannel("#dev", "dave", "o")
+
+	var users struct {
+		Users      map[string]*UserInfo `json:"users"`
+		Count      int                  `json:"count"`
+		Total      int                  `json:"total"`
+		NextOffset int                  `json:"next_offset"`
+	}
+	get("/api/users?limit=2&offset=1", &users)
+	if users.Count != 2 || users.Total != 4 || users.NextOffset != 3 || users.Users["bob"] == nil || users.Users["carol"] == nil {
+		t.Errorf("Expected bob and carol with more to come, got %+v", users)
+	}
+	users.NextOffset = 0
+	get("/api/users?limit=2&offset=3", &users)
+	if users.Count != 1 || users.NextOffset != 0 || users.Users["dave"] == nil {
+		t.Errorf("Expected the last page with dave, got %+v", users)
+	}
+	get("/api/users?channel=%23DEV", &users)
+	if users.Total != 2 || users.Users["bob"] == nil || users.Users["dave"] == nil {
+		t.Errorf("Expected the users of #dev, got %+v", users)
+	}
+	get("/api/users?account=CAROL_acct", &users)
+	if users.Total != 1 || users.Users["carol"] == nil {
+		t.Errorf("Expected carol by account, got %+v", users)
+	}
+	get("/api/users?nick=*a*e*", &users)
+	if users.Total != 2 || users.Users["alice"] == nil || users.Users["dave"] == nil {
+		t.Errorf("Expected the nicks matching the mask, got %+v", users)
+	}
+
+	client.addError("401", "#dev", "No such nick")
+	client.addError("404", "#dev", "Cannot send")
+	client.addError("401", "#ops", "No such nick")
+	var errs struct {
+		Errors []IRCError `json:"errors"`
+		Total  int        `json:"total"`
+	}
+	get("/api/errors?code=401&target=%23OPS", &errs)
+	if errs.Total != 1 || len(errs.Errors) != 1 || errs.Errors[0].Target != "#ops" {
+		t.Errorf("Expected the 401 for #ops, got %+v", errs)
+	}
+	get("/api/errors?offset=10", &errs)
+	if errs.Total != 3 || len(errs.Errors) != 0 {
+		t.Errorf("Expected an empty page past the end, got %+v", errs)
+	}
+
+	for _, path := range []string{"/api/users?limit=x", "/api/errors?offset=-1", "/api/stats?limit=-5", "/api/pm?offset=y"} {
+		if code := get(path, nil); code != 400 {
+			t.Errorf("%s: expected 400, got %d", path, code)
+		}
+	}
+}
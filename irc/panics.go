@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// panicRestartDelay is how long a supervised goroutine waits before it is
+// restarted after a panic, so a panic on every run does not spin
+const panicRestartDelay = time.Second
+
+// recordPanic logs a recovered panic with its stack, adds it to the recent
+// errors and counts it for /metrics; where names the handler or goroutine
+func (c *Client) recordPanic(where string, v any) {
+	log.Printf("PANIC in %s: %v\n%s", where, v, debug.Stack())
+	c.addError("panic", where, fmt.Sprint(v))
+	c.panicsMu.Lock()
+	defer c.panicsMu.Unlock()
+	if c.panics == nil {
+		c.panics = make(map[string]int64)
+	}
+	c.panics[where]++
+}
+
+// safely runs fn and recovers a panic from it, reporting whether it panicked
+func (c *Client) safely(where string, fn func()) (panicked bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			c.recordPanic(where, v)
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// supervise runs fn in a new goroutine and restarts it after a panic, until
+// it returns normally or the client is closed
+func (c *Client) supervise(where string, fn func()) {
+	go func() {
+		for c.safely(where, fn) {
+			select {
+			case <-c.context().Done():
+				return
+			case <-time.After(panicRestartDelay):
+			}
+			log.Printf("Restarting %s after a panic", where)
+		}
+	}()
+}
+
+// PanicCount is how many panics were recovered in one place
+type PanicCount struct {
+	Where string `json:"where"`
+	Count int64  `json:"count"`
+}
+
+// Panics returns the recovered panics since startup, sorted by place
+func (c *Client) Panics() []PanicCount {
+	c.panicsMu.Lock()
+	defer c.panicsMu.Unlock()
+	out := make([]PanicCount, 0, len(c.panics))
+	for where, n := range c.panics {
+		out = append(out, PanicCount{where, n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Where < out[j].Where })
+	return out
+}
+
+// withRecover answers 500 instead of dropping the connection when an API
+// handler panics
+func (a *API) withRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			a.bot.recordPanic("api", fmt.Sprintf("%s %s: %v", r.Method, r.URL.Path, v))
+			writeError(w, 500, codeInternal, "internal error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
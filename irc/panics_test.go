@@ -0,0 +1,81 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupervisedRestart(t *testing.T) {
+	client := NewClient()
+	runs := make(chan int, 2)
+	n := 0
+	client.supervise("test loop", func() {
+		n++
+		runs <- n
+		if n == 1 {
+			panic("boom")
+		}
+	})
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-runs:
+			if got != want {
+				t.Fatalf("Expected run %d, got %d", want, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for run %d", want)
+		}
+	}
+	if p := client.Panics(); len(p) != 1 || p[0] != (PanicCount{"test loop", 1}) {
+		t.Errorf("Expected one recorded panic, got %+v", p)
+	}
+	if errs := client.getRecentErrors(); len(errs) != 1 || errs[0].Code != "panic" || errs[0].Message != "boom" {
+		t.Errorf("Expected the panic in the recent errors, got %+v", errs)
+	}
+}
+
+func TestReadLoopSurvivesPanic(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	texts := make(chan string, 2)
+	client.bus.Subscribe("test", func(e Event) {
+		if e.Text == "boom" {
+			panic("handler bug")
+		}
+		texts <- e.Text
+	})
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	go client.readLoop(client.attach(context.Background(), conn))
+
+	fmt.Fprint(peer, ":alice!a@host PRIVMSG #test :boom\r\n:alice!a@host PRIVMSG #test :after\r\n")
+	select {
+	case text := <-texts:
+		if text != "after" {
+			t.Errorf("Expected the next line to be handled, got %q", text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The read loop stopped after a panic")
+	}
+}
+
+func TestAPIRecover(t *testing.T) {
+	client := NewClient()
+	api := &API{bot: client}
+	h := api.withRecover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("handler bug") }))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/state", nil))
+	if rec.Code != 500 || !strings.Contains(rec.Body.String(), codeInternal) {
+		t.Errorf("Expected a 500 internal error, got %d %s", rec.Code, rec.Body.String())
+	}
+	if p := client.Panics(); len(p) != 1 || p[0].Where != "api" {
+		t.Errorf("Expected the panic to be counted, got %+v", p)
+	}
+}
@@ -0,0 +1,1271 @@
+package irc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Characters that should be ignored when surrounding the bot nick
+var ignoreChars = []string{"/"}
+
+// Helper function to check if a nick mention should be ignored based on surrounding characters
+func shouldIgnoreNickMention(message, quotedNick string) bool {
+	for _, char := range ignoreChars {
+		// Check for patterns like /nick/ (both sides)
+		bothSidesPattern := char + quotedNick + char
+		if matched, _ := regexp.MatchString("(?i)"+regexp.QuoteMeta(bothSidesPattern), message); matched {
+			return true
+		}
+
+		// Check for patterns like /nick (left side only)
+		if matched, _ := regexp.MatchString("(?i)"+regexp.QuoteMeta(char)+quotedNick+`\b`, message); matched {
+			return true
+		}
+
+		// Check for patterns like nick/ (right side only)
+		if matched, _ := regexp.MatchString("(?i)"+`\b`+quotedNick+regexp.QuoteMeta(char), message); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func sanitizeNick(nick string) string {
+	if nick == "" {
+		return "Hanna"
+	}
+
+	// Valid IRC nick characters: a-z, A-Z, 0-9, {, }, [, ], _, -, `
+	var sanitized strings.Builder
+	for _, r := range nick {
+		if (r >= 'a' && r <= 'z') ||
+			(r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9') ||
+			r == '{' || r == '}' ||
+			r == '[' || r == ']' ||
+			r == '_' || r == '-' || r == '`' {
+			sanitized.WriteRune(r)
+		}
+	}
+
+	result := sanitized.String()
+	if result == "" {
+		return "Hanna"
+	}
+
+	// Truncate to maximum 63 characters as per IRC spec
+	if len(result) > 63 {
+		result = result[:63]
+	}
+
+	return result
+}
+
+func (c *Client) handleLine(line string) {
+	// Everything downstream (history, JSON payloads) expects valid UTF-8
+	line = c.decodeIncoming(line)
+
+	// Parse message tags if present
+	var tags map[string]string
+	rest := line
+
+	if strings.HasPrefix(rest, "@") {
+		// Find the end of the tags section
+		if i := strings.Index(rest, " "); i != -1 {
+			tagSection := rest[1:i] // Remove the @ prefix
+			rest = strings.TrimSpace(rest[i+1:])
+
+			// Parse individual tags
+			tags = make(map[string]string)
+			if tagSection != "" {
+				tagPairs := strings.Split(tagSection, ";")
+				for _, pair := range tagPairs {
+					if strings.Contains(pair, "=") {
+						parts := strings.SplitN(pair, "=", 2)
+						key := parts[0]
+						value := parts[1]
+						// Unescape tag values according to IRC v3.2 spec
+						value = strings.ReplaceAll(value, "\\:", ";")
+						value = strings.ReplaceAll(value, "\\s", " ")
+						value = strings.ReplaceAll(value, "\\\\", "\\")
+						value = strings.ReplaceAll(value, "\\r", "\r")
+						value = strings.ReplaceAll(value, "\\n", "\n")
+						tags[key] = value
+					} else {
+						// Tag without value
+						tags[pair] = ""
+					}
+				}
+			}
+
+			if len(tags) > 0 {
+				log.Printf("Parsed message tags: %v", tags)
+			}
+		}
+	}
+
+	prefix := ""
+	if strings.HasPrefix(rest, ":") {
+		if i := strings.Index(rest, " "); i != -1 {
+			prefix = rest[1:i]
+			rest = strings.TrimSpace(rest[i+1:])
+		}
+	}
+	parts := strings.Split(rest, " ")
+	if len(parts) == 0 {
+		return
+	}
+	cmd := strings.ToUpper(parts[0])
+
+	argEnd := strings.Index(rest, " :")
+	var args []string
+	var trailing string
+	if argEnd != -1 {
+		argsStart := len(cmd) + 1
+		if argsStart < argEnd {
+			args = strings.Fields(rest[argsStart:argEnd])
+		}
+		trailing = rest[argEnd+2:]
+	} else {
+		if len(rest) > len(cmd) {
+			args = strings.Fields(rest[len(cmd)+1:])
+		}
+	}
+
+	// Answers to the bot's labeled replies; an ACK says nothing more
+	if label, ok := tags["label"]; ok && c.answerLabel(label, cmd, args, trailing) && cmd == "ACK" {
+		return
+	}
+
+	// Feed numeric replies to pending requests once state tracking is done
+	defer c.routeReply(cmd, args, trailing)
+
+	// Attached bouncer clients see the server traffic
+	c.relayUpstream(line, cmd)
+
+	switch cmd {
+	case "PING":
+		if trailing == "" && len(args) > 0 {
+			trailing = args[len(args)-1]
+		}
+		c.rawf("PONG :%s", trailing)
+	case "PONG":
+		c.handleLagPong(trailing)
+	case "ERROR":
+		// :server ERROR :Closing Link: ... sent right before the server disconnects
+		log.Printf("Server error: %s", trailing)
+		c.noteServerError(trailing)
+	case "001": // welcome
+		log.Printf("IRC registration successful! Welcome message received")
+		c.transition(StateConnected, "")
+		c.resetUserModes()
+		c.resetBatches()
+		c.isOper.Store(false)
+		if c.onReady != nil {
+			c.onReady()
+		}
+		// Per-connection loops; they are restarted if they panic
+		done := c.connDone()
+		// Periodic state resync for the lifetime of this connection
+		if c.resyncInterval > 0 && done != nil {
+			c.supervise("resync", func() { c.resyncLoop(done) })
+		}
+		// Away does not survive a reconnect
+		c.restoreAway()
+		if c.autoAwayAfter > 0 && done != nil {
+			c.supervise("auto away", func() { c.autoAwayLoop(done) })
+		}
+		// Lag to the server, reported by /api/state, /metrics and /readyz
+		c.lag.reset()
+		if done != nil {
+			c.supervise("lag check", func() { c.lagLoop(done) })
+		}
+		// Scheduled messages are only sent while connected
+		if done != nil {
+			c.supervise("schedules", func() { c.scheduleLoop(done) })
+			c.supervise("seen save", func() { c.seenSaveLoop(done) })
+			c.supervise("state snapshot", func() { c.snapshotLoop(done) })
+		}
+		// Eviction of state about users and channels the bot no longer sees
+		if (c.gc.ttl > 0 || c.gc.maxUsers > 0) && done != nil {
+			c.supervise("state gc", func() { c.stateGCLoop(done) })
+		}
+		// Oper up if an oper block is configured
+		c.operLogin()
+		// Bot mode is set once ISUPPORT advertises it, see setBotMode
+		c.botModeSent.Store(false)
+		// Autojoin, paced once ISUPPORT is known
+		c.startAutojoin(done)
+		// Take IRC_NICK back if registration ended up with a fallback
+		if c.nickCollision.reclaim > 0 && done != nil {
+			c.supervise("nick reclaim", func() { c.nickReclaimLoop(done) })
+		}
+	case "433": // nick in use
+		// choose a fallback nick by NICK_COLLISION while registering
+		nick := c.Nick()
+		if len(args) >= 2 {
+			nick = args[1]
+		}
+		c.addError(cmd, nick, trailing) // Add error tracking
+		c.nickInUse(nick, trailing)
+	case "CAP":
+		// server capability negotiation
+		// Expect: :server CAP * ACK :sasl or :server CAP * ACK sasl
+		log.Printf("CAP response: %s %s", strings.Join(args, " "), trailing)
+		if len(args) >= 2 && strings.ToUpper(args[1]) == "NAK" {
+			log.Printf("Server refused capabilities: %s", trailing)
+			if c.capPending.Add(-1) <= 0 && !c.saslInProgress.Load() {
+				log.Printf("Ending capability negotiation")
+				c.raw("CAP END")
+			}
+		}
+		if len(args) >= 2 && strings.ToUpper(args[1]) == "ACK" {
+			capList := trailing
+			if capList == "" && len(args) > 2 {
+				capList = strings.Join(args[2:], " ")
+			}
+			log.Printf("Server acknowledged capabilities: %s", capList)
+
+			if strings.Contains(strings.ToLower(capList), "message-tags") {
+				log.Printf("Message-tags capability enabled")
+				c.messageTags.Store(true)
+			}
+			if strings.Contains(strings.ToLower(capList), "setname") {
+				c.setname.Store(true)
+			}
+			if strings.Contains(strings.ToLower(capList), "labeled-response") {
+				c.labeledResponse.Store(true)
+			}
+
+			pending := c.capPending.Add(-1)
+			if strings.Contains(strings.ToLower(capList), "sasl") {
+				log.Printf("SASL capability acknowledged, starting authentication")
+				c.raw("AUTHENTICATE PLAIN")
+			} else if pending <= 0 && !c.saslInProgress.Load() {
+				// No SASL requested, end CAP negotiation
+				log.Printf("Ending capability negotiation")
+				c.raw("CAP END")
+			}
+		}
+	case "BATCH":
+		// :server BATCH +ref type params... / :server BATCH -ref
+		c.handleBatch(args, tags)
+	case "AUTHENTICATE":
+		// Expect a '+' from server to send payload
+		if args[0] == "+" {
+			payload := fmt.Sprintf("\x00%s\x00%s", c.saslUser, c.saslPass)
+			enc := base64.StdEncoding.EncodeToString([]byte(payload))
+			log.Printf("Sending SASL PLAIN credentials")
+			c.rawf("AUTHENTICATE %s", enc)
+		}
+	case "903": // SASL success
+		log.Printf("SASL authentication successful")
+		// Track this in user info for our own nick
+		c.updateUserInfo(c.Nick(), func(info *UserInfo) {
+			if info.SpecialInfo == nil {
+				info.SpecialInfo = make(map[string]string)
+			}
+			info.SpecialInfo["sasl_authenticated"] = "true"
+		})
+		c.raw("CAP END")
+		if c.saslInProgress.Load() {
+			c.saslInProgress.Store(false)
+			select {
+			case c.saslComplete <- true:
+			default:
+			}
+		}
+	case "904", "905": // SASL fail/abort
+		log.Printf("SASL authentication failed (code %s)", cmd)
+		c.addError(cmd, "", trailing) // Add error tracking
+		c.raw("CAP END")
+		if c.saslInProgress.Load() {
+			c.saslInProgress.Store(false)
+			select {
+			case c.saslComplete <- false:
+			default:
+			}
+		}
+	case "KICK":
+		// :op KICK #chan nick :reason
+		if len(args) >= 2 {
+			ch, kickedNick := args[0], args[1]
+			kicker := strings.Split(prefix, "!")[0]
+			c.bus.Publish(Event{
+				Type: "kick", Prefix: prefix, Sender: kicker, Target: ch, Nick: kickedNick,
+				Args: args, Text: trailing, Tags: tags,
+				Message: fmt.Sprintf("%s kicked %s: %s", kicker, kickedNick, trailing),
+				Self:    strings.EqualFold(kickedNick, c.Nick()),
+			})
+		}
+	case "MODE":
+		// :nick!user@host MODE target modestring [params...]
+		if len(args) >= 1 {
+			setter := strings.Split(prefix, "!")[0]
+			message := ""
+			if len(args) >= 2 {
+				message = fmt.Sprintf("Mode %s %s %s", args[0], args[1], strings.Join(args[2:], " "))
+				log.Printf("Mode change by %s: %s", setter, message)
+			}
+			c.bus.Publish(Event{
+				Type: "mode", Prefix: prefix, Sender: setter, Target: args[0],
+				Args: args, Text: trailing, Message: message, Tags: tags,
+				Self: strings.EqualFold(args[0], c.Nick()),
+			})
+		}
+	case "TOPIC":
+		// :nick!user@host TOPIC #channel :new topic
+		if len(args) >= 1 {
+			setter := strings.Split(prefix, "!")[0]
+			channel := args[0]
+			message := fmt.Sprintf("Topic for %s set by %s: %s", channel, setter, trailing)
+			log.Printf("Topic change: %s", message)
+			c.bus.Publish(Event{
+				Type: "topic", Prefix: prefix, Sender: setter, Target: channel,
+				Args: args, Text: trailing, Message: message, Tags: tags,
+			})
+		}
+	case "NOTICE":
+		// :sender!user@host NOTICE target :message
+		if len(args) >= 1 && trailing != "" {
+			sender := strings.Split(prefix, "!")[0]
+			target := args[0]
+			message := trailing
+			c.markBot(prefix, tags)
+
+			log.Printf("NOTICE from %s to %s: %s", sender, target, message)
+			c.bus.Publish(Event{
+				Type: "notice", Prefix: prefix, Sender: sender, Target: target,
+				Args: args, Text: message, Message: message, Tags: tags,
+			})
+
+			// Notices sent to us by a server (not a user) are server notices
+			if isServerPrefix(prefix) && strings.EqualFold(target, c.Nick()) {
+				kind := "snotice"
+				if isGlobops(message) {
+					kind = "globops"
+				}
+				c.addServerNotice(kind, prefix, message, tags)
+			}
+		}
+	case "WALLOPS", "GLOBOPS":
+		// :source WALLOPS :message
+		source := strings.Split(prefix, "!")[0]
+		kind := "wallops"
+		if cmd == "GLOBOPS" || isGlobops(trailing) {
+			kind = "globops"
+		}
+		c.addServerNotice(kind, source, trailing, tags)
+	case "NICK":
+		// :oldnick!u@h NICK :newnick
+		oldNick := strings.Split(prefix, "!")[0]
+		newNick := trailing
+		if newNick != "" && oldNick != "" {
+			c.bus.Publish(Event{
+				Type: "nick", Prefix: prefix, Sender: oldNick, Nick: newNick,
+				Args: args, Channels: c.userChannels(oldNick), Tags: tags,
+				Self: strings.EqualFold(oldNick, c.Nick()),
+			})
+			c.moveAccount(oldNick, newNick)
+		}
+	case "SETNAME":
+		// :nick!user@host SETNAME :new realname
+		c.handleSetname(prefix, trailing)
+	case "FAIL":
+		// :server FAIL SETNAME INVALID_REALNAME :Realname is not valid
+		c.handleFail(args, trailing)
+	case "TAGMSG":
+		// @+typing=active :sender!user@host TAGMSG target
+		if len(args) >= 1 {
+			c.handleTagmsg(prefix, args[0], args, tags)
+		}
+	case "PRIVMSG":
+		// :sender!user@host PRIVMSG target :message
+		debugf("PRIVMSG Recv: %s", trailing)
+		if len(tags) > 0 {
+			debugf("Message tags: %v", tags)
+		}
+		c.markBot(prefix, tags)
+		if len(args) >= 1 && trailing != "" {
+			sender := strings.Split(prefix, "!")[0]
+			target := args[0]
+			message := trailing
+			// A mention keeps the ID of the message it came from
+			e := Event{
+				ID: newRequestID(), Type: "privmsg", Prefix: prefix, Sender: sender, Target: target,
+				Args: args, Text: message, Message: message, Tags: tags,
+			}
+			// /me is an action event carrying just its text
+			if action, ok := ctcpAction(message); ok {
+				e.Type, e.Text, e.Message = "action", action, action
+			}
+
+			// Replayed history is counted into its batch, never acted upon
+			if c.batchKind(tags) == "chathistory" {
+				e.Replayed = true
+				c.bus.Publish(e)
+				return
+			}
+
+			// Messages from flooding or repeating users are logged but dropped
+			e.Dropped = c.checkSpam(prefix, target, message, tags)
+			// Private messages belong to a conversation, CTCP requests aside
+			if command, _, ok := parseCTCP(message); c.isDM(target) && !e.Dropped && (!ok || command == "ACTION") {
+				e.Session = c.dmReceived(sender, tags)
+			}
+			c.bus.Publish(e)
+			if e.Dropped {
+				return
+			}
+
+			// CTCP requests are answered here, never treated as commands or mentions
+			if c.handleCTCP(sender, target, message) {
+				return
+			}
+			// In-channel commands are not treated as mentions
+			if c.dispatchCommand(prefix, target, message, tags) {
+				return
+			}
+			// Private messages get their own event, and by DM_MENTIONS are
+			// all mentions, none, or like channel messages
+			if e.Session != "" {
+				e.Type = "pm"
+				c.bus.Publish(e)
+				switch c.dm.mentions {
+				case "off":
+					return
+				case "all":
+					log.Printf("Private message from %s [%s] treated as a mention: %s", sender, e.ID, message)
+					e.Type = "mention"
+					c.bus.Publish(e)
+					return
+				}
+			}
+			// Channel profiles can make every message a mention, or none
+			switch c.channelProfile(target).Mentions {
+			case "off":
+				return
+			case "all":
+				log.Printf("Message in %s by %s [%s] treated as a mention: %s", target, sender, e.ID, message)
+				e.Type = "mention"
+				c.bus.Publish(e)
+				return
+			}
+			// Ignore when surrounded by specific characters like '/'
+			botNick := c.Nick()
+
+			// Create regex pattern that matches bot nick with word boundaries
+			quotedNick := regexp.QuoteMeta(strings.ToLower(botNick))
+			pattern := `\b` + quotedNick + `\b`
+			regex, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				log.Printf("Error compiling regex for nick matching: %v", err)
+				return
+			}
+
+			// First check if the nick matches as a word
+			if regex.MatchString(message) {
+				// Additional check: reject if surrounded by ignore characters
+				if shouldIgnoreNickMention(message, quotedNick) {
+					// Skip this match - it's surrounded by ignore characters
+					return
+				}
+
+				// This is a valid mention
+				log.Printf("Nick mentioned in %s by %s [%s]: %s", target, sender, e.ID, message)
+
+				// Publish the mention for triggers
+				e.Type = "mention"
+				c.bus.Publish(e)
+			}
+		}
+	case "JOIN":
+		// :nick!user@host JOIN :#chan, or with extended-join
+		// :nick!user@host JOIN #chan account :Real Name
+		sender := strings.Split(prefix, "!")[0]
+		ch := trailing
+		if len(args) > 0 {
+			ch = args[0]
+		}
+		if len(args) > 1 {
+			account, realName := args[1], trailing
+			c.updateUserInfo(sender, func(info *UserInfo) {
+				info.Account = strings.TrimPrefix(account, "*")
+				info.RealName = realName
+				if _, userhost, ok := strings.Cut(prefix, "!"); ok {
+					info.User, info.Host, _ = strings.Cut(userhost, "@")
+				}
+			})
+		}
+		if ch != "" {
+			e := Event{
+				Type: "join", Prefix: prefix, Sender: sender, Target: ch, Args: args, Tags: tags,
+				Self: strings.EqualFold(sender, c.Nick()),
+			}
+			if !e.Self {
+				e.Rejoin = c.rejoinFromSplit(sender)
+			}
+			c.bus.Publish(e)
+		}
+	case "ACCOUNT":
+		// account-notify: :nick!user@host ACCOUNT account, or * when logged out
+		if len(args) > 0 {
+			account := strings.TrimPrefix(args[0], "*")
+			c.updateUserInfo(strings.Split(prefix, "!")[0], func(info *UserInfo) { info.Account = account })
+		}
+	case "PART":
+		sender := strings.Split(prefix, "!")[0]
+		if len(args) > 0 {
+			c.bus.Publish(Event{
+				Type: "part", Prefix: prefix, Sender: sender, Target: args[0],
+				Args: args, Text: trailing, Message: trailing, Tags: tags,
+				Self: strings.EqualFold(sender, c.Nick()),
+			})
+		}
+	case "QUIT":
+		// :nick!user@host QUIT :reason
+		sender := strings.Split(prefix, "!")[0]
+		e := Event{
+			Type: "quit", Prefix: prefix, Sender: sender, Args: args,
+			Text: trailing, Message: trailing, Channels: c.userChannels(sender), Tags: tags,
+		}
+		if servers, ok := parseNetsplitReason(trailing); ok {
+			e.Netsplit = servers
+		}
+		c.bus.Publish(e)
+		c.moveAccount(sender, "")
+	case "353": // RPL_NAMREPLY
+		// :server 353 nick = #channel :nick1 @nick2 +nick3
+		if len(args) >= 3 && trailing != "" {
+			channel := args[2]
+			names := strings.Fields(trailing)
+
+			log.Printf("NAMES reply for %s: %s", channel, trailing)
+
+			req := c.findPendingRequestByTarget("names", channel)
+			prefixes := c.prefixMap()
+			for _, name := range names {
+				nick, modes := prefixes.parseNamesEntry(name)
+				if nick != "" {
+					c.AddUserToChannel(channel, nick, modes)
+					c.recordNames(channel, nick)
+					if req != nil {
+						req.Data = append(req.Data, map[string]string{
+							"nick":   nick,
+							"modes":  modes,
+							"prefix": name[:len(modes)],
+						})
+					}
+				}
+			}
+		}
+	case "366": // RPL_ENDOFNAMES
+		// :server 366 nick #channel :End of NAMES list
+		if len(args) >= 2 {
+			channel := args[1]
+			log.Printf("End of NAMES list for %s", channel)
+			c.finishNames(channel)
+			c.recordChange(StateChange{Type: "channel_synced", Channel: channel})
+		}
+	// RFC1459 and Extended IRC Numerics - Comprehensive State Tracking
+	case "002": // RPL_YOURHOST
+		// :server 002 nick :Your host is servername, running version
+		c.updateServerInfo(func(info *ServerInfo) {
+			info.Name = extractServerName(prefix)
+			// Parse version from message if possible
+			if versionMatch := regexp.MustCompile(`running version (.+)$`).FindStringSubmatch(trailing); len(versionMatch) > 1 {
+				info.Version = versionMatch[1]
+			}
+		})
+	case "003": // RPL_CREATED
+		// :server 003 nick :This server was created date
+		c.updateServerInfo(func(info *ServerInfo) {
+			if createdMatch := regexp.MustCompile(`created (.+)$`).FindStringSubmatch(trailing); len(createdMatch) > 1 {
+				info.Created = createdMatch[1]
+			}
+		})
+	case "004": // RPL_MYINFO
+		// :server 004 nick servername version usermodes chanmodes [chanmodes_with_param]
+		if len(args) >= 4 {
+			c.updateServerInfo(func(info *ServerInfo) {
+				info.Name = args[1]
+				info.Version = args[2]
+				info.UserModes = args[3]
+				if len(args) > 4 {
+					info.ChannelModes = args[4]
+				}
+			})
+		}
+	case "005": // RPL_ISUPPORT
+		// :server 005 nick TOKEN1=value TOKEN2 :are supported by this server
+		if len(args) >= 2 {
+			c.updateServerInfo(func(info *ServerInfo) {
+				// Parse all args except the first (nick) and last (usually a description)
+				for i := 1; i < len(args); i++ {
+					token := args[i]
+					if strings.Contains(token, "=") {
+						parts := strings.SplitN(token, "=", 2)
+						info.ISupportTags[parts[0]] = parts[1]
+					} else {
+						info.ISupportTags[token] = ""
+					}
+				}
+			})
+			c.setBotMode()
+		}
+	case "251": // RPL_LUSERCLIENT
+		// :server 251 nick :There are <int> users and <int> invisible on <int> servers
+		if matches := regexp.MustCompile(`(\d+) users and (\d+) invisible on (\d+) servers`).FindStringSubmatch(trailing); len(matches) > 3 {
+			if totalUsers, err := strconv.Atoi(matches[1]); err == nil {
+				if invisible, err := strconv.Atoi(matches[2]); err == nil {
+					c.updateServerInfo(func(info *ServerInfo) {
+						info.GlobalUsers = totalUsers + invisible
+					})
+				}
+			}
+		}
+	case "252": // RPL_LUSEROP
+		// :server 252 nick <int> :operator(s) online
+		if len(args) >= 2 {
+			if ops, err := strconv.Atoi(args[1]); err == nil {
+				c.updateServerInfo(func(info *ServerInfo) {
+					info.Operators = ops
+				})
+			}
+		}
+	case "253": // RPL_LUSERUNKNOWN
+		// :server 253 nick <int> :unknown connection(s)
+		if len(args) >= 2 {
+			if unknown, err := strconv.Atoi(args[1]); err == nil {
+				c.updateServerInfo(func(info *ServerInfo) {
+					info.UnknownConns = unknown
+				})
+			}
+		}
+	case "254": // RPL_LUSERCHANNELS
+		// :server 254 nick <int> :channels formed
+		if len(args) >= 2 {
+			if channels, err := strconv.Atoi(args[1]); err == nil {
+				c.updateServerInfo(func(info *ServerInfo) {
+					info.Channels = channels
+				})
+			}
+		}
+	case "255": // RPL_LUSERME
+		// :server 255 nick :I have <int> clients and <int> servers
+		if matches := regexp.MustCompile(`I have (\d+) clients and (\d+) servers`).FindStringSubmatch(trailing); len(matches) > 2 {
+			if clients, err := strconv.Atoi(matches[1]); err == nil {
+				c.updateServerInfo(func(info *ServerInfo) {
+					info.LocalUsers = clients
+				})
+			}
+		}
+	case "256": // RPL_ADMINME
+		// :server 256 nick server :Administrative info
+		if len(args) >= 2 {
+			c.updateServerInfo(func(info *ServerInfo) {
+				info.AdminInfo.Server = args[1]
+			})
+		}
+	case "257": // RPL_ADMINLOC1
+		// :server 257 nick :admin_location
+		c.updateServerInfo(func(info *ServerInfo) {
+			info.AdminInfo.Location1 = trailing
+		})
+	case "258": // RPL_ADMINLOC2
+		// :server 258 nick :admin_location
+		c.updateServerInfo(func(info *ServerInfo) {
+			info.AdminInfo.Location2 = trailing
+		})
+	case "259": // RPL_ADMINEMAIL
+		// :server 259 nick :email_address
+		c.updateServerInfo(func(info *ServerInfo) {
+			info.AdminInfo.Email = trailing
+		})
+	case "265": // RPL_LOCALUSERS
+		// :server 265 nick [<u> <m>] :Current local users <u>, max <m>
+		if len(args) >= 3 {
+			if current, err := strconv.Atoi(args[1]); err == nil {
+				if max, err := strconv.Atoi(args[2]); err == nil {
+					c.updateServerInfo(func(info *ServerInfo) {
+						info.LocalUsers = current
+						info.MaxLocalUsers = max
+					})
+				}
+			}
+		}
+	case "266": // RPL_GLOBALUSERS
+		// :server 266 nick [<u> <m>] :Current global users <u>, max <m>
+		if len(args) >= 3 {
+			if current, err := strconv.Atoi(args[1]); err == nil {
+				if max, err := strconv.Atoi(args[2]); err == nil {
+					c.updateServerInfo(func(info *ServerInfo) {
+						info.GlobalUsers = current
+						info.MaxGlobalUsers = max
+					})
+				}
+			}
+		}
+	case "221": // RPL_UMODEIS
+		// :server 221 nick +modes
+		if len(args) >= 2 || trailing != "" {
+			modeString := trailing
+			if len(args) >= 2 {
+				modeString = args[1]
+			}
+			c.resetUserModes()
+			c.applyUserModes(modeString)
+		}
+	case "381": // RPL_YOUREOPER
+		// :server 381 nick :You are now an IRC operator
+		log.Printf("Now an IRC operator")
+		c.isOper.Store(true)
+	case "276": // RPL_WHOISCERTFP
+		// :server 276 nick target :has client certificate fingerprint fingerprint
+		if len(args) >= 2 {
+			targetNick := args[1]
+			if fpMatch := regexp.MustCompile(`fingerprint (.+)$`).FindStringSubmatch(trailing); len(fpMatch) > 1 {
+				c.updateUserInfo(targetNick, func(info *UserInfo) {
+					info.CertFingerprint = fpMatch[1]
+				})
+			}
+		}
+	case "301": // RPL_AWAY
+		// :server 301 nick target :away_message
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.IsAway = true
+				info.AwayMessage = trailing
+			})
+		}
+	case "305": // RPL_UNAWAY
+		// :server 305 nick :info
+		c.updateUserInfo(c.Nick(), func(info *UserInfo) {
+			info.IsAway = false
+			info.AwayMessage = ""
+		})
+		c.setAwayConfirmed(false)
+	case "306": // RPL_NOWAWAY
+		// :server 306 nick :info
+		c.updateUserInfo(c.Nick(), func(info *UserInfo) {
+			info.IsAway = true
+		})
+		c.setAwayConfirmed(true)
+	case "307": // RPL_WHOISREGNICK / RPL_WHOISSERVICE
+		// :server 307 nick target :info
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["registered"] = "true"
+			})
+		}
+	case "308": // RPL_WHOISADMIN / RPL_RULESSTART
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["admin"] = "true"
+			})
+		}
+	case "309": // RPL_ENDOFRULES / RPL_WHOISSERVICE
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["service"] = "true"
+			})
+		}
+	case "310": // RPL_WHOISHELPOP / RPL_WHOISSERVICE
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["helpop"] = "true"
+			})
+		}
+	case "314": // RPL_WHOWASUSER
+		// :server 314 nick target user host * :real_name
+		if len(args) >= 5 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.User = args[2]
+				info.Host = args[3]
+				info.RealName = trailing
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["whowas"] = "true"
+			})
+		}
+	case "320": // RPL_WHOISSPECIAL / RPL_WHOIS_HIDDEN
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["special"] = trailing
+			})
+		}
+	case "324": // RPL_CHANNELMODEIS
+		// :server 324 nick channel mode mode_params
+		if len(args) >= 3 {
+			channel := strings.ToLower(args[1])
+			modes := args[2]
+			var params []string
+			if len(args) > 3 {
+				params = args[3:]
+			}
+
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.channelStates[channel].Modes = modes
+			c.channelStates[channel].ModeParams = params
+			c.channelStatesMu.Unlock()
+		}
+	case "325": // RPL_UNIQOPIS / RPL_CHANNELPASSIS / RPL_WHOISWEBIRC
+		if len(args) >= 3 && strings.HasPrefix(args[1], "#") {
+			// Channel related
+			channel := strings.ToLower(args[1])
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] != nil {
+				if c.channelStates[channel].SpecialInfo == nil {
+					c.channelStates[channel].SpecialInfo = make(map[string]string)
+				}
+				c.channelStates[channel].SpecialInfo["unique_op"] = args[2]
+			}
+			c.channelStatesMu.Unlock()
+		} else if len(args) >= 2 {
+			// User related (WHOISWEBIRC)
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["webirc"] = "true"
+			})
+		}
+	case "328": // RPL_CHANNEL_URL
+		// :server 328 nick channel :url
+		if len(args) >= 2 {
+			channel := strings.ToLower(args[1])
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.channelStates[channel].URL = trailing
+			c.channelStatesMu.Unlock()
+		}
+	case "329": // RPL_CREATIONTIME
+		// :server 329 nick channel timestamp
+		if len(args) >= 3 {
+			channel := strings.ToLower(args[1])
+			if timestamp, err := strconv.ParseInt(args[2], 10, 64); err == nil {
+				c.channelStatesMu.Lock()
+				if c.channelStates[channel] == nil {
+					c.channelStates[channel] = &ChannelState{
+						Name:        channel,
+						Users:       make(map[string]string),
+						BanList:     make([]BanListEntry, 0),
+						InviteList:  make([]InviteListEntry, 0),
+						ExceptList:  make([]ExceptListEntry, 0),
+						ModeParams:  make([]string, 0),
+						SpecialInfo: make(map[string]string),
+					}
+				}
+				c.channelStates[channel].CreatedTime = timestamp
+				c.channelStatesMu.Unlock()
+			}
+		}
+	case "330": // RPL_WHOISACCOUNT / RPL_WHOISLOGGEDIN
+		// :server 330 nick target authname :info
+		if len(args) >= 3 {
+			targetNick := args[1]
+			account := args[2]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.Account = account
+			})
+		}
+	case "331": // RPL_NOTOPIC
+		// :server 331 nick channel :info
+		if len(args) >= 2 {
+			channel := strings.ToLower(args[1])
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.channelStates[channel].Topic = ""
+			c.channelStatesMu.Unlock()
+		}
+	case "332": // RPL_TOPIC
+		// :server 332 nick channel :topic
+		if len(args) >= 2 {
+			channel := strings.ToLower(args[1])
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.channelStates[channel].Topic = trailing
+			c.channelStatesMu.Unlock()
+		}
+	case "333": // RPL_TOPICWHOTIME
+		// :server 333 nick channel nick!user@host timestamp
+		if len(args) >= 4 {
+			channel := strings.ToLower(args[1])
+			topicSetter := args[2]
+			if timestamp, err := strconv.ParseInt(args[3], 10, 64); err == nil {
+				c.channelStatesMu.Lock()
+				if c.channelStates[channel] == nil {
+					c.channelStates[channel] = &ChannelState{
+						Name:        channel,
+						Users:       make(map[string]string),
+						BanList:     make([]BanListEntry, 0),
+						InviteList:  make([]InviteListEntry, 0),
+						ExceptList:  make([]ExceptListEntry, 0),
+						ModeParams:  make([]string, 0),
+						SpecialInfo: make(map[string]string),
+					}
+				}
+				c.channelStates[channel].TopicSetBy = topicSetter
+				c.channelStates[channel].TopicSetTime = timestamp
+				c.channelStatesMu.Unlock()
+			}
+		}
+	case "335": // RPL_WHOISBOT
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.IsBot = true
+			})
+		}
+	case "338": // RPL_WHOISACTUALLY
+		// :server 338 nick target :is actually user@host ip
+		if len(args) >= 2 {
+			targetNick := args[1]
+			if actualMatch := regexp.MustCompile(`is actually (.+)`).FindStringSubmatch(trailing); len(actualMatch) > 1 {
+				c.updateUserInfo(targetNick, func(info *UserInfo) {
+					info.ActualHost = actualMatch[1]
+				})
+			}
+		}
+	case "344": // RPL_WHOISCOUNTRY
+		// :server 344 nick target countrycode :is connecting from country
+		if len(args) >= 3 {
+			targetNick := args[1]
+			countryCode := args[2]
+			if countryMatch := regexp.MustCompile(`from (.+)$`).FindStringSubmatch(trailing); len(countryMatch) > 1 {
+				c.updateUserInfo(targetNick, func(info *UserInfo) {
+					info.CountryCode = countryCode
+					info.Country = countryMatch[1]
+				})
+			}
+		}
+	case "346": // RPL_INVITELIST
+		// :server 346 nick channel invitemask [who set-ts]
+		if len(args) >= 3 {
+			channel := strings.ToLower(args[1])
+			mask := args[2]
+			entry := InviteListEntry{Mask: mask}
+
+			if len(args) > 3 {
+				entry.SetBy = args[3]
+			}
+			if len(args) > 4 {
+				entry.SetTime = parseIRCTime(args[4])
+			}
+
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.openModeList(c.channelStates[channel], 'I')
+			c.channelStates[channel].InviteList = append(c.channelStates[channel].InviteList, entry)
+			c.channelStatesMu.Unlock()
+		}
+	case "347": // RPL_ENDOFINVITELIST
+		if len(args) >= 2 {
+			log.Printf("End of invite list for %s", args[1])
+			c.closeModeList(args[1], 'I')
+		}
+	case "348": // RPL_EXCEPTLIST
+		// :server 348 nick channel exceptionmask [who set-ts]
+		if len(args) >= 3 {
+			channel := strings.ToLower(args[1])
+			mask := args[2]
+			entry := ExceptListEntry{Mask: mask}
+
+			if len(args) > 3 {
+				entry.SetBy = args[3]
+			}
+			if len(args) > 4 {
+				entry.SetTime = parseIRCTime(args[4])
+			}
+
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.openModeList(c.channelStates[channel], 'e')
+			c.channelStates[channel].ExceptList = append(c.channelStates[channel].ExceptList, entry)
+			c.channelStatesMu.Unlock()
+		}
+	case "349": // RPL_ENDOFEXCEPTLIST
+		if len(args) >= 2 {
+			log.Printf("End of exception list for %s", args[1])
+			c.closeModeList(args[1], 'e')
+		}
+	case "350": // RPL_WHOISGATEWAY
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.WebIRCGateway = trailing
+			})
+		}
+	case "367": // RPL_BANLIST
+		// :server 367 nick channel banid [setter time_left|time_left :reason]
+		if len(args) >= 3 {
+			channel := strings.ToLower(args[1])
+			mask := args[2]
+			entry := BanListEntry{Mask: mask}
+
+			if len(args) > 3 {
+				entry.SetBy = args[3]
+			}
+			if len(args) > 4 {
+				entry.SetTime = parseIRCTime(args[4])
+			}
+			if trailing != "" && trailing != args[2] {
+				entry.Reason = trailing
+			}
+
+			c.channelStatesMu.Lock()
+			if c.channelStates[channel] == nil {
+				c.channelStates[channel] = &ChannelState{
+					Name:        channel,
+					Users:       make(map[string]string),
+					BanList:     make([]BanListEntry, 0),
+					InviteList:  make([]InviteListEntry, 0),
+					ExceptList:  make([]ExceptListEntry, 0),
+					ModeParams:  make([]string, 0),
+					SpecialInfo: make(map[string]string),
+				}
+			}
+			c.openModeList(c.channelStates[channel], 'b')
+			c.channelStates[channel].BanList = append(c.channelStates[channel].BanList, entry)
+			c.channelStatesMu.Unlock()
+		}
+	case "368": // RPL_ENDOFBANLIST
+		if len(args) >= 2 {
+			log.Printf("End of ban list for %s", args[1])
+			c.closeModeList(args[1], 'b')
+		}
+	case "371": // RPL_INFO
+		// :server 371 nick :string
+		c.updateServerInfo(func(info *ServerInfo) {
+			info.MOTD = append(info.MOTD, trailing)
+		})
+	case "372": // RPL_MOTD
+		// :server 372 nick :- string
+		c.updateServerInfo(func(info *ServerInfo) {
+			// Remove leading "- " from MOTD lines
+			line := trailing
+			if strings.HasPrefix(line, "- ") {
+				line = line[2:]
+			}
+			info.MOTD = append(info.MOTD, line)
+		})
+	case "375": // RPL_MOTDSTART
+		// :server 375 nick :- server Message of the day -
+		c.updateServerInfo(func(info *ServerInfo) {
+			info.MOTD = []string{} // Clear existing MOTD
+		})
+	case "376": // RPL_ENDOFMOTD
+		log.Printf("End of MOTD")
+		c.endOfMOTD()
+	case "378": // RPL_WHOISHOST
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["host_info"] = trailing
+			})
+		}
+	case "379": // RPL_WHOISMODES
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["user_modes"] = trailing
+			})
+		}
+	case "569": // RPL_WHOISASN
+		// :server 569 nick target asn :is connecting from AS#### [Org Name]
+		if len(args) >= 3 {
+			targetNick := args[1]
+			asn := args[2]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.ASN = asn
+			})
+		}
+	case "671": // RPL_WHOISSECURE / RPL_WHOISSSL
+		// :server 671 nick target :is using a secure connection
+		if len(args) >= 2 {
+			targetNick := args[1]
+			c.updateUserInfo(targetNick, func(info *UserInfo) {
+				info.IsSecure = true
+			})
+		}
+	// Error numerics - track for debugging/monitoring
+	case "400", "401", "402", "403", "404", "405", "406", "407", "408", "409",
+		"410", "411", "412", "413", "414", "415", "416", "417", "421", "422",
+		"423", "424", "431", "432", "436", "437", "441", "442", "443",
+		"444", "445", "446", "451", "461", "462", "463", "464", "465", "466",
+		"467", "471", "472", "473", "474", "475", "476", "477", "478", "481",
+		"482", "483", "484", "485", "491", "492", "501", "502":
+		target := ""
+		if len(args) >= 2 {
+			target = args[1]
+		}
+		c.addError(cmd, target, trailing)
+		log.Printf("IRC Error %s: %s", cmd, trailing)
+		switch cmd {
+		case "422": // ERR_NOMOTD ends the MOTD as well
+			c.endOfMOTD()
+		case "403", "405", "471", "473", "474", "475", "476", "477":
+			c.autojoinError(target, trailing)
+		case "432", "437": // erroneous or temporarily unavailable nick
+			if target != "" && !isChannelName(target) {
+				c.nickInUse(target, trailing)
+			}
+		}
+	// SASL Authentication numerics
+	case "900": // RPL_LOGGEDIN
+		// :server 900 nick nick!ident@host account :You are now logged in as user
+		if len(args) >= 3 {
+			account := args[2]
+			c.updateUserInfo(c.Nick(), func(info *UserInfo) {
+				info.Account = account
+			})
+			log.Printf("SASL: Logged in as %s", account)
+		}
+	case "901": // RPL_LOGGEDOUT
+		// :server 901 nick nick!ident@host :You are now logged out
+		c.updateUserInfo(c.Nick(), func(info *UserInfo) {
+			info.Account = ""
+		})
+		log.Printf("SASL: Logged out")
+	case "902": // ERR_NICKLOCKED
+		c.addError(cmd, c.Nick(), trailing)
+		log.Printf("SASL: Nick locked - %s", trailing)
+	case "906": // ERR_SASLABORTED
+		log.Printf("SASL: Authentication aborted")
+	case "907": // ERR_SASLALREADY
+		log.Printf("SASL: Already authenticated")
+	case "908": // RPL_SASLMECHS
+		log.Printf("SASL: Available mechanisms - %s", trailing)
+	// Statistics numerics - track for monitoring
+	case "211", "212", "213", "214", "215", "216", "217", "218", "219",
+		"241", "242", "243", "244", "245", "246", "247", "248", "249", "250":
+		statData := make(map[string]string)
+		statData["numeric"] = cmd
+		statData["raw"] = strings.Join(args, " ") + " :" + trailing
+		for i, arg := range args {
+			statData[fmt.Sprintf("arg%d", i)] = arg
+		}
+		if trailing != "" {
+			statData["trailing"] = trailing
+		}
+		c.addStatEntry("stats_"+cmd, statData)
+	// Additional user information numerics
+	case "396": // RPL_VISIBLEHOST / RPL_YOURDISPLAYEDHOST / RPL_HOSTHIDDEN
+		// :server 396 nick hostname :is now your visible host
+		if len(args) >= 2 {
+			hostname := args[1]
+			c.updateUserInfo(c.Nick(), func(info *UserInfo) {
+				if info.SpecialInfo == nil {
+					info.SpecialInfo = make(map[string]string)
+				}
+				info.SpecialInfo["visible_host"] = hostname
+			})
+		}
+	// Default case for unhandled numerics - log for potential future implementation
+	default:
+		if len(cmd) == 3 && cmd[0] >= '0' && cmd[0] <= '9' {
+			log.Printf("Unhandled numeric %s: %s %s", cmd, strings.Join(args, " "), trailing)
+			// Store unknown numerics for analysis
+			statData := make(map[string]string)
+			statData["numeric"] = cmd
+			statData["args"] = strings.Join(args, " ")
+			statData["trailing"] = trailing
+			c.addStatEntry("unknown_numeric", statData)
+		}
+	}
+}
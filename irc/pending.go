@@ -0,0 +1,238 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PendingRequest represents a request waiting for IRC response
+type PendingRequest struct {
+	ID        string              `json:"id"`
+	Type      string              `json:"type"`             // a requestSpecs key such as "list" or "whois"
+	Target    string              `json:"target,omitempty"` // for whois, the nick being queried
+	Data      []map[string]string `json:"data"`
+	Complete  bool                `json:"complete"`
+	Error     string              `json:"error,omitempty"` // set when an error numeric or the timeout ended the request
+	StartTime time.Time           `json:"start_time"`
+	done      chan bool           // closed when the request completes or times out
+	finish    sync.Once
+	queued    bool   // waiting behind another untargeted request of its type
+	command   string // sent when a queued request reaches the front
+}
+
+// WhoisInfo represents collected WHOIS information
+type WhoisInfo struct {
+	Nick       string `json:"nick"`
+	User       string `json:"user,omitempty"`
+	Host       string `json:"host,omitempty"`
+	RealName   string `json:"real_name,omitempty"`
+	Server     string `json:"server,omitempty"`
+	ServerInfo string `json:"server_info,omitempty"`
+	Operator   bool   `json:"operator"`
+	Idle       string `json:"idle,omitempty"`
+	Channels   string `json:"channels,omitempty"`
+}
+
+// pendingRequestTTL is how long a request waits for its answer, and how long
+// an answered one is kept for a caller that has not collected it yet
+var pendingRequestTTL = 30 * time.Second
+
+// Helper functions for pending requests
+func (c *Client) createPendingRequest(reqType, target string) *PendingRequest {
+	req, _ := c.addPendingRequest(reqType, target, "")
+	return req
+}
+
+// addPendingRequest registers a request answered by command. Replies to
+// untargeted requests such as LIST do not say which request they answer, so
+// while one of the same type waits for its replies a new one is queued, and
+// sent when that one ends; send reports whether to send command now.
+func (c *Client) addPendingRequest(reqType, target, command string) (req *PendingRequest, send bool) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	req = &PendingRequest{
+		ID:        fmt.Sprintf("%s_%d", reqType, time.Now().UnixNano()),
+		Type:      reqType,
+		Target:    target,
+		Data:      make([]map[string]string, 0),
+		Complete:  false,
+		StartTime: time.Now(),
+		done:      make(chan bool, 1),
+	}
+
+	if spec := requestSpecs[reqType]; command != "" && spec != nil && spec.targetArg < 0 && c.inFlight(reqType) != nil {
+		req.queued, req.command = true, command
+		c.requestQueue[reqType] = append(c.requestQueue[reqType], req)
+	}
+	c.pending[req.ID] = req
+
+	// Cleanup old requests after 30 seconds
+	ttl := pendingRequestTTL
+	go func() {
+		select {
+		case <-req.done:
+			// Request completed normally; keep it briefly so a caller that
+			// has not started waiting yet can still collect the result
+			time.Sleep(ttl)
+		case <-time.After(ttl):
+			// Request timed out; what was collected is not a full answer
+			c.finishPendingRequest(req, errRequestTimeout.Error())
+		}
+		c.pendingMu.Lock()
+		delete(c.pending, req.ID)
+		c.pendingMu.Unlock()
+	}()
+
+	return req, !req.queued
+}
+
+func (c *Client) getPendingRequest(id string) *PendingRequest {
+	c.pendingMu.RLock()
+	defer c.pendingMu.RUnlock()
+	return c.pending[id]
+}
+
+func (c *Client) completePendingRequest(id string) {
+	if req := c.getPendingRequest(id); req != nil {
+		c.finishPendingRequest(req, "")
+	}
+}
+
+// finishPendingRequest marks a request complete, failed with errMsg unless
+// it is empty, and wakes its waiter. Only the first call counts; the fields
+// change under pendingMu since the find functions read them. The next
+// queued request of the type, if any, is sent.
+func (c *Client) finishPendingRequest(req *PendingRequest, errMsg string) {
+	var next *PendingRequest
+	c.pendingMu.Lock()
+	req.finish.Do(func() {
+		req.Complete = true
+		req.Error = errMsg
+		close(req.done)
+		next = c.advanceQueue(req)
+	})
+	c.pendingMu.Unlock()
+	if next != nil {
+		c.raw(next.command)
+	}
+}
+
+// advanceQueue takes a finished request out of its type's queue or, if it
+// was the one in flight, returns the next queued request, now in flight
+func (c *Client) advanceQueue(req *PendingRequest) *PendingRequest {
+	queue := c.requestQueue[req.Type]
+	if req.queued {
+		c.requestQueue[req.Type] = slices.DeleteFunc(queue, func(r *PendingRequest) bool { return r == req })
+		return nil
+	}
+	if len(queue) == 0 {
+		return nil
+	}
+	next := queue[0]
+	c.requestQueue[req.Type] = queue[1:]
+	next.queued = false
+	return next
+}
+
+func (c *Client) findPendingRequestByType(reqType string) *PendingRequest {
+	c.pendingMu.RLock()
+	defer c.pendingMu.RUnlock()
+	return c.inFlight(reqType)
+}
+
+// inFlight returns the incomplete request of a type that is not queued, the
+// only one replies can belong to. The caller holds pendingMu.
+func (c *Client) inFlight(reqType string) *PendingRequest {
+	for _, req := range c.pending {
+		if req.Type == reqType && !req.Complete && !req.queued {
+			return req
+		}
+	}
+	return nil
+}
+
+// findPendingRequestByTarget finds an incomplete request of a type for a target (case-insensitive)
+func (c *Client) findPendingRequestByTarget(reqType, target string) *PendingRequest {
+	c.pendingMu.RLock()
+	defer c.pendingMu.RUnlock()
+
+	for _, req := range c.pending {
+		if req.Type == reqType && strings.EqualFold(req.Target, target) && !req.Complete {
+			return req
+		}
+	}
+	return nil
+}
+
+func (c *Client) findPendingWhoisRequest(nick string) *PendingRequest {
+	c.pendingMu.RLock()
+	defer c.pendingMu.RUnlock()
+
+	for _, req := range c.pending {
+		if req.Type == "whois" && strings.EqualFold(req.Target, nick) && !req.Complete {
+			return req
+		}
+	}
+	return nil
+}
+
+// Errors from GetRequestResultContext besides the server's own error replies
+var (
+	errRequestTimeout   = errors.New("request timed out")
+	errConnectionClosed = errors.New("connection closed")
+)
+
+// Names initiates a NAMES command for a channel and returns a request ID
+func (c *Client) Names(channel string) string {
+	req := c.createPendingRequest("names", channel)
+	c.rawf("NAMES %s", channel)
+	return req.ID
+}
+
+// Whois initiates a WHOIS command for a specific nick and returns a request ID
+func (c *Client) Whois(nick string) string {
+	req := c.createPendingRequest("whois", nick)
+	c.rawf("WHOIS %s", nick)
+	return req.ID
+}
+
+// GetRequestResult waits for a request to complete and returns the result
+func (c *Client) GetRequestResult(requestID string, timeout time.Duration) (*PendingRequest, error) {
+	return c.GetRequestResultContext(context.Background(), requestID, timeout)
+}
+
+// GetRequestResultContext is GetRequestResult that also gives up when ctx is
+// cancelled or the connection the request was sent on ends
+func (c *Client) GetRequestResultContext(ctx context.Context, requestID string, timeout time.Duration) (*PendingRequest, error) {
+	req := c.getPendingRequest(requestID)
+	if req == nil {
+		return nil, fmt.Errorf("request not found")
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// Wait for completion, timeout or cancellation
+	select {
+	case <-req.done:
+		if req.Error == errRequestTimeout.Error() {
+			return req, errRequestTimeout
+		}
+		if req.Error != "" {
+			return req, errors.New(req.Error)
+		}
+		return req, nil
+	case <-timer.C:
+		return req, errRequestTimeout
+	case <-ctx.Done():
+		return req, ctx.Err()
+	case <-c.connContext().Done():
+		return req, errConnectionClosed
+	}
+}
@@ -0,0 +1,81 @@
+package irc
+
+import "strings"
+
+// prefixMap pairs channel status modes with their NAMES/WHO prefixes, highest
+// rank first, as advertised in ISUPPORT PREFIX, e.g. "(qaohv)~&@%+"
+type prefixMap struct {
+	modes    string
+	prefixes string
+}
+
+// defaultPrefixMap is used until the server sends PREFIX
+var defaultPrefixMap = prefixMap{modes: "qaohv", prefixes: "~&@%+"}
+
+// parsePrefixMap parses an ISUPPORT PREFIX value. A malformed value yields
+// the default map; an empty "()" means the server has no status modes.
+func parsePrefixMap(value string) prefixMap {
+	end := strings.IndexByte(value, ')')
+	if !strings.HasPrefix(value, "(") || end == -1 || end-1 != len(value)-end-1 {
+		return defaultPrefixMap
+	}
+	return prefixMap{modes: value[1:end], prefixes: value[end+1:]}
+}
+
+// prefixMap returns the server's status prefixes
+func (c *Client) prefixMap() prefixMap {
+	c.serverInfoMu.RLock()
+	defer c.serverInfoMu.RUnlock()
+	value, ok := c.serverInfo.ISupportTags["PREFIX"]
+	if !ok {
+		return defaultPrefixMap
+	}
+	return parsePrefixMap(value)
+}
+
+// isStatusMode reports whether mode is a status mode such as o or v
+func (p prefixMap) isStatusMode(mode rune) bool {
+	return strings.ContainsRune(p.modes, mode)
+}
+
+// parseNamesEntry splits an RPL_NAMREPLY entry such as "@+nick" into the
+// nick and its modes ("ov"). With multi-prefix a user may carry several
+// prefixes, all of which are kept.
+func (p prefixMap) parseNamesEntry(name string) (nick, modes string) {
+	i := 0
+	for i < len(name) {
+		j := strings.IndexByte(p.prefixes, name[i])
+		if j == -1 {
+			break
+		}
+		modes += p.modes[j : j+1]
+		i++
+	}
+	// userhost-in-names: strip !user@host
+	nick = name[i:]
+	if j := strings.Index(nick, "!"); j != -1 {
+		nick = nick[:j]
+	}
+	return nick, p.sortModes(modes)
+}
+
+// sortModes orders status modes by rank, highest first
+func (p prefixMap) sortModes(modes string) string {
+	var b strings.Builder
+	for _, m := range p.modes {
+		if strings.ContainsRune(modes, m) {
+			b.WriteRune(m)
+		}
+	}
+	return b.String()
+}
+
+// highest returns the prefix of the highest status mode in modes
+func (p prefixMap) highest(modes string) string {
+	for i, m := range p.modes {
+		if strings.ContainsRune(modes, m) {
+			return p.prefixes[i : i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package irc
+
+import "testing"
+
+func TestParsePrefixMap(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected prefixMap
+	}{
+		{"(ov)@+", prefixMap{"ov", "@+"}},
+		{"(Yqaohv)!~&@%+", prefixMap{"Yqaohv", "!~&@%+"}},
+		{"()", prefixMap{}},
+		{"(ov)@", defaultPrefixMap},
+		{"ov@+", defaultPrefixMap},
+	}
+	for _, tt := range tests {
+		if got := parsePrefixMap(tt.value); got != tt.expected {
+			t.Errorf("parsePrefixMap(%q) = %+v; want %+v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestNamesWithServerPrefixes(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":server 005 TestBot PREFIX=(Yqaohv)!~&@%+ :are supported by this server")
+	client.handleLine(":server 353 TestBot = #test :TestBot !~alice &@bob +carol")
+	client.handleLine(":server 366 TestBot #test :End of /NAMES list")
+
+	users := client.GetChannelStates()["#test"]
+	for nick, want := range map[string]any{"alice": "Yq", "bob": "ao", "carol": "v", "TestBot": nil} {
+		if users[nick] != want {
+			t.Errorf("Expected %s to have modes %v, got %v", nick, want, users[nick])
+		}
+	}
+
+	client.handleLine(":ChanServ!s@services MODE #test +qY-o carol carol bob")
+	users = client.GetChannelStates()["#test"]
+	if users["carol"] != "Yqv" || users["bob"] != "a" {
+		t.Errorf("Expected carol Yqv and bob a, got %v and %v", users["carol"], users["bob"])
+	}
+	if h := client.prefixMap().highest("qv"); h != "~" {
+		t.Errorf("Expected ~ as the highest prefix, got %q", h)
+	}
+}
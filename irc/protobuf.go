@@ -0,0 +1,117 @@
+package irc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal protocol buffers wire format support for the gRPC interface. Only
+// the types used by proto/hanna/v1/hanna.proto are handled: strings, bools,
+// int64 and embedded messages.
+
+const (
+	pbVarint = 0
+	pbBytes  = 2
+)
+
+// pbWriter appends fields to an encoded message. Zero values are omitted as
+// in proto3.
+type pbWriter struct{ buf []byte }
+
+func (w *pbWriter) tag(field, wire int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wire))
+}
+
+func (w *pbWriter) bytes(field int, b []byte) {
+	w.tag(field, pbBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *pbWriter) string(field int, s string) {
+	if s != "" {
+		w.bytes(field, []byte(s))
+	}
+}
+
+// strings writes a repeated string, keeping empty elements
+func (w *pbWriter) strings(field int, list []string) {
+	for _, s := range list {
+		w.bytes(field, []byte(s))
+	}
+}
+
+func (w *pbWriter) int64(field int, v int64) {
+	if v != 0 {
+		w.tag(field, pbVarint)
+		w.buf = binary.AppendUvarint(w.buf, uint64(v))
+	}
+}
+
+func (w *pbWriter) bool(field int, v bool) {
+	if v {
+		w.tag(field, pbVarint)
+		w.buf = append(w.buf, 1)
+	}
+}
+
+func (w *pbWriter) message(field int, m pbWriter) {
+	w.bytes(field, m.buf)
+}
+
+// pbField is one decoded field; Bytes is set for length-delimited fields and
+// Varint for varints
+type pbField struct {
+	Num    int
+	Varint uint64
+	Bytes  []byte
+}
+
+func (f pbField) String() string { return string(f.Bytes) }
+func (f pbField) Bool() bool     { return f.Varint != 0 }
+
+// pbFields splits an encoded message into its fields. Fixed-size fields are
+// skipped since no message the server reads uses them.
+func pbFields(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("invalid field key")
+		}
+		b = b[n:]
+		f := pbField{Num: int(key >> 3)}
+		switch key & 7 {
+		case pbVarint:
+			f.Varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("field %d: invalid varint", f.Num)
+			}
+			b = b[n:]
+		case pbBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, fmt.Errorf("field %d: invalid length", f.Num)
+			}
+			f.Bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case 1: // fixed64
+			if len(b) < 8 {
+				return nil, fmt.Errorf("field %d: truncated", f.Num)
+			}
+			b = b[8:]
+			continue
+		case 5: // fixed32
+			if len(b) < 4 {
+				return nil, fmt.Errorf("field %d: truncated", f.Num)
+			}
+			b = b[4:]
+			continue
+		default:
+			return nil, fmt.Errorf("field %d: unsupported wire type %d", f.Num, key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
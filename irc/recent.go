@@ -0,0 +1,98 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecentMessages bounds the recent_messages of a trigger endpoint
+const maxRecentMessages = 100
+
+// RecentMessage is a channel message in the recentMessages of a mention
+type RecentMessage struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // privmsg, action or notice
+	Nick    string    `json:"nick"`
+	Message string    `json:"message"`
+
+	id string // event ID, to leave the mention itself out
+}
+
+// recentMessages keeps the last messages of each channel, the bot's own
+// included, for trigger endpoints with recent_messages. size is the largest
+// recent_messages of any endpoint, 0 when none asks for them.
+type recentMessages struct {
+	size int
+
+	mu       sync.Mutex
+	channels map[string][]RecentMessage // lowercase channel -> oldest first
+}
+
+func (r *recentMessages) add(channel string, m RecentMessage) {
+	if r.size == 0 {
+		return
+	}
+	if action, ok := ctcpAction(m.Message); ok {
+		m.Type, m.Message = "action", action
+	} else if strings.HasPrefix(m.Message, "\x01") {
+		// Other CTCP requests and replies are not conversation
+		return
+	}
+	key := strings.ToLower(channel)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.channels == nil {
+		r.channels = make(map[string][]RecentMessage)
+	}
+	list := r.channels[key]
+	// One more than asked for: the last one may be the mention itself
+	if len(list) > r.size {
+		list = append(list[:0], list[len(list)-r.size:]...)
+	}
+	r.channels[key] = append(list, m)
+}
+
+func (r *recentMessages) forget(channel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.channels, strings.ToLower(channel))
+}
+
+// snapshot returns the last n messages of a channel before the message with
+// event ID id, oldest first
+func (r *recentMessages) snapshot(channel string, n int, id string) []RecentMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := r.channels[strings.ToLower(channel)]
+	// The mention was remembered as a privmsg just before it fired
+	if len(list) > 0 && id != "" && list[len(list)-1].id == id {
+		list = list[:len(list)-1]
+	}
+	list = list[max(len(list)-n, 0):]
+	return append([]RecentMessage{}, list...)
+}
+
+// rememberMessage keeps the channel messages of events for recent_messages
+func (c *Client) rememberMessage(e Event) {
+	if c.recent.size == 0 || e.Replayed || !isChannelName(e.Target) {
+		return
+	}
+	switch e.Type {
+	case "privmsg", "action", "notice":
+		if !e.Dropped {
+			c.recent.add(e.Target, RecentMessage{Time: messageTime(e.Tags), Type: e.Type, Nick: e.Sender, Message: e.Text, id: e.ID})
+		}
+	case "part", "kick":
+		if e.Self {
+			c.recent.forget(e.Target)
+		}
+	}
+}
+
+// rememberOutgoing keeps the bot's own channel messages for recent_messages
+func (c *Client) rememberOutgoing(typ, target, message string) {
+	if c.recent.size > 0 && isChannelName(target) {
+		c.recent.add(target, RecentMessage{Time: time.Now(), Type: typ, Nick: c.Nick(), Message: message})
+	}
+}
@@ -0,0 +1,61 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMentionRecentMessages(t *testing.T) {
+	received := make(chan TriggerPayload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload TriggerPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			received <- payload
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"llm":{"url":%q,"events":["mention"],"recent_messages":4,"formatting":"strip"}}}`, srv.URL))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :first")
+	client.handleLine(":alice!a@host PRIVMSG #test :\x02second\x02")
+	client.handleLine(":carol!c@host PRIVMSG #other :elsewhere")
+	client.Privmsg("#test", "third")
+	client.Action("#test", "nods")
+	client.handleLine(":bob!b@host PRIVMSG #test :\x01ACTION waves\x01")
+	client.handleLine(":bob!b@host PRIVMSG #test :\x01VERSION\x01")
+	client.handleLine(":alice!a@host PRIVMSG #test :TestBot: what did I say?")
+
+	payload := expectTrigger(t, received)
+	want := []RecentMessage{
+		{Type: "privmsg", Nick: "alice", Message: "second"},
+		{Type: "privmsg", Nick: "TestBot", Message: "third"},
+		{Type: "action", Nick: "TestBot", Message: "nods"},
+		{Type: "action", Nick: "bob", Message: "waves"},
+	}
+	if len(payload.Recent) != len(want) {
+		t.Fatalf("Expected %d recent messages, got %+v", len(want), payload.Recent)
+	}
+	for i, m := range payload.Recent {
+		if m.Type != want[i].Type || m.Nick != want[i].Nick || m.Message != want[i].Message || m.Time.IsZero() {
+			t.Errorf("Message %d: expected %+v, got %+v", i, want[i], m)
+		}
+	}
+
+	// Private mentions have no channel to look back on
+	client.handleLine(":alice!a@host PRIVMSG TestBot :hello")
+	if payload := expectTrigger(t, received); payload.Recent != nil {
+		t.Errorf("Expected no recent messages for a private mention, got %+v", payload.Recent)
+	}
+
+	// Leaving a channel forgets it
+	client.handleLine(":TestBot!b@host PART #test")
+	if got := client.recent.snapshot("#test", 3, ""); len(got) != 0 {
+		t.Errorf("Expected no messages after leaving, got %+v", got)
+	}
+}
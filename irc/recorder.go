@@ -0,0 +1,164 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RecordedLine is one line of a session recording (JSON Lines). Lines that
+// are not valid UTF-8 are kept byte for byte in Base64.
+type RecordedLine struct {
+	Time   time.Time `json:"time"`
+	Dir    string    `json:"dir"` // in (from the server) or out (to the server)
+	Line   string    `json:"line,omitempty"`
+	Base64 string    `json:"base64,omitempty"`
+}
+
+// text returns the line as it was sent or received
+func (r RecordedLine) text() (string, error) {
+	if r.Base64 == "" {
+		return r.Line, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(r.Base64)
+	return string(b), err
+}
+
+// sessionRecorder appends every IRC line to IRC_RECORD_FILE, with
+// passwords and SASL credentials blanked out
+type sessionRecorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func (c *Client) loadRecorder() {
+	path := strings.TrimSpace(os.Getenv("IRC_RECORD_FILE"))
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		log.Fatalf("FATAL: Cannot open IRC_RECORD_FILE: %v", err)
+	}
+	log.Printf("Recording the IRC session to %s", path)
+	c.recorder = &sessionRecorder{f: f, enc: json.NewEncoder(f)}
+}
+
+// record appends a line; dir is "in" or "out"
+func (r *sessionRecorder) record(dir, line string) {
+	if r == nil {
+		return
+	}
+	if dir == "out" {
+		line = redactOutgoing(line)
+	}
+	rec := RecordedLine{Time: time.Now().UTC(), Dir: dir, Line: line}
+	if !utf8.ValidString(line) {
+		rec.Line, rec.Base64 = "", base64.StdEncoding.EncodeToString([]byte(line))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(rec); err != nil {
+		log.Printf("Error recording IRC line: %v", err)
+	}
+}
+
+func (r *sessionRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// redactOutgoing blanks out the secrets in PASS, OPER, SASL and NickServ
+// IDENTIFY lines
+func redactOutgoing(line string) string {
+	tags, rest := "", line
+	if strings.HasPrefix(rest, "@") {
+		if i := strings.Index(rest, " "); i != -1 {
+			tags, rest = rest[:i+1], rest[i+1:]
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 2 {
+		return line
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "PASS":
+		return tags + "PASS ***"
+	case "OPER":
+		return tags + "OPER " + fields[1] + " ***"
+	case "AUTHENTICATE":
+		if arg := fields[1]; arg != "+" && arg != "*" && strings.ToUpper(arg) != "PLAIN" {
+			return tags + "AUTHENTICATE ***"
+		}
+	case "PRIVMSG":
+		_, text, _ := strings.Cut(rest, " :")
+		if strings.EqualFold(fields[1], "NickServ") && strings.HasPrefix(strings.ToUpper(text), "IDENTIFY") {
+			return tags + "PRIVMSG " + fields[1] + " :IDENTIFY ***"
+		}
+	}
+	return line
+}
+
+// ReplayStats counts the lines of a replayed recording
+type ReplayStats struct {
+	Inbound  int `json:"inbound"`  // fed through the parser
+	Outbound int `json:"outbound"` // sent by the bot at the time, not resent
+}
+
+// Replay feeds the inbound lines of a recording through the parser as if
+// they came from the server, to reproduce state or debug numeric handling.
+// speed 1 keeps the recorded pacing, 2 replays twice as fast, and 0 or less
+// replays without waiting. Replies the bot would send go nowhere without a
+// connection.
+func (c *Client) Replay(ctx context.Context, r io.Reader, speed float64) (ReplayStats, error) {
+	var stats ReplayStats
+	var last time.Time
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var rec RecordedLine
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return stats, fmt.Errorf("line %d: %w", n, err)
+		}
+		line, err := rec.text()
+		if err != nil {
+			return stats, fmt.Errorf("line %d: %w", n, err)
+		}
+		if speed > 0 && !last.IsZero() && rec.Time.After(last) {
+			if !sleepContext(ctx, time.Duration(float64(rec.Time.Sub(last))/speed)) {
+				return stats, ctx.Err()
+			}
+		}
+		last = rec.Time
+		switch rec.Dir {
+		case "in":
+			stats.Inbound++
+			c.safely("replay", func() { c.handleLine(line) })
+		case "out":
+			stats.Outbound++
+		default:
+			return stats, fmt.Errorf("line %d: unknown direction %q", n, rec.Dir)
+		}
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+	}
+	return stats, scanner.Err()
+}
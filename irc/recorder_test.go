@@ -0,0 +1,121 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	t.Setenv("IRC_RECORD_FILE", path)
+	t.Setenv("IRC_PASS", "serverpass")
+	t.Setenv("SASL_USER", "bot")
+	t.Setenv("SASL_PASS", "secret")
+	srv := newFakeIRCd(t)
+	srv.saslUser, srv.saslPass = "bot", "secret"
+	client := srv.client()
+
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fc := srv.accept()
+	waitState(t, client, StateConnected)
+	fc.send(":fake.server NOTICE TestBot :caf\xe9")
+	time.Sleep(100 * time.Millisecond)
+	client.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "serverpass") || strings.Contains(string(data), "secret") {
+		t.Errorf("Credentials leaked into the recording:\n%s", data)
+	}
+	var lines []RecordedLine
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		var rec RecordedLine
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, rec)
+	}
+	has := func(dir, prefix string) bool {
+		for _, rec := range lines {
+			if text, _ := rec.text(); rec.Dir == dir && strings.HasPrefix(text, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, want := range []struct{ dir, prefix string }{
+		{"out", "PASS ***"},
+		{"out", "AUTHENTICATE ***"},
+		{"out", "NICK "},
+		{"in", ":fake.server 001 "},
+		{"in", ":fake.server NOTICE TestBot :caf\xe9"},
+	} {
+		if !has(want.dir, want.prefix) {
+			t.Errorf("Expected %s line %q in the recording", want.dir, want.prefix)
+		}
+	}
+}
+
+func TestReplaySession(t *testing.T) {
+	recording := strings.Join([]string{
+		`{"time":"2025-01-01T12:00:00Z","dir":"out","line":"NICK TestBot"}`,
+		`{"time":"2025-01-01T12:00:01Z","dir":"in","line":":fake.server 001 TestBot :Welcome"}`,
+		`{"time":"2025-01-01T12:00:01Z","dir":"in","line":":fake.server 005 TestBot NETWORK=FakeNet :are supported"}`,
+		`{"time":"2025-01-01T12:00:02Z","dir":"in","line":":TestBot!b@host JOIN #test"}`,
+		`{"time":"2025-01-01T12:00:02Z","dir":"in","line":":fake.server 353 TestBot = #test :@alice +bob TestBot"}`,
+		`{"time":"2025-01-01T12:00:02Z","dir":"in","line":":fake.server 366 TestBot #test :End of /NAMES list."}`,
+		`{"time":"2025-01-01T12:00:03Z","dir":"in","base64":"OmFsaWNlIVVAaCBUT1BJQyAjdGVzdCA6Y2Fm6Q=="}`,
+	}, "\n")
+	client := NewClient()
+	client.setNick("TestBot")
+
+	stats, err := client.Replay(context.Background(), strings.NewReader(recording), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ReplayStats{Inbound: 6, Outbound: 1}) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if network := client.getServerInfo().ISupportTags["NETWORK"]; network != "FakeNet" {
+		t.Errorf("Expected NETWORK=FakeNet, got %q", network)
+	}
+	state := client.GetChannelState("#test")
+	if state == nil || state.Users["alice"] != "o" || state.Users["bob"] != "v" {
+		t.Fatalf("Expected the replayed NAMES in the channel state, got %+v", state)
+	}
+	if state.Topic != "café" {
+		t.Errorf("Expected the cp1252 topic to be decoded, got %q", state.Topic)
+	}
+
+	if _, err := client.Replay(context.Background(), strings.NewReader(`{"dir":"sideways","line":"x"}`), 0); err == nil {
+		t.Error("Expected an error for an unknown direction")
+	}
+}
+
+func TestRedactOutgoing(t *testing.T) {
+	tests := map[string]string{
+		"PASS hunter2":                          "PASS ***",
+		"OPER admin hunter2":                    "OPER admin ***",
+		"AUTHENTICATE PLAIN":                    "AUTHENTICATE PLAIN",
+		"AUTHENTICATE AGJvdABzZWNyZXQ=":         "AUTHENTICATE ***",
+		"PRIVMSG NickServ :IDENTIFY hunter2":    "PRIVMSG NickServ :IDENTIFY ***",
+		"@label=1 PRIVMSG #test :identify me":   "@label=1 PRIVMSG #test :identify me",
+		"PRIVMSG nickserv :identify bot hunter": "PRIVMSG nickserv :IDENTIFY ***",
+	}
+	for in, want := range tests {
+		if got := redactOutgoing(in); got != want {
+			t.Errorf("redactOutgoing(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package irc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRemindersPerUser caps the pending reminders a single user may hold
+const maxRemindersPerUser = 10
+
+var reminderUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// compactDurationRe matches compact durations such as "2h", "1d12h" or "90s"
+var compactDurationRe = regexp.MustCompile(`^(\d+[a-z]+)+$`)
+var compactPartRe = regexp.MustCompile(`(\d+)([a-z]+)`)
+
+// parseReminder splits "!remind" arguments such as "me in 2 hours and 30
+// minutes to restart the server" into a delay and the reminder text
+func parseReminder(args []string) (time.Duration, string, error) {
+	i := 0
+	if i < len(args) && strings.EqualFold(args[i], "me") {
+		i++
+	}
+	if i < len(args) && strings.EqualFold(args[i], "in") {
+		i++
+	}
+
+	var total time.Duration
+durations:
+	for i < len(args) {
+		word := strings.ToLower(strings.TrimSuffix(args[i], ","))
+		switch {
+		case word == "and" && total > 0:
+			i++
+		case compactDurationRe.MatchString(word):
+			d, ok := parseCompactDuration(word)
+			if !ok {
+				return 0, "", fmt.Errorf("unknown duration %q", args[i])
+			}
+			total += d
+			i++
+		case i+1 < len(args) && isReminderCount(word):
+			unit, ok := reminderUnits[strings.ToLower(strings.TrimSuffix(args[i+1], ","))]
+			if !ok {
+				return 0, "", fmt.Errorf("unknown time unit %q", args[i+1])
+			}
+			n := 1
+			if word != "a" && word != "an" {
+				n, _ = strconv.Atoi(word)
+			}
+			total += time.Duration(n) * unit
+			i += 2
+		default:
+			break durations
+		}
+	}
+
+	if total <= 0 {
+		return 0, "", errors.New("missing duration")
+	}
+	if i < len(args) && (strings.EqualFold(args[i], "to") || strings.EqualFold(args[i], "that")) {
+		i++
+	}
+	msg := strings.TrimSpace(strings.Join(args[i:], " "))
+	if msg == "" {
+		return 0, "", errors.New("missing reminder text")
+	}
+	return total, msg, nil
+}
+
+func isReminderCount(word string) bool {
+	if word == "a" || word == "an" {
+		return true
+	}
+	n, err := strconv.Atoi(word)
+	return err == nil && n > 0
+}
+
+func parseCompactDuration(s string) (time.Duration, bool) {
+	var total time.Duration
+	for _, m := range compactPartRe.FindAllStringSubmatch(s, -1) {
+		unit, ok := reminderUnits[m[2]]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, false
+		}
+		total += time.Duration(n) * unit
+	}
+	return total, true
+}
+
+// reminderOwner identifies whose reminder a schedule is: the services account
+// when known, otherwise the nick
+func reminderOwner(ctx *CommandContext) string {
+	if ctx.Account != "" {
+		return "account:" + strings.ToLower(ctx.Account)
+	}
+	return "nick:" + strings.ToLower(ctx.Sender)
+}
+
+// Reminders returns the pending reminders of an owner ordered by due time
+func (c *Client) Reminders(owner string) []Schedule {
+	var out []Schedule
+	for _, s := range c.Schedules() {
+		if s.Kind == "reminder" && s.Owner == owner {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (c *Client) registerRemindCommand() {
+	c.RegisterCommand(Command{
+		Name:  "remind",
+		Usage: "remind [me] in <duration> [to] <text> | remind list | remind cancel <n>",
+		Handler: func(ctx *CommandContext) {
+			owner := reminderOwner(ctx)
+			if len(ctx.Args) > 0 {
+				switch strings.ToLower(ctx.Args[0]) {
+				case "list":
+					c.listReminders(ctx, owner)
+					return
+				case "cancel":
+					c.cancelReminder(ctx, owner)
+					return
+				}
+			}
+
+			delay, text, err := parseReminder(ctx.Args)
+			if err != nil {
+				ctx.Replyf("%s: %v (e.g. %sremind me in 2h to restart the server)", ctx.Sender, err, c.commandPrefix)
+				return
+			}
+			if len(c.Reminders(owner)) >= maxRemindersPerUser {
+				ctx.Replyf("%s: you already have %d pending reminders", ctx.Sender, maxRemindersPerUser)
+				return
+			}
+			s, err := c.addSchedule("reminder", ctx.ReplyTo, fmt.Sprintf("%s: reminder: %s", ctx.Sender, text), delay, "", owner)
+			if err != nil {
+				ctx.Replyf("%s: %v", ctx.Sender, err)
+				return
+			}
+			ctx.Replyf("%s: I'll remind you at %s (in %s)", ctx.Sender, s.NextRun.Format("2006-01-02 15:04 MST"), delay)
+		},
+	})
+}
+
+func (c *Client) listReminders(ctx *CommandContext, owner string) {
+	reminders := c.Reminders(owner)
+	if len(reminders) == 0 {
+		ctx.Replyf("%s: you have no pending reminders", ctx.Sender)
+		return
+	}
+	now := time.Now()
+	for i, s := range reminders {
+		text := strings.TrimPrefix(s.Message, ctx.Sender+": reminder: ")
+		ctx.Replyf("%s: #%d in %s to %s: %s", ctx.Sender, i+1, s.NextRun.Sub(now).Round(time.Second), s.Target, text)
+	}
+}
+
+func (c *Client) cancelReminder(ctx *CommandContext, owner string) {
+	reminders := c.Reminders(owner)
+	n := 0
+	if len(ctx.Args) > 1 {
+		n, _ = strconv.Atoi(ctx.Args[1])
+	}
+	if n < 1 || n > len(reminders) {
+		ctx.Replyf("%s: usage: %sremind cancel <n> (see %sremind list)", ctx.Sender, c.commandPrefix, c.commandPrefix)
+		return
+	}
+	c.RemoveSchedule(reminders[n-1].ID)
+	ctx.Replyf("%s: cancelled reminder #%d", ctx.Sender, n)
+}
@@ -0,0 +1,77 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReminder(t *testing.T) {
+	tests := []struct {
+		in    string
+		delay time.Duration
+		text  string
+	}{
+		{"me in 2h to restart the server", 2 * time.Hour, "restart the server"},
+		{"in 2 hours and 30 minutes check the oven", 150 * time.Minute, "check the oven"},
+		{"1d12h that the deploy is due", 36 * time.Hour, "the deploy is due"},
+		{"me in an hour to stretch", time.Hour, "stretch"},
+		{"90s, tea", 90 * time.Second, "tea"},
+	}
+	for _, tt := range tests {
+		delay, text, err := parseReminder(strings.Fields(tt.in))
+		if err != nil {
+			t.Errorf("parseReminder(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if delay != tt.delay || text != tt.text {
+			t.Errorf("parseReminder(%q) = %s %q, want %s %q", tt.in, delay, text, tt.delay, tt.text)
+		}
+	}
+
+	for _, bad := range []string{"me to do things", "in 2h", "in 3 fortnights to rest", ""} {
+		if _, _, err := parseReminder(strings.Fields(bad)); err == nil {
+			t.Errorf("parseReminder(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRemindCommand(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":alice!a@host PRIVMSG #test :!remind me in 2h to restart the server")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "PRIVMSG #test :alice: I'll remind you at ") {
+		t.Fatalf("Expected a confirmation, got %v", sent)
+	}
+	reminders := client.Reminders("nick:alice")
+	if len(reminders) != 1 || reminders[0].Target != "#test" || reminders[0].Message != "alice: reminder: restart the server" {
+		t.Fatalf("Expected one reminder for alice, got %+v", reminders)
+	}
+
+	sent = nil
+	client.handleLine(":bob!b@host PRIVMSG #test :!remind list")
+	if len(sent) != 1 || sent[0] != "PRIVMSG #test :bob: you have no pending reminders" {
+		t.Errorf("Reminders should be listed per user, got %v", sent)
+	}
+
+	sent = nil
+	client.handleLine(":alice!a@host PRIVMSG #test :!remind list")
+	if len(sent) != 1 || !strings.Contains(sent[0], "#1 in ") || !strings.HasSuffix(sent[0], "to #test: restart the server") {
+		t.Errorf("Expected alice's reminder to be listed, got %v", sent)
+	}
+
+	client.runDueSchedules(reminders[0].NextRun.Add(time.Second))
+	if last := sent[len(sent)-1]; last != "PRIVMSG #test :alice: reminder: restart the server" {
+		t.Errorf("Expected the reminder to be delivered, got %q", last)
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG TestBot :!remind in 5m to check mail")
+	sent = nil
+	client.handleLine(":alice!a@host PRIVMSG TestBot :!remind cancel 1")
+	if len(sent) != 1 || sent[0] != "PRIVMSG alice :alice: cancelled reminder #1" || len(client.Reminders("nick:alice")) != 0 {
+		t.Errorf("Expected the private reminder to be cancelled, got %v", sent)
+	}
+}
@@ -0,0 +1,206 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replyRefsMax bounds how many received messages can be answered by
+// correlation ID
+const replyRefsMax = 1000
+
+// replyAckTimeout bounds how long /api/reply waits for the server's answer
+// to a labeled reply; labels are forgotten after twice as long
+const replyAckTimeout = 5 * time.Second
+
+// ReplyRef is a received message as /api/reply answers it
+type ReplyRef struct {
+	Target string // the channel, or the sender of a private message
+	MsgID  string // its msgid tag, "" when the server sends none
+}
+
+// replyRefs remembers the last received messages by correlation ID, the
+// correlationId of their trigger payloads
+type replyRefs struct {
+	mu    sync.Mutex
+	refs  map[string]ReplyRef
+	order []string
+}
+
+func (r *replyRefs) add(id string, ref ReplyRef) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refs == nil {
+		r.refs = make(map[string]ReplyRef)
+	}
+	r.refs[id] = ref
+	r.order = append(r.order, id)
+	if len(r.order) > replyRefsMax {
+		delete(r.refs, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *replyRefs) get(id string) (ReplyRef, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ref, ok := r.refs[id]
+	return ref, ok
+}
+
+// rememberReplyRef keeps where and to which msgid a message is answered
+func (c *Client) rememberReplyRef(e Event) {
+	if e.Type != "privmsg" && e.Type != "action" || e.Replayed || e.Dropped || e.Self {
+		return
+	}
+	ref := ReplyRef{Target: e.Target, MsgID: e.Tags["msgid"]}
+	if !isChannelName(e.Target) {
+		ref.Target = e.Sender
+	}
+	c.replyRefs.add(e.ID, ref)
+}
+
+// labelTracker matches the server's answers to labeled commands
+// (labeled-response) with the commands that are waiting for them
+type labelTracker struct {
+	mu      sync.Mutex
+	waiting map[string]chan error
+}
+
+// expect registers a new label; its answer arrives on the channel: nil when
+// the server accepted the command, the error it answered with otherwise
+func (l *labelTracker) expect() (string, chan error) {
+	label, ch := newRequestID(), make(chan error, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.waiting == nil {
+		l.waiting = make(map[string]chan error)
+	}
+	l.waiting[label] = ch
+	time.AfterFunc(2*replyAckTimeout, func() { l.drop(label) })
+	return label, ch
+}
+
+// drop stops waiting for a label
+func (l *labelTracker) drop(label string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.waiting, label)
+}
+
+// answerLabel delivers the server's answer to a labeled command: ACK, the
+// start of a labeled-response batch or a reply, and reports whether the
+// label was the bot's. FAIL and error numerics are errors.
+func (c *Client) answerLabel(label, cmd string, args []string, trailing string) bool {
+	l := &c.labels
+	l.mu.Lock()
+	ch, ok := l.waiting[label]
+	delete(l.waiting, label)
+	l.mu.Unlock()
+	if !ok {
+		return false
+	}
+	var err error
+	numeric := len(cmd) == 3 && strings.Trim(cmd, "0123456789") == ""
+	if cmd == "FAIL" || numeric && (cmd[0] == '4' || cmd[0] == '5') {
+		err = fmt.Errorf("%s %s", cmd, trailing)
+	}
+	ch <- err
+	return true
+}
+
+// withLabel adds a label to tags ("@key=value " or empty)
+func withLabel(tags, label string) string {
+	if tags == "" {
+		return "@label=" + label + " "
+	}
+	return "@label=" + label + ";" + strings.TrimPrefix(tags, "@")
+}
+
+// Reply answers a message: in its channel, or privately to its sender. With
+// message-tags every line carries +draft/reply naming the message's msgid,
+// so clients can show the answer threaded under the question. With
+// labeled-response the first line is labeled, and the server's answer to it
+// arrives on the returned channel; it is nil otherwise. It is a PRIVMSG or a
+// NOTICE as replyCommand picks.
+func (c *Client) Reply(ref ReplyRef, msg string, notice *bool) (<-chan error, error) {
+	var tags string
+	if ref.MsgID != "" && c.messageTags.Load() {
+		tags = formatTags(map[string]string{replyTag: ref.MsgID})
+	}
+	cmd := c.replyCommand(ref.Target, notice)
+	if !c.labeledResponse.Load() {
+		return nil, c.sendLines(cmd, tags, "", ref.Target, msg)
+	}
+	label, answer := c.labels.expect()
+	if err := c.sendLines(cmd, tags, label, ref.Target, msg); err != nil {
+		c.labels.drop(label)
+		return nil, err
+	}
+	return answer, nil
+}
+
+// handleReply answers a received message, threaded under it where the server
+// supports it:
+// POST /api/reply {"correlation_id": "...", "message": "..."}
+func (a *API) handleReply(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		CorrelationID string `json:"correlation_id"` // correlationId of the trigger payload
+		Target        string `json:"target"`         // instead of correlation_id: where to answer
+		ReplyTo       string `json:"reply_to"`       // and the msgid answered
+		Message       string `json:"message"`
+		Format        string `json:"format"`
+		Notice        *bool  `json:"notice"` // instead of REPLY_NOTICE and the channel's profile
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Message == "" || (in.CorrelationID == "") == (in.Target == "") {
+		writeError(w, 400, codeInvalidRequest, "message and either correlation_id or target required")
+		return
+	}
+	message, err := applyMessageFormat(in.Format, in.Message)
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+	ref := ReplyRef{Target: in.Target, MsgID: in.ReplyTo}
+	if in.CorrelationID != "" {
+		var ok bool
+		if ref, ok = a.bot.replyRefs.get(in.CorrelationID); !ok {
+			writeError(w, 404, codeNotFound, fmt.Sprintf("no recent message with correlation ID %s (the last %d are kept)", in.CorrelationID, replyRefsMax))
+			return
+		}
+	}
+
+	var answer <-chan error
+	sent, err := a.bot.trackSend(a.bot.replyKind(ref.Target, in.Notice), ref.Target, requestID(r.Context()), func() error {
+		var err error
+		answer, err = a.bot.Reply(ref, message, in.Notice)
+		return err
+	})
+	if err != nil {
+		writeSendError(w, err)
+		return
+	}
+	if answer != nil {
+		select {
+		case err := <-answer:
+			sent = a.bot.confirmSent(sent.ID, err)
+		case <-time.After(replyAckTimeout):
+		case <-r.Context().Done():
+		}
+	}
+	if sent.Status == "rejected" {
+		writeError(w, 500, codeRequestFailed, sent.Error)
+		return
+	}
+	writeJSON(w, 200, map[string]any{
+		"status":   sent.Status,
+		"id":       sent.ID,
+		"target":   ref.Target,
+		"reply_to": ref.MsgID,
+		"threaded": ref.MsgID != "" && a.bot.messageTags.Load(),
+	})
+}
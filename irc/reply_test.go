@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplyAPI(t *testing.T) {
+	received := newTriggerRecorder(t, "mention")
+	client := NewClient()
+	client.setNick("TestBot")
+	lines := make(chan string, 10)
+	client.testRawCapture = func(line string) { lines <- line }
+	api := client.CreateAPI("token")
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/reply", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	client.handleLine("@msgid=AB12cd :alice!a@host PRIVMSG #test :TestBot: what is the answer?")
+	id := expectTrigger(t, received).CorrelationID
+
+	// Without message-tags the reply is a plain message in the channel
+	rec := post(`{"correlation_id":"` + id + `","message":"42"}`)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"threaded":false`) {
+		t.Errorf("Expected an unthreaded reply, got %d %s", rec.Code, rec.Body.String())
+	}
+	if line := <-lines; line != "PRIVMSG #test :42" {
+		t.Errorf("Unexpected line %q", line)
+	}
+
+	client.messageTags.Store(true)
+	rec = post(`{"correlation_id":"` + id + `","message":"42\nreally"}`)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"threaded":true`) || !strings.Contains(rec.Body.String(), `"status":"sent"`) {
+		t.Errorf("Expected a threaded reply, got %d %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{"@+draft/reply=AB12cd PRIVMSG #test :42", "@+draft/reply=AB12cd PRIVMSG #test :really"} {
+		if line := <-lines; line != want {
+			t.Errorf("Expected %q, got %q", want, line)
+		}
+	}
+
+	// With labeled-response the first line is labeled and the server's answer
+	// decides the status
+	client.labeledResponse.Store(true)
+	for _, tc := range []struct {
+		answer string
+		code   int
+		status string
+	}{
+		{"ACK", 200, `"status":"acknowledged"`},
+		{"404 TestBot #test :Cannot send to channel", 500, "Cannot send to channel"},
+	} {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- post(`{"target":"bob","reply_to":"x1","message":"a\nb"}`) }()
+		first, second := <-lines, <-lines
+		label, rest, _ := strings.Cut(strings.TrimPrefix(first, "@label="), ";")
+		if rest != "+draft/reply=x1 PRIVMSG bob :a" || second != "@+draft/reply=x1 PRIVMSG bob :b" {
+			t.Errorf("Expected the label on the first line only, got %q and %q", first, second)
+		}
+		client.handleLine("@label=" + label + " :irc.example.com " + tc.answer)
+		select {
+		case rec := <-done:
+			if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.status) {
+				t.Errorf("%s: expected %d %s, got %d %s", tc.answer, tc.code, tc.status, rec.Code, rec.Body.String())
+			}
+			var out struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &out)
+			if m, ok := client.SentMessage(out.ID); out.ID != "" && (!ok || m.Status != "acknowledged") {
+				t.Errorf("Expected the message status to be acknowledged, got %+v", m)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: timed out waiting for the reply", tc.answer)
+		}
+	}
+
+	if rec := post(`{"correlation_id":"unknown","message":"hi"}`); rec.Code != 404 {
+		t.Errorf("Expected 404 for an unknown correlation ID, got %d", rec.Code)
+	}
+	if rec := post(`{"correlation_id":"` + id + `","target":"#test","message":"hi"}`); rec.Code != 400 {
+		t.Errorf("Expected 400 with both correlation_id and target, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,38 @@
+package irc
+
+import "strings"
+
+// loadReplyMode reads REPLY_NOTICE, which makes the bot answer with NOTICE
+// instead of PRIVMSG, as RFC 1459 asks of automatic responders so other bots
+// never reply to them. The notice field of CHANNEL_CONFIG overrides it per
+// channel.
+func (c *Client) loadReplyMode() {
+	c.replyNotice = boolenv("REPLY_NOTICE", false)
+}
+
+// replyCommand picks PRIVMSG or NOTICE for a reply to target: the caller's
+// choice when notice is set, else the channel's profile, else REPLY_NOTICE
+func (c *Client) replyCommand(target string, notice *bool) string {
+	if notice == nil {
+		notice = c.channelProfile(target).Notice
+	}
+	if notice != nil && *notice || notice == nil && c.replyNotice {
+		return "NOTICE"
+	}
+	return "PRIVMSG"
+}
+
+// replyKind is the SentMessage kind of a reply to target
+func (c *Client) replyKind(target string, notice *bool) string {
+	return strings.ToLower(c.replyCommand(target, notice))
+}
+
+// SendReply sends a reply with client-only tags as PRIVMSG or NOTICE, see
+// replyCommand. Either way it is split and pasted like Privmsg, so notices
+// are throttled by the same flood protection.
+func (c *Client) SendReply(target, msg string, tags map[string]string, notice *bool) error {
+	if len(tags) > 0 && !c.messageTags.Load() {
+		return errNoMessageTags
+	}
+	return c.sendLines(c.replyCommand(target, notice), formatTags(tags), "", target, msg)
+}
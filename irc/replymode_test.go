@@ -0,0 +1,48 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplyNotice(t *testing.T) {
+	t.Setenv("REPLY_NOTICE", "true")
+	t.Setenv("CHANNEL_CONFIG", `{"#chat": {"notice": false}}`)
+	client := NewClient()
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	api := client.CreateAPI("token")
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/send", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		body, want, kind string
+	}{
+		{`{"target":"#help","message":"hi\nthere"}`, "NOTICE #help :hi|NOTICE #help :there", "notice"},
+		{`{"target":"alice","message":"hi"}`, "NOTICE alice :hi", "notice"},
+		{`{"target":"#chat","message":"hi"}`, "PRIVMSG #chat :hi", "privmsg"},
+		{`{"target":"#chat","message":"hi","notice":true}`, "NOTICE #chat :hi", "notice"},
+		{`{"target":"#help","message":"hi","notice":false}`, "PRIVMSG #help :hi", "privmsg"},
+	} {
+		lines = nil
+		rec := send(tc.body)
+		if rec.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d %s", tc.body, rec.Code, rec.Body.String())
+		}
+		if got := strings.Join(lines, "|"); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.body, tc.want, got)
+		}
+		var out struct{ ID string }
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		if m, ok := client.SentMessage(out.ID); !ok || m.Kind != tc.kind {
+			t.Errorf("%s: expected kind %s, got %+v", tc.body, tc.kind, m)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// replyRoute describes how one numeric contributes to a pending request
+type replyRoute struct {
+	parse func(args []string, trailing string) map[string]string // builds an entry; nil result is skipped
+	end   bool                                                      // completes the request
+	err   bool                                                      // completes the request with trailing as error
+}
+
+// requestSpec correlates the numerics answering one kind of request
+type requestSpec struct {
+	targetArg int // index of the request target in the reply args, -1 when replies are untargeted
+	routes    map[string]replyRoute
+}
+
+// requestSpecs holds every request type handled by the correlator, keyed by
+// PendingRequest.Type
+var requestSpecs = map[string]*requestSpec{
+	"list": {
+		targetArg: -1,
+		routes: map[string]replyRoute{
+			"322": {parse: func(args []string, trailing string) map[string]string {
+				// :server 322 nick #channel users :topic
+				if len(args) < 3 {
+					return nil
+				}
+				return map[string]string{"channel": args[1], "users": args[2], "topic": trailing}
+			}},
+			"323": {end: true},
+		},
+	},
+	"whois": {
+		targetArg: 1,
+		routes: map[string]replyRoute{
+			"311": {parse: func(args []string, trailing string) map[string]string {
+				// :server 311 nick target user host * :real_name
+				if len(args) < 5 || trailing == "" {
+					return nil
+				}
+				return map[string]string{"type": "user", "nick": args[1], "user": args[2], "host": args[3], "real_name": trailing}
+			}},
+			"312": {parse: func(args []string, trailing string) map[string]string {
+				// :server 312 nick target server :server_info
+				if len(args) < 3 || trailing == "" {
+					return nil
+				}
+				return map[string]string{"type": "server", "nick": args[1], "server": args[2], "server_info": trailing}
+			}},
+			"313": {parse: func(args []string, trailing string) map[string]string {
+				// :server 313 nick target :privileges
+				if trailing == "" {
+					return nil
+				}
+				return map[string]string{"type": "operator", "nick": args[1], "privileges": trailing}
+			}},
+			"317": {parse: func(args []string, trailing string) map[string]string {
+				// :server 317 nick target seconds :seconds idle
+				if len(args) < 3 || trailing == "" {
+					return nil
+				}
+				return map[string]string{"type": "idle", "nick": args[1], "seconds": args[2], "info": trailing}
+			}},
+			"319": {parse: func(args []string, trailing string) map[string]string {
+				// :server 319 nick target :*( ( '@' / '+' ) <channel> ' ' )
+				if trailing == "" {
+					return nil
+				}
+				return map[string]string{"type": "channels", "nick": args[1], "channels": trailing}
+			}},
+			"318": {end: true},
+			"401": {err: true},
+		},
+	},
+	"whowas": {
+		targetArg: 1,
+		routes: map[string]replyRoute{
+			"314": {parse: func(args []string, trailing string) map[string]string {
+				// :server 314 nick target user host * :real_name
+				if len(args) < 4 {
+					return nil
+				}
+				return map[string]string{"type": "user", "nick": args[1], "user": args[2], "host": args[3], "real_name": trailing}
+			}},
+			"312": {parse: func(args []string, trailing string) map[string]string {
+				// :server 312 nick target server :signoff time
+				if len(args) < 3 {
+					return nil
+				}
+				return map[string]string{"type": "server", "nick": args[1], "server": args[2], "server_info": trailing}
+			}},
+			"330": {parse: func(args []string, trailing string) map[string]string {
+				// :server 330 nick target account :was logged in as
+				if len(args) < 3 {
+					return nil
+				}
+				return map[string]string{"type": "account", "nick": args[1], "account": args[2]}
+			}},
+			"369": {end: true},
+			"406": {err: true}, // ERR_WASNOSUCHNICK
+		},
+	},
+	"names": {
+		targetArg: 1,
+		routes: map[string]replyRoute{
+			// RPL_NAMREPLY entries are added by the 353 handler, which knows
+			// about prefixes; only the terminator is routed here
+			"366": {end: true},
+			"403": {err: true},
+		},
+	},
+	// Ban, invite and except list entries are added to the channel state by
+	// their handlers; the requests only wait for the end of the list
+	"banlist":    modeListSpec("368"),
+	"invitelist": modeListSpec("347"),
+	"exceptlist": modeListSpec("349"),
+	"links": {
+		targetArg: -1,
+		routes: map[string]replyRoute{
+			"364": {parse: func(args []string, trailing string) map[string]string {
+				// :server 364 nick mask server :hopcount server_info
+				if len(args) < 3 {
+					return nil
+				}
+				entry := map[string]string{"mask": args[1], "server": args[2]}
+				if hop, info, ok := strings.Cut(trailing, " "); ok {
+					entry["hopcount"] = hop
+					entry["info"] = info
+				} else {
+					entry["hopcount"] = trailing
+				}
+				return entry
+			}},
+			"365": {end: true},
+		},
+	},
+	"map": {
+		targetArg: -1,
+		routes: map[string]replyRoute{
+			"015": {parse: mapRoute}, // RPL_MAP
+			"006": {parse: mapRoute},
+			"017": {end: true}, // RPL_MAPEND
+			"007": {end: true},
+		},
+	},
+	"stats": {
+		targetArg: -1,
+		routes: statsRoutes(),
+	},
+	"admin": {
+		targetArg: -1,
+		routes: map[string]replyRoute{
+			"256": {parse: func(args []string, trailing string) map[string]string {
+				// :server 256 nick server :Administrative info
+				entry := map[string]string{"type": "server", "info": trailing}
+				if len(args) >= 2 {
+					entry["server"] = args[1]
+				}
+				return entry
+			}},
+			"257": {parse: textRoute("location1")},
+			"258": {parse: textRoute("location2")},
+			// RPL_ADMINEMAIL is always the last ADMIN reply
+			"259": {parse: textRoute("email"), end: true},
+			"423": {err: true}, // ERR_NOADMININFO
+			"402": {err: true}, // ERR_NOSUCHSERVER
+		},
+	},
+	"motd": {
+		targetArg: -1,
+		routes: map[string]replyRoute{
+			"375": {},
+			"372": {parse: func(args []string, trailing string) map[string]string {
+				return map[string]string{"line": strings.TrimPrefix(trailing, "- ")}
+			}},
+			"376": {end: true},
+			"422": {err: true}, // ERR_NOMOTD
+			"402": {err: true},
+		},
+	},
+}
+
+// modeListSpec correlates a MODE list query ending with the numeric end
+func modeListSpec(end string) *requestSpec {
+	return &requestSpec{
+		targetArg: 1,
+		routes: map[string]replyRoute{
+			end:   {end: true},
+			"403": {err: true}, // ERR_NOSUCHCHANNEL
+			"442": {err: true}, // ERR_NOTONCHANNEL
+			"482": {err: true}, // ERR_CHANOPRIVSNEEDED
+		},
+	}
+}
+
+func mapRoute(args []string, trailing string) map[string]string {
+	return parseMapLine(trailing)
+}
+
+func textRoute(kind string) func([]string, string) map[string]string {
+	return func(args []string, trailing string) map[string]string {
+		return map[string]string{"type": kind, "info": trailing}
+	}
+}
+
+// statsRoutes collects the STATS reply numerics, terminated by RPL_ENDOFSTATS
+func statsRoutes() map[string]replyRoute {
+	routes := map[string]replyRoute{
+		"219": {end: true},
+		"481": {err: true}, // ERR_NOPRIVILEGES
+		"402": {err: true},
+	}
+	parse := func(args []string, trailing string) map[string]string {
+		entry := map[string]string{"args": strings.Join(args[1:], " ")}
+		if trailing != "" {
+			entry["trailing"] = trailing
+		}
+		return entry
+	}
+	for _, numeric := range []string{"211", "212", "213", "214", "215", "216", "217", "218",
+		"241", "242", "243", "244", "245", "246", "247", "248", "249", "250"} {
+		n := numeric
+		routes[n] = replyRoute{parse: func(args []string, trailing string) map[string]string {
+			entry := parse(args, trailing)
+			entry["numeric"] = n
+			return entry
+		}}
+	}
+	return routes
+}
+
+// routeReply feeds a numeric reply into the pending requests waiting for it
+func (c *Client) routeReply(cmd string, args []string, trailing string) {
+	for reqType, spec := range requestSpecs {
+		route, ok := spec.routes[cmd]
+		if !ok {
+			continue
+		}
+		var req *PendingRequest
+		if spec.targetArg >= 0 {
+			if len(args) <= spec.targetArg {
+				continue
+			}
+			req = c.findPendingRequestByTarget(reqType, args[spec.targetArg])
+		} else {
+			req = c.findPendingRequestByType(reqType)
+		}
+		if req == nil {
+			continue
+		}
+
+		if route.parse != nil {
+			if entry := route.parse(args, trailing); entry != nil {
+				req.Data = append(req.Data, entry)
+			}
+		}
+		var errMsg string
+		if route.err {
+			errMsg = trailing
+			if errMsg == "" {
+				errMsg = "error " + cmd
+			}
+		}
+		if route.end || route.err {
+			log.Printf("End of %s request %s - collected %d entries", reqType, req.ID, len(req.Data))
+			c.finishPendingRequest(req, errMsg)
+		}
+	}
+}
+
+// StartRequest registers a pending request and sends the command that
+// answers it, or queues it behind an untargeted request of the same type. It
+// returns the request ID to pass to GetRequestResult.
+func (c *Client) StartRequest(reqType, target, command string) string {
+	req, send := c.addPendingRequest(reqType, target, command)
+	if send {
+		c.raw(command)
+	}
+	return req.ID
+}
+
+// Whowas initiates a WHOWAS query for a nick and returns a request ID. A
+// count of zero lets the server decide how many records to return.
+func (c *Client) Whowas(nick string, count int) string {
+	command := "WHOWAS " + nick
+	if count > 0 {
+		command = fmt.Sprintf("WHOWAS %s %d", nick, count)
+	}
+	return c.StartRequest("whowas", nick, command)
+}
+
+// whowasRecords groups WHOWAS entries into one record per RPL_WHOWASUSER,
+// attaching the server and account replies that follow it
+func whowasRecords(data []map[string]string) []map[string]string {
+	records := make([]map[string]string, 0)
+	var current map[string]string
+	for _, entry := range data {
+		switch entry["type"] {
+		case "user":
+			current = map[string]string{
+				"nick":      entry["nick"],
+				"user":      entry["user"],
+				"host":      entry["host"],
+				"real_name": entry["real_name"],
+			}
+			records = append(records, current)
+		case "server":
+			if current != nil {
+				current["server"] = entry["server"]
+				current["signoff"] = entry["server_info"]
+			}
+		case "account":
+			if current != nil {
+				current["account"] = entry["account"]
+			}
+		}
+	}
+	return records
+}
+
+// Stats initiates a STATS query (e.g. "u" for uptime) and returns a request ID
+func (c *Client) Stats(query string) string {
+	return c.StartRequest("stats", query, "STATS "+query)
+}
+
+// Admin initiates an ADMIN command and returns a request ID
+func (c *Client) Admin(server string) string {
+	return c.StartRequest("admin", server, strings.TrimSpace("ADMIN "+server))
+}
+
+// Motd initiates a MOTD command and returns a request ID
+func (c *Client) Motd(server string) string {
+	return c.StartRequest("motd", server, strings.TrimSpace("MOTD "+server))
+}
+
+// awaitRequest waits for a pending request and writes the error response
+// when it fails or times out
+func (a *API) awaitRequest(w http.ResponseWriter, r *http.Request, requestID, what string) (*PendingRequest, bool) {
+	result, err := a.bot.GetRequestResultContext(r.Context(), requestID, 10*time.Second)
+	if err != nil {
+		writeRequestError(w, what, err)
+		return nil, false
+	}
+	return result, true
+}
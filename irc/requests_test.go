@@ -0,0 +1,227 @@
+package irc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsRequest(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	id := client.Stats("u")
+	if len(sent) != 1 || sent[0] != "STATS u" {
+		t.Errorf("Expected STATS command, got %v", sent)
+	}
+	client.handleLine(":irc.server.com 242 TestBot :Server Up 12 days, 3:04:05")
+	client.handleLine(":irc.server.com 219 TestBot u :End of /STATS report")
+
+	// The result is still available after completion
+	result, err := client.GetRequestResult(id, time.Second)
+	if err != nil {
+		t.Fatalf("Expected completed stats request, got %v", err)
+	}
+	if len(result.Data) != 1 || result.Data[0]["numeric"] != "242" || result.Data[0]["trailing"] != "Server Up 12 days, 3:04:05" {
+		t.Errorf("Unexpected stats data: %v", result.Data)
+	}
+}
+
+func TestAdminAndMotdRequests(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+
+	adminID := client.Admin("")
+	client.handleLine(":irc.server.com 256 TestBot irc.server.com :Administrative info")
+	client.handleLine(":irc.server.com 257 TestBot :Stockholm")
+	client.handleLine(":irc.server.com 258 TestBot :Example Network")
+	client.handleLine(":irc.server.com 259 TestBot :admin@example.com")
+	admin, err := client.GetRequestResult(adminID, time.Second)
+	if err != nil || len(admin.Data) != 4 || admin.Data[3]["info"] != "admin@example.com" {
+		t.Errorf("Unexpected admin result: %v (%v)", admin.Data, err)
+	}
+
+	motdID := client.Motd("")
+	client.handleLine(":irc.server.com 375 TestBot :- irc.server.com Message of the day -")
+	client.handleLine(":irc.server.com 372 TestBot :- Welcome!")
+	client.handleLine(":irc.server.com 372 TestBot :- Be nice.")
+	client.handleLine(":irc.server.com 376 TestBot :End of /MOTD command.")
+	motd, err := client.GetRequestResult(motdID, time.Second)
+	if err != nil || len(motd.Data) != 2 || motd.Data[1]["line"] != "Be nice." {
+		t.Errorf("Unexpected motd result: %v (%v)", motd.Data, err)
+	}
+}
+
+func TestRequestErrorNumeric(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+
+	id := client.Whois("ghost")
+	client.handleLine(":irc.server.com 401 TestBot ghost :No such nick/channel")
+
+	start := time.Now()
+	result, err := client.GetRequestResult(id, 5*time.Second)
+	if err == nil || err.Error() != "No such nick/channel" {
+		t.Errorf("Expected the error numeric's message, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Error numeric should complete the request without waiting for the timeout")
+	}
+	if result == nil || !result.Complete {
+		t.Error("Request should be complete after an error numeric")
+	}
+}
+
+func TestMotdEndpoint(t *testing.T) {
+	client := NewClient()
+	markConnected(client)
+	client.testRawCapture = func(s string) {
+		if s == "MOTD" {
+			go func() {
+				client.handleLine(":irc.server.com 375 TestBot :- irc.server.com Message of the day -")
+				client.handleLine(":irc.server.com 372 TestBot :- Hello")
+				client.handleLine(":irc.server.com 376 TestBot :End of /MOTD command.")
+			}()
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/motd", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	client.CreateAPI("token").ServeHTTP(rec, req)
+
+	var body struct {
+		Motd  []string `json:"motd"`
+		Count int      `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if rec.Code != 200 || strings.Join(body.Motd, "|") != "Hello" {
+		t.Errorf("Unexpected MOTD response %d: %+v", rec.Code, body)
+	}
+}
+
+func TestWhowasRequest(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	id := client.Whowas("Gone", 2)
+	if len(sent) != 1 || sent[0] != "WHOWAS Gone 2" {
+		t.Errorf("Expected WHOWAS command, got %v", sent)
+	}
+	client.handleLine(":irc.server.com 314 TestBot gone g1 host1.example * :First Record")
+	client.handleLine(":irc.server.com 312 TestBot gone irc.server.com :Mon Jan 1 10:00:00 2024")
+	client.handleLine(":irc.server.com 330 TestBot gone goneacct :was logged in as")
+	client.handleLine(":irc.server.com 314 TestBot gone g2 host2.example * :Second Record")
+	client.handleLine(":irc.server.com 369 TestBot gone :End of WHOWAS")
+
+	result, err := client.GetRequestResult(id, time.Second)
+	if err != nil {
+		t.Fatalf("Expected completed whowas request, got %v", err)
+	}
+	records := whowasRecords(result.Data)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %v", records)
+	}
+	if records[0]["host"] != "host1.example" || records[0]["server"] != "irc.server.com" || records[0]["account"] != "goneacct" {
+		t.Errorf("Unexpected first record: %v", records[0])
+	}
+	if records[1]["real_name"] != "Second Record" || records[1]["server"] != "" {
+		t.Errorf("Unexpected second record: %v", records[1])
+	}
+
+	id = client.Whowas("nobody", 0)
+	client.handleLine(":irc.server.com 406 TestBot nobody :There was no such nickname")
+	if _, err := client.GetRequestResult(id, time.Second); err == nil {
+		t.Error("Expected ERR_WASNOSUCHNICK to fail the request")
+	}
+}
+
+func TestPendingRequestTimeout(t *testing.T) {
+	old := pendingRequestTTL
+	pendingRequestTTL = 20 * time.Millisecond
+	defer func() { pendingRequestTTL = old }()
+	client := NewClient()
+	req := client.createPendingRequest("whois", "alice")
+
+	// The read loop looks requests up while the timeout ends them
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				client.findPendingRequestByType("whois")
+				client.findPendingRequestByTarget("whois", "alice")
+				client.findPendingWhoisRequest("alice")
+			}
+		}
+	}()
+	_, err := client.GetRequestResultContext(context.Background(), req.ID, time.Second)
+	close(stop)
+	<-done
+
+	if !errors.Is(err, errRequestTimeout) {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if !req.Complete || req.Error != "request timed out" {
+		t.Errorf("Expected the request to end with an error, got complete=%v error=%q", req.Complete, req.Error)
+	}
+}
+
+func TestConcurrentUntargetedRequests(t *testing.T) {
+	client := NewClient()
+	sent := make(chan string, 10)
+	client.testRawCapture = func(s string) { sent <- s }
+
+	// A fake server answers each LIST with the channel it asked for, once
+	// every request has started
+	start := make(chan struct{})
+	go func() {
+		<-start
+		for line := range sent {
+			ch := strings.TrimPrefix(line, "LIST ")
+			client.handleLine(":irc.server.com 322 TestBot " + ch + " 3 :topic")
+			client.handleLine(":irc.server.com 323 TestBot :End of /LIST")
+		}
+	}()
+	defer close(sent)
+
+	results := make(chan error, 5)
+	var started sync.WaitGroup
+	started.Add(5)
+	for i := range 5 {
+		go func() {
+			ch := "#chan" + string(rune('a'+i))
+			id := client.StartRequest("list", "", "LIST "+ch)
+			started.Done()
+			result, err := client.GetRequestResult(id, 2*time.Second)
+			switch {
+			case err != nil:
+				results <- err
+			case len(result.Data) != 1 || result.Data[0]["channel"] != ch:
+				results <- errors.New(ch + " got another request's replies")
+			default:
+				results <- nil
+			}
+		}()
+	}
+	started.Wait()
+	close(start)
+	for range 5 {
+		if err := <-results; err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// clone returns a deep copy of the channel state
+func (s *ChannelState) clone() *ChannelState {
+	stateCopy := *s
+	stateCopy.Users = make(map[string]string, len(s.Users))
+	for k, v := range s.Users {
+		stateCopy.Users[k] = v
+	}
+	stateCopy.BanList = make([]BanListEntry, len(s.BanList))
+	copy(stateCopy.BanList, s.BanList)
+	stateCopy.InviteList = make([]InviteListEntry, len(s.InviteList))
+	copy(stateCopy.InviteList, s.InviteList)
+	stateCopy.ExceptList = make([]ExceptListEntry, len(s.ExceptList))
+	copy(stateCopy.ExceptList, s.ExceptList)
+	stateCopy.ModeParams = make([]string, len(s.ModeParams))
+	copy(stateCopy.ModeParams, s.ModeParams)
+	if s.SpecialInfo != nil {
+		stateCopy.SpecialInfo = make(map[string]string, len(s.SpecialInfo))
+		for k, v := range s.SpecialInfo {
+			stateCopy.SpecialInfo[k] = v
+		}
+	}
+	return &stateCopy
+}
+
+// resyncLoop periodically resynchronizes all joined channels until done is closed
+func (c *Client) resyncLoop(done <-chan struct{}) {
+	log.Printf("State resync every %s", c.resyncInterval)
+	ticker := time.NewTicker(c.resyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.ResyncChannels()
+		}
+	}
+}
+
+// ResyncChannels re-issues MODE, TOPIC and NAMES for every joined channel.
+// Differences against the cached state are reported through a "state_resync"
+// trigger event once the NAMES reply completes.
+func (c *Client) ResyncChannels() []string {
+	channels := c.Channels()
+	for _, ch := range channels {
+		c.resyncChannel(ch)
+	}
+	return channels
+}
+
+func (c *Client) resyncChannel(channel string) {
+	key := strings.ToLower(channel)
+	c.channelStatesMu.Lock()
+	if state := c.channelStates[key]; state != nil {
+		c.resyncPending[key] = state.clone()
+	} else {
+		c.resyncPending[key] = &ChannelState{Name: key, Users: make(map[string]string)}
+	}
+	c.channelStatesMu.Unlock()
+
+	// Replies arrive in order, so MODE and TOPIC are settled by the time
+	// RPL_ENDOFNAMES closes the resync
+	c.rawf("MODE %s", channel)
+	c.rawf("TOPIC %s", channel)
+	c.rawf("NAMES %s", channel)
+}
+
+// recordNames remembers a nick seen in an RPL_NAMREPLY so that stale users
+// can be dropped when the reply completes
+func (c *Client) recordNames(channel, nick string) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+
+	key := strings.ToLower(channel)
+	if c.namesSeen[key] == nil {
+		c.namesSeen[key] = make(map[string]bool)
+	}
+	c.namesSeen[key][nick] = true
+}
+
+// finishNames reconciles the channel's user list against the completed NAMES
+// reply and reports the differences of a pending resync
+func (c *Client) finishNames(channel string) {
+	key := strings.ToLower(channel)
+
+	c.channelStatesMu.Lock()
+	state := c.channelStates[key]
+	if seen := c.namesSeen[key]; seen != nil && state != nil {
+		for nick := range state.Users {
+			if !seen[nick] {
+				delete(state.Users, nick)
+			}
+		}
+	}
+	delete(c.namesSeen, key)
+	if state != nil {
+		state.Stale = false
+	}
+
+	before, resyncing := c.resyncPending[key]
+	delete(c.resyncPending, key)
+	var changes []string
+	if resyncing && state != nil {
+		changes = diffChannelState(before, state)
+	}
+	c.channelStatesMu.Unlock()
+
+	if len(changes) > 0 {
+		message := fmt.Sprintf("Resync of %s: %d change(s): %s", channel, len(changes), strings.Join(changes, ", "))
+		log.Printf("%s", message)
+		c.sendTriggerEvent("state_resync", "", channel, message, strings.Join(changes, "\n"), nil)
+	}
+}
+
+// diffChannelState describes how a channel's users, topic and modes changed
+func diffChannelState(before, after *ChannelState) []string {
+	var changes []string
+	for nick, modes := range after.Users {
+		old, ok := before.Users[nick]
+		if !ok {
+			changes = append(changes, "+"+nick)
+		} else if old != modes {
+			changes = append(changes, fmt.Sprintf("%s modes %q -> %q", nick, old, modes))
+		}
+	}
+	for nick := range before.Users {
+		if _, ok := after.Users[nick]; !ok {
+			changes = append(changes, "-"+nick)
+		}
+	}
+	sort.Strings(changes)
+
+	if before.Topic != after.Topic {
+		changes = append(changes, fmt.Sprintf("topic %q -> %q", before.Topic, after.Topic))
+	}
+	if before.Modes != after.Modes {
+		changes = append(changes, fmt.Sprintf("channel modes %q -> %q", before.Modes, after.Modes))
+	}
+	return changes
+}
+
+// parseNamesEntry splits an RPL_NAMREPLY entry using the default prefixes
+func parseNamesEntry(name string) (nick, modes string) {
+	return defaultPrefixMap.parseNamesEntry(name)
+}
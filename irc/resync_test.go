@@ -0,0 +1,143 @@
+package irc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamesReconciliation(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.AddUserToChannel("#test", "TestBot", "")
+	client.AddUserToChannel("#test", "stale", "")
+	client.AddUserToChannel("#test", "alice", "")
+
+	client.handleLine(":irc.server.com 353 TestBot = #test :TestBot @alice bob")
+	client.handleLine(":irc.server.com 366 TestBot #test :End of /NAMES list.")
+
+	expected := map[string]interface{}{
+		"TestBot": nil,
+		"alice":   "o",
+		"bob":     nil,
+	}
+	if got := client.GetChannelStates()["#test"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v after NAMES, got %v", expected, got)
+	}
+}
+
+func TestResyncChannel(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":TestBot!u@h JOIN #test")
+	client.handleLine(":irc.server.com 353 TestBot = #test :TestBot alice")
+	client.handleLine(":irc.server.com 366 TestBot #test :End of /NAMES list.")
+
+	sent = nil
+	client.ResyncChannels()
+	if want := []string{"MODE #test", "TOPIC #test", "NAMES #test"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("Expected resync commands %v, got %v", want, sent)
+	}
+
+	client.channelStatesMu.RLock()
+	_, pending := client.resyncPending["#test"]
+	client.channelStatesMu.RUnlock()
+	if !pending {
+		t.Fatal("Expected a pending resync snapshot")
+	}
+
+	client.handleLine(":irc.server.com 324 TestBot #test +nt")
+	client.handleLine(":irc.server.com 353 TestBot = #test :TestBot +bob")
+	client.handleLine(":irc.server.com 366 TestBot #test :End of /NAMES list.")
+
+	client.channelStatesMu.RLock()
+	_, pending = client.resyncPending["#test"]
+	client.channelStatesMu.RUnlock()
+	if pending {
+		t.Error("Resync snapshot should be cleared after RPL_ENDOFNAMES")
+	}
+
+	users := client.GetChannelStates()["#test"]
+	if _, ok := users["alice"]; ok {
+		t.Error("alice should have been removed by the resync")
+	}
+	if users["bob"] != "v" {
+		t.Errorf("Expected bob with voice, got %v", users["bob"])
+	}
+}
+
+func TestDiffChannelState(t *testing.T) {
+	before := &ChannelState{
+		Users: map[string]string{"alice": "", "bob": "o"},
+		Topic: "old",
+		Modes: "+nt",
+	}
+	after := &ChannelState{
+		Users: map[string]string{"bob": "", "carol": "v"},
+		Topic: "new",
+		Modes: "+nt",
+	}
+
+	changes := diffChannelState(before, after)
+	joined := strings.Join(changes, "|")
+	for _, want := range []string{"+carol", "-alice", `bob modes "o" -> ""`, `topic "old" -> "new"`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected change %q in %v", want, changes)
+		}
+	}
+	if strings.Contains(joined, "channel modes") {
+		t.Errorf("Unchanged channel modes should not be reported: %v", changes)
+	}
+}
+
+func TestParseNamesEntry(t *testing.T) {
+	tests := []struct{ name, nick, modes string }{
+		{"alice", "alice", ""},
+		{"@alice", "alice", "o"},
+		{"@+alice", "alice", "ov"},
+		{"~&@%+alice", "alice", "qaohv"},
+		{"+bob!b@host.example", "bob", "v"},
+	}
+	for _, tt := range tests {
+		nick, modes := parseNamesEntry(tt.name)
+		if nick != tt.nick || modes != tt.modes {
+			t.Errorf("parseNamesEntry(%q) = %q, %q; want %q, %q", tt.name, nick, modes, tt.nick, tt.modes)
+		}
+	}
+}
+
+func TestNamesRequest(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	req := client.getPendingRequest(client.Names("#Test"))
+	if req == nil {
+		t.Fatal("Expected a pending names request")
+	}
+	if len(sent) != 1 || sent[0] != "NAMES #Test" {
+		t.Errorf("Expected NAMES command, got %v", sent)
+	}
+
+	client.handleLine(":irc.server.com 353 TestBot = #other :carol")
+	client.handleLine(":irc.server.com 353 TestBot = #test :TestBot @+alice")
+	client.handleLine(":irc.server.com 353 TestBot = #test :%bob")
+	client.handleLine(":irc.server.com 366 TestBot #test :End of /NAMES list.")
+
+	if !req.Complete {
+		t.Fatal("NAMES request should be complete after RPL_ENDOFNAMES")
+	}
+	if len(req.Data) != 3 {
+		t.Fatalf("Expected 3 users from #test only, got %v", req.Data)
+	}
+	alice := req.Data[1]
+	if alice["nick"] != "alice" || alice["modes"] != "ov" || alice["prefix"] != "@+" {
+		t.Errorf("Unexpected entry for alice: %v", alice)
+	}
+}
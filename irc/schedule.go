@@ -0,0 +1,198 @@
+package irc
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Schedule is a message sent once after a delay or repeatedly on a cron
+// expression
+type Schedule struct {
+	ID        string    `json:"id"`
+	Target    string    `json:"target"`
+	Message   string    `json:"message"`
+	Cron      string    `json:"cron,omitempty"`  // empty for one-shot schedules
+	Kind      string    `json:"kind,omitempty"`  // "reminder" for !remind entries, empty otherwise
+	Owner     string    `json:"owner,omitempty"` // account or nick that created it, if any
+	NextRun   time.Time `json:"next_run"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddSchedule registers a schedule. Exactly one of delay and cronExpr must be
+// set; the new schedule is persisted to SCHEDULE_FILE when configured.
+func (c *Client) AddSchedule(target, message string, delay time.Duration, cronExpr, owner string) (*Schedule, error) {
+	return c.addSchedule("", target, message, delay, cronExpr, owner)
+}
+
+func (c *Client) addSchedule(kind, target, message string, delay time.Duration, cronExpr, owner string) (*Schedule, error) {
+	now := time.Now()
+	s := &Schedule{
+		ID:        "sched_" + newRequestID(),
+		Target:    target,
+		Message:   message,
+		Kind:      kind,
+		Owner:     owner,
+		CreatedAt: now,
+	}
+
+	switch {
+	case cronExpr != "" && delay != 0:
+		return nil, errors.New("use either a delay or a cron expression, not both")
+	case cronExpr != "":
+		spec, err := parseCron(cronExpr)
+		if err != nil {
+			return nil, err
+		}
+		s.Cron = cronExpr
+		s.NextRun = spec.Next(now)
+		if s.NextRun.IsZero() {
+			return nil, errors.New("cron expression never matches")
+		}
+	case delay > 0:
+		s.NextRun = now.Add(delay)
+	default:
+		return nil, errors.New("a positive delay or a cron expression is required")
+	}
+
+	c.schedulesMu.Lock()
+	c.schedules[s.ID] = s
+	c.schedulesMu.Unlock()
+	c.saveSchedules()
+
+	log.Printf("Scheduled %s for %s at %s", s.ID, target, s.NextRun.Format(time.RFC3339))
+	copied := *s
+	return &copied, nil
+}
+
+// RemoveSchedule deletes a schedule, reporting whether it existed
+func (c *Client) RemoveSchedule(id string) bool {
+	c.schedulesMu.Lock()
+	_, ok := c.schedules[id]
+	delete(c.schedules, id)
+	c.schedulesMu.Unlock()
+	if ok {
+		c.saveSchedules()
+	}
+	return ok
+}
+
+// Schedules returns all pending schedules ordered by their next run
+func (c *Client) Schedules() []Schedule {
+	c.schedulesMu.Lock()
+	out := make([]Schedule, 0, len(c.schedules))
+	for _, s := range c.schedules {
+		out = append(out, *s)
+	}
+	c.schedulesMu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].NextRun.Before(out[j].NextRun) })
+	return out
+}
+
+// runDueSchedules sends every schedule whose time has come. One-shot
+// schedules are removed; cron schedules move to their next run. Runs missed
+// while disconnected are sent once on reconnect.
+func (c *Client) runDueSchedules(now time.Time) {
+	var due []Schedule
+	changed := false
+
+	c.schedulesMu.Lock()
+	for id, s := range c.schedules {
+		if s.NextRun.After(now) {
+			continue
+		}
+		due = append(due, *s)
+		changed = true
+		if s.Cron == "" {
+			delete(c.schedules, id)
+			continue
+		}
+		spec, err := parseCron(s.Cron)
+		if err == nil {
+			s.NextRun = spec.Next(now)
+		}
+		if err != nil || s.NextRun.IsZero() {
+			delete(c.schedules, id)
+		}
+	}
+	c.schedulesMu.Unlock()
+
+	if !changed {
+		return
+	}
+	c.saveSchedules()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].NextRun.Before(due[j].NextRun) })
+	for _, s := range due {
+		log.Printf("Running schedule %s for %s", s.ID, s.Target)
+		c.Privmsg(s.Target, s.Message)
+	}
+}
+
+// scheduleLoop checks for due schedules every second until done is closed
+func (c *Client) scheduleLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			c.runDueSchedules(now)
+		}
+	}
+}
+
+// loadSchedules restores the schedules persisted in SCHEDULE_FILE
+func (c *Client) loadSchedules() {
+	if c.scheduleFile == "" {
+		return
+	}
+	data, err := os.ReadFile(c.scheduleFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading schedules from %s: %v", c.scheduleFile, err)
+		return
+	}
+	var list []*Schedule
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("Error parsing schedules from %s: %v", c.scheduleFile, err)
+		return
+	}
+
+	c.schedulesMu.Lock()
+	for _, s := range list {
+		c.schedules[s.ID] = s
+	}
+	c.schedulesMu.Unlock()
+	log.Printf("Loaded %d schedule(s) from %s", len(list), c.scheduleFile)
+}
+
+// saveSchedules writes all schedules to SCHEDULE_FILE, replacing it atomically
+func (c *Client) saveSchedules() {
+	if c.scheduleFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(c.Schedules(), "", "  ")
+	if err != nil {
+		log.Printf("Error encoding schedules: %v", err)
+		return
+	}
+
+	c.scheduleSaveMu.Lock()
+	defer c.scheduleSaveMu.Unlock()
+	tmp := c.scheduleFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Error writing schedules to %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, c.scheduleFile); err != nil {
+		log.Printf("Error saving schedules to %s: %v", c.scheduleFile, err)
+	}
+}
@@ -0,0 +1,113 @@
+package irc
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC) // a Monday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 12 29 2 *", time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := spec.Next(base); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("parseCron(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	client := NewClient()
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	once, err := client.AddSchedule("#test", "once", time.Minute, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly, err := client.AddSchedule("#test", "hourly", 0, "0 * * * *", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddSchedule("#test", "both", time.Minute, "0 * * * *", ""); err == nil {
+		t.Error("A delay and a cron expression together should be rejected")
+	}
+
+	client.runDueSchedules(time.Now())
+	if len(sent) != 0 {
+		t.Fatalf("Nothing should be due yet, sent %v", sent)
+	}
+
+	later := hourly.NextRun.Add(time.Second)
+	if later.Before(once.NextRun) {
+		later = once.NextRun.Add(time.Second)
+	}
+	client.runDueSchedules(later)
+	want := []string{"PRIVMSG #test :once", "PRIVMSG #test :hourly"}
+	if hourly.NextRun.Before(once.NextRun) {
+		want[0], want[1] = want[1], want[0]
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("Expected %v in order of their run time, sent %v", want, sent)
+	}
+
+	remaining := client.Schedules()
+	if len(remaining) != 1 || remaining[0].ID != hourly.ID || !remaining[0].NextRun.After(later) {
+		t.Errorf("Expected only the rescheduled cron entry, got %+v", remaining)
+	}
+}
+
+func TestScheduleIDsAreUnique(t *testing.T) {
+	client := NewClient()
+	for range 100 {
+		if _, err := client.AddSchedule("#test", "tick", time.Minute, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(client.Schedules()); n != 100 {
+		t.Errorf("Expected 100 schedules created back to back, got %d", n)
+	}
+}
+
+func TestSchedulePersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "schedules.json")
+	t.Setenv("SCHEDULE_FILE", file)
+
+	client := NewClient()
+	s, err := client.AddSchedule("#test", "hello", time.Hour, "", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewClient().Schedules()
+	if len(restored) != 1 || restored[0].ID != s.ID || restored[0].Owner != "alice" || !restored[0].NextRun.Equal(s.NextRun) {
+		t.Fatalf("Expected the schedule to be restored, got %+v", restored)
+	}
+
+	client.RemoveSchedule(s.ID)
+	if restored := NewClient().Schedules(); len(restored) != 0 {
+		t.Errorf("Removed schedule should not be restored, got %+v", restored)
+	}
+}
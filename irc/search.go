@@ -0,0 +1,298 @@
+package irc
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// searchableTypes are the log entry types whose text is indexed
+var searchableTypes = map[string]bool{"privmsg": true, "action": true, "notice": true, "topic": true}
+
+// SearchResult is a matching log entry with its neighbouring channel messages
+type SearchResult struct {
+	Entry  LogEntry   `json:"entry"`
+	Score  float64    `json:"score"`
+	Before []LogEntry `json:"before"`
+	After  []LogEntry `json:"after"`
+}
+
+// SearchQuery narrows a search; zero values are unrestricted
+type SearchQuery struct {
+	Text    string
+	Channel string
+	Nick    string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Context int // messages of context on each side of a match
+}
+
+// searchIndex is an in-memory inverted index over recent channel messages
+type searchIndex struct {
+	mu       sync.RWMutex
+	days     int              // messages older than this are evicted
+	docs     []LogEntry       // doc ID -> entry
+	terms    []map[string]int // doc ID -> term frequencies
+	postings map[string][]int // term -> doc IDs (ascending)
+	channels map[string][]int // channel (lowercase) -> doc IDs in log order
+	position []int            // doc ID -> index into its channel's list
+	pruned   string           // date of the last eviction
+}
+
+func newSearchIndex(days int) *searchIndex {
+	return &searchIndex{
+		days:     days,
+		postings: make(map[string][]int),
+		channels: make(map[string][]int),
+	}
+}
+
+// loadSearchIndex builds the search index from the JSONL channel logs when
+// logging is enabled and SEARCH_INDEX_DAYS is positive
+func (c *Client) loadSearchIndex() {
+	days := intenv("SEARCH_INDEX_DAYS", 30)
+	if c.chanlog == nil || days <= 0 {
+		return
+	}
+	idx := newSearchIndex(days)
+	if c.chanlog.jsonl {
+		cutoff := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+		dirs, err := os.ReadDir(c.chanlog.dir)
+		if err != nil {
+			log.Printf("Error reading CHANLOG_DIR %s: %v", c.chanlog.dir, err)
+		}
+		for _, d := range dirs {
+			if !d.IsDir() {
+				continue
+			}
+			files, _ := filepath.Glob(filepath.Join(c.chanlog.dir, d.Name(), "*.jsonl"))
+			sort.Strings(files)
+			for _, path := range files {
+				if strings.TrimSuffix(filepath.Base(path), ".jsonl") < cutoff {
+					continue
+				}
+				if err := readLogFile(path, func(e LogEntry) error {
+					idx.add(e)
+					return nil
+				}); err != nil {
+					log.Printf("Error indexing %s: %v", path, err)
+				}
+			}
+		}
+	}
+	log.Printf("Search index ready with %d message(s) from the last %d days", len(idx.docs), days)
+	c.search = idx
+}
+
+// tokenize splits text into lowercase words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// add indexes an entry if it carries searchable text
+func (idx *searchIndex) add(e LogEntry) {
+	cutoff := time.Now().AddDate(0, 0, -idx.days)
+	if !searchableTypes[e.Type] || e.Time.Before(cutoff) {
+		return
+	}
+	tf := make(map[string]int)
+	for _, term := range tokenize(e.Message) {
+		tf[term]++
+	}
+	if len(tf) == 0 {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if today := time.Now().Format("2006-01-02"); today != idx.pruned {
+		idx.pruned = today
+		idx.evict(cutoff)
+	}
+	idx.insert(e, tf)
+}
+
+func (idx *searchIndex) insert(e LogEntry, tf map[string]int) {
+	id := len(idx.docs)
+	idx.docs = append(idx.docs, e)
+	idx.terms = append(idx.terms, tf)
+	for term := range tf {
+		idx.postings[term] = append(idx.postings[term], id)
+	}
+	ch := strings.ToLower(e.Channel)
+	idx.position = append(idx.position, len(idx.channels[ch]))
+	idx.channels[ch] = append(idx.channels[ch], id)
+}
+
+// evict rebuilds the index without entries older than cutoff
+func (idx *searchIndex) evict(cutoff time.Time) {
+	docs, terms := idx.docs, idx.terms
+	idx.docs, idx.terms, idx.position = nil, nil, nil
+	idx.postings = make(map[string][]int)
+	idx.channels = make(map[string][]int)
+	for i, e := range docs {
+		if !e.Time.Before(cutoff) {
+			idx.insert(e, terms[i])
+		}
+	}
+	if dropped := len(docs) - len(idx.docs); dropped > 0 {
+		log.Printf("Evicted %d message(s) older than %s from the search index", dropped, cutoff.Format("2006-01-02"))
+	}
+}
+
+// Search returns entries containing every query term, best matches first.
+// Scores are summed TF-IDF weights; ties favour newer messages.
+func (idx *searchIndex) Search(q SearchQuery) []SearchResult {
+	terms := tokenize(q.Text)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Intersect postings starting from the rarest term
+	sort.Slice(terms, func(i, j int) bool { return len(idx.postings[terms[i]]) < len(idx.postings[terms[j]]) })
+	candidates := idx.postings[terms[0]]
+	for _, term := range terms[1:] {
+		candidates = intersectSorted(candidates, idx.postings[term])
+	}
+
+	n := float64(len(idx.docs))
+	var results []SearchResult
+	var ids []int
+	for _, id := range candidates {
+		e := idx.docs[id]
+		if q.Channel != "" && !strings.EqualFold(e.Channel, q.Channel) {
+			continue
+		}
+		if q.Nick != "" && !strings.EqualFold(e.Nick, q.Nick) {
+			continue
+		}
+		if (!q.From.IsZero() && e.Time.Before(q.From)) || (!q.To.IsZero() && e.Time.After(q.To)) {
+			continue
+		}
+		score := 0.0
+		for _, term := range terms {
+			idf := math.Log(1 + n/float64(len(idx.postings[term])))
+			score += float64(idx.terms[id][term]) * idf
+		}
+		results = append(results, SearchResult{Entry: e, Score: math.Round(score*1000) / 1000})
+		ids = append(ids, id)
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := results[order[i]], results[order[j]]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Entry.Time.After(b.Entry.Time)
+	})
+	if q.Limit > 0 && len(order) > q.Limit {
+		order = order[:q.Limit]
+	}
+
+	out := make([]SearchResult, 0, len(order))
+	for _, i := range order {
+		r := results[i]
+		r.Before, r.After = idx.context(ids[i], q.Context)
+		out = append(out, r)
+	}
+	return out
+}
+
+// context returns up to n channel messages around a document
+func (idx *searchIndex) context(id, n int) ([]LogEntry, []LogEntry) {
+	list := idx.channels[strings.ToLower(idx.docs[id].Channel)]
+	pos := idx.position[id]
+	before := make([]LogEntry, 0, n)
+	for _, other := range list[max(0, pos-n):pos] {
+		before = append(before, idx.docs[other])
+	}
+	after := make([]LogEntry, 0, n)
+	for _, other := range list[pos+1 : min(len(list), pos+1+n)] {
+		after = append(after, idx.docs[other])
+	}
+	return before, after
+}
+
+func intersectSorted(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// handleSearch serves GET /api/search?q=...&channel=...&nick=...&from=...&to=...
+func (a *API) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if a.bot.search == nil {
+		writeError(w, 503, codeUnavailable, "search requires CHANLOG_DIR and SEARCH_INDEX_DAYS > 0")
+		return
+	}
+	params := r.URL.Query()
+	q := SearchQuery{
+		Text:    params.Get("q"),
+		Channel: params.Get("channel"),
+		Nick:    params.Get("nick"),
+		Limit:   20,
+		Context: 2,
+	}
+	if len(tokenize(q.Text)) == 0 {
+		writeError(w, 400, codeInvalidRequest, "q required")
+		return
+	}
+	for name, bound := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := params.Get(name); v != "" {
+			t, err := parseTimeParam(v)
+			if err != nil {
+				writeError(w, 400, codeInvalidRequest, err.Error())
+				return
+			}
+			*bound = t
+		}
+	}
+	for name, dst := range map[string]*int{"limit": &q.Limit, "context": &q.Context} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, 400, codeInvalidRequest, name+" must be a non-negative integer")
+				return
+			}
+			*dst = n
+		}
+	}
+	q.Limit = min(max(q.Limit, 1), 100)
+	q.Context = min(q.Context, 10)
+
+	results := a.bot.search.Search(q)
+	writeJSON(w, 200, map[string]any{
+		"query":   q.Text,
+		"results": results,
+		"count":   len(results),
+	})
+}
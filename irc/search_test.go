@@ -0,0 +1,88 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSearchIndex(t *testing.T) {
+	idx := newSearchIndex(30)
+	now := time.Now()
+	msgs := []struct{ channel, nick, text string }{
+		{"#test", "alice", "the server is down again"},
+		{"#test", "bob", "which server?"},
+		{"#test", "alice", "the build server, restart the build server please"},
+		{"#test", "bob", "done"},
+		{"#other", "carol", "server maintenance tonight"},
+	}
+	for i, m := range msgs {
+		idx.add(LogEntry{Time: now.Add(time.Duration(i) * time.Minute), Channel: m.channel, Type: "privmsg", Nick: m.nick, Message: m.text})
+	}
+	idx.add(LogEntry{Time: now, Channel: "#test", Type: "join", Nick: "dave"})
+	idx.add(LogEntry{Time: now.AddDate(0, 0, -31), Channel: "#test", Type: "privmsg", Nick: "old", Message: "ancient server"})
+
+	results := idx.Search(SearchQuery{Text: "Server", Channel: "#TEST", Context: 1})
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 matches in #test, got %+v", results)
+	}
+	best := results[0]
+	if best.Entry.Nick != "alice" || best.Entry.Message != msgs[2].text {
+		t.Errorf("Expected the message repeating the term to rank first, got %+v", best.Entry)
+	}
+	if len(best.Before) != 1 || best.Before[0].Message != "which server?" || len(best.After) != 1 || best.After[0].Message != "done" {
+		t.Errorf("Unexpected context: before %+v after %+v", best.Before, best.After)
+	}
+	if results[1].Entry.Time.Before(results[2].Entry.Time) {
+		t.Errorf("Equal scores should list newer messages first")
+	}
+
+	if results := idx.Search(SearchQuery{Text: "build restart", Nick: "alice"}); len(results) != 1 {
+		t.Errorf("Expected all terms to be required, got %+v", results)
+	}
+	if results := idx.Search(SearchQuery{Text: "server", From: now.Add(3 * time.Minute)}); len(results) != 1 || results[0].Entry.Channel != "#other" {
+		t.Errorf("Expected time filtering, got %+v", results)
+	}
+	if results := idx.Search(SearchQuery{Text: "ancient"}); len(results) != 0 {
+		t.Errorf("Entries older than the index window should be evicted, got %+v", results)
+	}
+}
+
+func TestSearchAPI(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHANLOG_DIR", dir)
+	t.Setenv("CHANLOG_FORMATS", "jsonl")
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	client.handleLine(":alice!a@host PRIVMSG #test :deploy finished")
+	client.chanlog.Close()
+
+	// A fresh client rebuilds the index from the JSONL logs
+	api := NewClient().CreateAPI("token")
+	req := httptest.NewRequest("GET", "/api/search?q=deploy&channel=%23test", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	var resp struct {
+		Results []SearchResult `json:"results"`
+		Count   int            `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if rec.Code != 200 || resp.Count != 1 || resp.Results[0].Entry.Nick != "alice" {
+		t.Errorf("Unexpected search response %d %+v", rec.Code, resp)
+	}
+
+	req = httptest.NewRequest("GET", "/api/search?q=%20", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Errorf("Expected 400 for an empty query, got %d", rec.Code)
+	}
+}
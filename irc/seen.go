@@ -0,0 +1,234 @@
+package irc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// SeenRecord is the last thing a user was seen doing
+type SeenRecord struct {
+	Nick    string    `json:"nick"`
+	Account string    `json:"account,omitempty"`
+	Action  string    `json:"action"` // privmsg, action, join, part, quit, nick or kick
+	Channel string    `json:"channel,omitempty"`
+	Target  string    `json:"target,omitempty"` // new nick for nick changes, kicker for kicks
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// seenData is the SEEN_FILE document
+type seenData struct {
+	Nicks    map[string]*SeenRecord `json:"nicks"`    // nick (lowercase) -> record
+	Accounts map[string]*SeenRecord `json:"accounts"` // account (lowercase) -> record
+}
+
+// recordSeen stores a user's latest activity by nick and, when known, by
+// services account. Private messages are never recorded.
+func (c *Client) recordSeen(nick, action, channel, target, message string, tags map[string]string) {
+	if nick == "" || strings.EqualFold(nick, c.Nick()) {
+		return
+	}
+	r := &SeenRecord{
+		Nick:    nick,
+		Account: c.senderAccount(nick, tags),
+		Action:  action,
+		Channel: channel,
+		Target:  target,
+		Message: message,
+		Time:    messageTime(tags),
+	}
+
+	c.seenMu.Lock()
+	defer c.seenMu.Unlock()
+	if c.seen.Nicks == nil {
+		c.seen = seenData{Nicks: make(map[string]*SeenRecord), Accounts: make(map[string]*SeenRecord)}
+	}
+	c.seen.Nicks[strings.ToLower(nick)] = r
+	if r.Account != "" {
+		c.seen.Accounts[strings.ToLower(r.Account)] = r
+	}
+	c.seenDirty = true
+}
+
+// SeenNick returns the last activity of a nick, or nil if never seen
+func (c *Client) SeenNick(nick string) *SeenRecord {
+	c.seenMu.RLock()
+	defer c.seenMu.RUnlock()
+	if r := c.seen.Nicks[strings.ToLower(nick)]; r != nil {
+		copied := *r
+		return &copied
+	}
+	return nil
+}
+
+// SeenAccount returns the last activity of a services account, or nil
+func (c *Client) SeenAccount(account string) *SeenRecord {
+	c.seenMu.RLock()
+	defer c.seenMu.RUnlock()
+	if r := c.seen.Accounts[strings.ToLower(account)]; r != nil {
+		copied := *r
+		return &copied
+	}
+	return nil
+}
+
+// describe renders a record as e.g. "in #chan saying: hi"
+func (r *SeenRecord) describe() string {
+	switch r.Action {
+	case "privmsg":
+		if text, ok := ctcpAction(r.Message); ok {
+			return fmt.Sprintf("in %s: * %s %s", r.Channel, r.Nick, text)
+		}
+		return fmt.Sprintf("in %s saying: %s", r.Channel, r.Message)
+	case "action":
+		return fmt.Sprintf("in %s: * %s %s", r.Channel, r.Nick, r.Message)
+	case "join":
+		return fmt.Sprintf("joining %s", r.Channel)
+	case "part":
+		if r.Message != "" {
+			return fmt.Sprintf("leaving %s (%s)", r.Channel, r.Message)
+		}
+		return fmt.Sprintf("leaving %s", r.Channel)
+	case "quit":
+		return fmt.Sprintf("quitting (%s)", r.Message)
+	case "nick":
+		return fmt.Sprintf("changing nick to %s", r.Target)
+	case "kick":
+		return fmt.Sprintf("being kicked from %s by %s (%s)", r.Channel, r.Target, r.Message)
+	default:
+		return r.Action
+	}
+}
+
+func (c *Client) registerSeenCommand() {
+	c.RegisterCommand(Command{
+		Name:  "seen",
+		Usage: "seen <nick>",
+		Handler: func(ctx *CommandContext) {
+			if len(ctx.Args) == 0 {
+				ctx.Replyf("%s: usage: %sseen <nick>", ctx.Sender, c.commandPrefix)
+				return
+			}
+			nick := ctx.Args[0]
+			switch {
+			case strings.EqualFold(nick, ctx.Sender):
+				ctx.Replyf("%s: that's you!", ctx.Sender)
+				return
+			case strings.EqualFold(nick, c.Nick()):
+				ctx.Replyf("%s: I'm right here.", ctx.Sender)
+				return
+			}
+			r := c.SeenNick(nick)
+			if r == nil {
+				ctx.Replyf("%s: I haven't seen %s.", ctx.Sender, nick)
+				return
+			}
+			ago := time.Since(r.Time).Round(time.Second)
+			ctx.Replyf("%s: %s was last seen %s ago (%s) %s", ctx.Sender, r.Nick, ago, r.Time.Local().Format("2006-01-02 15:04 MST"), r.describe())
+		},
+	})
+}
+
+// seenSaveLoop writes changed seen records to SEEN_FILE every minute and once
+// more when done is closed
+func (c *Client) seenSaveLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			c.saveSeen()
+			return
+		case <-ticker.C:
+			c.saveSeen()
+		}
+	}
+}
+
+// loadSeen restores the seen records persisted in SEEN_FILE
+func (c *Client) loadSeen() {
+	if c.seenFile == "" {
+		return
+	}
+	data, err := os.ReadFile(c.seenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading seen records from %s: %v", c.seenFile, err)
+		return
+	}
+	var loaded seenData
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		log.Printf("Error parsing seen records from %s: %v", c.seenFile, err)
+		return
+	}
+
+	c.seenMu.Lock()
+	for k, r := range loaded.Nicks {
+		c.seen.Nicks[k] = r
+	}
+	for k, r := range loaded.Accounts {
+		c.seen.Accounts[k] = r
+	}
+	c.seenMu.Unlock()
+	log.Printf("Loaded %d seen record(s) from %s", len(loaded.Nicks), c.seenFile)
+}
+
+// saveSeen writes seen records to SEEN_FILE if they changed since the last save
+func (c *Client) saveSeen() {
+	if c.seenFile == "" {
+		return
+	}
+	c.seenMu.Lock()
+	if !c.seenDirty {
+		c.seenMu.Unlock()
+		return
+	}
+	data, err := json.Marshal(c.seen)
+	c.seenDirty = false
+	c.seenMu.Unlock()
+	if err != nil {
+		log.Printf("Error encoding seen records: %v", err)
+		return
+	}
+
+	tmp := c.seenFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Error writing seen records to %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, c.seenFile); err != nil {
+		log.Printf("Error saving seen records to %s: %v", c.seenFile, err)
+	}
+}
+
+// handleSeen serves GET /api/seen?nick=... or ?account=...
+func (a *API) handleSeen(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var record *SeenRecord
+	switch {
+	case q.Get("nick") != "":
+		record = a.bot.SeenNick(q.Get("nick"))
+	case q.Get("account") != "":
+		record = a.bot.SeenAccount(q.Get("account"))
+	default:
+		writeError(w, 400, codeInvalidRequest, "nick or account required")
+		return
+	}
+	if record == nil {
+		writeError(w, 404, codeNotFound, "not seen")
+		return
+	}
+	writeJSON(w, 200, map[string]any{
+		"seen":        record,
+		"description": record.describe(),
+		"ago_seconds": int64(time.Since(record.Time).Seconds()),
+	})
+}
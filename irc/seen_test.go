@@ -0,0 +1,66 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSeenCommand(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine("@account=alice_acct :alice!a@host PRIVMSG #test :see you later")
+	client.handleLine(":alice!a@host PRIVMSG TestBot :this is private")
+	client.handleLine(":bob!b@host PRIVMSG #test :!seen alice")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "PRIVMSG #test :bob: alice was last seen ") || !strings.HasSuffix(sent[0], " in #test saying: see you later") {
+		t.Errorf("Expected alice's last channel message, got %v", sent)
+	}
+
+	client.handleLine(":alice!a@host QUIT :Ping timeout")
+	sent = nil
+	client.handleLine(":bob!b@host PRIVMSG #test :!seen ALICE")
+	if len(sent) != 1 || !strings.HasSuffix(sent[0], " quitting (Ping timeout)") {
+		t.Errorf("Expected alice's quit, got %v", sent)
+	}
+
+	sent = nil
+	client.handleLine(":bob!b@host PRIVMSG #test :!seen carol")
+	if len(sent) != 1 || sent[0] != "PRIVMSG #test :bob: I haven't seen carol." {
+		t.Errorf("Expected unknown nick reply, got %v", sent)
+	}
+
+	if r := client.SeenAccount("ALICE_ACCT"); r == nil || r.Nick != "alice" || r.Action != "privmsg" {
+		t.Errorf("Expected the account to be tracked, got %+v", r)
+	}
+}
+
+func TestSeenPersistenceAndAPI(t *testing.T) {
+	t.Setenv("SEEN_FILE", filepath.Join(t.TempDir(), "seen.json"))
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	client.handleLine(":alice!a@host JOIN #test")
+	client.saveSeen()
+
+	api := NewClient().CreateAPI("token")
+	req := httptest.NewRequest("GET", "/api/seen?nick=Alice", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"description":"joining #test"`) {
+		t.Errorf("Expected the restored record, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/seen?nick=nobody", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 404 {
+		t.Errorf("Expected 404 for an unseen nick, got %d", rec.Code)
+	}
+}
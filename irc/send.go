@@ -0,0 +1,214 @@
+package irc
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func (c *Client) Join(channel string) { c.rawf("JOIN %s", channel) }
+
+func (c *Client) Part(channel string, reason string) {
+	if reason == "" {
+		c.rawf("PART %s", channel)
+	} else {
+		c.rawf("PART %s :%s", channel, reason)
+	}
+}
+
+// messageSender posts messages to IRC, as inbound webhooks do
+type messageSender interface {
+	Privmsg(target, msg string) error
+	Notice(target, msg string) error
+}
+
+// Privmsg sends a message, split into lines and chunks that fit the server's
+// line length; it stops at the first line that cannot be written and
+// returns the error
+func (c *Client) Privmsg(target, msg string) error { return c.privmsg("", target, msg) }
+
+// privmsg sends a message with tags ("@key=value " or empty) on every line
+func (c *Client) privmsg(tags, target, msg string) error {
+	return c.sendLines("PRIVMSG", tags, "", target, msg)
+}
+
+// sendLines sends a PRIVMSG or NOTICE like privmsg, with a labeled-response
+// label on the first line only, since a label names one command
+func (c *Client) sendLines(cmd, tags, label, target, msg string) error {
+	maxMsgLen := c.messageLen(cmd, target)
+	c.stopTyping(target)
+	c.dmSent(target)
+	tags = c.withBotTag(tags)
+	maxLines := c.maxLinesFor(target)
+	lines := strings.Split(msg, "\n")
+	line := func(text string) error {
+		t := tags
+		if label != "" {
+			t, label = withLabel(tags, label), ""
+		}
+		return c.rawf("%s%s %s :%s", t, cmd, target, text)
+	}
+	send := func(text string) error {
+		for len(text) > 0 {
+			chunk := text
+			if len(chunk) > maxMsgLen {
+				chunk = truncateUTF8(chunk, maxMsgLen)
+			}
+			if err := line(chunk); err != nil {
+				return err
+			}
+			text = text[len(chunk):]
+		}
+		return nil
+	}
+	sendFirst := func() error {
+		for i := 0; i < maxLines && i < len(lines); i++ {
+			if err := send(lines[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Check if flood protection should be applied
+	if c.isFloodProtectedChannel(target) && len(lines) > maxLines {
+		// Check if paste service is configured
+		if strings.TrimSpace(c.pasteCurlTemplate) == "" {
+			// No paste service configured, just truncate
+			if err := sendFirst(); err != nil {
+				return err
+			}
+			return line(fmt.Sprintf("... (truncated %d lines - configure PASTE_CURL_TEMPLATE to enable pasting)", len(lines)-maxLines))
+		}
+
+		// Create paste and send URL instead
+		url, err := c.createPaste(msg)
+		if err != nil {
+			log.Printf("Failed to create paste for flood protection: %v", err)
+			// Fall back to sending first few lines + truncation message
+			if err := sendFirst(); err != nil {
+				return err
+			}
+			return line(fmt.Sprintf("... (truncated %d lines - paste creation failed)", len(lines)-maxLines))
+		}
+
+		// Send first few lines plus paste URL
+		if err := sendFirst(); err != nil {
+			return err
+		}
+		return line("... full output: " + url)
+	}
+
+	// Normal message sending (no flood protection)
+	for _, text := range lines {
+		if err := send(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notice sends a notice, split into chunks that fit the server's line length
+func (c *Client) Notice(target, msg string) error {
+	tags, limit := c.withBotTag(""), c.messageLen("NOTICE", target)
+	for first := true; first || len(msg) > 0; first = false {
+		chunk := truncateUTF8(msg, limit)
+		if err := c.rawf("%sNOTICE %s :%s", tags, target, chunk); err != nil {
+			return err
+		}
+		msg = msg[len(chunk):]
+	}
+	return nil
+}
+
+// Action sends a CTCP ACTION (/me), one for each line of text; long lines
+// become several actions that fit the server's line length
+func (c *Client) Action(target, text string) error {
+	c.stopTyping(target)
+	c.dmSent(target)
+	tags, limit := c.withBotTag(""), c.messageLen("PRIVMSG", target)-len("\x01ACTION \x01")
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > 0 {
+			chunk := truncateUTF8(line, limit)
+			if err := c.rawf("%sPRIVMSG %s :\x01ACTION %s\x01", tags, target, chunk); err != nil {
+				return err
+			}
+			line = line[len(chunk):]
+		}
+	}
+	return nil
+}
+
+// SetNick changes the bot's nick, after removing invalid characters. A nick
+// longer than the server's NICKLEN is refused.
+func (c *Client) SetNick(n string) error {
+	sanitized := sanitizeNick(n)
+	if err := c.checkLength("nick", "NICKLEN", sanitized); err != nil {
+		return err
+	}
+	c.wantNick(sanitized)
+	return c.rawf("NICK %s", sanitized)
+}
+
+func (c *Client) createPaste(content string) (string, error) {
+	templatePath := "templates/paste.html"
+	// Try current directory first, then parent directory for tests
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		templatePath = "../templates/paste.html"
+		tmpl, err = template.ParseFiles(templatePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse template: %w", err)
+		}
+	}
+
+	tempFile, err := os.CreateTemp("", "paste_*.html")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	data := struct{ Content string }{Content: content}
+	if err := tmpl.Execute(tempFile, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	curlCmd := strings.ReplaceAll(c.pasteCurlTemplate, "{{filename}}", tempFile.Name())
+	if strings.TrimSpace(curlCmd) == "" {
+		return "", fmt.Errorf("invalid curl template")
+	}
+
+	// Use shell execution to properly handle quoted arguments
+	cmd := exec.Command("sh", "-c", curlCmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("curl command failed: %w", err)
+	}
+
+	url := strings.TrimSpace(string(output))
+	if url == "" {
+		return "", fmt.Errorf("empty response from paste service")
+	}
+
+	return url, nil
+}
+
+func (c *Client) isFloodProtectedChannel(channel string) bool {
+	if p := c.channelProfile(channel); p.FloodProtect != nil {
+		return *p.FloodProtect
+	}
+	for _, ch := range c.floodProtectedChannels {
+		if strings.EqualFold(ch, channel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package irc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ServerNotice is a WALLOPS, GLOBOPS or server notice received by the bot
+type ServerNotice struct {
+	Kind    string `json:"kind"` // "wallops", "globops" or "snotice"
+	Source  string `json:"source"`
+	Message string `json:"message"`
+	Time    int64  `json:"time"`
+}
+
+// isServerPrefix reports whether a message prefix names a server rather than a user
+func isServerPrefix(prefix string) bool {
+	return prefix != "" && !strings.Contains(prefix, "!") && !strings.Contains(prefix, "@")
+}
+
+// isGlobops reports whether a notice or wallops carries a GLOBOPS message
+func isGlobops(message string) bool {
+	upper := strings.ToUpper(message)
+	return strings.HasPrefix(upper, "GLOBOPS") || strings.Contains(upper, "*** GLOBAL") || strings.Contains(upper, "GLOBOPS:")
+}
+
+// addServerNotice stores a notice in the ring buffer and forwards it as a
+// "wallops" trigger event
+func (c *Client) addServerNotice(kind, source, message string, tags map[string]string) {
+	c.serverNoticesMu.Lock()
+	c.serverNotices = append(c.serverNotices, ServerNotice{
+		Kind:    kind,
+		Source:  source,
+		Message: message,
+		Time:    time.Now().Unix(),
+	})
+	// Keep only the last 200 notices to prevent memory growth
+	if len(c.serverNotices) > 200 {
+		c.serverNotices = c.serverNotices[len(c.serverNotices)-200:]
+	}
+	c.serverNoticesMu.Unlock()
+
+	log.Printf("%s from %s: %s", strings.ToUpper(kind), source, message)
+	c.sendTriggerEvent("wallops", source, "", fmt.Sprintf("[%s] %s", kind, message), message, tags)
+}
+
+// ServerNotices returns a copy of the recently captured server notices
+func (c *Client) ServerNotices() []ServerNotice {
+	c.serverNoticesMu.RLock()
+	defer c.serverNoticesMu.RUnlock()
+
+	notices := make([]ServerNotice, len(c.serverNotices))
+	copy(notices, c.serverNotices)
+	return notices
+}
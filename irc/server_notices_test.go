@@ -0,0 +1,54 @@
+package irc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestServerNoticeCapture(t *testing.T) {
+	received := newTriggerRecorder(t, "wallops")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":oper!o@staff WALLOPS :Server maintenance at 10:00")
+	client.handleLine(":irc.server.com WALLOPS :GLOBOPS: linking leaf.example.net")
+	client.handleLine(":irc.server.com NOTICE TestBot :*** Notice -- Client connecting: foo")
+	client.handleLine(":someone!u@h NOTICE TestBot :hello")
+	client.handleLine(":irc.server.com NOTICE #chan :not for us")
+
+	notices := client.ServerNotices()
+	if len(notices) != 3 {
+		t.Fatalf("Expected 3 captured notices, got %d: %+v", len(notices), notices)
+	}
+	expected := []struct{ kind, source string }{
+		{"wallops", "oper"},
+		{"globops", "irc.server.com"},
+		{"snotice", "irc.server.com"},
+	}
+	for i, want := range expected {
+		if notices[i].Kind != want.kind || notices[i].Source != want.source {
+			t.Errorf("Notice %d: expected %s from %s, got %+v", i, want.kind, want.source, notices[i])
+		}
+	}
+
+	for range expected {
+		if payload := expectTrigger(t, received); payload.EventType != "wallops" {
+			t.Errorf("Expected wallops event, got %s", payload.EventType)
+		}
+	}
+}
+
+func TestServerNoticeRingBuffer(t *testing.T) {
+	client := NewClient()
+	for i := 0; i < 250; i++ {
+		client.addServerNotice("snotice", "irc.server.com", fmt.Sprintf("notice %d", i), nil)
+	}
+	notices := client.ServerNotices()
+	if len(notices) != 200 {
+		t.Fatalf("Expected ring buffer of 200, got %d", len(notices))
+	}
+	if notices[0].Message != "notice 50" {
+		t.Errorf("Expected oldest notices to be dropped, first is %q", notices[0].Message)
+	}
+}
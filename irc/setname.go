@@ -0,0 +1,48 @@
+package irc
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+// errNoSetname is returned for SETNAME when the server has not acknowledged
+// the setname capability
+var errNoSetname = errors.New("the server does not support setname")
+
+// SetRealname changes the bot's realname (gecos) without reconnecting. The
+// tracked realname changes once the server echoes SETNAME back; the
+// configured IRC_NAME is used again after a reconnect.
+func (c *Client) SetRealname(name string) error {
+	if !c.setname.Load() {
+		return errNoSetname
+	}
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "\r\n") {
+		return errors.New("realname must be one non-empty line")
+	}
+	if err := c.checkLength("realname", "NAMELEN", name); err != nil {
+		return err
+	}
+	return c.rawf("SETNAME :%s", name)
+}
+
+// handleSetname records a user's new realname: :nick!user@host SETNAME :name
+func (c *Client) handleSetname(prefix, realname string) {
+	nick := strings.Split(prefix, "!")[0]
+	if nick == "" {
+		return
+	}
+	if strings.EqualFold(nick, c.Nick()) {
+		log.Printf("Realname changed to %q", realname)
+	}
+	c.updateUserInfo(nick, func(info *UserInfo) { info.RealName = realname })
+}
+
+// handleFail logs IRCv3 standard replies: FAIL <command> <code> [context...] :description
+func (c *Client) handleFail(args []string, trailing string) {
+	if len(args) < 2 {
+		return
+	}
+	log.Printf("FAIL %s %s: %s", args[0], args[1], trailing)
+	c.addError("FAIL", args[0], args[1]+": "+trailing)
+}
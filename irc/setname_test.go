@@ -0,0 +1,56 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetnameTracking(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host SETNAME :Alice Liddell")
+	if info := client.getUserInfo("alice"); info == nil || info.RealName != "Alice Liddell" {
+		t.Errorf("Expected the realname to be tracked, got %+v", info)
+	}
+
+	client.handleLine(":server CAP TestBot ACK :setname")
+	if !client.setname.Load() {
+		t.Error("Expected setname to be enabled once acknowledged")
+	}
+}
+
+func TestSetnameAPI(t *testing.T) {
+	client := NewClient()
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	api := client.CreateAPI("token")
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/setname", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"realname":"New Name"}`); rec.Code != 409 {
+		t.Errorf("Expected 409 without setname, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	client.setname.Store(true)
+	client.serverInfo.ISupportTags["NAMELEN"] = "10"
+	if rec := post(`{"realname":"New Name"}`); rec.Code != 200 {
+		t.Errorf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(lines) != 1 || lines[0] != "SETNAME :New Name" {
+		t.Errorf("Expected SETNAME :New Name, got %q", lines)
+	}
+	if rec := post(`{"realname":"A much longer name"}`); rec.Code != 400 {
+		t.Errorf("Expected 400 above NAMELEN, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"realname":""}`); rec.Code != 400 {
+		t.Errorf("Expected 400 without a realname, got %d %s", rec.Code, rec.Body.String())
+	}
+}
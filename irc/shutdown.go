@@ -0,0 +1,96 @@
+package irc
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// drainState coordinates a graceful shutdown: event streams end with a
+// shutting_down event and trigger deliveries already queued are sent, while
+// new ones are refused
+type drainState struct {
+	mu      sync.Mutex
+	ch      chan struct{} // closed when draining starts
+	refused atomic.Bool   // a trigger event was refused, logged once
+
+	streams    atomic.Int64 // open event streams (SSE and gRPC)
+	deliveries atomic.Int64 // trigger payloads queued or being sent
+}
+
+// done returns a channel closed when the client starts draining
+func (d *drainState) done() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ch == nil {
+		d.ch = make(chan struct{})
+	}
+	return d.ch
+}
+
+func (d *drainState) start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ch == nil {
+		d.ch = make(chan struct{})
+	}
+	select {
+	case <-d.ch:
+	default:
+		close(d.ch)
+	}
+}
+
+func (d *drainState) draining() bool {
+	select {
+	case <-d.done():
+		return true
+	default:
+		return false
+	}
+}
+
+// openStream counts an event stream until the returned function is called.
+// It reports false when the client is already draining.
+func (d *drainState) openStream() (func(), bool) {
+	if d.draining() {
+		return nil, false
+	}
+	d.streams.Add(1)
+	return func() { d.streams.Add(-1) }, true
+}
+
+// shutdownEvent is the last event of every stream
+func shutdownEvent() Event {
+	return Event{
+		ID:      newRequestID(),
+		Type:    "shutting_down",
+		Time:    time.Now(),
+		Message: "The bot is shutting down",
+	}
+}
+
+// Drain prepares the client to stop: event streams get a final
+// shutting_down event and end, no new trigger events are queued, and the
+// ones already queued are delivered. It returns when all of that is done or
+// ctx expires, whichever is first. The IRC connection stays up, so trigger
+// replies can still be sent; close the client afterwards.
+func (c *Client) Drain(ctx context.Context) {
+	c.drain.start()
+	log.Printf("Draining: %d event streams, %d trigger deliveries pending",
+		c.drain.streams.Load(), c.drain.deliveries.Load())
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for c.drain.streams.Load() > 0 || c.drain.deliveries.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			log.Printf("Drain timed out: %d event streams, %d trigger deliveries left",
+				c.drain.streams.Load(), c.drain.deliveries.Load())
+			return
+		case <-ticker.C:
+		}
+	}
+	log.Printf("Drained")
+}
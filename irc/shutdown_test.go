@@ -0,0 +1,142 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrainEndsEventStreams(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	srv := httptest.NewServer(client.CreateAPI("token"))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/events?types=privmsg", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	ended := make(chan []string, 1)
+	go func() {
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				lines = append(lines, scanner.Text())
+			}
+		}
+		ended <- lines
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	client.Drain(ctx)
+	if ctx.Err() != nil {
+		t.Fatal("Drain timed out with one stream open")
+	}
+	select {
+	case lines := <-ended:
+		if len(lines) != 2 || lines[0] != "event: shutting_down" {
+			t.Fatalf("Expected a final shutting_down event, got %q", lines)
+		}
+		var e Event
+		json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e)
+		if e.Type != "shutting_down" {
+			t.Errorf("Unexpected final event: %s", lines[1])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the stream to end")
+	}
+
+	// New streams are refused while draining
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != 503 {
+		t.Errorf("Expected 503 for a stream opened while draining, got %d", resp2.StatusCode)
+	}
+}
+
+func TestDrainDeliversQueuedTriggers(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []string
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var payload TriggerPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		delivered = append(delivered, payload.Message)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+	oldConfig := os.Getenv("TRIGGER_CONFIG")
+	defer os.Setenv("TRIGGER_CONFIG", oldConfig)
+	os.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"slow":{"url":%q,"events":["privmsg"]}}}`, srv.URL))
+
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	for i := 0; i < 3; i++ {
+		client.handleLine(fmt.Sprintf(":alice!a@host PRIVMSG #test :message %d", i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		client.Drain(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected Drain to wait for queued deliveries")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Events after draining started are not queued
+	client.handleLine(":alice!a@host PRIVMSG #test :too late")
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Drain to return once the queue is delivered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 3 || delivered[2] != "message 2" {
+		t.Errorf("Expected the 3 queued events to be delivered, got %q", delivered)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	client := NewClient()
+	client.drain.deliveries.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	client.Drain(ctx)
+	if time.Since(start) > time.Second {
+		t.Errorf("Expected Drain to give up at the deadline")
+	}
+}
@@ -0,0 +1,117 @@
+package irc
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// stateSnapshot is the channel, user and server state kept in STATE_FILE
+type stateSnapshot struct {
+	SavedAt  time.Time                `json:"saved_at"`
+	Server   *ServerInfo              `json:"server"`
+	Channels map[string]*ChannelState `json:"channels"`
+	Users    map[string]*UserInfo     `json:"users"`
+}
+
+// snapshotLoop writes the state to STATE_FILE every minute and once more
+// when done is closed
+func (c *Client) snapshotLoop(done <-chan struct{}) {
+	if c.stateFile == "" {
+		return
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			c.saveStateSnapshot()
+			return
+		case <-ticker.C:
+			c.saveStateSnapshot()
+		}
+	}
+}
+
+// saveStateSnapshot writes the current state to STATE_FILE
+func (c *Client) saveStateSnapshot() {
+	if c.stateFile == "" {
+		return
+	}
+	snap := stateSnapshot{SavedAt: time.Now(), Server: c.getServerInfo()}
+	c.channelStatesMu.RLock()
+	snap.Channels = make(map[string]*ChannelState, len(c.channelStates))
+	for name, state := range c.channelStates {
+		snap.Channels[name] = state.clone()
+	}
+	c.channelStatesMu.RUnlock()
+	snap.Users = c.ListUsers()
+	data, err := json.Marshal(snap)
+	if err != nil {
+		log.Printf("Error encoding state snapshot: %v", err)
+		return
+	}
+
+	tmp := c.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Error writing state snapshot to %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, c.stateFile); err != nil {
+		log.Printf("Error saving state snapshot to %s: %v", c.stateFile, err)
+	}
+}
+
+// loadStateSnapshot restores the state saved in STATE_FILE. Everything
+// restored is marked stale until the server confirms it: channels once their
+// NAMES reply completes after rejoining, users and server info on the next
+// update.
+func (c *Client) loadStateSnapshot() {
+	if c.stateFile == "" {
+		return
+	}
+	data, err := os.ReadFile(c.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading state snapshot from %s: %v", c.stateFile, err)
+		return
+	}
+	var snap stateSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		log.Printf("Error parsing state snapshot from %s: %v", c.stateFile, err)
+		return
+	}
+
+	now := time.Now()
+	if snap.Server != nil {
+		if snap.Server.ISupportTags == nil {
+			snap.Server.ISupportTags = make(map[string]string)
+		}
+		snap.Server.Stale = true
+		c.serverInfoMu.Lock()
+		c.serverInfo = snap.Server
+		c.serverInfoMu.Unlock()
+	}
+	c.channelStatesMu.Lock()
+	for name, state := range snap.Channels {
+		if state.Users == nil {
+			state.Users = make(map[string]string)
+		}
+		state.Stale = true
+		c.channelStates[name] = state
+	}
+	c.channelStatesMu.Unlock()
+	c.userInfoMu.Lock()
+	for nick, info := range snap.Users {
+		info.Stale = true
+		info.updated = now
+		c.userInfo[nick] = info
+	}
+	c.userInfoMu.Unlock()
+	log.Printf("Restored state of %d channel(s) and %d user(s) saved %s ago from %s",
+		len(snap.Channels), len(snap.Users), now.Sub(snap.SavedAt).Round(time.Second), c.stateFile)
+}
@@ -0,0 +1,49 @@
+package irc
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStateSnapshot(t *testing.T) {
+	t.Setenv("STATE_FILE", filepath.Join(t.TempDir(), "state.json"))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	client.handleLine(":server 005 TestBot NETWORK=Example :are supported by this server")
+	client.handleLine(":TestBot!b@host JOIN #test")
+	client.handleLine(":server 353 TestBot = #test :TestBot @alice")
+	client.handleLine(":server 366 TestBot #test :End of /NAMES list")
+	client.handleLine(":server 332 TestBot #test :Saved topic")
+	client.updateUserInfo("alice", func(info *UserInfo) { info.Account = "alice_acct" })
+	client.saveStateSnapshot()
+
+	restored := NewClient()
+	restored.setNick("TestBot")
+	restored.testRawCapture = func(string) {}
+	topic, ok := restored.Topic("#test")
+	if !ok || topic.Topic != "Saved topic" {
+		t.Fatalf("Expected the topic to be restored, got %+v", topic)
+	}
+	if !restored.HasChannelUser("#test", "alice") || restored.senderAccount("alice", nil) != "alice_acct" {
+		t.Error("Expected users and accounts to be restored")
+	}
+	if info := restored.getServerInfo(); info.ISupportTags["NETWORK"] != "Example" || !info.Stale {
+		t.Errorf("Expected stale server info to be restored, got %+v", info)
+	}
+	if !restored.GetChannelState("#test").Stale || !restored.getUserInfo("alice").Stale {
+		t.Error("Restored state should be marked stale")
+	}
+
+	// Rejoining refreshes the channel and drops users who are gone
+	restored.handleLine(":TestBot!b@host JOIN #test")
+	restored.handleLine(":server 353 TestBot = #test :TestBot bob")
+	restored.handleLine(":server 366 TestBot #test :End of /NAMES list")
+	if restored.GetChannelState("#test").Stale || restored.HasChannelUser("#test", "alice") {
+		t.Error("Expected the NAMES reply to replace the restored users")
+	}
+	restored.updateUserInfo("alice", func(*UserInfo) {})
+	if restored.getUserInfo("alice").Stale {
+		t.Error("An update should clear the stale mark")
+	}
+}
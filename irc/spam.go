@@ -0,0 +1,173 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpamConfig is the SPAM_CONFIG JSON document controlling channel protection
+type SpamConfig struct {
+	Channels      []string `json:"channels,omitempty"` // channels to protect (empty = all)
+	MaxMessages   int      `json:"max_messages"`       // messages allowed per window
+	WindowSeconds int      `json:"window_seconds"`     // rate window length
+	MaxRepeats    int      `json:"max_repeats"`        // identical consecutive messages allowed
+	Action        string   `json:"action"`             // ignore, warn, kick or ban
+	IgnoreSeconds int      `json:"ignore_seconds"`     // how long offenders are ignored
+	ExemptRole    Role     `json:"exempt_role"`        // users with this role or higher are never checked
+}
+
+var spamActions = map[string]bool{"ignore": true, "warn": true, "kick": true, "ban": true}
+
+// spamActivity is the recent message history of one user in one channel
+type spamActivity struct {
+	times        []time.Time
+	last         string
+	repeats      int
+	ignoredUntil time.Time
+}
+
+type spamTracker struct {
+	mu    sync.Mutex
+	users map[string]*spamActivity // channel + " " + nick (lowercase) -> activity
+}
+
+func (c *Client) loadSpamConfig() {
+	configStr := os.Getenv("SPAM_CONFIG")
+	if configStr == "" {
+		return
+	}
+	cfg := SpamConfig{
+		MaxMessages:   5,
+		WindowSeconds: 10,
+		MaxRepeats:    3,
+		Action:        "warn",
+		IgnoreSeconds: 300,
+		ExemptRole:    RoleTrusted,
+	}
+	if err := json.Unmarshal([]byte(configStr), &cfg); err != nil {
+		log.Fatalf("FATAL: Invalid SPAM_CONFIG JSON: %v", err)
+	}
+	cfg.Action = strings.ToLower(cfg.Action)
+	if !spamActions[cfg.Action] {
+		log.Fatalf("FATAL: Invalid SPAM_CONFIG action %q (expected ignore, warn, kick or ban)", cfg.Action)
+	}
+	log.Printf("Spam protection enabled (action: %s)", cfg.Action)
+	c.spamConfig = &cfg
+	c.spam = &spamTracker{users: make(map[string]*spamActivity)}
+}
+
+func (c *Client) isSpamProtectedChannel(channel string) bool {
+	if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+		return false
+	}
+	if len(c.spamConfig.Channels) == 0 {
+		return true
+	}
+	for _, ch := range c.spamConfig.Channels {
+		if strings.EqualFold(ch, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSpam records a channel message and applies the configured action when
+// the sender floods or repeats themselves. It returns true when the message
+// should be dropped instead of being dispatched to commands and triggers.
+func (c *Client) checkSpam(prefix, channel, message string, tags map[string]string) bool {
+	if c.spamConfig == nil || !c.isSpamProtectedChannel(channel) {
+		return false
+	}
+	sender := strings.Split(prefix, "!")[0]
+	if c.spamConfig.ExemptRole > RoleNone && c.RoleOf(prefix, c.senderAccount(sender, tags)) >= c.spamConfig.ExemptRole {
+		return false
+	}
+
+	now := time.Now()
+	window := time.Duration(c.spamConfig.WindowSeconds) * time.Second
+	key := strings.ToLower(channel) + " " + strings.ToLower(sender)
+
+	c.spam.mu.Lock()
+	act := c.spam.users[key]
+	if act == nil {
+		act = &spamActivity{}
+		c.spam.users[key] = act
+	}
+	if now.Before(act.ignoredUntil) {
+		c.spam.mu.Unlock()
+		return true
+	}
+
+	// Drop timestamps that fell out of the window
+	recent := act.times[:0]
+	for _, t := range act.times {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	act.times = append(recent, now)
+
+	if strings.EqualFold(strings.TrimSpace(message), act.last) {
+		act.repeats++
+	} else {
+		act.last = strings.ToLower(strings.TrimSpace(message))
+		act.repeats = 1
+	}
+
+	reason := ""
+	if c.spamConfig.MaxMessages > 0 && len(act.times) > c.spamConfig.MaxMessages {
+		reason = fmt.Sprintf("flood: %d messages in %ds", len(act.times), c.spamConfig.WindowSeconds)
+	} else if c.spamConfig.MaxRepeats > 0 && act.repeats > c.spamConfig.MaxRepeats {
+		reason = fmt.Sprintf("repeat: same message %d times", act.repeats)
+	}
+	if reason != "" {
+		act.times = nil
+		act.repeats = 0
+		act.ignoredUntil = now.Add(time.Duration(c.spamConfig.IgnoreSeconds) * time.Second)
+	}
+	c.pruneSpamActivity(now, window)
+	c.spam.mu.Unlock()
+
+	if reason == "" {
+		return false
+	}
+
+	action := c.spamConfig.Action
+	log.Printf("Spam detected from %s in %s (%s), action: %s", prefix, channel, reason, action)
+	switch action {
+	case "warn":
+		c.Notice(sender, fmt.Sprintf("Please slow down in %s (%s)", channel, reason))
+	case "kick":
+		c.rawf("KICK %s %s :%s", channel, sender, c.truncateTo("KICKLEN", reason))
+	case "ban":
+		if i := strings.Index(prefix, "@"); i != -1 {
+			c.rawf("MODE %s +b *!*@%s", channel, prefix[i+1:])
+		} else {
+			c.rawf("MODE %s +b %s!*@*", channel, sender)
+		}
+		c.rawf("KICK %s %s :%s", channel, sender, c.truncateTo("KICKLEN", reason))
+	}
+	payload := c.newTriggerPayload("spam", sender, channel, fmt.Sprintf("%s (action: %s)", reason, action), message, tags)
+	payload.Hostmask = prefix
+	c.sendTrigger(payload)
+	return true
+}
+
+// pruneSpamActivity forgets users with no recent activity once the tracker
+// grows large. Must be called with c.spam.mu held.
+func (c *Client) pruneSpamActivity(now time.Time, window time.Duration) {
+	if len(c.spam.users) < 1000 {
+		return
+	}
+	for key, act := range c.spam.users {
+		idle := len(act.times) == 0 || now.Sub(act.times[len(act.times)-1]) >= window
+		if idle && now.After(act.ignoredUntil) {
+			delete(c.spam.users, key)
+		}
+	}
+}
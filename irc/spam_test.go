@@ -0,0 +1,81 @@
+package irc
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func newSpamTestClient(t *testing.T, config string) (*Client, *[]string) {
+	t.Helper()
+	oldConfig := os.Getenv("SPAM_CONFIG")
+	t.Cleanup(func() { os.Setenv("SPAM_CONFIG", oldConfig) })
+	os.Setenv("SPAM_CONFIG", config)
+
+	client := NewClient()
+	client.setNick("TestBot")
+	sent := []string{}
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	return client, &sent
+}
+
+func TestSpamFloodDetection(t *testing.T) {
+	client, sent := newSpamTestClient(t, `{"max_messages": 3, "window_seconds": 60, "action": "kick"}`)
+
+	for i := 0; i < 3; i++ {
+		if client.checkSpam("flooder!f@host", "#test", strings.Repeat("x", i+1), nil) {
+			t.Fatalf("Message %d should not be treated as spam", i+1)
+		}
+	}
+	if !client.checkSpam("flooder!f@host", "#test", "one too many", nil) {
+		t.Fatal("Fourth message within the window should be treated as spam")
+	}
+	if len(*sent) != 1 || !strings.HasPrefix((*sent)[0], "KICK #test flooder :flood") {
+		t.Errorf("Expected a KICK for flooding, got %v", *sent)
+	}
+
+	// Offender stays ignored after the action
+	if !client.checkSpam("flooder!f@host", "#test", "hello again", nil) {
+		t.Error("Offender should be ignored after detection")
+	}
+
+	// Other users are unaffected
+	if client.checkSpam("other!o@host", "#test", "hi", nil) {
+		t.Error("Other users should not be affected")
+	}
+}
+
+func TestSpamRepeatDetection(t *testing.T) {
+	client, sent := newSpamTestClient(t, `{"max_messages": 100, "max_repeats": 2, "action": "ban"}`)
+
+	client.checkSpam("rep!r@bad.example", "#test", "buy now", nil)
+	client.checkSpam("rep!r@bad.example", "#test", "BUY NOW", nil)
+	if !client.checkSpam("rep!r@bad.example", "#test", "buy now", nil) {
+		t.Fatal("Third identical message should be treated as spam")
+	}
+	if len(*sent) != 2 || (*sent)[0] != "MODE #test +b *!*@bad.example" {
+		t.Errorf("Expected ban then kick, got %v", *sent)
+	}
+}
+
+func TestSpamChannelFilterAndExemption(t *testing.T) {
+	oldAccess := os.Getenv("ACCESS_CONFIG")
+	defer os.Setenv("ACCESS_CONFIG", oldAccess)
+	os.Setenv("ACCESS_CONFIG", `{"users": [{"mask": "trusted!*@*", "role": "trusted"}]}`)
+
+	client, _ := newSpamTestClient(t, `{"channels": ["#guarded"], "max_messages": 1}`)
+
+	client.checkSpam("user!u@h", "#open", "a", nil)
+	if client.checkSpam("user!u@h", "#open", "b", nil) {
+		t.Error("Unprotected channels should not be checked")
+	}
+
+	client.checkSpam("trusted!t@h", "#guarded", "a", nil)
+	if client.checkSpam("trusted!t@h", "#guarded", "b", nil) {
+		t.Error("Trusted users should be exempt by default")
+	}
+
+	if client.checkSpam("user!u@h", "TestBot", "private", nil) {
+		t.Error("Private messages should not be checked")
+	}
+}
@@ -0,0 +1,515 @@
+package irc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChannelUser represents a user in a channel with their modes
+type ChannelUser struct {
+	Nick  string `json:"nick"`
+	Modes string `json:"modes,omitempty"` // e.g. "ov" for operator+voice
+}
+
+// ChannelState represents the state of an IRC channel
+type ChannelState struct {
+	Name         string            `json:"name"`
+	Users        map[string]string `json:"users"`                  // nick -> modes (e.g. "ov", "o", "v", or "" for no modes)
+	Topic        string            `json:"topic"`                  // current topic
+	TopicSetBy   string            `json:"topic_set_by"`           // who set the topic
+	TopicSetTime int64             `json:"topic_set_time"`         // when topic was set (unix timestamp)
+	CreatedTime  int64             `json:"created_time"`           // channel creation time (unix timestamp)
+	Modes        string            `json:"modes"`                  // channel modes (e.g. "+nt")
+	ModeParams   []string          `json:"mode_params"`            // parameters for modes that take them
+	BanList      []BanListEntry    `json:"ban_list"`               // channel ban list
+	InviteList   []InviteListEntry `json:"invite_list"`            // channel invite list
+	ExceptList   []ExceptListEntry `json:"except_list"`            // channel exception list
+	URL          string            `json:"url,omitempty"`          // channel URL if set
+	SpecialInfo  map[string]string `json:"special_info,omitempty"` // other special channel info
+	Stale        bool              `json:"stale,omitempty"`        // restored from STATE_FILE, not confirmed by NAMES yet
+}
+
+// BanListEntry represents a ban list entry
+type BanListEntry struct {
+	Mask    string `json:"mask"`
+	SetBy   string `json:"set_by"`
+	SetTime int64  `json:"set_time"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// InviteListEntry represents an invite list entry
+type InviteListEntry struct {
+	Mask    string `json:"mask"`
+	SetBy   string `json:"set_by"`
+	SetTime int64  `json:"set_time"`
+}
+
+// ExceptListEntry represents an exception list entry
+type ExceptListEntry struct {
+	Mask    string `json:"mask"`
+	SetBy   string `json:"set_by"`
+	SetTime int64  `json:"set_time"`
+}
+
+// ServerInfo represents information about the IRC server
+type ServerInfo struct {
+	Name           string            `json:"name"`
+	Version        string            `json:"version"`
+	UserModes      string            `json:"user_modes"`
+	ChannelModes   string            `json:"channel_modes"`
+	Created        string            `json:"created"`
+	ISupportTags   map[string]string `json:"isupport_tags"` // RPL_ISUPPORT (005) tags
+	AdminInfo      AdminInfo         `json:"admin_info"`
+	MOTD           []string          `json:"motd"`
+	LocalUsers     int               `json:"local_users"`
+	GlobalUsers    int               `json:"global_users"`
+	MaxLocalUsers  int               `json:"max_local_users"`
+	MaxGlobalUsers int               `json:"max_global_users"`
+	Operators      int               `json:"operators"`
+	UnknownConns   int               `json:"unknown_connections"`
+	Channels       int               `json:"channels_formed"`
+	Stale          bool              `json:"stale,omitempty"` // restored from STATE_FILE, not updated by the server yet
+}
+
+// AdminInfo represents server administrator information
+type AdminInfo struct {
+	Server    string `json:"server"`
+	Location1 string `json:"location1"`
+	Location2 string `json:"location2"`
+	Email     string `json:"email"`
+}
+
+// UserInfo represents comprehensive information about a user
+type UserInfo struct {
+	Nick            string            `json:"nick"`
+	User            string            `json:"user"`
+	Host            string            `json:"host"`
+	RealName        string            `json:"real_name"`
+	Server          string            `json:"server"`
+	ServerInfo      string            `json:"server_info"`
+	Account         string            `json:"account,omitempty"` // services account (330)
+	IsOperator      bool              `json:"is_operator"`       // global IRC operator
+	IsAway          bool              `json:"is_away"`
+	AwayMessage     string            `json:"away_message,omitempty"`
+	IdleTime        int               `json:"idle_time"`                  // seconds idle
+	SignonTime      int64             `json:"signon_time"`                // when they signed on
+	Channels        []string          `json:"channels"`                   // list of channels
+	Modes           string            `json:"modes"`                      // user modes
+	IsSecure        bool              `json:"is_secure"`                  // using SSL/TLS (671)
+	CertFingerprint string            `json:"cert_fingerprint,omitempty"` // SSL cert fingerprint (276)
+	ActualHost      string            `json:"actual_host,omitempty"`      // actual host/IP (338)
+	CountryCode     string            `json:"country_code,omitempty"`     // country code (344)
+	Country         string            `json:"country,omitempty"`          // country name (344)
+	ASN             string            `json:"asn,omitempty"`              // AS number (569)
+	IsBot           bool              `json:"is_bot"`                     // marked as bot (335)
+	WebIRCGateway   string            `json:"webirc_gateway,omitempty"`   // WebIRC gateway info
+	SpecialInfo     map[string]string `json:"special_info,omitempty"`     // other special info
+	Stale           bool              `json:"stale,omitempty"`            // restored from STATE_FILE, not updated since
+
+	updated time.Time // last change, for eviction by stategc.go
+}
+
+// StatEntry represents a server statistics entry
+type StatEntry struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+// IRCError represents an IRC error response
+type IRCError struct {
+	Code    string `json:"code"`
+	Target  string `json:"target,omitempty"`
+	Message string `json:"message"`
+	Time    int64  `json:"time"`
+}
+
+// UserModeChange represents a mode change operation
+type UserModeChange struct {
+	Adding bool   // true for +, false for -
+	Mode   rune   // the mode character (o, v, etc.)
+	Nick   string // the user affected
+}
+
+// Helper functions for channel state tracking
+func (c *Client) AddUserToChannel(channel, nick string, modes string) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+
+	channel = strings.ToLower(channel)
+	if c.channelStates[channel] == nil {
+		c.channelStates[channel] = &ChannelState{
+			Name:        channel,
+			Users:       make(map[string]string),
+			BanList:     make([]BanListEntry, 0),
+			InviteList:  make([]InviteListEntry, 0),
+			ExceptList:  make([]ExceptListEntry, 0),
+			ModeParams:  make([]string, 0),
+			SpecialInfo: make(map[string]string),
+		}
+	}
+	c.channelStates[channel].Users[nick] = modes
+}
+
+// HasChannelUser reports whether nick is listed in the channel's user list
+func (c *Client) HasChannelUser(channel, nick string) bool {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+
+	if state := c.channelStates[strings.ToLower(channel)]; state != nil {
+		_, ok := state.Users[nick]
+		return ok
+	}
+	return false
+}
+
+// ensureUserInChannel adds nick with no modes unless already listed
+func (c *Client) ensureUserInChannel(channel, nick string) {
+	if !c.HasChannelUser(channel, nick) {
+		c.AddUserToChannel(channel, nick, "")
+	}
+}
+
+func (c *Client) RemoveUserFromChannel(channel, nick string) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+
+	channel = strings.ToLower(channel)
+	if state := c.channelStates[channel]; state != nil {
+		delete(state.Users, nick)
+	}
+}
+
+func (c *Client) RemoveUserFromAllChannels(nick string) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+
+	for _, state := range c.channelStates {
+		delete(state.Users, nick)
+	}
+}
+
+func (c *Client) ClearChannelState(channel string) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+
+	channel = strings.ToLower(channel)
+	delete(c.channelStates, channel)
+}
+
+// Helper functions for user information tracking
+func (c *Client) updateUserInfo(nick string, updateFunc func(*UserInfo)) {
+	c.userInfoMu.Lock()
+	defer c.userInfoMu.Unlock()
+
+	nick = strings.ToLower(nick)
+	if c.userInfo[nick] == nil {
+		c.userInfo[nick] = &UserInfo{
+			Nick:        nick,
+			SpecialInfo: make(map[string]string),
+		}
+	}
+	updateFunc(c.userInfo[nick])
+	c.userInfo[nick].updated = time.Now()
+	c.userInfo[nick].Stale = false
+}
+
+// clone returns a copy of the user info that shares nothing with it
+func (u *UserInfo) clone() *UserInfo {
+	copyInfo := *u
+	copyInfo.Channels = append([]string(nil), u.Channels...)
+	copyInfo.SpecialInfo = make(map[string]string, len(u.SpecialInfo))
+	for k, v := range u.SpecialInfo {
+		copyInfo.SpecialInfo[k] = v
+	}
+	return &copyInfo
+}
+
+func (c *Client) getUserInfo(nick string) *UserInfo {
+	c.userInfoMu.RLock()
+	defer c.userInfoMu.RUnlock()
+
+	nick = strings.ToLower(nick)
+	if info := c.userInfo[nick]; info != nil {
+		// Return a copy to avoid race conditions
+		return info.clone()
+	}
+	return nil
+}
+
+func (c *Client) removeUserInfo(nick string) {
+	c.userInfoMu.Lock()
+	defer c.userInfoMu.Unlock()
+
+	nick = strings.ToLower(nick)
+	delete(c.userInfo, nick)
+}
+
+// Helper functions for server information tracking
+func (c *Client) updateServerInfo(updateFunc func(*ServerInfo)) {
+	c.serverInfoMu.Lock()
+	defer c.serverInfoMu.Unlock()
+	updateFunc(c.serverInfo)
+	c.serverInfo.Stale = false
+}
+
+func (c *Client) getServerInfo() *ServerInfo {
+	c.serverInfoMu.RLock()
+	defer c.serverInfoMu.RUnlock()
+
+	// Return a copy
+	info := *c.serverInfo
+
+	// Deep copy maps
+	info.ISupportTags = make(map[string]string)
+	for k, v := range c.serverInfo.ISupportTags {
+		info.ISupportTags[k] = v
+	}
+
+	// Copy MOTD slice
+	info.MOTD = make([]string, len(c.serverInfo.MOTD))
+	copy(info.MOTD, c.serverInfo.MOTD)
+
+	return &info
+}
+
+// Helper functions for statistics tracking
+func (c *Client) addStatEntry(statType string, data map[string]string) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	c.stats = append(c.stats, StatEntry{
+		Type: statType,
+		Data: data,
+	})
+
+	// Keep only the last 1000 stat entries to prevent memory growth
+	if len(c.stats) > 1000 {
+		c.stats = c.stats[len(c.stats)-1000:]
+	}
+}
+
+func (c *Client) getStats() []StatEntry {
+	c.statsMu.RLock()
+	defer c.statsMu.RUnlock()
+
+	// Return a copy
+	stats := make([]StatEntry, len(c.stats))
+	copy(stats, c.stats)
+	return stats
+}
+
+// Helper functions for error tracking
+func (c *Client) addError(code, target, message string) {
+	c.errorsMu.Lock()
+	defer c.errorsMu.Unlock()
+
+	c.errors = append(c.errors, IRCError{
+		Code:    code,
+		Target:  target,
+		Message: message,
+		Time:    time.Now().Unix(),
+	})
+
+	// Keep only the last 100 errors to prevent memory growth
+	if len(c.errors) > 100 {
+		c.errors = c.errors[len(c.errors)-100:]
+	}
+}
+
+func (c *Client) getRecentErrors() []IRCError {
+	c.errorsMu.RLock()
+	defer c.errorsMu.RUnlock()
+
+	// Return a copy
+	errors := make([]IRCError, len(c.errors))
+	copy(errors, c.errors)
+	return errors
+}
+
+// ListUsers returns a copy of the known user information, keyed by
+// lowercased nick
+func (c *Client) ListUsers() map[string]*UserInfo {
+	c.userInfoMu.RLock()
+	defer c.userInfoMu.RUnlock()
+
+	users := make(map[string]*UserInfo, len(c.userInfo))
+	for nick, info := range c.userInfo {
+		users[nick] = info.clone()
+	}
+	return users
+}
+
+// UserFilter selects users for FindUsers; empty fields match everyone
+type UserFilter struct {
+	Nick    string // wildcard mask, e.g. alice*
+	Account string // services account
+	Channel string // only users listed in this channel
+}
+
+// FindUsers returns copies of the users matching the filter, sorted by nick
+func (c *Client) FindUsers(f UserFilter) []*UserInfo {
+	var members map[string]bool
+	if f.Channel != "" {
+		members = make(map[string]bool)
+		if state := c.GetChannelState(f.Channel); state != nil {
+			for nick := range state.Users {
+				members[strings.ToLower(nick)] = true
+			}
+		}
+	}
+
+	c.userInfoMu.RLock()
+	var users []*UserInfo
+	for key, info := range c.userInfo {
+		if f.Nick != "" && !matchMask(f.Nick, info.Nick) {
+			continue
+		}
+		if f.Account != "" && !strings.EqualFold(f.Account, info.Account) {
+			continue
+		}
+		if members != nil && !members[key] {
+			continue
+		}
+		users = append(users, info.clone())
+	}
+	c.userInfoMu.RUnlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].Nick < users[j].Nick })
+	return users
+}
+
+// parseTime parses various time formats used in IRC
+func parseIRCTime(timeStr string) int64 {
+	// Try parsing as Unix timestamp first
+	if timestamp, err := strconv.ParseInt(timeStr, 10, 64); err == nil {
+		return timestamp
+	}
+
+	// Try parsing common IRC date formats
+	formats := []string{
+		time.RFC1123,
+		time.RFC1123Z,
+		"Mon Jan 2 15:04:05 2006",
+		"Mon Jan 2 15:04:05 MST 2006",
+		"Jan 2 15:04:05 2006",
+		"2006-01-02 15:04:05",
+	}
+
+	for _, format := range formats {
+		if t, err := time.Parse(format, timeStr); err == nil {
+			return t.Unix()
+		}
+	}
+
+	return time.Now().Unix() // fallback to current time
+}
+
+// extractServerName extracts server name from IRC prefix
+func extractServerName(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+
+	// If prefix contains '!' it's a user, extract server differently
+	if strings.Contains(prefix, "!") {
+		return ""
+	}
+
+	// If it's just a server name, return it
+	return prefix
+}
+
+// parseModeChange parses IRC mode strings like "+oo nick1 nick2" or "-v nick"
+func (c *Client) ParseModeChange(channel, modeString string, params []string) []UserModeChange {
+	var changes []UserModeChange
+	adding := true
+	paramIdx := 0
+	prefixes := c.prefixMap()
+
+	for _, char := range modeString {
+		switch {
+		case char == '+':
+			adding = true
+		case char == '-':
+			adding = false
+		case prefixes.isStatusMode(char), char == 'b', char == 'k', char == 'l': // modes that take parameters
+			if paramIdx < len(params) {
+				changes = append(changes, UserModeChange{
+					Adding: adding,
+					Mode:   char,
+					Nick:   params[paramIdx],
+				})
+				paramIdx++
+			}
+		}
+	}
+
+	return changes
+}
+
+func (c *Client) ApplyModeChanges(channel string, changes []UserModeChange) {
+	prefixes := c.prefixMap()
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+
+	channel = strings.ToLower(channel)
+	if state := c.channelStates[channel]; state != nil {
+		for _, change := range changes {
+			if prefixes.isStatusMode(change.Mode) {
+				currentModes := state.Users[change.Nick]
+				if change.Adding {
+					// Add mode if not present, keeping modes in rank order
+					if !strings.ContainsRune(currentModes, change.Mode) {
+						currentModes = prefixes.sortModes(currentModes + string(change.Mode))
+					}
+				} else {
+					// Remove mode if present
+					currentModes = strings.ReplaceAll(currentModes, string(change.Mode), "")
+				}
+				state.Users[change.Nick] = currentModes
+			}
+		}
+	}
+}
+
+// GetChannelState returns a copy of a channel's state, or nil if the channel
+// is not tracked
+func (c *Client) GetChannelState(channel string) *ChannelState {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+
+	if state := c.channelStates[strings.ToLower(channel)]; state != nil {
+		return state.clone()
+	}
+	return nil
+}
+
+func (c *Client) GetChannelStates() map[string]map[string]interface{} {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+
+	result := make(map[string]map[string]interface{})
+	for channelName, state := range c.channelStates {
+		users := make(map[string]interface{})
+		for nick, modes := range state.Users {
+			if modes == "" {
+				users[nick] = nil
+			} else {
+				users[nick] = modes
+			}
+		}
+		result[channelName] = users
+	}
+	return result
+}
+
+func (c *Client) Channels() []string {
+	c.channelsMu.RLock()
+	defer c.channelsMu.RUnlock()
+	out := make([]string, 0, len(c.channels))
+	for ch := range c.channels {
+		out = append(out, ch)
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package irc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateChange is one change to the channel state of /api/state, numbered by
+// the state revision it produced
+type StateChange struct {
+	Revision int64     `json:"revision"`
+	Time     time.Time `json:"time"`
+	// user_joined, user_left, nick_changed, user_modes, topic,
+	// channel_joined, channel_left or channel_synced (the user list was
+	// reloaded from NAMES)
+	Type     string   `json:"type"`
+	Channel  string   `json:"channel,omitempty"`
+	Nick     string   `json:"nick,omitempty"`
+	NewNick  string   `json:"new_nick,omitempty"` // nick_changed
+	Channels []string `json:"channels,omitempty"` // nick_changed: channels the user is in
+	Modes    string   `json:"modes,omitempty"`    // user_modes: the user's modes after the change
+	Topic    string   `json:"topic,omitempty"`    // topic
+	Reason   string   `json:"reason,omitempty"`   // user_left: part, kick, quit or netsplit
+	By       string   `json:"by,omitempty"`       // who made the change, for kicks, modes and topics
+}
+
+// stateChanges numbers channel state changes and keeps the latest
+// STATE_CHANGES_MAX for /api/state/changes. Revisions start at the startup
+// time in microseconds, so they keep increasing across restarts and a
+// revision from an earlier run always asks for a full reload.
+type stateChanges struct {
+	max int
+
+	mu       sync.Mutex
+	revision int64
+	changes  []StateChange // oldest first
+}
+
+func (c *Client) loadStateChanges() {
+	c.changes.max = max(intenv("STATE_CHANGES_MAX", 1000), 1)
+	c.changes.revision = time.Now().UnixMicro()
+}
+
+// recordChange numbers a change to the channel state
+func (c *Client) recordChange(change StateChange) {
+	s := &c.changes
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revision++
+	change.Revision = s.revision
+	// Channels as keyed in /api/state
+	change.Channel = strings.ToLower(change.Channel)
+	for i, ch := range change.Channels {
+		change.Channels[i] = strings.ToLower(ch)
+	}
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	if len(s.changes) >= s.max {
+		s.changes = append(s.changes[:0], s.changes[len(s.changes)-s.max+1:]...)
+	}
+	s.changes = append(s.changes, change)
+}
+
+// StateRevision returns the revision of the current channel state
+func (c *Client) StateRevision() int64 {
+	c.changes.mu.Lock()
+	defer c.changes.mu.Unlock()
+	return c.changes.revision
+}
+
+// StateChangesSince returns the changes after revision since and the
+// current revision. reset is set when the changes since then are no longer
+// kept, or since is not a revision of this run; the consumer then reloads
+// /api/state instead.
+func (c *Client) StateChangesSince(since int64) (changes []StateChange, revision int64, reset bool) {
+	s := &c.changes
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldest := s.revision + 1
+	if len(s.changes) > 0 {
+		oldest = s.changes[0].Revision
+	}
+	if since > s.revision || since < oldest-1 {
+		return []StateChange{}, s.revision, true
+	}
+	i := len(s.changes) - int(s.revision-since)
+	return append([]StateChange{}, s.changes[i:]...), s.revision, false
+}
+
+// userModes returns a user's modes in a channel
+func (c *Client) userModes(channel, nick string) string {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+	if state := c.channelStates[strings.ToLower(channel)]; state != nil {
+		return state.Users[nick]
+	}
+	return ""
+}
+
+// trackChanges records the channel state changes of events, after the
+// state tracker applied them
+func (c *Client) trackChanges(e Event) {
+	if e.Replayed {
+		return
+	}
+	change := StateChange{Time: e.Time, Channel: e.Target, Nick: e.Sender}
+	switch e.Type {
+	case "join":
+		if e.Rejoin {
+			// Netsplit users were kept in the state
+			return
+		}
+		change.Type = "user_joined"
+		if e.Self {
+			change.Type = "channel_joined"
+		}
+	case "part", "kick":
+		change.Type, change.Reason = "user_left", e.Type
+		if e.Type == "kick" {
+			change.Nick, change.By = e.Nick, e.Sender
+		}
+		if e.Self {
+			change.Type, change.Nick = "channel_left", ""
+		}
+	case "quit":
+		if e.Netsplit != "" {
+			return
+		}
+		for _, ch := range e.Channels {
+			c.recordChange(StateChange{Time: e.Time, Type: "user_left", Channel: ch, Nick: e.Sender, Reason: "quit"})
+		}
+		return
+	case "nick":
+		change = StateChange{Time: e.Time, Type: "nick_changed", Nick: e.Sender, NewNick: e.Nick, Channels: e.Channels}
+	case "topic":
+		change.Type, change.Topic, change.Nick, change.By = "topic", e.Text, "", e.Sender
+	case "mode":
+		if len(e.Args) < 2 || !isChannelName(e.Target) {
+			return
+		}
+		prefixes := c.prefixMap()
+		seen := make(map[string]bool)
+		for _, m := range c.ParseModeChange(e.Target, e.Args[1], e.Args[2:]) {
+			if !prefixes.isStatusMode(m.Mode) || seen[m.Nick] {
+				continue
+			}
+			seen[m.Nick] = true
+			c.recordChange(StateChange{Time: e.Time, Type: "user_modes", Channel: e.Target, Nick: m.Nick, Modes: c.userModes(e.Target, m.Nick), By: e.Sender})
+		}
+		return
+	default:
+		return
+	}
+	c.recordChange(change)
+}
+
+// handleStateChanges returns the channel state changes since a revision:
+// GET /api/state/changes?since=N
+func (a *API) handleStateChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		writeError(w, 400, codeInvalidRequest, "since must be a revision from /api/state or an earlier /api/state/changes")
+		return
+	}
+	changes, revision, reset := a.bot.StateChangesSince(since)
+	writeJSON(w, 200, map[string]any{
+		"revision": revision,
+		"changes":  changes,
+		"count":    len(changes),
+		"reset":    reset,
+	})
+}
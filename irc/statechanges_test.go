@@ -0,0 +1,104 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestStateChanges(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("token")
+	get := func(path string, out any) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		json.NewDecoder(rec.Body).Decode(out)
+		return rec.Code
+	}
+
+	var state struct {
+		Revision int64 `json:"revision"`
+	}
+	get("/api/state", &state)
+	if state.Revision == 0 {
+		t.Fatal("Expected a state revision")
+	}
+
+	client.handleLine(":TestBot!b@host JOIN #Test")
+	client.handleLine(":server 353 TestBot = #test :TestBot alice")
+	client.handleLine(":server 366 TestBot #test :End of /NAMES list")
+	client.handleLine(":bob!b@host JOIN #test")
+	client.handleLine(":alice!a@host MODE #test +ov bob bob")
+	client.handleLine(":alice!a@host TOPIC #test :new topic")
+	client.handleLine(":bob!b@host NICK :robert")
+	client.handleLine(":alice!a@host KICK #test robert :bye")
+	client.handleLine(":alice!a@host QUIT :gone")
+	client.handleLine(":TestBot!b@host PART #test")
+
+	type change struct {
+		Revision int64  `json:"revision"`
+		Type     string `json:"type"`
+		Channel  string `json:"channel"`
+		Nick     string `json:"nick"`
+		NewNick  string `json:"new_nick"`
+		Modes    string `json:"modes"`
+		Topic    string `json:"topic"`
+		Reason   string `json:"reason"`
+	}
+	var out struct {
+		Revision int64    `json:"revision"`
+		Changes  []change `json:"changes"`
+		Reset    bool     `json:"reset"`
+	}
+	get("/api/state/changes?since="+strconv.FormatInt(state.Revision, 10), &out)
+	want := []change{
+		{Type: "channel_joined", Channel: "#test", Nick: "TestBot"},
+		{Type: "channel_synced", Channel: "#test"},
+		{Type: "user_joined", Channel: "#test", Nick: "bob"},
+		{Type: "user_modes", Channel: "#test", Nick: "bob", Modes: "ov"},
+		{Type: "topic", Channel: "#test", Topic: "new topic"},
+		{Type: "nick_changed", Nick: "bob", NewNick: "robert"},
+		{Type: "user_left", Channel: "#test", Nick: "robert", Reason: "kick"},
+		{Type: "user_left", Channel: "#test", Nick: "alice", Reason: "quit"},
+		{Type: "channel_left", Channel: "#test", Reason: "part"},
+	}
+	if out.Reset || len(out.Changes) != len(want) || out.Revision != state.Revision+int64(len(want)) {
+		t.Fatalf("Expected %d changes up to %d, got %+v", len(want), state.Revision+int64(len(want)), out)
+	}
+	for i, got := range out.Changes {
+		want[i].Revision = state.Revision + int64(i) + 1
+		if got != want[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], got)
+		}
+	}
+
+	// Only newer changes, and none once caught up
+	get("/api/state/changes?since="+strconv.FormatInt(out.Revision-1, 10), &out)
+	if len(out.Changes) != 1 || out.Changes[0].Type != "channel_left" {
+		t.Errorf("Expected the last change, got %+v", out.Changes)
+	}
+	get("/api/state/changes?since="+strconv.FormatInt(out.Revision, 10), &out)
+	if len(out.Changes) != 0 || out.Reset {
+		t.Errorf("Expected no changes, got %+v", out)
+	}
+
+	// Revisions no longer kept, or from another run, ask for a reload
+	client.changes.max = 2
+	client.handleLine(":TestBot!b@host JOIN #other")
+	client.handleLine(":carol!c@host JOIN #other")
+	client.handleLine(":dave!d@host JOIN #other")
+	for _, since := range []int64{state.Revision, out.Revision + 100} {
+		get("/api/state/changes?since="+strconv.FormatInt(since, 10), &out)
+		if !out.Reset || len(out.Changes) != 0 {
+			t.Errorf("since=%d: expected a reset, got %+v", since, out)
+		}
+	}
+	if code := get("/api/state/changes", &out); code != 400 {
+		t.Errorf("Expected 400 without since, got %d", code)
+	}
+}
@@ -0,0 +1,134 @@
+package irc
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// stateGCInterval is how often stale state is collected
+const stateGCInterval = 5 * time.Minute
+
+// stateGC evicts what the bot knows about users and channels it no longer
+// shares a channel with
+type stateGC struct {
+	ttl      time.Duration // how long unused state is kept, 0 keeps it forever
+	maxUsers int           // cap on userInfo entries, 0 for no cap
+
+	orphaned map[string]time.Time // channel -> when it was first found not joined, guarded by channelStatesMu
+
+	usersEvicted    atomic.Int64
+	channelsEvicted atomic.Int64
+}
+
+// StateGCResult reports one collection and the map sizes after it
+type StateGCResult struct {
+	UsersEvicted    int `json:"users_evicted"`
+	ChannelsEvicted int `json:"channels_evicted"`
+	Users           int `json:"users"`
+	Channels        int `json:"channels"`
+}
+
+func (c *Client) loadStateGC() {
+	c.gc.ttl = time.Duration(intenv("STATE_TTL_MINUTES", 60)) * time.Minute
+	c.gc.maxUsers = intenv("USER_INFO_MAX", 10000)
+	c.gc.orphaned = make(map[string]time.Time)
+}
+
+// stateGCLoop collects stale state until done is closed
+func (c *Client) stateGCLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(stateGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if r := c.CollectState(time.Now()); r.UsersEvicted > 0 || r.ChannelsEvicted > 0 {
+				log.Printf("State GC evicted %d users and %d channels (%d users, %d channels left)",
+					r.UsersEvicted, r.ChannelsEvicted, r.Users, r.Channels)
+			}
+		}
+	}
+}
+
+// CollectState drops channel state for channels the bot left more than the
+// TTL ago, and user info not updated within the TTL for users outside the
+// joined channels. When user info is still over its cap, the least recently
+// updated of those users go first.
+func (c *Client) CollectState(now time.Time) StateGCResult {
+	var result StateGCResult
+	joined := make(map[string]bool)
+	for _, ch := range c.Channels() {
+		joined[strings.ToLower(ch)] = true
+	}
+
+	present := make(map[string]bool) // users in a joined channel are never evicted
+	c.channelStatesMu.Lock()
+	for name, state := range c.channelStates {
+		if joined[name] {
+			delete(c.gc.orphaned, name)
+			for nick := range state.Users {
+				present[strings.ToLower(nick)] = true
+			}
+			continue
+		}
+		since, ok := c.gc.orphaned[name]
+		if !ok {
+			c.gc.orphaned[name] = now
+		} else if c.gc.ttl > 0 && now.Sub(since) >= c.gc.ttl {
+			delete(c.channelStates, name)
+			delete(c.gc.orphaned, name)
+			result.ChannelsEvicted++
+		}
+	}
+	for name := range c.gc.orphaned {
+		if c.channelStates[name] == nil {
+			delete(c.gc.orphaned, name)
+		}
+	}
+	result.Channels = len(c.channelStates)
+	c.channelStatesMu.Unlock()
+
+	c.userInfoMu.Lock()
+	var candidates []string
+	for nick, info := range c.userInfo {
+		if present[nick] {
+			continue
+		}
+		if c.gc.ttl > 0 && now.Sub(info.updated) >= c.gc.ttl {
+			delete(c.userInfo, nick)
+			result.UsersEvicted++
+			continue
+		}
+		candidates = append(candidates, nick)
+	}
+	if excess := len(c.userInfo) - c.gc.maxUsers; c.gc.maxUsers > 0 && excess > 0 {
+		sort.Slice(candidates, func(i, j int) bool {
+			return c.userInfo[candidates[i]].updated.Before(c.userInfo[candidates[j]].updated)
+		})
+		for _, nick := range candidates[:min(excess, len(candidates))] {
+			delete(c.userInfo, nick)
+			result.UsersEvicted++
+		}
+	}
+	result.Users = len(c.userInfo)
+	c.userInfoMu.Unlock()
+
+	c.gc.usersEvicted.Add(int64(result.UsersEvicted))
+	c.gc.channelsEvicted.Add(int64(result.ChannelsEvicted))
+	return result
+}
+
+// stateSizes returns the number of userInfo and channelStates entries
+func (c *Client) stateSizes() (users, channels int) {
+	c.userInfoMu.RLock()
+	users = len(c.userInfo)
+	c.userInfoMu.RUnlock()
+	c.channelStatesMu.RLock()
+	channels = len(c.channelStates)
+	c.channelStatesMu.RUnlock()
+	return users, channels
+}
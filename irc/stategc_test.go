@@ -0,0 +1,47 @@
+package irc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectState(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	client.gc.ttl = time.Hour
+	client.gc.maxUsers = 3
+
+	client.handleLine(":TestBot!b@host JOIN #test")
+	client.handleLine(":alice!a@host JOIN #test")
+	client.updateUserInfo("alice", func(info *UserInfo) { info.RealName = "Alice" })
+	client.updateUserInfo("bob", func(info *UserInfo) { info.RealName = "Bob" })
+	// State for a channel the bot is not in, e.g. from a TOPIC query
+	client.handleLine(":server 332 TestBot #other :Topic")
+
+	now := time.Now()
+	if r := client.CollectState(now); r.UsersEvicted != 0 || r.ChannelsEvicted != 0 {
+		t.Fatalf("Nothing should be evicted yet, got %+v", r)
+	}
+
+	r := client.CollectState(now.Add(2 * time.Hour))
+	if r.ChannelsEvicted != 1 || r.Channels != 1 {
+		t.Errorf("Expected #other to be evicted, got %+v", r)
+	}
+	if client.getUserInfo("bob") != nil || client.getUserInfo("alice") == nil {
+		t.Errorf("Expected bob to be evicted and alice, who is in #test, kept (%+v)", r)
+	}
+
+	// Over the cap, the least recently updated users outside channels go first
+	for _, nick := range []string{"u1", "u2", "u3", "u4"} {
+		client.updateUserInfo(nick, func(*UserInfo) {})
+		time.Sleep(time.Millisecond)
+	}
+	r = client.CollectState(time.Now())
+	if r.Users != 3 || client.getUserInfo("u1") != nil || client.getUserInfo("u2") != nil || client.getUserInfo("u4") == nil {
+		t.Errorf("Expected u1 and u2 to be evicted, got %+v", r)
+	}
+	if client.gc.usersEvicted.Load() != 3 || client.gc.channelsEvicted.Load() != 1 {
+		t.Errorf("Unexpected eviction counters %d, %d", client.gc.usersEvicted.Load(), client.gc.channelsEvicted.Load())
+	}
+}
@@ -0,0 +1,123 @@
+package irc
+
+import (
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// publicStatus is the unauthenticated GET /status, for uptime monitors that
+// should not hold the API token. It shows nothing about channels, users or
+// the server beyond counts.
+type publicStatus struct {
+	perMinute int // requests per client address per minute, 0 for no limit
+
+	mu      sync.Mutex
+	clients map[string]*statusWindow // remote IP -> requests in the current minute
+}
+
+type statusWindow struct {
+	start time.Time
+	count int
+}
+
+// PublicStatus is the body of GET /status
+type PublicStatus struct {
+	Connected     bool   `json:"connected"`
+	State         string `json:"state"`
+	Uptime        string `json:"uptime"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Channels      int    `json:"channels"`
+	Version       string `json:"version"`
+}
+
+func (c *Client) loadPublicStatus() {
+	c.publicStatus.perMinute = intenv("PUBLIC_STATUS_PER_MINUTE", 30)
+	if c.publicStatus.perMinute < 0 {
+		c.publicStatus.perMinute = 0
+	}
+}
+
+// allow counts a request from addr and reports whether it fits the limit,
+// and if not, how long until it would
+func (s *publicStatus) allow(addr string, now time.Time) (bool, time.Duration) {
+	if s.perMinute == 0 {
+		return true, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients == nil {
+		s.clients = make(map[string]*statusWindow)
+	}
+	// Forget finished windows now and then, so scanners cannot grow the map
+	if len(s.clients) > 1000 {
+		for a, w := range s.clients {
+			if now.Sub(w.start) >= time.Minute {
+				delete(s.clients, a)
+			}
+		}
+	}
+	w := s.clients[addr]
+	if w == nil || now.Sub(w.start) >= time.Minute {
+		w = &statusWindow{start: now}
+		s.clients[addr] = w
+	}
+	if w.count >= s.perMinute {
+		return false, w.start.Add(time.Minute).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// PublicStatus returns the non-sensitive status shown by GET /status
+func (c *Client) PublicStatus() PublicStatus {
+	uptime := time.Since(c.startTime)
+	state := c.State()
+	return PublicStatus{
+		Connected:     state == StateConnected,
+		State:         state.String(),
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Channels:      len(c.Channels()),
+		Version:       Version,
+	}
+}
+
+// handleStatus serves GET /status as JSON, or as a small page to browsers
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+	if ok, wait := a.bot.publicStatus.allow(addr, time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeError(w, 429, codeRateLimited, "too many status requests, retry later")
+		return
+	}
+	status := a.bot.PublicStatus()
+	w.Header().Set("Cache-Control", "no-cache")
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		writeJSON(w, 200, status)
+		return
+	}
+	connected := "disconnected"
+	if status.Connected {
+		connected = "connected"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Hanna status</title></head>
+<body><h1>Hanna is %s</h1>
+<p>State: %s<br>Uptime: %s<br>Channels: %d<br>Version: %s</p>
+</body></html>
+`, connected, html.EscapeString(status.State), status.Uptime, status.Channels, html.EscapeString(status.Version))
+}
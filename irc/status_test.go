@@ -0,0 +1,53 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicStatus(t *testing.T) {
+	t.Setenv("PUBLIC_STATUS_PER_MINUTE", "2")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	markConnected(client)
+	client.handleLine(":TestBot!b@host JOIN #secret")
+	api := client.CreateAPI("token")
+	get := func(addr, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/status", nil)
+		req.RemoteAddr = addr
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("192.0.2.1:1234", "")
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200 without a token, got %d %s", rec.Code, rec.Body.String())
+	}
+	var status PublicStatus
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if !status.Connected || status.Channels != 1 || status.Version != Version {
+		t.Errorf("Unexpected status: %s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "#secret") || strings.Contains(rec.Body.String(), "TestBot") {
+		t.Errorf("Expected no channel names or nick in the public status: %s", rec.Body.String())
+	}
+
+	if rec := get("192.0.2.1:1235", "text/html"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "Hanna is connected") {
+		t.Errorf("Expected an HTML page, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = get("192.0.2.1:1236", "")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After over the limit, got %d %v", rec.Code, rec.Header())
+	}
+	// Other addresses have their own limit
+	if rec := get("192.0.2.2:1234", ""); rec.Code != 200 {
+		t.Errorf("Expected 200 for another address, got %d", rec.Code)
+	}
+}
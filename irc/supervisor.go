@@ -0,0 +1,78 @@
+package irc
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type Supervisor struct {
+	client *Client
+	ctx    context.Context // cancelled by Stop
+	cancel context.CancelFunc
+}
+
+func NewSupervisor(c *Client) *Supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Supervisor{client: c, ctx: ctx, cancel: cancel}
+}
+
+func (s *Supervisor) Run() {
+	backoff := time.Second
+	max := 2 * time.Minute
+
+	s.client.onReady = func() {
+		log.Printf("Connected as %s", s.client.Nick())
+		backoff = time.Second
+	}
+
+	for {
+		if s.ctx.Err() != nil {
+			log.Printf("Supervisor stopping")
+			return
+		}
+
+		log.Printf("Attempting to connect...")
+		if err := s.client.Dial(s.ctx); err != nil {
+			log.Printf("dial error: %v", err)
+		}
+
+		// Wait until the connection (or its registration) ends
+		for s.client.State() != StateDisconnected && sleepContext(s.ctx, 500*time.Millisecond) {
+		}
+		if info := s.client.StateInfo(); info.RegistrationFailed() {
+			log.Printf("registration failed: %s", info.Reason)
+		}
+
+		// Backoff before reconnect
+		log.Printf("disconnected; reconnecting in %s", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			log.Printf("Supervisor stopping during backoff")
+			return
+		}
+		backoff *= 2
+		if backoff > max {
+			backoff = max
+		}
+	}
+}
+
+func (s *Supervisor) Stop() {
+	log.Printf("Stopping supervisor")
+	s.cancel()
+	_ = s.client.Close()
+}
+
+// sleepContext waits for d and reports false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,260 @@
+package irc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client tags for typing notifications and reactions, see
+// https://ircv3.net/specs/client-tags/typing and +draft/react
+const (
+	typingTag = "+typing"
+	reactTag  = "+draft/react"
+	replyTag  = "+draft/reply"
+)
+
+// maxClientTagsLen is the most bytes of client-only tags a line may carry
+const maxClientTagsLen = 4094
+
+const (
+	typingInterval = 3 * time.Second  // active is repeated this often while a reply is pending
+	typingTimeout  = 60 * time.Second // give up on a reply that never comes
+)
+
+// errNoMessageTags is returned for TAGMSG when the server has not
+// acknowledged the message-tags capability
+var errNoMessageTags = errors.New("the server does not support message-tags")
+
+var tagEscaper = strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`)
+
+// formatTags returns "@key=value;key2 " for tags, sorted by key, or "" for none
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		b.WriteString(key)
+		if value := tags[key]; value != "" {
+			b.WriteByte('=')
+			b.WriteString(tagEscaper.Replace(value))
+		}
+	}
+	return "@" + b.String() + " "
+}
+
+// validClientTag reports whether key is a client-only tag: "+", an optional
+// vendor and "/", then letters, digits and hyphens
+func validClientTag(key string) bool {
+	name, ok := strings.CutPrefix(key, "+")
+	if !ok {
+		return false
+	}
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		vendor := name[:i]
+		name = name[i+1:]
+		if vendor == "" || strings.ContainsFunc(vendor, func(r rune) bool {
+			return !(r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		}) {
+			return false
+		}
+	}
+	return name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+}
+
+// checkClientTags validates tags an API caller wants attached to a message
+func checkClientTags(tags map[string]string) error {
+	for key := range tags {
+		if !validClientTag(key) {
+			return fmt.Errorf("invalid client tag %q: client-only tags start with +", key)
+		}
+	}
+	if n := len(formatTags(tags)); n > maxClientTagsLen+2 {
+		return fmt.Errorf("tags too long: %d bytes, at most %d", n-2, maxClientTagsLen)
+	}
+	return nil
+}
+
+// Tagmsg sends a TAGMSG carrying only client tags
+func (c *Client) Tagmsg(target string, tags map[string]string) error {
+	if !c.messageTags.Load() {
+		return errNoMessageTags
+	}
+	return c.rawf("%sTAGMSG %s", formatTags(tags), target)
+}
+
+// PrivmsgTags sends a message like Privmsg with client tags on every line;
+// tags need the message-tags capability
+func (c *Client) PrivmsgTags(target, msg string, tags map[string]string) error {
+	if len(tags) > 0 && !c.messageTags.Load() {
+		return errNoMessageTags
+	}
+	return c.privmsg(formatTags(tags), target, msg)
+}
+
+// validTypingState reports whether state is a +typing value
+func validTypingState(state string) bool {
+	return state == "active" || state == "paused" || state == "done"
+}
+
+// SendTyping sends a typing notification: active, paused or done. Paused and
+// done also end automatic typing in target.
+func (c *Client) SendTyping(target, state string) error {
+	if !validTypingState(state) {
+		return fmt.Errorf("invalid typing state %q", state)
+	}
+	if state != "active" {
+		c.stopTyping(target)
+	}
+	return c.Tagmsg(target, map[string]string{typingTag: state})
+}
+
+// React sends an emoji reaction to the message with the given msgid
+func (c *Client) React(target, msgid, emoji string) error {
+	return c.Tagmsg(target, map[string]string{reactTag: emoji, replyTag: msgid})
+}
+
+// typingTracker remembers where the bot is typing while a trigger endpoint
+// works on a reply
+type typingTracker struct {
+	mu     sync.Mutex
+	active map[string]chan struct{} // lower-cased target -> closed to stop
+}
+
+// startTyping shows the bot typing in target until it sends a message there
+// or typingTimeout passes; it does nothing without message-tags
+func (c *Client) startTyping(target string) {
+	if target == "" || !c.messageTags.Load() {
+		return
+	}
+	key := strings.ToLower(target)
+	c.typing.mu.Lock()
+	if _, ok := c.typing.active[key]; ok {
+		c.typing.mu.Unlock()
+		return
+	}
+	if c.typing.active == nil {
+		c.typing.active = make(map[string]chan struct{})
+	}
+	stop := make(chan struct{})
+	c.typing.active[key] = stop
+	c.typing.mu.Unlock()
+
+	go c.safely("typing", func() { c.typingLoop(target, key, stop) })
+}
+
+func (c *Client) typingLoop(target, key string, stop chan struct{}) {
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(typingTimeout)
+	defer timeout.Stop()
+	for {
+		if err := c.Tagmsg(target, map[string]string{typingTag: "active"}); err != nil {
+			c.endTyping(key, stop)
+			return
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-timeout.C:
+			c.endTyping(key, stop)
+			c.Tagmsg(target, map[string]string{typingTag: "done"})
+			return
+		}
+	}
+}
+
+// endTyping forgets a typing loop that ended on its own
+func (c *Client) endTyping(key string, stop chan struct{}) {
+	c.typing.mu.Lock()
+	defer c.typing.mu.Unlock()
+	if c.typing.active[key] == stop {
+		delete(c.typing.active, key)
+	}
+}
+
+// stopTyping ends automatic typing in target; a message sent there clears
+// the notification for other clients, so no done is sent
+func (c *Client) stopTyping(target string) {
+	key := strings.ToLower(target)
+	c.typing.mu.Lock()
+	defer c.typing.mu.Unlock()
+	if stop, ok := c.typing.active[key]; ok {
+		delete(c.typing.active, key)
+		close(stop)
+	}
+}
+
+// isTyping reports whether automatic typing is running in target
+func (c *Client) isTyping(target string) bool {
+	c.typing.mu.Lock()
+	defer c.typing.mu.Unlock()
+	_, ok := c.typing.active[strings.ToLower(target)]
+	return ok
+}
+
+// typingTarget returns where the reply to a trigger event goes, or "" for
+// events that do not expect one: mentions and private messages to the bot
+func (c *Client) typingTarget(payload TriggerPayload) string {
+	switch {
+	case payload.EventType == "mention" && isChannelName(payload.Target):
+		return payload.Target
+	case payload.EventType == "mention", payload.EventType == "pm",
+		payload.EventType == "privmsg" && strings.EqualFold(payload.Target, c.Nick()):
+		return payload.Sender
+	}
+	return ""
+}
+
+// handleTagmsg publishes every TAGMSG with its tags, and typing
+// notifications and reactions as their own events
+func (c *Client) handleTagmsg(prefix, target string, args []string, tags map[string]string) {
+	if c.batchKind(tags) == "chathistory" {
+		return
+	}
+	c.markBot(prefix, tags)
+	sender := strings.Split(prefix, "!")[0]
+	self := strings.EqualFold(sender, c.Nick())
+	c.bus.Publish(Event{
+		Type: "tagmsg", Prefix: prefix, Sender: sender, Target: target,
+		Args: args, Message: clientTagSummary(tags), Tags: tags, Self: self,
+	})
+	if state, ok := tags[typingTag]; ok && validTypingState(state) {
+		c.bus.Publish(Event{
+			Type: "typing", Prefix: prefix, Sender: sender, Target: target,
+			Args: args, Text: state, Message: state, Tags: tags, Self: self,
+		})
+	}
+	if emoji := tags[reactTag]; emoji != "" {
+		c.bus.Publish(Event{
+			Type: "reaction", Prefix: prefix, Sender: sender, Target: target,
+			Args: args, Text: emoji, Message: emoji, Tags: tags, Self: self,
+		})
+	}
+}
+
+// clientTagSummary lists the client-only tags of a TAGMSG as key=value pairs
+func clientTagSummary(tags map[string]string) string {
+	client := make(map[string]string)
+	for key, value := range tags {
+		if strings.HasPrefix(key, "+") {
+			client[key] = value
+		}
+	}
+	return strings.TrimSpace(strings.TrimPrefix(formatTags(client), "@"))
+}
@@ -0,0 +1,193 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTags(t *testing.T) {
+	got := formatTags(map[string]string{"+typing": "active", "+draft/react": "a b;c\\", "+flag": ""})
+	want := `@+draft/react=a\sb\:c\\;+flag;+typing=active `
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := formatTags(nil); got != "" {
+		t.Errorf("Expected no prefix without tags, got %q", got)
+	}
+}
+
+func TestIncomingTagmsg(t *testing.T) {
+	received := newTriggerRecorder(t, "typing", "reaction")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine("@+typing=active :alice!a@host TAGMSG #test")
+	if p := expectTrigger(t, received); p.EventType != "typing" || p.Sender != "alice" || p.Target != "#test" || p.Message != "active" {
+		t.Errorf("Unexpected typing event: %+v", p)
+	}
+
+	client.handleLine(`@+draft/react=\:thumbsup\:;+draft/reply=abc123 :bob!b@host TAGMSG #test`)
+	p := expectTrigger(t, received)
+	if p.EventType != "reaction" || p.Message != ";thumbsup;" || p.MessageTags["+draft/reply"] != "abc123" {
+		t.Errorf("Unexpected reaction event: %+v", p)
+	}
+
+	// Unknown states and the bot's own echoes are not forwarded
+	client.handleLine("@+typing=thinking :alice!a@host TAGMSG #test")
+	client.handleLine("@+typing=active :TestBot!b@host TAGMSG #test")
+	expectNoTrigger(t, received)
+}
+
+func TestTagmsgAPI(t *testing.T) {
+	client := NewClient()
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	api := client.CreateAPI("token")
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/tagmsg", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"target":"#test","typing":"active"}`); rec.Code != 409 || !strings.Contains(rec.Body.String(), "conflict") {
+		t.Errorf("Expected 409 without message-tags, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	client.messageTags.Store(true)
+	for _, tt := range []struct {
+		body string
+		code int
+		line string
+	}{
+		{`{"target":"#test","typing":"active"}`, 200, "@+typing=active TAGMSG #test"},
+		{`{"target":"#test","react":"👍","reply_to":"abc"}`, 200, "@+draft/react=👍;+draft/reply=abc TAGMSG #test"},
+		{`{"target":"#test","typing":"thinking"}`, 400, ""},
+		{`{"target":"#test","react":"👍"}`, 400, ""},
+		{`{"target":"#test"}`, 400, ""},
+	} {
+		lines = nil
+		rec := post(tt.body)
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d %s", tt.body, tt.code, rec.Code, rec.Body.String())
+		}
+		if tt.line != "" && (len(lines) != 1 || lines[0] != tt.line) {
+			t.Errorf("%s: expected %q, got %q", tt.body, tt.line, lines)
+		}
+	}
+}
+
+func TestAutoTyping(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	sent := make(chan string, 10)
+	client.testRawCapture = func(line string) { sent <- line }
+	client.messageTags.Store(true)
+
+	target := client.typingTarget(TriggerPayload{EventType: "mention", Sender: "alice", Target: "#test"})
+	if target != "#test" {
+		t.Errorf("Expected a mention to be answered in the channel, got %q", target)
+	}
+	if target := client.typingTarget(TriggerPayload{EventType: "privmsg", Sender: "alice", Target: "TestBot"}); target != "alice" {
+		t.Errorf("Expected a private message to be answered to the sender, got %q", target)
+	}
+	if target := client.typingTarget(TriggerPayload{EventType: "privmsg", Sender: "alice", Target: "#test"}); target != "" {
+		t.Errorf("Expected no typing for channel messages, got %q", target)
+	}
+
+	client.startTyping("#test")
+	client.startTyping("#TEST") // already typing there
+	select {
+	case line := <-sent:
+		if line != "@+typing=active TAGMSG #test" {
+			t.Errorf("Unexpected line %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the typing notification")
+	}
+
+	// The reply ends the typing
+	client.Privmsg("#test", "hello")
+	if line := <-sent; line != "PRIVMSG #test :hello" {
+		t.Errorf("Unexpected line %q", line)
+	}
+	if client.isTyping("#test") {
+		t.Error("Expected typing to stop once the reply was sent")
+	}
+}
+
+func TestValidClientTag(t *testing.T) {
+	for key, want := range map[string]bool{
+		"+typing":             true,
+		"+draft/react":        true,
+		"+example.com/my-tag": true,
+		"msgid":               false,
+		"+":                   false,
+		"+/name":              false,
+		"+example.com/":       false,
+		"+bad tag":            false,
+		"+bad_vendor!/name":   false,
+	} {
+		if got := validClientTag(key); got != want {
+			t.Errorf("validClientTag(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestTagmsgEvent(t *testing.T) {
+	received := newTriggerRecorder(t, "tagmsg")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine("@time=2024-01-01T00:00:00.000Z;+example.com/game=move\\se4 :alice!a@host TAGMSG #test")
+	p := expectTrigger(t, received)
+	if p.EventType != "tagmsg" || p.Sender != "alice" || p.Target != "#test" {
+		t.Errorf("Unexpected event: %+v", p)
+	}
+	if p.MessageTags["+example.com/game"] != "move e4" || p.MessageTags["time"] == "" {
+		t.Errorf("Expected all tags to be forwarded, got %v", p.MessageTags)
+	}
+	if p.Message != `+example.com/game=move\se4` {
+		t.Errorf("Expected the client tags as message, got %q", p.Message)
+	}
+}
+
+func TestSendAPIClientTags(t *testing.T) {
+	client := NewClient()
+	var lines []string
+	client.testRawCapture = func(line string) { lines = append(lines, line) }
+	api := client.CreateAPI("token")
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/send", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+	body := `{"target":"#test","message":"one\ntwo","tags":{"+draft/reply":"abc","+example.com/x":"a;b"}}`
+
+	if rec := post(body); rec.Code != 409 {
+		t.Errorf("Expected 409 without message-tags, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"target":"#test","message":"hi","tags":{"msgid":"x"}}`); rec.Code != 400 {
+		t.Errorf("Expected 400 for a server tag, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	client.messageTags.Store(true)
+	lines = nil
+	if rec := post(body); rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	want := []string{
+		`@+draft/reply=abc;+example.com/x=a\:b PRIVMSG #test :one`,
+		`@+draft/reply=abc;+example.com/x=a\:b PRIVMSG #test :two`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+}
@@ -0,0 +1,264 @@
+package irc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// TelegramConfig bridges IRC channels with Telegram groups
+type TelegramConfig struct {
+	Token    string            `json:"token"`
+	Mappings []TelegramMapping `json:"mappings"`
+	LoopProtection
+}
+
+// TelegramMapping relays one IRC channel to one Telegram chat. Events and
+// Users filter what goes to Telegram, like the trigger endpoint filters.
+type TelegramMapping struct {
+	Channel string   `json:"channel"`
+	ChatID  int64    `json:"chat_id"`
+	Events  []string `json:"events,omitempty"` // IRC events relayed to Telegram (default privmsg)
+	Users   []string `json:"users,omitempty"`  // only relay these IRC nicks (default everyone)
+}
+
+// telegramBridge posts IRC events with sendMessage and polls getUpdates for
+// messages to relay back
+type telegramBridge struct {
+	client  ircRelay
+	cfg     TelegramConfig
+	apiBase string
+	http    *http.Client
+	queue   *bridgeQueue
+	started time.Time // messages sent before the bridge started are skipped
+}
+
+func (c *Client) loadTelegramBridge() {
+	cfg := c.triggerConfig.Telegram
+	if cfg == nil {
+		return
+	}
+	if cfg.Token == "" || len(cfg.Mappings) == 0 {
+		log.Fatalf("FATAL: Telegram bridge requires a token and at least one mapping")
+	}
+	t := &telegramBridge{
+		client:  c,
+		cfg:     *cfg,
+		apiBase: telegramAPI,
+		http:    &http.Client{Timeout: 60 * time.Second},
+		started: time.Now(),
+	}
+	for i, m := range t.cfg.Mappings {
+		if m.Channel == "" || m.ChatID == 0 {
+			log.Fatalf("FATAL: Telegram mapping %d requires channel and chat_id", i)
+		}
+		t.cfg.Mappings[i].Events = validateBridgeEvents("Telegram", m.Events)
+	}
+	t.cfg.validate("Telegram")
+	t.queue = newBridgeQueue("Telegram", t.post)
+	c.telegram = t
+	c.bus.Subscribe("telegram", t.relayEvent)
+}
+
+// relayEvent queues the event for every mapping whose filters accept it
+func (t *telegramBridge) relayEvent(e Event) {
+	for _, mapping := range t.cfg.Mappings {
+		if len(mapping.Users) > 0 && !slices.ContainsFunc(mapping.Users, func(u string) bool { return strings.EqualFold(u, e.Sender) }) {
+			continue
+		}
+		bridged := func(ch string) bool { return strings.EqualFold(ch, mapping.Channel) }
+		for _, m := range bridgeMessages(e, mapping.Events, t.cfg.LoopProtection, bridged) {
+			m.Dest = strconv.FormatInt(mapping.ChatID, 10)
+			t.queue.push(t.client.context(), m)
+		}
+	}
+}
+
+// telegramResponse is the envelope of every Bot API reply
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// call invokes a Bot API method, waiting out rate limits
+func (t *telegramBridge) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", t.apiBase+"/bot"+t.cfg.Token+"/"+method, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := t.http.Do(req)
+		if err != nil {
+			// The URL holds the token, keep it out of logs
+			var uerr *url.Error
+			if errors.As(err, &uerr) {
+				err = uerr.Err
+			}
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		var r telegramResponse
+		err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: status %d: %w", method, resp.StatusCode, err)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && r.Parameters.RetryAfter > 0 {
+			if !sleepContext(ctx, time.Duration(r.Parameters.RetryAfter)*time.Second) {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if !r.OK {
+			return nil, fmt.Errorf("%s: %s", method, r.Description)
+		}
+		return r.Result, nil
+	}
+	return nil, fmt.Errorf("%s: still rate limited after retries", method)
+}
+
+// post sends one message with the IRC nick in bold
+func (t *telegramBridge) post(ctx context.Context, m bridgeMessage) error {
+	text := html.EscapeString(StripFormatting(m.Text))
+	nick := html.EscapeString(m.Nick)
+	switch {
+	case m.Nick == "":
+		text = "<i>" + text + "</i>"
+	case m.Action:
+		text = fmt.Sprintf("* <b>%s</b> %s", nick, text)
+	default:
+		text = fmt.Sprintf("<b>%s</b>: %s", nick, text)
+	}
+	_, err := t.call(ctx, "sendMessage", map[string]any{
+		"chat_id":                  m.Dest,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+	return err
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Date int64 `json:"date"`
+	From *struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Username  string `json:"username"`
+		IsBot     bool   `json:"is_bot"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text     string          `json:"text"`
+	Caption  string          `json:"caption"`
+	Photo    json.RawMessage `json:"photo"`
+	Video    json.RawMessage `json:"video"`
+	Voice    json.RawMessage `json:"voice"`
+	Document *struct {
+		FileName string `json:"file_name"`
+	} `json:"document"`
+	Sticker *struct {
+		Emoji string `json:"emoji"`
+	} `json:"sticker"`
+}
+
+// run long-polls getUpdates until ctx is cancelled
+func (t *telegramBridge) run(ctx context.Context) {
+	var offset int64
+	backoff := time.Second
+	for ctx.Err() == nil {
+		result, err := t.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         30,
+			"allowed_updates": []string{"message"},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Telegram bridge: polling failed: %v (retrying in %s)", err, backoff)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, 2*time.Minute)
+			continue
+		}
+		backoff = time.Second
+		var updates []telegramUpdate
+		if err := json.Unmarshal(result, &updates); err != nil {
+			log.Printf("Telegram bridge: invalid updates: %v", err)
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				t.relayMessage(u.Message)
+			}
+		}
+	}
+}
+
+// relayMessage posts a Telegram message to the IRC channels mapped to its chat
+func (t *telegramBridge) relayMessage(m *telegramMessage) {
+	if m.From == nil || m.From.IsBot || time.Unix(m.Date, 0).Before(t.started.Add(-time.Minute)) {
+		return
+	}
+	author := m.From.Username
+	if author == "" {
+		author = strings.TrimSpace(m.From.FirstName + " " + m.From.LastName)
+	}
+
+	// Media cannot be linked without exposing the bot token, so describe it
+	var media string
+	switch {
+	case m.Photo != nil:
+		media = "[photo]"
+	case m.Video != nil:
+		media = "[video]"
+	case m.Voice != nil:
+		media = "[voice message]"
+	case m.Document != nil:
+		media = "[file: " + m.Document.FileName + "]"
+	case m.Sticker != nil:
+		media = "[sticker " + m.Sticker.Emoji + "]"
+	}
+	text := m.Text
+	if media != "" {
+		text = strings.TrimSpace(media + " " + m.Caption)
+	}
+	if text == "" {
+		return
+	}
+
+	for _, mapping := range t.cfg.Mappings {
+		if mapping.ChatID == m.Chat.ID {
+			t.client.relayToIRC("Telegram", mapping.Channel, author, text, t.cfg.LoopProtection)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramBridge(t *testing.T) {
+	sent := make(chan map[string]any, 10)
+	polled := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]any
+		json.NewDecoder(r.Body).Decode(&params)
+		switch r.URL.Path {
+		case "/bottok/sendMessage":
+			sent <- params
+			fmt.Fprint(w, `{"ok":true,"result":{}}`)
+		case "/bottok/getUpdates":
+			select {
+			case polled <- true:
+				now := time.Now().Unix()
+				fmt.Fprintf(w, `{"ok":true,"result":[
+					{"update_id":1,"message":{"date":%d,"chat":{"id":-100},"from":{"first_name":"Old"},"text":"backlog"}},
+					{"update_id":2,"message":{"date":%d,"chat":{"id":-100},"from":{"username":"bot","is_bot":true},"text":"ignored"}},
+					{"update_id":3,"message":{"date":%d,"chat":{"id":-100},"from":{"first_name":"Ann","last_name":"Lee"},"text":"hi irc"}},
+					{"update_id":4,"message":{"date":%d,"chat":{"id":-100},"from":{"username":"bob"},"photo":[{}],"caption":"look"}}]}`,
+					now-3600, now, now, now)
+			default:
+				if offset, _ := params["offset"].(float64); offset != 5 {
+					t.Errorf("Expected offset 5 after the first poll, got %v", params["offset"])
+				}
+				<-r.Context().Done()
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("TRIGGER_CONFIG", `{"telegram":{"token":"tok","mappings":[
+		{"channel":"#test","chat_id":-100},
+		{"channel":"#ops","chat_id":-200,"events":["join","privmsg"],"users":["alice"]}]}}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	markConnected(client)
+	defer client.Close()
+	relayed := make(chan string, 10)
+	client.testRawCapture = func(s string) { relayed <- s }
+	client.telegram.apiBase = srv.URL
+
+	client.handleLine(":alice!a@host PRIVMSG #test :hello <all> \x02there\x02")
+	client.handleLine(":bob!b@host JOIN #ops")   // filtered by users
+	client.handleLine(":alice!a@host JOIN #ops") // second mapping relays joins
+	for _, want := range []map[string]any{
+		{"chat_id": "-100", "text": "<b>alice</b>: hello &lt;all&gt; there"},
+		{"chat_id": "-200", "text": "<i>alice joined #ops</i>"},
+	} {
+		select {
+		case got := <-sent:
+			if got["chat_id"] != want["chat_id"] || got["text"] != want["text"] || got["parse_mode"] != "HTML" {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %v", want)
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case <-relayed:
+			default:
+				return
+			}
+		}
+	}
+	drain() // NAMES requests caused by the joins
+
+	go client.telegram.run(client.context())
+	for _, want := range []string{"PRIVMSG #test :<Ann Lee> hi irc", "PRIVMSG #test :<bob> [photo] look"} {
+		select {
+		case line := <-relayed:
+			if line != want {
+				t.Errorf("Expected %q, got %q", want, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+	select {
+	case line := <-relayed:
+		if strings.Contains(line, "backlog") || strings.Contains(line, "ignored") {
+			t.Errorf("Unexpected relayed line %q", line)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
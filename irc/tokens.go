@@ -0,0 +1,393 @@
+package irc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFileCheck is how often API_TOKENS_FILE is checked for changes
+const tokenFileCheck = 5 * time.Second
+
+var (
+	errTokenNotFound = errors.New("unknown token id")
+	errLastToken     = errors.New("cannot revoke the last valid token")
+)
+
+// tokenStore holds every bearer token the API, gRPC and webhooks accept:
+// API_TOKEN, the API_TOKENS list, the lines of API_TOKENS_FILE and tokens
+// created with POST /api/tokens. Several tokens being valid at once lets a
+// credential be rotated without a restart. Only SHA-256 hashes are kept.
+type tokenStore struct {
+	mu      sync.Mutex
+	tokens  []*apiToken
+	revoked map[string]time.Time // token ID -> when it stops working
+
+	file        string
+	fileMod     time.Time
+	fileSize    int64
+	fileChecked time.Time
+}
+
+type apiToken struct {
+	id      string
+	name    string
+	source  string // env, file or api
+	hash    [sha256.Size]byte
+	created time.Time
+	expires time.Time // zero for never
+	manage  bool      // created with manage_tokens
+}
+
+// canManage reports whether the token may use /api/tokens: API_TOKEN,
+// API_TOKENS and API_TOKENS_FILE tokens always can, created ones only when
+// granted manage_tokens, so a token handed to a workflow cannot mint or
+// revoke others
+func (t *apiToken) canManage() bool {
+	return t.source != "api" || t.manage
+}
+
+// APIToken describes a token in GET /api/tokens; the secret is never shown
+type APIToken struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Source    string     `json:"source"` // env, file or api
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // expiry or end of a revocation grace period
+	Manage    bool       `json:"manage_tokens"`        // may list, create and revoke tokens
+}
+
+func newAPIToken(secret, name, source string, now time.Time) *apiToken {
+	hash := sha256.Sum256([]byte(secret))
+	return &apiToken{id: hex.EncodeToString(hash[:6]), name: name, source: source, hash: hash, created: now}
+}
+
+// loadTokens reads API_TOKENS, a comma-separated list of extra tokens, and
+// API_TOKENS_FILE, a file of one token per line (optionally "name token")
+// that is reloaded when it changes
+func (c *Client) loadTokens() {
+	now := time.Now()
+	for i, secret := range strings.Split(os.Getenv("API_TOKENS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			c.tokens.add(newAPIToken(secret, "API_TOKENS["+strconv.Itoa(i)+"]", "env", now))
+		}
+	}
+	c.tokens.file = strings.TrimSpace(os.Getenv("API_TOKENS_FILE"))
+	if c.tokens.file == "" {
+		return
+	}
+	if err := c.tokens.reloadFile(now); err != nil {
+		log.Fatalf("FATAL: Cannot read API_TOKENS_FILE: %v", err)
+	}
+}
+
+// setPrimary adds API_TOKEN, the token passed to CreateAPI and GRPCHandler
+func (s *tokenStore) setPrimary(secret string) {
+	if secret == "" {
+		return
+	}
+	t := newAPIToken(secret, "API_TOKEN", "env", time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, have := range s.tokens {
+		if have.id == t.id {
+			return
+		}
+	}
+	s.tokens = append(s.tokens, t)
+}
+
+func (s *tokenStore) add(t *apiToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, t)
+}
+
+// reloadFile replaces the file's tokens with its current lines, if it
+// changed since the last read
+func (s *tokenStore) reloadFile(now time.Time) error {
+	st, err := os.Stat(s.file)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.fileChecked = now
+	unchanged := st.ModTime().Equal(s.fileMod) && st.Size() == s.fileSize
+	s.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	f, err := os.Open(s.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var loaded []*apiToken
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		name, secret := "", fields[len(fields)-1]
+		if len(fields) > 1 {
+			name = strings.Join(fields[:len(fields)-1], " ")
+		}
+		loaded = append(loaded, newAPIToken(secret, name, "file", now))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.tokens[:0]
+	for _, t := range s.tokens {
+		if t.source != "file" {
+			kept = append(kept, t)
+		}
+	}
+	s.tokens = append(kept, loaded...)
+	s.fileMod, s.fileSize = st.ModTime(), st.Size()
+	log.Printf("Loaded %d API tokens from %s", len(loaded), s.file)
+	return nil
+}
+
+// maybeReload rereads API_TOKENS_FILE at most every tokenFileCheck. A file
+// that cannot be read keeps the tokens loaded before, rather than locking
+// every client out.
+func (s *tokenStore) maybeReload(now time.Time) {
+	s.mu.Lock()
+	due := s.file != "" && now.Sub(s.fileChecked) >= tokenFileCheck
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := s.reloadFile(now); err != nil {
+		log.Printf("Cannot reload API_TOKENS_FILE, keeping the current tokens: %v", err)
+	}
+}
+
+// expiry returns when a token stops working, from its own expiry or a
+// revocation, or the zero time for never
+func (s *tokenStore) expiry(t *apiToken) time.Time {
+	end := t.expires
+	if at, ok := s.revoked[t.id]; ok && (end.IsZero() || at.Before(end)) {
+		end = at
+	}
+	return end
+}
+
+func (s *tokenStore) active(t *apiToken, now time.Time) bool {
+	end := s.expiry(t)
+	return end.IsZero() || now.Before(end)
+}
+
+// configured reports whether any token was ever set, so a server without
+// one can say so instead of rejecting every token
+func (s *tokenStore) configured() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens) > 0
+}
+
+// valid reports whether secret is one of the active tokens
+func (s *tokenStore) valid(secret string) bool {
+	_, ok := s.lookup(secret)
+	return ok
+}
+
+// lookup returns a copy of the active token matching secret, its expires set
+// to when it stops working counting any revocation. Every token is compared,
+// in constant time, so the answer does not leak which one matched.
+func (s *tokenStore) lookup(secret string) (apiToken, bool) {
+	if secret == "" {
+		return apiToken{}, false
+	}
+	now := time.Now()
+	s.maybeReload(now)
+	hash := sha256.Sum256([]byte(secret))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found *apiToken
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.hash[:]) == 1 && s.active(t, now) {
+			found = t
+		}
+	}
+	if found == nil {
+		return apiToken{}, false
+	}
+	t := *found
+	t.expires = s.expiry(found)
+	return t, true
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	const pfx = "Bearer "
+	if !strings.HasPrefix(auth, pfx) {
+		return ""
+	}
+	return strings.TrimPrefix(auth, pfx)
+}
+
+// list returns the active tokens, oldest first
+func (s *tokenStore) list() []APIToken {
+	now := time.Now()
+	s.maybeReload(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []APIToken{}
+	for _, t := range s.tokens {
+		if !s.active(t, now) {
+			continue
+		}
+		info := APIToken{ID: t.id, Name: t.name, Source: t.source, CreatedAt: t.created, Manage: t.canManage()}
+		if end := s.expiry(t); !end.IsZero() {
+			info.ExpiresAt = &end
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+// create adds a random token, valid for ttl (0 for until revoked or restart),
+// that may manage tokens itself if manage is set
+func (s *tokenStore) create(name string, ttl time.Duration, manage bool) (string, APIToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", APIToken{}, err
+	}
+	secret := hex.EncodeToString(buf)
+	now := time.Now()
+	t := newAPIToken(secret, name, "api", now)
+	t.manage = manage
+	info := APIToken{ID: t.id, Name: name, Source: t.source, CreatedAt: now, Manage: manage}
+	if ttl > 0 {
+		t.expires = now.Add(ttl)
+		info.ExpiresAt = &t.expires
+	}
+	s.add(t)
+	return secret, info, nil
+}
+
+// revoke stops a token working after grace. It refuses to revoke the last
+// token that would still be valid afterwards, which would lock out the API.
+func (s *tokenStore) revoke(id string, grace time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var target *apiToken
+	others := 0
+	for _, t := range s.tokens {
+		switch {
+		case t.id == id && s.active(t, now):
+			target = t
+		case s.active(t, now.Add(grace)):
+			others++
+		}
+	}
+	if target == nil {
+		return errTokenNotFound
+	}
+	if others == 0 {
+		return errLastToken
+	}
+	if s.revoked == nil {
+		s.revoked = make(map[string]time.Time)
+	}
+	s.revoked[id] = now.Add(grace)
+	return nil
+}
+
+// tokenAdmin lets only tokens that canManage through. The tokens scope alone
+// does not restrict a request without X-Hanna-Hostmask or X-Hanna-Account.
+func (a *API) tokenAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if caller, ok := a.bot.tokens.lookup(bearerToken(r)); !ok || !caller.canManage() {
+			writeError(w, http.StatusForbidden, codeForbidden, "token cannot manage tokens")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// handleTokens lists tokens (GET) or creates one (POST): /api/tokens
+func (a *API) handleTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tokens := a.bot.tokens.list()
+		writeJSON(w, 200, map[string]any{"tokens": tokens, "count": len(tokens)})
+	case http.MethodPost:
+		var in struct {
+			Name      string `json:"name"`
+			ExpiresIn int    `json:"expires_in"` // seconds, 0 for no expiry
+			Manage    bool   `json:"manage_tokens"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.ExpiresIn < 0 {
+				writeError(w, 400, codeInvalidRequest, "invalid token request")
+				return
+			}
+		}
+		// A token that expires, or is being revoked, cannot create one that
+		// outlives it
+		ttl := time.Duration(in.ExpiresIn) * time.Second
+		if caller, _ := a.bot.tokens.lookup(bearerToken(r)); !caller.expires.IsZero() &&
+			(ttl == 0 || time.Now().Add(ttl).After(caller.expires)) {
+			writeError(w, http.StatusForbidden, codeForbidden, "token would outlive the calling token")
+			return
+		}
+		secret, info, err := a.bot.tokens.create(strings.TrimSpace(in.Name), ttl, in.Manage)
+		if err != nil {
+			writeError(w, 500, codeInternal, err.Error())
+			return
+		}
+		log.Printf("API token %s (%s) created", info.ID, info.Name)
+		writeJSON(w, 201, map[string]any{"token": secret, "info": info})
+	default:
+		writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleRevokeToken revokes a token, optionally after ?grace=seconds:
+// DELETE /api/tokens/{id}
+func (a *API) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	grace := 0
+	if g := r.URL.Query().Get("grace"); g != "" {
+		n, err := strconv.Atoi(g)
+		if err != nil || n < 0 {
+			writeError(w, 400, codeInvalidRequest, "grace must be a number of seconds")
+			return
+		}
+		grace = n
+	}
+	id := r.PathValue("id")
+	switch err := a.bot.tokens.revoke(id, time.Duration(grace)*time.Second); {
+	case errors.Is(err, errTokenNotFound):
+		writeError(w, 404, codeNotFound, err.Error())
+	case errors.Is(err, errLastToken):
+		writeError(w, 409, codeConflict, err.Error())
+	default:
+		log.Printf("API token %s revoked (grace %ds)", id, grace)
+		writeJSON(w, 200, map[string]any{"status": "revoked", "id": id, "grace_seconds": grace})
+	}
+}
@@ -0,0 +1,174 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tokenRequest(api http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTokenRotation(t *testing.T) {
+	t.Setenv("API_TOKENS", "second")
+	client := NewClient()
+	api := client.CreateAPI("first")
+
+	for _, token := range []string{"first", "second"} {
+		if rec := tokenRequest(api, "GET", "/api/state", token, ""); rec.Code != 200 {
+			t.Errorf("Expected %s to be valid, got %d", token, rec.Code)
+		}
+	}
+	if rec := tokenRequest(api, "GET", "/api/state", "wrong", ""); rec.Code != 401 {
+		t.Errorf("Expected 401 for a wrong token, got %d", rec.Code)
+	}
+
+	rec := tokenRequest(api, "POST", "/api/tokens", "first", `{"name":"ci","manage_tokens":true}`)
+	if rec.Code != 201 {
+		t.Fatalf("Expected 201 creating a token, got %d %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Token string   `json:"token"`
+		Info  APIToken `json:"info"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if len(created.Token) != 64 || created.Info.Name != "ci" || created.Info.Source != "api" {
+		t.Fatalf("Unexpected new token: %s", rec.Body.String())
+	}
+	if rec := tokenRequest(api, "GET", "/api/state", created.Token, ""); rec.Code != 200 {
+		t.Errorf("Expected the new token to be valid, got %d", rec.Code)
+	}
+
+	rec = tokenRequest(api, "GET", "/api/tokens", created.Token, "")
+	if strings.Contains(rec.Body.String(), created.Token) || strings.Contains(rec.Body.String(), `"first"`) {
+		t.Errorf("Expected no secrets in the token list: %s", rec.Body.String())
+	}
+	var list struct {
+		Tokens []APIToken `json:"tokens"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Tokens) != 3 {
+		t.Fatalf("Expected 3 tokens, got %s", rec.Body.String())
+	}
+	firstID := ""
+	for _, tok := range list.Tokens {
+		if tok.Name == "API_TOKEN" {
+			firstID = tok.ID
+		}
+	}
+
+	// Revoke the old token with a grace period, then right away
+	if rec := tokenRequest(api, "DELETE", "/api/tokens/"+firstID+"?grace=60", created.Token, ""); rec.Code != 200 {
+		t.Fatalf("Expected 200 revoking, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := tokenRequest(api, "GET", "/api/state", "first", ""); rec.Code != 200 {
+		t.Errorf("Expected the old token to work during the grace period, got %d", rec.Code)
+	}
+	if rec := tokenRequest(api, "DELETE", "/api/tokens/"+firstID, created.Token, ""); rec.Code != 200 {
+		t.Fatalf("Expected 200 revoking, got %d", rec.Code)
+	}
+	if rec := tokenRequest(api, "GET", "/api/state", "first", ""); rec.Code != 401 {
+		t.Errorf("Expected 401 for a revoked token, got %d", rec.Code)
+	}
+	if rec := tokenRequest(api, "DELETE", "/api/tokens/nope", created.Token, ""); rec.Code != 404 {
+		t.Errorf("Expected 404 for an unknown id, got %d", rec.Code)
+	}
+
+	// The last valid token cannot be revoked
+	second := newAPIToken("second", "", "", time.Now()).id
+	if rec := tokenRequest(api, "DELETE", "/api/tokens/"+second, created.Token, ""); rec.Code != 200 {
+		t.Fatalf("Expected 200 revoking, got %d", rec.Code)
+	}
+	if rec := tokenRequest(api, "DELETE", "/api/tokens/"+created.Info.ID, created.Token, ""); rec.Code != 409 {
+		t.Errorf("Expected 409 revoking the last token, got %d", rec.Code)
+	}
+}
+
+func TestTokenManagement(t *testing.T) {
+	client := NewClient()
+	api := client.CreateAPI("admin")
+	create := func(token, body string) (int, string) {
+		rec := tokenRequest(api, "POST", "/api/tokens", token, body)
+		var out struct {
+			Token string `json:"token"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out.Token
+	}
+
+	// A created token cannot manage tokens unless granted manage_tokens
+	_, plain := create("admin", `{"name":"workflow"}`)
+	if rec := tokenRequest(api, "GET", "/api/state", plain, ""); rec.Code != 200 {
+		t.Fatalf("Expected the created token to be valid, got %d", rec.Code)
+	}
+	adminID := newAPIToken("admin", "", "", time.Now()).id
+	for _, tc := range []struct{ method, path, body string }{
+		{"GET", "/api/tokens", ""},
+		{"POST", "/api/tokens", `{"name":"escalate"}`},
+		{"DELETE", "/api/tokens/" + adminID, ""},
+	} {
+		if rec := tokenRequest(api, tc.method, tc.path, plain, tc.body); rec.Code != 403 {
+			t.Errorf("%s %s: expected 403 for a token without manage_tokens, got %d", tc.method, tc.path, rec.Code)
+		}
+	}
+
+	// A short-lived token cannot create one that outlives it
+	_, short := create("admin", `{"name":"short","expires_in":60,"manage_tokens":true}`)
+	for body, want := range map[string]int{
+		`{"name":"forever"}`:                  403,
+		`{"name":"longer","expires_in":3600}`: 403,
+		`{"name":"shorter","expires_in":30}`:  201,
+	} {
+		if code, _ := create(short, body); code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, code)
+		}
+	}
+}
+
+func TestTokenScopeRequiresAdmin(t *testing.T) {
+	client := NewClient()
+	api := client.CreateAPI("token")
+	req := httptest.NewRequest("GET", "/api/tokens", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Hanna-Hostmask", "user!u@host")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 403 {
+		t.Errorf("Expected 403 for a user without the admin role, got %d", rec.Code)
+	}
+}
+
+func TestTokensFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	os.WriteFile(path, []byte("# deploy tokens\nold-deploy aaa\n"), 0o600)
+	t.Setenv("API_TOKENS_FILE", path)
+	client := NewClient()
+	api := client.CreateAPI("")
+
+	if rec := tokenRequest(api, "GET", "/api/state", "aaa", ""); rec.Code != 200 {
+		t.Fatalf("Expected the file token to be valid, got %d", rec.Code)
+	}
+	os.WriteFile(path, []byte("new-deploy bbbb\n"), 0o600)
+	client.tokens.mu.Lock()
+	client.tokens.fileChecked = time.Time{}
+	client.tokens.mu.Unlock()
+
+	if rec := tokenRequest(api, "GET", "/api/state", "bbbb", ""); rec.Code != 200 {
+		t.Errorf("Expected the new file token to be valid, got %d", rec.Code)
+	}
+	if rec := tokenRequest(api, "GET", "/api/state", "aaa", ""); rec.Code != 401 {
+		t.Errorf("Expected the removed file token to be rejected, got %d", rec.Code)
+	}
+}
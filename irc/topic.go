@@ -0,0 +1,63 @@
+package irc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Topic is a channel's topic as tracked from TOPIC, 332 and 333
+type Topic struct {
+	Channel string `json:"channel"`
+	Topic   string `json:"topic"`
+	SetBy   string `json:"set_by,omitempty"`
+	SetTime int64  `json:"set_time,omitempty"` // unix seconds
+}
+
+// updateTopic records a live TOPIC change for a channel the bot is in
+func (c *Client) updateTopic(e Event) {
+	c.channelStatesMu.Lock()
+	defer c.channelStatesMu.Unlock()
+	state := c.channelStates[strings.ToLower(e.Target)]
+	if state == nil {
+		return
+	}
+	setBy := e.Prefix
+	if setBy == "" {
+		setBy = e.Sender
+	}
+	state.Topic = e.Text
+	state.TopicSetBy = setBy
+	state.TopicSetTime = messageTime(e.Tags).Unix()
+}
+
+// Topic returns the tracked topic of a channel; ok is false when the bot
+// has no state for it
+func (c *Client) Topic(channel string) (topic Topic, ok bool) {
+	c.channelStatesMu.RLock()
+	defer c.channelStatesMu.RUnlock()
+	state := c.channelStates[strings.ToLower(channel)]
+	if state == nil {
+		return Topic{}, false
+	}
+	return Topic{Channel: state.Name, Topic: state.Topic, SetBy: state.TopicSetBy, SetTime: state.TopicSetTime}, true
+}
+
+// SetTopic sets a channel's topic; an empty topic clears it. The tracked
+// topic changes once the server confirms with a TOPIC message.
+func (c *Client) SetTopic(channel, topic string) error {
+	if !isChannelName(channel) || strings.ContainsAny(channel, " ,\r\n") {
+		return fmt.Errorf("invalid channel %q", channel)
+	}
+	if strings.ContainsAny(topic, "\r\n") {
+		return errors.New("topic must not contain newlines")
+	}
+	if err := c.checkLength("topic", "TOPICLEN", topic); err != nil {
+		return err
+	}
+	if err := c.checkChannelLength(channel); err != nil {
+		return err
+	}
+	c.rawf("TOPIC %s :%s", channel, topic)
+	return nil
+}
@@ -0,0 +1,52 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTopicTracking(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	api := client.CreateAPI("token")
+
+	client.handleLine(":TestBot!b@host JOIN #test")
+	client.handleLine(":server 332 TestBot #test :Old topic")
+	client.handleLine(":server 333 TestBot #test alice!a@host 1700000000")
+	client.handleLine("@time=2025-01-01T12:00:00.000Z :bob!b@host TOPIC #test :New topic")
+
+	req := httptest.NewRequest("GET", "/api/topic?channel=%23TEST", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	var topic Topic
+	if err := json.NewDecoder(rec.Body).Decode(&topic); err != nil {
+		t.Fatalf("Invalid JSON response %d: %v", rec.Code, err)
+	}
+	if topic.Topic != "New topic" || topic.SetBy != "bob!b@host" || topic.SetTime != 1735732800 {
+		t.Errorf("Unexpected topic %+v", topic)
+	}
+
+	req = httptest.NewRequest("POST", "/api/topic", strings.NewReader(`{"channel":"#test","topic":"Hello world"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != 200 || sent[len(sent)-1] != "TOPIC #test :Hello world" {
+		t.Errorf("Expected TOPIC command, got %d %v", rec.Code, sent)
+	}
+
+	client.handleLine(":server 005 TestBot TOPICLEN=5 :are supported by this server")
+	if err := client.SetTopic("#test", "Too long"); err == nil {
+		t.Error("Expected an error for a topic over TOPICLEN")
+	}
+	if err := client.SetTopic("nick", "Hi"); err == nil {
+		t.Error("Expected an error for a target that is not a channel")
+	}
+	if _, ok := client.Topic("#other"); ok {
+		t.Error("Expected no topic for a channel the bot is not in")
+	}
+}
@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// requestIDHeader carries correlation IDs: API callers may send one and
+// always get one back, and trigger webhooks receive the ID of their event,
+// so a workflow can pass it back when it replies through the API
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// newRequestID returns a random 16-character hex correlation ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts caller-supplied IDs that are safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID assigns each API request a correlation ID, taken from the
+// X-Request-ID header when it is valid, and logs it with the request
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			log.Printf("API %s %s [%s]", r.Method, r.URL.Path, id)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the correlation ID of an API request, or ""
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,79 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestIDAPI(t *testing.T) {
+	client := NewClient()
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("token")
+	send := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/send", strings.NewReader(`{"target":"#test","message":"hi"}`))
+		req.Header.Set("Authorization", "Bearer token")
+		if id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("loop-42")
+	if got := rec.Header().Get(requestIDHeader); got != "loop-42" {
+		t.Errorf("Expected the caller's request ID back, got %q", got)
+	}
+	var out struct{ ID string }
+	json.NewDecoder(rec.Body).Decode(&out)
+	if m, _ := client.SentMessage(out.ID); m.RequestID != "loop-42" {
+		t.Errorf("Expected the message to carry the request ID, got %+v", m)
+	}
+
+	for _, id := range []string{"", "bad id", strings.Repeat("x", 200)} {
+		if got := send(id).Header().Get(requestIDHeader); len(got) != 16 || got == id {
+			t.Errorf("Expected a generated request ID for %q, got %q", id, got)
+		}
+	}
+}
+
+func TestRequestIDTriggers(t *testing.T) {
+	type delivery struct {
+		header  string
+		payload TriggerPayload
+	}
+	received := make(chan delivery, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p TriggerPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		received <- delivery{r.Header.Get(requestIDHeader), p}
+	}))
+	defer srv.Close()
+
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"n8n":{"url":%q,"events":["privmsg","mention"]}}}`, srv.URL))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+	client.handleLine(":alice!a@host PRIVMSG #test :TestBot: ping")
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		select {
+		case d := <-received:
+			if d.payload.CorrelationID == "" || d.header != d.payload.CorrelationID {
+				t.Errorf("Expected matching correlation IDs in the header and payload, got %q and %q", d.header, d.payload.CorrelationID)
+			}
+			ids = append(ids, d.payload.CorrelationID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for trigger event")
+		}
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("The mention should share the message's correlation ID, got %q", ids)
+	}
+}
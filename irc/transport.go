@@ -0,0 +1,380 @@
+package irc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport opens the byte stream an IRC connection runs over
+type Transport interface {
+	Dial(ctx context.Context) (net.Conn, error)
+	String() string
+}
+
+// TransportFunc adapts a dial function to Transport, e.g. to connect through
+// a custom dialer or to an in-process server in tests
+type TransportFunc func(ctx context.Context) (net.Conn, error)
+
+func (f TransportFunc) Dial(ctx context.Context) (net.Conn, error) { return f(ctx) }
+func (f TransportFunc) String() string                             { return "custom transport" }
+
+// SetTransport replaces the transport built from IRC_ADDR; it applies from
+// the next Dial
+func (c *Client) SetTransport(t Transport) {
+	c.transport = t
+}
+
+// transport is selected by the scheme of IRC_ADDR:
+//
+//	host:port                       TCP, or TLS when IRC_TLS=1 (default)
+//	irc://host[:6667]               plain TCP
+//	ircs://host[:6697]              TLS
+//	ws://host[:80]/path             IRC over WebSocket
+//	wss://host[:443]/path           IRC over WebSocket with TLS
+//	socks5://[user:pass@]proxy:port|<any of the above>
+//
+// The SOCKS5 form tunnels the target through the proxy and lets the proxy
+// resolve its name, so .onion servers work through Tor.
+type transport struct {
+	scheme    string // tcp, tls, ws or wss
+	addr      string // host:port of the IRC server or gateway
+	path      string // WebSocket request path
+	proxy     *url.URL
+	tlsConfig *tls.Config
+	dialer    *net.Dialer
+	network   string // tcp, tcp4 or tcp6
+}
+
+var defaultPorts = map[string]string{"tcp": "6667", "tls": "6697", "ws": "80", "wss": "443"}
+
+// parseTransport builds the transport for an IRC_ADDR value. useTLS decides
+// between TCP and TLS for addresses without a scheme.
+func parseTransport(addr string, useTLS, insecure bool, dialer *net.Dialer) (*transport, error) {
+	t := &transport{dialer: dialer, network: "tcp"}
+	if proxy, target, ok := strings.Cut(addr, "|"); ok {
+		u, err := url.Parse(proxy)
+		if err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") || u.Host == "" {
+			return nil, fmt.Errorf("invalid SOCKS5 proxy %q (expected socks5://[user:pass@]host:port)", proxy)
+		}
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "1080")
+		}
+		t.proxy = u
+		addr = target
+	}
+
+	scheme, rest, hasScheme := strings.Cut(addr, "://")
+	if !hasScheme {
+		t.scheme, t.addr = "tcp", addr
+		if useTLS {
+			t.scheme = "tls"
+		}
+	} else {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IRC address %q: %w", addr, err)
+		}
+		switch scheme {
+		case "irc":
+			t.scheme = "tcp"
+		case "ircs":
+			t.scheme = "tls"
+		case "ws", "wss":
+			t.scheme = scheme
+			t.path = u.RequestURI()
+		default:
+			return nil, fmt.Errorf("unsupported IRC address scheme %q in %q", scheme, rest)
+		}
+		t.addr = u.Host
+	}
+	if t.addr == "" {
+		return nil, fmt.Errorf("missing host in IRC address %q", addr)
+	}
+	if _, _, err := net.SplitHostPort(t.addr); err != nil {
+		t.addr = net.JoinHostPort(strings.Trim(t.addr, "[]"), defaultPorts[t.scheme])
+	}
+	if t.scheme == "tls" || t.scheme == "wss" {
+		host, _, _ := net.SplitHostPort(t.addr)
+		t.tlsConfig = &tls.Config{ServerName: host, InsecureSkipVerify: insecure}
+	}
+	return t, nil
+}
+
+func (t *transport) String() string {
+	s := t.scheme + "://" + t.addr + t.path
+	if t.network != "tcp" {
+		s += " (IPv" + strings.TrimPrefix(t.network, "tcp") + ")"
+	}
+	if t.proxy != nil {
+		s += " via socks5://" + t.proxy.Host
+	}
+	return s
+}
+
+// Dial connects to the server, through the proxy when one is configured, and
+// performs the TLS and WebSocket handshakes
+func (t *transport) Dial(ctx context.Context) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if t.proxy != nil {
+		conn, err = t.dialSOCKS5(ctx)
+	} else {
+		conn, err = t.dialer.DialContext(ctx, t.network, t.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.tlsConfig != nil {
+		tlsConn := tls.Client(conn, t.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	if t.scheme == "ws" || t.scheme == "wss" {
+		ws, err := dialWebSocket(ctx, conn, t.scheme, t.addr, t.path)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = ws
+	}
+	return conn, nil
+}
+
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// dialSOCKS5 opens a tunnel to t.addr through the proxy (RFC 1928), sending
+// the host name unresolved
+func (t *transport) dialSOCKS5(ctx context.Context) (net.Conn, error) {
+	conn, err := t.dialer.DialContext(ctx, t.network, t.proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %w", t.proxy.Host, err)
+	}
+	// Abort the handshake when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := t.socks5Handshake(conn); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %w", t.proxy.Host, err)
+	}
+	return conn, nil
+}
+
+func (t *transport) socks5Handshake(conn net.Conn) error {
+	user := t.proxy.User
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if user == nil {
+			return errors.New("proxy requires authentication")
+		}
+		pass, _ := user.Password()
+		name := user.Username()
+		if len(name) > 255 || len(pass) > 255 {
+			return errors.New("proxy username or password too long")
+		}
+		req := []byte{0x01, byte(len(name))}
+		req = append(req, name...)
+		req = append(req, byte(len(pass)))
+		req = append(req, pass...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("proxy authentication failed")
+		}
+	default:
+		return errors.New("proxy accepted no authentication method")
+	}
+
+	host, portStr, _ := net.SplitHostPort(t.addr)
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(append(req, 0x01), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 0x04), ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return errors.New("host name too long")
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		if msg, ok := socks5Errors[head[1]]; ok {
+			return fmt.Errorf("connect to %s: %s", t.addr, msg)
+		}
+		return fmt.Errorf("connect to %s: error %d", t.addr, head[1])
+	}
+	// Skip the bound address and port
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = 4 + 2
+	case 0x04:
+		skip = 16 + 2
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return fmt.Errorf("unknown address type %d in reply", head[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip))
+	return err
+}
+
+// loadTransport parses IRC_ADDR and the local dialing options; invalid
+// values are fatal
+func (c *Client) loadTransport() {
+	if c.addr == "" {
+		return
+	}
+	dialer, network, err := newDialer(
+		strings.TrimSpace(os.Getenv("IRC_BIND")),
+		strings.ToLower(strings.TrimSpace(os.Getenv("IRC_IP_FAMILY"))),
+		time.Duration(intenv("IRC_HAPPY_EYEBALLS_MS", 300))*time.Millisecond,
+	)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	t, err := parseTransport(c.addr, c.useTLS, c.tlsInsecure, dialer)
+	if err != nil {
+		log.Fatalf("FATAL: Invalid IRC_ADDR: %v", err)
+	}
+	t.network = network
+	c.transport = t
+}
+
+// newDialer builds the dialer for IRC connections. bind is a local IP or an
+// interface name, family is "", "4" or "6" (also ipv4/ipv6). Without a family
+// both are tried, IPv6 first with IPv4 started after fallback (happy
+// eyeballs); a negative fallback disables the race.
+func newDialer(bind, family string, fallback time.Duration) (*net.Dialer, string, error) {
+	network := "tcp"
+	switch strings.TrimPrefix(family, "ipv") {
+	case "", "any":
+	case "4":
+		network = "tcp4"
+	case "6":
+		network = "tcp6"
+	default:
+		return nil, "", fmt.Errorf("invalid IRC_IP_FAMILY %q (use 4, 6 or leave empty)", family)
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, FallbackDelay: fallback}
+	if bind == "" {
+		return dialer, network, nil
+	}
+
+	ip := net.ParseIP(bind)
+	if ip == nil {
+		iface, err := net.InterfaceByName(bind)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid IRC_BIND %q: not an IP address or interface", bind)
+		}
+		ip, err = interfaceIP(iface, network)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid IRC_BIND %q: %w", bind, err)
+		}
+	}
+	// A bound address fixes the family, dialing the other one cannot work
+	is4 := ip.To4() != nil
+	if (network == "tcp4" && !is4) || (network == "tcp6" && is4) {
+		return nil, "", fmt.Errorf("IRC_BIND %s does not match IRC_IP_FAMILY %s", ip, family)
+	}
+	if is4 {
+		network = "tcp4"
+	} else {
+		network = "tcp6"
+	}
+	dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	return dialer, network, nil
+}
+
+// interfaceIP picks the first global unicast address of iface usable for
+// network, falling back to any usable address
+func interfaceIP(iface *net.Interface, network string) (net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var fallback net.IP
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP
+		if is4 := ip.To4() != nil; (network == "tcp4" && !is4) || (network == "tcp6" && is4) {
+			continue
+		}
+		if ip.IsGlobalUnicast() {
+			return ip, nil
+		}
+		if fallback == nil && !ip.IsLinkLocalUnicast() {
+			fallback = ip
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("interface %s has no usable address", iface.Name)
+	}
+	return fallback, nil
+}
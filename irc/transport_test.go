@@ -0,0 +1,296 @@
+package irc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTransport(t *testing.T) {
+	tests := []struct {
+		addr   string
+		useTLS bool
+		want   string
+	}{
+		{"irc.example.net:6697", true, "tls://irc.example.net:6697"},
+		{"irc.example.net:6667", false, "tcp://irc.example.net:6667"},
+		{"irc://irc.example.net", true, "tcp://irc.example.net:6667"},
+		{"ircs://irc.example.net", false, "tls://irc.example.net:6697"},
+		{"ircs://[::1]:7000", false, "tls://[::1]:7000"},
+		{"ws://gateway.example.net/webirc", true, "ws://gateway.example.net:80/webirc"},
+		{"wss://gateway.example.net:8443", true, "wss://gateway.example.net:8443/"},
+		{"socks5://127.0.0.1:9050|ircs://abc.onion", false, "tls://abc.onion:6697 via socks5://127.0.0.1:9050"},
+		{"socks5://localhost|irc.example.net:6667", false, "tcp://irc.example.net:6667 via socks5://localhost:1080"},
+	}
+	for _, tt := range tests {
+		tr, err := parseTransport(tt.addr, tt.useTLS, false, &net.Dialer{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.addr, err)
+			continue
+		}
+		if got := tr.String(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.addr, tt.want, got)
+		}
+	}
+
+	for _, addr := range []string{"http://example.net", "ircs://", "http://proxy:8080|irc.example.net:6667"} {
+		if _, err := parseTransport(addr, true, false, &net.Dialer{}); err == nil {
+			t.Errorf("%s: expected an error", addr)
+		}
+	}
+}
+
+// fakeSOCKS5 accepts one connection, checks the credentials, records the
+// requested destination and then echoes lines back
+func fakeSOCKS5(t *testing.T, user, pass string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	dest := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 262)
+		readField := func(prefix int) string {
+			io.ReadFull(conn, buf[:prefix])
+			field := make([]byte, buf[prefix-1])
+			io.ReadFull(conn, field)
+			return string(field)
+		}
+		readField(2) // version and methods
+		conn.Write([]byte{0x05, 0x02})
+
+		gotUser, gotPass := readField(2), readField(1)
+		if gotUser != user || gotPass != pass {
+			conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		conn.Write([]byte{0x01, 0x00})
+
+		io.ReadFull(conn, buf[:5])
+		if buf[3] != 0x03 {
+			conn.Write([]byte{0x05, 0x08, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		host := make([]byte, buf[4])
+		io.ReadFull(conn, host)
+		io.ReadFull(conn, buf[:2])
+		dest <- net.JoinHostPort(string(host), strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+		conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0x1f, 0x90})
+		io.Copy(conn, conn)
+	}()
+	return ln.Addr().String(), dest
+}
+
+func TestSOCKS5Transport(t *testing.T) {
+	proxy, dest := fakeSOCKS5(t, "alice", "s3cret")
+	tr, err := parseTransport("socks5://alice:s3cret@"+proxy+"|irc://example.onion:6667", false, false, &net.Dialer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tr.Dial(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := <-dest; got != "example.onion:6667" {
+		t.Errorf("Proxy should receive the unresolved host name, got %s", got)
+	}
+	conn.Write([]byte("PING :x\r\n"))
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	if line != "PING :x\r\n" {
+		t.Errorf("Expected tunnelled echo, got %q", line)
+	}
+
+	proxy, _ = fakeSOCKS5(t, "alice", "s3cret")
+	tr, _ = parseTransport("socks5://alice:wrong@"+proxy+"|example.onion:6667", false, false, &net.Dialer{})
+	if _, err := tr.Dial(t.Context()); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Expected authentication failure, got %v", err)
+	}
+}
+
+// readClientFrame reads one masked client frame
+func readClientFrame(r *bufio.Reader) (byte, string, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, "", err
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(r, ext)
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+	mask := make([]byte, 4)
+	io.ReadFull(r, mask)
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, "", err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return head[0] & 0x0F, string(payload), nil
+}
+
+func writeServerFrame(w io.Writer, opcode byte, payload string) {
+	frame := []byte{0x80 | opcode, byte(len(payload))}
+	if len(payload) >= 126 {
+		frame = binary.BigEndian.AppendUint16([]byte{0x80 | opcode, 126}, uint16(len(payload)))
+	}
+	w.Write(append(frame, payload...))
+}
+
+// serveWebSocket accepts WebSocket connections on srv paths and hands the
+// hijacked connection to handle
+func serveWebSocket(t *testing.T, handle func(conn net.Conn, r *bufio.Reader)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		rw.Flush()
+		handle(conn, rw.Reader)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebSocketTransport(t *testing.T) {
+	received := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/webirc" || r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "not a websocket request", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Protocol: text.ircv3.net\r\nSec-WebSocket-Accept: " +
+			base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		rw.Flush()
+		for {
+			opcode, payload, err := readClientFrame(rw.Reader)
+			if err != nil || opcode == wsClose {
+				return
+			}
+			received <- payload
+			if strings.HasPrefix(payload, "USER ") {
+				writeServerFrame(conn, wsPing, "keepalive")
+				writeServerFrame(conn, wsText, ":server 001 TestBot :Welcome")
+			}
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("IRC_ADDR", "ws://"+strings.TrimPrefix(srv.URL, "http://")+"/webirc")
+	client := NewClient()
+	client.setNick("TestBot")
+	if err := client.Dial(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var lines []string
+	timeout := time.After(2 * time.Second)
+	for client.State() != StateConnected {
+		select {
+		case line := <-received:
+			lines = append(lines, line)
+		case <-timeout:
+			t.Fatalf("Not registered over WebSocket, server received %q", lines)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for _, line := range lines {
+		if strings.HasSuffix(line, "\r\n") {
+			t.Errorf("Each frame should hold one line without CRLF, got %q", line)
+		}
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "NICK TestBot") {
+		t.Errorf("Expected NICK in %q", lines)
+	}
+}
+
+func TestNewDialer(t *testing.T) {
+	dialer, network, err := newDialer("", "ipv6", 0)
+	if err != nil || network != "tcp6" || dialer.LocalAddr != nil {
+		t.Errorf("Unexpected dialer for IPv6 preference: %v %s %v", dialer, network, err)
+	}
+
+	dialer, network, err = newDialer("127.0.0.1", "", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network != "tcp4" || dialer.LocalAddr.String() != "127.0.0.1:0" || dialer.FallbackDelay != -1 {
+		t.Errorf("Unexpected dialer for bound address: %v %s", dialer.LocalAddr, network)
+	}
+
+	if _, _, err := newDialer("127.0.0.1", "6", 0); err == nil {
+		t.Error("Expected an error for a bind address of the wrong family")
+	}
+	if _, _, err := newDialer("", "5", 0); err == nil {
+		t.Error("Expected an error for an invalid family")
+	}
+	if _, _, err := newDialer("no-such-interface0", "", 0); err == nil {
+		t.Error("Expected an error for an unknown interface")
+	}
+
+	if iface, err := net.InterfaceByName("lo"); err == nil {
+		if ip, err := interfaceIP(iface, "tcp4"); err != nil || !ip.IsLoopback() {
+			t.Errorf("Expected loopback address on lo, got %v %v", ip, err)
+		}
+	}
+}
+
+func TestBoundDial(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	dialer, network, err := newDialer("127.0.0.1", "4", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := parseTransport("irc://"+ln.Addr().String(), false, false, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.network = network
+	if got := tr.String(); !strings.HasSuffix(got, "(IPv4)") {
+		t.Errorf("Expected IPv4 in %q", got)
+	}
+	conn, err := tr.Dial(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if host, _, _ := net.SplitHostPort(conn.LocalAddr().String()); host != "127.0.0.1" {
+		t.Errorf("Expected connection from 127.0.0.1, got %s", host)
+	}
+}
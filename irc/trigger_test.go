@@ -0,0 +1,116 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTriggerRecorder points TRIGGER_CONFIG at a test server subscribed to
+// the given events and returns a channel receiving every delivered payload.
+// It must be called before NewClient.
+func newTriggerRecorder(t *testing.T, events ...string) <-chan TriggerPayload {
+	t.Helper()
+	received := make(chan TriggerPayload, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload TriggerPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			received <- payload
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	eventsJSON, _ := json.Marshal(events)
+	oldConfig := os.Getenv("TRIGGER_CONFIG")
+	t.Cleanup(func() { os.Setenv("TRIGGER_CONFIG", oldConfig) })
+	os.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"test":{"url":%q,"events":%s}}}`, srv.URL, eventsJSON))
+	return received
+}
+
+// expectTrigger waits for the next delivered payload
+func expectTrigger(t *testing.T, received <-chan TriggerPayload) TriggerPayload {
+	t.Helper()
+	select {
+	case payload := <-received:
+		return payload
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for trigger event")
+	}
+	return TriggerPayload{}
+}
+
+// expectNoTrigger asserts that nothing is delivered for a short while
+func expectNoTrigger(t *testing.T, received <-chan TriggerPayload) {
+	t.Helper()
+	select {
+	case payload := <-received:
+		t.Errorf("Unexpected trigger event %s: %s", payload.EventType, payload.Message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTriggerEventFiltering(t *testing.T) {
+	received := newTriggerRecorder(t, "join")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host JOIN #test")
+	payload := expectTrigger(t, received)
+	if payload.EventType != "join" || payload.Sender != "alice" || payload.Target != "#test" {
+		t.Errorf("Unexpected join payload: %+v", payload)
+	}
+
+	client.handleLine(":alice!a@host PART #test :bye")
+	expectNoTrigger(t, received)
+}
+
+func TestTriggerChannelContext(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	os.Setenv("TRIGGER_CONFIG", strings.Replace(os.Getenv("TRIGGER_CONFIG"), `"events"`, `"channel_context":true,"events"`, 1))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":server 353 TestBot = #test :TestBot @alice +bob")
+	client.handleLine(":server 332 TestBot #test :Welcome to the test channel")
+	client.handleLine("@account=alice_acct :alice!a@host PRIVMSG #test :hello")
+	payload := expectTrigger(t, received)
+	want := ChannelContext{Name: "#test", Topic: "Welcome to the test channel", UserCount: 3, SenderModes: "o", SenderAccount: "alice_acct"}
+	if payload.Channel == nil || *payload.Channel != want {
+		t.Errorf("Expected channel context %+v, got %+v", want, payload.Channel)
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG TestBot :private")
+	if payload := expectTrigger(t, received); payload.Channel != nil {
+		t.Errorf("Private messages should have no channel context, got %+v", payload.Channel)
+	}
+}
+
+func TestTriggerSenderFilters(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	os.Setenv("TRIGGER_CONFIG", strings.Replace(os.Getenv("TRIGGER_CONFIG"), `"events"`,
+		`"users":["carol"],"masks":["*!*@staff.example.org"],"accounts":["alice_acct"],"events"`, 1))
+	client := NewClient()
+	client.setNick("TestBot")
+
+	client.handleLine("@account=alice_acct :alice_away!a@home.example PRIVMSG #test :by account")
+	if p := expectTrigger(t, received); p.Message != "by account" || p.Hostmask != "alice_away!a@home.example" {
+		t.Errorf("Unexpected payload %+v", p)
+	}
+	client.handleLine(":bob!b@staff.example.org PRIVMSG #test :by mask")
+	if p := expectTrigger(t, received); p.Message != "by mask" {
+		t.Errorf("Unexpected payload %+v", p)
+	}
+	client.handleLine(":CAROL!c@host PRIVMSG #test :by nick")
+	if p := expectTrigger(t, received); p.Message != "by nick" {
+		t.Errorf("Unexpected payload %+v", p)
+	}
+	client.handleLine("@account=mallory :mallory!m@staff.example.org.evil PRIVMSG #test :spoofed")
+	expectNoTrigger(t, received)
+}
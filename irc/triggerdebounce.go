@@ -0,0 +1,51 @@
+package irc
+
+import (
+	"strings"
+	"time"
+)
+
+// suppressTrigger reports whether an endpoint already received a matching
+// event within its debounce or dedup window. Otherwise the event is
+// remembered and allowed through.
+func (c *Client) suppressTrigger(name string, endpoint TriggerEndpoint, p TriggerPayload) bool {
+	debounce := time.Duration(endpoint.DebounceSeconds[p.EventType]) * time.Second
+	dedup := time.Duration(endpoint.DedupSeconds) * time.Second
+	if debounce <= 0 && dedup <= 0 {
+		return false
+	}
+	who := strings.ToLower(p.Sender) + "\x00" + strings.ToLower(p.Target)
+	var keys []string
+	var windows []time.Duration
+	if debounce > 0 {
+		keys = append(keys, name+"\x00debounce\x00"+p.EventType+"\x00"+who)
+		windows = append(windows, debounce)
+	}
+	if dedup > 0 && p.Message != "" {
+		keys = append(keys, name+"\x00dedup\x00"+p.EventType+"\x00"+who+"\x00"+p.Message)
+		windows = append(windows, dedup)
+	}
+
+	now := time.Now()
+	c.triggerSeenMu.Lock()
+	defer c.triggerSeenMu.Unlock()
+	if c.triggerSeen == nil {
+		c.triggerSeen = make(map[string]time.Time)
+	}
+	for _, key := range keys {
+		if now.Before(c.triggerSeen[key]) {
+			return true
+		}
+	}
+	if len(c.triggerSeen) >= 10000 {
+		for key, until := range c.triggerSeen {
+			if !now.Before(until) {
+				delete(c.triggerSeen, key)
+			}
+		}
+	}
+	for i, key := range keys {
+		c.triggerSeen[key] = now.Add(windows[i])
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package irc
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTriggerDebounceAndDedup(t *testing.T) {
+	received := newTriggerRecorder(t, "join", "privmsg")
+	os.Setenv("TRIGGER_CONFIG", strings.Replace(os.Getenv("TRIGGER_CONFIG"), `"events"`, `"debounce_seconds":{"join":300},"dedup_seconds":60,"events"`, 1))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":alice!a@host JOIN #test")
+	expectTrigger(t, received)
+	client.handleLine(":alice!a@host PART #test")
+	client.handleLine(":alice!a@host JOIN #test")
+	expectNoTrigger(t, received)
+
+	// Other users and channels have their own window
+	client.handleLine(":bob!b@host JOIN #test")
+	if p := expectTrigger(t, received); p.Sender != "bob" {
+		t.Errorf("Expected bob's join, got %+v", p)
+	}
+	client.handleLine(":alice!a@host JOIN #other")
+	if p := expectTrigger(t, received); p.Target != "#other" {
+		t.Errorf("Expected the join to #other, got %+v", p)
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :buy now")
+	expectTrigger(t, received)
+	client.handleLine(":alice!a@host PRIVMSG #test :buy now")
+	expectNoTrigger(t, received)
+	client.handleLine(":alice!a@host PRIVMSG #test :something else")
+	if p := expectTrigger(t, received); p.Message != "something else" {
+		t.Errorf("Expected a different message to pass, got %+v", p)
+	}
+}
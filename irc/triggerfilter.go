@@ -0,0 +1,51 @@
+package irc
+
+import "strings"
+
+// matchesSender reports whether an endpoint's sender filters accept a
+// payload: users lists nicks, masks nick!user@host globs and accounts
+// services accounts, and a sender matching any entry passes. Events without
+// a sender, such as lag or batch events, are never filtered out.
+func (e TriggerEndpoint) matchesSender(p TriggerPayload) bool {
+	if p.Sender == "" || len(e.Users)+len(e.Masks)+len(e.Accounts) == 0 {
+		return true
+	}
+	for _, user := range e.Users {
+		if strings.EqualFold(user, p.Sender) {
+			return true
+		}
+	}
+	if p.Hostmask != "" {
+		for _, mask := range e.Masks {
+			if matchMask(mask, p.Hostmask) {
+				return true
+			}
+		}
+	}
+	if p.SenderAccount != "" {
+		for _, account := range e.Accounts {
+			if strings.EqualFold(account, p.SenderAccount) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ignoresBots reports whether events from other bots are dropped
+func (e TriggerEndpoint) ignoresBots() bool {
+	return e.IgnoreBots != nil && *e.IgnoreBots
+}
+
+// knownHostmask returns nick!user@host from tracked user info, or "" when the
+// user or host is unknown
+func (c *Client) knownHostmask(nick string) string {
+	if nick == "" {
+		return ""
+	}
+	info := c.getUserInfo(nick)
+	if info == nil || info.User == "" || info.Host == "" {
+		return ""
+	}
+	return nick + "!" + info.User + "@" + info.Host
+}
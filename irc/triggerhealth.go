@@ -0,0 +1,105 @@
+package irc
+
+import (
+	"net/url"
+	"sort"
+	"time"
+)
+
+// TriggerHealth summarizes deliveries to one trigger endpoint since startup
+type TriggerHealth struct {
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Healthy     bool     `json:"healthy"` // the last delivery succeeded (true before the first one)
+	Successes   int      `json:"successes"`
+	Failures    int      `json:"failures"`
+	LastStatus  int      `json:"last_status,omitempty"` // HTTP status of the last delivery
+	LastError   string   `json:"last_error,omitempty"`
+	LastSuccess int64    `json:"last_success,omitempty"`
+	LastFailure int64    `json:"last_failure,omitempty"`
+	FailureRate float64  `json:"failure_rate"` // share of the last deliveries that failed
+	QueueDepth  int      `json:"queue_depth"`  // events waiting for delivery
+	QueueSize   int      `json:"queue_size"`   // capacity of the queue
+	Dropped     int64    `json:"dropped"`      // events dropped because the queue was full
+
+	recent []bool // outcome of the last triggerHealthWindow deliveries
+}
+
+// triggerHealthWindow is how many recent deliveries the failure rate covers
+const triggerHealthWindow = 20
+
+// recordTriggerResult counts a delivery; status is 0 when the request failed
+func (c *Client) recordTriggerResult(name string, status int, err error) {
+	c.triggerHealthMu.Lock()
+	defer c.triggerHealthMu.Unlock()
+	if c.triggerHealth == nil {
+		c.triggerHealth = make(map[string]*TriggerHealth)
+	}
+	h := c.triggerHealth[name]
+	if h == nil {
+		h = &TriggerHealth{Name: name}
+		c.triggerHealth[name] = h
+	}
+	now := time.Now().Unix()
+	h.LastStatus = status
+	switch {
+	case err != nil:
+		h.Failures++
+		h.LastFailure = now
+		h.LastError = err.Error()
+	case status >= 200 && status < 300:
+		h.Successes++
+		h.LastSuccess = now
+		h.LastError = ""
+	default:
+		h.Failures++
+		h.LastFailure = now
+		h.LastError = ""
+	}
+	h.Healthy = err == nil && status >= 200 && status < 300
+	c.countDelivery(name, h.Healthy)
+	h.recent = append(h.recent, h.Healthy)
+	if len(h.recent) > triggerHealthWindow {
+		h.recent = h.recent[1:]
+	}
+	failed := 0
+	for _, ok := range h.recent {
+		if !ok {
+			failed++
+		}
+	}
+	h.FailureRate = float64(failed) / float64(len(h.recent))
+}
+
+// TriggerHealth returns the health of every configured trigger endpoint,
+// sorted by name. URLs are shown without credentials or query strings.
+func (c *Client) TriggerHealth() []TriggerHealth {
+	c.triggerHealthMu.Lock()
+	defer c.triggerHealthMu.Unlock()
+	out := make([]TriggerHealth, 0, len(c.triggerConfig.Endpoints))
+	for name, endpoint := range c.triggerConfig.Endpoints {
+		h := TriggerHealth{Name: name, Healthy: true}
+		if recorded := c.triggerHealth[name]; recorded != nil {
+			h = *recorded
+			h.recent = nil
+		}
+		h.URL = redactURL(endpoint.URL)
+		h.Events = endpoint.Events
+		h.QueueDepth, h.QueueSize, h.Dropped = c.triggerQueueStats(name)
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
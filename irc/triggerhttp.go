@@ -0,0 +1,69 @@
+package irc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// defaultTriggerClient serves endpoints without HTTP settings of their own
+var defaultTriggerClient = &http.Client{Timeout: 10 * time.Second}
+
+// newTriggerClient builds the HTTP client for an endpoint from its timeout,
+// proxy and TLS settings
+func newTriggerClient(e TriggerEndpoint) (*http.Client, error) {
+	if e.TimeoutSeconds == 0 && e.Proxy == "" && e.TLSCA == "" && e.TLSCert == "" && e.TLSKey == "" && !e.TLSSkipVerify {
+		return defaultTriggerClient, nil
+	}
+	if e.TimeoutSeconds < 0 {
+		return nil, errors.New("timeout_seconds must not be negative")
+	}
+	timeout := 10 * time.Second
+	if e.TimeoutSeconds > 0 {
+		timeout = time.Duration(e.TimeoutSeconds) * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if e.Proxy != "" {
+		u, err := url.Parse(e.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", e.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", u.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: e.TLSSkipVerify}
+	if e.TLSCA != "" {
+		pem, err := os.ReadFile(e.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading tls_ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in tls_ca %s", e.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (e.TLSCert == "") != (e.TLSKey == "") {
+		return nil, errors.New("tls_cert and tls_key must be set together")
+	}
+	if e.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(e.TLSCert, e.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
@@ -0,0 +1,103 @@
+package irc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert creates a self-signed client certificate and returns the
+// paths of its PEM certificate and key
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestTriggerEndpointMTLS(t *testing.T) {
+	type request struct {
+		certs  int
+		header string
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- request{len(r.TLS.PeerCertificates), r.Header.Get("X-Api-Key")}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600)
+	certFile, keyFile := writeClientCert(t, dir)
+
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"internal":{"url":%q,"events":["mention"],
+		"timeout_seconds":5,"headers":{"X-Api-Key":"secret"},"tls_ca":%q,"tls_cert":%q,"tls_key":%q}}}`,
+		srv.URL, caFile, certFile, keyFile))
+	client := NewClient()
+	endpoint := client.triggerConfig.Endpoints["internal"]
+	if endpoint.client.Timeout != 5*time.Second {
+		t.Errorf("Expected a 5s timeout, got %s", endpoint.client.Timeout)
+	}
+
+	client.callTriggerEndpoint("internal", endpoint, TriggerPayload{EventType: "mention"})
+	select {
+	case r := <-requests:
+		if r.certs != 1 || r.header != "secret" {
+			t.Errorf("Expected a client certificate and the custom header, got %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The endpoint was not called")
+	}
+	if h := client.TriggerHealth(); len(h) != 1 || !h[0].Healthy {
+		t.Errorf("Expected a successful delivery, got %+v", h)
+	}
+}
+
+func TestTriggerEndpointHTTPSettingsInvalid(t *testing.T) {
+	for _, e := range []TriggerEndpoint{
+		{TLSCert: "cert.pem"},
+		{Proxy: "ftp://proxy:21"},
+		{TimeoutSeconds: -1},
+		{TLSCA: "/nonexistent/ca.pem"},
+	} {
+		if _, err := newTriggerClient(e); err == nil {
+			t.Errorf("Expected an error for %+v", e)
+		}
+	}
+	if c, err := newTriggerClient(TriggerEndpoint{}); err != nil || c != defaultTriggerClient {
+		t.Errorf("Endpoints without settings should share the default client")
+	}
+}
@@ -0,0 +1,165 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTriggerQueueSize bounds each endpoint's queue unless it sets queue_size
+const defaultTriggerQueueSize = 1000
+
+// Batch limits for endpoints that set only one of batch_size and batch_seconds
+const (
+	defaultTriggerBatchSize     = 100
+	defaultTriggerBatchInterval = 5 * time.Second
+)
+
+// triggerQueue delivers one endpoint's payloads in the order they were
+// queued, one request at a time. When the endpoint cannot keep up and the
+// queue is full, new payloads are dropped and counted.
+type triggerQueue struct {
+	ch      chan TriggerPayload
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// loadTriggerQueues creates a queue for every configured endpoint. Senders
+// start with the first payload.
+func (c *Client) loadTriggerQueues() {
+	c.triggerQueues = make(map[string]*triggerQueue, len(c.triggerConfig.Endpoints))
+	for name, endpoint := range c.triggerConfig.Endpoints {
+		size := endpoint.QueueSize
+		if size <= 0 {
+			size = defaultTriggerQueueSize
+		}
+		c.triggerQueues[name] = &triggerQueue{ch: make(chan TriggerPayload, size)}
+	}
+}
+
+// enqueueTrigger queues a payload for an endpoint
+func (c *Client) enqueueTrigger(name string, endpoint TriggerEndpoint, payload TriggerPayload) {
+	if c.drain.draining() {
+		if !c.drain.refused.Swap(true) {
+			log.Printf("Shutting down, no longer queuing trigger events")
+		}
+		return
+	}
+	q := c.triggerQueues[name]
+	if q == nil {
+		c.drain.deliveries.Add(1)
+		go c.safely("trigger "+name, func() {
+			defer c.drain.deliveries.Add(-1)
+			c.callTriggerEndpoint(name, endpoint, payload)
+		})
+		return
+	}
+	q.once.Do(func() { c.supervise("trigger "+name, func() { c.runTriggerQueue(name, endpoint, q) }) })
+	select {
+	case q.ch <- payload:
+		c.drain.deliveries.Add(1)
+	default:
+		if q.dropped.Add(1) == 1 {
+			log.Printf("Trigger endpoint %s: queue full, dropping events", name)
+		}
+	}
+}
+
+func (c *Client) runTriggerQueue(name string, endpoint TriggerEndpoint, q *triggerQueue) {
+	if endpoint.BatchSize > 0 || endpoint.BatchSeconds > 0 {
+		c.runTriggerBatches(name, endpoint, q)
+		return
+	}
+	ctx := c.context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-q.ch:
+			c.callTriggerEndpoint(name, endpoint, payload)
+			c.drain.deliveries.Add(-1)
+		}
+	}
+}
+
+// runTriggerBatches collects queued payloads and sends them as one JSON
+// array when batch_size is reached or batch_seconds after the first one
+func (c *Client) runTriggerBatches(name string, endpoint TriggerEndpoint, q *triggerQueue) {
+	size := endpoint.BatchSize
+	if size <= 0 {
+		size = defaultTriggerBatchSize
+	}
+	interval := defaultTriggerBatchInterval
+	if endpoint.BatchSeconds > 0 {
+		interval = time.Duration(endpoint.BatchSeconds) * time.Second
+	}
+
+	ctx := c.context()
+	timer := time.NewTimer(interval)
+	timer.Stop()
+	var batch []TriggerPayload
+	flush := func() {
+		timer.Stop()
+		c.callTriggerBatch(name, endpoint, batch)
+		c.drain.deliveries.Add(-int64(len(batch)))
+		batch = nil
+	}
+	// When draining, batches are sent right away instead of waiting
+	drain := c.drain.done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-q.ch:
+			if len(batch) == 0 {
+				timer.Reset(interval)
+			}
+			batch = append(batch, payload)
+			if len(batch) >= size || c.drain.draining() {
+				flush()
+			}
+		case <-drain:
+			drain = nil
+			if len(batch) > 0 {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (c *Client) callTriggerBatch(name string, endpoint TriggerEndpoint, batch []TriggerPayload) {
+	items := make([]json.RawMessage, 0, len(batch))
+	for _, payload := range batch {
+		item, err := endpoint.render(payload)
+		if err != nil {
+			log.Printf("Error rendering trigger payload for %s: %v", name, err)
+			c.recordTriggerResult(name, 0, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return
+	}
+	jsonData, err := json.Marshal(items)
+	if err != nil {
+		log.Printf("Error marshaling trigger batch for %s: %v", name, err)
+		return
+	}
+	c.postTrigger(name, endpoint, jsonData, fmt.Sprintf("a batch of %d events", len(items)), "")
+}
+
+// triggerQueueStats returns the depth, capacity and dropped count of an
+// endpoint's queue
+func (c *Client) triggerQueueStats(name string) (depth, capacity int, dropped int64) {
+	q := c.triggerQueues[name]
+	if q == nil {
+		return 0, 0, 0
+	}
+	return len(q.ch), cap(q.ch), q.dropped.Load()
+}
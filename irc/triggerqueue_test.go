@@ -0,0 +1,115 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriggerQueueOrdering(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	for i := 0; i < 20; i++ {
+		client.handleLine(fmt.Sprintf(":alice!a@host PRIVMSG #test :message %d", i))
+	}
+	for i := 0; i < 20; i++ {
+		p := expectTrigger(t, received)
+		if want := fmt.Sprintf("message %d", i); p.Message != want {
+			t.Fatalf("Expected %q, got %q", want, p.Message)
+		}
+	}
+}
+
+func TestTriggerQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"slow":{"url":%q,"events":["privmsg"],"queue_size":2}}}`, srv.URL))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	// The first message is picked up by the sender and blocks it
+	client.handleLine(":alice!a@host PRIVMSG #test :first")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if depth, _, _ := client.triggerQueueStats("slow"); depth == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The sender did not pick up the first event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		client.handleLine(":alice!a@host PRIVMSG #test :more")
+	}
+
+	h := client.TriggerHealth()
+	if len(h) != 1 || h[0].QueueDepth != 2 || h[0].QueueSize != 2 || h[0].Dropped != 3 {
+		t.Errorf("Expected 2 queued and 3 dropped events, got %+v", h)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	client.CreateAPI("token").ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{`hanna_trigger_queue_depth{endpoint="slow"} 2`, `hanna_trigger_dropped_total{endpoint="slow"} 3`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in metrics:\n%s", want, body)
+		}
+	}
+}
+
+func TestTriggerBatches(t *testing.T) {
+	batches := make(chan []TriggerPayload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []TriggerPayload
+		if err := json.NewDecoder(r.Body).Decode(&batch); err == nil {
+			batches <- batch
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"bulk":{"url":%q,"events":["privmsg"],"batch_size":3,"batch_seconds":1}}}`, srv.URL))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	start := time.Now()
+	for i := 0; i < 7; i++ {
+		client.handleLine(fmt.Sprintf(":alice!a@host PRIVMSG #test :message %d", i))
+	}
+	next := 0
+	for _, want := range []int{3, 3, 1} {
+		select {
+		case batch := <-batches:
+			if len(batch) != want {
+				t.Fatalf("Expected a batch of %d events, got %d", want, len(batch))
+			}
+			for _, p := range batch {
+				if p.Message != fmt.Sprintf("message %d", next) {
+					t.Errorf("Expected message %d, got %q", next, p.Message)
+				}
+				next++
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timed out waiting for a batch")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("The partial batch should wait for batch_seconds, sent after %s", elapsed)
+	}
+}
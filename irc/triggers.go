@@ -0,0 +1,331 @@
+package irc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+type TriggerPayload struct {
+	EventType     string            `json:"eventType"`
+	Sender        string            `json:"sender"`
+	Target        string            `json:"target"`
+	Message       string            `json:"message"`
+	ChatInput     string            `json:"chatInput"`
+	BotNick       string            `json:"botNick"`
+	SessionId     string            `json:"sessionId"`
+	Timestamp     int64             `json:"timestamp"`
+	MessageTags   map[string]string `json:"messageTags,omitempty"`
+	LinkPreview   []LinkPreview     `json:"linkPreview,omitempty"`    // link_preview events only
+	RawMessage    string            `json:"rawMessage,omitempty"`     // original message when the endpoint converts formatting
+	SenderAccount string            `json:"senderAccount,omitempty"`  // services account of the sender, when known
+	Hostmask      string            `json:"hostmask,omitempty"`       // nick!user@host of the sender, when known
+	SenderIsBot   bool              `json:"senderIsBot,omitempty"`    // the sender is a bot, by message tag or WHOIS
+	Language      string            `json:"language,omitempty"`       // from the channel's CHANNEL_CONFIG profile
+	SystemPrompt  string            `json:"systemPrompt,omitempty"`   // from the channel's CHANNEL_CONFIG profile
+	Channel       *ChannelContext   `json:"channel,omitempty"`        // endpoints with channel_context only
+	Recent        []RecentMessage   `json:"recentMessages,omitempty"` // channel mentions of endpoints with recent_messages only
+	CorrelationID string            `json:"correlationId"`            // also sent as X-Request-ID; pass it back to the API to trace a reply
+}
+
+type TriggerConfig struct {
+	Endpoints  map[string]TriggerEndpoint `json:"endpoints"`
+	Discord    *DiscordConfig             `json:"discord,omitempty"`
+	Telegram   *TelegramConfig            `json:"telegram,omitempty"`
+	XMPP       *XMPPConfig                `json:"xmpp,omitempty"`
+	IgnoreBots bool                       `json:"ignore_bots,omitempty"` // default for endpoints without ignore_bots
+	Bots       []string                   `json:"bots,omitempty"`        // nicks or nick!user@host globs of bots without bot mode
+}
+
+type TriggerEndpoint struct {
+	Type            string            `json:"type,omitempty"` // "" for TriggerPayload JSON, or "slack"
+	URL             string            `json:"url"`
+	Token           string            `json:"token"`
+	Events          []string          `json:"events"`
+	Channels        []string          `json:"channels,omitempty"`
+	Users           []string          `json:"users,omitempty"`
+	Masks           []string          `json:"masks,omitempty"`            // nick!user@host globs, matched like users
+	Accounts        []string          `json:"accounts,omitempty"`         // services accounts, matched like users
+	IgnoreBots      *bool             `json:"ignore_bots,omitempty"`      // drop events from other bots; default from the top-level ignore_bots
+	StripColors     bool              `json:"strip_colors,omitempty"`     // shorthand for "formatting": "strip"
+	Formatting      string            `json:"formatting,omitempty"`       // raw (default), strip or markdown for message and chatInput
+	ChannelContext  bool              `json:"channel_context,omitempty"`  // add topic, modes, user count and sender details for channel events
+	RecentMessages  int               `json:"recent_messages,omitempty"`  // add the channel's last N messages to mentions, at most 100
+	TimeoutSeconds  int               `json:"timeout_seconds,omitempty"`  // per request, default 10
+	Headers         map[string]string `json:"headers,omitempty"`          // sent with every request, after Content-Type and Authorization
+	Proxy           string            `json:"proxy,omitempty"`            // http, https or socks5 URL; default from HTTPS_PROXY/HTTP_PROXY
+	TLSCA           string            `json:"tls_ca,omitempty"`           // PEM file of CAs trusted instead of the system ones
+	TLSCert         string            `json:"tls_cert,omitempty"`         // PEM client certificate for mTLS
+	TLSKey          string            `json:"tls_key,omitempty"`          // PEM key of tls_cert
+	TLSSkipVerify   bool              `json:"tls_skip_verify,omitempty"`  // do not verify the server certificate
+	QueueSize       int               `json:"queue_size,omitempty"`       // events waiting for delivery before new ones are dropped, default 1000
+	DebounceSeconds map[string]int    `json:"debounce_seconds,omitempty"` // event type -> at most one event per sender and target in this many seconds
+	DedupSeconds    int               `json:"dedup_seconds,omitempty"`    // drop repeats of the same message from the same sender and target
+	BatchSize       int               `json:"batch_size,omitempty"`       // send arrays of up to this many events, default 100 when batching
+	BatchSeconds    int               `json:"batch_seconds,omitempty"`    // send a partial batch after this long, default 5 when batching
+	Template        string            `json:"template,omitempty"`         // Go template producing the request body from the payload
+	Encoding        string            `json:"encoding,omitempty"`         // json (default), msgpack or cbor request bodies
+	Typing          bool              `json:"typing,omitempty"`           // show the bot typing while a mention or private message awaits a reply
+	Slack           SlackOptions      `json:"slack,omitempty"`            // type slack only
+
+	client *http.Client           // built from the settings above by loadTriggerConfig
+	tmpl   *texttemplate.Template // parsed Template, nil to send the payload as is
+}
+
+func (c *Client) loadTriggerConfig() {
+	configStr := os.Getenv("TRIGGER_CONFIG")
+	if configStr == "" {
+		// Fallback to legacy N8N_WEBHOOK for backward compatibility
+		if webhook := os.Getenv("N8N_WEBHOOK"); webhook != "" {
+			c.triggerConfig = TriggerConfig{
+				Endpoints: map[string]TriggerEndpoint{
+					"legacy": {
+						URL:    webhook,
+						Events: []string{"mention"},
+					},
+				},
+			}
+		}
+		return
+	}
+	log.Printf("Got trigger config")
+	if err := json.Unmarshal([]byte(configStr), &c.triggerConfig); err != nil {
+		log.Fatalf("FATAL: Invalid TRIGGER_CONFIG JSON: %v", err)
+	}
+	for name, endpoint := range c.triggerConfig.Endpoints {
+		switch endpoint.Type {
+		case "":
+		case "slack":
+			if endpoint.Template != "" || endpoint.BatchSize > 0 || endpoint.BatchSeconds > 0 {
+				log.Fatalf("FATAL: Slack trigger endpoint %s cannot use template or batching", name)
+			}
+		default:
+			log.Fatalf("FATAL: Invalid type %q for trigger endpoint %s (use slack or leave it empty)", endpoint.Type, name)
+		}
+		endpoint.Formatting = strings.ToLower(endpoint.Formatting)
+		if endpoint.Formatting == "" && (endpoint.StripColors || endpoint.Type == "slack") {
+			endpoint.Formatting = "strip"
+		}
+		if endpoint.Formatting != "" && !formattingModes[endpoint.Formatting] {
+			log.Fatalf("FATAL: Invalid formatting %q for trigger endpoint %s (use raw, strip or markdown)", endpoint.Formatting, name)
+		}
+		endpoint.Encoding = strings.ToLower(endpoint.Encoding)
+		if endpoint.Encoding != "" && !triggerEncodings[endpoint.Encoding] {
+			log.Fatalf("FATAL: Invalid encoding %q for trigger endpoint %s (use json, msgpack or cbor)", endpoint.Encoding, name)
+		}
+		if endpoint.Type == "slack" && endpoint.Encoding != "" && endpoint.Encoding != "json" {
+			log.Fatalf("FATAL: Slack trigger endpoint %s must use JSON", name)
+		}
+		client, err := newTriggerClient(endpoint)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid HTTP settings for trigger endpoint %s: %v", name, err)
+		}
+		endpoint.client = client
+		if endpoint.BatchSize < 0 || endpoint.BatchSeconds < 0 {
+			log.Fatalf("FATAL: batch_size and batch_seconds of trigger endpoint %s must not be negative", name)
+		}
+		for event, seconds := range endpoint.DebounceSeconds {
+			if seconds < 0 {
+				log.Fatalf("FATAL: debounce_seconds for %s of trigger endpoint %s must not be negative", event, name)
+			}
+		}
+		if endpoint.DedupSeconds < 0 {
+			log.Fatalf("FATAL: dedup_seconds of trigger endpoint %s must not be negative", name)
+		}
+		if endpoint.Template != "" {
+			if endpoint.tmpl, err = parseTriggerTemplate(name, endpoint.Template); err != nil {
+				log.Fatalf("FATAL: Invalid template for trigger endpoint %s: %v", name, err)
+			}
+		}
+		if endpoint.IgnoreBots == nil {
+			endpoint.IgnoreBots = &c.triggerConfig.IgnoreBots
+		}
+		if endpoint.RecentMessages < 0 || endpoint.RecentMessages > maxRecentMessages {
+			log.Fatalf("FATAL: recent_messages of trigger endpoint %s must be between 0 and %d", name, maxRecentMessages)
+		}
+		c.recent.size = max(c.recent.size, endpoint.RecentMessages)
+		c.triggerConfig.Endpoints[name] = endpoint
+	}
+}
+
+func (c *Client) sendTriggerEvent(eventType, sender, target, message, fullMessage string, tags map[string]string) {
+	c.sendTrigger(c.newTriggerPayload(eventType, sender, target, message, fullMessage, tags))
+}
+
+// sendTrigger delivers a payload unless it belongs to a batch
+func (c *Client) sendTrigger(payload TriggerPayload) {
+	// Events inside a batch are summarized by its batch_end event
+	if c.absorbBatched(payload.EventType, payload.MessageTags) {
+		return
+	}
+	c.deliverTrigger(payload)
+}
+
+func (c *Client) newTriggerPayload(eventType, sender, target, message, fullMessage string, tags map[string]string) TriggerPayload {
+	return TriggerPayload{
+		EventType:     eventType,
+		Sender:        sender,
+		Target:        target,
+		Message:       message,
+		SessionId:     "IRC",
+		ChatInput:     fullMessage,
+		BotNick:       c.Nick(),
+		Timestamp:     time.Now().Unix(),
+		MessageTags:   tags,
+		SenderAccount: c.senderAccount(sender, tags),
+		Hostmask:      c.knownHostmask(sender),
+		SenderIsBot:   c.senderIsBot(sender, tags),
+		CorrelationID: newRequestID(),
+	}
+}
+
+// deliverTrigger sends a payload to every endpoint subscribed to its event,
+// channel and sender
+func (c *Client) deliverTrigger(payload TriggerPayload) {
+	eventType, target := payload.EventType, payload.Target
+	var channelCtx *ChannelContext // looked up once, for the first endpoint that wants it
+	var forwarded []string
+	profile := c.channelProfile(target)
+	payload.Language, payload.SystemPrompt = profile.Language, profile.SystemPrompt
+	for endpointName, endpoint := range c.triggerConfig.Endpoints {
+		// Check if this endpoint listens for this event type
+		found := false
+		for _, event := range endpoint.Events {
+			if event == eventType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		// Check channel filter, including channel groups
+		if len(endpoint.Channels) > 0 && target != "" && !c.inChannels(endpoint.Channels, target) {
+			continue
+		}
+
+		// Check user, mask and account filters
+		if !endpoint.matchesSender(payload) {
+			continue
+		}
+		// Keep LLM workflows from talking to other bots forever
+		if payload.SenderIsBot && endpoint.ignoresBots() {
+			continue
+		}
+		// Channel profiles can route a channel to some endpoints only
+		if !profile.routes(endpointName) {
+			continue
+		}
+
+		// Drop flapping and repeated events, see triggerdebounce.go
+		if c.suppressTrigger(endpointName, endpoint, payload) {
+			continue
+		}
+		// Channels over their daily_cap wait for midnight, see usage.go
+		if len(forwarded) == 0 && c.overDailyCap(target, profile) {
+			return
+		}
+		forwarded = append(forwarded, endpointName)
+
+		// Send to this endpoint
+		p := payload
+		if endpoint.Formatting != "" && endpoint.Formatting != "raw" {
+			p.Message = convertFormatting(endpoint.Formatting, payload.Message)
+			p.ChatInput = convertFormatting(endpoint.Formatting, payload.ChatInput)
+			if p.Message != payload.Message {
+				p.RawMessage = payload.Message
+			}
+		}
+		if endpoint.ChannelContext && isChannelName(target) {
+			if channelCtx == nil {
+				channelCtx = c.channelContext(target, payload.Sender, payload.MessageTags)
+			}
+			p.Channel = channelCtx
+		}
+		if endpoint.RecentMessages > 0 && eventType == "mention" && isChannelName(target) {
+			p.Recent = c.recent.snapshot(target, endpoint.RecentMessages, payload.CorrelationID)
+			if endpoint.Formatting != "" && endpoint.Formatting != "raw" {
+				for i := range p.Recent {
+					p.Recent[i].Message = convertFormatting(endpoint.Formatting, p.Recent[i].Message)
+				}
+			}
+		}
+		c.enqueueTrigger(endpointName, endpoint, p)
+		if endpoint.Typing {
+			c.startTyping(c.typingTarget(payload))
+		}
+	}
+	if len(forwarded) > 0 {
+		c.countForwarded(target, forwarded)
+	}
+}
+
+func (c *Client) callTriggerEndpoint(name string, endpoint TriggerEndpoint, payload TriggerPayload) {
+	jsonData, err := endpoint.render(payload)
+	if err != nil {
+		log.Printf("Error rendering trigger payload for %s: %v", name, err)
+		c.recordTriggerResult(name, 0, err)
+		return
+	}
+	c.postTrigger(name, endpoint, jsonData, fmt.Sprintf("%s event from %s", payload.EventType, payload.Sender), payload.CorrelationID)
+}
+
+// postTrigger sends a JSON body, in the endpoint's encoding, to an endpoint
+// and records the outcome; what describes the body for the log, and a
+// non-empty id is sent as X-Request-ID
+func (c *Client) postTrigger(name string, endpoint TriggerEndpoint, jsonData []byte, what, id string) {
+	if id != "" {
+		what += " [" + id + "]"
+	}
+	debugf("Calling trigger endpoint %s: %s", name, endpoint.URL)
+
+	body, contentType, err := endpoint.encode(jsonData)
+	if err != nil {
+		log.Printf("Error encoding trigger payload for %s: %v", name, err)
+		c.recordTriggerResult(name, 0, err)
+		return
+	}
+	client := endpoint.client
+	if client == nil {
+		client = defaultTriggerClient
+	}
+	req, err := http.NewRequestWithContext(c.context(), "POST", endpoint.URL, bytes.NewBuffer(body))
+	if err != nil {
+		log.Printf("Error creating request for %s: %v", name, err)
+		return
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if endpoint.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+endpoint.Token)
+	}
+	if id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	for k, v := range endpoint.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error calling trigger endpoint %s: %v", name, err)
+		c.recordTriggerResult(name, 0, err)
+		return
+	}
+	defer resp.Body.Close()
+	c.recordTriggerResult(name, resp.StatusCode, nil)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		c.touchActivity()
+		log.Printf("Successfully called trigger endpoint %s for %s", name, what)
+	} else {
+		log.Printf("Trigger endpoint %s returned status %d for %s", name, resp.StatusCode, what)
+	}
+}
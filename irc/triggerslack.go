@@ -0,0 +1,88 @@
+package irc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SlackOptions adjust the messages of endpoints with "type": "slack"
+type SlackOptions struct {
+	Channel     string `json:"channel,omitempty"`  // overrides the webhook's default channel
+	Username    string `json:"username,omitempty"` // overrides the webhook's display name
+	IconEmoji   string `json:"icon_emoji,omitempty"`
+	IconURL     string `json:"icon_url,omitempty"`
+	Attachments bool   `json:"attachments,omitempty"` // send each event as an attachment with author, title and timestamp
+	Color       string `json:"color,omitempty"`       // attachment side bar color, e.g. "#439FE0"
+}
+
+type slackMessage struct {
+	Text        string            `json:"text,omitempty"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Fallback   string `json:"fallback"`
+	Color      string `json:"color,omitempty"`
+	AuthorName string `json:"author_name,omitempty"`
+	Title      string `json:"title"`
+	Text       string `json:"text,omitempty"`
+	Ts         int64  `json:"ts"`
+}
+
+// slackEscaper escapes the characters Slack and Mattermost treat as markup
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// renderSlack builds an incoming-webhook message for a payload
+func renderSlack(opts SlackOptions, p TriggerPayload) ([]byte, error) {
+	msg := slackMessage{
+		Channel:   opts.Channel,
+		Username:  opts.Username,
+		IconEmoji: opts.IconEmoji,
+		IconURL:   opts.IconURL,
+	}
+	text := slackText(p)
+	if !opts.Attachments {
+		msg.Text = text
+		return json.Marshal(msg)
+	}
+	title := p.EventType
+	if p.Target != "" {
+		title += " in " + p.Target
+	}
+	msg.Attachments = []slackAttachment{{
+		Fallback:   text,
+		Color:      opts.Color,
+		AuthorName: p.Sender,
+		Title:      slackEscaper.Replace(title),
+		Text:       slackEscaper.Replace(p.Message),
+		Ts:         p.Timestamp,
+	}}
+	return json.Marshal(msg)
+}
+
+// slackText summarizes a payload on one line, e.g. "[#general] *alice*: hi"
+// for messages and "[#general] *alice* _join_" for other events
+func slackText(p TriggerPayload) string {
+	var b strings.Builder
+	if p.Target != "" {
+		fmt.Fprintf(&b, "[%s] ", slackEscaper.Replace(p.Target))
+	}
+	if p.Sender != "" {
+		fmt.Fprintf(&b, "*%s*", slackEscaper.Replace(p.Sender))
+	}
+	if p.EventType != "privmsg" && p.EventType != "pm" && p.EventType != "mention" {
+		fmt.Fprintf(&b, " _%s_", p.EventType)
+	}
+	if p.Message != "" {
+		if p.Sender != "" {
+			b.WriteString(":")
+		}
+		b.WriteString(" " + slackEscaper.Replace(p.Message))
+	}
+	return strings.TrimSpace(b.String())
+}
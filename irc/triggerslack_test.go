@@ -0,0 +1,45 @@
+package irc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRenderSlack(t *testing.T) {
+	p := TriggerPayload{EventType: "privmsg", Sender: "alice", Target: "#test", Message: "a <b> & c", Timestamp: 1735732800}
+	body, err := renderSlack(SlackOptions{Channel: "#irc", Username: "hanna"}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg slackMessage
+	json.Unmarshal(body, &msg)
+	if msg.Text != "[#test] *alice*: a &lt;b&gt; &amp; c" || msg.Channel != "#irc" || msg.Username != "hanna" {
+		t.Errorf("Unexpected message %s", body)
+	}
+
+	if got := slackText(TriggerPayload{EventType: "join", Sender: "bob", Target: "#test"}); got != "[#test] *bob* _join_" {
+		t.Errorf("Unexpected join text %q", got)
+	}
+	if got := slackText(TriggerPayload{EventType: "bot_registered", Message: "TestBot"}); got != "_bot_registered_ TestBot" {
+		t.Errorf("Unexpected lifecycle text %q", got)
+	}
+
+	body, _ = renderSlack(SlackOptions{Attachments: true, Color: "#439FE0"}, p)
+	msg = slackMessage{}
+	json.Unmarshal(body, &msg)
+	if len(msg.Attachments) != 1 || msg.Text != "" {
+		t.Fatalf("Expected one attachment, got %s", body)
+	}
+	a := msg.Attachments[0]
+	if a.AuthorName != "alice" || a.Title != "privmsg in #test" || a.Text != "a &lt;b&gt; &amp; c" || a.Ts != 1735732800 || a.Color != "#439FE0" {
+		t.Errorf("Unexpected attachment %+v", a)
+	}
+}
+
+func TestSlackEndpointStripsFormatting(t *testing.T) {
+	t.Setenv("TRIGGER_CONFIG", `{"endpoints":{"chat":{"type":"slack","url":"http://localhost/hook","events":["mention"]}}}`)
+	client := NewClient()
+	if f := client.triggerConfig.Endpoints["chat"].Formatting; f != "strip" {
+		t.Errorf("Slack endpoints should strip IRC formatting by default, got %q", f)
+	}
+}
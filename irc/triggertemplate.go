@@ -0,0 +1,58 @@
+package irc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"text/template"
+)
+
+// triggerTemplateFuncs are available in endpoint templates. json quotes a
+// value, so text from IRC cannot break the surrounding document.
+var triggerTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parseTriggerTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(triggerTemplateFuncs).Parse(text)
+}
+
+// render returns the request body for a payload: a Slack message for slack
+// endpoints, the output of the endpoint's template, or the payload itself as
+// JSON
+func (e TriggerEndpoint) render(payload TriggerPayload) ([]byte, error) {
+	if e.Type == "slack" {
+		return renderSlack(e.Slack, payload)
+	}
+	if e.tmpl == nil {
+		return json.Marshal(payload)
+	}
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("template output is not valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// triggerEncodings are the request body encodings an endpoint can ask for
+var triggerEncodings = map[string]bool{"json": true, "msgpack": true, "cbor": true}
+
+// encode converts a rendered JSON body, a payload or a batch of them, to the
+// endpoint's encoding and returns it with its Content-Type
+func (e TriggerEndpoint) encode(body []byte) ([]byte, string, error) {
+	switch e.Encoding {
+	case "msgpack":
+		b, err := jsonToMsgpack(body)
+		return b, "application/msgpack", err
+	case "cbor":
+		b, err := jsonToCBOR(body)
+		return b, "application/cbor", err
+	}
+	return body, "application/json", nil
+}
@@ -0,0 +1,128 @@
+package irc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTriggerTemplate(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	tmpl := `{"text": {{json (printf "<%s> %s" .Sender .Message)}}, "channel": {{json .Target}}}`
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{"slack":{"url":%q,"events":["privmsg"],"template":%q}}}`, srv.URL, tmpl))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(`:alice!a@host PRIVMSG #test :say "hi"`)
+	select {
+	case body := <-bodies:
+		var got map[string]string
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("Invalid body %s: %v", body, err)
+		}
+		if got["text"] != `<alice> say "hi"` || got["channel"] != "#test" || len(got) != 2 {
+			t.Errorf("Unexpected body %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The endpoint was not called")
+	}
+}
+
+func TestTriggerTemplateInvalidOutput(t *testing.T) {
+	tmpl, err := parseTriggerTemplate("test", `{"text": "{{.Message}}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := TriggerEndpoint{tmpl: tmpl}
+	if _, err := e.render(TriggerPayload{Message: `a "quoted" word`}); err == nil {
+		t.Error("Expected an error for output that is not JSON")
+	}
+	if _, err := parseTriggerTemplate("test", `{{.Message`); err == nil {
+		t.Error("Expected a parse error")
+	}
+}
+
+func TestTriggerEncoding(t *testing.T) {
+	type request struct {
+		contentType string
+		body        []byte
+	}
+	requests := make(chan request, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Get("Content-Type"), body}
+	}))
+	defer srv.Close()
+
+	t.Setenv("TRIGGER_CONFIG", fmt.Sprintf(`{"endpoints":{
+		"mp":{"url":%q,"events":["privmsg"],"channels":["#mp"],"encoding":"msgpack"},
+		"cb":{"url":%q,"events":["privmsg"],"channels":["#cb"],"encoding":"CBOR","template":"{\"n\": 300, \"text\": {{json .Message}}}"}}}`, srv.URL, srv.URL))
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	receive := func() request {
+		select {
+		case req := <-requests:
+			return req
+		case <-time.After(2 * time.Second):
+			t.Fatal("The endpoint was not called")
+		}
+		return request{}
+	}
+	client.handleLine(`:alice!a@host PRIVMSG #mp :hello`)
+	req := receive()
+	if req.contentType != "application/msgpack" {
+		t.Errorf("Expected MessagePack, got %q", req.contentType)
+	}
+	// The payload is a map with a 9-byte eventType key
+	if !bytes.Contains(req.body, append([]byte{0xa9}, "eventType"...)) || !bytes.Contains(req.body, append([]byte{0xa5}, "hello"...)) {
+		t.Errorf("Unexpected MessagePack body % x", req.body)
+	}
+
+	client.handleLine(`:alice!a@host PRIVMSG #cb :hi`)
+	req = receive()
+	want := []byte{0xa2, 0x61, 'n', 0x19, 0x01, 0x2c, 0x64, 't', 'e', 'x', 't', 0x62, 'h', 'i'}
+	if req.contentType != "application/cbor" || !bytes.Equal(req.body, want) {
+		t.Errorf("Expected CBOR % x, got %q % x", want, req.contentType, req.body)
+	}
+}
+
+func TestJSONToCBOR(t *testing.T) {
+	// Examples from RFC 8949 appendix A
+	for _, tc := range []struct {
+		json string
+		want []byte
+	}{
+		{`0`, []byte{0x00}},
+		{`23`, []byte{0x17}},
+		{`24`, []byte{0x18, 0x18}},
+		{`1000`, []byte{0x19, 0x03, 0xe8}},
+		{`1000000`, []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}},
+		{`-1`, []byte{0x20}},
+		{`-1000`, []byte{0x39, 0x03, 0xe7}},
+		{`1.1`, []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{`false`, []byte{0xf4}},
+		{`null`, []byte{0xf6}},
+		{`"ü"`, []byte{0x62, 0xc3, 0xbc}},
+		{`[1, [2, 3]]`, []byte{0x82, 0x01, 0x82, 0x02, 0x03}},
+		{`{"a": 1, "b": [2, 3]}`, []byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x62, 0x82, 0x02, 0x03}},
+	} {
+		got, err := jsonToCBOR([]byte(tc.json))
+		if err != nil || !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: expected % x, got % x (%v)", tc.json, tc.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package irc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// applyUserModes applies a user mode string such as "+iw-x" to the bot's own modes
+func (c *Client) applyUserModes(modeString string) {
+	c.umodeMu.Lock()
+	defer c.umodeMu.Unlock()
+
+	adding := true
+	for _, m := range modeString {
+		switch m {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		default:
+			if adding {
+				if !strings.ContainsRune(c.umodes, m) {
+					c.umodes += string(m)
+				}
+			} else {
+				c.umodes = strings.ReplaceAll(c.umodes, string(m), "")
+			}
+		}
+	}
+	modes := []rune(c.umodes)
+	sort.Slice(modes, func(i, j int) bool { return modes[i] < modes[j] })
+	c.umodes = string(modes)
+}
+
+// resetUserModes forgets the bot's modes, e.g. on a new connection
+func (c *Client) resetUserModes() {
+	c.umodeMu.Lock()
+	defer c.umodeMu.Unlock()
+	c.umodes = ""
+}
+
+// UserModes returns the bot's current user modes, e.g. "+Biw"
+func (c *Client) UserModes() string {
+	c.umodeMu.RLock()
+	defer c.umodeMu.RUnlock()
+	if c.umodes == "" {
+		return ""
+	}
+	return "+" + c.umodes
+}
+
+// SetUserModes sends a MODE change for the bot itself after validating the
+// requested modes against the user modes advertised by the server (RPL_MYINFO)
+func (c *Client) SetUserModes(modeString string) error {
+	modeString = strings.TrimSpace(modeString)
+	if modeString == "" || (modeString[0] != '+' && modeString[0] != '-') {
+		return fmt.Errorf("mode string must start with + or -")
+	}
+	supported := c.getServerInfo().UserModes
+	for _, m := range modeString {
+		if m == '+' || m == '-' {
+			continue
+		}
+		if !(m >= 'a' && m <= 'z') && !(m >= 'A' && m <= 'Z') {
+			return fmt.Errorf("invalid user mode %q", m)
+		}
+		if supported != "" && !strings.ContainsRune(supported, m) {
+			return fmt.Errorf("user mode %q is not supported by the server (supported: %s)", m, supported)
+		}
+	}
+	c.rawf("MODE %s %s", c.Nick(), modeString)
+	return nil
+}
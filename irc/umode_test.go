@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"testing"
+)
+
+func TestUserModeTracking(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	client.testRawCapture = func(string) {}
+
+	client.handleLine(":irc.server.com 221 TestBot +iw")
+	if got := client.UserModes(); got != "+iw" {
+		t.Errorf("Expected +iw from RPL_UMODEIS, got %q", got)
+	}
+
+	client.handleLine(":TestBot MODE TestBot :+B")
+	if got := client.UserModes(); got != "+Biw" {
+		t.Errorf("Expected +Biw after MODE, got %q", got)
+	}
+
+	client.handleLine(":TestBot!u@h MODE TestBot -w+x")
+	if got := client.UserModes(); got != "+Bix" {
+		t.Errorf("Expected +Bix after MODE with params form, got %q", got)
+	}
+
+	// Modes of other users are not tracked as ours
+	client.handleLine(":other!u@h MODE other :+i")
+	if got := client.UserModes(); got != "+Bix" {
+		t.Errorf("Other users' modes should not change ours, got %q", got)
+	}
+
+	client.handleLine(":irc.server.com 001 TestBot :Welcome")
+	if got := client.UserModes(); got != "" {
+		t.Errorf("Expected modes to reset on welcome, got %q", got)
+	}
+}
+
+func TestSetUserModes(t *testing.T) {
+	client := NewClient()
+	client.setNick("TestBot")
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+
+	client.handleLine(":irc.server.com 004 TestBot irc.server.com ircd-1.0 BRgiow biklmnopstv")
+
+	if err := client.SetUserModes("+B-g"); err != nil {
+		t.Fatalf("Expected valid modes to be accepted: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "MODE TestBot +B-g" {
+		t.Errorf("Expected MODE command, got %v", sent)
+	}
+
+	for _, bad := range []string{"B", "+Z", "+1", ""} {
+		if err := client.SetUserModes(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
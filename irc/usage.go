@@ -0,0 +1,242 @@
+package irc
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageRetention is how long hourly usage is kept, and the longest window
+// /api/usage reports
+const usageRetention = 7 * 24 * time.Hour
+
+// UsageCounts counts trigger activity in a time window
+type UsageCounts struct {
+	Triggered int64 `json:"triggered"`           // events forwarded to trigger endpoints
+	Delivered int64 `json:"delivered,omitempty"` // requests answered with 2xx (endpoints only)
+	Failed    int64 `json:"failed,omitempty"`    // requests that failed or were refused (endpoints only)
+	Paused    int64 `json:"paused,omitempty"`    // events held back by the channel's daily cap (channels only)
+}
+
+func (u *UsageCounts) add(o *UsageCounts) {
+	u.Triggered += o.Triggered
+	u.Delivered += o.Delivered
+	u.Failed += o.Failed
+	u.Paused += o.Paused
+}
+
+// usageHour holds the counts of one hour
+type usageHour struct {
+	start     time.Time
+	total     UsageCounts
+	endpoints map[string]*UsageCounts
+	channels  map[string]*UsageCounts // lowercased channel
+}
+
+// usageTracker accounts trigger events per endpoint and channel by the hour,
+// for /api/usage and the daily_cap of channel profiles
+type usageTracker struct {
+	mu     sync.Mutex
+	hours  []*usageHour         // oldest first
+	paused map[string]time.Time // lowercased channel -> day its cap was reached, logged once
+}
+
+// hour returns the counts of the hour containing now, dropping hours past
+// the retention. Must be called with mu held.
+func (u *usageTracker) hour(now time.Time) *usageHour {
+	start := now.Truncate(time.Hour)
+	if n := len(u.hours); n > 0 && u.hours[n-1].start.Equal(start) {
+		return u.hours[n-1]
+	}
+	for len(u.hours) > 0 && now.Sub(u.hours[0].start) > usageRetention {
+		u.hours = u.hours[1:]
+	}
+	h := &usageHour{start: start, endpoints: make(map[string]*UsageCounts), channels: make(map[string]*UsageCounts)}
+	u.hours = append(u.hours, h)
+	return h
+}
+
+func counts(m map[string]*UsageCounts, key string) *UsageCounts {
+	n := m[key]
+	if n == nil {
+		n = &UsageCounts{}
+		m[key] = n
+	}
+	return n
+}
+
+// countForwarded counts an event forwarded to trigger endpoints, for each
+// endpoint and for the channel it happened in
+func (c *Client) countForwarded(target string, endpoints []string) {
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+	h := c.usage.hour(time.Now())
+	h.total.Triggered++
+	for _, name := range endpoints {
+		counts(h.endpoints, name).Triggered++
+	}
+	if isChannelName(target) {
+		counts(h.channels, strings.ToLower(target)).Triggered++
+	}
+}
+
+// countDelivery counts the outcome of a request to an endpoint
+func (c *Client) countDelivery(endpoint string, ok bool) {
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+	h := c.usage.hour(time.Now())
+	n := counts(h.endpoints, endpoint)
+	if ok {
+		n.Delivered++
+		h.total.Delivered++
+	} else {
+		n.Failed++
+		h.total.Failed++
+	}
+}
+
+// startOfDay returns local midnight of the day of t, when daily caps reset
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// usedToday returns the events forwarded from a channel since midnight.
+// Must be called with mu held.
+func (u *usageTracker) usedToday(key string, now time.Time) int64 {
+	midnight := startOfDay(now)
+	var used int64
+	for _, h := range u.hours {
+		if n := h.channels[key]; n != nil && !h.start.Before(midnight) {
+			used += n.Triggered
+		}
+	}
+	return used
+}
+
+// overDailyCap reports whether a channel has used up the daily_cap of its
+// profile. Held back events are counted, and the pause logged once a day.
+func (c *Client) overDailyCap(channel string, profile ChannelProfile) bool {
+	if profile.DailyCap <= 0 || !isChannelName(channel) {
+		return false
+	}
+	key := strings.ToLower(channel)
+	now := time.Now()
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+	if c.usage.usedToday(key, now) < int64(profile.DailyCap) {
+		return false
+	}
+	h := c.usage.hour(now)
+	h.total.Paused++
+	counts(h.channels, key).Paused++
+	if day := startOfDay(now); !c.usage.paused[key].Equal(day) {
+		if c.usage.paused == nil {
+			c.usage.paused = make(map[string]time.Time)
+		}
+		c.usage.paused[key] = day
+		log.Printf("Daily trigger cap of %d reached for %s, forwarding paused until midnight", profile.DailyCap, channel)
+	}
+	return true
+}
+
+// UsageReport is the trigger usage over a window, served by /api/usage
+type UsageReport struct {
+	Window    string                  `json:"window"`
+	Since     time.Time               `json:"since"`
+	Total     UsageCounts             `json:"total"`
+	Endpoints map[string]*UsageCounts `json:"endpoints"`
+	Channels  map[string]*UsageCounts `json:"channels"`
+	Hourly    []UsageHourly           `json:"hourly"`
+	Quotas    []UsageQuota            `json:"quotas,omitempty"`
+}
+
+// UsageHourly is the total of one hour of the window
+type UsageHourly struct {
+	Start time.Time `json:"start"`
+	UsageCounts
+}
+
+// UsageQuota is the state of a channel's daily_cap today
+type UsageQuota struct {
+	Channel   string `json:"channel"`
+	DailyCap  int    `json:"daily_cap"`
+	UsedToday int64  `json:"used_today"`
+	Paused    bool   `json:"paused"`
+}
+
+// Usage returns the trigger usage of the last window, at most the retention
+func (c *Client) Usage(window time.Duration) UsageReport {
+	window = min(window, usageRetention)
+	now := time.Now()
+	since := now.Add(-window).Truncate(time.Hour)
+	report := UsageReport{
+		Window:    window.String(),
+		Since:     since,
+		Endpoints: make(map[string]*UsageCounts),
+		Channels:  make(map[string]*UsageCounts),
+		Hourly:    []UsageHourly{},
+	}
+
+	// Channels that may have a daily cap: profiled, joined or counted
+	seen := make(map[string]bool)
+	var quotaChannels []string
+	addQuota := func(name string) {
+		if name = strings.ToLower(name); name != "*" && !seen[name] {
+			seen[name] = true
+			quotaChannels = append(quotaChannels, name)
+		}
+	}
+	for name := range c.channelProfiles {
+		addQuota(name)
+	}
+	for _, name := range c.Channels() {
+		addQuota(name)
+	}
+
+	c.usage.mu.Lock()
+	for _, h := range c.usage.hours {
+		for name := range h.channels {
+			addQuota(name)
+		}
+		if h.start.Before(since) {
+			continue
+		}
+		for name, n := range h.endpoints {
+			counts(report.Endpoints, name).add(n)
+		}
+		for name, n := range h.channels {
+			counts(report.Channels, name).add(n)
+		}
+		report.Total.add(&h.total)
+		report.Hourly = append(report.Hourly, UsageHourly{Start: h.start, UsageCounts: h.total})
+	}
+	for _, name := range quotaChannels {
+		if limit := c.channelProfile(name).DailyCap; limit > 0 {
+			used := c.usage.usedToday(name, now)
+			report.Quotas = append(report.Quotas, UsageQuota{Channel: name, DailyCap: limit, UsedToday: used, Paused: used >= int64(limit)})
+		}
+	}
+	c.usage.mu.Unlock()
+
+	sort.Slice(report.Quotas, func(i, j int) bool { return report.Quotas[i].Channel < report.Quotas[j].Channel })
+	return report
+}
+
+// handleUsage serves trigger usage over a window:
+// GET /api/usage?window=24h
+func (a *API) handleUsage(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, 400, codeInvalidRequest, "window must be a duration such as 1h or 24h")
+			return
+		}
+		window = d
+	}
+	writeJSON(w, 200, a.bot.Usage(window))
+}
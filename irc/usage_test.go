@@ -0,0 +1,61 @@
+package irc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUsageDailyCap(t *testing.T) {
+	received := newTriggerRecorder(t, "privmsg")
+	t.Setenv("CHANNEL_CONFIG", `{"#capped": {"daily_cap": 2}}`)
+	client := NewClient()
+	client.setNick("TestBot")
+
+	for i := 0; i < 3; i++ {
+		client.handleLine(":alice!a@host PRIVMSG #capped :hello")
+	}
+	client.handleLine(":alice!a@host PRIVMSG #open :hello")
+	for _, want := range []string{"#capped", "#capped", "#open"} {
+		if p := expectTrigger(t, received); p.Target != want {
+			t.Errorf("Expected an event from %s, got %s", want, p.Target)
+		}
+	}
+	expectNoTrigger(t, received)
+
+	// Deliveries are counted once the endpoint has answered
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Usage(time.Hour).Total.Delivered < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req := httptest.NewRequest("GET", "/api/usage?window=1h", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	client.CreateAPI("token").ServeHTTP(rec, req)
+	var report UsageReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil || rec.Code != 200 {
+		t.Fatalf("Expected a usage report, got %d %v", rec.Code, err)
+	}
+	if report.Total.Triggered != 3 || report.Total.Delivered != 3 || report.Total.Paused != 1 {
+		t.Errorf("Unexpected totals: %+v", report.Total)
+	}
+	if n := report.Endpoints["test"]; n == nil || n.Triggered != 3 {
+		t.Errorf("Expected 3 events for the endpoint, got %+v", n)
+	}
+	if n := report.Channels["#capped"]; n == nil || n.Triggered != 2 || n.Paused != 1 {
+		t.Errorf("Expected #capped at 2 with 1 held back, got %+v", n)
+	}
+	if len(report.Quotas) != 1 || !report.Quotas[0].Paused || report.Quotas[0].UsedToday != 2 {
+		t.Errorf("Expected #capped paused, got %+v", report.Quotas)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/usage?window=soon", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	client.CreateAPI("token").ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Errorf("Expected 400 for a bad window, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,229 @@
+package irc
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// WebhookConfig is the WEBHOOK_CONFIG JSON document: inbound webhooks that
+// are rendered with a template and relayed to IRC
+type WebhookConfig struct {
+	Webhooks map[string]*Webhook `json:"webhooks"`
+}
+
+// Webhook is one /api/webhook/{name} endpoint
+type Webhook struct {
+	Type     string   `json:"type,omitempty"`  // "" (template) or "github"
+	Token    string   `json:"token,omitempty"` // shared secret; when empty the API token is required
+	Channels []string `json:"channels"`        // targets the rendered message is sent to
+	Template string   `json:"template"`        // Go text/template applied to the decoded JSON body
+	Notice   bool     `json:"notice,omitempty"`
+	MaxLines int      `json:"max_lines,omitempty"`
+
+	// GitHub webhooks
+	Secret string              `json:"secret,omitempty"` // verifies X-Hub-Signature-256 instead of a token
+	Repos  map[string][]string `json:"repos,omitempty"`  // "owner/repo" -> channels, overriding Channels
+	Events []string            `json:"events,omitempty"` // GitHub events to announce (empty = all supported)
+
+	tmpl *template.Template
+}
+
+// maxWebhookBody limits the size of inbound webhook payloads
+const maxWebhookBody = 1 << 20
+
+var webhookFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, items []any) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"truncate": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n]) + "…"
+		}
+		return s
+	},
+	"default": func(def, v any) any {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"firstline": func(s string) string {
+		line, _, _ := strings.Cut(s, "\n")
+		return line
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func (c *Client) loadWebhookConfig() {
+	configStr := os.Getenv("WEBHOOK_CONFIG")
+	if configStr == "" {
+		return
+	}
+	var cfg WebhookConfig
+	if err := json.Unmarshal([]byte(configStr), &cfg); err != nil {
+		log.Fatalf("FATAL: Invalid WEBHOOK_CONFIG JSON: %v", err)
+	}
+	for name, hook := range cfg.Webhooks {
+		switch hook.Type {
+		case "":
+		case "github":
+			hook.Repos = lowerKeys(hook.Repos)
+		default:
+			log.Fatalf("FATAL: Webhook %s has unknown type %q", name, hook.Type)
+		}
+		if len(hook.Channels) == 0 && len(hook.Repos) == 0 {
+			log.Fatalf("FATAL: Webhook %s has no channels", name)
+		}
+		tmpl, err := template.New(name).Funcs(webhookFuncs).Option("missingkey=zero").Parse(hook.Template)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid template for webhook %s: %v", name, err)
+		}
+		hook.tmpl = tmpl
+		if hook.MaxLines <= 0 {
+			hook.MaxLines = 10
+		}
+	}
+	log.Printf("Loaded %d inbound webhook(s)", len(cfg.Webhooks))
+	c.webhooks = cfg.Webhooks
+}
+
+// render executes the webhook template and returns the non-empty lines
+func (h *Webhook) render(data any) ([]string, error) {
+	var buf bytes.Buffer
+	if err := h.tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line = strings.TrimRight(line, "\r \t"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// relay sends rendered lines to the webhook's channels
+func (h *Webhook) relay(s messageSender, channels, lines []string) {
+	for _, ch := range channels {
+		for _, line := range lines {
+			if h.Notice {
+				s.Notice(ch, line)
+			} else {
+				s.Privmsg(ch, line)
+			}
+		}
+	}
+}
+
+func lowerKeys(m map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+// webhookAuthorized checks the webhook's own token (Bearer header, the
+// X-Hanna-Webhook-Token header or ?token=) or, if it has none, the API tokens
+func (a *API) webhookAuthorized(r *http.Request, h *Webhook) bool {
+	for _, got := range []string{
+		strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		r.Header.Get("X-Hanna-Webhook-Token"),
+		r.URL.Query().Get("token"),
+	} {
+		if got == "" {
+			continue
+		}
+		if h.Token != "" {
+			if subtle.ConstantTimeCompare([]byte(got), []byte(h.Token)) == 1 {
+				return true
+			}
+		} else if a.bot.tokens.valid(got) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleWebhook serves POST /api/webhook/{name}
+func (a *API) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	hook := a.bot.webhooks[name]
+	if hook == nil {
+		writeError(w, 404, codeNotFound, "unknown webhook")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, 405, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, 400, codeInvalidRequest, "could not read body")
+		return
+	}
+	if hook.Type == "github" && hook.Secret != "" {
+		if !verifyGitHubSignature(hook.Secret, r.Header.Get("X-Hub-Signature-256"), body) {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing X-Hub-Signature-256")
+			return
+		}
+	} else if hook.Token == "" && !a.allowAddr(w, r) {
+		// API tokens are only good from API_ALLOWED_IPS, here as elsewhere
+		return
+	} else if !a.webhookAuthorized(r, hook) {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid or missing webhook token")
+		return
+	}
+	if hook.Type == "github" {
+		a.handleGitHubWebhook(w, r, name, hook, body)
+		return
+	}
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		writeError(w, 400, codeInvalidRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	lines, err := hook.render(data)
+	if err != nil {
+		log.Printf("Webhook %s template error: %v", name, err)
+		writeError(w, 422, codeInvalidRequest, fmt.Sprintf("template error: %v", err))
+		return
+	}
+	a.relayWebhook(w, name, hook, hook.Channels, lines)
+}
+
+// relayWebhook sends rendered lines to channels and writes the response
+func (a *API) relayWebhook(w http.ResponseWriter, name string, hook *Webhook, channels, lines []string) {
+	if len(lines) > hook.MaxLines {
+		lines = append(lines[:hook.MaxLines-1:hook.MaxLines-1], fmt.Sprintf("... (%d more lines)", len(lines)-hook.MaxLines+1))
+	}
+	if len(lines) == 0 {
+		writeJSON(w, 200, map[string]any{"status": "skipped", "lines": 0})
+		return
+	}
+	if !a.bot.Connected() {
+		writeError(w, 503, codeNotConnected, "bot not connected")
+		return
+	}
+	a.bot.touchActivity()
+	hook.relay(a.bot, channels, lines)
+	log.Printf("Webhook %s relayed %d line(s) to %s", name, len(lines), strings.Join(channels, ","))
+	writeJSON(w, 200, map[string]any{"status": "ok", "lines": len(lines), "channels": channels})
+}
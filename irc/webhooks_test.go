@@ -0,0 +1,94 @@
+package irc
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWebhookRelay(t *testing.T) {
+	t.Setenv("WEBHOOK_CONFIG", `{"webhooks": {
+		"alerts": {
+			"token": "hook-secret",
+			"channels": ["#ops", "#alerts"],
+			"template": "[{{.status | upper}}] {{.labels.alertname}}{{if .labels.instance}} on {{.labels.instance}}{{end}}\n{{range .extra}}- {{.}}\n{{end}}"
+		},
+		"quiet": {"channels": ["#ops"], "template": "{{.msg}}", "notice": true}
+	}}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	markConnected(client)
+	var sent []string
+	client.testRawCapture = func(s string) { sent = append(sent, s) }
+	api := client.CreateAPI("api-token")
+
+	post := func(path, body, token string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	body := `{"status":"firing","labels":{"alertname":"DiskFull","instance":"db1"},"extra":["90% used"]}`
+	if code := post("/api/webhook/alerts", body, "api-token"); code != 401 {
+		t.Errorf("Webhooks with their own token should reject the API token, got %d", code)
+	}
+	if code := post("/api/webhook/alerts", body, "hook-secret"); code != 200 {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	want := []string{
+		"PRIVMSG #ops :[FIRING] DiskFull on db1",
+		"PRIVMSG #ops :- 90% used",
+		"PRIVMSG #alerts :[FIRING] DiskFull on db1",
+		"PRIVMSG #alerts :- 90% used",
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("Expected %v, sent %v", want, sent)
+	}
+
+	sent = nil
+	if code := post("/api/webhook/quiet?token=api-token", `{"msg":"deploy done"}`, ""); code != 200 || len(sent) != 1 || sent[0] != "NOTICE #ops :deploy done" {
+		t.Errorf("Expected a NOTICE authorized by the API token, got %d %v", code, sent)
+	}
+	if code := post("/api/webhook/quiet", `not json`, "api-token"); code != 400 {
+		t.Errorf("Expected 400 for invalid JSON, got %d", code)
+	}
+	if code := post("/api/webhook/missing", `{}`, "api-token"); code != 404 {
+		t.Errorf("Expected 404 for an unknown webhook, got %d", code)
+	}
+}
+
+func TestWebhookAllowlist(t *testing.T) {
+	t.Setenv("API_ALLOWED_IPS", "10.0.0.0/8")
+	t.Setenv("WEBHOOK_CONFIG", `{"webhooks": {
+		"alerts": {"token": "hook-secret", "channels": ["#ops"], "template": "{{.msg}}"},
+		"quiet": {"channels": ["#ops"], "template": "{{.msg}}"}
+	}}`)
+	client := NewClient()
+	markConnected(client)
+	client.testRawCapture = func(string) {}
+	api := client.CreateAPI("api-token")
+
+	for _, tc := range []struct {
+		path, token, addr string
+		want              int
+	}{
+		{"/api/webhook/quiet", "api-token", "198.51.100.1:5000", 403},
+		{"/api/webhook/quiet", "api-token", "10.1.2.3:5000", 200},
+		// A webhook's own token works from anywhere, for external services
+		{"/api/webhook/alerts", "hook-secret", "198.51.100.1:5000", 200},
+	} {
+		req := httptest.NewRequest("POST", tc.path, strings.NewReader(`{"msg":"hi"}`))
+		req.RemoteAddr = tc.addr
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s from %s: expected %d, got %d", tc.path, tc.addr, tc.want, rec.Code)
+		}
+	}
+}
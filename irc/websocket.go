@@ -0,0 +1,247 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IRCv3 WebSocket subprotocols; with neither, text frames are used
+const (
+	wsProtoBinary = "binary.ircv3.net"
+	wsProtoText   = "text.ircv3.net"
+	wsGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxFrame    = 8 << 20 // bridge gateways send larger payloads than IRC
+)
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsConn adapts an IRC-over-WebSocket connection to a line-oriented stream:
+// every received message is returned followed by CRLF, and every line
+// written is sent as one message
+type wsConn struct {
+	net.Conn
+	br     *bufio.Reader
+	opcode byte // opcode used for outgoing messages
+
+	rbuf    bytes.Buffer // received data not yet returned by Read
+	message []byte       // fragments of the message being received
+
+	wmu     sync.Mutex
+	pending []byte // written data without a line ending yet
+	closed  bool
+}
+
+// dialWebSocket performs the client handshake (RFC 6455) over conn
+func dialWebSocket(ctx context.Context, conn net.Conn, scheme, host, path string) (*wsConn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	origin := "http://" + host
+	if scheme == "wss" {
+		origin = "https://" + host
+	}
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Protocol: %s, %s\r\n"+
+		"Origin: %s\r\nUser-Agent: Hanna-IRC-Bot/%s\r\n\r\n",
+		path, host, key, wsProtoBinary, wsProtoText, origin, Version)
+	if _, err := io.WriteString(conn, req); err != nil {
+		return nil, fmt.Errorf("WebSocket handshake: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("WebSocket handshake: unexpected status %s", resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errors.New("WebSocket handshake: invalid Sec-WebSocket-Accept")
+	}
+
+	ws := &wsConn{Conn: conn, br: br, opcode: wsText}
+	if resp.Header.Get("Sec-WebSocket-Protocol") == wsProtoBinary {
+		ws.opcode = wsBinary
+	}
+	return ws, nil
+}
+
+func (w *wsConn) Read(p []byte) (int, error) {
+	for w.rbuf.Len() == 0 {
+		if err := w.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	return w.rbuf.Read(p)
+}
+
+// readFrame reads one frame, answering control frames and queueing complete
+// messages for Read
+func (w *wsConn) readFrame() error {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(w.br, head); err != nil {
+		return err
+	}
+	fin, opcode := head[0]&0x80 != 0, head[0]&0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(w.br, ext); err != nil {
+			return err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(w.br, ext); err != nil {
+			return err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > wsMaxFrame {
+		return fmt.Errorf("WebSocket frame too large (%d bytes)", length)
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(w.br, mask); err != nil {
+			return err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(w.br, payload); err != nil {
+		return err
+	}
+	for i := range mask {
+		for j := i; j < len(payload); j += 4 {
+			payload[j] ^= mask[i]
+		}
+	}
+
+	switch opcode {
+	case wsPing:
+		return w.writeFrame(wsPong, payload)
+	case wsPong:
+		return nil
+	case wsClose:
+		w.writeFrame(wsClose, nil)
+		return io.EOF
+	case wsText, wsBinary, wsContinuation:
+		w.message = append(w.message, payload...)
+		if len(w.message) > wsMaxFrame {
+			return errors.New("WebSocket message too large")
+		}
+		if fin {
+			w.rbuf.Write(bytes.TrimRight(w.message, "\r\n"))
+			w.rbuf.WriteString("\r\n")
+			w.message = w.message[:0]
+		}
+		return nil
+	default:
+		return fmt.Errorf("unexpected WebSocket opcode %d", opcode)
+	}
+}
+
+// Write sends each complete line as one message; a trailing partial line is
+// kept until its line ending is written
+func (w *wsConn) Write(p []byte) (int, error) {
+	w.wmu.Lock()
+	w.pending = append(w.pending, p...)
+	var lines [][]byte
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimRight(w.pending[:i], "\r")
+		lines = append(lines, append([]byte(nil), line...))
+		w.pending = w.pending[i+1:]
+	}
+	w.wmu.Unlock()
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.writeFrame(w.opcode, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// writeFrame sends one masked frame, as clients must
+func (w *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	if w.closed && opcode != wsClose {
+		return net.ErrClosed
+	}
+	_, err := w.Conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame (best effort) and closes the connection
+func (w *wsConn) Close() error {
+	w.wmu.Lock()
+	already := w.closed
+	w.closed = true
+	w.wmu.Unlock()
+	if !already {
+		w.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		w.writeFrame(wsClose, nil)
+	}
+	return w.Conn.Close()
+}
@@ -0,0 +1,78 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRawWriteTimeout(t *testing.T) {
+	client := NewClient()
+	if err := client.raw("PING :x"); !errors.Is(err, errNotConnected) {
+		t.Errorf("Expected errNotConnected without a connection, got %v", err)
+	}
+
+	// Nobody reads the other end, so the write blocks until its deadline
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	ic := client.attach(context.Background(), conn)
+	client.writeTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if err := client.raw("PRIVMSG #test :hello"); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("The write should give up at its deadline")
+	}
+	if cause := context.Cause(ic.ctx); cause == nil || !strings.HasPrefix(cause.Error(), "write error") {
+		t.Errorf("Expected the connection to end with a write error, got %v", cause)
+	}
+}
+
+func TestRawAPINotConnected(t *testing.T) {
+	client := NewClient()
+	req := httptest.NewRequest("POST", "/api/raw", strings.NewReader(`{"line":"PING :x"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	client.CreateAPI("token").ServeHTTP(rec, req)
+	if rec.Code != 503 {
+		t.Errorf("Expected 503 without a connection, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSendAPIErrors(t *testing.T) {
+	client := NewClient()
+	api := client.CreateAPI("token")
+	post := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"target":"#test","message":"one\ntwo"}`))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, path := range []string{"/api/send", "/api/notice"} {
+		if rec := post(path); rec.Code != 503 || !strings.Contains(rec.Body.String(), "not connected") {
+			t.Errorf("%s: expected 503 without a connection, got %d %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// A closed connection fails the write
+	conn, peer := net.Pipe()
+	peer.Close()
+	client.attach(context.Background(), conn)
+	if rec := post("/api/send"); rec.Code != 500 || !strings.Contains(rec.Body.String(), "write failed") {
+		t.Errorf("Expected 500 for a failed write, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	client = NewClient()
+	client.testRawCapture = func(string) {}
+	api = client.CreateAPI("token")
+	if rec := post("/api/send"); rec.Code != 200 || !strings.Contains(rec.Body.String(), `"sent"`) {
+		t.Errorf("Expected 200 sent, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,299 @@
+package irc
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// XMPPConfig bridges IRC channels with XMPP multi-user chats. The bot
+// connects as an external component (XEP-0114), so the XMPP server needs a
+// component entry for Domain with the same Secret.
+type XMPPConfig struct {
+	Addr   string            `json:"addr"`             // component port of the XMPP server, e.g. localhost:5347
+	Domain string            `json:"domain"`           // component domain, e.g. irc.example.org
+	Secret string            `json:"secret"`           // component secret
+	JID    string            `json:"jid,omitempty"`    // bridge JID (default hanna@<domain>)
+	Nick   string            `json:"nick,omitempty"`   // room nickname (default IRC)
+	Rooms  map[string]string `json:"rooms"`            // IRC channel -> room JID
+	Events []string          `json:"events,omitempty"` // IRC events relayed to XMPP (default privmsg)
+	LoopProtection
+}
+
+// xmppBridge keeps one component stream open and relays groupchat messages
+type xmppBridge struct {
+	client ircRelay
+	cfg    XMPPConfig
+	toRoom map[string]string // lowercased IRC channel -> room JID
+	toIRC  map[string]string // room JID -> IRC channel
+	queue  *bridgeQueue
+
+	mu   sync.Mutex
+	conn net.Conn // nil between sessions
+}
+
+type xmppMessage struct {
+	XMLName xml.Name  `xml:"message"`
+	From    string    `xml:"from,attr,omitempty"`
+	To      string    `xml:"to,attr"`
+	Type    string    `xml:"type,attr,omitempty"`
+	Body    string    `xml:"body,omitempty"`
+	Delay   *struct{} `xml:"urn:xmpp:delay delay"`
+}
+
+type xmppPresence struct {
+	XMLName xml.Name `xml:"presence"`
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Type    string   `xml:"type,attr,omitempty"`
+	MUC     *xmppMUC `xml:"http://jabber.org/protocol/muc x,omitempty"`
+}
+
+type xmppMUC struct {
+	History struct {
+		MaxStanzas int `xml:"maxstanzas,attr"`
+	} `xml:"history"`
+}
+
+func (c *Client) loadXMPPBridge() {
+	cfg := c.triggerConfig.XMPP
+	if cfg == nil {
+		return
+	}
+	if cfg.Addr == "" || cfg.Domain == "" || cfg.Secret == "" || len(cfg.Rooms) == 0 {
+		log.Fatalf("FATAL: XMPP bridge requires addr, domain, secret and at least one room")
+	}
+	x := &xmppBridge{client: c, cfg: *cfg, toRoom: make(map[string]string), toIRC: make(map[string]string)}
+	if x.cfg.JID == "" {
+		x.cfg.JID = "hanna@" + cfg.Domain
+	}
+	if x.cfg.Nick == "" {
+		x.cfg.Nick = "IRC"
+	}
+	x.cfg.Events = validateBridgeEvents("XMPP", cfg.Events)
+	x.cfg.validate("XMPP")
+	for ircChan, room := range cfg.Rooms {
+		x.toRoom[strings.ToLower(ircChan)] = room
+		x.toIRC[strings.ToLower(room)] = ircChan
+	}
+	x.queue = newBridgeQueue("XMPP", x.post)
+	c.xmpp = x
+	c.bus.Subscribe("xmpp", x.relayEvent)
+}
+
+func (x *xmppBridge) relayEvent(e Event) {
+	bridged := func(ch string) bool { return x.toRoom[strings.ToLower(ch)] != "" }
+	for _, m := range bridgeMessages(e, x.cfg.Events, x.cfg.LoopProtection, bridged) {
+		x.queue.push(x.client.context(), m)
+	}
+}
+
+// send writes a stanza on the current stream
+func (x *xmppBridge) send(v any) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.conn == nil {
+		return errors.New("not connected")
+	}
+	x.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = x.conn.Write(data)
+	return err
+}
+
+// post sends an IRC message to its room as the bridge occupant
+func (x *xmppBridge) post(ctx context.Context, m bridgeMessage) error {
+	text := StripFormatting(m.Text)
+	switch {
+	case m.Nick == "":
+	case m.Action:
+		text = fmt.Sprintf("* %s %s", m.Nick, text)
+	default:
+		text = fmt.Sprintf("<%s> %s", m.Nick, text)
+	}
+	return x.send(xmppMessage{
+		From: x.cfg.JID + "/" + x.cfg.Nick,
+		To:   x.toRoom[strings.ToLower(m.Channel)],
+		Type: "groupchat",
+		Body: text,
+	})
+}
+
+// run keeps a component session open until ctx is cancelled
+func (x *xmppBridge) run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := x.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("XMPP bridge: session ended: %v (reconnecting in %s)", err, backoff)
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+		backoff = min(backoff*2, 2*time.Minute)
+	}
+}
+
+// session authenticates the component, joins the rooms and relays messages
+// until the stream ends
+func (x *xmppBridge) session(ctx context.Context) error {
+	conn, err := (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", x.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	context.AfterFunc(sessionCtx, func() { conn.Close() })
+
+	fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:component:accept' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' to='%s'>", xmlEscape(x.cfg.Domain))
+	dec := xml.NewDecoder(conn)
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+	var streamID string
+	for streamID == "" {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("reading stream header: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if se.Name.Local != "stream" {
+				return fmt.Errorf("unexpected <%s> before stream header", se.Name.Local)
+			}
+			for _, a := range se.Attr {
+				if a.Name.Local == "id" {
+					streamID = a.Value
+				}
+			}
+			if streamID == "" {
+				return errors.New("stream header without id")
+			}
+		}
+	}
+	sum := sha1.Sum([]byte(streamID + x.cfg.Secret))
+	fmt.Fprintf(conn, "<handshake>%s</handshake>", hex.EncodeToString(sum[:]))
+
+	se, err := nextElement(dec)
+	if err != nil {
+		return err
+	}
+	if se.Name.Local != "handshake" {
+		dec.Skip()
+		return fmt.Errorf("handshake rejected (<%s>)", se.Name.Local)
+	}
+	dec.Skip()
+	conn.SetReadDeadline(time.Time{})
+	log.Printf("XMPP bridge: connected as component %s", x.cfg.Domain)
+
+	x.mu.Lock()
+	x.conn = conn
+	x.mu.Unlock()
+	defer func() {
+		x.mu.Lock()
+		x.conn = nil
+		x.mu.Unlock()
+	}()
+
+	for _, room := range x.cfg.Rooms {
+		if err := x.send(xmppPresence{From: x.cfg.JID + "/" + x.cfg.Nick, To: room + "/" + x.cfg.Nick, MUC: &xmppMUC{}}); err != nil {
+			return err
+		}
+	}
+
+	// Whitespace keepalive so idle streams are not dropped by NATs
+	go func() {
+		ticker := time.NewTicker(60 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sessionCtx.Done():
+				return
+			case <-ticker.C:
+				x.mu.Lock()
+				if x.conn != nil {
+					x.conn.Write([]byte(" "))
+				}
+				x.mu.Unlock()
+			}
+		}
+	}()
+
+	for {
+		se, err := nextElement(dec)
+		if err != nil {
+			return err
+		}
+		switch se.Name.Local {
+		case "message":
+			var m xmppMessage
+			if err := dec.DecodeElement(&m, &se); err != nil {
+				return err
+			}
+			x.relayMessage(m)
+		case "error":
+			dec.Skip()
+			return errors.New("stream error")
+		default:
+			dec.Skip()
+		}
+	}
+}
+
+// relayMessage posts a room message to its IRC channel, skipping history,
+// subjects and the bridge's own reflected messages
+func (x *xmppBridge) relayMessage(m xmppMessage) {
+	if m.Type != "groupchat" || m.Body == "" || m.Delay != nil {
+		return
+	}
+	room, nick, ok := strings.Cut(m.From, "/")
+	if !ok || nick == x.cfg.Nick {
+		return
+	}
+	ircChan, ok := x.toIRC[strings.ToLower(room)]
+	if !ok {
+		return
+	}
+	x.client.relayToIRC("XMPP", ircChan, nick, m.Body, x.cfg.LoopProtection)
+}
+
+// nextElement returns the next child start element of the stream
+func nextElement(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			if t.Name.Local == "stream" {
+				return xml.StartElement{}, io.EOF
+			}
+		}
+	}
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package irc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeXMPPServer accepts one component connection, checks its handshake
+// and hands the decoded stanzas to stanzas
+func fakeXMPPServer(t *testing.T, secret string, stanzas chan<- any, outgoing <-chan string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		dec := xml.NewDecoder(conn)
+		if _, err := nextStart(dec); err != nil {
+			return
+		}
+		fmt.Fprint(conn, "<?xml version='1.0'?><stream:stream xmlns:stream='http://etherx.jabber.org/streams' xmlns='jabber:component:accept' id='abc123' from='irc.example.org'>")
+		var handshake struct {
+			Hash string `xml:",chardata"`
+		}
+		se, _ := nextStart(dec)
+		dec.DecodeElement(&handshake, &se)
+		sum := sha1.Sum([]byte("abc123" + secret))
+		if handshake.Hash != hex.EncodeToString(sum[:]) {
+			fmt.Fprint(conn, "<stream:error><not-authorized xmlns='urn:ietf:params:xml:ns:xmpp-streams'/></stream:error></stream:stream>")
+			stanzas <- "rejected"
+			return
+		}
+		fmt.Fprint(conn, "<handshake/>")
+		go func() {
+			for s := range outgoing {
+				fmt.Fprint(conn, s)
+			}
+		}()
+		for {
+			se, err := nextStart(dec)
+			if err != nil {
+				return
+			}
+			switch se.Name.Local {
+			case "presence":
+				var p xmppPresence
+				dec.DecodeElement(&p, &se)
+				stanzas <- p
+			case "message":
+				var m xmppMessage
+				dec.DecodeElement(&m, &se)
+				stanzas <- m
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func nextStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se, nil
+		}
+	}
+}
+
+func TestXMPPBridge(t *testing.T) {
+	stanzas := make(chan any, 10)
+	outgoing := make(chan string, 10)
+	addr := fakeXMPPServer(t, "s3cret", stanzas, outgoing)
+	t.Setenv("TRIGGER_CONFIG", `{"xmpp":{"addr":"`+addr+`","domain":"irc.example.org","secret":"s3cret",
+		"rooms":{"#test":"dev@conference.example.org"}}}`)
+	client := NewClient()
+	client.setNick("TestBot")
+	markConnected(client)
+	defer client.Close()
+	relayed := make(chan string, 10)
+	client.testRawCapture = func(s string) { relayed <- s }
+	go client.xmpp.run(client.context())
+
+	next := func() any {
+		t.Helper()
+		select {
+		case s := <-stanzas:
+			return s
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for a stanza")
+			return nil
+		}
+	}
+	p, ok := next().(xmppPresence)
+	if !ok || p.To != "dev@conference.example.org/IRC" || p.From != "hanna@irc.example.org/IRC" || p.MUC == nil {
+		t.Fatalf("Expected a MUC join, got %+v", p)
+	}
+
+	client.handleLine(":alice!a@host PRIVMSG #test :hello \x02xmpp\x02 <&>")
+	m, ok := next().(xmppMessage)
+	if !ok || m.To != "dev@conference.example.org" || m.Type != "groupchat" || m.Body != "<alice> hello xmpp <&>" {
+		t.Errorf("Unexpected groupchat message %+v", m)
+	}
+
+	outgoing <- `<message from='dev@conference.example.org/IRC' to='hanna@irc.example.org/IRC' type='groupchat'><body>&lt;alice&gt; echo</body></message>`
+	outgoing <- `<message from='dev@conference.example.org/carol' type='groupchat'><body>old</body><delay xmlns='urn:xmpp:delay' stamp='2020-01-01T00:00:00Z'/></message>`
+	outgoing <- `<message from='dev@conference.example.org/carol' type='groupchat'><body>hi &amp; bye</body></message>`
+	select {
+	case line := <-relayed:
+		if line != "PRIVMSG #test :<carol> hi & bye" {
+			t.Errorf("Unexpected relayed line %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Room message was not relayed to IRC")
+	}
+}
+
+func TestXMPPBridgeBadSecret(t *testing.T) {
+	stanzas := make(chan any, 1)
+	addr := fakeXMPPServer(t, "right", stanzas, nil)
+	t.Setenv("TRIGGER_CONFIG", `{"xmpp":{"addr":"`+addr+`","domain":"irc.example.org","secret":"wrong","rooms":{"#test":"dev@conference.example.org"}}}`)
+	client := NewClient()
+	defer client.Close()
+	if err := client.xmpp.session(t.Context()); err == nil {
+		t.Error("Expected the session to fail with a wrong secret")
+	}
+	if s := <-stanzas; s != "rejected" {
+		t.Errorf("Expected the server to reject the handshake, got %v", s)
+	}
+}
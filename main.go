@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -31,6 +32,10 @@ const Version = "2.0.0"
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	log.SetOutput(irc.LevelWriter(os.Stderr))
+	if err := irc.SetLogLevel(getenv("LOG_LEVEL", "debug")); err != nil {
+		log.Fatalf("FATAL: Invalid LOG_LEVEL: %v", err)
+	}
 	log.Printf("Hanna IRC Bot v%s starting up...", Version)
 
 	apiToken := os.Getenv("API_TOKEN")
@@ -43,20 +48,49 @@ func main() {
 	if apiTLS && (apiCert == "" || apiKey == "") {
 		log.Fatalf("API_CERT and API_KEY are required when API_TLS=1")
 	}
+	// The certificate is reloaded when its files change or on SIGHUP
+	var certs *irc.CertReloader
+	if apiTLS {
+		var err error
+		if certs, err = irc.NewCertReloader(apiCert, apiKey); err != nil {
+			log.Fatalf("FATAL: Cannot load API_CERT and API_KEY: %v", err)
+		}
+	}
+	tlsConfig, err := irc.APIServerTLS(certs)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if tlsConfig != nil && !apiTLS {
+		log.Fatalf("API_CLIENT_CA requires API_TLS=1")
+	}
 
 	bot := irc.NewClient()
 	sup := NewSupervisor(bot)
 
-	// Run IRC supervisor
-	go sup.Run()
+	// Run IRC supervisor, or replay a recorded session instead of connecting
+	if replayFile := os.Getenv("IRC_REPLAY_FILE"); replayFile != "" {
+		go replay(bot, replayFile)
+	} else {
+		go sup.Run()
+	}
+
+	// Connect chat bridges configured in TRIGGER_CONFIG and the NATS output
+	bot.StartBridges()
+
+	// Let IRC clients attach when BOUNCER_ADDR is set
+	go func() {
+		if err := bot.ServeBouncer(); err != nil {
+			log.Fatalf("bouncer error: %v", err)
+		}
+	}()
 
 	// Start HTTP API using the comprehensive API from the IRC client
-	srv := &http.Server{Addr: apiAddr, Handler: bot.CreateAPI(apiToken)}
+	srv := &http.Server{Addr: apiAddr, Handler: bot.CreateAPI(apiToken), TLSConfig: tlsConfig}
 
 	go func() {
 		if apiTLS {
 			log.Printf("HTTPS API listening on %s", apiAddr)
-			if err := srv.ListenAndServeTLS(apiCert, apiKey); err != nil && err != http.ErrServerClosed {
+			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 				log.Fatalf("https server error: %v", err)
 			}
 		} else {
@@ -67,21 +101,96 @@ func main() {
 		}
 	}()
 
-	// Graceful shutdown
+	// Serve the gRPC interface on its own HTTP/2 listener when GRPC_ADDR is set
+	var grpcSrv *http.Server
+	if grpcAddr := os.Getenv("GRPC_ADDR"); grpcAddr != "" {
+		var protocols http.Protocols
+		if apiTLS {
+			protocols.SetHTTP2(true)
+		} else {
+			protocols.SetUnencryptedHTTP2(true)
+		}
+		grpcSrv = &http.Server{Addr: grpcAddr, Handler: bot.GRPCHandler(apiToken), Protocols: &protocols}
+		if tlsConfig != nil {
+			grpcSrv.TLSConfig = tlsConfig.Clone()
+		}
+		go func() {
+			var err error
+			log.Printf("gRPC API listening on %s", grpcAddr)
+			if apiTLS {
+				err = grpcSrv.ListenAndServeTLS("", "")
+			} else {
+				err = grpcSrv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("grpc server error: %v", err)
+			}
+		}()
+	}
+
+	if certs != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				log.Printf("SIGHUP: reloading the API certificate")
+				if err := certs.Reload(); err != nil {
+					log.Printf("Cannot reload API certificate, keeping the current one: %v", err)
+				}
+			}
+		}()
+	}
+
+	// Graceful shutdown: stop accepting connections, end event streams with
+	// a shutting_down event and deliver queued trigger events, all within
+	// SHUTDOWN_TIMEOUT, then leave IRC
+	drainTimeout, err := strconv.Atoi(getenv("SHUTDOWN_TIMEOUT", "10"))
+	if err != nil || drainTimeout < 0 {
+		log.Fatalf("FATAL: Invalid SHUTDOWN_TIMEOUT %q", os.Getenv("SHUTDOWN_TIMEOUT"))
+	}
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
 	<-sigc
 	log.Printf("shutting down...")
 
-	sup.Stop()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(drainTimeout)*time.Second)
 	defer cancel()
-	_ = srv.Shutdown(ctx)
+	serversDone := make(chan struct{})
+	go func() {
+		_ = srv.Shutdown(ctx)
+		if grpcSrv != nil {
+			_ = grpcSrv.Shutdown(ctx)
+		}
+		close(serversDone)
+	}()
+	bot.Drain(ctx)
+	<-serversDone
+
+	sup.Stop()
 
 	log.Printf("bye")
 }
 
+// replay feeds a recording made with IRC_RECORD_FILE through the bot; the
+// API stays up to inspect the resulting state
+func replay(bot *irc.Client, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("FATAL: Cannot open IRC_REPLAY_FILE: %v", err)
+	}
+	defer f.Close()
+	speed, err := strconv.ParseFloat(getenv("IRC_REPLAY_SPEED", "0"), 64)
+	if err != nil {
+		log.Fatalf("FATAL: Invalid IRC_REPLAY_SPEED: %v", err)
+	}
+	log.Printf("Replaying %s", path)
+	stats, err := bot.Replay(context.Background(), f, speed)
+	if err != nil {
+		log.Printf("Replay stopped: %v", err)
+	}
+	log.Printf("Replayed %d inbound lines (%d outbound lines skipped)", stats.Inbound, stats.Outbound)
+}
+
 // Helper functions
 func getenv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
@@ -99,11 +208,13 @@ func boolenv(key string, def bool) bool {
 
 type Supervisor struct {
 	client *irc.Client
-	stop   chan struct{}
+	ctx    context.Context // cancelled by Stop
+	cancel context.CancelFunc
 }
 
 func NewSupervisor(c *irc.Client) *Supervisor {
-	return &Supervisor{client: c, stop: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Supervisor{client: c, ctx: ctx, cancel: cancel}
 }
 
 func (s *Supervisor) Run() {
@@ -111,32 +222,35 @@ func (s *Supervisor) Run() {
 	max := 2 * time.Minute
 
 	for {
-		select {
-		case <-s.stop:
+		if s.ctx.Err() != nil {
 			log.Printf("Supervisor stopping")
 			return
-		default:
 		}
 
 		log.Printf("Attempting to connect...")
-		ctx := context.Background()
-		if err := s.client.Dial(ctx); err != nil {
+		if err := s.client.Dial(s.ctx); err != nil {
 			log.Printf("dial error: %v", err)
-		} else {
-			log.Printf("Waiting for IRC registration...")
-			time.Sleep(2 * time.Second)
 		}
 
-		// Wait until connection drops
-		for s.client.Connected() {
-			time.Sleep(500 * time.Millisecond)
+		// Wait until the connection (or its registration) ends
+		for s.client.State() != irc.StateDisconnected && sleepCtx(s.ctx, 500*time.Millisecond) {
+		}
+
+		// A connection that registered and later dropped reconnects quickly;
+		// dial and registration failures keep backing off
+		info := s.client.StateInfo()
+		switch {
+		case info.Previous == irc.StateConnected:
+			backoff = time.Second
+		case info.RegistrationFailed():
+			log.Printf("registration failed: %s", info.Reason)
 		}
 
 		// Backoff before reconnect
 		log.Printf("disconnected; reconnecting in %s", backoff)
 		select {
 		case <-time.After(backoff):
-		case <-s.stop:
+		case <-s.ctx.Done():
 			log.Printf("Supervisor stopping during backoff")
 			return
 		}
@@ -149,6 +263,18 @@ func (s *Supervisor) Run() {
 
 func (s *Supervisor) Stop() {
 	log.Printf("Stopping supervisor")
-	close(s.stop)
+	s.cancel()
 	_ = s.client.Close()
+}
+
+// sleepCtx waits for d and reports false if ctx was cancelled first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
\ No newline at end of file